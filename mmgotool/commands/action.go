@@ -0,0 +1,107 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/xzl8028/xenia-utilities/mmgotool/internal/codegen"
+)
+
+//go:embed templates/action/*.tmpl
+var actionTemplates embed.FS
+
+var ActionGenCmd = &cobra.Command{
+	Use:   "action",
+	Short: "Generate a GitHub Action running mmgotool",
+	Long: `Generate the Dockerfile and action.yml of a Docker GitHub Action running an mmgotool command, so other repositories can use the checks by referencing the published action.
+
+The image runs mmgotool with --action, reporting the warnings and errors as workflow annotations. The command and its arguments are the command and args inputs of the action.`,
+	Example: `  codegen action --output .github/actions/i18n-check
+  gen action --command "lint errcheck-i18n" --name "Xenia errcheck" --check`,
+	RunE: actionGenCmdF,
+}
+
+func init() {
+	ActionGenCmd.Flags().String("output", ".", "Folder of the generated Dockerfile and action.yml")
+	ActionGenCmd.Flags().String("name", "Xenia i18n check", "Name of the action")
+	ActionGenCmd.Flags().String("description", "Check that the Xenia translation files are up to date", "Description of the action")
+	ActionGenCmd.Flags().String("command", "i18n check", "Default mmgotool command run by the action")
+	ActionGenCmd.Flags().String("ref", "master", "Branch or tag of xenia-utilities built into the image")
+	ActionGenCmd.Flags().String("go-version", "1.17", "Version of the golang image building mmgotool")
+	ActionGenCmd.Flags().Bool("check", false, "Fail if the generated files are not up to date instead of writing them")
+	CodegenCmd.AddCommand(ActionGenCmd)
+}
+
+type actionData struct {
+	Name        string
+	Description string
+	Command     string
+	Ref         string
+	GoVersion   string
+}
+
+func actionGenCmdF(command *cobra.Command, args []string) error {
+	output, err := command.Flags().GetString("output")
+	if err != nil {
+		return errors.New("Invalid output parameter")
+	}
+	check, err := command.Flags().GetBool("check")
+	if err != nil {
+		return errors.New("Invalid check parameter")
+	}
+	data := actionData{}
+	for flag, value := range map[string]*string{
+		"name":        &data.Name,
+		"description": &data.Description,
+		"command":     &data.Command,
+		"ref":         &data.Ref,
+		"go-version":  &data.GoVersion,
+	} {
+		if *value, err = command.Flags().GetString(flag); err != nil || *value == "" {
+			return fmt.Errorf("Invalid %s parameter", flag)
+		}
+	}
+
+	engine, err := codegen.New("codegen action", actionTemplates, "templates/action/*.tmpl")
+	if err != nil {
+		return err
+	}
+	outdated := 0
+	for _, name := range []string{"Dockerfile", "action.yml"} {
+		content, err := engine.RenderRaw(name, data)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(output, name)
+		if check {
+			upToDate, err := matchesFile(path, content)
+			if err != nil {
+				return err
+			}
+			if !upToDate {
+				fmt.Printf("%s is out of date.\n", path)
+				outdated++
+			}
+			continue
+		}
+		if err := mkdirAll(output, 0755); err != nil {
+			return err
+		}
+		if err := writeFile(path, content, 0644); err != nil {
+			return err
+		}
+		logger.Info("Generated", "path", path)
+	}
+	if outdated > 0 {
+		command.SilenceUsage = true
+		return fmt.Errorf("%d generated files are out of date.", outdated)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// assetLocations maps the prefixes of the asset references to their
+// location in the dist layout produced by the build.
+var assetLocations = []struct {
+	Prefix string
+	Dir    string
+}{
+	{"/static/", "client"},
+	{"client/", "client"},
+	{"fonts/", "fonts"},
+	{"templates/", "templates"},
+}
+
+var assetReferencePattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9_./-])(/static/|client/plugins/|fonts/|templates/)([A-Za-z0-9_.@-]+(?:/[A-Za-z0-9_.@-]+)*)`)
+
+var AssetsCmd = &cobra.Command{
+	Use:   "assets",
+	Short: "Static assets tooling",
+}
+
+var AssetsCheckCmd = &cobra.Command{
+	Use:     "check",
+	Short:   "Check the static asset references",
+	Long:    "Check that every static asset referenced in the source code and in the translations exists in the dist layout produced by the build",
+	Example: "  lint assets check --xenia-dir ../xenia-server --dist-dir ../xenia-server/dist/xenia",
+	RunE:    assetsCheckCmdF,
+}
+
+func init() {
+	addExtractFlags(AssetsCheckCmd)
+	AssetsCheckCmd.Flags().String("dist-dir", "", "Path to the dist layout produced by the build, defaults to dist/xenia under the xenia-dir")
+	AssetsCmd.AddCommand(AssetsCheckCmd)
+	LintCmd.AddCommand(AssetsCmd)
+}
+
+type assetReference struct {
+	Path     string
+	Position string
+}
+
+func (r assetReference) String() string {
+	return fmt.Sprintf("%s: %s", r.Position, r.Path)
+}
+
+// findAssetReferences returns the asset paths mentioned in the text. Paths
+// ending with a slash are prefixes used to build the full path at runtime
+// and are skipped.
+func findAssetReferences(text string) []string {
+	paths := []string{}
+	for _, match := range assetReferencePattern.FindAllStringSubmatchIndex(text, -1) {
+		end := match[5]
+		if end < len(text) && text[end] == '/' {
+			continue
+		}
+		paths = append(paths, text[match[2]:end])
+	}
+	return paths
+}
+
+func sourceAssetReferences(path string) ([]assetReference, error) {
+	src, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	references := []assetReference{}
+	fset := token.NewFileSet()
+	file := fset.AddFile(path, -1, len(src))
+	var s scanner.Scanner
+	s.Init(file, src, nil, 0)
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok != token.STRING {
+			continue
+		}
+		value, err := strconv.Unquote(lit)
+		if err != nil {
+			continue
+		}
+		for _, p := range findAssetReferences(value) {
+			references = append(references, assetReference{Path: p, Position: fset.Position(pos).String()})
+		}
+	}
+	return references, nil
+}
+
+func translationAssetReferences(translations []Translation) []assetReference {
+	references := []assetReference{}
+	for _, t := range translations {
+		texts := []string{}
+		if forms, ok := t.PluralForms(); ok {
+			for _, category := range pluralCategoriesOrder {
+				if text, ok := forms[category]; ok {
+					texts = append(texts, text)
+				}
+			}
+		} else if text, ok := t.Translation.(string); ok {
+			texts = append(texts, text)
+		}
+		for _, text := range texts {
+			for _, p := range findAssetReferences(text) {
+				references = append(references, assetReference{Path: p, Position: "i18n/en.json " + t.Id})
+			}
+		}
+	}
+	return references
+}
+
+// assetDistPath returns the location of the referenced asset in the dist
+// layout.
+func assetDistPath(distDir, reference string) string {
+	for _, location := range assetLocations {
+		if strings.HasPrefix(reference, location.Prefix) {
+			return filepath.Join(distDir, location.Dir, filepath.FromSlash(strings.TrimPrefix(reference, location.Prefix)))
+		}
+	}
+	return filepath.Join(distDir, filepath.FromSlash(reference))
+}
+
+func assetsCheckCmdF(command *cobra.Command, args []string) error {
+	opts, err := getExtractOptions(command)
+	if err != nil {
+		return err
+	}
+	distDir, err := command.Flags().GetString("dist-dir")
+	if err != nil {
+		return errors.New("Invalid dist-dir parameter")
+	}
+	if distDir == "" {
+		distDir = filepath.Join(opts.XeniaDir, "dist", "xenia")
+	}
+	if info, err := os.Stat(distDir); err != nil || !info.IsDir() {
+		return fmt.Errorf("Unable to find the dist layout at %s, build the server first.", distDir)
+	}
+	command.SilenceUsage = true
+
+	references := []assetReference{}
+	var walkErr error
+	walkSourceFiles(opts, func(p string) {
+		found, err := sourceAssetReferences(p)
+		if err != nil {
+			walkErr = err
+			return
+		}
+		references = append(references, found...)
+	})
+	if walkErr != nil {
+		return walkErr
+	}
+
+	translations, err := getCurrentTranslations(opts.XeniaDir)
+	if err != nil {
+		return err
+	}
+	references = append(references, translationAssetReferences(translations)...)
+
+	missing := []assetReference{}
+	for _, reference := range references {
+		if _, err := os.Stat(assetDistPath(distDir, reference.Path)); os.IsNotExist(err) {
+			missing = append(missing, reference)
+		}
+	}
+
+	for _, reference := range missing {
+		fmt.Println(reference.String())
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%d static asset references not found in %s.", len(missing), distDir)
+	}
+	fmt.Printf("All %d static asset references found.\n", len(references))
+	return nil
+}
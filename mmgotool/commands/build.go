@@ -0,0 +1,160 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultBuildTargets are the platforms the server is released for.
+var defaultBuildTargets = []string{
+	"linux/amd64",
+	"linux/arm64",
+	"darwin/amd64",
+	"darwin/arm64",
+	"windows/amd64",
+	"freebsd/amd64",
+}
+
+var BuildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "Build tooling",
+}
+
+var BuildMatrixCmd = &cobra.Command{
+	Use:   "matrix",
+	Short: "Cross compilation matrix",
+}
+
+var BuildMatrixVerifyCmd = &cobra.Command{
+	Use:     "verify",
+	Short:   "Cross compile for every supported platform",
+	Long:    "Compile the packages for every GOOS/GOARCH target and report the failures of each target in one summary",
+	Example: "  release build matrix verify --package ./cmd/xenia --tags enterprise --target linux/amd64 --target windows/amd64",
+	RunE:    buildMatrixVerifyCmdF,
+}
+
+func init() {
+	BuildMatrixVerifyCmd.Flags().String("dir", "./", "Path to the Go module to compile")
+	BuildMatrixVerifyCmd.Flags().StringArray("package", []string{"./..."}, "Package to compile, can be repeated")
+	BuildMatrixVerifyCmd.Flags().StringArray("target", defaultBuildTargets, "GOOS/GOARCH target, can be repeated")
+	BuildMatrixVerifyCmd.Flags().String("tags", "", "Comma separated build tags")
+	BuildMatrixVerifyCmd.Flags().Bool("cgo", false, "Compile with CGO_ENABLED=1")
+	BuildMatrixCmd.AddCommand(BuildMatrixVerifyCmd)
+	BuildCmd.AddCommand(BuildMatrixCmd)
+	ReleaseCmd.AddCommand(BuildCmd)
+}
+
+type buildTargetResult struct {
+	Target   string
+	Duration time.Duration
+	Output   string
+	Err      error
+}
+
+func buildTarget(dir, target, tags string, cgo bool, packages []string) buildTargetResult {
+	parts := strings.SplitN(target, "/", 2)
+	if len(parts) != 2 {
+		return buildTargetResult{Target: target, Err: fmt.Errorf("invalid target, expected GOOS/GOARCH")}
+	}
+
+	outputDir, err := ioutil.TempDir("", "mmgotool-build-")
+	if err != nil {
+		return buildTargetResult{Target: target, Err: err}
+	}
+	defer os.RemoveAll(outputDir)
+
+	args := []string{"build", "-o", outputDir + string(os.PathSeparator)}
+	if tags != "" {
+		args = append(args, "-tags", tags)
+	}
+	args = append(args, packages...)
+
+	cgoEnabled := "0"
+	if cgo {
+		cgoEnabled = "1"
+	}
+	cmd := execCommand("go", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS="+parts[0], "GOARCH="+parts[1], "CGO_ENABLED="+cgoEnabled)
+
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	return buildTargetResult{Target: target, Duration: time.Since(start), Output: string(output), Err: err}
+}
+
+func buildMatrixVerifyCmdF(command *cobra.Command, args []string) error {
+	dir, err := command.Flags().GetString("dir")
+	if err != nil {
+		return errors.New("Invalid dir parameter")
+	}
+	packages, err := command.Flags().GetStringArray("package")
+	if err != nil || len(packages) == 0 {
+		return errors.New("Invalid package parameter")
+	}
+	targets, err := command.Flags().GetStringArray("target")
+	if err != nil {
+		return errors.New("Invalid target parameter")
+	}
+	tags, err := command.Flags().GetString("tags")
+	if err != nil {
+		return errors.New("Invalid tags parameter")
+	}
+	cgo, err := command.Flags().GetBool("cgo")
+	if err != nil {
+		return errors.New("Invalid cgo parameter")
+	}
+	results := []buildTargetResult{}
+	for _, target := range targets {
+		logger.Info("Compiling", "target", target)
+		results = append(results, buildTarget(dir, target, tags, cgo, packages))
+	}
+
+	failures := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TARGET\tRESULT\tDURATION\tERROR")
+	for _, result := range results {
+		status := "ok"
+		firstLine := ""
+		if result.Err != nil {
+			status = "FAIL"
+			failures++
+			firstLine = firstErrorLine(result.Output, result.Err)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.Target, status, result.Duration.Round(time.Millisecond), firstLine)
+	}
+	w.Flush()
+
+	if logger.Verbose() {
+		for _, result := range results {
+			if result.Err != nil {
+				fmt.Printf("\n== %s ==\n%s", result.Target, result.Output)
+			}
+		}
+	}
+
+	if failures > 0 {
+		command.SilenceUsage = true
+		return fmt.Errorf("%d of %d targets failed to compile.", failures, len(results))
+	}
+	return nil
+}
+
+func firstErrorLine(output string, err error) string {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return line
+		}
+	}
+	return err.Error()
+}
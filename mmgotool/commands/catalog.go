@@ -0,0 +1,272 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	CatalogFormatArray = "array"
+	CatalogFormatMap   = "map"
+	CatalogFormatSplit = "split"
+)
+
+// catalogFormat is the format of the translation files written, set with
+// --catalog-format. When empty the files keep their format, and the new
+// ones are arrays.
+var catalogFormat string
+
+func init() {
+	I18nCmd.PersistentFlags().String("catalog-format", "", "Format of the written translation files: array of {id, translation} objects or {\"id\": translation} map, the one of the existing file by default. The map format keeps the other fields of the strings, like their module, in a reserved \""+catalogStringsId+"\" entry, the files are read in both")
+	I18nCmd.PersistentFlags().String("catalog-metadata", CatalogMetadataNone, "Metadata written with the English catalogs, the tool version, the extraction time, the commit and the key count: none, sidecar for an en.json.meta file or entry for a reserved \""+catalogMetaId+"\" entry")
+}
+
+// configureCatalogFormat reads --catalog-format and --catalog-metadata, for
+// the commands having them.
+func configureCatalogFormat(command *cobra.Command) error {
+	if command.Flags().Lookup("catalog-format") == nil {
+		return nil
+	}
+	format, err := command.Flags().GetString("catalog-format")
+	if err != nil {
+		return errors.New("Invalid catalog-format parameter")
+	}
+	if format != "" && format != CatalogFormatArray && format != CatalogFormatMap {
+		return fmt.Errorf("Unknown catalog format %s", format)
+	}
+	metadata, err := command.Flags().GetString("catalog-metadata")
+	if err != nil {
+		return errors.New("Invalid catalog-metadata parameter")
+	}
+	if metadata != CatalogMetadataNone && metadata != CatalogMetadataSidecar && metadata != CatalogMetadataEntry {
+		return fmt.Errorf("Unknown catalog metadata %s", metadata)
+	}
+	catalogFormat, catalogMetadata = format, metadata
+	return nil
+}
+
+// catalogFileFormat returns the format to write a translation file in: the
+// one of --catalog-format, else the one of the existing file.
+func catalogFileFormat(filePath string) string {
+	if catalogFormat != "" {
+		return catalogFormat
+	}
+	data, err := readFile(filePath)
+	if err != nil {
+		return CatalogFormatArray
+	}
+	return catalogDataFormat(data)
+}
+
+// catalogDataFormat detects the format of the content of a translation file.
+func catalogDataFormat(data []byte) string {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return CatalogFormatMap
+	}
+	return CatalogFormatArray
+}
+
+// decodeTranslations reads a translation file in the array or in the map
+// format, the map entries sorted by id.
+func decodeTranslations(data []byte) ([]Translation, error) {
+	defer i18nProfile.phase("decode")()
+	if catalogDataFormat(data) == CatalogFormatArray {
+		var translations []Translation
+		err := json.Unmarshal(data, &translations)
+		return dropCatalogMeta(translations), err
+	}
+	values := map[string]interface{}{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	fields := map[string]stringFields{}
+	if raw, ok := values[catalogStringsId]; ok {
+		encoded, err := json.Marshal(raw)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(encoded, &fields); err != nil {
+			return nil, fmt.Errorf("Invalid %s entry: %s", catalogStringsId, err.Error())
+		}
+	}
+	translations := make([]Translation, 0, len(values))
+	for id, value := range values {
+		if id == catalogMetaId || id == catalogStringsId {
+			continue
+		}
+		f := fields[id]
+		translations = append(translations, Translation{Id: id, Translation: value, Description: f.Description, Expires: f.Expires, Fuzzy: f.Fuzzy, Module: f.Module})
+	}
+	sort.Slice(translations, func(i, j int) bool { return translations[i].Id < translations[j].Id })
+	return translations, nil
+}
+
+// stringFields are the fields of a string other than its translation, kept
+// by the map format in its catalogStringsId entry.
+type stringFields struct {
+	Description string `json:"description,omitempty"`
+	Expires     string `json:"expires,omitempty"`
+	Fuzzy       bool   `json:"fuzzy,omitempty"`
+	Module      string `json:"module,omitempty"`
+}
+
+// encodeCatalog writes translations in the format. The map format writes
+// the fields other than the translations in its catalogStringsId entry, left
+// out when no string has any.
+func encodeCatalog(translations []Translation, format string) ([]byte, error) {
+	defer i18nProfile.phase("encode")()
+	if format != CatalogFormatMap {
+		return encodeTranslations(translations)
+	}
+	values := map[string]interface{}{}
+	fields := map[string]stringFields{}
+	for _, t := range translations {
+		values[t.Id] = t.Translation
+		if f := (stringFields{Description: t.Description, Expires: t.Expires, Fuzzy: t.Fuzzy, Module: t.Module}); f != (stringFields{}) {
+			fields[t.Id] = f
+		}
+	}
+	if len(fields) > 0 {
+		values[catalogStringsId] = fields
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(values); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Catalog is a format independent representation of a translation file.
+type Catalog struct {
+	Path         string
+	Format       string
+	Translations map[string]interface{}
+}
+
+func (c *Catalog) Ids() []string {
+	ids := []string{}
+	for id := range c.Translations {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// loadCatalog reads a translation catalog, detecting whether it is stored as
+// an array of {id, translation} objects, as a {"id": translation} map or split
+// across several json files inside a directory.
+func loadCatalog(catalogPath string) (*Catalog, error) {
+	info, err := os.Stat(catalogPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return loadSplitCatalog(catalogPath)
+	}
+
+	if err := sandbox.checkPath(catalogPath); err != nil {
+		return nil, err
+	}
+	data, err := readFile(catalogPath)
+	if err != nil {
+		return nil, err
+	}
+
+	translations, format, err := parseCatalog(data)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse %s: %s", catalogPath, err.Error())
+	}
+	return &Catalog{Path: catalogPath, Format: format, Translations: translations}, nil
+}
+
+func parseCatalog(data []byte) (map[string]interface{}, string, error) {
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "{") {
+		translations := map[string]interface{}{}
+		if err := json.Unmarshal(data, &translations); err != nil {
+			return nil, "", err
+		}
+		delete(translations, catalogStringsId)
+		return translations, CatalogFormatMap, nil
+	}
+
+	var list []Translation
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, "", err
+	}
+	translations := map[string]interface{}{}
+	for _, t := range list {
+		translations[t.Id] = t.Translation
+	}
+	return translations, CatalogFormatArray, nil
+}
+
+func loadSplitCatalog(dir string) (*Catalog, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	translations := map[string]interface{}{}
+	for _, file := range files {
+		part, err := loadCatalog(file)
+		if err != nil {
+			return nil, err
+		}
+		for id, value := range part.Translations {
+			if _, exists := translations[id]; exists {
+				return nil, fmt.Errorf("Translation id %s defined more than once in %s", id, dir)
+			}
+			translations[id] = value
+		}
+	}
+	return &Catalog{Path: dir, Format: CatalogFormatSplit, Translations: translations}, nil
+}
+
+// localeFiles returns the translation files of every locale except English,
+// the enterprise catalog of English included.
+func localeFiles(xeniaDir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(xeniaDir, "i18n", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	locales := []string{}
+	for _, file := range files {
+		if name := filepath.Base(file); name != "en.json" && name != enterpriseCatalogFile {
+			locales = append(locales, file)
+		}
+	}
+	sort.Strings(locales)
+	return locales, nil
+}
+
+func localeName(file string) string {
+	return strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+}
+
+// localeTagPattern matches the locale tags naming the translation files, like
+// es, pt-BR or zh_Hant.
+var localeTagPattern = regexp.MustCompile(`^[a-zA-Z]{2,3}([-_][a-zA-Z0-9]{2,8})*$`)
+
+// isLocaleTag tells whether the locale can name a translation file of the
+// i18n folder.
+func isLocaleTag(locale string) bool {
+	return localeTagPattern.MatchString(locale)
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	CatalogMetadataNone    = "none"
+	CatalogMetadataSidecar = "sidecar"
+	CatalogMetadataEntry   = "entry"
+)
+
+const (
+	// catalogMetaId is the reserved id of the metadata entry of the
+	// English catalogs, never read as a translation.
+	catalogMetaId = "_meta"
+	// catalogStringsId is the reserved id of the map catalogs keeping the
+	// fields of the strings other than their translation.
+	catalogStringsId = "_strings"
+	// catalogMetaSuffix is appended to the path of a catalog to get its
+	// sidecar metadata file, like i18n/en.json.meta.
+	catalogMetaSuffix = ".meta"
+	// catalogMetaSchema is the version of the metadata and of the catalog
+	// layout. Catalogs written with a newer schema are refused by check.
+	catalogMetaSchema = 1
+)
+
+// catalogMetadata is where extraction writes the metadata of the English
+// catalogs, set with --catalog-metadata.
+var catalogMetadata = CatalogMetadataNone
+
+// catalogMeta tells which tool and which commit produced an English catalog.
+type catalogMeta struct {
+	Schema      int    `json:"schema"`
+	ToolVersion string `json:"tool_version"`
+	ExtractedAt string `json:"extracted_at"`
+	Commit      string `json:"commit,omitempty"`
+	Keys        int    `json:"keys"`
+}
+
+func newCatalogMeta(xeniaDir string, keys int) catalogMeta {
+	meta := catalogMeta{Schema: catalogMetaSchema, ToolVersion: Version, ExtractedAt: time.Now().UTC().Format(time.RFC3339), Keys: keys}
+	// Without git, or with --no-exec, the commit is left out.
+	if commit, err := gitHead(xeniaDir); err == nil {
+		meta.Commit = commit
+	}
+	return meta
+}
+
+// withCatalogMeta returns the translations of a catalog to write, with the
+// metadata entry first when --catalog-metadata is entry.
+func withCatalogMeta(translations []Translation, meta catalogMeta) []Translation {
+	if catalogMetadata != CatalogMetadataEntry {
+		return translations
+	}
+	return append([]Translation{{Id: catalogMetaId, Translation: meta}}, translations...)
+}
+
+// writeCatalogMetaSidecar writes the metadata of a catalog next to it when
+// --catalog-metadata is sidecar, else removes the one left by a previous
+// extraction, which no longer describes the catalog.
+func writeCatalogMetaSidecar(filePath string, meta catalogMeta) error {
+	if catalogMetadata != CatalogMetadataSidecar {
+		if err := removeFile(filePath + catalogMetaSuffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(filePath+catalogMetaSuffix, append(data, '\n'), 0644)
+}
+
+// dropCatalogMeta removes the metadata entry from decoded translations.
+func dropCatalogMeta(translations []Translation) []Translation {
+	for i, t := range translations {
+		if t.Id == catalogMetaId {
+			return append(translations[:i:i], translations[i+1:]...)
+		}
+	}
+	return translations
+}
+
+// readCatalogMeta returns the metadata of a catalog, from its sidecar file
+// or from its metadata entry, nil when it has none.
+func readCatalogMeta(filePath string) (*catalogMeta, error) {
+	var raw json.RawMessage
+	if data, err := readFile(filePath + catalogMetaSuffix); err == nil {
+		raw = data
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	} else {
+		data, err := readFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		if catalogDataFormat(data) == CatalogFormatArray {
+			var entries []struct {
+				Id          string          `json:"id"`
+				Translation json.RawMessage `json:"translation"`
+			}
+			if err := json.Unmarshal(data, &entries); err != nil {
+				return nil, fmt.Errorf("Unable to parse %s: %s", filePath, err.Error())
+			}
+			for _, entry := range entries {
+				if entry.Id == catalogMetaId {
+					raw = entry.Translation
+				}
+			}
+		} else {
+			values := map[string]json.RawMessage{}
+			if err := json.Unmarshal(data, &values); err != nil {
+				return nil, fmt.Errorf("Unable to parse %s: %s", filePath, err.Error())
+			}
+			raw = values[catalogMetaId]
+		}
+	}
+	if raw == nil {
+		return nil, nil
+	}
+	meta := &catalogMeta{}
+	if err := json.Unmarshal(raw, meta); err != nil {
+		return nil, fmt.Errorf("Invalid metadata of %s: %s", filePath, err.Error())
+	}
+	return meta, nil
+}
+
+// majorVersion returns the major version of a release like v5.3.0, empty
+// for the development builds.
+func majorVersion(version string) string {
+	if version == "" || version == "dev" {
+		return ""
+	}
+	return strings.SplitN(strings.TrimPrefix(version, "v"), ".", 2)[0]
+}
+
+// checkCatalogMeta fails when the catalog was produced by a tool version
+// incompatible with this one: a newer metadata schema or another major
+// version. The development builds are compatible with every version.
+func checkCatalogMeta(filePath string, meta *catalogMeta) error {
+	if meta == nil {
+		return nil
+	}
+	if meta.Schema > catalogMetaSchema {
+		return fmt.Errorf("%s was produced by mmgotool %s with the catalog schema %d, this version only knows the schema %d, upgrade mmgotool.", filePath, meta.ToolVersion, meta.Schema, catalogMetaSchema)
+	}
+	produced, current := majorVersion(meta.ToolVersion), majorVersion(Version)
+	if produced != "" && current != "" && produced != current {
+		return fmt.Errorf("%s was produced by mmgotool %s, incompatible with this version %s, run i18n extract or check --fix with the same major version.", filePath, meta.ToolVersion, Version)
+	}
+	return nil
+}
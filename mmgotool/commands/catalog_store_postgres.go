@@ -0,0 +1,154 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+
+	_ "github.com/lib/pq"
+)
+
+const defaultCatalogTable = "translation_overrides"
+
+var sqlIdentifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// postgresCatalogStore keeps the catalogs in a table with a row per locale
+// and id. The table, translation_overrides unless the table parameter of the
+// URL names another one, is created when missing:
+//
+//	CREATE TABLE translation_overrides (locale text, id text, translation text, PRIMARY KEY (locale, id))
+//
+// The translation column holds the JSON value of the translation, a string
+// or the object of the plural forms.
+type postgresCatalogStore struct {
+	location string
+	dsn      string
+	table    string
+}
+
+func newPostgresCatalogStore(location string) (*postgresCatalogStore, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid Postgres location: %s", err.Error())
+	}
+	query := u.Query()
+	table := query.Get("table")
+	if table == "" {
+		table = defaultCatalogTable
+	}
+	if !sqlIdentifierPattern.MatchString(table) {
+		return nil, fmt.Errorf("Invalid table name %s.", table)
+	}
+	// The other parameters are connection settings of the driver.
+	query.Del("table")
+	u.RawQuery = query.Encode()
+	redacted := *u
+	redacted.User = nil
+	return &postgresCatalogStore{location: redacted.String(), dsn: u.String(), table: table}, nil
+}
+
+func (s *postgresCatalogStore) open() (*sql.DB, error) {
+	if err := sandbox.checkNetwork(); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("postgres", s.dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS " + s.table + " (locale text NOT NULL, id text NOT NULL, translation text NOT NULL, PRIMARY KEY (locale, id))"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Unable to prepare the %s table: %s", s.table, err.Error())
+	}
+	return db, nil
+}
+
+func (s *postgresCatalogStore) Locales() ([]string, error) {
+	db, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT DISTINCT locale FROM " + s.table + " ORDER BY locale")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	locales := []string{}
+	for rows.Next() {
+		var locale string
+		if err := rows.Scan(&locale); err != nil {
+			return nil, err
+		}
+		locales = append(locales, locale)
+	}
+	return locales, rows.Err()
+}
+
+func (s *postgresCatalogStore) Load(locale string) (*Catalog, error) {
+	db, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT id, translation FROM "+s.table+" WHERE locale = $1", locale)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	translations := map[string]interface{}{}
+	for rows.Next() {
+		var id, value string
+		if err := rows.Scan(&id, &value); err != nil {
+			return nil, err
+		}
+		var translation interface{}
+		if err := json.Unmarshal([]byte(value), &translation); err != nil {
+			return nil, fmt.Errorf("Invalid translation of %s for %s: %s", id, locale, err.Error())
+		}
+		translations[id] = translation
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &Catalog{Path: s.location + "#" + locale, Format: CatalogFormatMap, Translations: translations}, nil
+}
+
+// Save replaces the rows of the locale in one transaction.
+func (s *postgresCatalogStore) Save(locale string, data []byte) error {
+	translations, _, err := parseCatalog(data)
+	if err != nil {
+		return err
+	}
+	catalog := &Catalog{Translations: translations}
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM "+s.table+" WHERE locale = $1", locale); err != nil {
+		return err
+	}
+	for _, id := range catalog.Ids() {
+		value, err := json.Marshal(catalog.Translations[id])
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO "+s.table+" (locale, id, translation) VALUES ($1, $2, $3)", locale, id, string(value)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,256 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3CatalogStore keeps the catalogs as <prefix>/<locale>.json objects of an
+// S3 bucket. The requests are signed with the AWS_ACCESS_KEY_ID and
+// AWS_SECRET_ACCESS_KEY credentials, AWS_ENDPOINT_URL selects S3 compatible
+// servers like MinIO.
+type s3CatalogStore struct {
+	bucket       string
+	prefix       string
+	region       string
+	endpoint     string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	client       *http.Client
+}
+
+func newS3CatalogStore(location string) (*s3CatalogStore, error) {
+	u, err := url.Parse(location)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("Invalid S3 location %s, expected s3://bucket/prefix.", location)
+	}
+	store := &s3CatalogStore{
+		bucket:       u.Host,
+		prefix:       strings.Trim(u.Path, "/"),
+		region:       firstNonEmpty(os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), "us-east-1"),
+		endpoint:     strings.TrimRight(os.Getenv("AWS_ENDPOINT_URL"), "/"),
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		client:       &http.Client{Timeout: 30 * time.Second},
+	}
+	if store.accessKey == "" || store.secretKey == "" {
+		return nil, errors.New("S3 credentials are missing, set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.")
+	}
+	if store.endpoint == "" {
+		store.endpoint = "https://s3." + store.region + ".amazonaws.com"
+	}
+	return store, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func (s *s3CatalogStore) key(locale string) string {
+	return path.Join(s.prefix, locale+".json")
+}
+
+type s3ListResult struct {
+	Contents []struct {
+		Key string
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+func (s *s3CatalogStore) Locales() ([]string, error) {
+	prefix := s.prefix
+	if prefix != "" {
+		prefix += "/"
+	}
+	locales := []string{}
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		data, err := s.do(http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result s3ListResult
+		if err := xml.Unmarshal(data, &result); err != nil {
+			return nil, fmt.Errorf("Unable to parse the S3 listing: %s", err.Error())
+		}
+		for _, object := range result.Contents {
+			name := strings.TrimPrefix(object.Key, prefix)
+			if !strings.Contains(name, "/") && strings.HasSuffix(name, ".json") {
+				locales = append(locales, strings.TrimSuffix(name, ".json"))
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	sort.Strings(locales)
+	return locales, nil
+}
+
+func (s *s3CatalogStore) Load(locale string) (*Catalog, error) {
+	data, err := s.do(http.MethodGet, s.key(locale), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	location := "s3://" + s.bucket + "/" + s.key(locale)
+	translations, format, err := parseCatalog(data)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse %s: %s", location, err.Error())
+	}
+	return &Catalog{Path: location, Format: format, Translations: translations}, nil
+}
+
+func (s *s3CatalogStore) Save(locale string, data []byte) error {
+	_, err := s.do(http.MethodPut, s.key(locale), nil, data)
+	return err
+}
+
+// do sends a signed request for an object of the bucket, or for the bucket
+// itself when the key is empty, and returns the response body.
+func (s *s3CatalogStore) do(method, key string, query url.Values, body []byte) ([]byte, error) {
+	canonicalURI := "/" + s3Escape(s.bucket, false)
+	if key != "" {
+		canonicalURI += "/" + s3Escape(key, false)
+	}
+	canonicalQuery := s3CanonicalQuery(query)
+	requestURL := s.endpoint + canonicalURI
+	if canonicalQuery != "" {
+		requestURL += "?" + canonicalQuery
+	}
+	request, err := http.NewRequest(method, requestURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	s.sign(request, canonicalURI, canonicalQuery, body, time.Now().UTC())
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode == http.StatusNotFound && key != "" {
+		return nil, fmt.Errorf("s3://%s/%s does not exist", s.bucket, key)
+	}
+	if response.StatusCode >= 300 {
+		return nil, fmt.Errorf("S3 request %s %s failed with %s: %s", method, canonicalURI, response.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+// sign adds the AWS Signature Version 4 headers to the request.
+func (s *s3CatalogStore) sign(request *http.Request, canonicalURI, canonicalQuery string, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.sessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name := range request.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(request.Header.Get(name))
+	}
+	names := []string{}
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{request.Method, canonicalURI, canonicalQuery, canonicalHeaders.String(), signedHeaders, payloadHash}, "\n")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	for _, part := range []string{s.region, "s3", "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.accessKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape percent encodes everything but the unreserved characters, and
+// the slashes of object keys.
+func s3Escape(value string, escapeSlash bool) string {
+	var buf strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			buf.WriteByte(c)
+		case c == '/' && !escapeSlash:
+			buf.WriteByte(c)
+		default:
+			fmt.Fprintf(&buf, "%%%02X", c)
+		}
+	}
+	return buf.String()
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	keys := []string{}
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := []string{}
+	for _, key := range keys {
+		for _, value := range query[key] {
+			pairs = append(pairs, s3Escape(key, true)+"="+s3Escape(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
@@ -0,0 +1,185 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, file, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMapCatalogKeepsStringFields(t *testing.T) {
+	translations := []Translation{
+		{Id: "api.user.get.app_error", Translation: "Unable to get the user."},
+		{Id: "ent.ldap.sync.app_error", Translation: "Unable to sync.", Module: "enterprise", Description: "Shown in the System Console"},
+		{Id: "web.beta.banner", Translation: "Beta", Expires: "v6.0", Fuzzy: true},
+	}
+	data, err := encodeCatalog(translations, CatalogFormatMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format := catalogDataFormat(data); format != CatalogFormatMap {
+		t.Fatalf("got format %s, want %s", format, CatalogFormatMap)
+	}
+	decoded, err := decodeTranslations(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, translations) {
+		t.Errorf("got translations %+v, want %+v", decoded, translations)
+	}
+}
+
+func TestMapCatalogExtractThenCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mmgotool-catalog-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	xeniaDir := filepath.Join(dir, "xenia")
+	enterpriseDir := filepath.Join(dir, "enterprise")
+	writeTestFile(t, filepath.Join(xeniaDir, "app", "app.go"), "package app\n\nfunc f() { T(\"app.server.key\") }\n")
+	writeTestFile(t, filepath.Join(enterpriseDir, "ldap", "ldap.go"), "package ldap\n\nfunc f() { T(\"ent.ldap.key\") }\n")
+	writeTestFile(t, filepath.Join(xeniaDir, "i18n", "en.json"), "[]\n")
+
+	dirs := []string{"--xenia-dir", xeniaDir, "--enterprise-dir", enterpriseDir, "--no-cache"}
+	RootCmd.SetArgs(append([]string{"i18n", "extract", "--catalog-format", CatalogFormatMap}, dirs...))
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatalf("extract failed: %s", err)
+	}
+	RootCmd.SetArgs(append([]string{"i18n", "check"}, dirs...))
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatalf("check of the extracted catalog failed: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(xeniaDir, "i18n", "en.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format := catalogDataFormat(data); format != CatalogFormatMap {
+		t.Fatalf("got format %s, want %s", format, CatalogFormatMap)
+	}
+	translations, err := decodeTranslations(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tr := range translations {
+		if tr.Id == "ent.ldap.key" && tr.Module != enterpriseModule {
+			t.Errorf("got module %q for ent.ldap.key, want %q", tr.Module, enterpriseModule)
+		}
+	}
+}
+
+func TestCatalogDataFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		format string
+	}{
+		{name: "array", data: `[{"id": "a", "translation": "A"}]`, format: CatalogFormatArray},
+		{name: "empty array", data: "[]\n", format: CatalogFormatArray},
+		{name: "map", data: `{"a": "A"}`, format: CatalogFormatMap},
+		{name: "empty map", data: "{}\n", format: CatalogFormatMap},
+		{name: "indented map", data: "\n  \t{\n  \"a\": \"A\"\n}\n", format: CatalogFormatMap},
+		{name: "empty file", data: "", format: CatalogFormatArray},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if format := catalogDataFormat([]byte(test.data)); format != test.format {
+				t.Errorf("got format %s, want %s", format, test.format)
+			}
+		})
+	}
+}
+
+func TestCatalogFileFormat(t *testing.T) {
+	defer func(format string) { catalogFormat = format }(catalogFormat)
+	dir := t.TempDir()
+	arrayFile := filepath.Join(dir, "array.json")
+	mapFile := filepath.Join(dir, "map.json")
+	writeTestFile(t, arrayFile, "[]\n")
+	writeTestFile(t, mapFile, "{}\n")
+
+	tests := []struct {
+		name   string
+		flag   string
+		file   string
+		format string
+	}{
+		{name: "array file", file: arrayFile, format: CatalogFormatArray},
+		{name: "map file", file: mapFile, format: CatalogFormatMap},
+		{name: "new file", file: filepath.Join(dir, "new.json"), format: CatalogFormatArray},
+		{name: "array file written as a map", flag: CatalogFormatMap, file: arrayFile, format: CatalogFormatMap},
+		{name: "map file written as an array", flag: CatalogFormatArray, file: mapFile, format: CatalogFormatArray},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			catalogFormat = test.flag
+			if format := catalogFileFormat(test.file); format != test.format {
+				t.Errorf("got format %s, want %s", format, test.format)
+			}
+		})
+	}
+}
+
+func TestDecodeMapCatalog(t *testing.T) {
+	data := `{
+  "web.signup": "Sign up",
+  "api.user.count": {"one": "{{.Count}} user", "other": "{{.Count}} users"},
+  "` + catalogStringsId + `": {"web.signup": {"module": "webapp", "fuzzy": true}},
+  "` + catalogMetaId + `": {"schema": 1, "keys": 2}
+}`
+	translations, err := decodeTranslations([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Translation{
+		{Id: "api.user.count", Translation: map[string]interface{}{"one": "{{.Count}} user", "other": "{{.Count}} users"}},
+		{Id: "web.signup", Translation: "Sign up", Module: "webapp", Fuzzy: true},
+	}
+	if !reflect.DeepEqual(translations, expected) {
+		t.Errorf("got translations %+v, want %+v", translations, expected)
+	}
+
+	if _, err := decodeTranslations([]byte(`{"` + catalogStringsId + `": ["not", "fields"]}`)); err == nil {
+		t.Error("expected an error for an invalid " + catalogStringsId + " entry")
+	}
+}
+
+func TestReadTranslationsFileDetectsTheFormat(t *testing.T) {
+	dir := t.TempDir()
+	translations := []Translation{
+		{Id: "api.user.get.app_error", Translation: "Unable to get the user."},
+		{Id: "web.beta.banner", Translation: "Beta", Expires: "v6.0"},
+	}
+	for _, format := range []string{CatalogFormatArray, CatalogFormatMap} {
+		t.Run(format, func(t *testing.T) {
+			data, err := encodeCatalog(translations, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			file := filepath.Join(dir, format+".json")
+			writeTestFile(t, file, string(data))
+			read, err := readTranslationsFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(read, translations) {
+				t.Errorf("got translations %+v, want %+v", read, translations)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const githubTokenEnv = "GITHUB_TOKEN"
+
+var (
+	conventionalCommitPattern = regexp.MustCompile(`^([a-z]+)(?:\(([^)]*)\))?(!)?: (.+)$`)
+	pullRequestPattern        = regexp.MustCompile(`(?:\(#(\d+)\)\s*$|^Merge pull request #(\d+))`)
+)
+
+// changelogSections are the sections of the release notes, in order, with
+// the conventional commit types and the PR labels they gather.
+var changelogSections = []struct {
+	Title  string
+	Types  []string
+	Labels []string
+}{
+	{"Breaking Changes", nil, []string{"breaking-change", "Breaking Change"}},
+	{"Features", []string{"feat"}, []string{"type/feature", "Type/Enhancement", "enhancement", "feature"}},
+	{"Bug Fixes", []string{"fix"}, []string{"type/bug", "Type/Bug", "bug"}},
+	{"Performance", []string{"perf"}, []string{"type/performance", "performance"}},
+	{"Documentation", []string{"docs"}, []string{"type/docs", "documentation"}},
+	{"Other Changes", nil, nil},
+}
+
+var ChangelogCmd = &cobra.Command{
+	Use:   "changelog",
+	Short: "Release notes tooling",
+}
+
+var ChangelogGenerateCmd = &cobra.Command{
+	Use:   "generate <from>..<to>",
+	Short: "Generate the release notes of a git range",
+	Long: `Group the commits of the git range by their conventional commit type, or by the labels of their pull request when a GitHub repository is given, and render them as markdown release notes.
+Commits of type chore, ci, test, build and style are left out unless --all is used. The GitHub token is read from the token flag or $` + githubTokenEnv + `.`,
+	Example: "  release changelog generate v7.1.0..v7.2.0 --github-repo xzl8028/xenia-server",
+	Args:    cobra.ExactArgs(1),
+	RunE:    changelogGenerateCmdF,
+}
+
+func init() {
+	ChangelogGenerateCmd.Flags().String("repo-dir", "./", "Path to the git repository")
+	ChangelogGenerateCmd.Flags().String("github-repo", "", "GitHub repository (owner/name) to read the pull request labels from")
+	ChangelogGenerateCmd.Flags().String("token", "", "GitHub API token")
+	ChangelogGenerateCmd.Flags().String("title", "", "Title of the release notes, defaults to the end of the range")
+	ChangelogGenerateCmd.Flags().Bool("all", false, "Include the maintenance commits")
+	ChangelogCmd.AddCommand(ChangelogGenerateCmd)
+	ReleaseCmd.AddCommand(ChangelogCmd)
+}
+
+type changelogEntry struct {
+	Hash        string
+	Type        string
+	Scope       string
+	Subject     string
+	Breaking    bool
+	PullRequest int
+	Labels      []string
+}
+
+// parseCommit reads the conventional commit header and the pull request
+// number of a commit. Merge commits take their subject from the body.
+func parseCommit(hash, subject, body string) changelogEntry {
+	entry := changelogEntry{Hash: hash, Subject: subject}
+	if match := pullRequestPattern.FindStringSubmatch(subject); match != nil {
+		number := match[1]
+		if number == "" {
+			number = match[2]
+			if line := strings.TrimSpace(strings.SplitN(strings.TrimSpace(body), "\n", 2)[0]); line != "" {
+				entry.Subject = line
+			}
+		}
+		entry.PullRequest, _ = strconv.Atoi(number)
+		entry.Subject = strings.TrimSpace(pullRequestPattern.ReplaceAllString(entry.Subject, ""))
+	}
+	if match := conventionalCommitPattern.FindStringSubmatch(entry.Subject); match != nil {
+		entry.Type = match[1]
+		entry.Scope = match[2]
+		entry.Breaking = match[3] != ""
+		entry.Subject = match[4]
+	}
+	if strings.Contains(body, "BREAKING CHANGE") {
+		entry.Breaking = true
+	}
+	return entry
+}
+
+func gitCommits(repoDir, gitRange string) ([]changelogEntry, error) {
+	const separator = "\x1e"
+	output, err := execCommand("git", "-C", repoDir, "log", "--first-parent", "--format=%H%x1f%s%x1f%b"+separator, gitRange).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("git log failed: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, err
+	}
+
+	entries := []changelogEntry{}
+	for _, record := range strings.Split(string(output), separator) {
+		fields := strings.SplitN(strings.TrimSpace(record), "\x1f", 3)
+		if len(fields) < 2 {
+			continue
+		}
+		body := ""
+		if len(fields) == 3 {
+			body = fields[2]
+		}
+		entries = append(entries, parseCommit(fields[0], fields[1], body))
+	}
+	return entries, nil
+}
+
+// pullRequestLabels reads the labels of a pull request through the issues
+// API, which works for pull requests too.
+func pullRequestLabels(client *http.Client, repo, token string, number int) ([]string, error) {
+	request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://api.github.com/repos/%s/issues/%d", repo, number), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/vnd.github+json")
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s reading pull request #%d", response.Status, number)
+	}
+
+	var issue struct {
+		Labels []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&issue); err != nil {
+		return nil, err
+	}
+	labels := []string{}
+	for _, label := range issue.Labels {
+		labels = append(labels, label.Name)
+	}
+	return labels, nil
+}
+
+func isMaintenanceCommit(entry changelogEntry) bool {
+	switch entry.Type {
+	case "chore", "ci", "test", "build", "style":
+		return true
+	}
+	return false
+}
+
+// changelogSection returns the index of the section of the entry. The pull
+// request labels take precedence over the commit type.
+func changelogSection(entry changelogEntry) int {
+	for i, section := range changelogSections {
+		for _, label := range section.Labels {
+			for _, entryLabel := range entry.Labels {
+				if strings.EqualFold(label, entryLabel) {
+					return i
+				}
+			}
+		}
+	}
+	if entry.Breaking {
+		return 0
+	}
+	for i, section := range changelogSections {
+		for _, commitType := range section.Types {
+			if entry.Type == commitType {
+				return i
+			}
+		}
+	}
+	return len(changelogSections) - 1
+}
+
+func renderChangelog(title string, entries []changelogEntry, repo string) string {
+	grouped := make([][]changelogEntry, len(changelogSections))
+	for _, entry := range entries {
+		section := changelogSection(entry)
+		grouped[section] = append(grouped[section], entry)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s\n", title)
+	for i, section := range changelogSections {
+		if len(grouped[i]) == 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "\n## %s\n\n", section.Title)
+		for _, entry := range grouped[i] {
+			line := entry.Subject
+			if entry.Scope != "" {
+				line = "**" + entry.Scope + ":** " + line
+			}
+			if entry.PullRequest != 0 {
+				if repo != "" {
+					line += fmt.Sprintf(" ([#%d](https://github.com/%s/pull/%d))", entry.PullRequest, repo, entry.PullRequest)
+				} else {
+					line += fmt.Sprintf(" (#%d)", entry.PullRequest)
+				}
+			} else {
+				line += fmt.Sprintf(" (%s)", entry.Hash[:7])
+			}
+			fmt.Fprintf(&buf, "- %s\n", line)
+		}
+	}
+	return buf.String()
+}
+
+func changelogGenerateCmdF(command *cobra.Command, args []string) error {
+	repoDir, err := command.Flags().GetString("repo-dir")
+	if err != nil {
+		return errors.New("Invalid repo-dir parameter")
+	}
+	repo, err := command.Flags().GetString("github-repo")
+	if err != nil {
+		return errors.New("Invalid github-repo parameter")
+	}
+	token, err := command.Flags().GetString("token")
+	if err != nil {
+		return errors.New("Invalid token parameter")
+	}
+	title, err := command.Flags().GetString("title")
+	if err != nil {
+		return errors.New("Invalid title parameter")
+	}
+	all, err := command.Flags().GetBool("all")
+	if err != nil {
+		return errors.New("Invalid all parameter")
+	}
+	if !strings.Contains(args[0], "..") {
+		return errors.New("The range must have the <from>..<to> form")
+	}
+	if token == "" {
+		token = os.Getenv(githubTokenEnv)
+	}
+	if title == "" {
+		title = strings.SplitN(args[0], "..", 2)[1]
+		if title == "" {
+			title = "Unreleased"
+		}
+	}
+	command.SilenceUsage = true
+
+	commits, err := gitCommits(repoDir, args[0])
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	entries := []changelogEntry{}
+	for _, entry := range commits {
+		if repo != "" && entry.PullRequest != 0 {
+			labels, err := pullRequestLabels(client, repo, token, entry.PullRequest)
+			if err != nil {
+				return err
+			}
+			entry.Labels = labels
+		}
+		if !all && isMaintenanceCommit(entry) && changelogSection(entry) == len(changelogSections)-1 {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	fmt.Print(renderChangelog(title, entries, repo))
+	return nil
+}
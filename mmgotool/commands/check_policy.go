@@ -0,0 +1,224 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// checkExitFailed is the exit code of the checks failed by differences,
+	// with --class-exit-codes the one of the checks failed by several
+	// classes.
+	checkExitFailed   = 1
+	checkExitInternal = 2
+)
+
+// checkClass is a kind of difference found by a check command. The classes
+// of the --fail-on policy fail the command, with their exit code with
+// --class-exit-codes, the other ones are only reported.
+type checkClass struct {
+	Name        string
+	Description string
+	ExitCode    int
+	// Message is the error of the command when the class fails it.
+	Message string
+}
+
+// checkClassSet are the classes of a check command.
+type checkClassSet []checkClass
+
+// i18nCheckClasses are the classes of i18n check.
+var i18nCheckClasses = checkClassSet{
+	{Name: "added", Description: "ids used by the source code missing from i18n/en.json", ExitCode: 3, Message: "Translations file out of date, ids are missing."},
+	{Name: "removed", Description: "ids of i18n/en.json no longer used", ExitCode: 4, Message: "Translations file out of date, ids are unused."},
+	{Name: "untranslated", Description: "strings of i18n/en.json still holding the placeholder", ExitCode: 5, Message: "Strings of i18n/en.json still hold the placeholder, write them."},
+	{Name: "placeholder-mismatch", Description: "translations not using the placeholders of the English string", ExitCode: 6, Message: "Translations don't use the placeholders of the English strings."},
+	{Name: "module", Description: "ids tagged with another module than the one using them", ExitCode: 7, Message: "Translations file out of date, ids changed module."},
+	{Name: "catalog", Description: "catalog produced by an incompatible mmgotool version", ExitCode: 8, Message: "Translations file produced by an incompatible version."},
+	{Name: "expired", Description: "experimental strings past their expiry", ExitCode: 9, Message: "Experimental strings expired, remove them or drop their expiry."},
+	{Name: "naming", Description: "new ids breaking the naming policy", ExitCode: 10, Message: "New translation ids break the naming policy."},
+	{Name: "frozen", Description: "strings changed after the string freeze", ExitCode: 11, Message: "Strings changed after the string freeze, request exceptions with i18n key-freeze exceptions request."},
+	{Name: "conflict", Description: "call sites giving conflicting English strings", ExitCode: 12, Message: "Call sites give conflicting English strings, align them with i18n/en.json."},
+}
+
+// i18nCheckFailOn are the classes failing i18n check by default.
+var i18nCheckFailOn = []string{"added", "removed", "module", "catalog", "expired", "naming", "frozen", "conflict"}
+
+func (s checkClassSet) find(name string) (checkClass, bool) {
+	for _, class := range s {
+		if class.Name == name {
+			return class, true
+		}
+	}
+	return checkClass{}, false
+}
+
+func (s checkClassSet) names() []string {
+	names := []string{}
+	for _, class := range s {
+		names = append(names, class.Name)
+	}
+	return names
+}
+
+// exitCodesHelp documents the exit codes of the classes, for the long
+// description of the command.
+func (s checkClassSet) exitCodesHelp() string {
+	lines := []string{
+		"  0  no class of the --fail-on policy has differences",
+		"  1  classes of the --fail-on policy have differences",
+		"  2  the check could not be completed",
+		"",
+		"With --class-exit-codes, a class failing the check alone exits with its own code instead of 1:",
+	}
+	for _, class := range s {
+		lines = append(lines, fmt.Sprintf("  %-2d %s: %s", class.ExitCode, class.Name, class.Description))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// addFailOnFlag adds the --fail-on and --class-exit-codes flags of a check
+// command.
+func (s checkClassSet) addFailOnFlag(command *cobra.Command, defaults []string) {
+	command.Flags().StringSlice("fail-on", defaults, "Classes of differences failing the check, all or none: "+strings.Join(s.names(), ", "))
+	command.Flags().Bool("class-exit-codes", false, "Exit with the code of the class failing the check alone instead of 1")
+}
+
+// checkPolicy are the classes failing a check command.
+type checkPolicy struct {
+	classes checkClassSet
+	failOn  map[string]bool
+	// classCodes exits with the code of the class failing the check alone.
+	classCodes bool
+}
+
+// getCheckPolicy reads the --fail-on flag of a check command.
+func (s checkClassSet) getCheckPolicy(command *cobra.Command) (*checkPolicy, error) {
+	names, err := command.Flags().GetStringSlice("fail-on")
+	if err != nil {
+		return nil, errors.New("Invalid fail-on parameter")
+	}
+	classCodes, err := command.Flags().GetBool("class-exit-codes")
+	if err != nil {
+		return nil, errors.New("Invalid class-exit-codes parameter")
+	}
+	policy := &checkPolicy{classes: s, failOn: map[string]bool{}, classCodes: classCodes}
+	for _, name := range names {
+		switch name {
+		case "all":
+			for _, class := range s {
+				policy.failOn[class.Name] = true
+			}
+		case "none":
+		default:
+			if _, ok := s.find(name); !ok {
+				return nil, fmt.Errorf("Unknown class %s, use all, none or %s.", name, strings.Join(s.names(), ", "))
+			}
+			policy.failOn[name] = true
+		}
+	}
+	return policy, nil
+}
+
+// require makes the class fail the check, for the flags predating
+// --fail-on.
+func (p *checkPolicy) require(name string) {
+	p.failOn[name] = true
+}
+
+// Fails tells whether the differences of the class fail the check.
+func (p *checkPolicy) Fails(name string) bool {
+	return p.failOn[name]
+}
+
+// Result returns the error of the check from the number of differences of
+// every class: checkExitFailed, or with --class-exit-codes the code of the
+// class failing it alone, nil when no class of the policy has differences.
+func (p *checkPolicy) Result(counts map[string]int) error {
+	failed := []checkClass{}
+	for _, class := range p.classes {
+		if counts[class.Name] > 0 && p.failOn[class.Name] {
+			failed = append(failed, class)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	if len(failed) == 1 {
+		return p.Failure(failed[0].Name, errors.New(failed[0].Message))
+	}
+	messages := []string{}
+	for _, class := range failed {
+		messages = append(messages, class.Message)
+	}
+	return &ExitError{Code: checkExitFailed, Err: errors.New(strings.Join(messages, " "))}
+}
+
+// Failure returns the error of the check failed by the class alone.
+func (p *checkPolicy) Failure(name string, err error) error {
+	if class, ok := p.classes.find(name); ok && p.classCodes {
+		return &ExitError{Code: class.ExitCode, Err: err}
+	}
+	return &ExitError{Code: checkExitFailed, Err: err}
+}
+
+// Report reports the findings of a check command, the ones of the classes
+// not failing the check as warnings, and returns their number by class for
+// Result.
+func (p *checkPolicy) Report(reporter *findingsReporter, findings []finding) map[string]int {
+	counts := map[string]int{}
+	for _, f := range findings {
+		if !p.Fails(f.Class) {
+			f.Warning = true
+		}
+		if f.Rule.Severity != severityWarning {
+			counts[f.Class]++
+		}
+		reporter.Report(f)
+	}
+	return counts
+}
+
+// runCheck wraps the run function of a check command: the errors other than
+// the ones of checkPolicy exit with checkExitInternal.
+func runCheck(run func(command *cobra.Command, args []string) error) func(command *cobra.Command, args []string) error {
+	return func(command *cobra.Command, args []string) error {
+		err := run(command, args)
+		if _, ok := err.(*ExitError); err == nil || ok {
+			return err
+		}
+		return &ExitError{Code: checkExitInternal, Err: err}
+	}
+}
+
+// isFailureCode tells whether an exit code is the one of differences, not
+// of a check which could not be completed.
+func (s checkClassSet) isFailureCode(code int) bool {
+	if code == checkExitFailed {
+		return true
+	}
+	for _, class := range s {
+		if class.ExitCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed returns the classes with differences not failing the check, with
+// their number of differences, for the logs.
+func (p *checkPolicy) Allowed(counts map[string]int) []string {
+	allowed := []string{}
+	for _, class := range p.classes {
+		if counts[class.Name] > 0 && !p.failOn[class.Name] {
+			allowed = append(allowed, fmt.Sprintf("%s=%d", class.Name, counts[class.Name]))
+		}
+	}
+	return allowed
+}
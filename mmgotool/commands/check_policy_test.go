@@ -0,0 +1,102 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestCheckPolicyResult(t *testing.T) {
+	tests := []struct {
+		name       string
+		failOn     []string
+		classCodes bool
+		counts     map[string]int
+		code       int
+	}{
+		{name: "no difference", failOn: []string{"all"}, counts: map[string]int{}, code: 0},
+		{name: "allowed class", failOn: []string{"added"}, counts: map[string]int{"removed": 2}, code: 0},
+		{name: "none", failOn: []string{"none"}, counts: map[string]int{"added": 1, "removed": 1}, code: 0},
+		{name: "failing class", failOn: []string{"added"}, counts: map[string]int{"added": 1, "removed": 1}, code: checkExitFailed},
+		{name: "failing class with its code", failOn: []string{"added"}, classCodes: true, counts: map[string]int{"added": 1, "removed": 1}, code: 3},
+		{name: "several failing classes", failOn: []string{"all"}, classCodes: true, counts: map[string]int{"added": 1, "removed": 1}, code: checkExitFailed},
+		{name: "last class", failOn: []string{"all"}, classCodes: true, counts: map[string]int{"conflict": 1}, code: 12},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy := &checkPolicy{classes: i18nCheckClasses, failOn: map[string]bool{}, classCodes: test.classCodes}
+			for _, name := range test.failOn {
+				switch name {
+				case "all":
+					for _, class := range i18nCheckClasses {
+						policy.require(class.Name)
+					}
+				case "none":
+				default:
+					policy.require(name)
+				}
+			}
+			code := 0
+			if err := policy.Result(test.counts); err != nil {
+				code = ExitCode(err)
+			}
+			if code != test.code {
+				t.Errorf("exit code %d, expected %d", code, test.code)
+			}
+		})
+	}
+}
+
+func TestCheckCommandExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "missing.go"), "package x\n")
+	writeTestFile(t, filepath.Join(dir, "incorrect.go"), "// Copyright (c) Someone else.\n\npackage x\n")
+	clean := t.TempDir()
+	writeTestFile(t, filepath.Join(clean, "ok.go"), licenseHeader+"\npackage x\n")
+
+	tests := []struct {
+		name       string
+		dir        string
+		failOn     string
+		classCodes bool
+		code       int
+	}{
+		{name: "no finding", dir: clean, failOn: "all", code: 0},
+		{name: "default policy", dir: dir, failOn: "missing,incorrect", code: checkExitFailed},
+		{name: "several classes with their codes", dir: dir, failOn: "all", classCodes: true, code: checkExitFailed},
+		{name: "missing alone", dir: dir, failOn: "missing", classCodes: true, code: 3},
+		{name: "incorrect alone", dir: dir, failOn: "incorrect", classCodes: true, code: 4},
+		{name: "incorrect alone without class codes", dir: dir, failOn: "incorrect", code: checkExitFailed},
+		{name: "findings allowed", dir: dir, failOn: "none", classCodes: true, code: 0},
+		{name: "unknown class", dir: dir, failOn: "typo", code: checkExitInternal},
+		{name: "missing folder", dir: filepath.Join(dir, "missing"), failOn: "all", code: checkExitInternal},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			classCodes := "--class-exit-codes=false"
+			if test.classCodes {
+				classCodes = "--class-exit-codes=true"
+			}
+			// The slice flags of LicenseCheckCmd would keep the values of
+			// the previous cases.
+			command := &cobra.Command{Use: "check", RunE: LicenseCheckCmd.RunE, SilenceErrors: true, SilenceUsage: true}
+			command.Flags().String("dir", "./", "")
+			command.Flags().StringArray("exclude", []string{}, "")
+			command.Flags().Bool("no-gitignore", false, "")
+			addFindingsFormatFlag(command)
+			licenseCheckClasses.addFailOnFlag(command, licenseCheckClasses.names())
+			command.SetArgs([]string{"--dir", test.dir, "--fail-on", test.failOn, classCodes})
+			code := 0
+			if err := command.Execute(); err != nil {
+				code = ExitCode(err)
+			}
+			if code != test.code {
+				t.Errorf("exit code %d, expected %d", code, test.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,620 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/xzl8028/xenia-utilities/mmgotool/internal/codegen"
+	"gopkg.in/yaml.v2"
+)
+
+//go:embed templates/client/*.tmpl
+var clientTemplates embed.FS
+
+// clientRequestCalls maps the request helpers of Client4 to the HTTP method
+// they send, for the helpers taking the path as first argument.
+var clientRequestCalls = map[string]string{
+	"DoApiGet":        "get",
+	"DoApiPost":       "post",
+	"DoApiPut":        "put",
+	"DoApiDelete":     "delete",
+	"doApiPostBytes":  "post",
+	"doApiPutBytes":   "put",
+	"DoUploadFile":    "post",
+	"doUploadFile":    "post",
+	"DoApiPatchBytes": "patch",
+}
+
+// clientMethodRequestCalls are the request helpers taking the HTTP method and
+// the full URL.
+var clientMethodRequestCalls = map[string]bool{
+	"DoApiRequest":            true,
+	"doApiRequest":            true,
+	"DoApiRequestWithHeaders": true,
+	"doApiRequestWithHeaders": true,
+}
+
+// outsideApiMarker replaces c.Url in the evaluated paths, the requests
+// outside of the API root are not endpoints of the api4 routes.
+const outsideApiMarker = "\x00"
+
+var ClientCmd = &cobra.Command{
+	Use:   "client",
+	Short: "Generation of the Go client methods of model/client4.go",
+}
+
+var ClientGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate the missing client methods",
+	Long: `Read the route registrations of the api4 package, or the paths of an OpenAPI specification, and append a Client4 method to model/client4.go for every endpoint with no client method.
+
+The generated methods send the request and return the raw response, named after the handler or the operation id. Their request and response types are completed by hand.`,
+	Example: `  codegen client generate --api-dir ../xenia-server/api4 --client-file ../xenia-server/model/client4.go
+  codegen client generate --spec openapi.yaml --dry-run`,
+	RunE: clientGenerateCmdF,
+}
+
+var ClientCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Find the endpoints without a client method",
+	Long: `List the endpoints of the api4 routes, or of an OpenAPI specification, with no Client4 method requesting them.
+
+The paths requested by the client are read from the calls of its request helpers, like DoApiGet, following the route helpers such as GetUserRoute.`,
+	Example: "  codegen client check --api-dir ../xenia-server/api4 --client-file ../xenia-server/model/client4.go",
+	RunE:    clientCheckCmdF,
+}
+
+func init() {
+	for _, command := range []*cobra.Command{ClientGenerateCmd, ClientCheckCmd} {
+		command.Flags().String("api-dir", "api4", "Path to the api4 package")
+		command.Flags().String("spec", "", "Path to an OpenAPI specification to read the endpoints from instead of the api4 package")
+		command.Flags().String("base-path", "/api/v4", "Path of the API root, stripped from the paths of the specification")
+		command.Flags().String("client-file", "model/client4.go", "Path to the Go file of the Client4 methods")
+	}
+	ClientGenerateCmd.Flags().Bool("dry-run", false, "Print the generated methods instead of writing them")
+	ClientCmd.AddCommand(ClientGenerateCmd, ClientCheckCmd)
+	CodegenCmd.AddCommand(ClientCmd)
+}
+
+// clientEndpoint is an endpoint of the API, its path relative to the API
+// root.
+type clientEndpoint struct {
+	Method  string
+	Path    string
+	Handler string
+}
+
+// Key identifies the endpoint whatever the names of its path parameters.
+func (e clientEndpoint) Key() string {
+	return e.Method + " " + normalizeEndpointPath(e.Path)
+}
+
+// normalizeEndpointPath replaces the parameters of a path by {} and drops
+// its query, so the route and client paths can be compared.
+func normalizeEndpointPath(p string) string {
+	if i := strings.Index(p, "?"); i >= 0 {
+		p = p[:i]
+	}
+	p = routeParamPattern.ReplaceAllString(p, "{}")
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		switch j := strings.Index(segment, "{"); {
+		case j == 0:
+			segments[i] = "{}"
+		case j > 0:
+			// A value appended to a literal segment is a query or a suffix
+			// built at run time, not a path parameter.
+			segments[i] = segment[:j]
+		}
+	}
+	p = strings.Join(segments, "/")
+	if len(p) > 1 {
+		p = strings.TrimSuffix(p, "/")
+	}
+	return p
+}
+
+func loadEndpoints(command *cobra.Command) ([]clientEndpoint, error) {
+	apiDir, err := command.Flags().GetString("api-dir")
+	if err != nil {
+		return nil, errors.New("Invalid api-dir parameter")
+	}
+	spec, err := command.Flags().GetString("spec")
+	if err != nil {
+		return nil, errors.New("Invalid spec parameter")
+	}
+	basePath, err := command.Flags().GetString("base-path")
+	if err != nil {
+		return nil, errors.New("Invalid base-path parameter")
+	}
+	command.SilenceUsage = true
+	if spec != "" {
+		return loadSpecEndpoints(spec, basePath)
+	}
+
+	source, err := loadApiSource(apiDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(source.routes) == 0 {
+		return nil, fmt.Errorf("No route registration found in %s.", apiDir)
+	}
+	source.complete("")
+	endpoints := []clientEndpoint{}
+	for _, route := range source.routes {
+		endpoints = append(endpoints, clientEndpoint{Method: route.Method, Path: route.Path, Handler: route.Handler})
+	}
+	return endpoints, nil
+}
+
+// loadSpecEndpoints reads the endpoints of an OpenAPI specification, named
+// after their operation id.
+func loadSpecEndpoints(spec, basePath string) ([]clientEndpoint, error) {
+	data, err := readFile(spec)
+	if err != nil {
+		return nil, err
+	}
+	var document struct {
+		Paths map[string]map[string]interface{} `yaml:"paths"`
+	}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("Unable to parse %s: %s", spec, err.Error())
+	}
+
+	endpoints := []clientEndpoint{}
+	for specPath, item := range document.Paths {
+		for method, operation := range item {
+			if !isHTTPMethod(method) {
+				continue
+			}
+			handler := ""
+			if fields, ok := operation.(map[interface{}]interface{}); ok {
+				handler, _ = fields["operationId"].(string)
+			}
+			endpoints = append(endpoints, clientEndpoint{
+				Method:  method,
+				Path:    strings.TrimPrefix(specPath, basePath),
+				Handler: codegen.Unexport(handler),
+			})
+		}
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("No path found in %s.", spec)
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		if endpoints[i].Path != endpoints[j].Path {
+			return endpoints[i].Path < endpoints[j].Path
+		}
+		return endpoints[i].Method < endpoints[j].Method
+	})
+	return endpoints, nil
+}
+
+func isHTTPMethod(method string) bool {
+	switch method {
+	case "get", "post", "put", "patch", "delete":
+		return true
+	}
+	return false
+}
+
+// clientSource holds the Client4 methods of the client file and the
+// endpoints they request.
+type clientSource struct {
+	methods map[string]*ast.FuncDecl
+	// covered maps the endpoint keys to the methods requesting them.
+	covered map[string][]string
+}
+
+func loadClientSource(clientFile string) (*clientSource, error) {
+	src, err := readFile(clientFile)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, clientFile, src, 0)
+	if err != nil {
+		return nil, err
+	}
+	source := &clientSource{methods: map[string]*ast.FuncDecl{}, covered: map[string][]string{}}
+	for _, decl := range f.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && isClient4Method(fn) {
+			source.methods[fn.Name.Name] = fn
+		}
+	}
+	for name, fn := range source.methods {
+		for _, key := range source.requestedEndpoints(fn) {
+			if !contains(source.covered[key], name) {
+				source.covered[key] = append(source.covered[key], name)
+			}
+		}
+	}
+	return source, nil
+}
+
+func isClient4Method(fn *ast.FuncDecl) bool {
+	if fn.Recv == nil || len(fn.Recv.List) != 1 || fn.Body == nil {
+		return false
+	}
+	star, ok := fn.Recv.List[0].Type.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	ident, ok := star.X.(*ast.Ident)
+	return ok && ident.Name == "Client4"
+}
+
+// requestedEndpoints returns the keys of the endpoints requested by a
+// client method.
+func (s *clientSource) requestedEndpoints(fn *ast.FuncDecl) []string {
+	receiver := ""
+	if names := fn.Recv.List[0].Names; len(names) == 1 {
+		receiver = names[0].Name
+	}
+	env := map[string]string{}
+	keys := []string{}
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.AssignStmt:
+			if len(node.Lhs) == 1 && len(node.Rhs) == 1 {
+				if ident, ok := node.Lhs[0].(*ast.Ident); ok {
+					env[ident.Name] = s.evalPath(node.Rhs[0], receiver, env, 0)
+				}
+			}
+		case *ast.CallExpr:
+			sel, ok := node.Fun.(*ast.SelectorExpr)
+			if !ok || len(node.Args) == 0 {
+				return true
+			}
+			method, urlArg := clientRequestCalls[sel.Sel.Name], node.Args[0]
+			if clientMethodRequestCalls[sel.Sel.Name] && len(node.Args) > 1 {
+				method, urlArg = requestMethod(node.Args[0]), node.Args[1]
+			}
+			if method == "" {
+				return true
+			}
+			p := s.evalPath(urlArg, receiver, env, 0)
+			if !strings.Contains(p, outsideApiMarker) {
+				keys = append(keys, method+" "+normalizeEndpointPath(p))
+			}
+		}
+		return true
+	})
+	return keys
+}
+
+// requestMethod returns the lower case HTTP method of http.MethodGet or
+// "GET".
+func requestMethod(expr ast.Expr) string {
+	if value, ok := stringLiteral(expr); ok {
+		return strings.ToLower(value)
+	}
+	if sel, ok := expr.(*ast.SelectorExpr); ok && strings.HasPrefix(sel.Sel.Name, "Method") {
+		return strings.ToLower(strings.TrimPrefix(sel.Sel.Name, "Method"))
+	}
+	return ""
+}
+
+// evalPath evaluates the string expression of a requested path. The values
+// only known at run time become {}, the route helpers of the client are
+// followed.
+func (s *clientSource) evalPath(expr ast.Expr, receiver string, env map[string]string, depth int) string {
+	if depth > 10 {
+		return "{}"
+	}
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		if value, ok := stringLiteral(e); ok {
+			return value
+		}
+	case *ast.ParenExpr:
+		return s.evalPath(e.X, receiver, env, depth)
+	case *ast.BinaryExpr:
+		if e.Op == token.ADD {
+			return s.evalPath(e.X, receiver, env, depth) + s.evalPath(e.Y, receiver, env, depth)
+		}
+	case *ast.Ident:
+		if value, ok := env[e.Name]; ok {
+			return value
+		}
+	case *ast.SelectorExpr:
+		if ident, ok := e.X.(*ast.Ident); ok && ident.Name == receiver {
+			switch e.Sel.Name {
+			case "ApiUrl":
+				return ""
+			case "Url":
+				return outsideApiMarker
+			}
+		}
+	case *ast.CallExpr:
+		return s.evalCall(e, receiver, env, depth)
+	}
+	return "{}"
+}
+
+func (s *clientSource) evalCall(call *ast.CallExpr, receiver string, env map[string]string, depth int) string {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return "{}"
+	}
+	ident, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return "{}"
+	}
+	if ident.Name == "fmt" && sel.Sel.Name == "Sprintf" && len(call.Args) > 0 {
+		layout := s.evalPath(call.Args[0], receiver, env, depth)
+		values := []string{}
+		for _, arg := range call.Args[1:] {
+			values = append(values, s.evalPath(arg, receiver, env, depth))
+		}
+		return sprintfPath(layout, values)
+	}
+	helper := s.methods[sel.Sel.Name]
+	if ident.Name != receiver || helper == nil {
+		return "{}"
+	}
+
+	// Route helpers return the path built from their parameters.
+	helperEnv := map[string]string{}
+	i := 0
+	for _, field := range helper.Type.Params.List {
+		for _, name := range field.Names {
+			if i < len(call.Args) {
+				helperEnv[name.Name] = s.evalPath(call.Args[i], receiver, env, depth+1)
+			}
+			i++
+		}
+	}
+	helperReceiver := ""
+	if names := helper.Recv.List[0].Names; len(names) == 1 {
+		helperReceiver = names[0].Name
+	}
+	for _, stmt := range helper.Body.List {
+		if ret, ok := stmt.(*ast.ReturnStmt); ok && len(ret.Results) == 1 {
+			return s.evalPath(ret.Results[0], helperReceiver, helperEnv, depth+1)
+		}
+	}
+	return "{}"
+}
+
+// sprintfPath replaces the verbs of a format by the values.
+func sprintfPath(format string, values []string) string {
+	var buf strings.Builder
+	next := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			buf.WriteByte(format[i])
+			continue
+		}
+		i++
+		if format[i] == '%' {
+			buf.WriteByte('%')
+			continue
+		}
+		if next < len(values) {
+			buf.WriteString(values[next])
+		} else {
+			buf.WriteString("{}")
+		}
+		next++
+	}
+	return buf.String()
+}
+
+// missingEndpoints returns the endpoints no client method requests.
+func missingEndpoints(endpoints []clientEndpoint, source *clientSource) []clientEndpoint {
+	missing := []clientEndpoint{}
+	seen := map[string]bool{}
+	for _, endpoint := range endpoints {
+		key := endpoint.Key()
+		if len(source.covered[key]) == 0 && !seen[key] {
+			seen[key] = true
+			missing = append(missing, endpoint)
+		}
+	}
+	return missing
+}
+
+type generatedClientMethod struct {
+	Name   string
+	Method string
+	Path   string
+	Params string
+	Call   string
+}
+
+// clientParamName turns a route parameter like user_id into userId.
+func clientParamName(param string) string {
+	parts := strings.Split(param, "_")
+	for i := range parts {
+		if i == 0 {
+			parts[i] = codegen.Unexport(parts[i])
+		} else {
+			parts[i] = codegen.Export(parts[i])
+		}
+	}
+	name := strings.Join(parts, "")
+	if token.IsKeyword(name) {
+		name += "Param"
+	}
+	return name
+}
+
+// clientMethodFor builds the client method of an endpoint. The path
+// parameters become string arguments.
+func clientMethodFor(name string, endpoint clientEndpoint) *generatedClientMethod {
+	params := []string{}
+	pathParts := []string{}
+	last := 0
+	for _, match := range routeParamPattern.FindAllStringSubmatchIndex(endpoint.Path, -1) {
+		if literal := endpoint.Path[last:match[0]]; literal != "" {
+			pathParts = append(pathParts, fmt.Sprintf("%q", literal))
+		}
+		param := clientParamName(endpoint.Path[match[2]:match[3]])
+		params = append(params, param+" string")
+		pathParts = append(pathParts, param)
+		last = match[1]
+	}
+	if literal := endpoint.Path[last:]; literal != "" || len(pathParts) == 0 {
+		pathParts = append(pathParts, fmt.Sprintf("%q", literal))
+	}
+	url := strings.Join(pathParts, " + ")
+
+	var call string
+	switch endpoint.Method {
+	case "get":
+		params = append(params, "etag string")
+		call = fmt.Sprintf("c.DoApiGet(%s, etag)", url)
+	case "post":
+		params = append(params, "data string")
+		call = fmt.Sprintf("c.DoApiPost(%s, data)", url)
+	case "put":
+		params = append(params, "data string")
+		call = fmt.Sprintf("c.DoApiPut(%s, data)", url)
+	case "patch":
+		params = append(params, "data string")
+		call = fmt.Sprintf("c.DoApiRequest(http.MethodPatch, c.ApiUrl+%s, data, \"\")", url)
+	case "delete":
+		call = fmt.Sprintf("c.DoApiDelete(%s)", url)
+	default:
+		return nil
+	}
+	return &generatedClientMethod{
+		Name:   name,
+		Method: endpoint.Method,
+		Path:   routeParamPattern.ReplaceAllString(endpoint.Path, "{$1}"),
+		Params: strings.Join(params, ", "),
+		Call:   call,
+	}
+}
+
+// clientMethods names the methods of the missing endpoints after their
+// handler, adding the HTTP method when the handler serves several endpoints
+// or the name is taken.
+func clientMethods(endpoints, missing []clientEndpoint, source *clientSource) []*generatedClientMethod {
+	handlerUses := map[string]int{}
+	for _, endpoint := range endpoints {
+		handlerUses[endpoint.Handler]++
+	}
+	taken := map[string]bool{}
+	for name := range source.methods {
+		taken[name] = true
+	}
+
+	methods := []*generatedClientMethod{}
+	for _, endpoint := range missing {
+		if endpoint.Handler == "" {
+			logger.Warn("Skipping endpoint without handler name", "method", endpoint.Method, "path", endpoint.Path)
+			continue
+		}
+		name := codegen.Export(endpoint.Handler)
+		if handlerUses[endpoint.Handler] > 1 || taken[name] {
+			name += codegen.Export(endpoint.Method)
+		}
+		if taken[name] {
+			logger.Warn("Skipping endpoint, its client method name is taken", "method", endpoint.Method, "path", endpoint.Path, "name", name)
+			continue
+		}
+		method := clientMethodFor(name, endpoint)
+		if method == nil {
+			continue
+		}
+		taken[name] = true
+		methods = append(methods, method)
+	}
+	return methods
+}
+
+func clientGenerateCmdF(command *cobra.Command, args []string) error {
+	clientFile, err := command.Flags().GetString("client-file")
+	if err != nil {
+		return errors.New("Invalid client-file parameter")
+	}
+	dryRun, err := command.Flags().GetBool("dry-run")
+	if err != nil {
+		return errors.New("Invalid dry-run parameter")
+	}
+	endpoints, err := loadEndpoints(command)
+	if err != nil {
+		return err
+	}
+
+	source, err := loadClientSource(clientFile)
+	if err != nil {
+		return err
+	}
+	methods := clientMethods(endpoints, missingEndpoints(endpoints, source), source)
+	if len(methods) == 0 {
+		fmt.Println("Every endpoint has a client method.")
+		return nil
+	}
+
+	engine, err := codegen.New("codegen client generate", clientTemplates, "templates/client/*.tmpl")
+	if err != nil {
+		return err
+	}
+	generated, err := engine.RenderRaw("methods", methods)
+	if err != nil {
+		return err
+	}
+	if generated, err = format.Source(generated); err != nil {
+		return fmt.Errorf("Generated client methods do not parse: %s", err.Error())
+	}
+	if dryRun {
+		_, err = os.Stdout.Write(generated)
+		return err
+	}
+
+	current, err := readFile(clientFile)
+	if err != nil {
+		return err
+	}
+	updated := append(bytes.TrimRight(current, "\n"), append([]byte("\n\n"), bytes.TrimLeft(generated, "\n")...)...)
+	if err := writeFile(clientFile, updated, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("%d client methods generated in %s.\n", len(methods), clientFile)
+	return nil
+}
+
+func clientCheckCmdF(command *cobra.Command, args []string) error {
+	clientFile, err := command.Flags().GetString("client-file")
+	if err != nil {
+		return errors.New("Invalid client-file parameter")
+	}
+	endpoints, err := loadEndpoints(command)
+	if err != nil {
+		return err
+	}
+
+	source, err := loadClientSource(clientFile)
+	if err != nil {
+		return err
+	}
+	missing := missingEndpoints(endpoints, source)
+	for _, endpoint := range missing {
+		path := routeParamPattern.ReplaceAllString(endpoint.Path, "{$1}")
+		if endpoint.Handler != "" {
+			fmt.Printf("Missing: %s %s (%s)\n", strings.ToUpper(endpoint.Method), path, endpoint.Handler)
+		} else {
+			fmt.Printf("Missing: %s %s\n", strings.ToUpper(endpoint.Method), path)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%d endpoints have no client method.", len(missing))
+	}
+	return nil
+}
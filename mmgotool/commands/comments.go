@@ -0,0 +1,19 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+// keyDescriptions returns the translator comment of every key that has
+// one. When a key has several, the first one found is used.
+func keyDescriptions(refs []keyRef) map[string]string {
+	descriptions := map[string]string{}
+	for _, ref := range refs {
+		if ref.Description == "" {
+			continue
+		}
+		if _, ok := descriptions[ref.Id]; !ok {
+			descriptions[ref.Id] = ref.Description
+		}
+	}
+	return descriptions
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// binaryName is the name the tool is installed under, completion is
+// registered for it as well as for the root command name.
+const binaryName = "mmgotool"
+
+var CompletionCmd = &cobra.Command{
+	Use:   "completion <bash|zsh|fish|powershell>",
+	Short: "Generate the shell completion script",
+	Long: `Print the completion script of the shell on stdout. Load it from the shell profile, for example:
+
+  bash:        source <(mmgotool completion bash)
+  zsh:         mmgotool completion zsh > "${fpath[1]}/_mmgotool"
+  fish:        mmgotool completion fish > ~/.config/fish/completions/mmgotool.fish
+  powershell:  mmgotool completion powershell | Out-String | Invoke-Expression`,
+	Example:   "  completion bash",
+	ValidArgs: []string{"bash", "zsh", "fish", "powershell"},
+	Args:      cobra.ExactArgs(1),
+	RunE:      completionCmdF,
+}
+
+func init() {
+	RootCmd.AddCommand(CompletionCmd)
+}
+
+// writeCompletion writes the completion script of shell.
+func writeCompletion(w io.Writer, shell string) error {
+	name := RootCmd.Name()
+	switch shell {
+	case "bash":
+		if err := RootCmd.GenBashCompletion(w); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintf(w, "complete -o default -F __start_%s %s\n", name, binaryName)
+		return err
+	case "zsh":
+		var buf bytes.Buffer
+		if err := RootCmd.GenZshCompletion(&buf); err != nil {
+			return err
+		}
+		script := strings.Replace(buf.String(), "#compdef "+name, "#compdef "+name+" "+binaryName, 1)
+		_, err := io.WriteString(w, script)
+		return err
+	case "fish":
+		return genFishCompletion(w)
+	case "powershell":
+		return genPowerShellCompletion(w)
+	}
+	return fmt.Errorf("no completion available for %s", shell)
+}
+
+// completionTree returns the visible commands by path, the root one having
+// an empty path.
+func completionTree() map[string]*cobra.Command {
+	tree := map[string]*cobra.Command{}
+	var walk func(path string, command *cobra.Command)
+	walk = func(path string, command *cobra.Command) {
+		tree[path] = command
+		for _, child := range command.Commands() {
+			if !child.IsAvailableCommand() || child.Name() == "help" {
+				continue
+			}
+			walk(strings.TrimSpace(path+" "+child.Name()), child)
+		}
+	}
+	walk("", RootCmd)
+	return tree
+}
+
+func sortedCommandPaths(tree map[string]*cobra.Command) []string {
+	paths := []string{}
+	for path := range tree {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// completionFlags returns the flags accepted by command, its own and the
+// inherited ones.
+func completionFlags(command *cobra.Command) []*pflag.Flag {
+	flags := []*pflag.Flag{}
+	visit := func(flag *pflag.Flag) {
+		if !flag.Hidden {
+			flags = append(flags, flag)
+		}
+	}
+	command.NonInheritedFlags().VisitAll(visit)
+	command.InheritedFlags().VisitAll(visit)
+	return flags
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.Replace(strings.Replace(s, `\`, `\\`, -1), "'", `\'`, -1) + "'"
+}
+
+func genFishCompletion(w io.Writer) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# fish completion for %s\n\n", binaryName)
+	fmt.Fprintf(&buf, `function __%[1]s_path_is
+    set -l words
+    for word in (commandline -opc)[2..-1]
+        if not string match -q -- '-*' $word
+            set words $words $word
+        end
+    end
+    test "$words" = "$argv"
+end
+
+`, binaryName)
+
+	tree := completionTree()
+	for _, command := range []string{binaryName, RootCmd.Name()} {
+		for _, path := range sortedCommandPaths(tree) {
+			cmd := tree[path]
+			condition := fishQuote(strings.TrimSpace("__" + binaryName + "_path_is " + path))
+			for _, child := range cmd.Commands() {
+				if _, ok := tree[strings.TrimSpace(path+" "+child.Name())]; !ok {
+					continue
+				}
+				fmt.Fprintf(&buf, "complete -c %s -f -n %s -a %s -d %s\n", command, condition, child.Name(), fishQuote(child.Short))
+			}
+			for _, arg := range cmd.ValidArgs {
+				fmt.Fprintf(&buf, "complete -c %s -f -n %s -a %s\n", command, condition, arg)
+			}
+			for _, flag := range completionFlags(cmd) {
+				line := fmt.Sprintf("complete -c %s -n %s -l %s", command, condition, flag.Name)
+				if flag.Shorthand != "" {
+					line += " -s " + flag.Shorthand
+				}
+				if flag.Value.Type() != "bool" {
+					line += " -r"
+				}
+				fmt.Fprintf(&buf, "%s -d %s\n", line, fishQuote(flag.Usage))
+			}
+		}
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func powerShellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+func genPowerShellCompletion(w io.Writer) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# powershell completion for %s\n\n", binaryName)
+	fmt.Fprintln(&buf, "$__mmgotoolCompletions = @{")
+	tree := completionTree()
+	for _, path := range sortedCommandPaths(tree) {
+		cmd := tree[path]
+		words := []string{}
+		for _, child := range cmd.Commands() {
+			if _, ok := tree[strings.TrimSpace(path+" "+child.Name())]; ok {
+				words = append(words, powerShellQuote(child.Name()))
+			}
+		}
+		for _, arg := range cmd.ValidArgs {
+			words = append(words, powerShellQuote(arg))
+		}
+		for _, flag := range completionFlags(cmd) {
+			words = append(words, powerShellQuote("--"+flag.Name))
+		}
+		fmt.Fprintf(&buf, "    %s = @(%s)\n", powerShellQuote(path), strings.Join(words, ", "))
+	}
+	fmt.Fprintln(&buf, "}")
+	fmt.Fprintf(&buf, `
+Register-ArgumentCompleter -Native -CommandName %s, %s -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @()
+    foreach ($element in $commandAst.CommandElements | Select-Object -Skip 1) {
+        $text = $element.ToString()
+        if ($text -eq $wordToComplete -or $text.StartsWith('-')) { continue }
+        $words += $text
+    }
+    $candidates = $__mmgotoolCompletions[($words -join ' ')]
+    $candidates | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+    }
+}
+`, binaryName, RootCmd.Name())
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func completionCmdF(command *cobra.Command, args []string) error {
+	return writeCompletion(os.Stdout, args[0])
+}
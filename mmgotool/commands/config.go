@@ -0,0 +1,629 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const configRootStruct = "Config"
+
+// configEditionVariable is the build variable telling the edition, "true"
+// in the enterprise builds. The SetDefaults branches testing it assign the
+// defaults of one edition.
+const configEditionVariable = "BuildEnterpriseReady"
+
+const (
+	editionTeam       = "team"
+	editionEnterprise = "enterprise"
+)
+
+var ConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Server configuration tooling",
+	Long:  "Generate documentation and defaults of the server settings from the structs of model/config.go",
+}
+
+var ConfigDocsCmd = &cobra.Command{
+	Use:     "docs",
+	Short:   "Generate the markdown documentation of the settings",
+	Example: "  dev config docs --xenia-dir ../xenia-server --output docs/settings.md",
+	RunE:    configDocsCmdF,
+}
+
+var ConfigGenDefaultCmd = &cobra.Command{
+	Use:     "gen-default",
+	Aliases: []string{"defaults"},
+	Short:   "Generate the default config.json",
+	Long: `Generate the canonical config.json of an edition with the defaults assigned by the SetDefaults methods, interpreted from the source code. The branches of SetDefaults testing ` + configEditionVariable + ` assign the defaults of the edition they test. Settings whose default is computed at runtime are left null and listed as warnings.
+
+With --check nothing is written, the command fails when the output file, like the sample config.json of the documentation, is out of date.`,
+	Example: `  dev config gen-default --xenia-dir ../xenia-server --output config/default.json
+  dev config gen-default --edition enterprise --output ../docs/source/samples/config.json --check`,
+	RunE: configGenDefaultCmdF,
+}
+
+var ConfigDiffCmd = &cobra.Command{
+	Use:     "diff <config.json>",
+	Short:   "Find the unknown and deprecated settings of a config.json",
+	Example: "  dev config diff /opt/xenia/config/config.json --xenia-dir ../xenia-server",
+	Args:    cobra.ExactArgs(1),
+	RunE:    configDiffCmdF,
+}
+
+func init() {
+	for _, command := range []*cobra.Command{ConfigDocsCmd, ConfigGenDefaultCmd, ConfigDiffCmd} {
+		command.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+		command.Flags().String("config-file", filepath.Join("model", "config.go"), "Path to the Go file declaring the Config struct, relative to the xenia-dir")
+	}
+	ConfigDocsCmd.Flags().String("output", "", "Write the documentation to this file instead of the standard output")
+	ConfigGenDefaultCmd.Flags().String("output", "", "Write the defaults to this file instead of the standard output")
+	ConfigGenDefaultCmd.Flags().String("edition", editionTeam, "Edition whose defaults are generated: team or enterprise")
+	ConfigGenDefaultCmd.Flags().Bool("check", false, "Fail if the output file is not up to date instead of writing it")
+	ConfigCmd.AddCommand(ConfigDocsCmd)
+	ConfigCmd.AddCommand(ConfigGenDefaultCmd)
+	ConfigCmd.AddCommand(ConfigDiffCmd)
+	DevCmd.AddCommand(ConfigCmd)
+}
+
+// configSetting is a field of one of the configuration structs.
+type configSetting struct {
+	Name        string
+	Key         string
+	Type        string
+	Description string
+	Deprecated  bool
+	// Default is the value assigned by SetDefaults, nil when unset.
+	Default interface{}
+	// Computed is set when the default is only known at runtime.
+	Computed bool
+	// Section holds the settings of a nested struct.
+	Section []*configSetting
+}
+
+type configSource struct {
+	fset      *token.FileSet
+	structs   map[string]*ast.StructType
+	constants map[string]ast.Expr
+	defaults  map[string]map[string]configDefault
+	// edition selects the SetDefaults branches testing the edition, the
+	// first assignment of a field wins when empty.
+	edition string
+}
+
+type configDefault struct {
+	Value    interface{}
+	Computed bool
+}
+
+func loadConfigSource(command *cobra.Command, edition string) (*configSource, error) {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return nil, errors.New("Invalid xenia-dir parameter")
+	}
+	configFile, err := command.Flags().GetString("config-file")
+	if err != nil {
+		return nil, errors.New("Invalid config-file parameter")
+	}
+	if !filepath.IsAbs(configFile) {
+		configFile = filepath.Join(xeniaDir, configFile)
+	}
+	if _, err := os.Stat(configFile); err != nil {
+		return nil, err
+	}
+
+	// The constants used as defaults can be declared anywhere in the package.
+	fset := token.NewFileSet()
+	pkgs, err := parseDir(fset, filepath.Dir(configFile), func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	source := &configSource{
+		fset:      fset,
+		structs:   map[string]*ast.StructType{},
+		constants: map[string]ast.Expr{},
+		defaults:  map[string]map[string]configDefault{},
+		edition:   edition,
+	}
+	setDefaults := []*ast.FuncDecl{}
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			for _, decl := range f.Decls {
+				switch d := decl.(type) {
+				case *ast.GenDecl:
+					source.addGenDecl(d)
+				case *ast.FuncDecl:
+					if d.Name.Name == "SetDefaults" && d.Recv != nil && len(d.Recv.List) == 1 {
+						setDefaults = append(setDefaults, d)
+					}
+				}
+			}
+		}
+	}
+	if source.structs[configRootStruct] == nil {
+		return nil, fmt.Errorf("Unable to find the %s struct next to %s.", configRootStruct, configFile)
+	}
+	for _, fn := range setDefaults {
+		source.addDefaults(fn)
+	}
+	return source, nil
+}
+
+func (s *configSource) addGenDecl(decl *ast.GenDecl) {
+	for _, spec := range decl.Specs {
+		switch sp := spec.(type) {
+		case *ast.TypeSpec:
+			if st, ok := sp.Type.(*ast.StructType); ok {
+				s.structs[sp.Name.Name] = st
+			}
+		case *ast.ValueSpec:
+			if decl.Tok != token.CONST && decl.Tok != token.VAR {
+				continue
+			}
+			for i, name := range sp.Names {
+				if i < len(sp.Values) {
+					s.constants[name.Name] = sp.Values[i]
+				}
+			}
+		}
+	}
+}
+
+// addDefaults records the assignments of SetDefaults such as
+// s.SiteURL = NewString(SERVICE_SETTINGS_DEFAULT_SITE_URL).
+func (s *configSource) addDefaults(fn *ast.FuncDecl) {
+	recv := fn.Recv.List[0]
+	if len(recv.Names) == 0 {
+		return
+	}
+	recvName := recv.Names[0].Name
+	typeExpr := recv.Type
+	if star, ok := typeExpr.(*ast.StarExpr); ok {
+		typeExpr = star.X
+	}
+	typeName, ok := typeExpr.(*ast.Ident)
+	if !ok {
+		return
+	}
+	defaults := s.defaults[typeName.Name]
+	if defaults == nil {
+		defaults = map[string]configDefault{}
+		s.defaults[typeName.Name] = defaults
+	}
+
+	var visit func(n ast.Node) bool
+	visit = func(n ast.Node) bool {
+		if ifStmt, ok := n.(*ast.IfStmt); ok {
+			if holds, ok := s.editionCondition(ifStmt.Cond); ok {
+				if holds {
+					ast.Inspect(ifStmt.Body, visit)
+				} else if ifStmt.Else != nil {
+					ast.Inspect(ifStmt.Else, visit)
+				}
+				return false
+			}
+		}
+		assign, ok := n.(*ast.AssignStmt)
+		if !ok || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+			return true
+		}
+		sel, ok := assign.Lhs[0].(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if ident, ok := sel.X.(*ast.Ident); !ok || ident.Name != recvName {
+			return true
+		}
+		if _, seen := defaults[sel.Sel.Name]; seen {
+			return true
+		}
+		value, ok := s.eval(assign.Rhs[0], 0)
+		defaults[sel.Sel.Name] = configDefault{Value: value, Computed: !ok}
+		return true
+	}
+	ast.Inspect(fn.Body, visit)
+}
+
+// editionCondition tells if the condition, comparing the edition build
+// variable with a literal, holds for the edition of the source. It is not
+// ok for the other conditions or when no edition is selected.
+func (s *configSource) editionCondition(cond ast.Expr) (bool, bool) {
+	binary, ok := cond.(*ast.BinaryExpr)
+	if s.edition == "" || !ok || (binary.Op != token.EQL && binary.Op != token.NEQ) {
+		return false, false
+	}
+	isVariable := func(expr ast.Expr) bool {
+		switch e := expr.(type) {
+		case *ast.Ident:
+			return e.Name == configEditionVariable
+		case *ast.SelectorExpr:
+			return e.Sel.Name == configEditionVariable
+		}
+		return false
+	}
+	var literal ast.Expr
+	switch {
+	case isVariable(binary.X):
+		literal = binary.Y
+	case isVariable(binary.Y):
+		literal = binary.X
+	default:
+		return false, false
+	}
+	value, ok := stringLiteral(literal)
+	if !ok {
+		return false, false
+	}
+	holds := value == strconv.FormatBool(s.edition == editionEnterprise)
+	if binary.Op == token.NEQ {
+		holds = !holds
+	}
+	return holds, true
+}
+
+// eval computes the value of a default expression. Only literals,
+// constants, the New<Type> pointer helpers and empty composite literals are
+// supported, the other expressions are computed at runtime.
+func (s *configSource) eval(expr ast.Expr, depth int) (interface{}, bool) {
+	if depth > 10 {
+		return nil, false
+	}
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		switch e.Kind {
+		case token.STRING:
+			value, err := strconv.Unquote(e.Value)
+			return value, err == nil
+		case token.INT:
+			value, err := strconv.ParseInt(e.Value, 0, 64)
+			return value, err == nil
+		case token.FLOAT:
+			value, err := strconv.ParseFloat(e.Value, 64)
+			return value, err == nil
+		}
+	case *ast.Ident:
+		switch e.Name {
+		case "true":
+			return true, true
+		case "false":
+			return false, true
+		case "nil":
+			return nil, true
+		}
+		if value, ok := s.constants[e.Name]; ok {
+			return s.eval(value, depth+1)
+		}
+	case *ast.SelectorExpr:
+		if value, ok := s.constants[e.Sel.Name]; ok {
+			return s.eval(value, depth+1)
+		}
+	case *ast.ParenExpr:
+		return s.eval(e.X, depth+1)
+	case *ast.UnaryExpr:
+		if e.Op == token.AND {
+			return s.eval(e.X, depth+1)
+		}
+		value, ok := s.eval(e.X, depth+1)
+		if number, isInt := value.(int64); ok && isInt && e.Op == token.SUB {
+			return -number, true
+		}
+	case *ast.BinaryExpr:
+		x, okX := s.eval(e.X, depth+1)
+		y, okY := s.eval(e.Y, depth+1)
+		if !okX || !okY {
+			return nil, false
+		}
+		if a, ok := x.(string); ok && e.Op == token.ADD {
+			if b, ok := y.(string); ok {
+				return a + b, true
+			}
+		}
+		a, okA := x.(int64)
+		b, okB := y.(int64)
+		if okA && okB {
+			switch e.Op {
+			case token.ADD:
+				return a + b, true
+			case token.SUB:
+				return a - b, true
+			case token.MUL:
+				return a * b, true
+			}
+		}
+	case *ast.CallExpr:
+		name := ""
+		switch fun := e.Fun.(type) {
+		case *ast.Ident:
+			name = fun.Name
+		case *ast.SelectorExpr:
+			name = fun.Sel.Name
+		}
+		if strings.HasPrefix(name, "New") && len(e.Args) == 1 {
+			return s.eval(e.Args[0], depth+1)
+		}
+	case *ast.CompositeLit:
+		if len(e.Elts) > 0 {
+			return nil, false
+		}
+		switch e.Type.(type) {
+		case *ast.ArrayType:
+			return []interface{}{}, true
+		case *ast.MapType:
+			return map[string]interface{}{}, true
+		}
+	}
+	return nil, false
+}
+
+func (s *configSource) typeString(expr ast.Expr) string {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	var buf bytes.Buffer
+	printer.Fprint(&buf, s.fset, expr)
+	return buf.String()
+}
+
+// settings returns the settings of the struct, following the nested
+// structs of the package.
+func (s *configSource) settings(structName string, seen map[string]bool) []*configSetting {
+	st := s.structs[structName]
+	if st == nil || seen[structName] {
+		return nil
+	}
+	seen[structName] = true
+	defer delete(seen, structName)
+
+	settings := []*configSetting{}
+	for _, field := range st.Fields.List {
+		for _, name := range field.Names {
+			if !ast.IsExported(name.Name) {
+				continue
+			}
+			setting := &configSetting{Name: name.Name, Key: name.Name, Type: s.typeString(field.Type)}
+			if field.Tag != nil {
+				tag, _ := strconv.Unquote(field.Tag.Value)
+				jsonName := strings.Split(reflect.StructTag(tag).Get("json"), ",")[0]
+				if jsonName == "-" {
+					continue
+				}
+				if jsonName != "" {
+					setting.Key = jsonName
+				}
+			}
+			comments := []string{}
+			for _, group := range []*ast.CommentGroup{field.Doc, field.Comment} {
+				if text := strings.TrimSpace(group.Text()); text != "" {
+					comments = append(comments, text)
+				}
+			}
+			setting.Description = strings.Join(strings.Fields(strings.Join(comments, " ")), " ")
+			setting.Deprecated = strings.Contains(strings.ToLower(setting.Description), "deprecated")
+			if _, ok := s.structs[setting.Type]; ok {
+				setting.Section = s.settings(setting.Type, seen)
+			} else {
+				defaultValue := s.defaults[structName][name.Name]
+				setting.Default = defaultValue.Value
+				setting.Computed = defaultValue.Computed
+			}
+			settings = append(settings, setting)
+		}
+	}
+	return settings
+}
+
+func configDefaults(settings []*configSetting) map[string]interface{} {
+	defaults := map[string]interface{}{}
+	for _, setting := range settings {
+		if setting.Section != nil {
+			defaults[setting.Key] = configDefaults(setting.Section)
+		} else {
+			defaults[setting.Key] = setting.Default
+		}
+	}
+	return defaults
+}
+
+func writeConfigDocs(buf *bytes.Buffer, prefix string, settings []*configSetting) {
+	rows := []*configSetting{}
+	for _, setting := range settings {
+		if setting.Section == nil {
+			rows = append(rows, setting)
+		}
+	}
+	if len(rows) > 0 {
+		fmt.Fprintf(buf, "## %s\n\n", prefix)
+		fmt.Fprintln(buf, "| Setting | Type | Default | Description |")
+		fmt.Fprintln(buf, "| --- | --- | --- | --- |")
+		for _, setting := range rows {
+			defaultValue := "`" + formatSettingValue(setting.Default) + "`"
+			if setting.Computed {
+				defaultValue = "computed"
+			}
+			description := strings.Replace(setting.Description, "|", `\|`, -1)
+			if setting.Deprecated && !strings.HasPrefix(strings.ToLower(description), "deprecated") {
+				description = "**Deprecated.** " + description
+			}
+			fmt.Fprintf(buf, "| `%s` | `%s` | %s | %s |\n", setting.Key, setting.Type, defaultValue, description)
+		}
+		fmt.Fprintln(buf)
+	}
+	for _, setting := range settings {
+		if setting.Section != nil {
+			name := setting.Key
+			if prefix != "" {
+				name = prefix + "." + setting.Key
+			}
+			writeConfigDocs(buf, name, setting.Section)
+		}
+	}
+}
+
+func writeCommandOutput(command *cobra.Command, data []byte) error {
+	output, err := command.Flags().GetString("output")
+	if err != nil {
+		return errors.New("Invalid output parameter")
+	}
+	if output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return writeFile(output, data, 0644)
+}
+
+func configDocsCmdF(command *cobra.Command, args []string) error {
+	source, err := loadConfigSource(command, "")
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "# Configuration settings")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "<!-- Generated by mmgotool dev config docs. DO NOT EDIT. -->")
+	fmt.Fprintln(&buf)
+	writeConfigDocs(&buf, "", source.settings(configRootStruct, map[string]bool{}))
+	return writeCommandOutput(command, append(bytes.TrimRight(buf.Bytes(), "\n"), '\n'))
+}
+
+// computedSettings returns the keys of the settings whose default is
+// computed at runtime.
+func computedSettings(prefix string, settings []*configSetting) []string {
+	keys := []string{}
+	for _, setting := range settings {
+		key := setting.Key
+		if prefix != "" {
+			key = prefix + "." + setting.Key
+		}
+		if setting.Section != nil {
+			keys = append(keys, computedSettings(key, setting.Section)...)
+		} else if setting.Computed {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func configGenDefaultCmdF(command *cobra.Command, args []string) error {
+	edition, err := command.Flags().GetString("edition")
+	if err != nil {
+		return errors.New("Invalid edition parameter")
+	}
+	if edition != editionTeam && edition != editionEnterprise {
+		return fmt.Errorf("Unknown edition %s", edition)
+	}
+	check, err := command.Flags().GetBool("check")
+	if err != nil {
+		return errors.New("Invalid check parameter")
+	}
+	source, err := loadConfigSource(command, edition)
+	if err != nil {
+		return err
+	}
+	settings := source.settings(configRootStruct, map[string]bool{})
+	for _, key := range computedSettings("", settings) {
+		logger.Warn("Default computed at runtime, left null", "setting", key)
+	}
+	data, err := json.MarshalIndent(configDefaults(settings), "", "    ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if !check {
+		return writeCommandOutput(command, data)
+	}
+
+	output, err := command.Flags().GetString("output")
+	if err != nil || output == "" {
+		return errors.New("Invalid output parameter, --check compares with the output file")
+	}
+	current, err := readFile(output)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if !bytes.Equal(current, data) {
+		command.SilenceUsage = true
+		return fmt.Errorf("%s is out of date, run dev config gen-default.", output)
+	}
+	return nil
+}
+
+// diffConfig returns the keys of config unknown to the settings and the
+// deprecated keys it still sets.
+func diffConfig(prefix string, config map[string]interface{}, settings []*configSetting) ([]string, []string) {
+	byKey := map[string]*configSetting{}
+	for _, setting := range settings {
+		byKey[setting.Key] = setting
+	}
+
+	unknown, deprecated := []string{}, []string{}
+	for key, value := range config {
+		name := key
+		if prefix != "" {
+			name = prefix + "." + key
+		}
+		setting, ok := byKey[key]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		if setting.Deprecated {
+			deprecated = append(deprecated, name)
+		}
+		if section, ok := value.(map[string]interface{}); ok && setting.Section != nil {
+			u, d := diffConfig(name, section, setting.Section)
+			unknown = append(unknown, u...)
+			deprecated = append(deprecated, d...)
+		}
+	}
+	sort.Strings(unknown)
+	sort.Strings(deprecated)
+	return unknown, deprecated
+}
+
+func configDiffCmdF(command *cobra.Command, args []string) error {
+	source, err := loadConfigSource(command, "")
+	if err != nil {
+		return err
+	}
+	data, err := readFile(args[0])
+	if err != nil {
+		return err
+	}
+	config := map[string]interface{}{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("Unable to parse %s: %s", args[0], err.Error())
+	}
+	command.SilenceUsage = true
+
+	unknown, deprecated := diffConfig("", config, source.settings(configRootStruct, map[string]bool{}))
+	for _, key := range unknown {
+		fmt.Println("Unknown:", key)
+	}
+	for _, key := range deprecated {
+		fmt.Println("Deprecated:", key)
+	}
+	if len(unknown) > 0 || len(deprecated) > 0 {
+		return fmt.Errorf("%d unknown and %d deprecated settings in %s.", len(unknown), len(deprecated), args[0])
+	}
+	fmt.Println("No unknown or deprecated settings.")
+	return nil
+}
@@ -0,0 +1,338 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+// configFileName is the optional configuration file read from the root of
+// the Xenia source code.
+const configFileName = ".mmgotool.yaml"
+
+// flagEnvPrefix prefixes the environment variables setting the flags, like
+// MMGOTOOL_XENIA_DIR for --xenia-dir.
+const flagEnvPrefix = "MMGOTOOL_"
+
+// configFileFlags are the flags the flags section of the configuration file
+// can set, the ones describing the layout of the source code. The file comes
+// with the checkout, which can't be trusted on a forked pull request, so the
+// flags accessing the network, sending tokens or writing files are only read
+// from the command line and the environment.
+var configFileFlags = map[string]bool{
+	"xenia-dir":          true,
+	"enterprise-dir":     true,
+	"webapp-dir":         true,
+	"extra-dir":          true,
+	"exclude":            true,
+	"include-cmd":        true,
+	"include-enterprise": true,
+	"include-server":     true,
+	"include-templates":  true,
+	"include-tests":      true,
+	"include-tests-path": true,
+	"follow-symlinks":    true,
+	"no-gitignore":       true,
+	"i18n-dir":           true,
+	"api-dir":            true,
+	"migrations-dir":     true,
+	"plugin-dir":         true,
+	"catalog-format":     true,
+	"placeholder":        true,
+	"naming-policy":      true,
+}
+
+// commandLineOnlyFlags are the flags the mmgotool arguments of the
+// configuration file, like the verify checks, can't use: the sandbox flags,
+// and the ones accessing the network, sending tokens or writing files.
+var commandLineOnlyFlags = map[string]bool{
+	"jail":         true,
+	"no-exec":      true,
+	"no-network":   true,
+	"remote":       true,
+	"token":        true,
+	"cache-url":    true,
+	"server-url":   true,
+	"url":          true,
+	"summary-file": true,
+	"report-file":  true,
+	"sign-key":     true,
+	"out":          true,
+	"output":       true,
+	"output-dir":   true,
+	"cpuprofile":   true,
+	"memprofile":   true,
+	"trace":        true,
+}
+
+type toolConfig struct {
+	// Flags are the default values of the command flags, by flag name.
+	// Relative paths of the *-dir flags are resolved from the folder of the
+	// configuration file.
+	Flags  map[string]interface{} `yaml:"flags"`
+	I18n   i18nConfig             `yaml:"i18n"`
+	Verify verifyConfig           `yaml:"verify"`
+	Cron   cronConfig             `yaml:"cron"`
+}
+
+type i18nConfig struct {
+	// ExtraDirs are additional module roots walked by extraction, relative to
+	// the folder containing the configuration file.
+	ExtraDirs []string `yaml:"extra_dirs"`
+	// Exclude are globs of the paths skipped by extraction.
+	Exclude []string `yaml:"exclude"`
+	// TranslationPackages are the import paths of the packages declaring
+	// translation functions, for --typed extraction.
+	TranslationPackages []string `yaml:"translation_packages"`
+	// IncludeTests are globs of the _test.go files walked by extraction.
+	IncludeTests []string `yaml:"include_tests"`
+	// CacheURL is rejected, the shared extraction cache is only set with
+	// --cache-url or its environment variable.
+	CacheURL string `yaml:"cache_url"`
+	// SnapshotKeys are the patterns of the translation ids covered by the
+	// generated snapshot tests.
+	SnapshotKeys []string `yaml:"snapshot_keys"`
+	// Glossary are the protected terms checked by i18n consistency check.
+	Glossary []glossaryTerm `yaml:"glossary"`
+	// NamingDirs restrict the ids of a first segment to source folders,
+	// relative to the module roots, replacing the built-in rules.
+	NamingDirs map[string][]string `yaml:"naming_dirs"`
+	// Placeholder is the translation of the ids added by extraction, see
+	// --placeholder.
+	Placeholder string `yaml:"placeholder"`
+	// SharedPrefixes are the prefixes of the ids the clients render too,
+	// checked by i18n cross-check.
+	SharedPrefixes []string `yaml:"shared_prefixes"`
+}
+
+type verifyConfig struct {
+	// Jobs is the number of checks run at the same time.
+	Jobs int `yaml:"jobs"`
+	// Checks replace the default checks of the verify command.
+	Checks []verifyCheck `yaml:"checks"`
+}
+
+type verifyCheck struct {
+	Name string `yaml:"name"`
+	// Args are the mmgotool arguments running the check.
+	Args []string `yaml:"args"`
+}
+
+type cronConfig struct {
+	// Repo is the owner/name GitHub repository of the alert issues.
+	Repo string `yaml:"repo"`
+	// Labels are the labels of the alert issues, the first one finds the
+	// open alerts.
+	Labels []string `yaml:"labels"`
+	// Thresholds are the increases, in percent of the previous night, that
+	// raise an alert, by metric name or pattern.
+	Thresholds map[string]float64 `yaml:"thresholds"`
+}
+
+// loadToolConfig reads the configuration file of the Xenia folder. A missing
+// file is not an error.
+func loadToolConfig(xeniaDir string) (*toolConfig, error) {
+	return readToolConfig(filepath.Join(xeniaDir, configFileName))
+}
+
+func readToolConfig(configPath string) (*toolConfig, error) {
+	config := &toolConfig{}
+	configDir := filepath.Dir(configPath)
+	if err := sandbox.checkPath(configPath); err != nil {
+		return nil, err
+	}
+	data, err := readFile(configPath)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("Unable to parse %s: %s", configPath, err.Error())
+	}
+	if config.I18n.CacheURL != "" {
+		return nil, fmt.Errorf("Invalid i18n.cache_url in %s: the shared extraction cache can only be set with --cache-url or $%s", configPath, cacheURLEnv)
+	}
+	for _, check := range config.Verify.Checks {
+		if err := checkConfigArgs(check.Args); err != nil {
+			return nil, fmt.Errorf("Invalid %s verify check in %s: %s", check.Name, configPath, err.Error())
+		}
+	}
+
+	for i, dir := range config.I18n.ExtraDirs {
+		if !filepath.IsAbs(dir) {
+			config.I18n.ExtraDirs[i] = filepath.Join(configDir, dir)
+		}
+	}
+	return config, nil
+}
+
+// findToolConfig returns the path of the configuration file of dir or of its
+// closest parent, or an empty string when there is none.
+func findToolConfig(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for sandbox.allows(dir) {
+		configPath := filepath.Join(dir, configFileName)
+		if _, err := os.Stat(configPath); err == nil {
+			return configPath
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+	return ""
+}
+
+// flagEnvName returns the environment variable setting a flag.
+func flagEnvName(name string) string {
+	return flagEnvPrefix + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// applyFlagDefaults sets the flags not given on the command line from the
+// MMGOTOOL_* environment variables, then from the flags section of the
+// closest configuration file.
+func applyFlagDefaults(command *cobra.Command) error {
+	fileFlags := map[string]interface{}{}
+	configDir := ""
+	if wd, err := os.Getwd(); err == nil {
+		if configPath := findToolConfig(wd); configPath != "" {
+			config, err := readToolConfig(configPath)
+			if err != nil {
+				return err
+			}
+			if err := checkConfigFlags(command.Root(), config.Flags); err != nil {
+				return fmt.Errorf("Invalid flags in %s: %s", configPath, err.Error())
+			}
+			fileFlags = config.Flags
+			configDir = filepath.Dir(configPath)
+		}
+	}
+
+	var setErr error
+	command.Flags().VisitAll(func(flag *pflag.Flag) {
+		if setErr != nil || flag.Changed || flag.Name == "help" {
+			return
+		}
+		if value, ok := os.LookupEnv(flagEnvName(flag.Name)); ok {
+			values := []string{value}
+			if isListFlag(flag) {
+				values = strings.Split(value, ",")
+			}
+			if err := setFlagValues(command, flag, values); err != nil {
+				setErr = fmt.Errorf("Invalid %s value: %s", flagEnvName(flag.Name), err.Error())
+			}
+			return
+		}
+		value, ok := fileFlags[flag.Name]
+		if !ok {
+			return
+		}
+		values := []string{}
+		if list, isList := value.([]interface{}); isList {
+			for _, item := range list {
+				values = append(values, fmt.Sprint(item))
+			}
+		} else {
+			values = append(values, fmt.Sprint(value))
+		}
+		if strings.HasSuffix(flag.Name, "-dir") {
+			for i, dir := range values {
+				if !filepath.IsAbs(dir) {
+					values[i] = filepath.Join(configDir, dir)
+				}
+			}
+		}
+		if err := setFlagValues(command, flag, values); err != nil {
+			setErr = fmt.Errorf("Invalid %s value in %s: %s", flag.Name, configFileName, err.Error())
+		}
+	})
+	return setErr
+}
+
+func isListFlag(flag *pflag.Flag) bool {
+	return strings.HasSuffix(flag.Value.Type(), "Slice") || strings.HasSuffix(flag.Value.Type(), "Array")
+}
+
+func setFlagValues(command *cobra.Command, flag *pflag.Flag, values []string) error {
+	if len(values) > 1 && !isListFlag(flag) {
+		return fmt.Errorf("--%s takes a single value", flag.Name)
+	}
+	for _, value := range values {
+		if err := command.Flags().Set(flag.Name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkConfigArgs rejects the mmgotool arguments of the configuration file
+// using a command line only flag.
+func checkConfigArgs(args []string) error {
+	denied := []string{}
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name := strings.TrimLeft(arg, "-")
+		if i := strings.Index(name, "="); i >= 0 {
+			name = name[:i]
+		}
+		if commandLineOnlyFlags[name] {
+			denied = append(denied, "--"+name)
+		}
+	}
+	if len(denied) > 0 {
+		return fmt.Errorf("%s can only be used on the command line", strings.Join(denied, ", "))
+	}
+	return nil
+}
+
+// checkConfigFlags rejects the configured flags no command defines, most
+// likely typos, and the ones only read from the command line and the
+// environment.
+func checkConfigFlags(root *cobra.Command, flags map[string]interface{}) error {
+	known := map[string]bool{}
+	var collect func(command *cobra.Command)
+	collect = func(command *cobra.Command) {
+		command.Flags().VisitAll(func(flag *pflag.Flag) { known[flag.Name] = true })
+		command.PersistentFlags().VisitAll(func(flag *pflag.Flag) { known[flag.Name] = true })
+		for _, child := range command.Commands() {
+			collect(child)
+		}
+	}
+	collect(root)
+
+	unknown := []string{}
+	denied := []string{}
+	for name := range flags {
+		if !known[name] {
+			unknown = append(unknown, name)
+		} else if !configFileFlags[name] {
+			denied = append(denied, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown flags %s", strings.Join(unknown, ", "))
+	}
+	if len(denied) > 0 {
+		sort.Strings(denied)
+		return fmt.Errorf("flags %s can only be set on the command line or with their %s* environment variable", strings.Join(denied, ", "), flagEnvPrefix)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckConfigFlags(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags map[string]interface{}
+		err   string
+	}{
+		{
+			name:  "source layout",
+			flags: map[string]interface{}{"xenia-dir": ".", "enterprise-dir": "../enterprise", "exclude": []interface{}{"vendor/**"}},
+		},
+		{
+			name:  "unknown",
+			flags: map[string]interface{}{"xenia-dri": "."},
+			err:   "unknown flags xenia-dri",
+		},
+		{
+			name:  "remote",
+			flags: map[string]interface{}{"remote": "https://attacker.example.com"},
+			err:   "flags remote can only be set",
+		},
+		{
+			name:  "network and output",
+			flags: map[string]interface{}{"cache-url": "https://attacker.example.com", "summary-file": "/etc/motd", "xenia-dir": "."},
+			err:   "flags cache-url, summary-file can only be set",
+		},
+		{
+			name:  "sandbox",
+			flags: map[string]interface{}{"jail": "/"},
+			err:   "flags jail can only be set",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkConfigFlags(RootCmd, test.flags)
+			if test.err == "" && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Fatalf("got error %v, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestReadToolConfigRejectsCommandLineOnlyFlags(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name:   "verify check",
+			config: "verify:\n  checks:\n    - name: check\n      args: [i18n, check]\n",
+		},
+		{
+			name:   "verify check remote",
+			config: "verify:\n  checks:\n    - name: check\n      args: [i18n, check, --remote=https://attacker.example.com]\n",
+			err:    "--remote can only be used on the command line",
+		},
+		{
+			name:   "verify check jail",
+			config: "verify:\n  checks:\n    - name: check\n      args: [i18n, check, --jail, /]\n",
+			err:    "--jail can only be used on the command line",
+		},
+		{
+			name:   "cache url",
+			config: "i18n:\n  cache_url: https://attacker.example.com\n",
+			err:    "Invalid i18n.cache_url",
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "mmgotool-config-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			configPath := filepath.Join(dir, configFileName)
+			writeTestFile(t, configPath, test.config)
+			_, err := readToolConfig(configPath)
+			if test.err == "" && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Fatalf("got error %v, want %q", err, test.err)
+			}
+		})
+	}
+}
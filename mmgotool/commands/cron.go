@@ -0,0 +1,489 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// cronHistoryRuns is the number of runs kept in the history file.
+const cronHistoryRuns = 90
+
+// defaultCronThresholds alert on any new failing check and on 5% more
+// untranslated strings.
+var defaultCronThresholds = map[string]float64{
+	"checks.failed":     0,
+	"i18n.untranslated": 5,
+}
+
+var CronCmd = &cobra.Command{
+	Use:   "cron",
+	Short: "Run the checks nightly and alert on trends",
+	Long: `Run the verify checks, compute the metrics of the repository and compare them with the previous run stored in the history file. A metric increasing by more than its threshold, in percent of the previous run, opens a GitHub issue. An open issue of the same metric gets a comment instead of a new issue.
+
+The metrics are checks.failed, check.<name>.failed, check.<name>.seconds, i18n.strings, i18n.untranslated and i18n.untranslated.<locale>. The issues need $` + githubTokenEnv + `, the repository and the thresholds come from the cron section of the .mmgotool.yaml file:
+
+  cron:
+    repo: xzl8028/xenia-server
+    labels: [mmgotool-cron, i18n]
+    thresholds:
+      checks.failed: 0
+      i18n.untranslated: 5
+      i18n.untranslated.*: 10
+
+With --at the command keeps running and runs the checks every day at that time, otherwise it runs them once, for a scheduled CI job or a crontab.`,
+	Example: `  lint cron --xenia-dir ../xenia-server --dry-run
+  lint cron --xenia-dir /srv/xenia-server --at 02:00`,
+	Args: cobra.NoArgs,
+	RunE: cronCmdF,
+}
+
+func init() {
+	CronCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	CronCmd.Flags().String("history", "", "File of the metrics of the previous runs, defaults to cron-history.json in the user configuration folder")
+	CronCmd.Flags().String("repo", "", "owner/name GitHub repository of the alert issues, defaults to the configuration")
+	CronCmd.Flags().String("at", "", "Keep running and run the checks every day at this HH:MM local time")
+	CronCmd.Flags().Int("jobs", 0, "Number of checks run at the same time, defaults to the configuration or the number of CPUs")
+	CronCmd.Flags().Bool("dry-run", false, "Print the alerts instead of opening issues")
+	LintCmd.AddCommand(CronCmd)
+}
+
+type cronRun struct {
+	Time     time.Time          `json:"time"`
+	Revision string             `json:"revision,omitempty"`
+	Metrics  map[string]float64 `json:"metrics"`
+}
+
+type cronHistory struct {
+	Runs []cronRun `json:"runs"`
+}
+
+func defaultCronHistoryPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "mmgotool", "cron-history.json"), nil
+}
+
+func loadCronHistory(historyFile string) (*cronHistory, error) {
+	history := &cronHistory{}
+	data, err := readFile(historyFile)
+	if os.IsNotExist(err) {
+		return history, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, history); err != nil {
+		return nil, fmt.Errorf("Unable to parse %s: %s", historyFile, err.Error())
+	}
+	return history, nil
+}
+
+func (h *cronHistory) save(historyFile string) error {
+	if len(h.Runs) > cronHistoryRuns {
+		h.Runs = h.Runs[len(h.Runs)-cronHistoryRuns:]
+	}
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := mkdirAll(filepath.Dir(historyFile), 0700); err != nil {
+		return err
+	}
+	return writeFile(historyFile, data, 0600)
+}
+
+// previous returns the last run, nil for the first one.
+func (h *cronHistory) previous() *cronRun {
+	if len(h.Runs) == 0 {
+		return nil
+	}
+	return &h.Runs[len(h.Runs)-1]
+}
+
+// untranslatedMetrics counts the English strings without translation, in
+// total and by locale.
+func untranslatedMetrics(xeniaDir string, metrics map[string]float64) error {
+	source, err := readTranslationsFile(filepath.Join(xeniaDir, "i18n", "en.json"))
+	if err != nil {
+		return err
+	}
+	metrics["i18n.strings"] = float64(len(source))
+	files, err := localeFiles(xeniaDir)
+	if err != nil {
+		return err
+	}
+	total := 0
+	for _, file := range files {
+		translations, err := readTranslationsFile(file)
+		if err != nil {
+			return err
+		}
+		translated := map[string]bool{}
+		for _, t := range translations {
+			if !isEmptyTranslation(t.Translation) {
+				translated[t.Id] = true
+			}
+		}
+		untranslated := 0
+		for _, t := range source {
+			if !translated[t.Id] {
+				untranslated++
+			}
+		}
+		metrics["i18n.untranslated."+localeName(file)] = float64(untranslated)
+		total += untranslated
+	}
+	metrics["i18n.untranslated"] = float64(total)
+	return nil
+}
+
+func cronMetrics(xeniaDir string, results []verifyResult) (map[string]float64, error) {
+	metrics := map[string]float64{}
+	failed := 0
+	for _, result := range results {
+		name := "check." + result.Check.Name
+		metrics[name+".failed"] = 0
+		if result.Err != nil {
+			metrics[name+".failed"] = 1
+			failed++
+		}
+		metrics[name+".seconds"] = result.Duration.Seconds()
+	}
+	metrics["checks.failed"] = float64(failed)
+	if err := untranslatedMetrics(xeniaDir, metrics); err != nil {
+		return nil, err
+	}
+	return metrics, nil
+}
+
+// cronThreshold returns the threshold of a metric, an exact name winning over
+// a pattern.
+func cronThreshold(thresholds map[string]float64, metric string) (float64, bool) {
+	if threshold, ok := thresholds[metric]; ok {
+		return threshold, true
+	}
+	patterns := []string{}
+	for pattern := range thresholds {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, metric); matched {
+			return thresholds[pattern], true
+		}
+	}
+	return 0, false
+}
+
+type cronAlert struct {
+	Metric    string
+	Previous  float64
+	Current   float64
+	Threshold float64
+}
+
+func (a cronAlert) Title() string {
+	return "Nightly check: " + a.Metric + " increased"
+}
+
+func (a cronAlert) Body(previous, current *cronRun) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "`%s` went from %s to %s", a.Metric, formatMetric(a.Previous), formatMetric(a.Current))
+	if a.Previous != 0 {
+		fmt.Fprintf(&buf, " (+%.1f%%)", (a.Current-a.Previous)/a.Previous*100)
+	}
+	fmt.Fprintf(&buf, ", above the %s%% threshold.\n\n", formatMetric(a.Threshold))
+	fmt.Fprintf(&buf, "| Run | Time | Revision |\n| --- | --- | --- |\n")
+	fmt.Fprintf(&buf, "| Previous | %s | %s |\n", previous.Time.Format(time.RFC3339), previous.Revision)
+	fmt.Fprintf(&buf, "| Current | %s | %s |\n", current.Time.Format(time.RFC3339), current.Revision)
+	return buf.String()
+}
+
+func formatMetric(value float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", value), "0"), ".")
+}
+
+// cronAlerts compares the metrics with the previous run. A metric alerts
+// when it increases by more than its threshold, in percent, or becomes
+// positive.
+func cronAlerts(previous, current *cronRun, thresholds map[string]float64) []cronAlert {
+	alerts := []cronAlert{}
+	if previous == nil {
+		return alerts
+	}
+	metrics := []string{}
+	for metric := range current.Metrics {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+	for _, metric := range metrics {
+		threshold, ok := cronThreshold(thresholds, metric)
+		before, known := previous.Metrics[metric]
+		value := current.Metrics[metric]
+		if !ok || !known || value <= before {
+			continue
+		}
+		if before == 0 || (value-before)/before*100 > threshold {
+			alerts = append(alerts, cronAlert{Metric: metric, Previous: before, Current: value, Threshold: threshold})
+		}
+	}
+	return alerts
+}
+
+type githubIssues struct {
+	client *http.Client
+	repo   string
+	token  string
+}
+
+func (g *githubIssues) do(method, endpoint string, body interface{}, result interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	request, err := http.NewRequest(method, "https://api.github.com/repos/"+g.repo+endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/vnd.github+json")
+	request.Header.Set("Authorization", "Bearer "+g.token)
+	response, err := g.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s from %s %s", response.Status, method, endpoint)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(result)
+}
+
+// openIssue returns the number of the open issue with the title and the
+// label, 0 when there is none.
+func (g *githubIssues) openIssue(title, label string) (int, error) {
+	query := url.Values{"state": {"open"}, "per_page": {"100"}}
+	if label != "" {
+		query.Set("labels", label)
+	}
+	var issues []struct {
+		Number int    `json:"number"`
+		Title  string `json:"title"`
+	}
+	if err := g.do(http.MethodGet, "/issues?"+query.Encode(), nil, &issues); err != nil {
+		return 0, err
+	}
+	for _, issue := range issues {
+		if issue.Title == title {
+			return issue.Number, nil
+		}
+	}
+	return 0, nil
+}
+
+// report opens an issue for the alert, or comments the open one.
+func (g *githubIssues) report(title, body string, labels []string) (string, error) {
+	label := ""
+	if len(labels) > 0 {
+		label = labels[0]
+	}
+	number, err := g.openIssue(title, label)
+	if err != nil {
+		return "", err
+	}
+	if number != 0 {
+		if err := g.do(http.MethodPost, fmt.Sprintf("/issues/%d/comments", number), map[string]string{"body": body}, nil); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("commented #%d", number), nil
+	}
+	var issue struct {
+		Number int `json:"number"`
+	}
+	if err := g.do(http.MethodPost, "/issues", map[string]interface{}{"title": title, "body": body, "labels": labels}, &issue); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("opened #%d", issue.Number), nil
+}
+
+type cronOptions struct {
+	XeniaDir    string
+	HistoryFile string
+	Repo        string
+	Jobs        int
+	DryRun      bool
+}
+
+func runCron(opts cronOptions) error {
+	config, err := loadToolConfig(opts.XeniaDir)
+	if err != nil {
+		return err
+	}
+	checks, err := configuredVerifyChecks(config, nil)
+	if err != nil {
+		return err
+	}
+	results, err := runVerifyChecks(opts.XeniaDir, checks, opts.Jobs, config.Verify.Jobs)
+	if err != nil {
+		return err
+	}
+	metrics, err := cronMetrics(opts.XeniaDir, results)
+	if err != nil {
+		return err
+	}
+	revision, _ := execCommand("git", "-C", opts.XeniaDir, "rev-parse", "HEAD").Output()
+	current := &cronRun{Time: time.Now().UTC(), Revision: strings.TrimSpace(string(revision)), Metrics: metrics}
+
+	history, err := loadCronHistory(opts.HistoryFile)
+	if err != nil {
+		return err
+	}
+	previous := history.previous()
+	thresholds := config.Cron.Thresholds
+	if len(thresholds) == 0 {
+		thresholds = defaultCronThresholds
+	}
+	alerts := cronAlerts(previous, current, thresholds)
+
+	names := []string{}
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "METRIC\tPREVIOUS\tCURRENT")
+	for _, name := range names {
+		before := "-"
+		if previous != nil {
+			if value, ok := previous.Metrics[name]; ok {
+				before = formatMetric(value)
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", name, before, formatMetric(metrics[name]))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	repo := opts.Repo
+	if repo == "" {
+		repo = config.Cron.Repo
+	}
+	labels := config.Cron.Labels
+	if len(labels) == 0 {
+		labels = []string{"mmgotool-cron"}
+	}
+	token := os.Getenv(githubTokenEnv)
+	issues := &githubIssues{client: &http.Client{Timeout: 30 * time.Second}, repo: repo, token: token}
+	failedReports := 0
+	for _, alert := range alerts {
+		if opts.DryRun || repo == "" || token == "" {
+			fmt.Println("Alert:", alert.Title())
+			continue
+		}
+		outcome, err := issues.report(alert.Title(), alert.Body(previous, current), labels)
+		if err != nil {
+			logger.Error("Unable to report the alert", "metric", alert.Metric, "error", err.Error())
+			failedReports++
+			continue
+		}
+		fmt.Printf("Alert: %s, %s\n", alert.Title(), outcome)
+	}
+	if len(alerts) > 0 && !opts.DryRun && (repo == "" || token == "") {
+		fmt.Printf("No issue opened, set the cron repo in %s and $%s.\n", configFileName, githubTokenEnv)
+	}
+
+	// The baseline of the next night doesn't move on dry runs, nor when
+	// alerts are lost, so they are raised again.
+	if failedReports > 0 {
+		return fmt.Errorf("%d alerts could not be reported.", failedReports)
+	}
+	if opts.DryRun {
+		return nil
+	}
+	history.Runs = append(history.Runs, *current)
+	return history.save(opts.HistoryFile)
+}
+
+// nextCronRun returns the next time of the day at the HH:MM clock time.
+func nextCronRun(now time.Time, clock time.Time) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+func cronCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	historyFile, err := command.Flags().GetString("history")
+	if err != nil {
+		return errors.New("Invalid history parameter")
+	}
+	repo, err := command.Flags().GetString("repo")
+	if err != nil {
+		return errors.New("Invalid repo parameter")
+	}
+	at, err := command.Flags().GetString("at")
+	if err != nil {
+		return errors.New("Invalid at parameter")
+	}
+	jobs, err := command.Flags().GetInt("jobs")
+	if err != nil {
+		return errors.New("Invalid jobs parameter")
+	}
+	dryRun, err := command.Flags().GetBool("dry-run")
+	if err != nil {
+		return errors.New("Invalid dry-run parameter")
+	}
+	var clock time.Time
+	if at != "" {
+		if clock, err = time.Parse("15:04", at); err != nil {
+			return errors.New("Invalid at parameter, expected HH:MM")
+		}
+	}
+	if historyFile == "" {
+		if historyFile, err = defaultCronHistoryPath(); err != nil {
+			return err
+		}
+	}
+	command.SilenceUsage = true
+
+	opts := cronOptions{XeniaDir: xeniaDir, HistoryFile: historyFile, Repo: repo, Jobs: jobs, DryRun: dryRun}
+	if at == "" {
+		return runCron(opts)
+	}
+	for {
+		next := nextCronRun(time.Now(), clock)
+		logger.Info("Waiting for the next run", "at", next.Format(time.RFC3339))
+		time.Sleep(time.Until(next))
+		if err := runCron(opts); err != nil {
+			logger.Error("Nightly run failed", "error", err.Error())
+		}
+	}
+}
@@ -0,0 +1,385 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/xzl8028/xenia-utilities/mmgotool/internal/codegen"
+	"gopkg.in/yaml.v2"
+)
+
+// handlerWrappers describe the authentication of the api4 handler
+// wrappers.
+var handlerWrappers = map[string]string{
+	"ApiHandler":                          "No authentication required.",
+	"ApiHandlerTrustRequester":            "No authentication required.",
+	"ApiSessionRequired":                  "Must be authenticated.",
+	"ApiSessionRequiredTrustRequester":    "Must be authenticated.",
+	"ApiSessionRequiredMfa":               "Must be authenticated, MFA is not enforced.",
+	"CloudApiKeyRequired":                 "Must be called with the cloud API key.",
+	"RemoteClusterTokenRequired":          "Must be called with a remote cluster token.",
+	"ApiSessionRequiredDisableWhenBusy":   "Must be authenticated, disabled when the server is busy.",
+	"ApiLocalHandler":                     "Only available through the local mode socket.",
+	"ApiSessionRequiredTrustRequesterMfa": "Must be authenticated.",
+}
+
+var routeParamPattern = regexp.MustCompile(`\{([A-Za-z0-9_]+)(?::[^}]*)?\}`)
+
+var DocsCmd = &cobra.Command{
+	Use:   "docs",
+	Short: "Documentation generation",
+}
+
+var DocsApiDocsCmd = &cobra.Command{
+	Use:   "apidocs",
+	Short: "Generate an OpenAPI skeleton from the api4 handlers",
+	Long: `Read the route registrations and the handlers of the api4 package and write an OpenAPI 3 YAML skeleton with the paths, methods, authentication and the permissions checked by every handler.
+The doc comment of the handler is used as the operation description.`,
+	Example: "  codegen docs apidocs --api-dir ../xenia-server/api4 --output openapi.yaml",
+	RunE:    docsApiDocsCmdF,
+}
+
+func init() {
+	DocsApiDocsCmd.Flags().String("api-dir", "api4", "Path to the api4 package")
+	DocsApiDocsCmd.Flags().String("base-path", "/api/v4", "Path of the API root router")
+	DocsApiDocsCmd.Flags().String("title", "Xenia API", "Title of the API")
+	DocsApiDocsCmd.Flags().String("output", "", "Write the specification to this file instead of the standard output")
+	DocsCmd.AddCommand(DocsApiDocsCmd)
+	CodegenCmd.AddCommand(DocsCmd)
+}
+
+type apiRoute struct {
+	Router      string
+	Path        string
+	Method      string
+	Handler     string
+	Wrapper     string
+	Description string
+	Permissions []string
+}
+
+type apiSource struct {
+	// prefixes holds the PathPrefix of every BaseRoutes subrouter.
+	prefixes map[string]subrouterPrefix
+	handlers map[string]*ast.FuncDecl
+	routes   []apiRoute
+}
+
+type subrouterPrefix struct {
+	Parent string
+	Prefix string
+}
+
+// baseRoute returns the name of the BaseRoutes field an expression such as
+// api.BaseRoutes.Users refers to.
+func baseRoute(expr ast.Expr) string {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return ""
+	}
+	parent, ok := sel.X.(*ast.SelectorExpr)
+	if !ok || parent.Sel.Name != "BaseRoutes" {
+		return ""
+	}
+	return sel.Sel.Name
+}
+
+func stringLiteral(expr ast.Expr) (string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	value, err := strconv.Unquote(lit.Value)
+	return value, err == nil
+}
+
+// methodCall returns the receiver and arguments of a call to the method
+// name, e.g. x.PathPrefix("/users").
+func methodCall(expr ast.Expr, name string) (ast.Expr, []ast.Expr, bool) {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return nil, nil, false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != name {
+		return nil, nil, false
+	}
+	return sel.X, call.Args, true
+}
+
+func loadApiSource(dir string) (*apiSource, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parseDir(fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	source := &apiSource{prefixes: map[string]subrouterPrefix{}, handlers: map[string]*ast.FuncDecl{}}
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			for _, decl := range f.Decls {
+				if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil {
+					source.handlers[fn.Name.Name] = fn
+				}
+			}
+			ast.Inspect(f, source.inspect)
+		}
+	}
+	return source, nil
+}
+
+func (s *apiSource) inspect(n ast.Node) bool {
+	switch node := n.(type) {
+	case *ast.AssignStmt:
+		// api.BaseRoutes.Users = api.BaseRoutes.ApiRoot.PathPrefix("/users").Subrouter()
+		if len(node.Lhs) != 1 || len(node.Rhs) != 1 {
+			return true
+		}
+		name := baseRoute(node.Lhs[0])
+		if name == "" {
+			return true
+		}
+		router, _, ok := methodCall(node.Rhs[0], "Subrouter")
+		if !ok {
+			return true
+		}
+		parent, args, ok := methodCall(router, "PathPrefix")
+		if !ok || len(args) != 1 {
+			return true
+		}
+		prefix, _ := stringLiteral(args[0])
+		s.prefixes[name] = subrouterPrefix{Parent: baseRoute(parent), Prefix: prefix}
+	case *ast.CallExpr:
+		// api.BaseRoutes.Users.Handle("", api.ApiHandler(createUser)).Methods("POST")
+		handle, methodArgs, ok := methodCall(node, "Methods")
+		if !ok {
+			return true
+		}
+		router, args, ok := methodCall(handle, "Handle")
+		if !ok || len(args) != 2 {
+			return true
+		}
+		name := baseRoute(router)
+		routePath, ok := stringLiteral(args[0])
+		if name == "" || !ok {
+			return true
+		}
+		wrapper, handler := handlerNames(args[1])
+		for _, arg := range methodArgs {
+			if method, ok := stringLiteral(arg); ok {
+				s.routes = append(s.routes, apiRoute{Router: name, Path: routePath, Method: strings.ToLower(method), Handler: handler, Wrapper: wrapper})
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// handlerNames returns the wrapper and the handler of api.ApiHandler(createUser).
+func handlerNames(expr ast.Expr) (string, string) {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok || len(call.Args) == 0 {
+		return "", ""
+	}
+	wrapper := ""
+	switch fun := call.Fun.(type) {
+	case *ast.SelectorExpr:
+		wrapper = fun.Sel.Name
+	case *ast.Ident:
+		wrapper = fun.Name
+	}
+	handler := ""
+	if ident, ok := call.Args[0].(*ast.Ident); ok {
+		handler = ident.Name
+	}
+	return wrapper, handler
+}
+
+// resolve returns the full path prefix of a BaseRoutes subrouter.
+func (s *apiSource) resolve(name string) string {
+	prefix := ""
+	for seen := map[string]bool{}; name != "" && !seen[name]; {
+		seen[name] = true
+		p, ok := s.prefixes[name]
+		if !ok {
+			break
+		}
+		prefix = p.Prefix + prefix
+		name = p.Parent
+	}
+	return prefix
+}
+
+// complete resolves the route paths once every subrouter is known and
+// reads the documentation and permissions of the handlers.
+func (s *apiSource) complete(basePath string) {
+	for i := range s.routes {
+		route := &s.routes[i]
+		route.Path = basePath + s.resolve(route.Router) + route.Path
+		fn := s.handlers[route.Handler]
+		if fn == nil {
+			continue
+		}
+		if fn.Doc != nil {
+			route.Description = strings.TrimSpace(fn.Doc.Text())
+		}
+		route.Permissions = handlerPermissions(fn)
+	}
+	sort.SliceStable(s.routes, func(i, j int) bool {
+		if s.routes[i].Path != s.routes[j].Path {
+			return s.routes[i].Path < s.routes[j].Path
+		}
+		return s.routes[i].Method < s.routes[j].Method
+	})
+}
+
+// handlerPermissions returns the permissions passed to the permission
+// checks of the handler.
+func handlerPermissions(fn *ast.FuncDecl) []string {
+	permissions := []string{}
+	seen := map[string]bool{}
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || !strings.Contains(sel.Sel.Name, "Permission") {
+			return true
+		}
+		for _, arg := range call.Args {
+			permission, ok := arg.(*ast.SelectorExpr)
+			if !ok || !strings.HasPrefix(strings.ToUpper(permission.Sel.Name), "PERMISSION") {
+				continue
+			}
+			if !seen[permission.Sel.Name] {
+				seen[permission.Sel.Name] = true
+				permissions = append(permissions, permission.Sel.Name)
+			}
+		}
+		return true
+	})
+	return permissions
+}
+
+func (r apiRoute) operation(operationId string) yaml.MapSlice {
+	description := r.Description
+	notes := []string{}
+	if auth, ok := handlerWrappers[r.Wrapper]; ok {
+		notes = append(notes, auth)
+	}
+	if len(r.Permissions) > 0 {
+		notes = append(notes, "Permissions: "+strings.Join(r.Permissions, ", ")+".")
+	}
+	if len(notes) > 0 {
+		if description != "" {
+			description += "\n\n"
+		}
+		description += "##### Permissions\n" + strings.Join(notes, "\n")
+	}
+
+	operation := yaml.MapSlice{{Key: "operationId", Value: operationId}}
+	if description != "" {
+		operation = append(operation, yaml.MapItem{Key: "description", Value: description})
+	}
+	parameters := []yaml.MapSlice{}
+	for _, match := range routeParamPattern.FindAllStringSubmatch(r.Path, -1) {
+		parameters = append(parameters, yaml.MapSlice{
+			{Key: "name", Value: match[1]},
+			{Key: "in", Value: "path"},
+			{Key: "required", Value: true},
+			{Key: "schema", Value: yaml.MapSlice{{Key: "type", Value: "string"}}},
+		})
+	}
+	if len(parameters) > 0 {
+		operation = append(operation, yaml.MapItem{Key: "parameters", Value: parameters})
+	}
+	operation = append(operation, yaml.MapItem{Key: "responses", Value: yaml.MapSlice{
+		{Key: "200", Value: yaml.MapSlice{{Key: "description", Value: "TODO"}}},
+	}})
+	return operation
+}
+
+func openAPISpec(title string, routes []apiRoute) yaml.MapSlice {
+	handlerUses := map[string]int{}
+	for _, route := range routes {
+		handlerUses[route.Handler]++
+	}
+
+	paths := yaml.MapSlice{}
+	for _, route := range routes {
+		// Operation ids must be unique, a handler registered several times
+		// gets the method appended.
+		operationId := codegen.Export(route.Handler)
+		if handlerUses[route.Handler] > 1 {
+			operationId += codegen.Export(route.Method)
+		}
+		specPath := routeParamPattern.ReplaceAllString(route.Path, "{$1}")
+		if len(paths) == 0 || paths[len(paths)-1].Key != specPath {
+			paths = append(paths, yaml.MapItem{Key: specPath, Value: yaml.MapSlice{}})
+		}
+		item := &paths[len(paths)-1]
+		item.Value = append(item.Value.(yaml.MapSlice), yaml.MapItem{Key: route.Method, Value: route.operation(operationId)})
+	}
+	return yaml.MapSlice{
+		{Key: "openapi", Value: "3.0.0"},
+		{Key: "info", Value: yaml.MapSlice{
+			{Key: "title", Value: title},
+			{Key: "version", Value: "4.0.0"},
+		}},
+		{Key: "paths", Value: paths},
+	}
+}
+
+func docsApiDocsCmdF(command *cobra.Command, args []string) error {
+	apiDir, err := command.Flags().GetString("api-dir")
+	if err != nil {
+		return errors.New("Invalid api-dir parameter")
+	}
+	basePath, err := command.Flags().GetString("base-path")
+	if err != nil {
+		return errors.New("Invalid base-path parameter")
+	}
+	title, err := command.Flags().GetString("title")
+	if err != nil {
+		return errors.New("Invalid title parameter")
+	}
+	output, err := command.Flags().GetString("output")
+	if err != nil {
+		return errors.New("Invalid output parameter")
+	}
+
+	source, err := loadApiSource(apiDir)
+	if err != nil {
+		return err
+	}
+	if len(source.routes) == 0 {
+		command.SilenceUsage = true
+		return fmt.Errorf("No route registration found in %s.", apiDir)
+	}
+	source.complete(basePath)
+
+	data, err := yaml.Marshal(openAPISpec(title, source.routes))
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return writeFile(output, data, 0644)
+}
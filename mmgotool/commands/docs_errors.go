@@ -0,0 +1,224 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const errorPagesHeader = "<!-- Generated by mmgotool codegen docs gen-errors, do not edit. -->\n\n"
+
+var DocsGenErrorsCmd = &cobra.Command{
+	Use:   "gen-errors",
+	Short: "Generate the API error reference pages",
+	Long: `Generate the Markdown pages of the API error reference: every error id of the source code with its HTTP statuses, its English message from i18n/en.json and the places raising it.
+
+The errors are the ids passed to NewAppError and newAppError. There is a page by first segment of the ids, like api.md or store.md, and an index.md listing them. The places are read from a file written by "i18n export-provenance" with --provenance, or extracted from the source code.
+
+With --check nothing is written, the command fails when a page is missing or out of date.`,
+	Example: `  codegen docs gen-errors --output-dir ../docs/source/api/errors
+  codegen docs gen-errors --provenance provenance.json --output-dir errors --check`,
+	RunE: docsGenErrorsCmdF,
+}
+
+func init() {
+	addExtractFlags(DocsGenErrorsCmd)
+	DocsGenErrorsCmd.Flags().String("provenance", "", "File written by i18n export-provenance, the source code is extracted when not set")
+	DocsGenErrorsCmd.Flags().String("output-dir", "errors", "Folder of the generated pages")
+	DocsGenErrorsCmd.Flags().Bool("check", false, "Fail if the pages are missing or out of date instead of writing them")
+	DocsCmd.AddCommand(DocsGenErrorsCmd)
+}
+
+// errorReference is an error of the API error reference.
+type errorReference struct {
+	Id       string
+	Statuses []int
+	Message  string
+	Sites    []provenanceSite
+}
+
+// isErrorSite tells if the id is raised as an error at the site.
+func isErrorSite(site provenanceSite) bool {
+	return site.Status != 0 || site.CallKind == "NewAppError" || site.CallKind == "newAppError"
+}
+
+// errorReferences returns the errors of the provenance by namespace, the
+// first segment of their id.
+func errorReferences(export *provenanceExport, translations []Translation) map[string][]errorReference {
+	messages := map[string]string{}
+	for _, t := range translations {
+		if forms, ok := t.PluralForms(); ok {
+			messages[t.Id] = forms["other"]
+		} else if text, ok := t.Translation.(string); ok {
+			messages[t.Id] = text
+		}
+	}
+
+	pages := map[string][]errorReference{}
+	for id, sites := range export.Keys {
+		reference := errorReference{Id: id, Message: messages[id]}
+		statuses := map[int]bool{}
+		for _, site := range sites {
+			if !isErrorSite(site) {
+				continue
+			}
+			reference.Sites = append(reference.Sites, site)
+			if site.Status != 0 && !statuses[site.Status] {
+				statuses[site.Status] = true
+				reference.Statuses = append(reference.Statuses, site.Status)
+			}
+		}
+		if len(reference.Sites) == 0 {
+			continue
+		}
+		sort.Ints(reference.Statuses)
+		namespace := strings.SplitN(id, ".", 2)[0]
+		pages[namespace] = append(pages[namespace], reference)
+	}
+	for _, references := range pages {
+		sort.Slice(references, func(i, j int) bool { return references[i].Id < references[j].Id })
+	}
+	return pages
+}
+
+func errorPage(namespace string, references []errorReference) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(errorPagesHeader)
+	fmt.Fprintf(&buf, "# API errors: %s\n\n", namespace)
+	buf.WriteString("| Error id | HTTP status | Message | Raised in |\n")
+	buf.WriteString("|---|---|---|---|\n")
+	for _, reference := range references {
+		statuses := []string{}
+		for _, status := range reference.Statuses {
+			statuses = append(statuses, strconv.Itoa(status))
+		}
+		sites := []string{}
+		for _, site := range reference.Sites {
+			place := fmt.Sprintf("%s:%d", site.File, site.Line)
+			if site.Function != "" {
+				place += " (`" + site.Function + "`)"
+			}
+			sites = append(sites, place)
+		}
+		fmt.Fprintf(&buf, "| `%s` | %s | %s | %s |\n", reference.Id, strings.Join(statuses, ", "), markdownCell(reference.Message), markdownCell(strings.Join(sites, "<br>")))
+	}
+	return buf.Bytes()
+}
+
+func errorIndexPage(pages map[string][]errorReference) []byte {
+	namespaces := []string{}
+	for namespace := range pages {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	var buf bytes.Buffer
+	buf.WriteString(errorPagesHeader)
+	buf.WriteString("# API error reference\n\n")
+	buf.WriteString("| Namespace | Errors |\n")
+	buf.WriteString("|---|---|\n")
+	for _, namespace := range namespaces {
+		fmt.Fprintf(&buf, "| [%s](%s.md) | %d |\n", namespace, namespace, len(pages[namespace]))
+	}
+	return buf.Bytes()
+}
+
+// readProvenanceExport reads a file written by i18n export-provenance.
+func readProvenanceExport(file string) (*provenanceExport, error) {
+	data, err := readFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var export provenanceExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("Unable to parse %s: %s", file, err.Error())
+	}
+	if export.Version != provenanceExportVersion {
+		return nil, fmt.Errorf("%s has version %d, export it again with this version of mmgotool.", file, export.Version)
+	}
+	return &export, nil
+}
+
+func docsGenErrorsCmdF(command *cobra.Command, args []string) error {
+	opts, err := getExtractOptions(command)
+	if err != nil {
+		return err
+	}
+	provenanceFile, err := command.Flags().GetString("provenance")
+	if err != nil {
+		return errors.New("Invalid provenance parameter")
+	}
+	outputDir, err := command.Flags().GetString("output-dir")
+	if err != nil || outputDir == "" {
+		return errors.New("Invalid output-dir parameter")
+	}
+	check, err := command.Flags().GetBool("check")
+	if err != nil {
+		return errors.New("Invalid check parameter")
+	}
+	command.SilenceUsage = true
+
+	var export *provenanceExport
+	if provenanceFile != "" {
+		if export, err = readProvenanceExport(provenanceFile); err != nil {
+			return err
+		}
+	} else {
+		refs, problems := extractKeyRefs(opts)
+		if err := reportExtractProblems(opts, problems); err != nil {
+			return err
+		}
+		export = exportedProvenance(refs)
+	}
+	translations, err := readTranslationsFile(filepath.Join(opts.XeniaDir, "i18n", "en.json"))
+	if err != nil {
+		return err
+	}
+
+	pages := errorReferences(export, translations)
+	files := map[string][]byte{"index.md": errorIndexPage(pages)}
+	for namespace, references := range pages {
+		files[namespace+".md"] = errorPage(namespace, references)
+	}
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if check {
+		stale := 0
+		for _, name := range names {
+			current, err := readFile(filepath.Join(outputDir, name))
+			if err != nil || !bytes.Equal(current, files[name]) {
+				fmt.Println("Out of date:", filepath.Join(outputDir, name))
+				stale++
+			}
+		}
+		if stale > 0 {
+			return fmt.Errorf("%d error reference pages are out of date, run codegen docs gen-errors.", stale)
+		}
+		return nil
+	}
+
+	if err := mkdirAll(outputDir, 0755); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := writeFile(filepath.Join(outputDir, name), files[name], 0644); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Wrote %d error reference pages to %s.\n", len(names), outputDir)
+	return nil
+}
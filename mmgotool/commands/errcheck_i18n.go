@@ -0,0 +1,236 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/xzl8028/xenia-utilities/mmgotool/pkg/i18nextract"
+)
+
+var ErrcheckI18nCmd = &cobra.Command{
+	Use:   "errcheck-i18n",
+	Short: "Check the NewAppError call sites",
+	Long: `Check every NewAppError call: the translation id must exist in i18n/en.json, the keys of the params map must match the placeholders of the English string and the status code must be a net/http status constant.
+Calls passing the id or the params through variables are only partially checked.
+
+The problems are sorted into classes. Only the classes of --fail-on fail the check, the others are reported as warnings.
+
+Exit codes:
+` + errcheckI18nClasses.exitCodesHelp(),
+	Example: "  lint errcheck-i18n --xenia-dir ../xenia-server --enterprise-dir ../enterprise",
+	RunE:    runCheck(errcheckI18nCmdF),
+}
+
+func init() {
+	addExtractFlags(ErrcheckI18nCmd)
+	addFindingsFormatFlag(ErrcheckI18nCmd)
+	errcheckI18nClasses.addFailOnFlag(ErrcheckI18nCmd, errcheckI18nClasses.names())
+	LintCmd.AddCommand(ErrcheckI18nCmd)
+}
+
+var appErrorRule = registerFindingRule(findingRule{Id: "i18n/app-error", Description: "The NewAppError call has an unknown id, params not matching the placeholders or an invalid status code", Command: "lint errcheck-i18n"})
+
+// errcheckI18nClasses are the classes of lint errcheck-i18n.
+var errcheckI18nClasses = checkClassSet{
+	{Name: "unknown-id", Description: "translation ids missing from i18n/en.json", ExitCode: 3, Message: "NewAppError calls use translation ids missing from i18n/en.json."},
+	{Name: "params", Description: "params not matching the placeholders of the English string", ExitCode: 4, Message: "NewAppError calls pass params not matching the placeholders."},
+	{Name: "status", Description: "status codes other than net/http constants", ExitCode: 5, Message: "NewAppError calls pass status codes other than net/http constants."},
+	{Name: "arguments", Description: "calls without the 5 arguments of NewAppError", ExitCode: 6, Message: "NewAppError calls have the wrong number of arguments."},
+}
+
+type appErrorFinding struct {
+	Position token.Position
+	Message  string
+	// Class is the class of errcheckI18nClasses of the problem.
+	Class string
+}
+
+func (f appErrorFinding) String() string {
+	return fmt.Sprintf("%s: %s", f.Position, f.Message)
+}
+
+func (f appErrorFinding) finding() finding {
+	return finding{Rule: appErrorRule, Message: f.Message, File: findingPath(f.Position.Filename), Line: f.Position.Line, Column: f.Position.Column, Text: f.String(), Class: f.Class}
+}
+
+func checkAppErrorCalls(filePath string, src []byte, source map[string]interface{}) ([]appErrorFinding, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filePath, src, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := []appErrorFinding{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		name := ""
+		switch fun := call.Fun.(type) {
+		case *ast.SelectorExpr:
+			name = fun.Sel.Name
+		case *ast.Ident:
+			name = fun.Name
+		}
+		if name != "NewAppError" {
+			return true
+		}
+		report := func(class string, node ast.Node, format string, args ...interface{}) {
+			findings = append(findings, appErrorFinding{Position: fset.Position(node.Pos()), Message: fmt.Sprintf(format, args...), Class: class})
+		}
+		if len(call.Args) != 5 {
+			report("arguments", call, "NewAppError expects 5 arguments, got %d", len(call.Args))
+			return true
+		}
+
+		id, idOk := stringLiteral(call.Args[1])
+		if idOk {
+			value, exists := source[id]
+			if !exists {
+				report("unknown-id", call.Args[1], "translation id %s is not in en.json", id)
+			} else {
+				checkAppErrorParams(call.Args[2], id, value, func(node ast.Node, format string, args ...interface{}) {
+					report("params", node, format, args...)
+				})
+			}
+		}
+
+		if !isHTTPStatusConstant(call.Args[4]) {
+			status := "the status code"
+			if lit, ok := call.Args[4].(*ast.BasicLit); ok {
+				status = lit.Value
+			}
+			report("status", call.Args[4], "%s is not a net/http status constant", status)
+		}
+		return true
+	})
+	return findings, nil
+}
+
+// checkAppErrorParams compares the keys of a literal params map with the
+// template fields of the English string.
+func checkAppErrorParams(params ast.Expr, id string, value interface{}, report func(ast.Node, string, ...interface{})) {
+	expected := map[string]bool{}
+	for _, placeholder := range translationPlaceholders(value) {
+		if strings.HasPrefix(placeholder, "{{.") {
+			expected[strings.TrimSuffix(strings.TrimPrefix(placeholder, "{{."), "}}")] = true
+		}
+	}
+
+	actual := map[string]bool{}
+	switch p := params.(type) {
+	case *ast.Ident:
+		if p.Name != "nil" {
+			return
+		}
+	case *ast.CompositeLit:
+		for _, elt := range p.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				return
+			}
+			key, ok := stringLiteral(kv.Key)
+			if !ok {
+				return
+			}
+			actual[key] = true
+		}
+	default:
+		return
+	}
+
+	missing, extra := []string{}, []string{}
+	for key := range expected {
+		if !actual[key] {
+			missing = append(missing, key)
+		}
+	}
+	for key := range actual {
+		if !expected[key] {
+			extra = append(extra, key)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+	if len(missing) > 0 {
+		report(params, "params of %s miss %s", id, strings.Join(missing, ", "))
+	}
+	if len(extra) > 0 {
+		report(params, "params of %s have unused %s", id, strings.Join(extra, ", "))
+	}
+}
+
+func isHTTPStatusConstant(expr ast.Expr) bool {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	if !ok || pkg.Name != "http" {
+		return false
+	}
+	_, ok = i18nextract.HTTPStatusConstants[sel.Sel.Name]
+	return ok
+}
+
+func errcheckI18nCmdF(command *cobra.Command, args []string) error {
+	opts, err := getExtractOptions(command)
+	if err != nil {
+		return err
+	}
+	reporter, err := getFindingsReporter(command)
+	if err != nil {
+		return err
+	}
+	failOn, err := errcheckI18nClasses.getCheckPolicy(command)
+	if err != nil {
+		return err
+	}
+	source, err := sourceTranslations(opts.XeniaDir)
+	if err != nil {
+		return err
+	}
+	command.SilenceUsage = true
+
+	findings := []appErrorFinding{}
+	var walkErr error
+	walkSourceFiles(opts, func(p string) {
+		if walkErr != nil {
+			return
+		}
+		src, err := readFile(p)
+		if err != nil {
+			walkErr = err
+			return
+		}
+		fileFindings, err := checkAppErrorCalls(p, src, source)
+		if err != nil {
+			walkErr = err
+			return
+		}
+		findings = append(findings, fileFindings...)
+	})
+	if walkErr != nil {
+		return walkErr
+	}
+
+	problems := []finding{}
+	for _, f := range findings {
+		problems = append(problems, f.finding())
+	}
+	counts := failOn.Report(reporter, problems)
+	if err := reporter.Flush(); err != nil {
+		return err
+	}
+	return failOn.Result(counts)
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var versionsListPattern = regexp.MustCompile(`(?s)var versions = \[\]string\{\s*"([^"]+)"`)
+
+// release is a server version reduced to its major and minor numbers, the
+// granularity of the expiry of experimental strings.
+type release struct {
+	Major int
+	Minor int
+}
+
+func parseRelease(version string) (release, error) {
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(version), "v"), ".")
+	if len(parts) < 2 {
+		return release{}, fmt.Errorf("invalid release %q, expected major.minor", version)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return release{}, fmt.Errorf("invalid release %q", version)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return release{}, fmt.Errorf("invalid release %q", version)
+	}
+	return release{Major: major, Minor: minor}, nil
+}
+
+func (r release) String() string {
+	return fmt.Sprintf("%d.%d", r.Major, r.Minor)
+}
+
+// before reports whether r is older than other.
+func (r release) before(other release) bool {
+	if r.Major != other.Major {
+		return r.Major < other.Major
+	}
+	return r.Minor < other.Minor
+}
+
+// minorsUntil returns the number of minor releases between r and other,
+// counting only the minors of the same major.
+func (r release) minorsUntil(other release) int {
+	if r.Major != other.Major {
+		if r.before(other) {
+			return int(^uint(0) >> 1)
+		}
+		return -1
+	}
+	return other.Minor - r.Minor
+}
+
+// currentRelease reads the current server version, the first entry of the
+// versions list of model/version.go.
+func currentRelease(xeniaDir string) (release, error) {
+	data, err := readFile(filepath.Join(xeniaDir, "model", "version.go"))
+	if err != nil {
+		return release{}, fmt.Errorf("unable to find the current release, use the release flag: %s", err.Error())
+	}
+	match := versionsListPattern.FindSubmatch(data)
+	if match == nil {
+		return release{}, fmt.Errorf("unable to find the versions list in model/version.go, use the release flag")
+	}
+	return parseRelease(string(match[1]))
+}
+
+// findExpiringKeys returns the experimental keys expiring within window
+// minor releases and the ones already expired at the current release.
+func findExpiringKeys(translations []Translation, current release, window int) ([]string, []string, error) {
+	expiring, expired := []string{}, []string{}
+	for _, t := range translations {
+		if t.Expires == "" {
+			continue
+		}
+		expires, err := parseRelease(t.Expires)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %s", t.Id, err.Error())
+		}
+		if !current.before(expires) {
+			expired = append(expired, fmt.Sprintf("%s (expired in %s)", t.Id, expires))
+		} else if current.minorsUntil(expires) <= window {
+			expiring = append(expiring, fmt.Sprintf("%s (expires in %s)", t.Id, expires))
+		}
+	}
+	sort.Strings(expiring)
+	sort.Strings(expired)
+	return expiring, expired, nil
+}
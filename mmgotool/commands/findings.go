@@ -0,0 +1,290 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	findingsFormatText  = "text"
+	findingsFormatSARIF = "sarif"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	toolHomepage = "https://github.com/xzl8028/xenia-utilities"
+)
+
+// catalogIdPatterns find the id declared on a line of a translation file,
+// in the array and in the map formats.
+var catalogIdPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`"id"\s*:\s*("(?:[^"\\]|\\.)*")`),
+	regexp.MustCompile(`^\s*("(?:[^"\\]|\\.)*")\s*:`),
+}
+
+// findingRule is a kind of problem reported by the check commands.
+type findingRule struct {
+	Id          string
+	Description string
+	// Command is the command checking the rule, Fix the one fixing its
+	// findings, empty when they are fixed by hand. Both lack the binary
+	// name.
+	Command string
+	Fix     string
+	// Severity is the level of the findings, error or warning.
+	Severity string
+}
+
+// fixCommand returns the command fixing the findings of the rule, empty
+// when there is none.
+func (r findingRule) fixCommand() string {
+	if r.Fix == "" {
+		return ""
+	}
+	return binaryName + " " + r.Fix
+}
+
+// finding is a problem found by a check command.
+type finding struct {
+	Rule    findingRule
+	Message string
+	// File and Line locate the problem when known. File is relative to the
+	// current directory, the root of the checkout for code scanning.
+	File   string
+	Line   int
+	Column int
+	// Warning findings don't fail the command.
+	Warning bool
+	// Class is the class of the --fail-on policy of the check command, see
+	// checkPolicy.Report.
+	Class string
+	// Text is the line printed in the text format, "File:Line: Message"
+	// when empty.
+	Text string
+}
+
+func (f finding) String() string {
+	if f.Text != "" {
+		return f.Text
+	}
+	switch {
+	case f.File == "":
+		return f.Message
+	case f.Line == 0:
+		return fmt.Sprintf("%s: %s", f.File, f.Message)
+	case f.Column == 0:
+		return fmt.Sprintf("%s:%d: %s", f.File, f.Line, f.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", f.File, f.Line, f.Column, f.Message)
+}
+
+// findingsReporter prints the findings of a command in the format chosen
+// with --format: one line per finding in the text format, a SARIF log
+// written by Flush in the sarif format.
+type findingsReporter struct {
+	format   string
+	out      io.Writer
+	findings []finding
+}
+
+// addFindingsFormatFlag adds the --format flag of the commands reporting
+// findings.
+func addFindingsFormatFlag(command *cobra.Command) {
+	command.Flags().String("format", findingsFormatText, "Output format: text or sarif")
+}
+
+func getFindingsReporter(command *cobra.Command) (*findingsReporter, error) {
+	format, err := command.Flags().GetString("format")
+	if err != nil {
+		return nil, fmt.Errorf("Invalid format parameter")
+	}
+	return newFindingsReporter(format)
+}
+
+func newFindingsReporter(format string) (*findingsReporter, error) {
+	if format != findingsFormatText && format != findingsFormatSARIF {
+		return nil, fmt.Errorf("Unknown format %s", format)
+	}
+	return &findingsReporter{format: format, out: os.Stdout}, nil
+}
+
+// Report records the finding, printed right away in the text format. The
+// findings of the warning rules are warnings.
+func (r *findingsReporter) Report(f finding) {
+	if f.Rule.Severity == severityWarning {
+		f.Warning = true
+	}
+	r.findings = append(r.findings, f)
+	if r.format == findingsFormatText {
+		fmt.Fprintln(r.out, f.String())
+	}
+}
+
+// Failures returns the number of findings failing the command.
+func (r *findingsReporter) Failures() int {
+	failures := 0
+	for _, f := range r.findings {
+		if !f.Warning {
+			failures++
+		}
+	}
+	return failures
+}
+
+// Printf prints a message of the text format, like the summary of a
+// successful check. The SARIF log stays the only output of the sarif format.
+func (r *findingsReporter) Printf(format string, args ...interface{}) {
+	if r.format == findingsFormatText {
+		fmt.Fprintf(r.out, format, args...)
+	}
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	Id               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleId    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// sarif returns the SARIF log of the findings, the rules in the order they
+// were first reported.
+func (r *findingsReporter) sarif() sarifLog {
+	run := sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: binaryName, InformationURI: toolHomepage, Rules: []sarifRule{}}},
+		Results: []sarifResult{},
+	}
+	rules := map[string]bool{}
+	for _, f := range r.findings {
+		if !rules[f.Rule.Id] {
+			rules[f.Rule.Id] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{Id: f.Rule.Id, ShortDescription: sarifMessage{Text: f.Rule.Description}})
+		}
+		result := sarifResult{RuleId: f.Rule.Id, Level: severityError, Message: sarifMessage{Text: f.Message}}
+		if f.Warning {
+			result.Level = severityWarning
+		}
+		if f.File != "" {
+			location := sarifLocation{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(f.File)}}}
+			if f.Line > 0 {
+				location.PhysicalLocation.Region = &sarifRegion{StartLine: f.Line, StartColumn: f.Column}
+			}
+			result.Locations = []sarifLocation{location}
+		}
+		run.Results = append(run.Results, result)
+	}
+	return sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}}
+}
+
+// Flush writes the SARIF log in the sarif format.
+func (r *findingsReporter) Flush() error {
+	if r.format != findingsFormatSARIF {
+		return nil
+	}
+	encoder := json.NewEncoder(r.out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r.sarif())
+}
+
+// findingPath returns the path of a file relative to the current directory,
+// the absolute path for the files outside of it.
+func findingPath(p string) string {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return filepath.ToSlash(p)
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, abs); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(abs)
+}
+
+// catalogIdLines returns the line declaring every id of a translation file,
+// empty when the file can't be read.
+func catalogIdLines(file string) map[string]int {
+	lines := map[string]int{}
+	f, err := openFile(file)
+	if err != nil {
+		return lines
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		for _, pattern := range catalogIdPatterns {
+			match := pattern.FindStringSubmatch(scanner.Text())
+			if match == nil {
+				continue
+			}
+			if id, err := strconv.Unquote(match[1]); err == nil {
+				if _, seen := lines[id]; !seen {
+					lines[id] = line
+				}
+			}
+			break
+		}
+	}
+	return lines
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var GenDocsCmd = &cobra.Command{
+	Use:     "gen-docs",
+	Short:   "Generate the man pages or markdown reference of every command",
+	Long:    "Write one man page or markdown file per command, in the style of the cobra doc generator",
+	Example: "  gen-docs --format man --output docs/man",
+	Hidden:  true,
+	Args:    cobra.NoArgs,
+	RunE:    genDocsCmdF,
+}
+
+func init() {
+	GenDocsCmd.Flags().String("format", "markdown", "Documentation format: markdown or man")
+	GenDocsCmd.Flags().String("output", "docs", "Folder the documentation is written to")
+	GenDocsCmd.Flags().String("date", "", "Date written in the man pages, YYYY-MM-DD, defaults to today")
+	RootCmd.AddCommand(GenDocsCmd)
+}
+
+// docFileBase returns the file name of the documentation of command without
+// extension, like mmdev_i18n_check.
+func docFileBase(command *cobra.Command) string {
+	return strings.Replace(command.CommandPath(), " ", "_", -1)
+}
+
+func documentedCommands() []*cobra.Command {
+	commands := []*cobra.Command{}
+	var walk func(command *cobra.Command)
+	walk = func(command *cobra.Command) {
+		commands = append(commands, command)
+		for _, child := range command.Commands() {
+			if child.IsAvailableCommand() && child.Name() != "help" {
+				walk(child)
+			}
+		}
+	}
+	walk(RootCmd)
+	return commands
+}
+
+func commandDescription(command *cobra.Command) string {
+	if command.Long != "" {
+		return command.Long
+	}
+	return command.Short
+}
+
+func markdownDoc(command *cobra.Command) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "## %s\n\n%s\n\n", command.CommandPath(), command.Short)
+	fmt.Fprintf(&buf, "### Synopsis\n\n%s\n\n", commandDescription(command))
+	if command.Runnable() {
+		fmt.Fprintf(&buf, "```\n%s\n```\n\n", command.UseLine())
+	}
+	if command.HasExample() {
+		fmt.Fprintf(&buf, "### Examples\n\n```\n%s\n```\n\n", command.Example)
+	}
+	if flags := command.NonInheritedFlags(); flags.HasAvailableFlags() {
+		fmt.Fprintf(&buf, "### Options\n\n```\n%s```\n\n", flags.FlagUsages())
+	}
+	if flags := command.InheritedFlags(); flags.HasAvailableFlags() {
+		fmt.Fprintf(&buf, "### Options inherited from parent commands\n\n```\n%s```\n\n", flags.FlagUsages())
+	}
+
+	seeAlso := []string{}
+	if parent := command.Parent(); parent != nil {
+		seeAlso = append(seeAlso, fmt.Sprintf("* [%s](%s.md)\t - %s", parent.CommandPath(), docFileBase(parent), parent.Short))
+	}
+	for _, child := range command.Commands() {
+		if child.IsAvailableCommand() && child.Name() != "help" {
+			seeAlso = append(seeAlso, fmt.Sprintf("* [%s](%s.md)\t - %s", child.CommandPath(), docFileBase(child), child.Short))
+		}
+	}
+	if len(seeAlso) > 0 {
+		fmt.Fprintf(&buf, "### SEE ALSO\n\n%s\n", strings.Join(seeAlso, "\n"))
+	}
+	return buf.Bytes()
+}
+
+// roffEscape escapes the text of a man page, lines starting with a dot or
+// a quote would be read as requests.
+func roffEscape(text string) string {
+	text = strings.Replace(text, `\`, `\e`, -1)
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// manPageName returns the man page name of command, like mmdev-i18n-check.
+func manPageName(command *cobra.Command) string {
+	return strings.Replace(command.CommandPath(), " ", "-", -1)
+}
+
+func manDoc(command *cobra.Command, date string) []byte {
+	var buf bytes.Buffer
+	title := strings.ToUpper(manPageName(command))
+	fmt.Fprintf(&buf, ".TH %q \"1\" %q \"%s\" \"User Commands\"\n", title, date, binaryName)
+	fmt.Fprintf(&buf, ".SH NAME\n%s \\- %s\n", manPageName(command), roffEscape(command.Short))
+	fmt.Fprintf(&buf, ".SH SYNOPSIS\n.B %s\n", roffEscape(command.UseLine()))
+	fmt.Fprintf(&buf, ".SH DESCRIPTION\n.nf\n%s\n.fi\n", roffEscape(commandDescription(command)))
+	if flags := command.NonInheritedFlags(); flags.HasAvailableFlags() {
+		fmt.Fprintf(&buf, ".SH OPTIONS\n.nf\n%s.fi\n", roffEscape(flags.FlagUsages()))
+	}
+	if flags := command.InheritedFlags(); flags.HasAvailableFlags() {
+		fmt.Fprintf(&buf, ".SH OPTIONS INHERITED FROM PARENT COMMANDS\n.nf\n%s.fi\n", roffEscape(flags.FlagUsages()))
+	}
+	if command.HasExample() {
+		fmt.Fprintf(&buf, ".SH EXAMPLE\n.nf\n%s\n.fi\n", roffEscape(command.Example))
+	}
+
+	seeAlso := []string{}
+	if parent := command.Parent(); parent != nil {
+		seeAlso = append(seeAlso, fmt.Sprintf(".BR %s (1)", manPageName(parent)))
+	}
+	for _, child := range command.Commands() {
+		if child.IsAvailableCommand() && child.Name() != "help" {
+			seeAlso = append(seeAlso, fmt.Sprintf(".BR %s (1)", manPageName(child)))
+		}
+	}
+	if len(seeAlso) > 0 {
+		fmt.Fprintf(&buf, ".SH SEE ALSO\n%s\n", strings.Join(seeAlso, "\n"))
+	}
+	return buf.Bytes()
+}
+
+func genDocsCmdF(command *cobra.Command, args []string) error {
+	format, err := command.Flags().GetString("format")
+	if err != nil {
+		return errors.New("Invalid format parameter")
+	}
+	output, err := command.Flags().GetString("output")
+	if err != nil {
+		return errors.New("Invalid output parameter")
+	}
+	date, err := command.Flags().GetString("date")
+	if err != nil {
+		return errors.New("Invalid date parameter")
+	}
+	if format != "markdown" && format != "man" {
+		return fmt.Errorf("Unknown format %s", format)
+	}
+	if date == "" {
+		date = time.Now().Format("2006-01-02")
+	} else if _, err := time.Parse("2006-01-02", date); err != nil {
+		return errors.New("Invalid date parameter, YYYY-MM-DD is expected")
+	}
+	command.SilenceUsage = true
+
+	if err := mkdirAll(output, 0755); err != nil {
+		return err
+	}
+	commands := documentedCommands()
+	for _, documented := range commands {
+		var content []byte
+		var filePath string
+		if format == "man" {
+			content = manDoc(documented, date)
+			filePath = filepath.Join(output, manPageName(documented)+".1")
+		} else {
+			content = markdownDoc(documented)
+			filePath = filepath.Join(output, docFileBase(documented)+".md")
+		}
+		if err := writeFile(filePath, content, 0644); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("%d %s files written to %s\n", len(commands), format, output)
+	return nil
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var CodegenCmd = &cobra.Command{
+	Use:     "codegen",
+	Aliases: []string{"gen"},
+	Short:   "Code, documentation and Makefile generation",
+}
+
+var LintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Source code checks",
+}
+
+var ReleaseCmd = &cobra.Command{
+	Use:   "release",
+	Short: "Release notes and build tooling",
+}
+
+var DevCmd = &cobra.Command{
+	Use:   "dev",
+	Short: "Day to day development helpers",
+}
+
+// legacyCommands maps the top level commands used before the command groups
+// to their new group. They keep working with a deprecation notice.
+var legacyCommands = map[string]string{
+	"mocks":          "codegen",
+	"store":          "codegen",
+	"make":           "codegen",
+	"docs":           "codegen",
+	"license":        "lint",
+	"errcheck-i18n":  "lint",
+	"assets":         "lint",
+	"logs":           "lint",
+	"verify":         "lint",
+	"changelog":      "release",
+	"build":          "release",
+	"perf":           "dev",
+	"stats":          "dev",
+	"support-packet": "dev",
+	"config":         "dev",
+	"plugin":         "dev",
+}
+
+// usedLegacyCommand is the legacy command name rewritten by Run, reported
+// once the logger is configured.
+var usedLegacyCommand string
+
+// cheatsheet lists the common workflows printed by --cheatsheet.
+var cheatsheet = []struct {
+	Title    string
+	Commands []string
+}{
+	{"Set up a new checkout", []string{
+		"mmgotool init",
+	}},
+	{"Update mmgotool to its latest release", []string{
+		"mmgotool version",
+		"mmgotool self-update",
+	}},
+	{"Update i18n/en.json after changing server strings", []string{
+		"mmgotool i18n extract --xenia-dir . --enterprise-dir ../enterprise",
+	}},
+	{"Check the translations before opening a pull request", []string{
+		"mmgotool i18n check --xenia-dir . --enterprise-dir ../enterprise",
+		"mmgotool lint errcheck-i18n --xenia-dir . --enterprise-dir ../enterprise",
+	}},
+	{"Run every configured check at once", []string{
+		"mmgotool lint verify --xenia-dir .",
+	}},
+	{"Run the release automation plan", []string{
+		"mmgotool run-script release-plan.yaml",
+	}},
+	{"Regenerate the store mocks and layers after changing an interface", []string{
+		"mmgotool codegen mocks generate --dir store --output store/storetest/mocks",
+		"mmgotool codegen store generate-layers --dir store",
+	}},
+	{"Fix the missing copyright headers", []string{
+		"mmgotool lint license fix --dir .",
+	}},
+	{"Write the release notes of a version", []string{
+		"mmgotool release changelog generate v5.12.0..v5.13.0 --github-repo xzl8028/xenia-server",
+	}},
+	{"Start a new plugin", []string{
+		"mmgotool dev plugin init com.example.demo --name Demo",
+	}},
+	{"Investigate a customer support packet", []string{
+		"mmgotool dev support-packet analyze xenia_support_packet.zip --xenia-dir .",
+	}},
+}
+
+func init() {
+	RootCmd.AddCommand(CodegenCmd, LintCmd, ReleaseCmd, DevCmd)
+}
+
+// rewriteLegacyArgs prefixes a legacy top level command with its group and
+// returns the name of the rewritten command, if any. Like cobra, a flag
+// placed before the command takes the next argument as its value unless it
+// is a root flag not needing one, like --verbose.
+func rewriteLegacyArgs(args []string) ([]string, string) {
+	RootCmd.InitDefaultHelpFlag()
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return args, ""
+		}
+		if strings.HasPrefix(arg, "-") {
+			if !strings.Contains(arg, "=") && !rootFlagWithoutValue(arg) {
+				i++
+			}
+			continue
+		}
+		group, ok := legacyCommands[arg]
+		if !ok {
+			return args, ""
+		}
+		rewritten := append([]string{}, args[:i]...)
+		rewritten = append(rewritten, group)
+		return append(rewritten, args[i:]...), arg
+	}
+	return args, ""
+}
+
+// rootFlagWithoutValue reports whether arg is a root flag which can be used
+// without a value.
+func rootFlagWithoutValue(arg string) bool {
+	var flag *pflag.Flag
+	if name := strings.TrimPrefix(arg, "--"); name != arg {
+		flag = RootCmd.PersistentFlags().Lookup(name)
+		if flag == nil {
+			flag = RootCmd.Flags().Lookup(name)
+		}
+	} else if shorthand := strings.TrimPrefix(arg, "-"); len(shorthand) == 1 {
+		flag = RootCmd.PersistentFlags().ShorthandLookup(shorthand)
+		if flag == nil {
+			flag = RootCmd.Flags().ShorthandLookup(shorthand)
+		}
+	} else {
+		// Combined shorthands or a shorthand with its value, like -vq or -j4.
+		return true
+	}
+	return flag != nil && flag.NoOptDefVal != ""
+}
+
+func printCheatsheet(w io.Writer) {
+	fmt.Fprintln(w, "Common workflows:")
+	for _, workflow := range cheatsheet {
+		fmt.Fprintf(w, "\n  %s\n", workflow.Title)
+		for _, line := range workflow.Commands {
+			fmt.Fprintf(w, "    %s\n", line)
+		}
+	}
+}
+
+func rootCmdF(command *cobra.Command, args []string) error {
+	showCheatsheet, err := command.Flags().GetBool("cheatsheet")
+	if err != nil {
+		return errors.New("Invalid cheatsheet parameter")
+	}
+	if showCheatsheet {
+		printCheatsheet(os.Stdout)
+		return nil
+	}
+	return command.Help()
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRewriteLegacyArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		rewritten []string
+		legacy    string
+	}{
+		{
+			name:      "legacy command",
+			args:      []string{"verify", "--xenia-dir", "/x"},
+			rewritten: []string{"lint", "verify", "--xenia-dir", "/x"},
+			legacy:    "verify",
+		},
+		{
+			name:      "flag value before the command",
+			args:      []string{"--xenia-dir", "/x", "verify"},
+			rewritten: []string{"--xenia-dir", "/x", "lint", "verify"},
+			legacy:    "verify",
+		},
+		{
+			name:      "flag value matching a legacy command",
+			args:      []string{"--log-format", "json", "--summary-file", "stats", "i18n", "extract"},
+			rewritten: []string{"--log-format", "json", "--summary-file", "stats", "i18n", "extract"},
+		},
+		{
+			name:      "flag without value before the command",
+			args:      []string{"--verbose", "--jail=/x", "stats"},
+			rewritten: []string{"--verbose", "--jail=/x", "dev", "stats"},
+			legacy:    "stats",
+		},
+		{
+			name:      "help before the command",
+			args:      []string{"-h", "license"},
+			rewritten: []string{"-h", "lint", "license"},
+			legacy:    "license",
+		},
+		{
+			name:      "grouped command",
+			args:      []string{"--xenia-dir", "/x", "i18n", "extract"},
+			rewritten: []string{"--xenia-dir", "/x", "i18n", "extract"},
+		},
+		{
+			name:      "end of the flags",
+			args:      []string{"--", "verify"},
+			rewritten: []string{"--", "verify"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rewritten, legacy := rewriteLegacyArgs(test.args)
+			if !reflect.DeepEqual(rewritten, test.rewritten) || legacy != test.legacy {
+				t.Errorf("got %q, %q, want %q, %q", rewritten, legacy, test.rewritten, test.legacy)
+			}
+		})
+	}
+}
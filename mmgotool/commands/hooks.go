@@ -0,0 +1,187 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/xzl8028/xenia-utilities/mmgotool/internal/codegen"
+)
+
+//go:embed templates/hooks/*.tmpl
+var hooksTemplates embed.FS
+
+// hooksMarker identifies the git hooks written by hooks install, replaced
+// without --force like the ones of init.
+const hooksMarker = `# Installed by "mmgotool dev hooks install".`
+
+// defaultHookCommands are the commands run by the hooks and the workflow.
+var defaultHookCommands = []string{"i18n check"}
+
+var HooksCmd = &cobra.Command{
+	Use:   "hooks",
+	Short: "Git hooks and CI workflow running the checks",
+}
+
+var HooksInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install a git hook running the checks",
+	Long: `Write a pre-commit or pre-push git hook running the mmgotool commands, i18n check by default.
+
+The pre-commit hook passes the staged files to the commands, i18n check then only checks the ids used by these files, unless --all-files is set. The pre-push hook runs the full commands. A hook not written by mmgotool is only replaced with --force.`,
+	Example: `  dev hooks install
+  dev hooks install --hook pre-push --command "i18n check" --command "lint errcheck-i18n"`,
+	Args: cobra.NoArgs,
+	RunE: hooksInstallCmdF,
+}
+
+var HooksGenGithubActionCmd = &cobra.Command{
+	Use:   "gen-github-action",
+	Short: "Generate a GitHub workflow running the checks",
+	Long:  "Generate a GitHub workflow building mmgotool and running the commands, i18n check by default, on the pull requests and on the pushes to the branch. The diagnostics are reported as workflow annotations.",
+	Example: `  dev hooks gen-github-action
+  dev hooks gen-github-action --command "i18n check" --command "lint errcheck-i18n" --check`,
+	Args: cobra.NoArgs,
+	RunE: hooksGenGithubActionCmdF,
+}
+
+func init() {
+	HooksInstallCmd.Flags().String("dir", "./", "Root of the repository")
+	HooksInstallCmd.Flags().String("hook", "pre-commit", "Hook to write: pre-commit or pre-push")
+	HooksInstallCmd.Flags().StringArray("command", defaultHookCommands, "mmgotool command run by the hook, with its flags, can be repeated")
+	HooksInstallCmd.Flags().Bool("all-files", false, "Run the commands on the whole repository instead of the staged files")
+	HooksInstallCmd.Flags().Bool("force", false, "Replace a hook not written by mmgotool")
+	HooksGenGithubActionCmd.Flags().String("output", filepath.Join(".github", "workflows", "mmgotool.yml"), "Path of the generated workflow")
+	HooksGenGithubActionCmd.Flags().String("name", "mmgotool checks", "Name of the workflow")
+	HooksGenGithubActionCmd.Flags().StringArray("command", defaultHookCommands, "mmgotool command run by the workflow, with its flags, can be repeated")
+	HooksGenGithubActionCmd.Flags().String("branch", "master", "Branch whose pushes run the workflow")
+	HooksGenGithubActionCmd.Flags().String("ref", "master", "Branch or tag of xenia-utilities built by the workflow")
+	HooksGenGithubActionCmd.Flags().String("go-version", "1.17", "Version of Go building mmgotool")
+	HooksGenGithubActionCmd.Flags().Bool("check", false, "Fail if the generated workflow is not up to date instead of writing it")
+	HooksCmd.AddCommand(HooksInstallCmd, HooksGenGithubActionCmd)
+	DevCmd.AddCommand(HooksCmd)
+}
+
+type hookData struct {
+	Marker   string
+	Binary   string
+	Commands []string
+	Staged   bool
+	// Skip is the git command whose --no-verify skips the hook.
+	Skip string
+}
+
+type workflowData struct {
+	Name      string
+	Commands  []string
+	Branch    string
+	Ref       string
+	GoVersion string
+}
+
+func hooksInstallCmdF(command *cobra.Command, args []string) error {
+	dir, err := command.Flags().GetString("dir")
+	if err != nil {
+		return errors.New("Invalid dir parameter")
+	}
+	hook, err := command.Flags().GetString("hook")
+	if err != nil {
+		return errors.New("Invalid hook parameter")
+	}
+	commands, err := command.Flags().GetStringArray("command")
+	if err != nil || len(commands) == 0 {
+		return errors.New("Invalid command parameter")
+	}
+	allFiles, err := command.Flags().GetBool("all-files")
+	if err != nil {
+		return errors.New("Invalid all-files parameter")
+	}
+	force, err := command.Flags().GetBool("force")
+	if err != nil {
+		return errors.New("Invalid force parameter")
+	}
+	data := hookData{Marker: hooksMarker, Binary: binaryName, Commands: commands}
+	switch hook {
+	case "pre-commit":
+		data.Staged, data.Skip = !allFiles, "commit"
+	case "pre-push":
+		data.Skip = "push"
+	default:
+		return fmt.Errorf("Unknown hook %s", hook)
+	}
+	command.SilenceUsage = true
+
+	engine, err := codegen.New("dev hooks install", hooksTemplates, "templates/hooks/*.tmpl")
+	if err != nil {
+		return err
+	}
+	content, err := engine.RenderRaw("hook", data)
+	if err != nil {
+		return err
+	}
+	hookPath, err := writeGitHook(dir, hook, content, force)
+	if err != nil {
+		return fmt.Errorf("Unable to install the %s hook: %s.", hook, err.Error())
+	}
+	fmt.Println("Installed", hookPath)
+	return nil
+}
+
+func hooksGenGithubActionCmdF(command *cobra.Command, args []string) error {
+	output, err := command.Flags().GetString("output")
+	if err != nil || output == "" {
+		return errors.New("Invalid output parameter")
+	}
+	check, err := command.Flags().GetBool("check")
+	if err != nil {
+		return errors.New("Invalid check parameter")
+	}
+	data := workflowData{}
+	if data.Commands, err = command.Flags().GetStringArray("command"); err != nil || len(data.Commands) == 0 {
+		return errors.New("Invalid command parameter")
+	}
+	for flag, value := range map[string]*string{
+		"name":       &data.Name,
+		"branch":     &data.Branch,
+		"ref":        &data.Ref,
+		"go-version": &data.GoVersion,
+	} {
+		if *value, err = command.Flags().GetString(flag); err != nil || *value == "" {
+			return fmt.Errorf("Invalid %s parameter", flag)
+		}
+	}
+
+	engine, err := codegen.New("dev hooks gen-github-action", hooksTemplates, "templates/hooks/*.tmpl")
+	if err != nil {
+		return err
+	}
+	content, err := engine.RenderRaw("workflow.yml", data)
+	if err != nil {
+		return err
+	}
+	if check {
+		upToDate, err := matchesFile(output, content)
+		if err != nil {
+			return err
+		}
+		if !upToDate {
+			command.SilenceUsage = true
+			return fmt.Errorf("%s is out of date.", output)
+		}
+		return nil
+	}
+	if err := mkdirAll(filepath.Dir(output), 0755); err != nil {
+		return err
+	}
+	if err := writeFile(output, content, 0644); err != nil {
+		return err
+	}
+	logger.Info("Generated", "path", output)
+	return nil
+}
@@ -4,25 +4,37 @@
 package commands
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"go/ast"
-	"go/parser"
 	"go/token"
-	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/xzl8028/xenia-utilities/mmgotool/pkg/i18nextract"
 )
 
 type Translation struct {
 	Id          string      `json:"id"`
 	Translation interface{} `json:"translation"`
+	// Description gives translators context about the string. It comes from
+	// an "// i18n:" comment next to the call using the key.
+	Description string `json:"description,omitempty"`
+	// Expires marks an experimental string, the check fails once the server
+	// reaches this release so the string is removed or made permanent.
+	Expires string `json:"expires,omitempty"`
+	// Fuzzy marks a machine translation a translator hasn't reviewed yet.
+	Fuzzy bool `json:"fuzzy,omitempty"`
+	// Module is the source module using the id when the Xenia server
+	// doesn't: enterprise, webapp or the name of an extra folder.
+	Module string `json:"module,omitempty"`
 }
 
 var I18nCmd = &cobra.Command{
@@ -31,26 +43,71 @@ var I18nCmd = &cobra.Command{
 }
 
 var ExtractCmd = &cobra.Command{
-	Use:     "extract",
-	Short:   "Extract translations",
-	Long:    "Extract translations from the source code and put them into the i18n/en.json file",
-	Example: "  i18n extract",
-	RunE:    extractCmdF,
+	Use:   "extract",
+	Short: "Extract translations",
+	Long: `Extract translations from the source code and put them into the i18n/en.json file.
+
+The new ids get the placeholder as translation, "{id}" by default to copy the id, so the strings not written yet can be told apart from the intentionally empty ones. The placeholder can be set with --placeholder or in the i18n.placeholder setting of .mmgotool.yaml, {id} being replaced by the id.
+
+Every id is tagged with the module using it when the Xenia server doesn't: enterprise, webapp or the name of an extra folder. With --split-enterprise the enterprise ids are written to i18n/en_enterprise.json instead of i18n/en.json, the catalogs stay split as long as the file exists.
+
+When the enterprise folder doesn't exist or has no Go file, the extraction warns and keeps the enterprise ids, or fails with --strict. When a component of the source code is not extracted, with the --include-* flags, the ids not found are kept instead of being removed.`,
+	Example: `  i18n extract
+  i18n extract --include-enterprise=false`,
+	RunE: extractCmdF,
 }
 
 var CheckCmd = &cobra.Command{
-	Use:     "check",
-	Short:   "Check translations",
-	Long:    "Check translations existing in the source code and compare it to the i18n/en.json file",
-	Example: "  i18n list",
-	RunE:    checkCmdF,
+	Use:   "check [files...]",
+	Short: "Check translations",
+	Long: `Check translations existing in the source code and compare it to the i18n/en.json file.
+
+Experimental strings have an "expires" release in i18n/en.json. The check warns about the ones expiring within the expiry window and fails for the expired ones.
+
+Every id is tagged with the module using it, enterprise, webapp or an extra folder, when the Xenia server doesn't. When i18n/en_enterprise.json exists, the check validates both catalogs: an id in both, or in the catalog of another module than the one using it, makes the translations file out of date.
+
+With --allow-empty=false the untranslated strings, the ones of i18n/en.json still holding the placeholder of extract, fail the check. The intentionally empty strings are allowed. In the JSON report untranslated lists the first ones and empty the second ones.
+
+With --fix the differences are written to i18n/en.json like extract does and the check succeeds, so pre-commit hooks can repair the file. Without it the check never modifies anything.
+
+With --freeze-since the strings added or changed in i18n/en.json since the string freeze, or about to be added from the source code, must have an exception approved with "i18n key-freeze exceptions".
+
+When the enterprise folder doesn't exist or has no Go file, the check warns and keeps the enterprise ids, or fails with --strict. The components not extracted, with the --include-* flags, can't remove ids.
+
+With files as arguments, like the staged files of a pre-commit hook, only the ids used by these files are checked: they must be in i18n/en.json. The removed ids are left to the full check, which runs instead when a translation file is among the files or with --fix.
+
+With --naming-policy the new ids must match the policy, and ids starting with api, app, cli, model, store or web must be used from the matching folder. The folders can be changed in the i18n.naming_dirs section of .mmgotool.yaml.
+
+The differences are sorted into classes, the placeholder mismatches of the locale files included. Only the classes of --fail-on fail the check, the others are only reported, so --fail-on added lets a release branch remove ids. By default every class fails but untranslated, also enabled by --allow-empty=false, and placeholder-mismatch. The check exits with 1 when classes fail it, with --class-exit-codes a class failing it alone exits with its own code.
+
+Exit codes:
+` + i18nCheckClasses.exitCodesHelp(),
+	Example: `  i18n check
+  i18n check app/user.go api4/user.go`,
+	RunE: checkCmdF,
 }
 
 func init() {
-	ExtractCmd.Flags().String("enterprise-dir", "../enterprise", "Path to folder with the Xenia enterprise source code")
-	ExtractCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
-	CheckCmd.Flags().String("enterprise-dir", "../enterprise", "Path to folder with the Xenia enterprise source code")
-	CheckCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	addExtractFlags(ExtractCmd)
+	addExtractFlags(CheckCmd)
+	ExtractCmd.Flags().Bool("dry-run", false, "Print a unified diff of the changes instead of writing i18n/en.json")
+	ExtractCmd.Flags().Bool("check-only-new", false, "Fail without writing anything if new translations would be added, removals are allowed")
+	ExtractCmd.Flags().String("naming-policy", "", "Naming policy of the new ids: dotted-lowercase, package.file.func.error or a regular expression")
+	ExtractCmd.Flags().Bool("strict-naming", false, "Fail without writing anything if new ids break the naming policy")
+	ExtractCmd.Flags().Bool("split-enterprise", false, "Write the ids only used by the enterprise source code to i18n/"+enterpriseCatalogFile+", kept split afterwards")
+	addPlaceholderFlag(ExtractCmd)
+	addPlaceholderFlag(CheckCmd)
+	CheckCmd.Flags().Bool("allow-empty", true, "Allow the strings of i18n/en.json still holding the placeholder, false adds untranslated to --fail-on")
+	i18nCheckClasses.addFailOnFlag(CheckCmd, i18nCheckFailOn)
+	CheckCmd.Flags().String("naming-policy", "", "Naming policy of the new ids: dotted-lowercase, package.file.func.error or a regular expression")
+	CheckCmd.Flags().String("format", "text", "Output format: text, json or sarif")
+	CheckCmd.Flags().Int("summary-threshold", 100, "Print the added and removed ids as counts by namespace when there are more than this, 0 always lists them")
+	CheckCmd.Flags().StringArray("expand", []string{}, "Namespace to list when the ids are summarized, like api or api.user, can be repeated")
+	CheckCmd.Flags().String("report-file", "", "Write the full list of the added and removed ids to this file")
+	CheckCmd.Flags().Bool("fix", false, "Rewrite i18n/en.json like extract when it is out of date instead of failing")
+	CheckCmd.Flags().String("release", "", "Current server release, read from model/version.go by default")
+	CheckCmd.Flags().String("freeze-since", "", "Release or git revision of the string freeze, fail for the strings added or changed since without an approved exception")
+	CheckCmd.Flags().Int("expiry-window", 1, "Number of minor releases before the expiry of an experimental string to warn about it")
 	I18nCmd.AddCommand(
 		ExtractCmd,
 		CheckCmd,
@@ -58,52 +115,810 @@ func init() {
 	RootCmd.AddCommand(I18nCmd)
 }
 
+// enterpriseCatalogFile is the English catalog of the ids only used by the
+// enterprise source code, when they are split from i18n/en.json.
+const enterpriseCatalogFile = "en_enterprise.json"
+
+const enterpriseModule = "enterprise"
+
+// getCurrentTranslations returns the English translations, the ones of the
+// enterprise catalog included.
 func getCurrentTranslations(xeniaDir string) ([]Translation, error) {
-	jsonFile, err := ioutil.ReadFile(path.Join(xeniaDir, "i18n", "en.json"))
+	translations, err := readTranslationsFile(path.Join(xeniaDir, "i18n", "en.json"))
+	if err != nil || !enterpriseCatalogSplit(xeniaDir) {
+		return translations, err
+	}
+	enterprise, err := readTranslationsFile(path.Join(xeniaDir, "i18n", enterpriseCatalogFile))
 	if err != nil {
 		return nil, err
 	}
-	var translations []Translation
-	json.Unmarshal(jsonFile, &translations)
+	ids := map[string]bool{}
+	for _, t := range translations {
+		ids[t.Id] = true
+	}
+	for _, t := range enterprise {
+		if ids[t.Id] {
+			return nil, fmt.Errorf("The id %s is in both i18n/en.json and i18n/%s.", t.Id, enterpriseCatalogFile)
+		}
+		t.Module = enterpriseModule
+		translations = append(translations, t)
+	}
+	sort.SliceStable(translations, func(i, j int) bool { return translations[i].Id < translations[j].Id })
 	return translations, nil
 }
 
-func extractStrings(enterpriseDir, xeniaDir string) map[string]bool {
-	i18nStrings := map[string]bool{}
-	walkFunc := func(p string, info os.FileInfo, err error) error {
-		if strings.HasPrefix(p, path.Join(xeniaDir, "vendor")) {
-			return nil
+// enterpriseCatalogSplit tells if the enterprise ids have their own catalog.
+func enterpriseCatalogSplit(xeniaDir string) bool {
+	_, err := os.Stat(path.Join(xeniaDir, "i18n", enterpriseCatalogFile))
+	return err == nil
+}
+
+// englishCatalogs returns the translations of i18n/en.json by file name.
+// When split, the ids of the enterprise module go to the enterprise catalog.
+func englishCatalogs(translations []Translation, split bool) map[string][]Translation {
+	if !split {
+		return map[string][]Translation{"en.json": translations}
+	}
+	catalogs := map[string][]Translation{"en.json": {}, enterpriseCatalogFile: {}}
+	for _, t := range translations {
+		if t.Module == enterpriseModule {
+			catalogs[enterpriseCatalogFile] = append(catalogs[enterpriseCatalogFile], t)
+		} else {
+			catalogs["en.json"] = append(catalogs["en.json"], t)
 		}
-		return extractFromPath(p, info, err, &i18nStrings)
 	}
-	filepath.Walk(xeniaDir, walkFunc)
-	filepath.Walk(enterpriseDir, walkFunc)
-	return i18nStrings
+	return catalogs
 }
 
-func extractCmdF(command *cobra.Command, args []string) error {
+// writeEnglishTranslations writes the English catalogs, with their metadata
+// when --catalog-metadata is set.
+func writeEnglishTranslations(xeniaDir string, translations []Translation, split bool) error {
+	for name, catalog := range englishCatalogs(translations, split) {
+		filePath := path.Join(xeniaDir, "i18n", name)
+		meta := newCatalogMeta(xeniaDir, len(catalog))
+		if err := writeTranslationsFile(filePath, withCatalogMeta(catalog, meta)); err != nil {
+			return err
+		}
+		if err := writeCatalogMetaSidecar(filePath, meta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// englishCatalogMeta checks the metadata of the English catalogs, returning
+// the one of i18n/en.json.
+func englishCatalogMeta(xeniaDir string) (*catalogMeta, error) {
+	var enMeta *catalogMeta
+	for name := range englishCatalogs(nil, enterpriseCatalogSplit(xeniaDir)) {
+		filePath := path.Join(xeniaDir, "i18n", name)
+		meta, err := readCatalogMeta(filePath)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkCatalogMeta(filePath, meta); err != nil {
+			return meta, err
+		}
+		if name == "en.json" {
+			enMeta = meta
+		}
+	}
+	return enMeta, nil
+}
+
+// sourceModule returns the module of a source file: empty for the Xenia
+// folder, enterprise, webapp or the name of an extra folder.
+func sourceModule(opts *extractOptions, filePath string) string {
+	module := ""
+	best := ""
+	if rel, err := filepath.Rel(opts.XeniaDir, filePath); err == nil && !strings.HasPrefix(rel, "..") {
+		best = rel
+	}
+	consider := func(dir, name string) {
+		rel, err := filepath.Rel(dir, filePath)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return
+		}
+		if best == "" || len(rel) < len(best) {
+			best, module = rel, name
+		}
+	}
+	if opts.Components[enterpriseModule] {
+		consider(opts.EnterpriseDir, enterpriseModule)
+	}
+	for _, dir := range opts.ExtraDirs {
+		consider(dir, filepath.Base(filepath.Clean(dir)))
+	}
+	if opts.WebappDir != "" {
+		consider(opts.WebappDir, "webapp")
+	}
+	return module
+}
+
+// keyModules returns the module of every referenced id. An id used by the
+// Xenia server belongs to it, else an id used by the enterprise source code
+// belongs to the enterprise module.
+func keyModules(refs []keyRef) map[string]string {
+	rank := func(module string) int {
+		switch module {
+		case "":
+			return 0
+		case enterpriseModule:
+			return 1
+		}
+		return 2
+	}
+	modules := map[string]string{}
+	for _, ref := range refs {
+		current, seen := modules[ref.Id]
+		if !seen || rank(ref.Module) < rank(current) || (rank(ref.Module) == rank(current) && ref.Module < current) {
+			modules[ref.Id] = ref.Module
+		}
+	}
+	return modules
+}
+
+func readTranslationsFile(filePath string) ([]Translation, error) {
+	if err := sandbox.checkPath(filePath); err != nil {
+		return nil, err
+	}
+	jsonFile, err := readFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	translations, err := decodeTranslations(jsonFile)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse %s: %s", filePath, err.Error())
+	}
+	return translations, nil
+}
+
+// extractOptions holds the flags shared by the commands walking the source
+// code looking for translation strings.
+type extractOptions struct {
+	EnterpriseDir string
+	XeniaDir      string
+	ExtraDirs     []string
+	// WebappDir is the root of the webapp sources, extraction reads its
+	// JavaScript and TypeScript files when set.
+	WebappDir   string
+	Exclude     []string
+	NoGitignore bool
+	// FollowSymlinks walks the symlinked folders of the source folders.
+	FollowSymlinks bool
+	// IncludeTests walks the _test.go files too, TestPaths only the ones
+	// matching these globs.
+	IncludeTests bool
+	TestPaths    []string
+	Jobs         int
+	NoCache      bool
+	// Typed resolves the callee of the translation calls and only extracts
+	// the ones of TranslationPackages.
+	Typed               bool
+	TranslationPackages []string
+	// Strict fails the extraction when a file can't be read or parsed,
+	// instead of skipping it.
+	Strict bool
+	// CacheURL is the base URL of the shared extraction cache.
+	CacheURL string
+	// Components are the parts of the source code extracted, by name.
+	Components map[string]bool
+}
+
+// extractComponents are the parts of the source code the extraction can
+// skip: the server, the enterprise folder, and the templates and cmd
+// folders of the server.
+var extractComponents = []string{"server", "enterprise", "templates", "cmd"}
+
+// SourceDirs returns the root of every module walked by extraction.
+func (o *extractOptions) SourceDirs() []string {
+	dirs := []string{o.XeniaDir}
+	if o.Components["enterprise"] {
+		dirs = append(dirs, o.EnterpriseDir)
+	}
+	return append(dirs, o.ExtraDirs...)
+}
+
+// ExcludedComponents returns the components not extracted. The ids they use
+// can't be told apart from the unused ones.
+func (o *extractOptions) ExcludedComponents() []string {
+	excluded := []string{}
+	for _, component := range extractComponents {
+		if !o.Components[component] {
+			excluded = append(excluded, component)
+		}
+	}
+	return excluded
+}
+
+// serverComponent returns the component of a path of the Xenia folder,
+// relative to it.
+func serverComponent(rel string) string {
+	top := strings.SplitN(rel, "/", 2)[0]
+	if top == "templates" || top == "cmd" {
+		return top
+	}
+	return "server"
+}
+
+func addExtractFlags(command *cobra.Command) {
+	command.Flags().String("enterprise-dir", "../enterprise", "Path to folder with the Xenia enterprise source code")
+	command.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	command.Flags().StringArray("extra-dir", []string{}, "Path to an additional folder with source code to extract translations from, can be repeated")
+	command.Flags().String("webapp-dir", "", "Path to folder with the webapp source code, to extract translations from its JavaScript and TypeScript files")
+	command.Flags().StringArray("exclude", []string{}, "Glob of the paths to skip, relative to every source folder, can be repeated")
+	command.Flags().Bool("no-gitignore", false, "Walk the paths ignored by the .gitignore files too")
+	command.Flags().Bool("follow-symlinks", false, "Walk the symlinked folders too, each folder is walked once")
+	command.Flags().Bool("include-tests", false, "Extract translations from the _test.go files too")
+	command.Flags().StringArray("include-tests-path", []string{}, "Glob of the _test.go files to extract translations from, relative to every source folder, can be repeated")
+	command.Flags().Int("jobs", 0, "Number of files to parse in parallel (defaults to GOMAXPROCS)")
+	command.Flags().Bool("no-cache", false, "Parse every file ignoring the extraction cache")
+	command.Flags().Bool("typed", false, "Load the packages with their types and only extract the calls of the translation packages functions")
+	command.Flags().StringArray("translation-package", []string{}, "Import path of a package declaring translation functions for --typed, can be repeated (defaults to the Xenia ones)")
+	command.Flags().Bool("strict", false, "Fail when a source file can't be read or parsed instead of skipping it")
+	command.Flags().String("cache-url", "", "Base URL of a shared extraction cache, defaults to $"+cacheURLEnv)
+	command.Flags().Bool("include-server", true, "Extract the translations of the server source code, outside of its templates and cmd folders")
+	command.Flags().Bool("include-enterprise", true, "Extract the translations of the enterprise source code")
+	command.Flags().Bool("include-templates", true, "Extract the translations of the templates folder of the server")
+	command.Flags().Bool("include-cmd", true, "Extract the translations of the cmd folder of the server")
+}
+
+func getExtractOptions(command *cobra.Command) (*extractOptions, error) {
 	enterpriseDir, err := command.Flags().GetString("enterprise-dir")
 	if err != nil {
-		return errors.New("Invalid enterprise-dir parameter")
+		return nil, errors.New("Invalid enterprise-dir parameter")
 	}
 	xeniaDir, err := command.Flags().GetString("xenia-dir")
 	if err != nil {
-		return errors.New("Invalid xenia-dir parameter")
+		return nil, errors.New("Invalid xenia-dir parameter")
+	}
+	extraDirs, err := command.Flags().GetStringArray("extra-dir")
+	if err != nil {
+		return nil, errors.New("Invalid extra-dir parameter")
+	}
+	webappDir, err := command.Flags().GetString("webapp-dir")
+	if err != nil {
+		return nil, errors.New("Invalid webapp-dir parameter")
+	}
+	exclude, err := command.Flags().GetStringArray("exclude")
+	if err != nil {
+		return nil, errors.New("Invalid exclude parameter")
+	}
+	noGitignore, err := command.Flags().GetBool("no-gitignore")
+	if err != nil {
+		return nil, errors.New("Invalid no-gitignore parameter")
+	}
+	followSymlinks, err := command.Flags().GetBool("follow-symlinks")
+	if err != nil {
+		return nil, errors.New("Invalid follow-symlinks parameter")
+	}
+	includeTests, err := command.Flags().GetBool("include-tests")
+	if err != nil {
+		return nil, errors.New("Invalid include-tests parameter")
+	}
+	testPaths, err := command.Flags().GetStringArray("include-tests-path")
+	if err != nil {
+		return nil, errors.New("Invalid include-tests-path parameter")
+	}
+	jobs, err := command.Flags().GetInt("jobs")
+	if err != nil {
+		return nil, errors.New("Invalid jobs parameter")
+	}
+	noCache, err := command.Flags().GetBool("no-cache")
+	if err != nil {
+		return nil, errors.New("Invalid no-cache parameter")
+	}
+	typed, err := command.Flags().GetBool("typed")
+	if err != nil {
+		return nil, errors.New("Invalid typed parameter")
+	}
+	translationPackages, err := command.Flags().GetStringArray("translation-package")
+	if err != nil {
+		return nil, errors.New("Invalid translation-package parameter")
+	}
+	strict, err := command.Flags().GetBool("strict")
+	if err != nil {
+		return nil, errors.New("Invalid strict parameter")
+	}
+	cacheURL, err := command.Flags().GetString("cache-url")
+	if err != nil {
+		return nil, errors.New("Invalid cache-url parameter")
+	}
+	components := map[string]bool{}
+	for _, component := range extractComponents {
+		if components[component], err = command.Flags().GetBool("include-" + component); err != nil {
+			return nil, fmt.Errorf("Invalid include-%s parameter", component)
+		}
+	}
+	if components["enterprise"] {
+		if problem := enterpriseDirProblem(enterpriseDir); problem != "" {
+			if strict {
+				return nil, fmt.Errorf("The enterprise folder %s %s, set --enterprise-dir or run with --include-enterprise=false.", enterpriseDir, problem)
+			}
+			// Extracting without it would remove every enterprise id, they
+			// are kept like the ones of the excluded components.
+			logger.Warn("The enterprise folder "+problem+", its ids are kept but not checked. Set --enterprise-dir, or --include-enterprise=false to silence this warning.", "path", enterpriseDir)
+			components["enterprise"] = false
+		}
+	}
+	config, err := loadToolConfig(xeniaDir)
+	if err != nil {
+		return nil, err
+	}
+	if cacheURL == "" {
+		cacheURL = os.Getenv(cacheURLEnv)
+	}
+	if !noCache && !sandbox.allows(extractCacheDir(xeniaDir)) {
+		logger.Debug("Disabling the extraction cache out of the --jail folders", "path", extractCacheDir(xeniaDir))
+		noCache = true
+	}
+	translationPackages = append(translationPackages, config.I18n.TranslationPackages...)
+	if len(translationPackages) == 0 {
+		translationPackages = defaultTranslationPackages
+	}
+	if typed && sandbox.noExec {
+		return nil, errors.New("The typed extraction runs the go command, it can't be used with --no-exec.")
+	}
+	if typed && len(sandbox.roots) > 0 {
+		return nil, errors.New("The typed extraction loads the dependencies out of the source code, it can't be used with --jail.")
+	}
+	opts := &extractOptions{
+		EnterpriseDir:       enterpriseDir,
+		XeniaDir:            xeniaDir,
+		ExtraDirs:           append(extraDirs, config.I18n.ExtraDirs...),
+		WebappDir:           webappDir,
+		Exclude:             append(exclude, config.I18n.Exclude...),
+		NoGitignore:         noGitignore,
+		FollowSymlinks:      followSymlinks,
+		IncludeTests:        includeTests,
+		TestPaths:           append(testPaths, config.I18n.IncludeTests...),
+		Jobs:                jobs,
+		NoCache:             noCache,
+		Strict:              strict,
+		Typed:               typed,
+		TranslationPackages: translationPackages,
+		CacheURL:            cacheURL,
+		Components:          components,
+	}
+	for _, dir := range append(opts.SourceDirs(), opts.WebappDir) {
+		if dir == "" {
+			continue
+		}
+		if err := sandbox.checkPath(dir); err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+// enterpriseDirProblem tells why the enterprise folder can't be extracted,
+// empty when it can.
+func enterpriseDirProblem(enterpriseDir string) string {
+	if !sandbox.allows(enterpriseDir) {
+		return "is out of the --jail folders"
+	}
+	info, err := os.Stat(enterpriseDir)
+	if err != nil || !info.IsDir() {
+		return "doesn't exist"
+	}
+	found := false
+	i18nextract.Walk(enterpriseDir, false, func(p string, info os.FileInfo, err error) error {
+		if err != nil || found {
+			return filepath.SkipDir
+		}
+		if info.IsDir() && (info.Name() == "vendor" || i18nextract.SkippedDirs[info.Name()]) {
+			return filepath.SkipDir
+		}
+		found = !info.IsDir() && strings.HasSuffix(p, ".go")
+		return nil
+	})
+	if !found {
+		return "has no Go file"
 	}
+	return ""
+}
+
+// keyRef is a reference to a translation id found in the source code.
+type keyRef = i18nextract.Ref
 
-	i18nStrings := extractStrings(enterpriseDir, xeniaDir)
+// sourceExtractor parses the source files, the commands select the test
+// files themselves.
+var sourceExtractor = i18nextract.New(i18nextract.Options{
+	IncludeTests: true,
+	OnNonLiteral: func(pos token.Position, function string) {
+		logger.Debug("Skipping translation call with a non literal id", "position", pos.String(), "func", function)
+	},
+})
+
+// extractProblem is a source file extraction skipped.
+type extractProblem struct {
+	Path string
+	Err  error
+}
+
+// sourceSkipper returns the Skip option of the extraction: the components
+// not extracted, the excluded paths, the paths ignored by git, the test
+// files not included and the dist folders of the webapp. The Go files are
+// extracted from the source folders, the JavaScript and TypeScript files
+// from the webapp folder.
+func sourceSkipper(opts *extractOptions) func(root, p string, info os.FileInfo) bool {
+	matchers := map[string]*ignoreMatcher{}
+	tests := &ignoreMatcher{}
+	for _, pattern := range opts.TestPaths {
+		tests.addPattern("", pattern)
+	}
+	return func(root, p string, info os.FileInfo) bool {
+		if !sandbox.allows(p) {
+			logger.Debug("Skipping path out of the --jail folders", "path", p)
+			return true
+		}
+		matcher, ok := matchers[root]
+		if !ok {
+			matcher = &ignoreMatcher{}
+			for _, pattern := range opts.Exclude {
+				matcher.addPattern("", pattern)
+			}
+			matchers[root] = matcher
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return true
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			rel = ""
+		}
+		webapp := root == opts.WebappDir
+
+		if !webapp && root == opts.XeniaDir && rel != "" && !opts.Components[serverComponent(rel)] {
+			// The server folder holds the other components, only its own
+			// files are skipped.
+			if !info.IsDir() || serverComponent(rel) != "server" {
+				logger.Debug("Skipping excluded component", "path", p)
+				return true
+			}
+		}
+		if info.IsDir() {
+			if webapp && info.Name() == "dist" {
+				logger.Debug("Skipping webapp folder", "path", p)
+				return true
+			}
+			if rel != "" && matcher.ignored(rel, true) {
+				logger.Debug("Skipping ignored folder", "path", p)
+				return true
+			}
+			if !opts.NoGitignore {
+				matcher.addGitignore(p, rel)
+			}
+			return false
+		}
+		if webapp != i18nextract.IsWebappFile(p) {
+			return true
+		}
+		test := strings.HasSuffix(p, "_test.go") || (webapp && i18nextract.IsWebappTestFile(p))
+		if test && !opts.IncludeTests && !tests.ignored(rel, false) {
+			return true
+		}
+		if matcher.ignored(rel, false) {
+			logger.Debug("Skipping ignored file", "path", p)
+			return true
+		}
+		return false
+	}
+}
+
+// newSourceExtractor returns the extractor walking the source files of the
+// options, with parse reading the references of a file.
+func newSourceExtractor(opts *extractOptions, parse func(p string) ([]keyRef, error)) *i18nextract.Extractor {
+	return i18nextract.New(i18nextract.Options{
+		IncludeTests:   true,
+		Webapp:         true,
+		FollowSymlinks: opts.FollowSymlinks,
+		Jobs:           opts.Jobs,
+		Skip:           sourceSkipper(opts),
+		Parse:          parse,
+	})
+}
+
+// walkSourceFiles calls fn with every Go file of the source folders the
+// extraction parses.
+func walkSourceFiles(opts *extractOptions, fn func(p string)) {
+	newSourceExtractor(opts, nil).WalkSourceFiles(context.Background(), opts.SourceDirs(), func(p, root string) error {
+		fn(p)
+		return nil
+	})
+}
+
+// walkSourceDirs calls fn with every folder of the source folders walked
+// for the files the extraction parses, until fn fails.
+func walkSourceDirs(opts *extractOptions, fn func(p string) error) error {
+	return newSourceExtractor(opts, nil).WalkSourceDirs(context.Background(), opts.SourceDirs(), func(p, root string) error {
+		return fn(p)
+	})
+}
+
+// walkWebappFiles calls fn with every JavaScript and TypeScript file of the
+// webapp folder the extraction parses.
+func walkWebappFiles(opts *extractOptions, fn func(p string)) {
+	if opts.WebappDir == "" {
+		return
+	}
+	newSourceExtractor(opts, nil).WalkSourceFiles(context.Background(), []string{opts.WebappDir}, func(p, root string) error {
+		fn(p)
+		return nil
+	})
+}
+
+// extractKeyRefs parses the source code and returns the translation
+// references found, ordered by file path, and the files that couldn't be
+// read or parsed.
+func extractKeyRefs(opts *extractOptions) ([]keyRef, []extractProblem) {
+	defer i18nProfile.phase("extract")()
+
+	var cache *extractCache
+	var remote *remoteCache
+	if !opts.NoCache {
+		done := i18nProfile.phase("cache load")
+		cache = loadExtractCache(opts.XeniaDir)
+		if opts.CacheURL != "" {
+			remote = newRemoteCache(opts.CacheURL, opts.XeniaDir)
+			if err := remote.fill(cache); err != nil {
+				logger.Warn("Unable to read the shared extraction cache", "error", err)
+			}
+		}
+		done()
+	}
+
+	var typedKeys map[string][]keyRef
+	problems := []extractProblem{}
+	if opts.Typed {
+		typedKeys, problems = extractTypedKeyRefs(opts)
+	}
+	parse := func(p string) ([]keyRef, error) {
+		if abs, err := filepath.Abs(p); err == nil && typedKeys[abs] != nil {
+			return typedKeys[abs], nil
+		}
+		return extractFromPath(p, cache)
+	}
+
+	dirs := opts.SourceDirs()
+	if opts.WebappDir != "" {
+		dirs = append(dirs, opts.WebappDir)
+	}
+	keys, err := newSourceExtractor(opts, parse).Extract(context.Background(), dirs...)
+	if fileErrors, ok := err.(i18nextract.FileErrors); ok {
+		for _, fileError := range fileErrors {
+			problems = append(problems, extractProblem{Path: fileError.Path, Err: fileError.Err})
+		}
+	} else if err != nil {
+		problems = append(problems, extractProblem{Path: opts.XeniaDir, Err: err})
+	}
+	sort.Slice(problems, func(i, j int) bool { return problems[i].Path < problems[j].Path })
+
+	refs := []keyRef{}
+	for _, id := range keys.Ids() {
+		refs = append(refs, keys[id]...)
+	}
+	sort.SliceStable(refs, func(i, j int) bool {
+		if refs[i].Path != refs[j].Path {
+			return refs[i].Path < refs[j].Path
+		}
+		return refs[i].Line < refs[j].Line
+	})
+	for i, ref := range refs {
+		refs[i].Path, refs[i].Module = sourceRelativePath(opts, ref.Path), sourceModule(opts, ref.Path)
+	}
+
+	i18nProfile.countKeys(len(refs))
+
+	defer i18nProfile.phase("cache save")()
+	if cache != nil {
+		if err := cache.save(); err != nil {
+			logger.Warn("Unable to save the extraction cache", "error", err)
+		}
+	}
+	if remote != nil {
+		if err := remote.upload(cache); err != nil {
+			logger.Warn("Unable to upload the shared extraction cache", "error", err)
+		}
+	}
+	return refs, problems
+}
+
+// reportExtractProblems prints the files skipped by the extraction. In
+// strict mode they fail the command.
+func reportExtractProblems(opts *extractOptions, problems []extractProblem) error {
+	for _, problem := range problems {
+		if opts.Strict {
+			logger.Error("Unable to extract translations", "path", problem.Path, "error", problem.Err)
+		} else {
+			logger.Warn("Skipping file", "path", problem.Path, "error", problem.Err)
+		}
+	}
+	if len(problems) > 0 && opts.Strict {
+		return fmt.Errorf("%d source files couldn't be read or parsed.", len(problems))
+	}
+	return nil
+}
+
+// keepExcludedTranslations marks the current ids as used when components
+// are not extracted, their ids would be removed otherwise.
+func keepExcludedTranslations(opts *extractOptions, i18nStrings map[string]bool, translations []Translation) {
+	excluded := opts.ExcludedComponents()
+	if len(excluded) == 0 {
+		return
+	}
+	logger.Warn("Some components are not extracted, the ids not found are kept", "components", strings.Join(excluded, ", "))
+	for _, t := range translations {
+		i18nStrings[t.Id] = true
+	}
+}
+
+// keepTranslations marks the current ids as used, so a file skipped by the
+// extraction doesn't remove its ids from the translations.
+func keepTranslations(i18nStrings map[string]bool, translations []Translation) {
+	logger.Warn("Some files were skipped, the ids not found are kept")
+	for _, t := range translations {
+		i18nStrings[t.Id] = true
+	}
+}
+
+// getI18nStrings returns every translation id the server needs: the ones
+// found in the source code and the ones generated at runtime.
+func getI18nStrings(opts *extractOptions) (map[string]bool, []extractProblem, error) {
+	refs, problems := extractKeyRefs(opts)
+	if err := reportExtractProblems(opts, problems); err != nil {
+		return nil, nil, err
+	}
+	return i18nStringsFromRefs(opts, refs), problems, nil
+}
+
+func i18nStringsFromRefs(opts *extractOptions, refs []keyRef) map[string]bool {
+	i18nStrings := map[string]bool{}
+	for _, ref := range refs {
+		i18nStrings[ref.Id] = true
+	}
 	addDynamicallyGeneratedStrings(&i18nStrings)
+	addNoticeStrings(opts.XeniaDir, &i18nStrings)
+	return i18nStrings
+}
 
-	i18nStringsList := []string{}
-	for id := range i18nStrings {
-		i18nStringsList = append(i18nStringsList, id)
+func extractCmdF(command *cobra.Command, args []string) error {
+	opts, err := getExtractOptions(command)
+	if err != nil {
+		return err
 	}
-	sort.Strings(i18nStringsList)
 
+	dryRun, err := command.Flags().GetBool("dry-run")
+	if err != nil {
+		return errors.New("Invalid dry-run parameter")
+	}
+	checkOnlyNew, err := command.Flags().GetBool("check-only-new")
+	if err != nil {
+		return errors.New("Invalid check-only-new parameter")
+	}
+	strictNaming, err := command.Flags().GetBool("strict-naming")
+	if err != nil {
+		return errors.New("Invalid strict-naming parameter")
+	}
+	split, err := command.Flags().GetBool("split-enterprise")
+	if err != nil {
+		return errors.New("Invalid split-enterprise parameter")
+	}
+	policy, err := getNamingPolicy(command, opts.XeniaDir)
+	if err != nil {
+		return err
+	}
+	placeholder, err := getPlaceholder(command, opts.XeniaDir)
+	if err != nil {
+		return err
+	}
+	if strictNaming && policy == nil {
+		return errors.New("The strict-naming flag requires a naming policy")
+	}
+
+	refs, problems := extractKeyRefs(opts)
+	if err := reportExtractProblems(opts, problems); err != nil {
+		command.SilenceUsage = true
+		return err
+	}
+	i18nStrings := i18nStringsFromRefs(opts, refs)
+
+	translations, err := getCurrentTranslations(opts.XeniaDir)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		keepTranslations(i18nStrings, translations)
+	}
+	keepExcludedTranslations(opts, i18nStrings, translations)
+
+	if policy != nil {
+		added, _ := diffTranslations(i18nStrings, translations)
+		violations := policy.namingViolations(refs, added)
+		for _, violation := range violations {
+			if strictNaming {
+				fmt.Println("Naming:", violation.String())
+			} else {
+				logger.Warn("Naming policy violation", "id", violation.Id, "path", violation.Path, "reason", violation.Reason)
+			}
+		}
+		if strictNaming && len(violations) > 0 {
+			command.SilenceUsage = true
+			return errors.New("New translation ids break the naming policy.")
+		}
+	}
+
+	for _, conflict := range findDefaultConflicts(refs, translations, placeholder) {
+		logger.Warn("Conflicting English strings", "conflict", conflict.String())
+	}
+
+	if checkOnlyNew {
+		added, _ := diffTranslations(i18nStrings, translations)
+		if len(added) > 0 {
+			for _, translationKey := range added {
+				fmt.Println("Added:", translationKey)
+			}
+			command.SilenceUsage = true
+			return errors.New("New translations are not allowed.")
+		}
+	}
+
+	result := mergeTranslations(translations, i18nStrings, refs, placeholder)
+	split = split || enterpriseCatalogSplit(opts.XeniaDir)
+	if !dryRun {
+		recordProvenance(opts, refs, result)
+		return writeEnglishTranslations(opts.XeniaDir, result, split)
+	}
+
+	catalogs := englishCatalogs(result, split)
+	for _, name := range []string{"en.json", enterpriseCatalogFile} {
+		catalog, ok := catalogs[name]
+		if !ok {
+			continue
+		}
+		// A new enterprise catalog is diffed against nothing.
+		current, err := readFile(path.Join(opts.XeniaDir, "i18n", name))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		updated, err := encodeCatalog(catalog, catalogFileFormat(path.Join(opts.XeniaDir, "i18n", name)))
+		if err != nil {
+			return err
+		}
+		fmt.Print(unifiedDiff("a/i18n/"+name, "b/i18n/"+name, string(current), string(updated), 3))
+	}
+	return nil
+}
+
+// updateTranslations rewrites the i18n/en.json file adding the new strings
+// and removing the ones not used anymore.
+func updateTranslations(xeniaDir string, i18nStrings map[string]bool, refs []keyRef, placeholder string) error {
 	translations, err := getCurrentTranslations(xeniaDir)
 	if err != nil {
 		return err
 	}
+	result := mergeTranslations(translations, i18nStrings, refs, placeholder)
+	return writeEnglishTranslations(xeniaDir, result, enterpriseCatalogSplit(xeniaDir))
+}
+
+// mergeTranslations adds the new strings to the translations and removes the
+// ones not used anymore. New strings get the English text given by their
+// call sites, like TDefault, or the placeholder as translation, for every
+// plural category of English when they are plural. The strings still
+// holding the placeholder get the text of the call sites too. Descriptions
+// and modules found in the source code replace the stored ones.
+func mergeTranslations(translations []Translation, i18nStrings map[string]bool, refs []keyRef, placeholder string) []Translation {
+	defer i18nProfile.phase("merge and sort")()
+	plural := pluralKeyIds(refs)
+	descriptions := keyDescriptions(refs)
+	defaults := keyDefaults(refs)
+
+	i18nStringsList := []string{}
+	for id := range i18nStrings {
+		i18nStringsList = append(i18nStringsList, id)
+	}
+	sort.Strings(i18nStringsList)
 
 	translationsList := []string{}
 	idx := map[string]bool{}
@@ -117,7 +932,28 @@ func extractCmdF(command *cobra.Command, args []string) error {
 
 	for _, translationKey := range i18nStringsList {
 		if _, hasKey := idx[translationKey]; !hasKey {
-			resultMap[translationKey] = Translation{Id: translationKey, Translation: ""}
+			text := placeholderText(placeholder, translationKey)
+			if defaultText, ok := defaults[translationKey]; ok {
+				text = defaultText
+			}
+			if plural[translationKey] {
+				forms := emptyPluralForms("en")
+				for category := range forms {
+					forms[category] = text
+				}
+				resultMap[translationKey] = Translation{Id: translationKey, Translation: forms}
+			} else {
+				resultMap[translationKey] = Translation{Id: translationKey, Translation: text}
+			}
+			continue
+		}
+		if defaultText, ok := defaults[translationKey]; ok && resultMap[translationKey].Translation == placeholderText(placeholder, translationKey) {
+			t := resultMap[translationKey]
+			t.Translation = defaultText
+			resultMap[translationKey] = t
+		}
+		if _, isPlural := resultMap[translationKey].PluralForms(); plural[translationKey] && !isPlural {
+			logger.Warn("Translation used with a count but not plural", "id", translationKey)
 		}
 	}
 
@@ -127,279 +963,555 @@ func extractCmdF(command *cobra.Command, args []string) error {
 		}
 	}
 
+	modules := keyModules(refs)
 	result := []Translation{}
 	for _, t := range resultMap {
+		if description, ok := descriptions[t.Id]; ok {
+			t.Description = description
+		}
+		if module, ok := modules[t.Id]; ok {
+			t.Module = module
+		}
 		result = append(result, t)
 	}
 	sort.Slice(result, func(i, j int) bool { return result[i].Id < result[j].Id })
+	return result
+}
 
-	f, err := os.Create(path.Join(xeniaDir, "i18n", "en.json"))
-	defer f.Close()
-
-	encoder := json.NewEncoder(f)
+func encodeTranslations(translations []Translation) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
 	encoder.SetIndent("", "  ")
 	encoder.SetEscapeHTML(false)
-	err = encoder.Encode(result)
+	if err := encoder.Encode(translations); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeTranslationsFile(filePath string, translations []Translation) error {
+	data, err := encodeCatalog(translations, catalogFileFormat(filePath))
 	if err != nil {
 		return err
 	}
+	return writeFile(filePath, data, 0644)
+}
 
-	return nil
+type checkReport struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	// Empty are the strings intentionally left empty.
+	Empty []string `json:"empty"`
+	// Untranslated are the strings still holding the placeholder.
+	Untranslated []string          `json:"untranslated"`
+	Expiring     []string          `json:"expiring"`
+	Expired      []string          `json:"expired"`
+	Naming       []namingViolation `json:"naming"`
+	// RemovedFrom explains every removed id.
+	RemovedFrom []removalAttribution `json:"removed_from"`
+	Frozen      []frozenChange       `json:"frozen,omitempty"`
+	// Modules are the ids whose module tag is out of date.
+	Modules []moduleChange `json:"modules"`
+	// Conflicts are the ids whose call sites give different English texts.
+	Conflicts []defaultConflict `json:"conflicts"`
+	// Catalog is the metadata of i18n/en.json, when it has some.
+	Catalog *catalogMeta `json:"catalog,omitempty"`
+	// Suggestions are the known ids close to the added ones, likely typos.
+	Suggestions []keySuggestion `json:"suggestions"`
+	// Placeholders are the translations of the locales not using the
+	// placeholders of the English strings.
+	Placeholders []*placeholderMismatch `json:"placeholders"`
+}
+
+// moduleChange is an id used by another module than the one it is tagged
+// with.
+type moduleChange struct {
+	Id   string `json:"id"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func (c moduleChange) String() string {
+	name := func(module string) string {
+		if module == "" {
+			return "xenia"
+		}
+		return module
+	}
+	return fmt.Sprintf("%s (%s -> %s)", c.Id, name(c.From), name(c.To))
+}
+
+// moduleChanges returns the referenced ids whose module tag differs from
+// the module using them, sorted by id.
+func moduleChanges(translations []Translation, refs []keyRef) []moduleChange {
+	modules := keyModules(refs)
+	changes := []moduleChange{}
+	for _, t := range translations {
+		if module, ok := modules[t.Id]; ok && module != t.Module {
+			changes = append(changes, moduleChange{Id: t.Id, From: t.Module, To: module})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Id < changes[j].Id })
+	return changes
 }
 
 func checkCmdF(command *cobra.Command, args []string) error {
-	enterpriseDir, err := command.Flags().GetString("enterprise-dir")
+	opts, err := getExtractOptions(command)
 	if err != nil {
-		return errors.New("Invalid enterprise-dir parameter")
+		return &ExitError{Code: checkExitInternal, Err: err}
 	}
-	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	format, err := command.Flags().GetString("format")
+	if err != nil {
+		return &ExitError{Code: checkExitInternal, Err: errors.New("Invalid format parameter")}
+	}
+	if format != "text" && format != "json" && format != findingsFormatSARIF {
+		return &ExitError{Code: checkExitInternal, Err: fmt.Errorf("Unknown format %s", format)}
+	}
+	releaseFlag, err := command.Flags().GetString("release")
+	if err != nil {
+		return &ExitError{Code: checkExitInternal, Err: errors.New("Invalid release parameter")}
+	}
+	expiryWindow, err := command.Flags().GetInt("expiry-window")
+	if err != nil {
+		return &ExitError{Code: checkExitInternal, Err: errors.New("Invalid expiry-window parameter")}
+	}
+	fix, err := command.Flags().GetBool("fix")
+	if err != nil {
+		return &ExitError{Code: checkExitInternal, Err: errors.New("Invalid fix parameter")}
+	}
+	threshold, err := command.Flags().GetInt("summary-threshold")
+	if err != nil {
+		return &ExitError{Code: checkExitInternal, Err: errors.New("Invalid summary-threshold parameter")}
+	}
+	expand, err := command.Flags().GetStringArray("expand")
+	if err != nil {
+		return &ExitError{Code: checkExitInternal, Err: errors.New("Invalid expand parameter")}
+	}
+	reportFile, err := command.Flags().GetString("report-file")
+	if err != nil {
+		return &ExitError{Code: checkExitInternal, Err: errors.New("Invalid report-file parameter")}
+	}
+	freezeSince, err := command.Flags().GetString("freeze-since")
+	if err != nil {
+		return &ExitError{Code: checkExitInternal, Err: errors.New("Invalid freeze-since parameter")}
+	}
+	allowEmpty, err := command.Flags().GetBool("allow-empty")
+	if err != nil {
+		return &ExitError{Code: checkExitInternal, Err: errors.New("Invalid allow-empty parameter")}
+	}
+	placeholder, err := getPlaceholder(command, opts.XeniaDir)
+	if err != nil {
+		return &ExitError{Code: checkExitInternal, Err: err}
+	}
+	policy, err := getNamingPolicy(command, opts.XeniaDir)
+	if err != nil {
+		return &ExitError{Code: checkExitInternal, Err: err}
+	}
+	failOn, err := i18nCheckClasses.getCheckPolicy(command)
 	if err != nil {
-		return errors.New("Invalid xenia-dir parameter")
+		return &ExitError{Code: checkExitInternal, Err: err}
+	}
+	if !allowEmpty {
+		failOn.require("untranslated")
 	}
+	command.SilenceUsage = true
 
-	i18nStrings := extractStrings(enterpriseDir, xeniaDir)
-	addDynamicallyGeneratedStrings(&i18nStrings)
+	if len(args) > 0 && !fix && !touchesTranslations(args) {
+		return checkFiles(opts, args, format, newIdSummary(threshold, expand), failOn)
+	}
 
-	i18nStringsList := []string{}
-	for id := range i18nStrings {
-		i18nStringsList = append(i18nStringsList, id)
+	extractStart := time.Now()
+	refs, problems := extractKeyRefs(opts)
+	if err := reportExtractProblems(opts, problems); err != nil {
+		return &ExitError{Code: checkExitInternal, Err: err}
 	}
-	sort.Strings(i18nStringsList)
+	i18nStrings := i18nStringsFromRefs(opts, refs)
+	stepSummary.AddTiming("Extraction", time.Since(extractStart))
 
-	translations, err := getCurrentTranslations(xeniaDir)
+	translations, err := getCurrentTranslations(opts.XeniaDir)
 	if err != nil {
-		return err
+		return &ExitError{Code: checkExitInternal, Err: err}
 	}
-
-	translationsList := []string{}
-	idx := map[string]bool{}
-	for _, t := range translations {
-		idx[t.Id] = true
-		translationsList = append(translationsList, t.Id)
+	keepExcludedTranslations(opts, i18nStrings, translations)
+	// --fix can't downgrade the catalogs of a newer schema.
+	meta, metaErr := englishCatalogMeta(opts.XeniaDir)
+	if metaErr != nil && (!fix || meta.Schema > catalogMetaSchema) {
+		if failOn.Fails("catalog") {
+			return failOn.Failure("catalog", metaErr)
+		}
+		logger.Warn("Incompatible catalog allowed by the --fail-on policy", "error", metaErr)
+		metaErr = nil
+	}
+	if meta != nil {
+		logger.Debug("Catalog metadata", "tool_version", meta.ToolVersion, "extracted_at", meta.ExtractedAt, "commit", meta.Commit, "keys", meta.Keys)
 	}
-	sort.Strings(translationsList)
 
-	changed := false
-	for _, translationKey := range i18nStringsList {
-		if _, hasKey := idx[translationKey]; !hasKey {
-			fmt.Println("Added:", translationKey)
-			changed = true
+	added, removed := diffTranslations(i18nStrings, translations)
+	expiring, expired, err := checkExpiringKeys(opts.XeniaDir, translations, releaseFlag, expiryWindow)
+	if err != nil {
+		return &ExitError{Code: checkExitInternal, Err: err}
+	}
+	naming := []namingViolation{}
+	if policy != nil {
+		naming = policy.namingViolations(refs, added)
+	}
+	removedFrom := attributeRemovals(opts, refs, translations, removed)
+	untranslated := untranslatedTranslations(translations, placeholder)
+	modules := moduleChanges(translations, refs)
+	conflicts := findDefaultConflicts(refs, translations, placeholder)
+	suggestions := suggestMissingKeys(opts, refs, translations, added)
+	mismatches, err := localePlaceholderMismatches(opts.XeniaDir, translations)
+	if err != nil {
+		return &ExitError{Code: checkExitInternal, Err: err}
+	}
+	frozen := []frozenChange{}
+	if freezeSince != "" {
+		if frozen, err = checkStringFreeze(opts.XeniaDir, freezeSince, translations, added); err != nil {
+			return &ExitError{Code: checkExitInternal, Err: err}
 		}
 	}
-
-	for _, translationKey := range translationsList {
-		if _, hasKey := i18nStrings[translationKey]; !hasKey {
-			fmt.Println("Removed:", translationKey)
-			changed = true
+	addedLines := []checkLine{}
+	for _, translationKey := range added {
+		addedLines = append(addedLines, checkLine{Id: translationKey, Text: translationKey})
+	}
+	removedLines := []checkLine{}
+	for _, attribution := range removedFrom {
+		removedLines = append(removedLines, checkLine{Id: attribution.Id, Text: attribution.String()})
+	}
+	if summaryRequested(command) {
+		if err := summarizeCheck(opts.XeniaDir, translations, i18nStrings, added, removedFrom, expired, naming, frozen); err != nil {
+			return &ExitError{Code: checkExitInternal, Err: err}
 		}
 	}
-	if changed {
-		command.SilenceUsage = true
-		return errors.New("Translations file out of date.")
+	if reportFile != "" {
+		if err := writeCheckReportFile(reportFile, addedLines, removedLines); err != nil {
+			return &ExitError{Code: checkExitInternal, Err: err}
+		}
 	}
-	return nil
-}
-
-func addDynamicallyGeneratedStrings(i18nStrings *map[string]bool) {
-	(*i18nStrings)["model.user.is_valid.pwd_lowercase.app_error"] = true
-	(*i18nStrings)["model.user.is_valid.pwd_lowercase_number.app_error"] = true
-	(*i18nStrings)["model.user.is_valid.pwd_lowercase_number_symbol.app_error"] = true
-	(*i18nStrings)["model.user.is_valid.pwd_lowercase_symbol.app_error"] = true
-	(*i18nStrings)["model.user.is_valid.pwd_lowercase_uppercase.app_error"] = true
-	(*i18nStrings)["model.user.is_valid.pwd_lowercase_uppercase_number.app_error"] = true
-	(*i18nStrings)["model.user.is_valid.pwd_lowercase_uppercase_number_symbol.app_error"] = true
-	(*i18nStrings)["model.user.is_valid.pwd_lowercase_uppercase_symbol.app_error"] = true
-	(*i18nStrings)["model.user.is_valid.pwd_number.app_error"] = true
-	(*i18nStrings)["model.user.is_valid.pwd_number_symbol.app_error"] = true
-	(*i18nStrings)["model.user.is_valid.pwd_symbol.app_error"] = true
-	(*i18nStrings)["model.user.is_valid.pwd_uppercase.app_error"] = true
-	(*i18nStrings)["model.user.is_valid.pwd_uppercase_number.app_error"] = true
-	(*i18nStrings)["model.user.is_valid.pwd_uppercase_number_symbol.app_error"] = true
-	(*i18nStrings)["model.user.is_valid.pwd_uppercase_symbol.app_error"] = true
-	(*i18nStrings)["January"] = true
-	(*i18nStrings)["February"] = true
-	(*i18nStrings)["March"] = true
-	(*i18nStrings)["April"] = true
-	(*i18nStrings)["May"] = true
-	(*i18nStrings)["June"] = true
-	(*i18nStrings)["July"] = true
-	(*i18nStrings)["August"] = true
-	(*i18nStrings)["September"] = true
-	(*i18nStrings)["October"] = true
-	(*i18nStrings)["November"] = true
-	(*i18nStrings)["December"] = true
-}
-
-func extractByFuncName(name string, args []ast.Expr) *string {
-	if name == "T" {
-		if len(args) == 0 {
-			return nil
-		}
-
-		key, ok := args[0].(*ast.BasicLit)
-		if !ok {
-			return nil
+	if format == "json" {
+		report := checkReport{Added: added, Removed: removed, Empty: emptyTranslations(translations), Untranslated: untranslated, Expiring: expiring, Expired: expired, Naming: naming, RemovedFrom: removedFrom, Frozen: frozen, Modules: modules, Conflicts: conflicts, Suggestions: suggestions, Catalog: meta, Placeholders: mismatches}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return &ExitError{Code: checkExitInternal, Err: err}
 		}
-		return &key.Value
-	} else if name == "NewAppError" {
-		if len(args) < 2 {
-			return nil
+	} else if format == findingsFormatSARIF {
+		reporter, _ := newFindingsReporter(format)
+		result := checkFindings{Added: added, RemovedFrom: removedFrom, Modules: modules, Expired: expired, Naming: naming, Frozen: frozen, Conflicts: conflicts, Suggestions: suggestions, Placeholders: mismatches}
+		if failOn.Fails("untranslated") {
+			result.Untranslated = untranslated
 		}
-
-		key, ok := args[1].(*ast.BasicLit)
-		if !ok {
-			return nil
+		reportCheckFindings(reporter, opts, refs, translations, result, failOn)
+		if err := reporter.Flush(); err != nil {
+			return &ExitError{Code: checkExitInternal, Err: err}
 		}
-		return &key.Value
-	} else if name == "newAppError" {
-		if len(args) < 1 {
-			return nil
+	} else {
+		summary := newIdSummary(threshold, expand)
+		summary.Print(os.Stdout, "Added", addedLines)
+		for _, suggestion := range suggestions {
+			fmt.Println("Suggestion:", suggestion.String())
 		}
-		key, ok := args[0].(*ast.BasicLit)
-		if !ok {
-			return nil
+		summary.Print(os.Stdout, "Removed", removedLines)
+		for _, change := range modules {
+			fmt.Println("Module:", change.String())
 		}
-		return &key.Value
-	} else if name == "translateFunc" {
-		if len(args) < 1 {
-			return nil
+		for _, translationKey := range expiring {
+			logger.Warn("Experimental string", "id", translationKey)
 		}
-
-		key, ok := args[0].(*ast.BasicLit)
-		if !ok {
-			return nil
+		for _, translationKey := range expired {
+			fmt.Println("Expired:", translationKey)
 		}
-		return &key.Value
-	} else if name == "TranslateAsHtml" {
-		if len(args) < 2 {
-			return nil
+		for _, violation := range naming {
+			fmt.Println("Naming:", violation.String())
 		}
-
-		key, ok := args[1].(*ast.BasicLit)
-		if !ok {
-			return nil
+		for _, change := range frozen {
+			fmt.Println("Frozen:", change.String())
 		}
-		return &key.Value
-	} else if name == "userLocale" {
-		if len(args) < 1 {
-			return nil
+		for _, conflict := range conflicts {
+			fmt.Println("Conflict:", conflict.String())
 		}
-
-		key, ok := args[0].(*ast.BasicLit)
-		if !ok {
-			return nil
+		for _, mismatch := range mismatches {
+			fmt.Println("Placeholder:", mismatch.String())
 		}
-		return &key.Value
-	} else if name == "localT" {
-		if len(args) < 1 {
-			return nil
+		if failOn.Fails("untranslated") {
+			for _, translationKey := range untranslated {
+				fmt.Println("Untranslated:", translationKey)
+			}
 		}
+	}
 
-		key, ok := args[0].(*ast.BasicLit)
-		if !ok {
-			return nil
+	counts := map[string]int{
+		"added":                len(added),
+		"removed":              len(removed),
+		"untranslated":         len(untranslated),
+		"placeholder-mismatch": len(mismatches),
+		"module":               len(modules),
+		"expired":              len(expired),
+		"naming":               len(naming),
+		"frozen":               len(frozen),
+		"conflict":             len(conflicts),
+	}
+	if metaErr != nil {
+		counts["catalog"] = 1
+	}
+	// An incompatible catalog is rewritten by --fix like an out of date one.
+	outOfDate := len(added) > 0 || len(removed) > 0 || len(modules) > 0 || metaErr != nil
+	if outOfDate && fix {
+		if len(problems) > 0 {
+			keepTranslations(i18nStrings, translations)
+		}
+		enJSON := path.Join(opts.XeniaDir, "i18n", "en.json")
+		if err := writeEnglishTranslations(opts.XeniaDir, mergeTranslations(translations, i18nStrings, refs, placeholder), enterpriseCatalogSplit(opts.XeniaDir)); err != nil {
+			return &ExitError{Code: checkExitInternal, Err: err}
+		}
+		logger.Info("Fixed the translations file", "path", enJSON, "added", len(added), "removed", len(removed), "modules", len(modules))
+		for _, name := range []string{"added", "removed", "module", "catalog"} {
+			counts[name] = 0
 		}
-		return &key.Value
 	}
-	return nil
+	if allowed := failOn.Allowed(counts); len(allowed) > 0 {
+		logger.Debug("Differences allowed by the --fail-on policy", "classes", strings.Join(allowed, ", "))
+	}
+	return failOn.Result(counts)
 }
 
-func extractForCostants(name string, value_node ast.Expr) *string {
-	validConstants := map[string]bool{
-		"MISSING_CHANNEL_ERROR":        true,
-		"MISSING_CHANNEL_MEMBER_ERROR": true,
-		"CHANNEL_EXISTS_ERROR":         true,
-		"MISSING_STATUS_ERROR":         true,
-		"TEAM_MEMBER_EXISTS_ERROR":     true,
-		"MISSING_AUTH_ACCOUNT_ERROR":   true,
-		"MISSING_ACCOUNT_ERROR":        true,
-		"EXPIRED_LICENSE_ERROR":        true,
-		"INVALID_LICENSE_ERROR":        true,
+// getNamingPolicy returns the naming policy selected with --naming-policy,
+// nil when there is none.
+func getNamingPolicy(command *cobra.Command, xeniaDir string) (*namingPolicy, error) {
+	spec, err := command.Flags().GetString("naming-policy")
+	if err != nil {
+		return nil, errors.New("Invalid naming-policy parameter")
 	}
-
-	if _, ok := validConstants[name]; !ok {
-		return nil
+	if spec == "" {
+		return nil, nil
 	}
-	value, ok := value_node.(*ast.BasicLit)
-
-	if !ok {
-		return nil
+	config, err := loadToolConfig(xeniaDir)
+	if err != nil {
+		return nil, err
 	}
-	return &value.Value
-
+	return parseNamingPolicy(spec, config.I18n.NamingDirs)
 }
 
-func extractFromPath(path string, info os.FileInfo, err error, i18nStrings *map[string]bool) error {
-	if strings.HasSuffix(path, "model/client4.go") {
-		return nil
-	}
-	if strings.HasSuffix(path, "_test.go") {
-		return nil
+// checkExpiringKeys returns the experimental keys close to their expiry and
+// the expired ones. The current release is only needed when some key has an
+// expiry.
+func checkExpiringKeys(xeniaDir string, translations []Translation, releaseFlag string, window int) ([]string, []string, error) {
+	hasExpiry := false
+	for _, t := range translations {
+		hasExpiry = hasExpiry || t.Expires != ""
 	}
-	if !strings.HasSuffix(path, ".go") {
-		return nil
+	if !hasExpiry {
+		return []string{}, []string{}, nil
 	}
 
-	src, err := ioutil.ReadFile(path)
+	var current release
+	var err error
+	if releaseFlag != "" {
+		current, err = parseRelease(releaseFlag)
+	} else {
+		current, err = currentRelease(xeniaDir)
+	}
 	if err != nil {
-		panic(err)
+		return nil, nil, err
+	}
+	return findExpiringKeys(translations, current, window)
+}
+
+func emptyTranslations(translations []Translation) []string {
+	empty := []string{}
+	for _, t := range translations {
+		if isEmptyTranslation(t.Translation) {
+			empty = append(empty, t.Id)
+		}
 	}
+	sort.Strings(empty)
+	return empty
+}
 
-	fset := token.NewFileSet()
-	f, err := parser.ParseFile(fset, "", src, 0)
+// defaultPlaceholder is the translation of the new ids, a copy of the id.
+const defaultPlaceholder = "{id}"
+
+func addPlaceholderFlag(command *cobra.Command) {
+	command.Flags().String("placeholder", "", "Translation of the new ids, {id} being replaced by the id (defaults to the i18n.placeholder setting or "+defaultPlaceholder+")")
+}
+
+// getPlaceholder returns the placeholder selected with --placeholder, or
+// configured.
+func getPlaceholder(command *cobra.Command, xeniaDir string) (string, error) {
+	placeholder, err := command.Flags().GetString("placeholder")
 	if err != nil {
-		panic(err)
+		return "", errors.New("Invalid placeholder parameter")
+	}
+	if placeholder != "" {
+		return placeholder, nil
 	}
+	config, err := loadToolConfig(xeniaDir)
+	if err != nil {
+		return "", err
+	}
+	if config.I18n.Placeholder != "" {
+		return config.I18n.Placeholder, nil
+	}
+	return defaultPlaceholder, nil
+}
 
-	ast.Inspect(f, func(n ast.Node) bool {
-		var id *string = nil
+func placeholderText(placeholder, id string) string {
+	return strings.Replace(placeholder, "{id}", id, -1)
+}
 
-		switch expr := n.(type) {
-		case *ast.CallExpr:
-			switch fun := expr.Fun.(type) {
-			case *ast.SelectorExpr:
-				id = extractByFuncName(fun.Sel.Name, expr.Args)
-				if id == nil {
-					return true
-				}
-				break
-			case *ast.Ident:
-				id = extractByFuncName(fun.Name, expr.Args)
-				break
-			default:
-				return true
+// untranslatedTranslations returns the sorted ids whose translation, or
+// every plural form, still is the placeholder.
+func untranslatedTranslations(translations []Translation, placeholder string) []string {
+	untranslated := []string{}
+	for _, t := range translations {
+		text := placeholderText(placeholder, t.Id)
+		held := t.Translation == text
+		if forms, ok := parsePluralForms(t.Translation); ok && len(forms) > 0 {
+			held = true
+			for _, form := range forms {
+				held = held && form == text
 			}
-			break
-		case *ast.GenDecl:
-			if expr.Tok == token.CONST {
-				for _, spec := range expr.Specs {
-					value_spec, ok := spec.(*ast.ValueSpec)
-					if !ok {
-						continue
-					}
-					if len(value_spec.Names) == 0 {
-						continue
-					}
-					if len(value_spec.Values) == 0 {
-						continue
-					}
-					id = extractForCostants(value_spec.Names[0].Name, value_spec.Values[0])
-					if id == nil {
-						continue
-					}
-					(*i18nStrings)[strings.Trim(*id, "\"")] = true
-				}
+		}
+		if held {
+			untranslated = append(untranslated, t.Id)
+		}
+	}
+	sort.Strings(untranslated)
+	return untranslated
+}
+
+// isEmptyTranslation reports whether a translation, or every form of a plural
+// translation, is empty.
+func isEmptyTranslation(value interface{}) bool {
+	if forms, ok := parsePluralForms(value); ok {
+		for _, text := range forms {
+			if text != "" {
+				return false
 			}
-			return true
-		default:
-			return true
 		}
+		return true
+	}
+	return value == nil || value == ""
+}
+
+// diffTranslations returns the sorted keys found in the source code but not in
+// the translations and the ones present in the translations but not used.
+func diffTranslations(i18nStrings map[string]bool, translations []Translation) ([]string, []string) {
+	defer i18nProfile.phase("diff and sort")()
+	i18nStringsList := []string{}
+	for id := range i18nStrings {
+		i18nStringsList = append(i18nStringsList, id)
+	}
+	sort.Strings(i18nStringsList)
 
-		if id != nil {
-			(*i18nStrings)[strings.Trim(*id, "\"")] = true
+	translationsList := []string{}
+	idx := map[string]bool{}
+	for _, t := range translations {
+		idx[t.Id] = true
+		translationsList = append(translationsList, t.Id)
+	}
+	sort.Strings(translationsList)
+
+	added := []string{}
+	for _, translationKey := range i18nStringsList {
+		if _, hasKey := idx[translationKey]; !hasKey {
+			added = append(added, translationKey)
 		}
+	}
 
-		return true
-	})
-	return nil
+	removed := []string{}
+	for _, translationKey := range translationsList {
+		if _, hasKey := i18nStrings[translationKey]; !hasKey {
+			removed = append(removed, translationKey)
+		}
+	}
+	return added, removed
+}
+
+// dynamicString is a translation id built at runtime, extraction can't find
+// it in the source code.
+type dynamicString struct {
+	Id string
+	// External is set when the id comes from outside the walked sources,
+	// like the month names of the time package.
+	External bool
+}
+
+// dynamicStrings is the manifest of the translation ids built at runtime.
+var dynamicStrings = []dynamicString{
+	{Id: "model.user.is_valid.pwd_lowercase.app_error"},
+	{Id: "model.user.is_valid.pwd_lowercase_number.app_error"},
+	{Id: "model.user.is_valid.pwd_lowercase_number_symbol.app_error"},
+	{Id: "model.user.is_valid.pwd_lowercase_symbol.app_error"},
+	{Id: "model.user.is_valid.pwd_lowercase_uppercase.app_error"},
+	{Id: "model.user.is_valid.pwd_lowercase_uppercase_number.app_error"},
+	{Id: "model.user.is_valid.pwd_lowercase_uppercase_number_symbol.app_error"},
+	{Id: "model.user.is_valid.pwd_lowercase_uppercase_symbol.app_error"},
+	{Id: "model.user.is_valid.pwd_number.app_error"},
+	{Id: "model.user.is_valid.pwd_number_symbol.app_error"},
+	{Id: "model.user.is_valid.pwd_symbol.app_error"},
+	{Id: "model.user.is_valid.pwd_uppercase.app_error"},
+	{Id: "model.user.is_valid.pwd_uppercase_number.app_error"},
+	{Id: "model.user.is_valid.pwd_uppercase_number_symbol.app_error"},
+	{Id: "model.user.is_valid.pwd_uppercase_symbol.app_error"},
+	{Id: "January", External: true},
+	{Id: "February", External: true},
+	{Id: "March", External: true},
+	{Id: "April", External: true},
+	{Id: "May", External: true},
+	{Id: "June", External: true},
+	{Id: "July", External: true},
+	{Id: "August", External: true},
+	{Id: "September", External: true},
+	{Id: "October", External: true},
+	{Id: "November", External: true},
+	{Id: "December", External: true},
+}
+
+func addDynamicallyGeneratedStrings(i18nStrings *map[string]bool) {
+	for _, dynamic := range dynamicStrings {
+		(*i18nStrings)[dynamic.Id] = true
+	}
+}
+
+func isExtractableFile(path string, includeTests bool) bool {
+	if !includeTests && strings.HasSuffix(path, "_test.go") {
+		return false
+	}
+	return strings.HasSuffix(path, ".go") && sourceExtractor.IsSourceFile(path)
+}
+
+func extractFromPath(path string, cache *extractCache) ([]keyRef, error) {
+	if err := sandbox.checkPath(path); err != nil {
+		return nil, err
+	}
+	src, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if cache == nil {
+		i18nProfile.countFile(false)
+		done := i18nProfile.phase("parse (all jobs)")
+		keys, err := sourceExtractor.Source(path, src)
+		done()
+		logger.Debug("Parsed file", "path", path, "keys", len(keys))
+		return keys, err
+	}
+	hash := contentHash(src)
+	if keys, ok := cache.get(hash); ok {
+		i18nProfile.countFile(true)
+		logger.Debug("Read file from cache", "path", path, "keys", len(keys))
+		return keys, nil
+	}
+	i18nProfile.countFile(false)
+	done := i18nProfile.phase("parse (all jobs)")
+	keys, err := sourceExtractor.Source(path, src)
+	done()
+	if err != nil {
+		return nil, err
+	}
+	cache.put(hash, keys)
+	logger.Debug("Parsed file", "path", path, "keys", len(keys))
+	return keys, nil
 }
@@ -0,0 +1,270 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/cobra"
+)
+
+var EnforceAsciiKeysCmd = &cobra.Command{
+	Use:   "enforce-ascii-keys",
+	Short: "Validate the charset of the translation ids",
+	Long: `Validate that the translation ids of i18n/en.json and of the Go call sites only contain lowercase ASCII letters, digits, dots, underscores and dashes. Unicode letters, invisible characters and whitespace pasted by accident in an id break the tooling reading the translation files.
+
+Every invalid id comes with a normalized suggestion: accents are removed, whitespace becomes an underscore and invisible characters are dropped. With --fix the ids are renamed to their suggestion in every translation file and at every call site.`,
+	Example: `  i18n enforce-ascii-keys
+  i18n enforce-ascii-keys --fix --dry-run`,
+	RunE: enforceAsciiKeysCmdF,
+}
+
+func init() {
+	addExtractFlags(EnforceAsciiKeysCmd)
+	EnforceAsciiKeysCmd.Flags().Bool("fix", false, "Rename the invalid ids to their suggestion")
+	EnforceAsciiKeysCmd.Flags().Bool("dry-run", false, "With --fix, print a unified diff of the changes instead of writing the files")
+	I18nCmd.AddCommand(EnforceAsciiKeysCmd)
+}
+
+var asciiIdPattern = regexp.MustCompile(`^[a-z0-9._-]+$`)
+
+// accentFolds maps the accented lowercase letters to their ASCII letter.
+var accentFolds = map[rune]rune{}
+
+func init() {
+	for ascii, accented := range map[rune]string{
+		'a': "àáâãäåāăą",
+		'c': "çćĉċč",
+		'd': "ďđ",
+		'e': "èéêëēĕėęě",
+		'g': "ĝğġģ",
+		'h': "ĥħ",
+		'i': "ìíîïĩīĭįı",
+		'j': "ĵ",
+		'k': "ķ",
+		'l': "ĺļľŀł",
+		'n': "ñńņňŉ",
+		'o': "òóôõöøōŏő",
+		'r': "ŕŗř",
+		's': "śŝşš",
+		't': "ţťŧ",
+		'u': "ùúûüũūŭůűų",
+		'w': "ŵ",
+		'y': "ýÿŷ",
+		'z': "źżž",
+	} {
+		for _, r := range accented {
+			accentFolds[r] = ascii
+		}
+	}
+}
+
+// normalizeTranslationId suggests an id with the allowed charset, empty when
+// nothing of the id is left.
+func normalizeTranslationId(id string) string {
+	invisible := func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.Is(unicode.Cf, r)
+	}
+	var buf strings.Builder
+	replaced := false
+	for _, r := range strings.TrimFunc(id, invisible) {
+		// Fullwidth forms typed with an input method.
+		if r >= 0xFF01 && r <= 0xFF5E {
+			r -= 0xFEE0
+		}
+		r = unicode.ToLower(r)
+		if folded, ok := accentFolds[r]; ok {
+			r = folded
+		}
+		switch {
+		case unicode.Is(unicode.Cf, r):
+			continue
+		case r <= unicode.MaxASCII && (unicode.IsLower(r) || unicode.IsDigit(r) || strings.ContainsRune("._-", r)):
+			buf.WriteRune(r)
+			replaced = false
+		case !replaced:
+			buf.WriteRune('_')
+			replaced = true
+		}
+	}
+	return strings.Trim(buf.String(), "_")
+}
+
+// invalidId is an id outside of the allowed charset.
+type invalidId struct {
+	Id         string
+	Suggestion string
+	// Problem tells why the id can't be renamed to the suggestion.
+	Problem string
+}
+
+func (i invalidId) String() string {
+	if i.Problem != "" {
+		return fmt.Sprintf("%q: %s", i.Id, i.Problem)
+	}
+	return fmt.Sprintf("%q, suggested %s", i.Id, i.Suggestion)
+}
+
+// findInvalidIds checks the ids and suggests their new id, unless it is
+// empty, already used or suggested for another id.
+func findInvalidIds(ids map[string]bool) []invalidId {
+	invalid := []invalidId{}
+	suggested := map[string]int{}
+	for id := range ids {
+		if asciiIdPattern.MatchString(id) {
+			continue
+		}
+		suggestion := normalizeTranslationId(id)
+		invalid = append(invalid, invalidId{Id: id, Suggestion: suggestion})
+		suggested[suggestion]++
+	}
+	for i := range invalid {
+		switch suggestion := invalid[i].Suggestion; {
+		case suggestion == "":
+			invalid[i].Problem = "no valid character to suggest an id"
+		case ids[suggestion]:
+			invalid[i].Problem = fmt.Sprintf("the suggested id %s already exists", suggestion)
+		case suggested[suggestion] > 1:
+			invalid[i].Problem = fmt.Sprintf("the suggested id %s is suggested for several ids", suggestion)
+		}
+	}
+	sort.Slice(invalid, func(i, j int) bool { return invalid[i].Id < invalid[j].Id })
+	return invalid
+}
+
+// sourceIds returns the ids used by the Go call sites of the source folders.
+func sourceIds(opts *extractOptions) (map[string]bool, error) {
+	ids := map[string]bool{}
+	var walkErr error
+	walkSourceFiles(opts, func(p string) {
+		if walkErr != nil {
+			return
+		}
+		src, err := readFile(p)
+		if err != nil {
+			walkErr = err
+			return
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), p, src, 0)
+		if err != nil {
+			walkErr = err
+			return
+		}
+		for _, literal := range idLiterals(f) {
+			if value, err := strconv.Unquote(literal.Value); err == nil {
+				ids[value] = true
+			}
+		}
+	})
+	return ids, walkErr
+}
+
+func enforceAsciiKeysCmdF(command *cobra.Command, args []string) error {
+	opts, err := getExtractOptions(command)
+	if err != nil {
+		return err
+	}
+	fix, err := command.Flags().GetBool("fix")
+	if err != nil {
+		return errors.New("Invalid fix parameter")
+	}
+	dryRun, err := command.Flags().GetBool("dry-run")
+	if err != nil {
+		return errors.New("Invalid dry-run parameter")
+	}
+	if dryRun && !fix {
+		return errors.New("The dry-run flag requires --fix")
+	}
+	command.SilenceUsage = true
+
+	enJSON := filepath.Join(opts.XeniaDir, "i18n", "en.json")
+	translations, err := readTranslationsFile(enJSON)
+	if err != nil {
+		return err
+	}
+	ids, err := sourceIds(opts)
+	if err != nil {
+		return err
+	}
+	for _, t := range translations {
+		ids[t.Id] = true
+	}
+
+	invalid := findInvalidIds(ids)
+	if len(invalid) == 0 {
+		return nil
+	}
+	renames := map[string]string{}
+	unfixable := 0
+	for _, id := range invalid {
+		fmt.Println("Invalid id:", id.String())
+		if id.Problem != "" {
+			unfixable++
+		} else {
+			renames[id.Id] = id.Suggestion
+		}
+	}
+	if !fix {
+		return fmt.Errorf("%d translation ids have characters outside of [a-z0-9._-], rename them or run with --fix.", len(invalid))
+	}
+
+	files, err := localeFiles(opts.XeniaDir)
+	if err != nil {
+		return err
+	}
+	changes := []renamedFile{}
+	for _, file := range append([]string{enJSON}, files...) {
+		change, err := renameInTranslationsFile(file, renames)
+		if err != nil {
+			return err
+		}
+		if change != nil {
+			changes = append(changes, *change)
+		}
+	}
+	var walkErr error
+	walkSourceFiles(opts, func(p string) {
+		if walkErr != nil {
+			return
+		}
+		change, err := renameInSourceFile(p, renames)
+		if err != nil {
+			walkErr = err
+			return
+		}
+		if change != nil {
+			changes = append(changes, *change)
+		}
+	})
+	if walkErr != nil {
+		return walkErr
+	}
+
+	for _, change := range changes {
+		name := sourceRelativePath(opts, change.Path)
+		if dryRun {
+			fmt.Print(unifiedDiff("a/"+name, "b/"+name, string(change.Current), string(change.Updated), 3))
+			continue
+		}
+		if err := writeFile(change.Path, change.Updated, 0644); err != nil {
+			return err
+		}
+	}
+	if !dryRun {
+		fmt.Printf("Renamed %d ids in %d files.\n", len(renames), len(changes))
+	}
+	if unfixable > 0 {
+		return fmt.Errorf("%d translation ids can't be renamed automatically, rename them with i18n rename.", unfixable)
+	}
+	return nil
+}
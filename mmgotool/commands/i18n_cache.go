@@ -0,0 +1,126 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	localCacheDir    = ".mmgotool-cache"
+	extractCacheFile = "extract.json"
+
+	// extractCacheVersion must be increased every time the extraction logic
+	// changes, so entries produced by older versions are discarded.
+	extractCacheVersion = 9
+)
+
+// extractCache remembers the keys found in every parsed file, indexed by the
+// hash of the file content, so unchanged files don't need to be parsed again.
+type extractCache struct {
+	path    string
+	mutex   sync.Mutex
+	entries map[string][]keyRef
+	used    map[string][]keyRef
+}
+
+type extractCacheData struct {
+	Version int                 `json:"version"`
+	Files   map[string][]keyRef `json:"files"`
+}
+
+// extractCacheDir returns the folder of the caches of a Xenia checkout. It
+// lives in the user cache folder, one folder per checkout named after the
+// hash of its absolute path, so the working tree stays clean. Without a user
+// cache folder, the .mmgotool-cache folder of the checkout is used.
+func extractCacheDir(xeniaDir string) string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(xeniaDir, localCacheDir)
+	}
+	absDir, err := filepath.Abs(xeniaDir)
+	if err != nil {
+		return filepath.Join(xeniaDir, localCacheDir)
+	}
+	return filepath.Join(cacheDir, "mmgotool", contentHash([]byte(absDir))[:16])
+}
+
+func loadExtractCache(xeniaDir string) *extractCache {
+	cache := &extractCache{
+		path:    filepath.Join(extractCacheDir(xeniaDir), extractCacheFile),
+		entries: map[string][]keyRef{},
+		used:    map[string][]keyRef{},
+	}
+
+	data, err := readFile(cache.path)
+	if err != nil {
+		return cache
+	}
+	var stored extractCacheData
+	if err := json.Unmarshal(data, &stored); err != nil || stored.Version != extractCacheVersion {
+		return cache
+	}
+	if stored.Files != nil {
+		cache.entries = stored.Files
+	}
+	return cache
+}
+
+func (c *extractCache) get(hash string) ([]keyRef, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	keys, ok := c.entries[hash]
+	if ok {
+		c.used[hash] = keys
+	}
+	return keys, ok
+}
+
+// merge adds entries not already known, used to warm the cache from
+// another source.
+func (c *extractCache) merge(entries map[string][]keyRef) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for hash, keys := range entries {
+		if _, ok := c.entries[hash]; !ok {
+			c.entries[hash] = keys
+		}
+	}
+}
+
+func (c *extractCache) put(hash string, keys []keyRef) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries[hash] = keys
+	c.used[hash] = keys
+}
+
+// save writes the entries used during this run, dropping the ones belonging
+// to files that changed or disappeared.
+func (c *extractCache) save() error {
+	data, err := c.encode()
+	if err != nil {
+		return err
+	}
+	if err := mkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	return writeFile(c.path, data, 0644)
+}
+
+func (c *extractCache) encode() ([]byte, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return json.Marshal(extractCacheData{Version: extractCacheVersion, Files: c.used})
+}
+
+func contentHash(src []byte) string {
+	sum := sha256.Sum256(src)
+	return hex.EncodeToString(sum[:])
+}
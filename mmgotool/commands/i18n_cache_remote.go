@@ -0,0 +1,183 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	cacheURLEnv   = "MMGOTOOL_CACHE_URL"
+	cacheTokenEnv = "MMGOTOOL_CACHE_TOKEN"
+
+	// remoteCacheDepth is the number of ancestor commits looked up when the
+	// current commit has no shared cache yet.
+	remoteCacheDepth = 20
+)
+
+// remoteCache shares the extraction cache between machines through an HTTP
+// server storing the cache of every commit at <url>/<commit>/extract.json.
+// Any server accepting GET and PUT works: a plain HTTP cache, or S3 and GCS
+// buckets through their XML API or a signing proxy. The downloaded files are
+// kept with their ETag so unchanged caches are not downloaded again.
+type remoteCache struct {
+	baseURL  string
+	token    string
+	xeniaDir string
+	client   *http.Client
+	// fetched is the cache downloaded for the current commit, used to skip
+	// uploading an unchanged cache.
+	fetched []byte
+}
+
+func newRemoteCache(baseURL, xeniaDir string) *remoteCache {
+	return &remoteCache{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		token:    os.Getenv(cacheTokenEnv),
+		xeniaDir: xeniaDir,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (r *remoteCache) url(commit string) string {
+	return fmt.Sprintf("%s/%s/v%d/%s", r.baseURL, commit, extractCacheVersion, extractCacheFile)
+}
+
+func (r *remoteCache) localPath(commit string) string {
+	return filepath.Join(extractCacheDir(r.xeniaDir), "remote", commit+".json")
+}
+
+func (r *remoteCache) newRequest(method, url string, body []byte) (*http.Request, error) {
+	request, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if r.token != "" {
+		request.Header.Set("Authorization", "Bearer "+r.token)
+	}
+	return request, nil
+}
+
+// commits returns the current commit followed by its ancestors.
+func (r *remoteCache) commits() ([]string, error) {
+	cmd := execCommand("git", "rev-list", fmt.Sprintf("--max-count=%d", remoteCacheDepth), "HEAD")
+	cmd.Dir = r.xeniaDir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the commits: %s", err.Error())
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// fetch downloads the cache of the commit, reusing the local copy when the
+// server reports it unchanged. A missing cache returns nil data.
+func (r *remoteCache) fetch(commit string) ([]byte, error) {
+	request, err := r.newRequest(http.MethodGet, r.url(commit), nil)
+	if err != nil {
+		return nil, err
+	}
+	localPath := r.localPath(commit)
+	local, localErr := readFile(localPath)
+	etag, etagErr := readFile(localPath + ".etag")
+	if localErr == nil && etagErr == nil {
+		request.Header.Set("If-None-Match", string(etag))
+	}
+
+	response, err := r.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusNotModified:
+		return local, nil
+	case http.StatusNotFound, http.StatusForbidden:
+		return nil, nil
+	case http.StatusOK:
+	default:
+		return nil, fmt.Errorf("unexpected status %s for %s", response.Status, r.url(commit))
+	}
+
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := mkdirAll(filepath.Dir(localPath), 0755); err == nil {
+		writeFile(localPath, data, 0644)
+		if etag := response.Header.Get("ETag"); etag != "" {
+			writeFile(localPath+".etag", []byte(etag), 0644)
+		}
+	}
+	return data, nil
+}
+
+// fill warms the cache with the shared cache of the nearest commit having
+// one.
+func (r *remoteCache) fill(cache *extractCache) error {
+	commits, err := r.commits()
+	if err != nil {
+		return err
+	}
+	for i, commit := range commits {
+		data, err := r.fetch(commit)
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			continue
+		}
+		var stored extractCacheData
+		if err := json.Unmarshal(data, &stored); err != nil || stored.Version != extractCacheVersion {
+			continue
+		}
+		cache.merge(stored.Files)
+		if i == 0 {
+			r.fetched = data
+		}
+		return nil
+	}
+	return nil
+}
+
+// upload stores the entries used by this run as the cache of the current
+// commit.
+func (r *remoteCache) upload(cache *extractCache) error {
+	commits, err := r.commits()
+	if err != nil {
+		return err
+	}
+	if len(commits) == 0 {
+		return nil
+	}
+	data, err := cache.encode()
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(data, r.fetched) {
+		return nil
+	}
+	request, err := r.newRequest(http.MethodPut, r.url(commits[0]), data)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := r.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s for %s", response.Status, r.url(commits[0]))
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeTestExtractCache(t *testing.T, xeniaDir string, version int, files map[string][]keyRef) {
+	t.Helper()
+	data, err := json.Marshal(extractCacheData{Version: version, Files: files})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(extractCacheDir(xeniaDir), extractCacheFile), string(data))
+}
+
+func extractedTestIds(t *testing.T, file string, cache *extractCache) []string {
+	t.Helper()
+	refs, err := extractFromPath(file, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, ref := range refs {
+		ids = append(ids, ref.Id)
+	}
+	return ids
+}
+
+// setTestUserCacheDir points the user cache folder of every platform to a
+// temporary folder.
+func setTestUserCacheDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("LocalAppData", dir)
+}
+
+func TestExtractCacheInvalidation(t *testing.T) {
+	setTestUserCacheDir(t)
+	const src = "package app\n\nfunc f() { T(\"app.parsed\") }\n"
+	// The cached references differ from the parsed ones to tell them apart.
+	cached := map[string][]keyRef{contentHash([]byte(src)): {{Id: "app.cached"}}}
+
+	tests := []struct {
+		name    string
+		content string
+		cache   func(t *testing.T, xeniaDir string)
+		ids     []string
+	}{
+		{
+			name:    "unchanged file",
+			content: src,
+			cache:   func(t *testing.T, xeniaDir string) { writeTestExtractCache(t, xeniaDir, extractCacheVersion, cached) },
+			ids:     []string{"app.cached"},
+		},
+		{
+			name:    "changed file",
+			content: "package app\n\nfunc f() { T(\"app.changed\") }\n",
+			cache:   func(t *testing.T, xeniaDir string) { writeTestExtractCache(t, xeniaDir, extractCacheVersion, cached) },
+			ids:     []string{"app.changed"},
+		},
+		{
+			name:    "cache of an older extraction",
+			content: src,
+			cache:   func(t *testing.T, xeniaDir string) { writeTestExtractCache(t, xeniaDir, extractCacheVersion-1, cached) },
+			ids:     []string{"app.parsed"},
+		},
+		{
+			name:    "corrupted cache",
+			content: src,
+			cache: func(t *testing.T, xeniaDir string) {
+				writeTestFile(t, filepath.Join(extractCacheDir(xeniaDir), extractCacheFile), "{\"version\":")
+			},
+			ids: []string{"app.parsed"},
+		},
+		{
+			name:    "no cache",
+			content: src,
+			cache:   func(t *testing.T, xeniaDir string) {},
+			ids:     []string{"app.parsed"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			xeniaDir := t.TempDir()
+			file := filepath.Join(xeniaDir, "app", "app.go")
+			writeTestFile(t, file, test.content)
+			test.cache(t, xeniaDir)
+			if ids := extractedTestIds(t, file, loadExtractCache(xeniaDir)); !reflect.DeepEqual(ids, test.ids) {
+				t.Errorf("got ids %q, want %q", ids, test.ids)
+			}
+		})
+	}
+}
+
+func TestExtractCacheSaveDropsUnusedEntries(t *testing.T) {
+	setTestUserCacheDir(t)
+	xeniaDir := t.TempDir()
+	file := filepath.Join(xeniaDir, "app", "app.go")
+	writeTestFile(t, file, "package app\n\nfunc f() { T(\"app.kept\") }\n")
+	writeTestExtractCache(t, xeniaDir, extractCacheVersion, map[string][]keyRef{
+		contentHash([]byte("package app\n")): {{Id: "app.deleted_file"}},
+	})
+
+	cache := loadExtractCache(xeniaDir)
+	extractedTestIds(t, file, cache)
+	if err := cache.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := loadExtractCache(xeniaDir)
+	if len(saved.entries) != 1 {
+		t.Fatalf("got %d cache entries, want the one of app.go", len(saved.entries))
+	}
+	if ids := extractedTestIds(t, file, saved); !reflect.DeepEqual(ids, []string{"app.kept"}) {
+		t.Errorf("got ids %q from the saved cache, want app.kept", ids)
+	}
+}
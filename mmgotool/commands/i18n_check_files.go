@@ -0,0 +1,153 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xzl8028/xenia-utilities/mmgotool/pkg/i18nextract"
+)
+
+// touchesTranslations tells if a translation file is among the files, the
+// fast path of check can't validate it.
+func touchesTranslations(files []string) bool {
+	for _, file := range files {
+		if filepath.Ext(file) == ".json" && filepath.Base(filepath.Dir(file)) == "i18n" {
+			return true
+		}
+	}
+	return false
+}
+
+// isCheckedFile tells if the full check would extract the file.
+func isCheckedFile(opts *extractOptions, file string) bool {
+	dirs := opts.SourceDirs()
+	if i18nextract.IsWebappFile(file) {
+		if opts.WebappDir == "" || (i18nextract.IsWebappTestFile(file) && !opts.IncludeTests) {
+			return false
+		}
+		dirs = []string{opts.WebappDir}
+	} else if !isExtractableFile(file, opts.IncludeTests) {
+		return false
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return false
+	}
+	for _, dir := range dirs {
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(absDir, abs); err == nil && !strings.HasPrefix(rel, "..") {
+			return !isSkippedPath(filepath.ToSlash(rel))
+		}
+	}
+	return false
+}
+
+// isSkippedPath tells if the walk of the source folder skips the relative
+// path: the vendor folder of the root and the folders of SkippedDirs.
+func isSkippedPath(rel string) bool {
+	segments := strings.Split(rel, "/")
+	if segments[0] == "vendor" {
+		return true
+	}
+	for _, segment := range segments[:len(segments)-1] {
+		if i18nextract.SkippedDirs[segment] {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFiles is the fast path of check for the changed files, like the
+// staged ones of a pre-commit hook: the ids they use must be in the
+// translations file. Finding the removed ids needs the whole source code,
+// they are left to the full check. The added ids fail the check when in the
+// --fail-on policy.
+func checkFiles(opts *extractOptions, files []string, format string, summary *idSummary, failOn *checkPolicy) error {
+	translations, err := getCurrentTranslations(opts.XeniaDir)
+	if err != nil {
+		return &ExitError{Code: checkExitInternal, Err: err}
+	}
+	known := map[string]bool{}
+	for _, t := range translations {
+		known[t.Id] = true
+	}
+
+	added := []string{}
+	addedRefs := []keyRef{}
+	problems := []extractProblem{}
+	for _, file := range files {
+		if !isCheckedFile(opts, file) {
+			logger.Debug("Skipping file", "path", file)
+			continue
+		}
+		refs, err := extractFromPath(file, nil)
+		if err != nil {
+			problems = append(problems, extractProblem{Path: file, Err: err})
+			continue
+		}
+		for _, ref := range refs {
+			if !known[ref.Id] {
+				known[ref.Id] = true
+				added = append(added, ref.Id)
+				ref.Path = file
+				addedRefs = append(addedRefs, ref)
+			}
+		}
+	}
+	if err := reportExtractProblems(opts, problems); err != nil {
+		return &ExitError{Code: checkExitInternal, Err: err}
+	}
+	sort.Strings(added)
+	suggestions := suggestMissingKeys(opts, nil, translations, added)
+	for i := range suggestions {
+		for _, ref := range addedRefs {
+			if ref.Id == suggestions[i].Id {
+				suggestions[i].Location = findingPath(ref.Path) + ":" + strconv.Itoa(ref.Line)
+			}
+		}
+	}
+
+	if format == "json" {
+		report := checkReport{Added: added, Removed: []string{}, Empty: []string{}, Untranslated: []string{}, Expiring: []string{}, Expired: []string{}, Naming: []namingViolation{}, RemovedFrom: []removalAttribution{}, Modules: []moduleChange{}, Conflicts: []defaultConflict{}, Suggestions: suggestions, Placeholders: []*placeholderMismatch{}}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return &ExitError{Code: checkExitInternal, Err: err}
+		}
+	} else if format == findingsFormatSARIF {
+		reporter, _ := newFindingsReporter(format)
+		for _, ref := range addedRefs {
+			message := "Missing translation " + ref.Id + ", run mmgotool i18n extract"
+			for _, suggestion := range suggestions {
+				if suggestion.Id == ref.Id {
+					message += fmt.Sprintf(", or did you mean %s (%s)?", suggestion.Suggestion, suggestion.SuggestionLocation)
+				}
+			}
+			reporter.Report(finding{Rule: missingKeyRule, Message: message, File: findingPath(ref.Path), Line: ref.Line, Warning: !failOn.Fails("added")})
+		}
+		if err := reporter.Flush(); err != nil {
+			return &ExitError{Code: checkExitInternal, Err: err}
+		}
+	} else {
+		addedLines := []checkLine{}
+		for _, translationKey := range added {
+			addedLines = append(addedLines, checkLine{Id: translationKey, Text: translationKey})
+		}
+		summary.Print(os.Stdout, "Added", addedLines)
+		for _, suggestion := range suggestions {
+			fmt.Println("Suggestion:", suggestion.String())
+		}
+	}
+	return failOn.Result(map[string]int{"added": len(added)})
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+)
+
+var (
+	missingKeyRule   = registerFindingRule(findingRule{Id: "i18n/missing-key", Description: "The translation id used by the source code is not in i18n/en.json", Command: "i18n check", Fix: "i18n extract"})
+	unusedKeyRule    = registerFindingRule(findingRule{Id: "i18n/unused-key", Description: "The translation id of i18n/en.json is no longer used by the source code", Command: "i18n check", Fix: "i18n extract"})
+	moduleChangeRule = registerFindingRule(findingRule{Id: "i18n/module-change", Description: "The translation id moved to another module", Command: "i18n check", Fix: "i18n extract"})
+	expiredKeyRule   = registerFindingRule(findingRule{Id: "i18n/expired-key", Description: "The experimental translation id expired", Command: "i18n check"})
+	namingRule       = registerFindingRule(findingRule{Id: "i18n/naming-policy", Description: "The new translation id breaks the naming policy", Command: "i18n check"})
+	frozenStringRule = registerFindingRule(findingRule{Id: "i18n/string-freeze", Description: "The string changed after the string freeze without an approved exception", Command: "i18n check"})
+	untranslatedRule = registerFindingRule(findingRule{Id: "i18n/untranslated", Description: "The string of i18n/en.json still holds the placeholder", Command: "i18n check"})
+)
+
+// checkFindings is the result of check reported as findings.
+type checkFindings struct {
+	Added        []string
+	RemovedFrom  []removalAttribution
+	Modules      []moduleChange
+	Expired      []string
+	Naming       []namingViolation
+	Frozen       []frozenChange
+	Untranslated []string
+	Conflicts    []defaultConflict
+	Suggestions  []keySuggestion
+	Placeholders []*placeholderMismatch
+}
+
+// moduleDir returns the folder of a source module, see sourceModule.
+func (o *extractOptions) moduleDir(module string) string {
+	switch module {
+	case "":
+		return o.XeniaDir
+	case enterpriseModule:
+		return o.EnterpriseDir
+	case "webapp":
+		return o.WebappDir
+	}
+	for _, dir := range o.ExtraDirs {
+		if filepath.Base(filepath.Clean(dir)) == module {
+			return dir
+		}
+	}
+	return o.XeniaDir
+}
+
+// firstRefs returns the first reference of every id, where the findings
+// about a used id are located.
+func firstRefs(refs []keyRef) map[string]keyRef {
+	first := map[string]keyRef{}
+	for _, ref := range refs {
+		if _, ok := first[ref.Id]; !ok {
+			first[ref.Id] = ref
+		}
+	}
+	return first
+}
+
+// refLocation returns the file of a reference, relative to the current
+// directory.
+func refLocation(opts *extractOptions, ref keyRef) string {
+	return findingPath(filepath.Join(opts.moduleDir(ref.Module), ref.Path))
+}
+
+// catalogLocator finds the line of the ids in the English catalogs, the
+// enterprise one included when split.
+type catalogLocator struct {
+	enJSON string
+	files  map[string]string
+	lines  map[string]map[string]int
+}
+
+func newCatalogLocator(opts *extractOptions, translations []Translation) *catalogLocator {
+	locator := &catalogLocator{enJSON: path.Join(opts.XeniaDir, "i18n", "en.json"), files: map[string]string{}, lines: map[string]map[string]int{}}
+	if enterpriseCatalogSplit(opts.XeniaDir) {
+		enterpriseJSON := path.Join(opts.XeniaDir, "i18n", enterpriseCatalogFile)
+		for _, t := range translations {
+			if t.Module == enterpriseModule {
+				locator.files[t.Id] = enterpriseJSON
+			}
+		}
+	}
+	return locator
+}
+
+// Locate returns the catalog file of the id, relative to the current
+// directory, and its line, 0 when unknown.
+func (l *catalogLocator) Locate(id string) (string, int) {
+	file, ok := l.files[id]
+	if !ok {
+		file = l.enJSON
+	}
+	if _, ok := l.lines[file]; !ok {
+		l.lines[file] = catalogIdLines(file)
+	}
+	return findingPath(file), l.lines[file][id]
+}
+
+// reportCheckFindings reports the problems found by check, the used ids at
+// their first reference and the others at their line in the English
+// catalogs. The findings of the classes not failing the check are warnings.
+func reportCheckFindings(reporter *findingsReporter, opts *extractOptions, refs []keyRef, translations []Translation, result checkFindings, failOn *checkPolicy) {
+	first := firstRefs(refs)
+	atRef := func(rule findingRule, id, message string) finding {
+		ref, ok := first[id]
+		if !ok {
+			return finding{Rule: rule, Message: message}
+		}
+		return finding{Rule: rule, Message: message, File: refLocation(opts, ref), Line: ref.Line}
+	}
+	locator := newCatalogLocator(opts, translations)
+	atCatalog := func(rule findingRule, id, message string) finding {
+		file, line := locator.Locate(id)
+		return finding{Rule: rule, Message: message, File: file, Line: line}
+	}
+	report := func(class string, f finding) {
+		f.Warning = !failOn.Fails(class)
+		reporter.Report(f)
+	}
+
+	suggestions := map[string]keySuggestion{}
+	for _, suggestion := range result.Suggestions {
+		suggestions[suggestion.Id] = suggestion
+	}
+	for _, id := range result.Added {
+		message := "Missing translation " + id + ", run mmgotool i18n extract"
+		if suggestion, ok := suggestions[id]; ok {
+			message += fmt.Sprintf(", or did you mean %s (%s)?", suggestion.Suggestion, suggestion.SuggestionLocation)
+		}
+		report("added", atRef(missingKeyRule, id, message))
+	}
+	for _, attribution := range result.RemovedFrom {
+		report("removed", atCatalog(unusedKeyRule, attribution.Id, "Unused translation "+attribution.String()+", run mmgotool i18n extract"))
+	}
+	for _, change := range result.Modules {
+		report("module", atCatalog(moduleChangeRule, change.Id, "Module change "+change.String()))
+	}
+	for _, id := range result.Expired {
+		report("expired", atCatalog(expiredKeyRule, id, "Expired experimental string "+id))
+	}
+	for _, violation := range result.Naming {
+		report("naming", atRef(namingRule, violation.Id, "Naming policy: "+violation.String()))
+	}
+	for _, change := range result.Frozen {
+		report("frozen", atCatalog(frozenStringRule, change.Id, "Frozen string "+change.String()))
+	}
+	for _, conflict := range result.Conflicts {
+		report("conflict", defaultConflictFinding(opts, conflict))
+	}
+	for _, id := range result.Untranslated {
+		report("untranslated", atCatalog(untranslatedRule, id, "Untranslated string "+id))
+	}
+	lines := map[string]map[string]int{}
+	for _, mismatch := range result.Placeholders {
+		if _, ok := lines[mismatch.File]; !ok {
+			lines[mismatch.File] = catalogIdLines(mismatch.File)
+		}
+		report("placeholder-mismatch", mismatch.finding(mismatch.File, lines[mismatch.File]))
+	}
+}
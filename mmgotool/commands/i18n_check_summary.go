@@ -0,0 +1,156 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// checkLine is a line of the check output about one id.
+type checkLine struct {
+	Id   string
+	Text string
+}
+
+// idSummary prints long lists of ids as counts by namespace, the first
+// segments of the ids. The expanded namespaces are drilled down one segment
+// at a time until their lists are short enough.
+type idSummary struct {
+	Threshold int
+	expanded  map[string]bool
+}
+
+func newIdSummary(threshold int, expand []string) *idSummary {
+	s := &idSummary{Threshold: threshold, expanded: map[string]bool{}}
+	for _, prefix := range expand {
+		// Expanding a namespace expands its parents.
+		segments := strings.Split(strings.Trim(prefix, "."), ".")
+		for i := range segments {
+			s.expanded[strings.Join(segments[:i+1], ".")] = true
+		}
+	}
+	return s
+}
+
+// Print writes the lines, or their counts by namespace when there are more
+// than the threshold.
+func (s *idSummary) Print(w io.Writer, label string, lines []checkLine) {
+	if s.Threshold <= 0 || len(lines) <= s.Threshold {
+		for _, line := range lines {
+			fmt.Fprintf(w, "%s: %s\n", label, line.Text)
+		}
+		return
+	}
+	s.print(w, label, "", lines)
+}
+
+func (s *idSummary) print(w io.Writer, label, namespace string, lines []checkLine) {
+	groups := map[string][]checkLine{}
+	for _, line := range lines {
+		group := childNamespace(namespace, line.Id)
+		groups[group] = append(groups[group], line)
+	}
+	names := []string{}
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		group := groups[name]
+		switch {
+		case len(group) == 1 || (s.expanded[name] && len(group) <= s.Threshold):
+			for _, line := range group {
+				fmt.Fprintf(w, "%s: %s\n", label, line.Text)
+			}
+		case s.expanded[name]:
+			s.print(w, label, name, group)
+		default:
+			fmt.Fprintf(w, "%s: %s.* (%d ids, use --expand %s to list them)\n", label, name, len(group), name)
+		}
+	}
+}
+
+// writeCheckReportFile writes the full lists of the added and removed ids.
+func writeCheckReportFile(path string, added, removed []checkLine) error {
+	var buf bytes.Buffer
+	full := &idSummary{}
+	full.Print(&buf, "Added", added)
+	full.Print(&buf, "Removed", removed)
+	return writeFile(path, buf.Bytes(), 0644)
+}
+
+// childNamespace returns the namespace of the id one segment below the
+// namespace, or the id itself when it has no more segments.
+func childNamespace(namespace, id string) string {
+	rest := id
+	if namespace != "" {
+		rest = strings.TrimPrefix(id, namespace+".")
+	}
+	segment := rest
+	if i := strings.Index(rest, "."); i >= 0 {
+		segment = rest[:i]
+	}
+	if namespace == "" {
+		return segment
+	}
+	return namespace + "." + segment
+}
+
+// summarizeCheck adds the findings of the check and the completion of the
+// locales once i18n/en.json is updated to the run summary.
+func summarizeCheck(xeniaDir string, translations []Translation, i18nStrings map[string]bool, added []string, removed []removalAttribution, expired []string, naming []namingViolation, frozen []frozenChange) error {
+	rows := [][]string{}
+	for _, id := range added {
+		rows = append(rows, []string{"`" + id + "`"})
+	}
+	stepSummary.AddTable("Added ids", []string{"Id"}, rows)
+
+	rows = [][]string{}
+	for _, attribution := range removed {
+		rows = append(rows, []string{"`" + attribution.Id + "`", attribution.Details()})
+	}
+	stepSummary.AddTable("Removed ids", []string{"Id", "Details"}, rows)
+
+	rows = [][]string{}
+	for _, id := range expired {
+		rows = append(rows, []string{"`" + id + "`"})
+	}
+	stepSummary.AddTable("Expired strings", []string{"Id"}, rows)
+
+	rows = [][]string{}
+	for _, violation := range naming {
+		rows = append(rows, []string{violation.String()})
+	}
+	stepSummary.AddTable("Naming policy violations", []string{"Violation"}, rows)
+
+	rows = [][]string{}
+	for _, change := range frozen {
+		rows = append(rows, []string{"`" + change.Id + "`", change.Change, change.Pending})
+	}
+	stepSummary.AddTable("Changed after the string freeze", []string{"Id", "Change", "Pending exception by"}, rows)
+
+	if len(added) == 0 && len(removed) == 0 {
+		return nil
+	}
+	deltas, err := localeCompletionDeltas(xeniaDir, translations, i18nStrings)
+	if err != nil {
+		return err
+	}
+	rows = [][]string{}
+	for _, delta := range deltas {
+		rows = append(rows, []string{
+			delta.Locale,
+			fmt.Sprintf("%.1f%%", delta.Before*100),
+			fmt.Sprintf("%.1f%%", delta.After*100),
+			fmt.Sprintf("%+.1f", (delta.After-delta.Before)*100),
+		})
+	}
+	stepSummary.AddTable("Locale completion", []string{"Locale", "Before", "After", "Delta"}, rows)
+	return nil
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+var CompareFormatVersionsCmd = &cobra.Command{
+	Use:     "compare-format-versions <old-catalog> <new-catalog>",
+	Short:   "Verify a translation file format migration",
+	Long:    "Load two representations of the same translations (array, map or split directory) and verify that every key has the same value in both. The report written with --report-file can be signed with --sign-key and checked with i18n verify",
+	Example: "  i18n compare-format-versions i18n/en.json i18n/en/",
+	Args:    cobra.ExactArgs(2),
+	RunE:    compareFormatVersionsCmdF,
+}
+
+func init() {
+	CompareFormatVersionsCmd.Flags().String("report-file", "", "Write the verification report to this file")
+	CompareFormatVersionsCmd.Flags().String("sign-key", "", "Sign the report file with this private key, generated by i18n keygen")
+	I18nCmd.AddCommand(CompareFormatVersionsCmd)
+}
+
+func compareFormatVersionsCmdF(command *cobra.Command, args []string) error {
+	reportFile, err := command.Flags().GetString("report-file")
+	if err != nil {
+		return errors.New("Invalid report-file parameter")
+	}
+	signKey, err := command.Flags().GetString("sign-key")
+	if err != nil {
+		return errors.New("Invalid sign-key parameter")
+	}
+	if signKey != "" && reportFile == "" {
+		return errors.New("Signing the report requires a report file")
+	}
+
+	oldCatalog, err := loadCatalog(args[0])
+	if err != nil {
+		return err
+	}
+	newCatalog, err := loadCatalog(args[1])
+	if err != nil {
+		return err
+	}
+
+	missing, extra, changed := compareCatalogs(oldCatalog, newCatalog)
+
+	var report bytes.Buffer
+	for _, c := range []*Catalog{oldCatalog, newCatalog} {
+		digest, err := catalogDigest(c.Path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&report, "Catalog: %s (format: %s, keys: %d, sha256: %s)\n", c.Path, c.Format, len(c.Translations), digest)
+	}
+	for _, id := range missing {
+		fmt.Fprintln(&report, "Missing:", id)
+	}
+	for _, id := range extra {
+		fmt.Fprintln(&report, "Extra:", id)
+	}
+	for _, id := range changed {
+		fmt.Fprintln(&report, "Changed:", id)
+	}
+	differences := len(missing) + len(extra) + len(changed)
+	if differences == 0 {
+		fmt.Fprintf(&report, "Result: equivalent (%d keys verified)\n", len(oldCatalog.Translations))
+	} else {
+		fmt.Fprintf(&report, "Result: %d differences found\n", differences)
+	}
+
+	if reportFile != "" {
+		if err := writeFile(reportFile, report.Bytes(), 0644); err != nil {
+			return err
+		}
+		if signKey != "" {
+			if err := signArtifact(reportFile, signKey); err != nil {
+				return err
+			}
+		}
+	}
+	os.Stdout.Write(report.Bytes())
+
+	if differences > 0 {
+		command.SilenceUsage = true
+		return errors.New("Translation catalogs are not equivalent.")
+	}
+	return nil
+}
+
+// compareCatalogs returns the ids missing from the new catalog, the ids only
+// present in the new catalog and the ids whose values differ.
+func compareCatalogs(oldCatalog, newCatalog *Catalog) ([]string, []string, []string) {
+	missing := []string{}
+	changed := []string{}
+	for _, id := range oldCatalog.Ids() {
+		newValue, ok := newCatalog.Translations[id]
+		if !ok {
+			missing = append(missing, id)
+			continue
+		}
+		if !reflect.DeepEqual(oldCatalog.Translations[id], newValue) {
+			changed = append(changed, id)
+		}
+	}
+
+	extra := []string{}
+	for _, id := range newCatalog.Ids() {
+		if _, ok := oldCatalog.Translations[id]; !ok {
+			extra = append(extra, id)
+		}
+	}
+	return missing, extra, changed
+}
+
+// catalogDigest hashes the catalog file, or every json file of a split
+// catalog in name order.
+func catalogDigest(catalogPath string) (string, error) {
+	files := []string{catalogPath}
+	info, err := os.Stat(catalogPath)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(catalogPath, "*.json"))
+		if err != nil {
+			return "", err
+		}
+		sort.Strings(files)
+	}
+
+	hash := sha256.New()
+	for _, file := range files {
+		data, err := readFile(file)
+		if err != nil {
+			return "", err
+		}
+		hash.Write(data)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultCompatPrefixes are the ids of the bulk import and export messages.
+// Exported data keeps referencing them, they must resolve in every release.
+var defaultCompatPrefixes = []string{
+	"app.import.",
+	"app.export.",
+	"app.bulk_export.",
+	"model.import.",
+}
+
+var CompatCmd = &cobra.Command{
+	Use:   "compat",
+	Short: "Check the compatibility of the ids used by exported data",
+	Long: `Check that no id of the bulk import and export messages was removed or renamed since a release. The data exported by older servers references these error ids, they must keep resolving.
+
+The release is a git tag or any other revision of the Xenia repository, "v5.20" also matches the "v5.20.0" tag. A removed id whose English string now belongs to a new id is reported as renamed.`,
+	Example: `  i18n compat --since v5.20
+  i18n compat --since v5.20 --prefix app.import. --prefix app.export.`,
+	RunE: compatCmdF,
+}
+
+func init() {
+	CompatCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	CompatCmd.Flags().String("since", "", "Release or git revision to compare with")
+	CompatCmd.Flags().StringArray("prefix", defaultCompatPrefixes, "Prefix of the ids to keep compatible, can be repeated")
+	I18nCmd.AddCommand(CompatCmd)
+}
+
+// translationsAtRevision reads i18n/en.json at a revision of the Xenia
+// repository. A release like v5.20 also matches its first patch tag.
+func translationsAtRevision(xeniaDir, revision string) ([]Translation, string, error) {
+	candidates := []string{revision}
+	if strings.Count(revision, ".") == 1 {
+		candidates = append(candidates, revision+".0")
+	}
+	for _, candidate := range candidates {
+		output, err := execCommand("git", "-C", xeniaDir, "show", candidate+":i18n/en.json").Output()
+		if err != nil {
+			continue
+		}
+		translations, err := decodeTranslations(output)
+		if err != nil {
+			return nil, "", fmt.Errorf("Unable to parse i18n/en.json at %s: %s", candidate, err.Error())
+		}
+		return translations, candidate, nil
+	}
+	return nil, "", fmt.Errorf("Unable to read i18n/en.json at %s", revision)
+}
+
+type compatProblem struct {
+	Id string
+	// RenamedTo are the new ids with the English string of the removed one.
+	RenamedTo []string
+}
+
+func (p *compatProblem) String() string {
+	if len(p.RenamedTo) > 0 {
+		return fmt.Sprintf("Renamed: %s -> %s", p.Id, strings.Join(p.RenamedTo, ", "))
+	}
+	return "Removed: " + p.Id
+}
+
+func hasAnyPrefix(id string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(id, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func findCompatProblems(old, current []Translation, prefixes []string) []*compatProblem {
+	currentIds := map[string]bool{}
+	for _, t := range current {
+		currentIds[t.Id] = true
+	}
+	oldIds := map[string]bool{}
+	for _, t := range old {
+		oldIds[t.Id] = true
+	}
+	// The ids added since the release, by English string.
+	added := map[string][]string{}
+	for _, t := range current {
+		if text, ok := t.Translation.(string); ok && !oldIds[t.Id] {
+			added[text] = append(added[text], t.Id)
+		}
+	}
+
+	problems := []*compatProblem{}
+	for _, t := range old {
+		if !hasAnyPrefix(t.Id, prefixes) || currentIds[t.Id] {
+			continue
+		}
+		problem := &compatProblem{Id: t.Id}
+		if text, ok := t.Translation.(string); ok && text != "" {
+			problem.RenamedTo = added[text]
+			sort.Strings(problem.RenamedTo)
+		}
+		problems = append(problems, problem)
+	}
+	sort.Slice(problems, func(i, j int) bool { return problems[i].Id < problems[j].Id })
+	return problems
+}
+
+func compatCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	since, err := command.Flags().GetString("since")
+	if err != nil || since == "" {
+		return errors.New("Invalid since parameter")
+	}
+	prefixes, err := command.Flags().GetStringArray("prefix")
+	if err != nil || len(prefixes) == 0 {
+		return errors.New("Invalid prefix parameter")
+	}
+	command.SilenceUsage = true
+
+	old, revision, err := translationsAtRevision(xeniaDir, since)
+	if err != nil {
+		return err
+	}
+	current, err := getCurrentTranslations(xeniaDir)
+	if err != nil {
+		return err
+	}
+
+	problems := findCompatProblems(old, current, prefixes)
+	for _, problem := range problems {
+		fmt.Println(problem.String())
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d ids used by exported data were removed or renamed since %s.", len(problems), revision)
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+var ConsistencyCmd = &cobra.Command{
+	Use:   "consistency",
+	Short: "Cross locale consistency checks",
+}
+
+var ConsistencyCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check that the protected terms are left untranslated",
+	Long: `Check that the protected terms of the glossary, like product names and trademarked features, appear unaltered in every translation whose English string uses them.
+The glossary lists the terms and the locales exempted from each of them, in the i18n section of the .mmgotool.yaml file or in the YAML or JSON file given with --glossary:
+
+  i18n:
+    glossary:
+      - term: Xenia
+      - term: Playbooks
+        exempt_locales: [ja, zh-CN]`,
+	Example: "  i18n consistency check --locale de --locale fr",
+	RunE:    consistencyCheckCmdF,
+}
+
+func init() {
+	ConsistencyCheckCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	ConsistencyCheckCmd.Flags().String("glossary", "", "Path to a glossary file with a terms list, replacing the glossary of the configuration file")
+	ConsistencyCheckCmd.Flags().StringSlice("locale", []string{}, "Only check these locales (defaults to all)")
+	ConsistencyCmd.AddCommand(ConsistencyCheckCmd)
+	I18nCmd.AddCommand(ConsistencyCmd)
+}
+
+type glossaryFile struct {
+	Terms []glossaryTerm `yaml:"terms"`
+}
+
+type glossaryTerm struct {
+	Term          string   `yaml:"term"`
+	ExemptLocales []string `yaml:"exempt_locales"`
+
+	pattern *regexp.Regexp
+}
+
+func (t *glossaryTerm) exempted(locale string) bool {
+	for _, exempt := range t.ExemptLocales {
+		if exempt == locale {
+			return true
+		}
+	}
+	return false
+}
+
+type termProblem struct {
+	Id     string
+	Locale string
+	Term   string
+	Found  string
+}
+
+func (p *termProblem) String() string {
+	if p.Found != "" {
+		return fmt.Sprintf("%s: %s: %q altered to %q", p.Locale, p.Id, p.Term, p.Found)
+	}
+	return fmt.Sprintf("%s: %s: %q missing, it may have been translated", p.Locale, p.Id, p.Term)
+}
+
+func readGlossaryFile(filePath string) ([]glossaryTerm, error) {
+	data, err := readFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	file := &glossaryFile{}
+	if err := yaml.UnmarshalStrict(data, file); err != nil {
+		return nil, fmt.Errorf("Unable to parse %s: %s", filePath, err.Error())
+	}
+	return file.Terms, nil
+}
+
+// compileGlossary prepares the matching of the terms.
+func compileGlossary(terms []glossaryTerm) ([]glossaryTerm, error) {
+	for i := range terms {
+		term := &terms[i]
+		if strings.TrimSpace(term.Term) == "" {
+			return nil, errors.New("The glossary has an empty term")
+		}
+		term.pattern = regexp.MustCompile(`(?i)(^|[^\pL\pN])(` + regexp.QuoteMeta(term.Term) + `)($|[^\pL\pN])`)
+	}
+	return terms, nil
+}
+
+// uses returns the occurrences of the term in text, whatever their
+// case, as whole words.
+func (t *glossaryTerm) uses(text string) []string {
+	uses := []string{}
+	for _, match := range t.pattern.FindAllStringSubmatch(text, -1) {
+		uses = append(uses, match[2])
+	}
+	return uses
+}
+
+// checkTerm compares the use of the term in a translated text with the
+// English one. The term must appear exactly as in the glossary.
+func checkTerm(term *glossaryTerm, english, translated string) (bool, string) {
+	if !contains(term.uses(english), term.Term) {
+		return true, ""
+	}
+	uses := term.uses(translated)
+	if contains(uses, term.Term) {
+		return true, ""
+	}
+	if len(uses) > 0 {
+		return false, uses[0]
+	}
+	return false, ""
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// translationTexts pairs the forms of a translation with the English text
+// they translate. Plural forms missing in English use its "other" form.
+func translationTexts(source, translation interface{}) [][2]string {
+	if text, ok := translation.(string); ok {
+		if english, ok := source.(string); ok && text != "" {
+			return [][2]string{{english, text}}
+		}
+		return nil
+	}
+	forms, ok := parsePluralForms(translation)
+	if !ok {
+		return nil
+	}
+	sourceForms, ok := parsePluralForms(source)
+	if !ok {
+		return nil
+	}
+	pairs := [][2]string{}
+	for _, category := range pluralCategoriesOrder {
+		if forms[category] == "" {
+			continue
+		}
+		english, ok := sourceForms[category]
+		if !ok {
+			english = sourceForms["other"]
+		}
+		pairs = append(pairs, [2]string{english, forms[category]})
+	}
+	return pairs
+}
+
+func findTermProblems(terms []glossaryTerm, source map[string]interface{}, locale string, translations []Translation) []*termProblem {
+	problems := []*termProblem{}
+	for _, t := range translations {
+		sourceValue, ok := source[t.Id]
+		if !ok {
+			continue
+		}
+		for i := range terms {
+			term := &terms[i]
+			if term.exempted(locale) {
+				continue
+			}
+			for _, pair := range translationTexts(sourceValue, t.Translation) {
+				if ok, found := checkTerm(term, pair[0], pair[1]); !ok {
+					problems = append(problems, &termProblem{Id: t.Id, Locale: locale, Term: term.Term, Found: found})
+					break
+				}
+			}
+		}
+	}
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Id < problems[j].Id })
+	return problems
+}
+
+func consistencyCheckCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	glossaryPath, err := command.Flags().GetString("glossary")
+	if err != nil {
+		return errors.New("Invalid glossary parameter")
+	}
+	locales, err := command.Flags().GetStringSlice("locale")
+	if err != nil {
+		return errors.New("Invalid locale parameter")
+	}
+	wanted := map[string]bool{}
+	for _, locale := range locales {
+		wanted[locale] = true
+	}
+
+	var terms []glossaryTerm
+	if glossaryPath != "" {
+		terms, err = readGlossaryFile(glossaryPath)
+	} else {
+		var config *toolConfig
+		if config, err = loadToolConfig(xeniaDir); err == nil {
+			terms = config.I18n.Glossary
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(terms) == 0 {
+		return errors.New("The glossary is empty, configure i18n.glossary or use --glossary.")
+	}
+	if terms, err = compileGlossary(terms); err != nil {
+		return err
+	}
+	source, err := sourceTranslations(xeniaDir)
+	if err != nil {
+		return err
+	}
+	files, err := localeFiles(xeniaDir)
+	if err != nil {
+		return err
+	}
+
+	problems := 0
+	for _, file := range files {
+		locale := localeName(file)
+		if len(wanted) > 0 && !wanted[locale] {
+			continue
+		}
+		translations, err := readTranslationsFile(file)
+		if err != nil {
+			return err
+		}
+		for _, problem := range findTermProblems(terms, source, locale, translations) {
+			fmt.Println(problem.String())
+			problems++
+		}
+	}
+
+	if problems > 0 {
+		command.SilenceUsage = true
+		return fmt.Errorf("%d translations with altered protected terms.", problems)
+	}
+	return nil
+}
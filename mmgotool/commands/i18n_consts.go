@@ -0,0 +1,311 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/cobra"
+
+	"github.com/xzl8028/xenia-utilities/mmgotool/internal/codegen"
+)
+
+var GenConstsCmd = &cobra.Command{
+	Use:   "gen-consts",
+	Short: "Generate the Go constants of the translation ids",
+	Long: `Generate a Go file declaring a constant for every translation id of i18n/en.json, named after the id in camel case:
+
+  const ApiUserBannedAppError = "api.user.banned.app_error"
+
+The constants are untyped so they are passed to the translation functions taking a string. The ids mapping to the same name get a numeric suffix, in the order of the ids.
+
+Once the file exists, i18n check-consts reports the call sites still passing raw strings.`,
+	Example: `  i18n gen-consts
+  i18n gen-consts --output model/i18nk/keys.go --check`,
+	Args: cobra.NoArgs,
+	RunE: genConstsCmdF,
+}
+
+var CheckConstsCmd = &cobra.Command{
+	Use:   "check-consts",
+	Short: "Find the translation ids passed as raw strings",
+	Long: `Report the translation calls passing as id a string literal which has a constant in the file generated by i18n gen-consts, to replace with the constant.
+
+Nothing is reported while the constants file doesn't exist.`,
+	Example: `  i18n check-consts
+  i18n check-consts --consts-file model/i18nk/keys.go --format sarif`,
+	Args: cobra.NoArgs,
+	RunE: checkConstsCmdF,
+}
+
+func init() {
+	GenConstsCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	GenConstsCmd.Flags().String("output", "", "Generated file, defaults to i18nk/keys.go in the Xenia folder")
+	GenConstsCmd.Flags().String("package", "i18nk", "Package of the generated file")
+	GenConstsCmd.Flags().Bool("check", false, "Only check that the generated file is up to date")
+	I18nCmd.AddCommand(GenConstsCmd)
+
+	addExtractFlags(CheckConstsCmd)
+	CheckConstsCmd.Flags().String("consts-file", "", "File generated by gen-consts, defaults to i18nk/keys.go in the Xenia folder")
+	addFindingsFormatFlag(CheckConstsCmd)
+	I18nCmd.AddCommand(CheckConstsCmd)
+}
+
+var rawKeyRule = registerFindingRule(findingRule{Id: "i18n/raw-key", Description: "The translation id is passed as a raw string instead of its constant", Command: "i18n check-consts"})
+
+type keyConst struct {
+	Name string
+	Id   string
+}
+
+type keyConstsData struct {
+	Package string
+	Keys    []keyConst
+}
+
+func defaultConstsFile(xeniaDir string) string {
+	return filepath.Join(xeniaDir, "i18nk", "keys.go")
+}
+
+// constantName returns the exported Go name of a translation id, its words
+// split on the characters other than letters and digits.
+func constantName(id string) string {
+	words := strings.FieldsFunc(id, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	name := ""
+	for _, word := range words {
+		name += codegen.Export(word)
+	}
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		name = "Key" + name
+	}
+	return name
+}
+
+// keyConsts names the constants of the ids, sorted by id. The names already
+// taken get the first free numeric suffix.
+func keyConsts(ids []string) []keyConst {
+	sort.Strings(ids)
+	taken := map[string]bool{}
+	consts := []keyConst{}
+	for _, id := range ids {
+		name := constantName(id)
+		if taken[name] {
+			base := name
+			for i := 2; taken[name]; i++ {
+				name = base + strconv.Itoa(i)
+			}
+			logger.Warn("Translation id mapping to a taken constant name", "id", id, "constant", name)
+		}
+		taken[name] = true
+		consts = append(consts, keyConst{Name: name, Id: id})
+	}
+	return consts
+}
+
+func genConstsCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	output, err := command.Flags().GetString("output")
+	if err != nil {
+		return errors.New("Invalid output parameter")
+	}
+	pkg, err := command.Flags().GetString("package")
+	if err != nil {
+		return errors.New("Invalid package parameter")
+	}
+	check, err := command.Flags().GetBool("check")
+	if err != nil {
+		return errors.New("Invalid check parameter")
+	}
+	if !token.IsIdentifier(pkg) {
+		return fmt.Errorf("Invalid package name %s.", pkg)
+	}
+	if output == "" {
+		output = defaultConstsFile(xeniaDir)
+	}
+	command.SilenceUsage = true
+
+	translations, err := getCurrentTranslations(xeniaDir)
+	if err != nil {
+		return err
+	}
+	ids := []string{}
+	for _, t := range translations {
+		ids = append(ids, t.Id)
+	}
+
+	engine, err := codegen.New("i18n gen-consts", i18nTemplates, "templates/i18n/*.tmpl")
+	if err != nil {
+		return err
+	}
+	data := keyConstsData{Package: pkg, Keys: keyConsts(ids)}
+	if err := sandbox.checkPath(output); err != nil {
+		return err
+	}
+
+	if check {
+		upToDate, err := engine.Check(output, "keys.go", data)
+		if err != nil {
+			return err
+		}
+		if !upToDate {
+			return fmt.Errorf("%s is out of date, run i18n gen-consts.", output)
+		}
+		return nil
+	}
+
+	if err := engine.WriteFile(output, "keys.go", data); err != nil {
+		return err
+	}
+	fmt.Printf("%d translation id constants written to %s\n", len(data.Keys), output)
+	return nil
+}
+
+// readKeyConsts returns the package of a file generated by gen-consts and
+// the names of its constants by id.
+func readKeyConsts(file string) (string, map[string]string, error) {
+	src, err := readFile(file)
+	if err != nil {
+		return "", nil, err
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), file, src, 0)
+	if err != nil {
+		return "", nil, err
+	}
+	names := map[string]string{}
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			valueSpec := spec.(*ast.ValueSpec)
+			for i, name := range valueSpec.Names {
+				if i >= len(valueSpec.Values) {
+					break
+				}
+				literal, ok := valueSpec.Values[i].(*ast.BasicLit)
+				if !ok || literal.Kind != token.STRING {
+					continue
+				}
+				if id, err := strconv.Unquote(literal.Value); err == nil {
+					names[id] = name.Name
+				}
+			}
+		}
+	}
+	return f.Name.Name, names, nil
+}
+
+// rawKeyFindings returns the translation calls of a source file passing a
+// string literal having a constant.
+func rawKeyFindings(file, pkg string, names map[string]string) ([]finding, error) {
+	src, err := readFile(file)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, src, 0)
+	if err != nil {
+		return nil, err
+	}
+	findings := []finding{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		literal, ok := sourceExtractor.IdArgument(call)
+		if !ok || literal.Kind != token.STRING {
+			return true
+		}
+		id, err := strconv.Unquote(literal.Value)
+		if err != nil {
+			return true
+		}
+		if name, ok := names[id]; ok {
+			position := fset.Position(literal.Pos())
+			findings = append(findings, finding{
+				Rule:    rawKeyRule,
+				Message: fmt.Sprintf("%s is passed as a raw string, use %s.%s", id, pkg, name),
+				File:    findingPath(file),
+				Line:    position.Line,
+				Column:  position.Column,
+			})
+		}
+		return true
+	})
+	return findings, nil
+}
+
+func checkConstsCmdF(command *cobra.Command, args []string) error {
+	opts, err := getExtractOptions(command)
+	if err != nil {
+		return err
+	}
+	constsFile, err := command.Flags().GetString("consts-file")
+	if err != nil {
+		return errors.New("Invalid consts-file parameter")
+	}
+	reporter, err := getFindingsReporter(command)
+	if err != nil {
+		return err
+	}
+	if constsFile == "" {
+		constsFile = defaultConstsFile(opts.XeniaDir)
+	}
+	command.SilenceUsage = true
+
+	if _, err := os.Stat(constsFile); os.IsNotExist(err) {
+		reporter.Printf("%s doesn't exist, run i18n gen-consts to check the raw translation ids.\n", constsFile)
+		return reporter.Flush()
+	}
+	pkg, names, err := readKeyConsts(constsFile)
+	if err != nil {
+		return err
+	}
+	generated, err := filepath.Abs(constsFile)
+	if err != nil {
+		return err
+	}
+
+	walkSourceFiles(opts, func(p string) {
+		if !strings.HasSuffix(p, ".go") {
+			return
+		}
+		if abs, err := filepath.Abs(p); err == nil && abs == generated {
+			return
+		}
+		findings, err := rawKeyFindings(p, pkg, names)
+		if err != nil {
+			logger.Warn("Skipping source file", "path", p, "error", err)
+			return
+		}
+		for _, f := range findings {
+			reporter.Report(f)
+		}
+	})
+
+	if err := reporter.Flush(); err != nil {
+		return err
+	}
+	if count := reporter.Failures(); count > 0 {
+		return fmt.Errorf("%d translation ids passed as raw strings.", count)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var CrossCheckCmd = &cobra.Command{
+	Use:   "cross-check",
+	Short: "Check the shared ids of the server and client catalogs",
+	Long: `Compare the ids of i18n/en.json with the English catalogs of the clients, like the webapp or the mobile app, for the ids the server returns and the clients render, like the error ids. The ids with a shared prefix missing from a client catalog, or from the server one, are reported.
+
+The shared prefixes are given with --prefix or in the i18n.shared_prefixes setting of .mmgotool.yaml. The client catalogs can be arrays of {id, translation} objects, {"id": translation} maps or folders of json files.`,
+	Example: `  i18n cross-check --other ../xenia-webapp/i18n/en.json --prefix api. --prefix model.
+  i18n cross-check --other ../xenia-mobile/assets/base/i18n/en.json --format json`,
+	Args: cobra.NoArgs,
+	RunE: crossCheckCmdF,
+}
+
+func init() {
+	CrossCheckCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	CrossCheckCmd.Flags().StringArray("other", []string{}, "English catalog of a client, can be repeated")
+	CrossCheckCmd.Flags().StringArray("prefix", []string{}, "Prefix of the ids shared with the clients, can be repeated, defaults to the i18n.shared_prefixes setting")
+	CrossCheckCmd.Flags().String("format", "text", "Output format: text or json")
+	I18nCmd.AddCommand(CrossCheckCmd)
+}
+
+// catalogDrift are the shared ids of the server missing from a client
+// catalog, and the ones of the client missing from the server.
+type catalogDrift struct {
+	Catalog           string   `json:"catalog"`
+	MissingFromClient []string `json:"missing_from_client"`
+	MissingFromServer []string `json:"missing_from_server"`
+}
+
+func hasSharedPrefix(id string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(id, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// crossCheckCatalog compares the shared ids of the server and of a client.
+func crossCheckCatalog(server map[string]bool, client *Catalog, prefixes []string) catalogDrift {
+	drift := catalogDrift{Catalog: client.Path, MissingFromClient: []string{}, MissingFromServer: []string{}}
+	ids := []string{}
+	for id := range server {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if _, ok := client.Translations[id]; !ok && hasSharedPrefix(id, prefixes) {
+			drift.MissingFromClient = append(drift.MissingFromClient, id)
+		}
+	}
+	for _, id := range client.Ids() {
+		if !server[id] && hasSharedPrefix(id, prefixes) {
+			drift.MissingFromServer = append(drift.MissingFromServer, id)
+		}
+	}
+	return drift
+}
+
+func crossCheckCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	others, err := command.Flags().GetStringArray("other")
+	if err != nil {
+		return errors.New("Invalid other parameter")
+	}
+	if len(others) == 0 {
+		return errors.New("Pass the catalog of a client with --other.")
+	}
+	prefixes, err := command.Flags().GetStringArray("prefix")
+	if err != nil {
+		return errors.New("Invalid prefix parameter")
+	}
+	format, err := command.Flags().GetString("format")
+	if err != nil {
+		return errors.New("Invalid format parameter")
+	}
+	if format != "text" && format != "json" {
+		return fmt.Errorf("Unknown format %s", format)
+	}
+	config, err := loadToolConfig(xeniaDir)
+	if err != nil {
+		return err
+	}
+	if len(prefixes) == 0 {
+		prefixes = config.I18n.SharedPrefixes
+	}
+	if len(prefixes) == 0 {
+		return errors.New("Pass the prefixes of the shared ids with --prefix or in the i18n.shared_prefixes setting.")
+	}
+	command.SilenceUsage = true
+
+	translations, err := getCurrentTranslations(xeniaDir)
+	if err != nil {
+		return err
+	}
+	server := map[string]bool{}
+	for _, t := range translations {
+		server[t.Id] = true
+	}
+
+	drifts := []catalogDrift{}
+	differences := 0
+	for _, other := range others {
+		client, err := loadCatalog(other)
+		if err != nil {
+			return err
+		}
+		drift := crossCheckCatalog(server, client, prefixes)
+		differences += len(drift.MissingFromClient) + len(drift.MissingFromServer)
+		drifts = append(drifts, drift)
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(drifts); err != nil {
+			return err
+		}
+	} else {
+		for _, drift := range drifts {
+			for _, id := range drift.MissingFromClient {
+				fmt.Printf("Missing from %s: %s\n", drift.Catalog, id)
+			}
+			for _, id := range drift.MissingFromServer {
+				fmt.Printf("Missing from i18n/en.json: %s (%s)\n", id, drift.Catalog)
+			}
+		}
+	}
+	if differences > 0 {
+		return fmt.Errorf("%d shared ids are missing from a catalog.", differences)
+	}
+	if format == "text" {
+		fmt.Printf("The shared ids of the %d client catalogs match i18n/en.json.\n", len(drifts))
+	}
+	return nil
+}
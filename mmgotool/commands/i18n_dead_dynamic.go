@@ -0,0 +1,162 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+var DeadDynamicCmd = &cobra.Command{
+	Use:   "dead-dynamic",
+	Short: "Audit of the dynamically built translation ids",
+}
+
+var DeadDynamicAuditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Find the stale entries of the dynamic strings manifest",
+	Long: `Check that every id of the dynamic strings manifest is still built somewhere: a string literal of the source code must be the id, or a prefix or suffix of it ending or starting at a "." or "_" separator.
+
+The ids coming from outside the source code, like the month names, are not audited.`,
+	Example: "  i18n dead-dynamic audit --xenia-dir . --enterprise-dir ../enterprise",
+	RunE:    deadDynamicAuditCmdF,
+}
+
+func init() {
+	addExtractFlags(DeadDynamicAuditCmd)
+	DeadDynamicCmd.AddCommand(DeadDynamicAuditCmd)
+	I18nCmd.AddCommand(DeadDynamicCmd)
+}
+
+// sourceStringLiterals returns the value of every string literal of the Go
+// source files.
+func sourceStringLiterals(opts *extractOptions) map[string]bool {
+	jobs := opts.Jobs
+	if jobs < 1 {
+		jobs = runtime.GOMAXPROCS(0)
+	}
+
+	paths := make(chan string)
+	results := make(chan []string)
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range paths {
+				results <- fileStringLiterals(p)
+			}
+		}()
+	}
+	go func() {
+		walkSourceFiles(opts, func(p string) {
+			paths <- p
+		})
+		close(paths)
+		wg.Wait()
+		close(results)
+	}()
+
+	literals := map[string]bool{}
+	for found := range results {
+		for _, literal := range found {
+			literals[literal] = true
+		}
+	}
+	return literals
+}
+
+func fileStringLiterals(filePath string) []string {
+	src, err := readFile(filePath)
+	if err != nil {
+		logger.Debug("Unable to read the file", "path", filePath, "error", err)
+		return nil
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), filePath, src, 0)
+	if err != nil {
+		logger.Debug("Unable to parse the file", "path", filePath, "error", err)
+		return nil
+	}
+	literals := []string{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		literal, ok := n.(*ast.BasicLit)
+		if !ok || literal.Kind != token.STRING {
+			return true
+		}
+		if value, err := strconv.Unquote(literal.Value); err == nil && strings.Trim(value, "._") != "" {
+			literals = append(literals, value)
+		}
+		return true
+	})
+	return literals
+}
+
+func isIdSeparator(b byte) bool {
+	return b == '.' || b == '_'
+}
+
+// buildsId tells if the literal is the id, or a prefix or suffix of it
+// split at a separator.
+func buildsId(literal, id string) bool {
+	if literal == id {
+		return true
+	}
+	if len(literal) >= len(id) {
+		return false
+	}
+	if strings.HasPrefix(id, literal) && (isIdSeparator(literal[len(literal)-1]) || isIdSeparator(id[len(literal)])) {
+		return true
+	}
+	if strings.HasSuffix(id, literal) && (isIdSeparator(literal[0]) || isIdSeparator(id[len(id)-len(literal)-1])) {
+		return true
+	}
+	return false
+}
+
+// deadDynamicStrings returns the ids of the manifest no literal builds.
+func deadDynamicStrings(manifest []dynamicString, literals map[string]bool) []string {
+	dead := []string{}
+	for _, dynamic := range manifest {
+		if dynamic.External {
+			logger.Debug("Skipping external dynamic string", "id", dynamic.Id)
+			continue
+		}
+		built := false
+		for literal := range literals {
+			if buildsId(literal, dynamic.Id) {
+				built = true
+				break
+			}
+		}
+		if !built {
+			dead = append(dead, dynamic.Id)
+		}
+	}
+	return dead
+}
+
+func deadDynamicAuditCmdF(command *cobra.Command, args []string) error {
+	opts, err := getExtractOptions(command)
+	if err != nil {
+		return err
+	}
+	command.SilenceUsage = true
+
+	dead := deadDynamicStrings(dynamicStrings, sourceStringLiterals(opts))
+	for _, id := range dead {
+		fmt.Println("Dead:", id)
+	}
+	if len(dead) > 0 {
+		return fmt.Errorf("%d dynamic strings are never built in the source code.", len(dead))
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/cobra"
+)
+
+var DedupeCmd = &cobra.Command{
+	Use:   "dedupe",
+	Short: "Report duplicated English strings",
+	Long: `Group the ids of i18n/en.json whose English strings are the same, suggesting candidates to consolidate into a single id.
+
+Strings are compared ignoring case, punctuation and whitespace, so "An error occurred." and "An error occurred" end in the same group. Use --exact to only group identical strings. Plural strings are not compared.`,
+	Example: `  i18n dedupe
+  i18n dedupe --exact --format json`,
+	RunE: dedupeCmdF,
+}
+
+func init() {
+	DedupeCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	DedupeCmd.Flags().Bool("exact", false, "Only group identical strings")
+	DedupeCmd.Flags().Int("min-ids", 2, "Minimum number of ids sharing a string to report it")
+	DedupeCmd.Flags().String("format", "text", "Output format: text or json")
+	I18nCmd.AddCommand(DedupeCmd)
+}
+
+// duplicateGroup is a set of ids with the same English string.
+type duplicateGroup struct {
+	// Variants are the distinct strings of the group, the most used first.
+	Variants []string `json:"variants"`
+	Ids      []string `json:"ids"`
+}
+
+// normalizeString folds the differences between near-identical strings:
+// case, punctuation and whitespace.
+func normalizeString(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	})
+	return strings.Join(words, " ")
+}
+
+func findDuplicateStrings(translations []Translation, exact bool, minIds int) []duplicateGroup {
+	groups := map[string]map[string][]string{}
+	for _, t := range translations {
+		text, ok := t.Translation.(string)
+		if !ok || strings.TrimSpace(text) == "" {
+			continue
+		}
+		key := text
+		if !exact {
+			key = normalizeString(text)
+		}
+		if groups[key] == nil {
+			groups[key] = map[string][]string{}
+		}
+		groups[key][text] = append(groups[key][text], t.Id)
+	}
+
+	result := []duplicateGroup{}
+	for _, variants := range groups {
+		group := duplicateGroup{}
+		for variant, ids := range variants {
+			group.Variants = append(group.Variants, variant)
+			group.Ids = append(group.Ids, ids...)
+		}
+		if len(group.Ids) < minIds {
+			continue
+		}
+		sort.Slice(group.Variants, func(i, j int) bool {
+			a, b := len(variants[group.Variants[i]]), len(variants[group.Variants[j]])
+			if a != b {
+				return a > b
+			}
+			return group.Variants[i] < group.Variants[j]
+		})
+		sort.Strings(group.Ids)
+		result = append(result, group)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if len(result[i].Ids) != len(result[j].Ids) {
+			return len(result[i].Ids) > len(result[j].Ids)
+		}
+		return result[i].Variants[0] < result[j].Variants[0]
+	})
+	return result
+}
+
+func dedupeCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	exact, err := command.Flags().GetBool("exact")
+	if err != nil {
+		return errors.New("Invalid exact parameter")
+	}
+	minIds, err := command.Flags().GetInt("min-ids")
+	if err != nil || minIds < 2 {
+		return errors.New("Invalid min-ids parameter")
+	}
+	format, err := command.Flags().GetString("format")
+	if err != nil {
+		return errors.New("Invalid format parameter")
+	}
+	if format != "text" && format != "json" {
+		return fmt.Errorf("Unknown format %s", format)
+	}
+	command.SilenceUsage = true
+
+	translations, err := getCurrentTranslations(xeniaDir)
+	if err != nil {
+		return err
+	}
+	groups := findDuplicateStrings(translations, exact, minIds)
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		return encoder.Encode(groups)
+	}
+
+	duplicated := 0
+	for _, group := range groups {
+		quoted := []string{}
+		for _, variant := range group.Variants {
+			quoted = append(quoted, fmt.Sprintf("%q", variant))
+		}
+		fmt.Printf("%s (%d ids)\n", strings.Join(quoted, " / "), len(group.Ids))
+		for _, id := range group.Ids {
+			fmt.Println("  " + id)
+		}
+		duplicated += len(group.Ids) - 1
+	}
+	fmt.Printf("%d groups found, consolidating them would remove %d ids.\n", len(groups), duplicated)
+	return nil
+}
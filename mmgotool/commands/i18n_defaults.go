@@ -0,0 +1,115 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var defaultConflictRule = registerFindingRule(findingRule{Id: "i18n/default-conflict", Description: "The call sites of the translation id give different English strings", Command: "i18n check"})
+
+// defaultSite is a call giving the English text of an id, like TDefault.
+type defaultSite struct {
+	Text string `json:"text"`
+	Path string `json:"path"`
+	Line int    `json:"line,omitempty"`
+	ref  keyRef
+}
+
+// defaultConflict is an id whose call sites give different English texts,
+// or a text different from the string of i18n/en.json.
+type defaultConflict struct {
+	Id string `json:"id"`
+	// Sites are the first call site of every text, the one used first.
+	Sites []defaultSite `json:"sites"`
+	// Current is the string of i18n/en.json when it differs from the text
+	// of the call sites.
+	Current string `json:"current,omitempty"`
+}
+
+func (c defaultConflict) String() string {
+	texts := []string{}
+	for _, site := range c.Sites {
+		texts = append(texts, fmt.Sprintf("%q at %s:%d", site.Text, site.Path, site.Line))
+	}
+	if c.Current != "" {
+		texts = append(texts, fmt.Sprintf("%q in i18n/en.json", c.Current))
+	}
+	return fmt.Sprintf("%s: %s", c.Id, strings.Join(texts, ", "))
+}
+
+// conflictingSite returns the call site a conflict is reported at: the
+// first one disagreeing with the text used, else the one disagreeing with
+// i18n/en.json.
+func (c defaultConflict) conflictingSite() defaultSite {
+	if len(c.Sites) > 1 {
+		return c.Sites[1]
+	}
+	return c.Sites[0]
+}
+
+// keyDefaults returns the English text given by the call sites of every id
+// that has one. When the sites disagree the first one found is used, like
+// for the descriptions.
+func keyDefaults(refs []keyRef) map[string]string {
+	defaults := map[string]string{}
+	for _, ref := range refs {
+		if ref.Default == "" {
+			continue
+		}
+		if _, ok := defaults[ref.Id]; !ok {
+			defaults[ref.Id] = ref.Default
+		}
+	}
+	return defaults
+}
+
+// findDefaultConflicts returns, sorted by id, the ids whose call sites give
+// different English texts and the ones whose text is not the string of
+// i18n/en.json. A string still holding the placeholder is no conflict,
+// extract replaces it with the text.
+func findDefaultConflicts(refs []keyRef, translations []Translation, placeholder string) []defaultConflict {
+	sites := map[string][]defaultSite{}
+	for _, ref := range refs {
+		if ref.Default == "" {
+			continue
+		}
+		known := false
+		for _, site := range sites[ref.Id] {
+			known = known || site.Text == ref.Default
+		}
+		if !known {
+			sites[ref.Id] = append(sites[ref.Id], defaultSite{Text: ref.Default, Path: ref.Path, Line: ref.Line, ref: ref})
+		}
+	}
+	current := map[string]string{}
+	for _, t := range translations {
+		if text, ok := t.Translation.(string); ok && text != "" && text != placeholderText(placeholder, t.Id) {
+			current[t.Id] = text
+		}
+	}
+
+	conflicts := []defaultConflict{}
+	for id, idSites := range sites {
+		conflict := defaultConflict{Id: id, Sites: idSites}
+		if text, ok := current[id]; ok && text != idSites[0].Text {
+			conflict.Current = text
+		}
+		if len(idSites) > 1 || conflict.Current != "" {
+			conflicts = append(conflicts, conflict)
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Id < conflicts[j].Id })
+	return conflicts
+}
+
+// defaultConflictFinding locates a conflict at its disagreeing call site.
+func defaultConflictFinding(opts *extractOptions, conflict defaultConflict) finding {
+	site := conflict.conflictingSite()
+	file := filepath.Join(opts.moduleDir(site.ref.Module), site.Path)
+	return finding{Rule: defaultConflictRule, Message: "Conflicting English strings for " + conflict.String(), File: findingPath(file), Line: site.Line}
+}
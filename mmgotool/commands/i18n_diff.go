@@ -0,0 +1,193 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/spf13/cobra"
+)
+
+var DiffCmd = &cobra.Command{
+	Use:   "diff [<base-catalog> <head-catalog>]",
+	Short: "List the English strings added, removed or changed between two versions",
+	Long: `List the English source strings added, removed or changed between two versions of a catalog, to write the release notes for the translators at string freeze.
+
+The versions are two catalog files, or with --git-ref the catalog at a git revision of the Xenia repository and the one of the working tree. The catalog defaults to i18n/en.json.`,
+	Example: `  i18n diff old/i18n/en.json i18n/en.json
+  i18n diff --git-ref v5.20.0
+  i18n diff --git-ref release-5.21 --format markdown > translator-notes.md`,
+	Args: cobra.MaximumNArgs(2),
+	RunE: diffCmdF,
+}
+
+func init() {
+	DiffCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	DiffCmd.Flags().String("git-ref", "", "Git revision of the base catalog, compared with the working tree")
+	DiffCmd.Flags().String("format", "text", "Output format: text, markdown or json")
+	I18nCmd.AddCommand(DiffCmd)
+}
+
+type stringChange struct {
+	Id     string      `json:"id"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+type stringDiff struct {
+	Base    string         `json:"base"`
+	Head    string         `json:"head"`
+	Added   []stringChange `json:"added"`
+	Removed []stringChange `json:"removed"`
+	Changed []stringChange `json:"changed"`
+}
+
+func diffCatalogs(base, head *Catalog) *stringDiff {
+	diff := &stringDiff{Base: base.Path, Head: head.Path, Added: []stringChange{}, Removed: []stringChange{}, Changed: []stringChange{}}
+	for _, id := range base.Ids() {
+		after, ok := head.Translations[id]
+		if !ok {
+			diff.Removed = append(diff.Removed, stringChange{Id: id, Before: base.Translations[id]})
+		} else if !reflect.DeepEqual(base.Translations[id], after) {
+			diff.Changed = append(diff.Changed, stringChange{Id: id, Before: base.Translations[id], After: after})
+		}
+	}
+	for _, id := range head.Ids() {
+		if _, ok := base.Translations[id]; !ok {
+			diff.Added = append(diff.Added, stringChange{Id: id, After: head.Translations[id]})
+		}
+	}
+	return diff
+}
+
+// catalogAtRevision reads a catalog file at a git revision of the repository.
+func catalogAtRevision(repoDir, revision, file string) (*Catalog, error) {
+	location := revision + ":" + filepath.ToSlash(file)
+	output, err := execCommand("git", "-C", repoDir, "show", location).Output()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read %s", location)
+	}
+	translations, format, err := parseCatalog(output)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse %s: %s", location, err.Error())
+	}
+	return &Catalog{Path: location, Format: format, Translations: translations}, nil
+}
+
+// stringValue renders a translation for the report, plural forms as JSON.
+func stringValue(value interface{}) string {
+	if text, ok := value.(string); ok {
+		return text
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+func (d *stringDiff) text() []byte {
+	var buf bytes.Buffer
+	for _, change := range d.Added {
+		fmt.Fprintf(&buf, "Added: %s\n    %s\n", change.Id, stringValue(change.After))
+	}
+	for _, change := range d.Removed {
+		fmt.Fprintf(&buf, "Removed: %s\n    %s\n", change.Id, stringValue(change.Before))
+	}
+	for _, change := range d.Changed {
+		fmt.Fprintf(&buf, "Changed: %s\n    - %s\n    + %s\n", change.Id, stringValue(change.Before), stringValue(change.After))
+	}
+	fmt.Fprintf(&buf, "%d added, %d removed, %d changed between %s and %s\n", len(d.Added), len(d.Removed), len(d.Changed), d.Base, d.Head)
+	return buf.Bytes()
+}
+
+func (d *stringDiff) markdown() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# English string changes\n\nFrom `%s` to `%s`: %d added, %d removed, %d changed.\n", d.Base, d.Head, len(d.Added), len(d.Removed), len(d.Changed))
+	if len(d.Added) > 0 {
+		buf.WriteString("\n## Added\n\n| Id | English |\n| --- | --- |\n")
+		for _, change := range d.Added {
+			fmt.Fprintf(&buf, "| `%s` | %s |\n", change.Id, markdownCell(stringValue(change.After)))
+		}
+	}
+	if len(d.Changed) > 0 {
+		buf.WriteString("\n## Changed\n\n| Id | Before | After |\n| --- | --- | --- |\n")
+		for _, change := range d.Changed {
+			fmt.Fprintf(&buf, "| `%s` | %s | %s |\n", change.Id, markdownCell(stringValue(change.Before)), markdownCell(stringValue(change.After)))
+		}
+	}
+	if len(d.Removed) > 0 {
+		buf.WriteString("\n## Removed\n\n| Id | English |\n| --- | --- |\n")
+		for _, change := range d.Removed {
+			fmt.Fprintf(&buf, "| `%s` | %s |\n", change.Id, markdownCell(stringValue(change.Before)))
+		}
+	}
+	return buf.Bytes()
+}
+
+func diffCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	gitRef, err := command.Flags().GetString("git-ref")
+	if err != nil {
+		return errors.New("Invalid git-ref parameter")
+	}
+	format, err := command.Flags().GetString("format")
+	if err != nil {
+		return errors.New("Invalid format parameter")
+	}
+	if format != "text" && format != "markdown" && format != "json" {
+		return fmt.Errorf("Unknown format %s", format)
+	}
+	if gitRef == "" && len(args) != 2 {
+		return errors.New("Pass the base and head catalogs, or a git revision with --git-ref.")
+	}
+	if gitRef != "" && len(args) > 1 {
+		return errors.New("With --git-ref, pass at most the catalog path inside the Xenia repository.")
+	}
+	command.SilenceUsage = true
+
+	var base, head *Catalog
+	if gitRef != "" {
+		file := filepath.Join("i18n", "en.json")
+		if len(args) == 1 {
+			file = args[0]
+		}
+		if base, err = catalogAtRevision(xeniaDir, gitRef, file); err != nil {
+			return err
+		}
+		if head, err = loadCatalog(filepath.Join(xeniaDir, file)); err != nil {
+			return err
+		}
+	} else {
+		if base, err = loadCatalog(args[0]); err != nil {
+			return err
+		}
+		if head, err = loadCatalog(args[1]); err != nil {
+			return err
+		}
+	}
+
+	diff := diffCatalogs(base, head)
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		return encoder.Encode(diff)
+	case "markdown":
+		os.Stdout.Write(diff.markdown())
+	default:
+		os.Stdout.Write(diff.text())
+	}
+	return nil
+}
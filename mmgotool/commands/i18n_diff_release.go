@@ -0,0 +1,282 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const defaultReleaseArtifact = "xenia-{version}-linux-amd64.tar.gz"
+
+var DiffReleaseCmd = &cobra.Command{
+	Use:   "diff-release <release>",
+	Short: "Check the catalogs of a published release against its tag",
+	Long: `Download the package of a published release and diff the translation files it ships with the ones of the release tag in the Xenia repository, to catch the packaging bugs shipping other catalogs than the tagged ones.
+
+The package is downloaded from https://<channel>/<version>/<artifact>, the version being the release without its "v" prefix, and "5.28" matching "5.28.0". With --package a downloaded package is read instead.`,
+	Example: `  i18n diff-release v5.28 --channel releases.xenia.com
+  i18n diff-release v5.28.1 --package xenia-5.28.1-linux-amd64.tar.gz --format json`,
+	Args: cobra.ExactArgs(1),
+	RunE: diffReleaseCmdF,
+}
+
+func init() {
+	DiffReleaseCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	DiffReleaseCmd.Flags().String("channel", "releases.xenia.com", "Host, or base URL, publishing the releases")
+	DiffReleaseCmd.Flags().String("artifact", defaultReleaseArtifact, "Name of the release package, {version} being replaced by the version")
+	DiffReleaseCmd.Flags().String("package", "", "Path to a downloaded release package, instead of the one of the channel")
+	DiffReleaseCmd.Flags().String("format", "text", "Output format: text or json")
+	I18nCmd.AddCommand(DiffReleaseCmd)
+}
+
+// localeDiff are the strings of a locale differing between the tag and the
+// release.
+type localeDiff struct {
+	Locale string `json:"locale"`
+	*stringDiff
+}
+
+type releaseDiff struct {
+	Release string `json:"release"`
+	Package string `json:"package"`
+	Tag     string `json:"tag"`
+	// Missing are the translation files of the tag not shipped, Extra the
+	// shipped ones not in the tag.
+	Missing []string     `json:"missing"`
+	Extra   []string     `json:"extra"`
+	Locales []localeDiff `json:"locales"`
+}
+
+func (d *releaseDiff) Mismatches() int {
+	return len(d.Missing) + len(d.Extra) + len(d.Locales)
+}
+
+// releaseVersion returns the version of a release, without its "v" prefix
+// and with its patch number.
+func releaseVersion(release string) string {
+	version := strings.TrimPrefix(release, "v")
+	if strings.Count(version, ".") == 1 {
+		version += ".0"
+	}
+	return version
+}
+
+// releasePackageURL returns the URL of the package of a release version.
+func releasePackageURL(channel, artifact, version string) string {
+	base := strings.TrimSuffix(channel, "/")
+	if !strings.Contains(base, "://") {
+		base = "https://" + base
+	}
+	return base + "/" + version + "/" + strings.Replace(artifact, "{version}", version, -1)
+}
+
+// readReleaseCatalogs reads the translation files of a .tar.gz release
+// package, the json files of its i18n folder, by file name.
+func readReleaseCatalogs(r io.Reader, name string) (map[string]*Catalog, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read the %s package: %s", name, err.Error())
+	}
+	defer gz.Close()
+
+	catalogs := map[string]*Catalog{}
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read the %s package: %s", name, err.Error())
+		}
+		file := strings.TrimPrefix(path.Clean(header.Name), "./")
+		// The packages hold a xenia folder.
+		if header.Typeflag != tar.TypeReg || path.Ext(file) != ".json" || path.Base(path.Dir(file)) != "i18n" || strings.Count(file, "/") > 2 {
+			continue
+		}
+		data, err := ioutil.ReadAll(archive)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read the %s package: %s", name, err.Error())
+		}
+		translations, format, err := parseCatalog(data)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse %s of the %s package: %s", file, name, err.Error())
+		}
+		catalogs[path.Base(file)] = &Catalog{Path: name + ":" + file, Format: format, Translations: translations}
+	}
+	if len(catalogs) == 0 {
+		return nil, fmt.Errorf("The %s package has no translation files.", name)
+	}
+	return catalogs, nil
+}
+
+// releaseTag returns the git tag of a release, "v5.28" matching "v5.28.0".
+func releaseTag(xeniaDir, release string) (string, error) {
+	tag := "v" + strings.TrimPrefix(release, "v")
+	for _, candidate := range []string{tag, "v" + releaseVersion(release)} {
+		if execCommand("git", "-C", xeniaDir, "rev-parse", "--verify", "--quiet", candidate+"^{commit}").Run() == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("No %s tag in %s", tag, xeniaDir)
+}
+
+// catalogsAtRevision reads the translation files of the i18n folder at a
+// git revision, by file name.
+func catalogsAtRevision(xeniaDir, revision string) (map[string]*Catalog, error) {
+	output, err := execCommand("git", "-C", xeniaDir, "ls-tree", "--name-only", revision, "i18n/").Output()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list the translation files at %s", revision)
+	}
+	catalogs := map[string]*Catalog{}
+	for _, file := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if path.Ext(file) != ".json" {
+			continue
+		}
+		catalog, err := catalogAtRevision(xeniaDir, revision, file)
+		if err != nil {
+			return nil, err
+		}
+		catalogs[path.Base(file)] = catalog
+	}
+	return catalogs, nil
+}
+
+// diffReleaseCatalogs compares the catalogs of the tag with the shipped ones.
+func diffReleaseCatalogs(tagged, shipped map[string]*Catalog) *releaseDiff {
+	diff := &releaseDiff{Missing: []string{}, Extra: []string{}, Locales: []localeDiff{}}
+	names := []string{}
+	for name := range tagged {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		released, ok := shipped[name]
+		if !ok {
+			diff.Missing = append(diff.Missing, name)
+			continue
+		}
+		changes := diffCatalogs(tagged[name], released)
+		if len(changes.Added)+len(changes.Removed)+len(changes.Changed) > 0 {
+			diff.Locales = append(diff.Locales, localeDiff{Locale: localeName(name), stringDiff: changes})
+		}
+	}
+	for name := range shipped {
+		if _, ok := tagged[name]; !ok {
+			diff.Extra = append(diff.Extra, name)
+		}
+	}
+	sort.Strings(diff.Extra)
+	return diff
+}
+
+func diffReleaseCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	channel, err := command.Flags().GetString("channel")
+	if err != nil {
+		return errors.New("Invalid channel parameter")
+	}
+	artifact, err := command.Flags().GetString("artifact")
+	if err != nil || artifact == "" {
+		return errors.New("Invalid artifact parameter")
+	}
+	packageFile, err := command.Flags().GetString("package")
+	if err != nil {
+		return errors.New("Invalid package parameter")
+	}
+	if packageFile == "" && channel == "" {
+		return errors.New("Pass the channel of the release, or a downloaded package with --package.")
+	}
+	format, err := command.Flags().GetString("format")
+	if err != nil {
+		return errors.New("Invalid format parameter")
+	}
+	if format != "text" && format != "json" {
+		return fmt.Errorf("Unknown format %s", format)
+	}
+	command.SilenceUsage = true
+
+	tag, err := releaseTag(xeniaDir, args[0])
+	if err != nil {
+		return err
+	}
+	tagged, err := catalogsAtRevision(xeniaDir, tag)
+	if err != nil {
+		return err
+	}
+
+	var shipped map[string]*Catalog
+	source := packageFile
+	if packageFile != "" {
+		f, err := openFile(packageFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if shipped, err = readReleaseCatalogs(f, path.Base(packageFile)); err != nil {
+			return err
+		}
+	} else {
+		source = releasePackageURL(channel, artifact, releaseVersion(args[0]))
+		logger.Info("Downloading the release package", "url", source)
+		client := &http.Client{Timeout: 10 * time.Minute}
+		response, err := client.Get(source)
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s answers %s", source, response.Status)
+		}
+		if shipped, err = readReleaseCatalogs(response.Body, path.Base(source)); err != nil {
+			return err
+		}
+	}
+
+	diff := diffReleaseCatalogs(tagged, shipped)
+	diff.Release, diff.Package, diff.Tag = args[0], source, tag
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(diff); err != nil {
+			return err
+		}
+	} else {
+		for _, name := range diff.Missing {
+			fmt.Printf("Not shipped: i18n/%s\n", name)
+		}
+		for _, name := range diff.Extra {
+			fmt.Printf("Not tagged: i18n/%s\n", name)
+		}
+		for _, locale := range diff.Locales {
+			fmt.Printf("Locale %s:\n", locale.Locale)
+			os.Stdout.Write(locale.text())
+		}
+	}
+	if diff.Mismatches() > 0 {
+		return fmt.Errorf("The catalogs of the %s package don't match the %s tag.", path.Base(source), tag)
+	}
+	if format == "text" {
+		fmt.Printf("The %d translation files of the %s package match the %s tag.\n", len(shipped), path.Base(source), tag)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+// provenanceExportVersion must be increased every time the format of the
+// exported provenance changes incompatibly.
+const provenanceExportVersion = 1
+
+var ExportProvenanceCmd = &cobra.Command{
+	Use:   "export-provenance",
+	Short: "Export where every translation id is used in the source code",
+	Long: `Export every translation id with the places of the source code using it: the file, relative to its module root, the line, the function holding the call, the translation function, component or constant used and the HTTP status of the errors.
+
+The export is meant for the tools documenting or navigating the translations, instead of each of them parsing the source code.`,
+	Example: `  i18n export-provenance --out provenance.json
+  i18n export-provenance --include-tests | jq '.keys["api.context.404.app_error"]'`,
+	RunE: exportProvenanceCmdF,
+}
+
+func init() {
+	addExtractFlags(ExportProvenanceCmd)
+	ExportProvenanceCmd.Flags().String("out", "", "File to write, defaults to the standard output")
+	I18nCmd.AddCommand(ExportProvenanceCmd)
+}
+
+// provenanceSite is a place of the source code using a translation id.
+type provenanceSite struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Function string `json:"function,omitempty"`
+	CallKind string `json:"callKind"`
+	Status   int    `json:"status,omitempty"`
+}
+
+type provenanceExport struct {
+	Version int                         `json:"version"`
+	Keys    map[string][]provenanceSite `json:"keys"`
+}
+
+// exportedProvenance groups the references by id, every id having its sites
+// sorted by file and line.
+func exportedProvenance(refs []keyRef) *provenanceExport {
+	export := &provenanceExport{Version: provenanceExportVersion, Keys: map[string][]provenanceSite{}}
+	for _, ref := range refs {
+		site := provenanceSite{File: ref.Path, Line: ref.Line, Function: ref.Function, CallKind: ref.CallKind, Status: ref.Status}
+		export.Keys[ref.Id] = append(export.Keys[ref.Id], site)
+	}
+	for _, sites := range export.Keys {
+		sort.SliceStable(sites, func(i, j int) bool {
+			if sites[i].File != sites[j].File {
+				return sites[i].File < sites[j].File
+			}
+			return sites[i].Line < sites[j].Line
+		})
+	}
+	return export
+}
+
+func exportProvenanceCmdF(command *cobra.Command, args []string) error {
+	opts, err := getExtractOptions(command)
+	if err != nil {
+		return err
+	}
+	out, err := command.Flags().GetString("out")
+	if err != nil {
+		return errors.New("Invalid out parameter")
+	}
+	command.SilenceUsage = true
+
+	refs, problems := extractKeyRefs(opts)
+	if err := reportExtractProblems(opts, problems); err != nil {
+		return err
+	}
+	export := exportedProvenance(refs)
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if out == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if err := writeFile(out, data, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Exported the provenance of %d ids to %s.\n", len(export.Keys), out)
+	return nil
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/text/unicode/norm"
+)
+
+var FmtCmd = &cobra.Command{
+	Use:   "fmt [file...]",
+	Short: "Rewrite translation files in canonical form",
+	Long: `Rewrite translation files in canonical form: sorted by id, indented with two spaces, without HTML escaping, with a trailing newline and with the strings normalized to Unicode NFC.
+
+The files default to every translation file of the i18n folder. Array files stay arrays and map files stay maps unless --catalog-format converts them, files with unknown fields are refused instead of losing them.`,
+	Example: `  i18n fmt
+  i18n fmt --check
+  i18n fmt i18n/de.json i18n/fr.json
+  i18n fmt --catalog-format map`,
+	RunE: fmtCmdF,
+}
+
+func init() {
+	FmtCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	FmtCmd.Flags().Bool("check", false, "List the files not in canonical form and fail instead of rewriting them")
+	I18nCmd.AddCommand(FmtCmd)
+}
+
+// normalizeValue returns the translation with its strings, and the strings of
+// its plural forms, in NFC.
+func normalizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return norm.NFC.String(v)
+	case map[string]interface{}:
+		forms := map[string]interface{}{}
+		for form, text := range v {
+			forms[form] = normalizeValue(text)
+		}
+		return forms
+	}
+	return value
+}
+
+// formatTranslationsFile returns the canonical content of a translation file.
+func formatTranslationsFile(data []byte) ([]byte, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		translations := map[string]interface{}{}
+		if err := json.Unmarshal(data, &translations); err != nil {
+			return nil, err
+		}
+		normalized := map[string]interface{}{}
+		for id, value := range translations {
+			normalized[id] = normalizeValue(value)
+		}
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(normalized); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	var translations []Translation
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&translations); err != nil {
+		return nil, err
+	}
+	for i := range translations {
+		translations[i].Translation = normalizeValue(translations[i].Translation)
+		translations[i].Description = norm.NFC.String(translations[i].Description)
+	}
+	sort.SliceStable(translations, func(i, j int) bool { return translations[i].Id < translations[j].Id })
+	return encodeTranslations(translations)
+}
+
+func fmtCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	check, err := command.Flags().GetBool("check")
+	if err != nil {
+		return errors.New("Invalid check parameter")
+	}
+	command.SilenceUsage = true
+
+	files := args
+	if len(files) == 0 {
+		if files, err = filepath.Glob(filepath.Join(xeniaDir, "i18n", "*.json")); err != nil {
+			return err
+		}
+	}
+
+	unformatted := 0
+	for _, file := range files {
+		current, err := readFile(file)
+		if err != nil {
+			return err
+		}
+		formatted, err := formatTranslationsFile(current)
+		if err != nil {
+			return fmt.Errorf("Unable to parse %s: %s", file, err.Error())
+		}
+		if catalogFormat != "" && catalogFormat != catalogDataFormat(formatted) {
+			translations, err := decodeTranslations(formatted)
+			if err != nil {
+				return fmt.Errorf("Unable to parse %s: %s", file, err.Error())
+			}
+			if formatted, err = encodeCatalog(translations, catalogFormat); err != nil {
+				return err
+			}
+		}
+		if bytes.Equal(current, formatted) {
+			continue
+		}
+		unformatted++
+		if check {
+			fmt.Println(file)
+			continue
+		}
+		if err := writeFile(file, formatted, 0644); err != nil {
+			return err
+		}
+		logger.Info("Formatted", "path", file)
+	}
+	if check && unformatted > 0 {
+		return fmt.Errorf("%d translation files are not in canonical form, run i18n fmt.", unformatted)
+	}
+	return nil
+}
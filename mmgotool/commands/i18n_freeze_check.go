@@ -0,0 +1,139 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// freezeAllowlistFile is the default allowlist of the string freeze, relative
+// to the Xenia folder.
+var freezeAllowlistFile = filepath.Join("i18n", "freeze-allowlist.txt")
+
+var FreezeCheckCmd = &cobra.Command{
+	Use:   "freeze-check",
+	Short: "Enforce the string freeze",
+	Long: `Fail when English translation ids or strings of i18n/en.json were added or changed since the string freeze.
+
+The allowlist file lists an id per line, or a pattern like "api.plugin.*", exempted from the freeze. Lines starting with # are comments. The exceptions approved with "i18n key-freeze exceptions" are exempted too.`,
+	Example: `  i18n freeze-check --since v5.21.0-rc1
+  i18n freeze-check --since release-5.21 --allowlist ./ci/freeze-allowlist.txt`,
+	RunE: freezeCheckCmdF,
+}
+
+func init() {
+	FreezeCheckCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	FreezeCheckCmd.Flags().String("since", "", "Release or git revision of the string freeze")
+	FreezeCheckCmd.Flags().String("allowlist", "", "File of the ids exempted from the freeze, defaults to i18n/freeze-allowlist.txt when it exists")
+	FreezeCheckCmd.Flags().String("format", "text", "Output format: text or json")
+	I18nCmd.AddCommand(FreezeCheckCmd)
+}
+
+// readFreezeAllowlist returns the ids and patterns of the allowlist file.
+func readFreezeAllowlist(file string) ([]string, error) {
+	f, err := openFile(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	patterns := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, err := path.Match(line, ""); err != nil {
+			return nil, fmt.Errorf("Invalid pattern %s in %s.", line, file)
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, scanner.Err()
+}
+
+func allowlisted(id string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, id); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func freezeCheckCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	since, err := command.Flags().GetString("since")
+	if err != nil || since == "" {
+		return errors.New("Invalid since parameter")
+	}
+	allowlist, err := command.Flags().GetString("allowlist")
+	if err != nil {
+		return errors.New("Invalid allowlist parameter")
+	}
+	format, err := command.Flags().GetString("format")
+	if err != nil {
+		return errors.New("Invalid format parameter")
+	}
+	if format != "text" && format != "json" {
+		return fmt.Errorf("Unknown format %s", format)
+	}
+	command.SilenceUsage = true
+
+	patterns := []string{}
+	if allowlist == "" {
+		allowlist = filepath.Join(xeniaDir, freezeAllowlistFile)
+		if _, err := os.Stat(allowlist); os.IsNotExist(err) {
+			allowlist = ""
+		}
+	}
+	if allowlist != "" {
+		if patterns, err = readFreezeAllowlist(allowlist); err != nil {
+			return err
+		}
+	}
+
+	translations, err := readTranslationsFile(filepath.Join(xeniaDir, "i18n", "en.json"))
+	if err != nil {
+		return err
+	}
+	changes, err := checkStringFreeze(xeniaDir, since, translations, nil)
+	if err != nil {
+		return err
+	}
+	frozen := []frozenChange{}
+	for _, change := range changes {
+		if !allowlisted(change.Id, patterns) {
+			frozen = append(frozen, change)
+		}
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(frozen); err != nil {
+			return err
+		}
+	} else {
+		for _, change := range frozen {
+			fmt.Println("Frozen:", change.String())
+		}
+	}
+	if len(frozen) > 0 {
+		return fmt.Errorf("%d strings changed after the string freeze at %s, allowlist them or request exceptions with i18n key-freeze exceptions request.", len(frozen), since)
+	}
+	return nil
+}
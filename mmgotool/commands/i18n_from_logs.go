@@ -0,0 +1,225 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var missingTranslationPattern = regexp.MustCompile(`(?i)missing translation|translation not found|untranslated|fall(?:ing)? ?back`)
+
+var logKeyValuePattern = regexp.MustCompile(`([A-Za-z_]+)=("(?:[^"\\]|\\.)*"|\S+)`)
+
+// Field names carrying the translation id and the locale in the warnings.
+var (
+	missingIdFields     = []string{"translation_id", "id", "key"}
+	missingLocaleFields = []string{"locale", "lang", "language"}
+)
+
+var FromLogsCmd = &cobra.Command{
+	Use:   "from-logs",
+	Short: "Translation gaps reported by server logs",
+}
+
+var MissingKeysCmd = &cobra.Command{
+	Use:   "missing-keys <log-file>...",
+	Short: "List the translations reported missing in the logs",
+	Long: `Parse server logs for missing translation and fallback warnings, aggregate the ids by locale and cross-reference them with the translation files.
+The ids are ordered by number of occurrences so the most visible gaps come first. Both JSON and plain text logs are supported.`,
+	Example: "  i18n from-logs missing-keys xenia.log xenia.log.1 --xenia-dir ../xenia-server",
+	Args:    cobra.MinimumNArgs(1),
+	RunE:    missingKeysCmdF,
+}
+
+func init() {
+	MissingKeysCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	MissingKeysCmd.Flags().String("format", "text", "Output format: text or json")
+	FromLogsCmd.AddCommand(MissingKeysCmd)
+	I18nCmd.AddCommand(FromLogsCmd)
+}
+
+type missingKey struct {
+	Id      string         `json:"id"`
+	Count   int            `json:"count"`
+	Locales map[string]int `json:"locales"`
+	Status  string         `json:"status"`
+}
+
+// parseMissingTranslation returns the id and locale of a missing
+// translation warning, or an empty id when the line is something else.
+func parseMissingTranslation(line string) (string, string) {
+	fields := map[string]string{}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &entry); err == nil {
+		msg, _ := entry["msg"].(string)
+		if !missingTranslationPattern.MatchString(msg) {
+			return "", ""
+		}
+		for key, value := range entry {
+			if text, ok := value.(string); ok {
+				fields[key] = text
+			}
+		}
+	} else {
+		if !missingTranslationPattern.MatchString(line) {
+			return "", ""
+		}
+		for _, match := range logKeyValuePattern.FindAllStringSubmatch(line, -1) {
+			value := match[2]
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				value = unquoted
+			}
+			fields[match[1]] = value
+		}
+	}
+
+	return firstField(fields, missingIdFields), firstField(fields, missingLocaleFields)
+}
+
+func firstField(fields map[string]string, names []string) string {
+	for _, name := range names {
+		if value := fields[name]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func countMissingKeys(files []string) (map[string]*missingKey, error) {
+	keys := map[string]*missingKey{}
+	for _, file := range files {
+		f, err := openFile(file)
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			id, locale := parseMissingTranslation(scanner.Text())
+			if id == "" {
+				continue
+			}
+			if locale == "" {
+				locale = "unknown"
+			}
+			key, ok := keys[id]
+			if !ok {
+				key = &missingKey{Id: id, Locales: map[string]int{}}
+				keys[id] = key
+			}
+			key.Count++
+			key.Locales[locale]++
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read %s: %s", file, err.Error())
+		}
+	}
+	return keys, nil
+}
+
+// missingKeyStatus tells what has to be fixed for the key: adding it to
+// the source strings, translating it, or nothing when the current
+// translation files already contain it.
+func missingKeyStatus(key *missingKey, source map[string]interface{}, locales map[string]map[string]interface{}) string {
+	if value, ok := source[key.Id]; !ok || isEmptyTranslation(value) {
+		return "missing in en.json"
+	}
+	untranslated := []string{}
+	for locale := range key.Locales {
+		translations, ok := locales[locale]
+		if !ok {
+			continue
+		}
+		if value, ok := translations[key.Id]; !ok || isEmptyTranslation(value) {
+			untranslated = append(untranslated, locale)
+		}
+	}
+	if len(untranslated) == 0 {
+		return "translated"
+	}
+	sort.Strings(untranslated)
+	return "untranslated in " + strings.Join(untranslated, ", ")
+}
+
+func missingKeysCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	format, err := command.Flags().GetString("format")
+	if err != nil {
+		return errors.New("Invalid format parameter")
+	}
+	if format != "text" && format != "json" {
+		return fmt.Errorf("Unknown format %s", format)
+	}
+	command.SilenceUsage = true
+
+	keys, err := countMissingKeys(args)
+	if err != nil {
+		return err
+	}
+	source, err := sourceTranslations(xeniaDir)
+	if err != nil {
+		return err
+	}
+	files, err := localeFiles(xeniaDir)
+	if err != nil {
+		return err
+	}
+	locales := map[string]map[string]interface{}{}
+	for _, file := range files {
+		catalog, err := loadCatalog(file)
+		if err != nil {
+			return err
+		}
+		locales[localeName(file)] = catalog.Translations
+	}
+
+	result := []*missingKey{}
+	for _, key := range keys {
+		key.Status = missingKeyStatus(key, source, locales)
+		result = append(result, key)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Id < result[j].Id
+	})
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+	for _, key := range result {
+		localeNames := []string{}
+		for locale := range key.Locales {
+			localeNames = append(localeNames, locale)
+		}
+		sort.Strings(localeNames)
+		counts := []string{}
+		for _, locale := range localeNames {
+			counts = append(counts, fmt.Sprintf("%s:%d", locale, key.Locales[locale]))
+		}
+		fmt.Printf("%6d %s [%s] %s\n", key.Count, key.Id, strings.Join(counts, " "), key.Status)
+	}
+	if len(result) == 0 {
+		fmt.Println("No missing translation found in the logs.")
+	}
+	return nil
+}
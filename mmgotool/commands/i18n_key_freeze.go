@@ -0,0 +1,365 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// freezeExceptionsFile is the file of the string freeze exceptions, relative
+// to the Xenia folder. It is committed so the exceptions have a history.
+var freezeExceptionsFile = filepath.Join("i18n", "freeze-exceptions.yaml")
+
+var KeyFreezeCmd = &cobra.Command{
+	Use:   "key-freeze",
+	Short: "String freeze tooling",
+}
+
+var KeyFreezeExceptionsCmd = &cobra.Command{
+	Use:   "exceptions",
+	Short: "Manage the string freeze exceptions",
+	Long: `Request, list and approve the exceptions to the string freeze.
+
+The exceptions are stored in i18n/freeze-exceptions.yaml with the git identity of the requester and of the approver, so they are reviewed and kept like any other change. The approved exceptions let "i18n check --freeze-since" and "i18n freeze-check" accept strings added or changed after the freeze.`,
+}
+
+var KeyFreezeRequestCmd = &cobra.Command{
+	Use:   "request <id>...",
+	Short: "Request string freeze exceptions",
+	Long:  "Request an exception to the string freeze for every id. The exception must be approved by someone else before the check accepts the id.",
+	Example: `  i18n key-freeze exceptions request api.user.login.mfa_required.app_error --reason "Security fix MM-1234"
+  i18n key-freeze exceptions request app.plugin.install.app_error --since v5.20 --reason "Plugin marketplace"`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: keyFreezeRequestCmdF,
+}
+
+var KeyFreezeListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "List the string freeze exceptions",
+	Example: "  i18n key-freeze exceptions list --pending",
+	RunE:    keyFreezeListCmdF,
+}
+
+var KeyFreezeApproveCmd = &cobra.Command{
+	Use:   "approve <id>...",
+	Short: "Approve string freeze exceptions",
+	Long:  "Approve the requested exceptions of the ids. The approver is the git identity of the Xenia repository and can't be the requester.",
+	Example: `  i18n key-freeze exceptions approve api.user.login.mfa_required.app_error
+  i18n key-freeze exceptions approve app.plugin.install.app_error --since v5.20`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: keyFreezeApproveCmdF,
+}
+
+func init() {
+	for _, command := range []*cobra.Command{KeyFreezeRequestCmd, KeyFreezeListCmd, KeyFreezeApproveCmd} {
+		command.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	}
+	KeyFreezeRequestCmd.Flags().String("reason", "", "Why the strings must change during the freeze")
+	KeyFreezeRequestCmd.Flags().String("since", "", "Release or git revision of the freeze, the exception applies to every freeze when empty")
+	KeyFreezeListCmd.Flags().Bool("pending", false, "Only list the exceptions waiting for approval")
+	KeyFreezeListCmd.Flags().String("format", "text", "Output format: text or json")
+	KeyFreezeApproveCmd.Flags().String("since", "", "Release or git revision of the freeze of the exceptions to approve")
+
+	KeyFreezeExceptionsCmd.AddCommand(KeyFreezeRequestCmd, KeyFreezeListCmd, KeyFreezeApproveCmd)
+	KeyFreezeCmd.AddCommand(KeyFreezeExceptionsCmd)
+	I18nCmd.AddCommand(KeyFreezeCmd)
+}
+
+type freezeException struct {
+	Id          string `yaml:"id" json:"id"`
+	Since       string `yaml:"since,omitempty" json:"since,omitempty"`
+	Reason      string `yaml:"reason" json:"reason"`
+	RequestedBy string `yaml:"requested_by" json:"requested_by"`
+	RequestedAt string `yaml:"requested_at" json:"requested_at"`
+	ApprovedBy  string `yaml:"approved_by,omitempty" json:"approved_by,omitempty"`
+	ApprovedAt  string `yaml:"approved_at,omitempty" json:"approved_at,omitempty"`
+}
+
+func (e *freezeException) Approved() bool {
+	return e.ApprovedBy != ""
+}
+
+// Covers tells whether the exception applies to the freeze starting at the
+// revision.
+func (e *freezeException) Covers(since string) bool {
+	return e.Since == "" || e.Since == since
+}
+
+type freezeExceptions struct {
+	Exceptions []*freezeException `yaml:"exceptions"`
+}
+
+func loadFreezeExceptions(xeniaDir string) (*freezeExceptions, error) {
+	exceptions := &freezeExceptions{}
+	data, err := readFile(filepath.Join(xeniaDir, freezeExceptionsFile))
+	if os.IsNotExist(err) {
+		return exceptions, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := yaml.UnmarshalStrict(data, exceptions); err != nil {
+		return nil, fmt.Errorf("Unable to parse %s: %s", freezeExceptionsFile, err.Error())
+	}
+	return exceptions, nil
+}
+
+func (f *freezeExceptions) save(xeniaDir string) error {
+	sort.SliceStable(f.Exceptions, func(i, j int) bool {
+		if f.Exceptions[i].Id != f.Exceptions[j].Id {
+			return f.Exceptions[i].Id < f.Exceptions[j].Id
+		}
+		return f.Exceptions[i].Since < f.Exceptions[j].Since
+	})
+	data, err := yaml.Marshal(f)
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(xeniaDir, freezeExceptionsFile), data, 0644)
+}
+
+func (f *freezeExceptions) find(id, since string) *freezeException {
+	for _, exception := range f.Exceptions {
+		if exception.Id == id && exception.Since == since {
+			return exception
+		}
+	}
+	return nil
+}
+
+// approved returns the approved exceptions of the ids covering the freeze.
+func (f *freezeExceptions) approved(since string) map[string]*freezeException {
+	approved := map[string]*freezeException{}
+	for _, exception := range f.Exceptions {
+		if exception.Approved() && exception.Covers(since) {
+			approved[exception.Id] = exception
+		}
+	}
+	return approved
+}
+
+// gitIdentity returns the "name <email>" identity of the git configuration
+// of the repository.
+func gitIdentity(dir string) (string, error) {
+	name, _ := execCommand("git", "-C", dir, "config", "user.name").Output()
+	email, _ := execCommand("git", "-C", dir, "config", "user.email").Output()
+	identity := strings.TrimSpace(string(name))
+	if identity == "" {
+		return "", errors.New("Unable to read the git identity, set user.name and user.email.")
+	}
+	if trimmed := strings.TrimSpace(string(email)); trimmed != "" {
+		identity += " <" + trimmed + ">"
+	}
+	return identity, nil
+}
+
+func freezeTimestamp() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+func keyFreezeRequestCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	reason, err := command.Flags().GetString("reason")
+	if err != nil || strings.TrimSpace(reason) == "" {
+		return errors.New("Invalid reason parameter")
+	}
+	since, err := command.Flags().GetString("since")
+	if err != nil {
+		return errors.New("Invalid since parameter")
+	}
+	command.SilenceUsage = true
+
+	identity, err := gitIdentity(xeniaDir)
+	if err != nil {
+		return err
+	}
+	exceptions, err := loadFreezeExceptions(xeniaDir)
+	if err != nil {
+		return err
+	}
+	for _, id := range args {
+		if exceptions.find(id, since) != nil {
+			return fmt.Errorf("An exception was already requested for %s.", id)
+		}
+		exceptions.Exceptions = append(exceptions.Exceptions, &freezeException{
+			Id:          id,
+			Since:       since,
+			Reason:      reason,
+			RequestedBy: identity,
+			RequestedAt: freezeTimestamp(),
+		})
+	}
+	if err := exceptions.save(xeniaDir); err != nil {
+		return err
+	}
+	fmt.Printf("%d exceptions requested in %s, commit the file to submit them.\n", len(args), freezeExceptionsFile)
+	return nil
+}
+
+func keyFreezeListCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	pending, err := command.Flags().GetBool("pending")
+	if err != nil {
+		return errors.New("Invalid pending parameter")
+	}
+	format, err := command.Flags().GetString("format")
+	if err != nil {
+		return errors.New("Invalid format parameter")
+	}
+	if format != "text" && format != "json" {
+		return fmt.Errorf("Unknown format %s", format)
+	}
+	command.SilenceUsage = true
+
+	exceptions, err := loadFreezeExceptions(xeniaDir)
+	if err != nil {
+		return err
+	}
+	listed := []*freezeException{}
+	for _, exception := range exceptions.Exceptions {
+		if !pending || !exception.Approved() {
+			listed = append(listed, exception)
+		}
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(listed)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSINCE\tREQUESTED BY\tAPPROVED BY\tREASON")
+	for _, exception := range listed {
+		since := exception.Since
+		if since == "" {
+			since = "-"
+		}
+		approvedBy := exception.ApprovedBy
+		if approvedBy == "" {
+			approvedBy = "pending"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", exception.Id, since, exception.RequestedBy, approvedBy, exception.Reason)
+	}
+	return w.Flush()
+}
+
+func keyFreezeApproveCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	since, err := command.Flags().GetString("since")
+	if err != nil {
+		return errors.New("Invalid since parameter")
+	}
+	command.SilenceUsage = true
+
+	identity, err := gitIdentity(xeniaDir)
+	if err != nil {
+		return err
+	}
+	exceptions, err := loadFreezeExceptions(xeniaDir)
+	if err != nil {
+		return err
+	}
+	for _, id := range args {
+		exception := exceptions.find(id, since)
+		switch {
+		case exception == nil:
+			return fmt.Errorf("No exception was requested for %s.", id)
+		case exception.Approved():
+			return fmt.Errorf("The exception of %s was already approved by %s.", id, exception.ApprovedBy)
+		case exception.RequestedBy == identity:
+			return fmt.Errorf("The exception of %s was requested by %s, it must be approved by someone else.", id, identity)
+		}
+		exception.ApprovedBy = identity
+		exception.ApprovedAt = freezeTimestamp()
+	}
+	if err := exceptions.save(xeniaDir); err != nil {
+		return err
+	}
+	fmt.Printf("%d exceptions approved in %s, commit the file to record the approval.\n", len(args), freezeExceptionsFile)
+	return nil
+}
+
+// frozenChange is a string added or changed after the string freeze.
+type frozenChange struct {
+	Id     string `json:"id"`
+	Change string `json:"change"`
+	// Pending is the requester of an exception waiting for approval.
+	Pending string `json:"pending,omitempty"`
+}
+
+func (c frozenChange) String() string {
+	if c.Pending != "" {
+		return fmt.Sprintf("%s (%s, exception requested by %s is not approved)", c.Id, c.Change, c.Pending)
+	}
+	return fmt.Sprintf("%s (%s)", c.Id, c.Change)
+}
+
+// checkStringFreeze returns the English strings added or changed since the
+// freeze revision, in i18n/en.json or about to be added from the source
+// code, without an approved exception.
+func checkStringFreeze(xeniaDir, since string, translations []Translation, added []string) ([]frozenChange, error) {
+	frozen, _, err := translationsAtRevision(xeniaDir, since)
+	if err != nil {
+		return nil, err
+	}
+	exceptions, err := loadFreezeExceptions(xeniaDir)
+	if err != nil {
+		return nil, err
+	}
+	approved := exceptions.approved(since)
+
+	old := map[string]interface{}{}
+	for _, t := range frozen {
+		old[t.Id] = t.Translation
+	}
+	changes := map[string]string{}
+	for _, t := range translations {
+		if previous, ok := old[t.Id]; !ok {
+			changes[t.Id] = "added"
+		} else if !reflect.DeepEqual(previous, t.Translation) {
+			changes[t.Id] = "changed"
+		}
+	}
+	for _, id := range added {
+		if _, ok := old[id]; !ok {
+			changes[id] = "added"
+		}
+	}
+
+	result := []frozenChange{}
+	for id, change := range changes {
+		if approved[id] != nil {
+			continue
+		}
+		frozenChange := frozenChange{Id: id, Change: change}
+		for _, exception := range exceptions.Exceptions {
+			if exception.Id == id && exception.Covers(since) {
+				frozenChange.Pending = exception.RequestedBy
+			}
+		}
+		result = append(result, frozenChange)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Id < result[j].Id })
+	return result, nil
+}
@@ -0,0 +1,217 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+var MergeCmd = &cobra.Command{
+	Use:   "merge <ours> <theirs> <base>",
+	Short: "Merge translation files id by id",
+	Long: `Merge the changes made to a translation file by two branches since their common ancestor, id by id instead of line by line. The result is written to the ours file, like git merge drivers do.
+
+Every id keeps the side that changed it. When both sides changed it differently, the non-empty translation wins over an empty one or one still holding the placeholder, else the id is a conflict: the ours entry is kept, the conflict is printed and the command fails, unless --prefer picks a side. The ids added by one side and removed by the other are merged the same way.
+
+To merge the translation files with it, declare the driver in the git configuration and the files in .gitattributes:
+
+  git config merge.mmgotool-i18n.name "mmgotool translation files merge"
+  git config merge.mmgotool-i18n.driver "mmgotool i18n merge %A %B %O"
+  echo "i18n/*.json merge=mmgotool-i18n" >> .gitattributes`,
+	Example: `  i18n merge i18n/en.json ../theirs/en.json ../base/en.json --output merged.json
+  i18n merge %A %B %O --prefer theirs`,
+	Args: cobra.ExactArgs(3),
+	RunE: mergeCmdF,
+}
+
+func init() {
+	MergeCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code, for the placeholder setting")
+	MergeCmd.Flags().String("output", "", "Path to the merged file, defaults to the ours file")
+	MergeCmd.Flags().String("prefer", "", "Side winning the conflicts: ours or theirs, they fail the command by default")
+	addPlaceholderFlag(MergeCmd)
+	I18nCmd.AddCommand(MergeCmd)
+}
+
+// mergeConflict is an id changed differently by both sides, nil for the
+// side removing it.
+type mergeConflict struct {
+	Id     string
+	Ours   *Translation
+	Theirs *Translation
+}
+
+func (c mergeConflict) String() string {
+	describe := func(t *Translation) string {
+		if t == nil {
+			return "removed"
+		}
+		return fmt.Sprintf("%v", t.Translation)
+	}
+	return fmt.Sprintf("%s: ours %q, theirs %q", c.Id, describe(c.Ours), describe(c.Theirs))
+}
+
+// readMergedFile reads a side of the merge, git passing an empty file for
+// the ancestor of the files added by both branches.
+func readMergedFile(filePath string) (map[string]Translation, error) {
+	entries := map[string]Translation{}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() == 0 {
+		return entries, nil
+	}
+	translations, err := readTranslationsFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range translations {
+		entries[t.Id] = t
+	}
+	return entries, nil
+}
+
+// mergeTranslationFiles merges the entries of both sides, returning the
+// merged translations sorted by id and the conflicts, resolved with the ours
+// entry.
+func mergeTranslationFiles(ours, theirs, base map[string]Translation, placeholder string) ([]Translation, []mergeConflict) {
+	ids := map[string]bool{}
+	for _, entries := range []map[string]Translation{ours, theirs, base} {
+		for id := range entries {
+			ids[id] = true
+		}
+	}
+	lookup := func(entries map[string]Translation, id string) *Translation {
+		if t, ok := entries[id]; ok {
+			return &t
+		}
+		return nil
+	}
+	empty := func(t *Translation) bool {
+		return t == nil || t.Translation == nil || t.Translation == "" || t.Translation == placeholderText(placeholder, t.Id)
+	}
+
+	merged := []Translation{}
+	conflicts := []mergeConflict{}
+	for id := range ids {
+		o, t, b := lookup(ours, id), lookup(theirs, id), lookup(base, id)
+		var result *Translation
+		switch {
+		case reflect.DeepEqual(o, t), reflect.DeepEqual(t, b):
+			result = o
+		case reflect.DeepEqual(o, b):
+			result = t
+		case o != nil && t != nil && empty(o) && !empty(t):
+			result = t
+		case o != nil && t != nil && empty(t) && !empty(o):
+			result = o
+		default:
+			conflicts = append(conflicts, mergeConflict{Id: id, Ours: o, Theirs: t})
+			result = o
+		}
+		if result != nil {
+			merged = append(merged, *result)
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Id < merged[j].Id })
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Id < conflicts[j].Id })
+	return merged, conflicts
+}
+
+// resolveMergeConflicts replaces the ours entry of the conflicts with the
+// theirs one.
+func resolveMergeConflicts(merged []Translation, conflicts []mergeConflict) []Translation {
+	theirs := map[string]*Translation{}
+	for _, conflict := range conflicts {
+		theirs[conflict.Id] = conflict.Theirs
+	}
+	resolved := []Translation{}
+	ids := map[string]bool{}
+	for _, t := range merged {
+		ids[t.Id] = true
+		if entry, ok := theirs[t.Id]; ok {
+			if entry != nil {
+				resolved = append(resolved, *entry)
+			}
+			continue
+		}
+		resolved = append(resolved, t)
+	}
+	// The ids removed by ours and changed by theirs.
+	for _, conflict := range conflicts {
+		if !ids[conflict.Id] && conflict.Theirs != nil {
+			resolved = append(resolved, *conflict.Theirs)
+		}
+	}
+	sort.Slice(resolved, func(i, j int) bool { return resolved[i].Id < resolved[j].Id })
+	return resolved
+}
+
+func mergeCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	output, err := command.Flags().GetString("output")
+	if err != nil {
+		return errors.New("Invalid output parameter")
+	}
+	prefer, err := command.Flags().GetString("prefer")
+	if err != nil {
+		return errors.New("Invalid prefer parameter")
+	}
+	if prefer != "" && prefer != "ours" && prefer != "theirs" {
+		return fmt.Errorf("Unknown side %s, use ours or theirs", prefer)
+	}
+	placeholder, err := getPlaceholder(command, xeniaDir)
+	if err != nil {
+		return err
+	}
+	command.SilenceUsage = true
+
+	oursFile, theirsFile, baseFile := args[0], args[1], args[2]
+	if output == "" {
+		output = oursFile
+	}
+	ours, err := readMergedFile(oursFile)
+	if err != nil {
+		return err
+	}
+	theirs, err := readMergedFile(theirsFile)
+	if err != nil {
+		return err
+	}
+	base, err := readMergedFile(baseFile)
+	if err != nil {
+		return err
+	}
+
+	merged, conflicts := mergeTranslationFiles(ours, theirs, base, placeholder)
+	if prefer == "theirs" {
+		merged = resolveMergeConflicts(merged, conflicts)
+	}
+	// The merged file keeps the format of the ours file.
+	data, err := encodeCatalog(merged, catalogFileFormat(oursFile))
+	if err != nil {
+		return err
+	}
+	if err := writeFile(output, data, 0644); err != nil {
+		return err
+	}
+	logger.Info("Merged the translation files", "path", output, "ids", len(merged), "conflicts", len(conflicts))
+
+	if len(conflicts) == 0 || prefer != "" {
+		return nil
+	}
+	for _, conflict := range conflicts {
+		fmt.Fprintln(os.Stderr, "Conflict:", conflict.String())
+	}
+	return &ExitError{Code: 1, Err: fmt.Errorf("%d ids changed differently by both sides, the ours translations were kept in %s.", len(conflicts), output)}
+}
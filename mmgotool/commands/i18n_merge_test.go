@@ -0,0 +1,139 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// absent marks an id missing from a side of the merge.
+const absent = "<absent>"
+
+func mergeTestSide(translation string) map[string]Translation {
+	if translation == absent {
+		return map[string]Translation{}
+	}
+	return map[string]Translation{"app.x": {Id: "app.x", Translation: translation}}
+}
+
+func TestMergeTranslationFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     string
+		ours     string
+		theirs   string
+		merged   string
+		conflict bool
+	}{
+		{name: "unchanged", base: "Save", ours: "Save", theirs: "Save", merged: "Save"},
+		{name: "changed by ours", base: "Save", ours: "Save it", theirs: "Save", merged: "Save it"},
+		{name: "changed by theirs", base: "Save", ours: "Save", theirs: "Save it", merged: "Save it"},
+		{name: "changed the same way", base: "Save", ours: "Save it", theirs: "Save it", merged: "Save it"},
+		{name: "added by ours", base: absent, ours: "Save", theirs: absent, merged: "Save"},
+		{name: "added by theirs", base: absent, ours: absent, theirs: "Save", merged: "Save"},
+		{name: "added the same way", base: absent, ours: "Save", theirs: "Save", merged: "Save"},
+		{name: "removed by ours", base: "Save", ours: absent, theirs: "Save", merged: absent},
+		{name: "removed by theirs", base: "Save", ours: "Save", theirs: absent, merged: absent},
+		{name: "removed by both", base: "Save", ours: absent, theirs: absent, merged: absent},
+		{name: "ours holding the placeholder", base: "app.x", ours: "app.x", theirs: "Save", merged: "Save"},
+		{name: "placeholder against a translation", base: "Save", ours: "app.x", theirs: "Store", merged: "Store"},
+		{name: "theirs empty", base: "Save", ours: "Store", theirs: "", merged: "Store"},
+		{name: "changed differently", base: "Save", ours: "Save it", theirs: "Store", merged: "Save it", conflict: true},
+		{name: "added differently", base: absent, ours: "Save", theirs: "Store", merged: "Save", conflict: true},
+		{name: "removed by ours and changed by theirs", base: "Save", ours: absent, theirs: "Store", merged: absent, conflict: true},
+		{name: "changed by ours and removed by theirs", base: "Save", ours: "Store", theirs: absent, merged: "Store", conflict: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			merged, conflicts := mergeTranslationFiles(mergeTestSide(test.ours), mergeTestSide(test.theirs), mergeTestSide(test.base), defaultPlaceholder)
+			expected := []Translation{}
+			for _, translation := range mergeTestSide(test.merged) {
+				expected = append(expected, translation)
+			}
+			if !reflect.DeepEqual(merged, expected) {
+				t.Errorf("merged %+v, want %+v", merged, expected)
+			}
+			if conflict := len(conflicts) > 0; conflict != test.conflict {
+				t.Errorf("conflict %v, want %v", conflict, test.conflict)
+			}
+		})
+	}
+}
+
+func TestResolveMergeConflicts(t *testing.T) {
+	ours := map[string]Translation{
+		"app.changed": {Id: "app.changed", Translation: "Save it"},
+		"app.kept":    {Id: "app.kept", Translation: "Cancel"},
+		"app.removed": {Id: "app.removed", Translation: "Close it"},
+	}
+	theirs := map[string]Translation{
+		"app.changed":   {Id: "app.changed", Translation: "Store"},
+		"app.kept":      {Id: "app.kept", Translation: "Cancel"},
+		"app.recreated": {Id: "app.recreated", Translation: "Open it"},
+	}
+	base := map[string]Translation{
+		"app.changed":   {Id: "app.changed", Translation: "Save"},
+		"app.kept":      {Id: "app.kept", Translation: "Cancel"},
+		"app.removed":   {Id: "app.removed", Translation: "Close"},
+		"app.recreated": {Id: "app.recreated", Translation: "Open"},
+	}
+	merged, conflicts := mergeTranslationFiles(ours, theirs, base, defaultPlaceholder)
+	if len(conflicts) != 3 {
+		t.Fatalf("got conflicts %v, want the changed, recreated and removed ids", conflicts)
+	}
+	expected := []Translation{
+		{Id: "app.changed", Translation: "Store"},
+		{Id: "app.kept", Translation: "Cancel"},
+		{Id: "app.recreated", Translation: "Open it"},
+	}
+	if resolved := resolveMergeConflicts(merged, conflicts); !reflect.DeepEqual(resolved, expected) {
+		t.Errorf("resolved %+v, want %+v", resolved, expected)
+	}
+}
+
+func TestMergeCmdExitCode(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.json")
+	theirs := filepath.Join(dir, "theirs.json")
+	writeTestFile(t, base, `[{"id": "app.x", "translation": "Save"}]`)
+	writeTestFile(t, theirs, `[{"id": "app.x", "translation": "Store"}]`)
+
+	tests := []struct {
+		prefer string
+		code   int
+		merged string
+	}{
+		{prefer: "", code: 1, merged: "Save it"},
+		{prefer: "ours", code: 0, merged: "Save it"},
+		{prefer: "theirs", code: 0, merged: "Store"},
+	}
+	for _, test := range tests {
+		t.Run("prefer "+test.prefer, func(t *testing.T) {
+			ours := filepath.Join(t.TempDir(), "ours.json")
+			writeTestFile(t, ours, `[{"id": "app.x", "translation": "Save it"}]`)
+			RootCmd.SetArgs([]string{"i18n", "merge", ours, theirs, base, "--xenia-dir", dir, "--output=", "--prefer=" + test.prefer})
+			code := 0
+			if err := RootCmd.Execute(); err != nil {
+				code = ExitCode(err)
+			}
+			if code != test.code {
+				t.Errorf("exit code %d, want %d", code, test.code)
+			}
+			data, err := ioutil.ReadFile(ours)
+			if err != nil {
+				t.Fatal(err)
+			}
+			translations, err := decodeTranslations(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(translations) != 1 || translations[0].Translation != test.merged {
+				t.Errorf("merged %+v, want %s", translations, test.merged)
+			}
+		})
+	}
+}
@@ -0,0 +1,355 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	deeplKeyEnv  = "DEEPL_API_KEY"
+	googleKeyEnv = "GOOGLE_TRANSLATE_API_KEY"
+	// mtBatchSize is the number of strings sent in a provider request.
+	mtBatchSize = 50
+)
+
+var MtFillCmd = &cobra.Command{
+	Use:   "mt-fill",
+	Short: "Pre-fill the empty translations of a locale with machine translation",
+	Long: `Translate the English strings missing or empty in the file of a locale with DeepL or Google Translate, to bootstrap a new language.
+
+The machine translations are marked with "fuzzy": true for the translators to review them. Existing translations, reviewed or not, are never overwritten. Placeholders and markup are protected from the provider, a translation losing them is skipped. Plural strings are skipped, they need the plural forms of the locale.
+
+The file is written when every batch of strings is translated, or before failing with the batches already translated when the provider fails.
+
+The API key is read from $` + deeplKeyEnv + ` or $` + googleKeyEnv + `.`,
+	Example: `  i18n mt-fill --locale de --provider deepl
+  i18n mt-fill --locale pt-BR --provider google --limit 200 --dry-run`,
+	RunE: mtFillCmdF,
+}
+
+func init() {
+	MtFillCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	MtFillCmd.Flags().String("locale", "", "Locale of the translation file to fill")
+	MtFillCmd.Flags().String("provider", "deepl", "Machine translation provider: deepl or google")
+	MtFillCmd.Flags().Int("limit", 0, "Maximum number of strings to translate, 0 for all")
+	MtFillCmd.Flags().Bool("dry-run", false, "List the strings to translate without calling the provider")
+	I18nCmd.AddCommand(MtFillCmd)
+}
+
+// machineTranslator translates English texts into a locale, the texts
+// being HTML fragments whose tags are kept.
+type machineTranslator interface {
+	Translate(texts []string, locale string) ([]string, error)
+}
+
+type deeplTranslator struct {
+	client *http.Client
+	key    string
+}
+
+// deeplLanguage returns the DeepL target language of a locale.
+func deeplLanguage(locale string) string {
+	switch strings.ToLower(locale) {
+	case "zh-cn", "zh":
+		return "ZH-HANS"
+	case "zh-tw":
+		return "ZH-HANT"
+	case "pt":
+		return "PT-PT"
+	case "en":
+		return "EN-US"
+	}
+	return strings.ToUpper(locale)
+}
+
+func (t *deeplTranslator) Translate(texts []string, locale string) ([]string, error) {
+	endpoint := "https://api.deepl.com/v2/translate"
+	// The keys of the free plan end with :fx and have their own endpoint.
+	if strings.HasSuffix(t.key, ":fx") {
+		endpoint = "https://api-free.deepl.com/v2/translate"
+	}
+	payload := map[string]interface{}{
+		"text":         texts,
+		"source_lang":  "EN",
+		"target_lang":  deeplLanguage(locale),
+		"tag_handling": "html",
+	}
+	var result struct {
+		Translations []struct {
+			Text string `json:"text"`
+		} `json:"translations"`
+	}
+	if err := postMachineTranslation(t.client, endpoint, "DeepL-Auth-Key "+t.key, payload, &result); err != nil {
+		return nil, err
+	}
+	translated := []string{}
+	for _, translation := range result.Translations {
+		translated = append(translated, translation.Text)
+	}
+	return translated, nil
+}
+
+type googleTranslator struct {
+	client *http.Client
+	key    string
+}
+
+func (t *googleTranslator) Translate(texts []string, locale string) ([]string, error) {
+	payload := map[string]interface{}{
+		"q":      texts,
+		"source": "en",
+		"target": locale,
+		"format": "html",
+	}
+	var result struct {
+		Data struct {
+			Translations []struct {
+				TranslatedText string `json:"translatedText"`
+			} `json:"translations"`
+		} `json:"data"`
+	}
+	endpoint := "https://translation.googleapis.com/language/translate/v2?key=" + url.QueryEscape(t.key)
+	if err := postMachineTranslation(t.client, endpoint, "", payload, &result); err != nil {
+		return nil, err
+	}
+	translated := []string{}
+	for _, translation := range result.Data.Translations {
+		translated = append(translated, translation.TranslatedText)
+	}
+	return translated, nil
+}
+
+func postMachineTranslation(client *http.Client, endpoint, authorization string, payload, result interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if authorization != "" {
+		request.Header.Set("Authorization", authorization)
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("Machine translation request failed with %s: %s", response.Status, strings.TrimSpace(string(data)))
+	}
+	return json.Unmarshal(data, result)
+}
+
+func newMachineTranslator(provider string) (machineTranslator, error) {
+	client := &http.Client{Timeout: time.Minute}
+	switch provider {
+	case "deepl":
+		key := os.Getenv(deeplKeyEnv)
+		if key == "" {
+			return nil, fmt.Errorf("Set the DeepL API key in $%s.", deeplKeyEnv)
+		}
+		return &deeplTranslator{client: client, key: key}, nil
+	case "google":
+		key := os.Getenv(googleKeyEnv)
+		if key == "" {
+			return nil, fmt.Errorf("Set the Google Translate API key in $%s.", googleKeyEnv)
+		}
+		return &googleTranslator{client: client, key: key}, nil
+	}
+	return nil, fmt.Errorf("Unknown provider %s", provider)
+}
+
+var mtProtectedTagRegexp = regexp.MustCompile(`<x id="(\d+)"\s*/?>(</x>)?`)
+
+// protectPlaceholders turns the text into an HTML fragment where the
+// placeholders and the markup are <x id="N"/> tags the providers keep.
+func protectPlaceholders(text string) (string, []string) {
+	var buf strings.Builder
+	protected := []string{}
+	last := 0
+	for _, match := range pseudoProtectedRegexp.FindAllStringIndex(text, -1) {
+		buf.WriteString(html.EscapeString(text[last:match[0]]))
+		fmt.Fprintf(&buf, `<x id="%d"/>`, len(protected))
+		protected = append(protected, text[match[0]:match[1]])
+		last = match[1]
+	}
+	buf.WriteString(html.EscapeString(text[last:]))
+	return buf.String(), protected
+}
+
+// restorePlaceholders reverses protectPlaceholders on the translated
+// fragment.
+func restorePlaceholders(fragment string, protected []string) string {
+	var buf strings.Builder
+	last := 0
+	for _, match := range mtProtectedTagRegexp.FindAllStringSubmatchIndex(fragment, -1) {
+		buf.WriteString(html.UnescapeString(fragment[last:match[0]]))
+		if n, err := strconv.Atoi(fragment[match[2]:match[3]]); err == nil && n < len(protected) {
+			buf.WriteString(protected[n])
+		}
+		last = match[1]
+	}
+	buf.WriteString(html.UnescapeString(fragment[last:]))
+	return buf.String()
+}
+
+func mtFillCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	locale, err := command.Flags().GetString("locale")
+	if err != nil || !isLocaleTag(locale) || locale == "en" {
+		return errors.New("Invalid locale parameter")
+	}
+	provider, err := command.Flags().GetString("provider")
+	if err != nil {
+		return errors.New("Invalid provider parameter")
+	}
+	limit, err := command.Flags().GetInt("limit")
+	if err != nil || limit < 0 {
+		return errors.New("Invalid limit parameter")
+	}
+	dryRun, err := command.Flags().GetBool("dry-run")
+	if err != nil {
+		return errors.New("Invalid dry-run parameter")
+	}
+	var translator machineTranslator
+	if !dryRun {
+		if translator, err = newMachineTranslator(provider); err != nil {
+			return err
+		}
+	}
+	command.SilenceUsage = true
+
+	source, err := readTranslationsFile(filepath.Join(xeniaDir, "i18n", "en.json"))
+	if err != nil {
+		return err
+	}
+	localeFile := filepath.Join(xeniaDir, "i18n", locale+".json")
+	translations := []Translation{}
+	if _, err := os.Stat(localeFile); err == nil {
+		if translations, err = readTranslationsFile(localeFile); err != nil {
+			return err
+		}
+	}
+	existing := map[string]int{}
+	for i, t := range translations {
+		existing[t.Id] = i
+	}
+
+	pending := []Translation{}
+	plurals := 0
+	for _, t := range source {
+		if i, ok := existing[t.Id]; ok && !isEmptyTranslation(translations[i].Translation) {
+			continue
+		}
+		text, ok := t.Translation.(string)
+		if !ok {
+			plurals++
+			continue
+		}
+		if text == "" {
+			continue
+		}
+		pending = append(pending, t)
+	}
+	if limit > 0 && len(pending) > limit {
+		pending = pending[:limit]
+	}
+	if plurals > 0 {
+		fmt.Printf("Skipped %d plural strings, translate them by hand.\n", plurals)
+	}
+	if dryRun {
+		for _, t := range pending {
+			fmt.Println("Translate:", t.Id)
+		}
+		fmt.Printf("%d strings to translate into %s.\n", len(pending), locale)
+		return nil
+	}
+
+	sorted := sort.SliceIsSorted(translations, func(i, j int) bool { return translations[i].Id < translations[j].Id })
+	save := func() error {
+		if sorted {
+			sort.SliceStable(translations, func(i, j int) bool { return translations[i].Id < translations[j].Id })
+		}
+		return writeTranslationsFile(localeFile, translations)
+	}
+	// failed writes the strings already translated, the provider requests
+	// being paid for, before returning the error.
+	filled := 0
+	failed := func(err error) error {
+		if filled == 0 {
+			return err
+		}
+		if saveErr := save(); saveErr != nil {
+			return saveErr
+		}
+		fmt.Printf("Filled %d of %d strings of %s before the error, marked as fuzzy.\n", filled, len(pending), localeFile)
+		return err
+	}
+	for start := 0; start < len(pending); start += mtBatchSize {
+		end := start + mtBatchSize
+		if end > len(pending) {
+			end = len(pending)
+		}
+		batch := pending[start:end]
+		fragments := make([]string, len(batch))
+		protected := make([][]string, len(batch))
+		for i, t := range batch {
+			fragments[i], protected[i] = protectPlaceholders(t.Translation.(string))
+		}
+		translated, err := translator.Translate(fragments, locale)
+		if err != nil {
+			return failed(err)
+		}
+		if len(translated) != len(batch) {
+			return failed(fmt.Errorf("The provider returned %d translations for %d strings.", len(translated), len(batch)))
+		}
+		for i, t := range batch {
+			text := restorePlaceholders(translated[i], protected[i])
+			if missing, extra := comparePlaceholders(extractPlaceholders(t.Translation.(string)), extractPlaceholders(text)); len(missing) > 0 || len(extra) > 0 {
+				fmt.Printf("Skipped %s, the translation changed its placeholders\n", t.Id)
+				continue
+			}
+			filled++
+			if idx, ok := existing[t.Id]; ok {
+				translations[idx].Translation = text
+				translations[idx].Fuzzy = true
+				continue
+			}
+			existing[t.Id] = len(translations)
+			translations = append(translations, Translation{Id: t.Id, Translation: text, Fuzzy: true})
+		}
+	}
+	if err := save(); err != nil {
+		return err
+	}
+	fmt.Printf("Filled %d of %d strings of %s, marked as fuzzy.\n", filled, len(pending), localeFile)
+	return nil
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// noticePrefix is the prefix of the translation ids used by the "About"
+// dialog to display the third party notices.
+const noticePrefix = "about.notice."
+
+var noticeIdInvalidChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+type thirdPartyNotice struct {
+	Name        string
+	Attribution string
+	License     string
+}
+
+func (n *thirdPartyNotice) Id() string {
+	return noticePrefix + strings.Trim(noticeIdInvalidChars.ReplaceAllString(strings.ToLower(n.Name), "_"), "_")
+}
+
+func (n *thirdPartyNotice) Text() string {
+	text := n.Attribution
+	if text == "" {
+		text = fmt.Sprintf("This product contains '%s'.", n.Name)
+	}
+	if n.License != "" {
+		text += " License: " + n.License + "."
+	}
+	return text
+}
+
+var SyncNoticesCmd = &cobra.Command{
+	Use:     "sync-notices",
+	Short:   "Synchronize the third party notices translations",
+	Long:    "Regenerate the " + noticePrefix + "* translations of the i18n/en.json file from the NOTICE.txt file, adding, updating and removing entries so they match the current dependencies",
+	Example: "  i18n sync-notices --notice-file NOTICE.txt",
+	RunE:    syncNoticesCmdF,
+}
+
+func init() {
+	SyncNoticesCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	SyncNoticesCmd.Flags().String("notice-file", "", "Path to the notices file (defaults to NOTICE.txt in the Xenia folder)")
+	SyncNoticesCmd.Flags().Bool("dry-run", false, "Print the changes without modifying i18n/en.json")
+	I18nCmd.AddCommand(SyncNoticesCmd)
+}
+
+// parseNotices reads the third party components listed in a NOTICE.txt file.
+// Every component starts with a "## name" heading, followed by its
+// attribution sentence and a "* LICENSE: name" line.
+func parseNotices(noticeFile string) ([]*thirdPartyNotice, error) {
+	f, err := openFile(noticeFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	notices := []*thirdPartyNotice{}
+	var current *thirdPartyNotice
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "## "):
+			current = &thirdPartyNotice{Name: strings.TrimSpace(strings.TrimPrefix(line, "## "))}
+			notices = append(notices, current)
+		case current == nil:
+			continue
+		case strings.HasPrefix(line, "This product contains") && current.Attribution == "":
+			current.Attribution = line
+		case strings.HasPrefix(line, "* LICENSE:") && current.License == "":
+			current.License = strings.TrimSpace(strings.TrimPrefix(line, "* LICENSE:"))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return notices, nil
+}
+
+func noticeFilePath(xeniaDir, noticeFile string) string {
+	if noticeFile != "" {
+		return noticeFile
+	}
+	return path.Join(xeniaDir, "NOTICE.txt")
+}
+
+// addNoticeStrings marks the notices translations as used so extract doesn't
+// remove them. Trees without a NOTICE.txt file are ignored.
+func addNoticeStrings(xeniaDir string, i18nStrings *map[string]bool) {
+	notices, err := parseNotices(noticeFilePath(xeniaDir, ""))
+	if err != nil {
+		return
+	}
+	for _, notice := range notices {
+		(*i18nStrings)[notice.Id()] = true
+	}
+}
+
+func syncNoticesCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	noticeFile, err := command.Flags().GetString("notice-file")
+	if err != nil {
+		return errors.New("Invalid notice-file parameter")
+	}
+	dryRun, err := command.Flags().GetBool("dry-run")
+	if err != nil {
+		return errors.New("Invalid dry-run parameter")
+	}
+
+	notices, err := parseNotices(noticeFilePath(xeniaDir, noticeFile))
+	if err != nil {
+		return err
+	}
+	expected := map[string]string{}
+	for _, notice := range notices {
+		expected[notice.Id()] = notice.Text()
+	}
+
+	translations, err := getCurrentTranslations(xeniaDir)
+	if err != nil {
+		return err
+	}
+
+	changes := 0
+	result := []Translation{}
+	seen := map[string]bool{}
+	for _, t := range translations {
+		if !strings.HasPrefix(t.Id, noticePrefix) {
+			result = append(result, t)
+			continue
+		}
+		text, ok := expected[t.Id]
+		if !ok {
+			fmt.Println("Removed:", t.Id)
+			changes++
+			continue
+		}
+		seen[t.Id] = true
+		if t.Translation != text {
+			fmt.Println("Updated:", t.Id)
+			changes++
+		}
+		result = append(result, Translation{Id: t.Id, Translation: text})
+	}
+	for _, notice := range notices {
+		id := notice.Id()
+		if !seen[id] {
+			fmt.Println("Added:", id)
+			changes++
+			seen[id] = true
+			result = append(result, Translation{Id: id, Translation: expected[id]})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Id < result[j].Id })
+
+	if changes == 0 || dryRun {
+		return nil
+	}
+	return writeEnglishTranslations(xeniaDir, result, enterpriseCatalogSplit(xeniaDir))
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+var ApplyOverridesCmd = &cobra.Command{
+	Use:   "apply-overrides",
+	Short: "Apply deployment specific translation overrides",
+	Long: `Validate the override files of a deployment and merge them into deployable locale files.
+
+Every file of the overrides folder is named after its locale (es.json, pt-BR.json...) and maps translation ids to their new values.
+The ids must exist in i18n/en.json and the values must keep the placeholders of the English string.
+
+The overrides and the merged files can also be kept in an S3 bucket, with s3://bucket/prefix, or in a Postgres table, with a postgres:// URL, for deployments managing their overrides centrally.`,
+	Example: `  i18n apply-overrides --overrides-dir ./customer/overrides --output-dir ./dist/i18n
+  i18n apply-overrides --overrides-dir s3://customer-config/i18n/overrides --output-dir s3://customer-config/i18n/dist`,
+	RunE: applyOverridesCmdF,
+}
+
+func init() {
+	ApplyOverridesCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	ApplyOverridesCmd.Flags().String("overrides-dir", "", "Location of the override files: "+catalogStoreHelp)
+	ApplyOverridesCmd.Flags().String("output-dir", "", "Location where the merged locale files are written: "+catalogStoreHelp)
+	I18nCmd.AddCommand(ApplyOverridesCmd)
+}
+
+type localeOverrides struct {
+	Locale       string
+	Translations map[string]interface{}
+}
+
+// loadOverrides reads the override catalog of every locale and validates it
+// against the English source strings.
+func loadOverrides(overridesDir string, source map[string]interface{}) ([]*localeOverrides, []string, error) {
+	store, err := openCatalogStore(overridesDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	locales, err := store.Locales()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	overrides := []*localeOverrides{}
+	problems := []string{}
+	for _, locale := range locales {
+		catalog, err := store.Load(locale)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, id := range catalog.Ids() {
+			sourceValue, ok := source[id]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s: %s: unknown translation id", locale, id))
+				continue
+			}
+			missing, extra := comparePlaceholders(translationPlaceholders(sourceValue), translationPlaceholders(catalog.Translations[id]))
+			mismatch := placeholderMismatch{Id: id, Locale: locale, Missing: missing, Extra: extra}
+			if len(missing) > 0 || len(extra) > 0 {
+				problems = append(problems, mismatch.String())
+			}
+		}
+		overrides = append(overrides, &localeOverrides{Locale: locale, Translations: catalog.Translations})
+	}
+	return overrides, problems, nil
+}
+
+// mergeOverrides replaces the values of the translations, adding the
+// overridden ids the locale doesn't translate yet.
+func mergeOverrides(translations []Translation, overrides map[string]interface{}) []Translation {
+	merged := []Translation{}
+	seen := map[string]bool{}
+	for _, t := range translations {
+		if value, ok := overrides[t.Id]; ok {
+			t.Translation = value
+		}
+		seen[t.Id] = true
+		merged = append(merged, t)
+	}
+	for id, value := range overrides {
+		if !seen[id] {
+			merged = append(merged, Translation{Id: id, Translation: value})
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Id < merged[j].Id })
+	return merged
+}
+
+func applyOverridesCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	overridesDir, err := command.Flags().GetString("overrides-dir")
+	if err != nil || overridesDir == "" {
+		return errors.New("Invalid overrides-dir parameter")
+	}
+	outputDir, err := command.Flags().GetString("output-dir")
+	if err != nil || outputDir == "" {
+		return errors.New("Invalid output-dir parameter")
+	}
+
+	source, err := sourceTranslations(xeniaDir)
+	if err != nil {
+		return err
+	}
+	overrides, problems, err := loadOverrides(overridesDir, source)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		command.SilenceUsage = true
+		return fmt.Errorf("%d invalid overrides.", len(problems))
+	}
+
+	overridesByLocale := map[string]map[string]interface{}{}
+	for _, o := range overrides {
+		overridesByLocale[o.Locale] = o.Translations
+	}
+
+	files, err := localeFiles(xeniaDir)
+	if err != nil {
+		return err
+	}
+	files = append([]string{path.Join(xeniaDir, "i18n", "en.json")}, files...)
+
+	output, err := openCatalogStore(outputDir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		translations, err := readTranslationsFile(file)
+		if err != nil {
+			return err
+		}
+		locale := localeName(file)
+		merged := mergeOverrides(translations, overridesByLocale[locale])
+		delete(overridesByLocale, locale)
+		data, err := encodeCatalog(merged, catalogFileFormat(file))
+		if err != nil {
+			return err
+		}
+		if err := output.Save(locale, data); err != nil {
+			return err
+		}
+	}
+
+	for _, o := range overrides {
+		if _, ignored := overridesByLocale[o.Locale]; ignored {
+			logger.Warn("Overrides ignored, the locale has no translations file", "locale", o.Locale)
+		}
+	}
+	return nil
+}
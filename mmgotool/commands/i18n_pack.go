@@ -0,0 +1,167 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+const packManifestFile = "manifest.json"
+
+var PackCmd = &cobra.Command{
+	Use:   "pack",
+	Short: "Pack the locale files with content hashes",
+	Long: `Minify every translation file of i18n and write it under a name containing the hash of its content, like de.3f2a9c1d.json, with a manifest.json mapping every locale to its file.
+
+The hashed names change only when the translations change, so the files can be served by a CDN and cached by the clients forever. The manifest also has the size and the subresource integrity of every file.
+
+With --sign-key, the manifest is signed in the minisign format, authenticating every file it lists. Verify it with i18n verify or minisign.`,
+	Example: `  i18n pack --output-dir dist/i18n
+  i18n pack --output-dir dist/i18n --hash-length 12 --no-minify
+  i18n pack --output-dir dist/i18n --sign-key release.key`,
+	RunE: packCmdF,
+}
+
+func init() {
+	PackCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	PackCmd.Flags().String("output-dir", "", "Folder of the hashed locale files and of the manifest")
+	PackCmd.Flags().Int("hash-length", 8, "Number of hexadecimal digits of the hash in the file names")
+	PackCmd.Flags().Bool("no-minify", false, "Keep the locale files as they are instead of minifying them")
+	PackCmd.Flags().Bool("dry-run", false, "Print the manifest without writing anything")
+	PackCmd.Flags().String("sign-key", "", "Sign the manifest with this private key, generated by i18n keygen")
+	I18nCmd.AddCommand(PackCmd)
+}
+
+type packedLocale struct {
+	File      string `json:"file"`
+	Hash      string `json:"hash"`
+	Integrity string `json:"integrity"`
+	Size      int    `json:"size"`
+}
+
+type packManifest struct {
+	Locales map[string]packedLocale `json:"locales"`
+}
+
+// packLocale returns the content served for a locale file and its manifest
+// entry.
+func packLocale(file string, minify bool, hashLength int) ([]byte, packedLocale, error) {
+	data, err := readFile(file)
+	if err != nil {
+		return nil, packedLocale{}, err
+	}
+	if minify {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, data); err != nil {
+			return nil, packedLocale{}, fmt.Errorf("Unable to minify %s: %s", file, err.Error())
+		}
+		data = buf.Bytes()
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	if hashLength < len(hash) {
+		hash = hash[:hashLength]
+	}
+	locale := localeName(file)
+	return data, packedLocale{
+		File:      fmt.Sprintf("%s.%s.json", locale, hash),
+		Hash:      hash,
+		Integrity: "sha256-" + base64.StdEncoding.EncodeToString(sum[:]),
+		Size:      len(data),
+	}, nil
+}
+
+func packCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	outputDir, err := command.Flags().GetString("output-dir")
+	if err != nil {
+		return errors.New("Invalid output-dir parameter")
+	}
+	hashLength, err := command.Flags().GetInt("hash-length")
+	if err != nil || hashLength < 4 {
+		return errors.New("Invalid hash-length parameter")
+	}
+	noMinify, err := command.Flags().GetBool("no-minify")
+	if err != nil {
+		return errors.New("Invalid no-minify parameter")
+	}
+	dryRun, err := command.Flags().GetBool("dry-run")
+	if err != nil {
+		return errors.New("Invalid dry-run parameter")
+	}
+	signKey, err := command.Flags().GetString("sign-key")
+	if err != nil {
+		return errors.New("Invalid sign-key parameter")
+	}
+	if outputDir == "" && !dryRun {
+		return errors.New("Invalid output-dir parameter")
+	}
+	if signKey != "" && dryRun {
+		return errors.New("Signing the manifest requires writing it, it can't be used with --dry-run")
+	}
+	command.SilenceUsage = true
+
+	files, err := filepath.Glob(filepath.Join(xeniaDir, "i18n", "*.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	if len(files) == 0 {
+		return fmt.Errorf("No translation file found in %s.", filepath.Join(xeniaDir, "i18n"))
+	}
+
+	manifest := packManifest{Locales: map[string]packedLocale{}}
+	contents := map[string][]byte{}
+	for _, file := range files {
+		data, packed, err := packLocale(file, !noMinify, hashLength)
+		if err != nil {
+			return err
+		}
+		manifest.Locales[localeName(file)] = packed
+		contents[packed.File] = data
+	}
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	manifestData = append(manifestData, '\n')
+
+	if dryRun {
+		_, err = os.Stdout.Write(manifestData)
+		return err
+	}
+	if err := mkdirAll(outputDir, 0755); err != nil {
+		return err
+	}
+	for name, data := range contents {
+		if err := writeFile(filepath.Join(outputDir, name), data, 0644); err != nil {
+			return err
+		}
+	}
+	manifestPath := filepath.Join(outputDir, packManifestFile)
+	if err := writeFile(manifestPath, manifestData, 0644); err != nil {
+		return err
+	}
+	if signKey != "" {
+		if err := signArtifact(manifestPath, signKey); err != nil {
+			return err
+		}
+	}
+	logger.Info("Packed the locale files", "path", outputDir, "locales", len(manifest.Locales))
+	return nil
+}
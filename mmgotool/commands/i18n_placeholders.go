@@ -0,0 +1,187 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var ValidatePlaceholdersCmd = &cobra.Command{
+	Use:   "validate-placeholders",
+	Short: "Validate translation placeholders",
+	Long: `Check that every locale translation uses the same {{.Name}} and printf style placeholders as the English string.
+
+The translations using placeholders the English string lacks break when rendered, the ones dropping placeholders lose details. Only the classes of --fail-on fail the check, the others are reported as warnings.
+
+Exit codes:
+` + validatePlaceholdersClasses.exitCodesHelp(),
+	Example: "  i18n validate-placeholders --locale es --locale fr",
+	RunE:    runCheck(validatePlaceholdersCmdF),
+}
+
+func init() {
+	ValidatePlaceholdersCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	ValidatePlaceholdersCmd.Flags().StringSlice("locale", []string{}, "Only validate these locales (defaults to all)")
+	addFindingsFormatFlag(ValidatePlaceholdersCmd)
+	validatePlaceholdersClasses.addFailOnFlag(ValidatePlaceholdersCmd, validatePlaceholdersClasses.names())
+	I18nCmd.AddCommand(ValidatePlaceholdersCmd)
+}
+
+var placeholderMismatchRule = registerFindingRule(findingRule{Id: "i18n/placeholder-mismatch", Description: "The translation does not use the placeholders of the English string", Command: "i18n validate-placeholders"})
+
+// validatePlaceholdersClasses are the classes of i18n validate-placeholders.
+var validatePlaceholdersClasses = checkClassSet{
+	{Name: "unexpected-placeholder", Description: "translations using placeholders the English string lacks", ExitCode: 3, Message: "Translations use placeholders the English strings lack."},
+	{Name: "missing-placeholder", Description: "translations dropping placeholders of the English string", ExitCode: 4, Message: "Translations drop placeholders of the English strings."},
+}
+
+type placeholderMismatch struct {
+	Id      string   `json:"id"`
+	Locale  string   `json:"locale"`
+	Missing []string `json:"missing"`
+	Extra   []string `json:"extra"`
+	// File is the locale file, set by localePlaceholderMismatches.
+	File string `json:"file,omitempty"`
+}
+
+func (m *placeholderMismatch) String() string {
+	problems := []string{}
+	if len(m.Missing) > 0 {
+		problems = append(problems, "missing "+strings.Join(m.Missing, ", "))
+	}
+	if len(m.Extra) > 0 {
+		problems = append(problems, "unexpected "+strings.Join(m.Extra, ", "))
+	}
+	return fmt.Sprintf("%s: %s: %s", m.Locale, m.Id, strings.Join(problems, "; "))
+}
+
+// class returns the class of the mismatch, unexpected-placeholder when the
+// translation uses unexpected placeholders whatever it misses.
+func (m *placeholderMismatch) class() string {
+	if len(m.Extra) > 0 {
+		return "unexpected-placeholder"
+	}
+	return "missing-placeholder"
+}
+
+// finding locates the mismatch at the line of the id in the locale file.
+func (m *placeholderMismatch) finding(file string, lines map[string]int) finding {
+	return finding{Rule: placeholderMismatchRule, Message: m.String(), File: findingPath(file), Line: lines[m.Id], Text: m.String(), Class: m.class()}
+}
+
+// findPlaceholderMismatches compares the placeholders of every non empty
+// translation with the ones of the English source string.
+func findPlaceholderMismatches(source map[string]interface{}, locale string, translations []Translation) []*placeholderMismatch {
+	mismatches := []*placeholderMismatch{}
+	for _, t := range translations {
+		if isEmptyTranslation(t.Translation) {
+			continue
+		}
+		sourceValue, ok := source[t.Id]
+		if !ok {
+			continue
+		}
+		missing, extra := comparePlaceholders(translationPlaceholders(sourceValue), translationPlaceholders(t.Translation))
+		if len(missing) > 0 || len(extra) > 0 {
+			mismatches = append(mismatches, &placeholderMismatch{Id: t.Id, Locale: locale, Missing: missing, Extra: extra})
+		}
+	}
+	return mismatches
+}
+
+// localePlaceholderMismatches returns the mismatches of every locale file
+// with the English translations, by file and id.
+func localePlaceholderMismatches(xeniaDir string, translations []Translation) ([]*placeholderMismatch, error) {
+	source := map[string]interface{}{}
+	for _, t := range translations {
+		source[t.Id] = t.Translation
+	}
+	files, err := localeFiles(xeniaDir)
+	if err != nil {
+		return nil, err
+	}
+	mismatches := []*placeholderMismatch{}
+	for _, file := range files {
+		localeTranslations, err := readTranslationsFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, mismatch := range findPlaceholderMismatches(source, localeName(file), localeTranslations) {
+			mismatch.File = file
+			mismatches = append(mismatches, mismatch)
+		}
+	}
+	return mismatches, nil
+}
+
+func sourceTranslations(xeniaDir string) (map[string]interface{}, error) {
+	translations, err := getCurrentTranslations(xeniaDir)
+	if err != nil {
+		return nil, err
+	}
+	source := map[string]interface{}{}
+	for _, t := range translations {
+		source[t.Id] = t.Translation
+	}
+	return source, nil
+}
+
+func validatePlaceholdersCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	locales, err := command.Flags().GetStringSlice("locale")
+	if err != nil {
+		return errors.New("Invalid locale parameter")
+	}
+	reporter, err := getFindingsReporter(command)
+	if err != nil {
+		return err
+	}
+	failOn, err := validatePlaceholdersClasses.getCheckPolicy(command)
+	if err != nil {
+		return err
+	}
+	wanted := map[string]bool{}
+	for _, locale := range locales {
+		wanted[locale] = true
+	}
+
+	source, err := sourceTranslations(xeniaDir)
+	if err != nil {
+		return err
+	}
+	files, err := localeFiles(xeniaDir)
+	if err != nil {
+		return err
+	}
+
+	findings := []finding{}
+	for _, file := range files {
+		locale := localeName(file)
+		if len(wanted) > 0 && !wanted[locale] {
+			continue
+		}
+		translations, err := readTranslationsFile(file)
+		if err != nil {
+			return err
+		}
+		lines := catalogIdLines(file)
+		for _, mismatch := range findPlaceholderMismatches(source, locale, translations) {
+			findings = append(findings, mismatch.finding(file, lines))
+		}
+	}
+
+	command.SilenceUsage = true
+	counts := failOn.Report(reporter, findings)
+	if err := reporter.Flush(); err != nil {
+		return err
+	}
+	return failOn.Result(counts)
+}
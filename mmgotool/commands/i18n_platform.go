@@ -0,0 +1,608 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	crowdinTokenEnv   = "CROWDIN_PERSONAL_TOKEN"
+	transifexTokenEnv = "TX_TOKEN"
+	weblateTokenEnv   = "WEBLATE_API_KEY"
+	// platformPollInterval and platformPollAttempts bound the wait for the
+	// asynchronous uploads and downloads of Transifex.
+	platformPollInterval = 2 * time.Second
+	platformPollAttempts = 90
+)
+
+const platformHelp = `The provider is selected with --provider:
+
+  crowdin    --project is the numeric project id, --resource the name of the file in the project, en.json by default.
+             The token is read from $` + crowdinTokenEnv + `, --url is the API of Crowdin Enterprise organizations.
+  transifex  --project is the project id like o:xenia:p:server, --resource the resource slug, server by default.
+             The token is read from $` + transifexTokenEnv + `.
+  weblate    --project and --resource are the project and the component slugs, server by default, --url the API of the instance.
+             The token is read from $` + weblateTokenEnv + `.`
+
+var PushCmd = &cobra.Command{
+	Use:   "push",
+	Short: "Upload the English strings to a translation platform",
+	Long: `Upload i18n/en.json to the project of a translation platform as its source strings, replacing the previous ones.
+
+` + platformHelp,
+	Example: `  i18n push --provider crowdin --project 42
+  i18n push --provider weblate --url https://hosted.weblate.org/api --project xenia`,
+	Args: cobra.NoArgs,
+	RunE: pushCmdF,
+}
+
+var PullCmd = &cobra.Command{
+	Use:   "pull",
+	Short: "Download the translations of a translation platform",
+	Long: `Download the translations of every target language of the project of a translation platform, or of --locale, into the i18n folder, replacing the locale files. The untranslated strings are left out and the existing files keep their format.
+
+The languages of the platform are saved under the same locale unless mapped with --language-map, like --language-map es-ES=es.
+
+` + platformHelp,
+	Example: `  i18n pull --provider crowdin --project 42 --language-map es-ES=es --language-map pt-BR=pt-BR
+  i18n pull --provider transifex --project o:xenia:p:server --locale de --locale fr`,
+	Args: cobra.NoArgs,
+	RunE: pullCmdF,
+}
+
+func init() {
+	for _, command := range []*cobra.Command{PushCmd, PullCmd} {
+		command.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+		command.Flags().String("provider", "", "Translation platform: crowdin, transifex or weblate")
+		command.Flags().String("project", "", "Project of the translation platform")
+		command.Flags().String("resource", "", "File, resource or component of the project holding the strings")
+		command.Flags().String("url", "", "Base URL of the platform API, required for weblate")
+		I18nCmd.AddCommand(command)
+	}
+	PullCmd.Flags().StringSlice("locale", []string{}, "Only pull these locales (defaults to all the languages of the project)")
+	PullCmd.Flags().StringSlice("language-map", []string{}, "Locale of a platform language, as language=locale")
+}
+
+// translationPlatform is a translation management service receiving the
+// English strings and returning the translated catalogs.
+type translationPlatform interface {
+	// PushSource uploads the English catalog as the source strings.
+	PushSource(data []byte) error
+	// Languages returns the target languages of the project, in the codes
+	// of the platform.
+	Languages() ([]string, error)
+	// PullTranslations downloads the catalog of a language.
+	PullTranslations(language string) ([]byte, error)
+}
+
+// platformClient sends the requests of a platform API. The authorization is
+// only sent to the API, not to the download links it returns.
+type platformClient struct {
+	client        *http.Client
+	baseURL       string
+	authorization string
+}
+
+func newPlatformClient(baseURL, authorization string) *platformClient {
+	client := &http.Client{
+		Timeout: 5 * time.Minute,
+		// The redirects are the download links of the finished exports.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return &platformClient{client: client, baseURL: strings.TrimSuffix(baseURL, "/"), authorization: authorization}
+}
+
+// send sends a request and returns the response with its body, failing on
+// the error statuses.
+func (c *platformClient) send(method, target, contentType string, body []byte, headers map[string]string) (*http.Response, []byte, error) {
+	request, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	if strings.HasPrefix(target, c.baseURL+"/") {
+		request.Header.Set("Authorization", c.authorization)
+	}
+	response, err := c.client.Do(request)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer response.Body.Close()
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if response.StatusCode >= http.StatusBadRequest {
+		return nil, nil, fmt.Errorf("%s %s failed with %s: %s", method, request.URL.Path, response.Status, strings.TrimSpace(string(data)))
+	}
+	return response, data, nil
+}
+
+// sendJSON sends a JSON payload, when not nil, to a path of the API and
+// decodes the response into result, when not nil.
+func (c *platformClient) sendJSON(method, path, contentType string, payload, result interface{}) (*http.Response, error) {
+	var body []byte
+	if payload != nil {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return nil, err
+		}
+	}
+	response, data, err := c.send(method, c.baseURL+path, contentType, body, nil)
+	if err != nil {
+		return nil, err
+	}
+	if result != nil && response.StatusCode < http.StatusMultipleChoices {
+		if err := json.Unmarshal(data, result); err != nil {
+			return nil, fmt.Errorf("Unable to decode the response of %s: %s", path, err.Error())
+		}
+	}
+	return response, nil
+}
+
+// download reads a link returned by the API.
+func (c *platformClient) download(link string) ([]byte, error) {
+	_, data, err := c.send(http.MethodGet, link, "", nil, nil)
+	return data, err
+}
+
+type crowdinPlatform struct {
+	*platformClient
+	project string
+	file    string
+}
+
+// fileId returns the id of the file of the project, 0 when missing.
+func (p *crowdinPlatform) fileId() (int, error) {
+	var files struct {
+		Data []struct {
+			Data struct {
+				Id   int    `json:"id"`
+				Name string `json:"name"`
+				Path string `json:"path"`
+			} `json:"data"`
+		} `json:"data"`
+	}
+	if _, err := p.sendJSON(http.MethodGet, "/projects/"+p.project+"/files?limit=500", "", nil, &files); err != nil {
+		return 0, err
+	}
+	for _, file := range files.Data {
+		if file.Data.Path == "/"+strings.TrimPrefix(p.file, "/") || file.Data.Name == p.file {
+			return file.Data.Id, nil
+		}
+	}
+	return 0, nil
+}
+
+func (p *crowdinPlatform) PushSource(data []byte) error {
+	// The content is uploaded to a storage first, then attached to the file.
+	var storage struct {
+		Data struct {
+			Id int `json:"id"`
+		} `json:"data"`
+	}
+	_, body, err := p.send(http.MethodPost, p.baseURL+"/storages", "application/octet-stream", data, map[string]string{"Crowdin-API-FileName": filepath.Base(p.file)})
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, &storage); err != nil {
+		return err
+	}
+	fileId, err := p.fileId()
+	if err != nil {
+		return err
+	}
+	if fileId == 0 {
+		_, err = p.sendJSON(http.MethodPost, "/projects/"+p.project+"/files", "application/json", map[string]interface{}{"storageId": storage.Data.Id, "name": p.file}, nil)
+		return err
+	}
+	_, err = p.sendJSON(http.MethodPut, "/projects/"+p.project+"/files/"+strconv.Itoa(fileId), "application/json", map[string]interface{}{"storageId": storage.Data.Id}, nil)
+	return err
+}
+
+func (p *crowdinPlatform) Languages() ([]string, error) {
+	var project struct {
+		Data struct {
+			TargetLanguageIds []string `json:"targetLanguageIds"`
+		} `json:"data"`
+	}
+	if _, err := p.sendJSON(http.MethodGet, "/projects/"+p.project, "", nil, &project); err != nil {
+		return nil, err
+	}
+	return project.Data.TargetLanguageIds, nil
+}
+
+func (p *crowdinPlatform) PullTranslations(language string) ([]byte, error) {
+	fileId, err := p.fileId()
+	if err != nil {
+		return nil, err
+	}
+	if fileId == 0 {
+		return nil, fmt.Errorf("The Crowdin project %s has no file %s, push it first.", p.project, p.file)
+	}
+	var build struct {
+		Data struct {
+			URL string `json:"url"`
+		} `json:"data"`
+	}
+	payload := map[string]interface{}{"targetLanguageId": language, "skipUntranslatedStrings": true}
+	if _, err := p.sendJSON(http.MethodPost, "/projects/"+p.project+"/translations/builds/files/"+strconv.Itoa(fileId), "application/json", payload, &build); err != nil {
+		return nil, err
+	}
+	return p.download(build.Data.URL)
+}
+
+// transifexMediaType is the JSON:API media type of the Transifex API.
+const transifexMediaType = "application/vnd.api+json"
+
+type transifexPlatform struct {
+	*platformClient
+	project  string
+	resource string
+}
+
+// transifexData builds the JSON:API document of a request.
+func transifexData(kind string, attributes map[string]interface{}, relationships map[string][2]string) map[string]interface{} {
+	related := map[string]interface{}{}
+	for name, target := range relationships {
+		related[name] = map[string]interface{}{"data": map[string]string{"type": target[0], "id": target[1]}}
+	}
+	return map[string]interface{}{"data": map[string]interface{}{"type": kind, "attributes": attributes, "relationships": related}}
+}
+
+type transifexJob struct {
+	Data struct {
+		Id         string `json:"id"`
+		Attributes struct {
+			Status string `json:"status"`
+			Errors []struct {
+				Detail string `json:"detail"`
+			} `json:"errors"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+func (j *transifexJob) failure() error {
+	details := []string{}
+	for _, e := range j.Data.Attributes.Errors {
+		details = append(details, e.Detail)
+	}
+	return fmt.Errorf("The Transifex job %s failed: %s", j.Data.Id, strings.Join(details, "; "))
+}
+
+// wait polls an asynchronous job until it is done, returning the link of
+// its result for the downloads.
+func (p *transifexPlatform) wait(path, id string) (string, error) {
+	for attempt := 0; attempt < platformPollAttempts; attempt++ {
+		job := &transifexJob{}
+		response, err := p.sendJSON(http.MethodGet, path+"/"+id, "", nil, job)
+		if err != nil {
+			return "", err
+		}
+		if response.StatusCode == http.StatusSeeOther {
+			return response.Header.Get("Location"), nil
+		}
+		switch job.Data.Attributes.Status {
+		case "succeeded":
+			return "", nil
+		case "failed":
+			return "", job.failure()
+		}
+		time.Sleep(platformPollInterval)
+	}
+	return "", fmt.Errorf("The Transifex job %s did not finish in time.", id)
+}
+
+func (p *transifexPlatform) resourceId() string {
+	return p.project + ":r:" + p.resource
+}
+
+func (p *transifexPlatform) PushSource(data []byte) error {
+	payload := transifexData("resource_strings_async_uploads",
+		map[string]interface{}{"content": string(data), "content_encoding": "text"},
+		map[string][2]string{"resource": {"resources", p.resourceId()}})
+	job := &transifexJob{}
+	if _, err := p.sendJSON(http.MethodPost, "/resource_strings_async_uploads", transifexMediaType, payload, job); err != nil {
+		return err
+	}
+	_, err := p.wait("/resource_strings_async_uploads", job.Data.Id)
+	return err
+}
+
+func (p *transifexPlatform) Languages() ([]string, error) {
+	var languages struct {
+		Data []struct {
+			Attributes struct {
+				Code string `json:"code"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if _, err := p.sendJSON(http.MethodGet, "/projects/"+url.PathEscape(p.project)+"/languages", "", nil, &languages); err != nil {
+		return nil, err
+	}
+	codes := []string{}
+	for _, language := range languages.Data {
+		codes = append(codes, language.Attributes.Code)
+	}
+	return codes, nil
+}
+
+func (p *transifexPlatform) PullTranslations(language string) ([]byte, error) {
+	payload := transifexData("resource_translations_async_downloads",
+		map[string]interface{}{"content_encoding": "text", "file_type": "default", "mode": "onlytranslated"},
+		map[string][2]string{"resource": {"resources", p.resourceId()}, "language": {"languages", "l:" + language}})
+	job := &transifexJob{}
+	if _, err := p.sendJSON(http.MethodPost, "/resource_translations_async_downloads", transifexMediaType, payload, job); err != nil {
+		return nil, err
+	}
+	link, err := p.wait("/resource_translations_async_downloads", job.Data.Id)
+	if err != nil {
+		return nil, err
+	}
+	if link == "" {
+		return nil, fmt.Errorf("The Transifex download of %s has no file.", language)
+	}
+	return p.download(link)
+}
+
+type weblatePlatform struct {
+	*platformClient
+	project   string
+	component string
+}
+
+func (p *weblatePlatform) translationPath(language string) string {
+	return "/translations/" + url.PathEscape(p.project) + "/" + url.PathEscape(p.component) + "/" + url.PathEscape(language) + "/file/"
+}
+
+func (p *weblatePlatform) PushSource(data []byte) error {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if err := form.WriteField("method", "replace"); err != nil {
+		return err
+	}
+	file, err := form.CreateFormFile("file", "en.json")
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		return err
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+	_, response, err := p.send(http.MethodPost, p.baseURL+p.translationPath("en"), form.FormDataContentType(), body.Bytes(), nil)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Result bool `json:"result"`
+	}
+	if err := json.Unmarshal(response, &result); err != nil {
+		return err
+	}
+	if !result.Result {
+		return fmt.Errorf("Weblate refused the upload: %s", strings.TrimSpace(string(response)))
+	}
+	return nil
+}
+
+func (p *weblatePlatform) Languages() ([]string, error) {
+	codes := []string{}
+	next := p.baseURL + "/components/" + url.PathEscape(p.project) + "/" + url.PathEscape(p.component) + "/translations/"
+	for next != "" {
+		var page struct {
+			Next    string `json:"next"`
+			Results []struct {
+				LanguageCode string `json:"language_code"`
+			} `json:"results"`
+		}
+		_, data, err := p.send(http.MethodGet, next, "", nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return nil, err
+		}
+		for _, result := range page.Results {
+			if result.LanguageCode != "en" {
+				codes = append(codes, result.LanguageCode)
+			}
+		}
+		next = page.Next
+	}
+	return codes, nil
+}
+
+func (p *weblatePlatform) PullTranslations(language string) ([]byte, error) {
+	return p.download(p.baseURL + p.translationPath(language))
+}
+
+// getTranslationPlatform returns the platform selected by the flags of push
+// and pull.
+func getTranslationPlatform(command *cobra.Command) (translationPlatform, error) {
+	provider, err := command.Flags().GetString("provider")
+	if err != nil {
+		return nil, errors.New("Invalid provider parameter")
+	}
+	project, err := command.Flags().GetString("project")
+	if err != nil || project == "" {
+		return nil, errors.New("Invalid project parameter")
+	}
+	resource, err := command.Flags().GetString("resource")
+	if err != nil {
+		return nil, errors.New("Invalid resource parameter")
+	}
+	baseURL, err := command.Flags().GetString("url")
+	if err != nil {
+		return nil, errors.New("Invalid url parameter")
+	}
+	if resource == "" {
+		resource = "server"
+		if provider == "crowdin" {
+			resource = "en.json"
+		}
+	}
+
+	switch provider {
+	case "crowdin":
+		token := os.Getenv(crowdinTokenEnv)
+		if token == "" {
+			return nil, fmt.Errorf("Set the Crowdin API token in $%s.", crowdinTokenEnv)
+		}
+		if baseURL == "" {
+			baseURL = "https://api.crowdin.com/api/v2"
+		}
+		return &crowdinPlatform{platformClient: newPlatformClient(baseURL, "Bearer "+token), project: project, file: resource}, nil
+	case "transifex":
+		token := os.Getenv(transifexTokenEnv)
+		if token == "" {
+			return nil, fmt.Errorf("Set the Transifex API token in $%s.", transifexTokenEnv)
+		}
+		if baseURL == "" {
+			baseURL = "https://rest.api.transifex.com"
+		}
+		return &transifexPlatform{platformClient: newPlatformClient(baseURL, "Bearer "+token), project: project, resource: resource}, nil
+	case "weblate":
+		token := os.Getenv(weblateTokenEnv)
+		if token == "" {
+			return nil, fmt.Errorf("Set the Weblate API key in $%s.", weblateTokenEnv)
+		}
+		if baseURL == "" {
+			return nil, errors.New("Set the API of the Weblate instance with --url.")
+		}
+		return &weblatePlatform{platformClient: newPlatformClient(baseURL, "Token "+token), project: project, component: resource}, nil
+	}
+	return nil, fmt.Errorf("Unknown provider %s", provider)
+}
+
+func pushCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	platform, err := getTranslationPlatform(command)
+	if err != nil {
+		return err
+	}
+	command.SilenceUsage = true
+
+	// The catalog is encoded again to leave its metadata out.
+	enJSON := filepath.Join(xeniaDir, "i18n", "en.json")
+	translations, err := readTranslationsFile(enJSON)
+	if err != nil {
+		return err
+	}
+	data, err := encodeCatalog(translations, catalogFileFormat(enJSON))
+	if err != nil {
+		return err
+	}
+	if err := platform.PushSource(data); err != nil {
+		return err
+	}
+	fmt.Printf("Pushed the %d English strings of %s.\n", len(translations), enJSON)
+	return nil
+}
+
+func pullCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	locales, err := command.Flags().GetStringSlice("locale")
+	if err != nil {
+		return errors.New("Invalid locale parameter")
+	}
+	mappings, err := command.Flags().GetStringSlice("language-map")
+	if err != nil {
+		return errors.New("Invalid language-map parameter")
+	}
+	localeOf := map[string]string{}
+	languageOf := map[string]string{}
+	for _, mapping := range mappings {
+		parts := strings.SplitN(mapping, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("Invalid language mapping %s, use language=locale.", mapping)
+		}
+		localeOf[parts[0]] = parts[1]
+		languageOf[parts[1]] = parts[0]
+	}
+	platform, err := getTranslationPlatform(command)
+	if err != nil {
+		return err
+	}
+	command.SilenceUsage = true
+
+	languages := []string{}
+	if len(locales) > 0 {
+		for _, locale := range locales {
+			if language, ok := languageOf[locale]; ok {
+				languages = append(languages, language)
+			} else {
+				languages = append(languages, locale)
+			}
+		}
+	} else if languages, err = platform.Languages(); err != nil {
+		return err
+	}
+	sort.Strings(languages)
+
+	for _, language := range languages {
+		locale := language
+		if mapped, ok := localeOf[language]; ok {
+			locale = mapped
+		}
+		if locale == "en" {
+			continue
+		}
+		data, err := platform.PullTranslations(language)
+		if err != nil {
+			return fmt.Errorf("Unable to pull %s: %s", language, err.Error())
+		}
+		downloaded, err := decodeTranslations(data)
+		if err != nil {
+			return fmt.Errorf("Unable to parse the translations of %s: %s", language, err.Error())
+		}
+		translations := []Translation{}
+		for _, t := range downloaded {
+			if !isEmptyTranslation(t.Translation) {
+				translations = append(translations, t)
+			}
+		}
+		sort.Slice(translations, func(i, j int) bool { return translations[i].Id < translations[j].Id })
+
+		localeFile := filepath.Join(xeniaDir, "i18n", locale+".json")
+		if err := sandbox.checkPath(localeFile); err != nil {
+			return err
+		}
+		if err := writeTranslationsFile(localeFile, translations); err != nil {
+			return err
+		}
+		fmt.Printf("Pulled %d translations of %s into %s.\n", len(translations), language, localeFile)
+	}
+	return nil
+}
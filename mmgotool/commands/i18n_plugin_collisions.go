@@ -0,0 +1,348 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/xzl8028/xenia-utilities/mmgotool/pkg/i18nextract"
+)
+
+var PluginCollisionsCmd = &cobra.Command{
+	Use:   "plugin-collisions",
+	Short: "Find the plugin translation ids colliding with the server ones",
+	Long: `Read the translation files of plugins and report the ids the server uses too. At runtime a plugin translation replaces the server one with the same id, the server errors then show the text of the plugin.
+
+The plugins are read from a folder, holding installed plugins or their .tar.gz bundles, or downloaded from the bundles of a marketplace listing, a file or URL. The translation files are the json files of the i18n folders of the plugins.`,
+	Example: `  i18n plugin-collisions --plugins-dir ../xenia-server/plugins
+  i18n plugin-collisions --marketplace https://api.integrations.xenia.com/api/v1/plugins`,
+	RunE: pluginCollisionsCmdF,
+}
+
+func init() {
+	PluginCollisionsCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	PluginCollisionsCmd.Flags().String("plugins-dir", "", "Folder of the installed plugins or of their bundles")
+	PluginCollisionsCmd.Flags().String("marketplace", "", "File or URL of a marketplace listing whose plugin bundles are checked")
+	PluginCollisionsCmd.Flags().String("format", "text", "Output format: text or json")
+	I18nCmd.AddCommand(PluginCollisionsCmd)
+}
+
+// pluginTranslations are the translation ids of a plugin, with the file
+// defining each of them.
+type pluginTranslations struct {
+	Plugin string
+	Ids    map[string]string
+}
+
+func newPluginTranslations(plugin string) *pluginTranslations {
+	return &pluginTranslations{Plugin: plugin, Ids: map[string]string{}}
+}
+
+// add records the ids of a translation file of the plugin.
+func (p *pluginTranslations) add(file string, data []byte) error {
+	translations, _, err := parseCatalog(data)
+	if err != nil {
+		return fmt.Errorf("Unable to parse %s of the %s plugin: %s", file, p.Plugin, err.Error())
+	}
+	for id := range translations {
+		if _, ok := p.Ids[id]; !ok {
+			p.Ids[id] = file
+		}
+	}
+	return nil
+}
+
+// isPluginTranslationFile tells if a file of a plugin is a translation file.
+func isPluginTranslationFile(file string) bool {
+	return path.Ext(file) == ".json" && path.Base(path.Dir(file)) == "i18n"
+}
+
+// pluginManifestId returns the id of the manifest, empty when it can't be
+// read.
+func pluginManifestId(data []byte) string {
+	var manifest struct {
+		Id string `json:"id"`
+	}
+	if json.Unmarshal(data, &manifest) != nil {
+		return ""
+	}
+	return manifest.Id
+}
+
+// readPluginFolder reads the translations of an installed plugin.
+func readPluginFolder(dir string) (*pluginTranslations, error) {
+	plugin := filepath.Base(dir)
+	if data, err := readFile(filepath.Join(dir, "plugin.json")); err == nil {
+		if id := pluginManifestId(data); id != "" {
+			plugin = id
+		}
+	}
+	p := newPluginTranslations(plugin)
+	var readErr error
+	i18nextract.Walk(dir, false, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if i18nextract.SkippedDirs[info.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil || !isPluginTranslationFile(filepath.ToSlash(rel)) {
+			return nil
+		}
+		data, err := readFile(file)
+		if err == nil {
+			err = p.add(filepath.ToSlash(rel), data)
+		}
+		if err != nil {
+			readErr = err
+			return io.EOF
+		}
+		return nil
+	})
+	return p, readErr
+}
+
+// readPluginBundle reads the translations of a .tar.gz plugin bundle. The
+// plugin is named after the bundle until its manifest is found.
+func readPluginBundle(r io.Reader, name string) (*pluginTranslations, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read the %s bundle: %s", name, err.Error())
+	}
+	defer gz.Close()
+
+	p := newPluginTranslations(name)
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read the %s bundle: %s", name, err.Error())
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		file := strings.TrimPrefix(path.Clean(header.Name), "./")
+		// The bundles hold a folder named after the plugin.
+		isManifest := file == "plugin.json" || (strings.Count(file, "/") == 1 && path.Base(file) == "plugin.json")
+		if !isManifest && !isPluginTranslationFile(file) {
+			continue
+		}
+		data, err := ioutil.ReadAll(archive)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read the %s bundle: %s", name, err.Error())
+		}
+		if isManifest {
+			if id := pluginManifestId(data); id != "" {
+				p.Plugin = id
+			}
+			continue
+		}
+		if err := p.add(file, data); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// readPluginsDir reads the installed plugins and the bundles of the folder.
+func readPluginsDir(dir string) ([]*pluginTranslations, error) {
+	entries, err := readDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	plugins := []*pluginTranslations{}
+	for _, entry := range entries {
+		p := filepath.Join(dir, entry.Name())
+		var plugin *pluginTranslations
+		switch {
+		case entry.IsDir():
+			plugin, err = readPluginFolder(p)
+		case strings.HasSuffix(entry.Name(), ".tar.gz") || strings.HasSuffix(entry.Name(), ".tgz"):
+			var file *os.File
+			if file, err = openFile(p); err == nil {
+				plugin, err = readPluginBundle(file, entry.Name())
+				file.Close()
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		plugins = append(plugins, plugin)
+	}
+	return plugins, nil
+}
+
+// readMarketplace downloads and reads the bundles of a marketplace listing.
+func readMarketplace(location string) ([]*pluginTranslations, error) {
+	client := &http.Client{Timeout: 5 * time.Minute}
+	open := func(location string) (io.ReadCloser, error) {
+		if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+			return openFile(location)
+		}
+		response, err := client.Get(location)
+		if err != nil {
+			return nil, err
+		}
+		if response.StatusCode != http.StatusOK {
+			response.Body.Close()
+			return nil, fmt.Errorf("Unable to download %s: %s", location, response.Status)
+		}
+		return response.Body, nil
+	}
+
+	listing, err := open(location)
+	if err != nil {
+		return nil, err
+	}
+	var entries []marketplacePlugin
+	err = json.NewDecoder(listing).Decode(&entries)
+	listing.Close()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse the marketplace listing %s: %s", location, err.Error())
+	}
+
+	plugins := []*pluginTranslations{}
+	for _, entry := range entries {
+		if entry.DownloadURL == "" {
+			logger.Warn("Skipping marketplace plugin without a bundle", "plugin", entry.Manifest.Id)
+			continue
+		}
+		logger.Info("Downloading plugin bundle", "plugin", entry.Manifest.Id, "url", entry.DownloadURL)
+		bundle, err := open(entry.DownloadURL)
+		if err != nil {
+			return nil, err
+		}
+		plugin, err := readPluginBundle(bundle, entry.Manifest.Id)
+		bundle.Close()
+		if err != nil {
+			return nil, err
+		}
+		plugins = append(plugins, plugin)
+	}
+	return plugins, nil
+}
+
+// pluginCollision is a plugin translation id the server uses too.
+type pluginCollision struct {
+	Plugin string `json:"plugin"`
+	Id     string `json:"id"`
+	File   string `json:"file"`
+}
+
+func findPluginCollisions(plugins []*pluginTranslations, core []Translation) []pluginCollision {
+	coreIds := map[string]bool{}
+	for _, t := range core {
+		coreIds[t.Id] = true
+	}
+	collisions := []pluginCollision{}
+	for _, plugin := range plugins {
+		for id, file := range plugin.Ids {
+			if coreIds[id] {
+				collisions = append(collisions, pluginCollision{Plugin: plugin.Plugin, Id: id, File: file})
+			}
+		}
+	}
+	sort.Slice(collisions, func(i, j int) bool {
+		if collisions[i].Plugin != collisions[j].Plugin {
+			return collisions[i].Plugin < collisions[j].Plugin
+		}
+		return collisions[i].Id < collisions[j].Id
+	})
+	return collisions
+}
+
+func pluginCollisionsCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	pluginsDir, err := command.Flags().GetString("plugins-dir")
+	if err != nil {
+		return errors.New("Invalid plugins-dir parameter")
+	}
+	marketplace, err := command.Flags().GetString("marketplace")
+	if err != nil {
+		return errors.New("Invalid marketplace parameter")
+	}
+	format, err := command.Flags().GetString("format")
+	if err != nil {
+		return errors.New("Invalid format parameter")
+	}
+	if format != "text" && format != "json" {
+		return fmt.Errorf("Unknown format %s", format)
+	}
+	if pluginsDir == "" && marketplace == "" {
+		return errors.New("Set --plugins-dir or --marketplace")
+	}
+	command.SilenceUsage = true
+
+	core, err := getCurrentTranslations(xeniaDir)
+	if err != nil {
+		return err
+	}
+	plugins := []*pluginTranslations{}
+	if pluginsDir != "" {
+		installed, err := readPluginsDir(pluginsDir)
+		if err != nil {
+			return err
+		}
+		plugins = append(plugins, installed...)
+	}
+	if marketplace != "" {
+		listed, err := readMarketplace(marketplace)
+		if err != nil {
+			return err
+		}
+		plugins = append(plugins, listed...)
+	}
+	collisions := findPluginCollisions(plugins, core)
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(collisions); err != nil {
+			return err
+		}
+	} else if len(collisions) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "PLUGIN\tID\tFILE")
+		for _, collision := range collisions {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", collision.Plugin, collision.Id, collision.File)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	if len(collisions) > 0 {
+		return fmt.Errorf("%d translation ids of %d plugins collide with the server ones.", len(collisions), len(plugins))
+	}
+	if format == "text" {
+		fmt.Printf("No collision found in %d plugins.\n", len(plugins))
+	}
+	return nil
+}
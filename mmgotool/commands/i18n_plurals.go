@@ -0,0 +1,111 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var ValidatePluralsCmd = &cobra.Command{
+	Use:     "validate-plurals",
+	Short:   "Validate plural translations",
+	Long:    "Check that every plural translation provides the plural categories CLDR requires for the language of its locale",
+	Example: "  i18n validate-plurals",
+	RunE:    validatePluralsCmdF,
+}
+
+func init() {
+	ValidatePluralsCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	addFindingsFormatFlag(ValidatePluralsCmd)
+	I18nCmd.AddCommand(ValidatePluralsCmd)
+}
+
+var pluralFormsRule = registerFindingRule(findingRule{Id: "i18n/plural-forms", Description: "The translation does not provide the plural categories of its locale", Command: "i18n validate-plurals"})
+
+type pluralProblem struct {
+	Id      string
+	Locale  string
+	Message string
+}
+
+func (p *pluralProblem) String() string {
+	return fmt.Sprintf("%s: %s: %s", p.Locale, p.Id, p.Message)
+}
+
+// finding locates the problem at the line of the id in the locale file.
+func (p *pluralProblem) finding(file string, lines map[string]int) finding {
+	return finding{Rule: pluralFormsRule, Message: p.String(), File: findingPath(file), Line: lines[p.Id], Text: p.String(), Class: "plural-forms"}
+}
+
+// findPluralProblems validates the translations of a locale. A translation
+// must be plural when its English source is plural, and plural translations
+// must provide every category required by the locale.
+func findPluralProblems(source map[string]interface{}, locale string, translations []Translation) []*pluralProblem {
+	problems := []*pluralProblem{}
+	for _, t := range translations {
+		if isEmptyTranslation(t.Translation) {
+			continue
+		}
+		forms, isPlural := t.PluralForms()
+		_, sourceIsPlural := parsePluralForms(source[t.Id])
+		if !isPlural {
+			if _, isMap := t.Translation.(map[string]interface{}); isMap {
+				problems = append(problems, &pluralProblem{Id: t.Id, Locale: locale, Message: "invalid plural categories"})
+			} else if sourceIsPlural {
+				problems = append(problems, &pluralProblem{Id: t.Id, Locale: locale, Message: "translation should be plural"})
+			}
+			continue
+		}
+		if missing := forms.MissingCategories(locale); len(missing) > 0 {
+			problems = append(problems, &pluralProblem{Id: t.Id, Locale: locale, Message: "missing plural categories " + strings.Join(missing, ", ")})
+		}
+	}
+	return problems
+}
+
+func validatePluralsCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	reporter, err := getFindingsReporter(command)
+	if err != nil {
+		return err
+	}
+
+	source, err := sourceTranslations(xeniaDir)
+	if err != nil {
+		return err
+	}
+	files, err := localeFiles(xeniaDir)
+	if err != nil {
+		return err
+	}
+	files = append([]string{path.Join(xeniaDir, "i18n", "en.json")}, files...)
+
+	for _, file := range files {
+		translations, err := readTranslationsFile(file)
+		if err != nil {
+			return err
+		}
+		lines := catalogIdLines(file)
+		for _, problem := range findPluralProblems(source, localeName(file), translations) {
+			reporter.Report(problem.finding(file, lines))
+		}
+	}
+
+	if err := reporter.Flush(); err != nil {
+		return err
+	}
+	if count := reporter.Failures(); count > 0 {
+		command.SilenceUsage = true
+		return fmt.Errorf("%d plural translations with problems.", count)
+	}
+	return nil
+}
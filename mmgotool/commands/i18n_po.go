@@ -0,0 +1,140 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var ExportPoCmd = &cobra.Command{
+	Use:   "export-po",
+	Short: "Export a locale to a gettext PO file",
+	Long: `Export the translations of a locale to a gettext PO file for the translation tools reading it, like Poedit or Weblate.
+
+Every id is an entry whose context is the id, with the English string as msgid and the current translation as msgstr. The description of the id is the extracted comment (#.) of the entry, shown to the translators, and its expiry release another one. The machine translations not reviewed yet are fuzzy. Plural strings have one entry per plural category of the locale, with the <id>#<category> context, since the server picks the forms with the CLDR rules rather than a Plural-Forms expression.`,
+	Example: "  i18n export-po --locale de --output de.po",
+	RunE:    exportPoCmdF,
+}
+
+func init() {
+	ExportPoCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	ExportPoCmd.Flags().String("locale", "", "Locale to export, like de or pt-BR")
+	ExportPoCmd.Flags().String("output", "", "Path to the PO file (defaults to stdout)")
+	I18nCmd.AddCommand(ExportPoCmd)
+}
+
+// poString quotes s as a PO string, split after its new lines like gettext
+// does for the multi-line strings.
+func poString(s string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`).Replace(s)
+	lines := strings.SplitAfter(escaped, `\n`)
+	if len(lines) > 1 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 1 {
+		return `"` + lines[0] + `"`
+	}
+	return `""` + "\n\"" + strings.Join(lines, "\"\n\"") + `"`
+}
+
+// writePoComments writes the comments of the entry of an id, every line of
+// the description being an extracted comment.
+func writePoComments(out *bytes.Buffer, source Translation, fuzzy bool) {
+	if source.Description != "" {
+		for _, line := range strings.Split(source.Description, "\n") {
+			fmt.Fprintf(out, "#. %s\n", line)
+		}
+	}
+	if source.Expires != "" {
+		fmt.Fprintf(out, "#. Expires: %s\n", source.Expires)
+	}
+	if fuzzy {
+		fmt.Fprintln(out, "#, fuzzy")
+	}
+}
+
+func writePoEntry(out *bytes.Buffer, source Translation, fuzzy bool, context, msgid, msgstr string) {
+	out.WriteString("\n")
+	writePoComments(out, source, fuzzy)
+	fmt.Fprintf(out, "msgctxt %s\n", poString(context))
+	fmt.Fprintf(out, "msgid %s\n", poString(msgid))
+	fmt.Fprintf(out, "msgstr %s\n", poString(msgstr))
+}
+
+// poEntriesFor writes the entries of an English translation and its
+// translation in the locale, nil when missing.
+func poEntriesFor(out *bytes.Buffer, source Translation, translated *Translation, locale string) {
+	var target interface{}
+	fuzzy := false
+	if translated != nil {
+		target = translated.Translation
+		fuzzy = translated.Fuzzy
+	}
+
+	if forms, ok := source.PluralForms(); ok {
+		targets, _ := parsePluralForms(target)
+		for _, category := range requiredPluralCategories(locale) {
+			text, ok := forms[category]
+			if !ok {
+				text = forms["other"]
+			}
+			writePoEntry(out, source, fuzzy && targets[category] != "", source.Id+"#"+category, text, targets[category])
+		}
+		return
+	}
+	text, _ := source.Translation.(string)
+	translation, _ := target.(string)
+	writePoEntry(out, source, fuzzy && translation != "", source.Id, text, translation)
+}
+
+func exportPoCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	locale, err := command.Flags().GetString("locale")
+	if err != nil || !isLocaleTag(locale) {
+		return errors.New("Invalid locale parameter")
+	}
+	output, err := command.Flags().GetString("output")
+	if err != nil {
+		return errors.New("Invalid output parameter")
+	}
+	command.SilenceUsage = true
+
+	source, err := getCurrentTranslations(xeniaDir)
+	if err != nil {
+		return err
+	}
+	translations, err := readTranslationsFile(filepath.Join(xeniaDir, "i18n", locale+".json"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	translated := map[string]*Translation{}
+	for i := range translations {
+		translated[translations[i].Id] = &translations[i]
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintln(&out, `msgid ""`)
+	fmt.Fprintln(&out, `msgstr ""`)
+	fmt.Fprintln(&out, `"Content-Type: text/plain; charset=UTF-8\n"`)
+	fmt.Fprintf(&out, "\"Language: %s\\n\"\n", strings.Replace(locale, "-", "_", -1))
+	for _, t := range source {
+		poEntriesFor(&out, t, translated[t.Id], locale)
+	}
+
+	if output == "" {
+		_, err = os.Stdout.Write(out.Bytes())
+		return err
+	}
+	return writeFile(output, out.Bytes(), 0644)
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// extractDescribedCatalog extracts the catalog of a source tree using an
+// "// i18n:" comment and returns the path of the tree, which has a de
+// translation file.
+func extractDescribedCatalog(t *testing.T) string {
+	t.Helper()
+	xeniaDir := t.TempDir()
+	writeTestFile(t, filepath.Join(xeniaDir, "app", "app.go"), `package app
+
+func f() {
+	// i18n: Shown when the "user" can't be found
+	T("app.user.missing")
+	T("app.user.count")
+}
+`)
+	writeTestFile(t, filepath.Join(xeniaDir, "i18n", "en.json"), `[
+  {"id": "app.user.count", "translation": {"one": "{{.Count}} user", "other": "{{.Count}} users"}},
+  {"id": "app.user.missing", "translation": "User \"{{.Name}}\" not found.\nTry again."}
+]
+`)
+	writeTestFile(t, filepath.Join(xeniaDir, "i18n", "de.json"), `[
+  {"id": "app.user.missing", "translation": "Benutzer nicht gefunden.", "fuzzy": true}
+]
+`)
+	RootCmd.SetArgs([]string{"i18n", "extract", "--xenia-dir", xeniaDir, "--include-enterprise=false", "--no-cache"})
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatalf("extract failed: %s", err)
+	}
+	return xeniaDir
+}
+
+func TestPoString(t *testing.T) {
+	tests := map[string]string{
+		"":                       `""`,
+		"Save":                   `"Save"`,
+		`Say "hi" \ bye`:         `"Say \"hi\" \\ bye"`,
+		"Tab\there":              `"Tab\there"`,
+		"Done.\n":                `"Done.\n"`,
+		"First line.\nSecond.":   "\"\"\n\"First line.\\n\"\n\"Second.\"",
+		"First line.\nSecond.\n": "\"\"\n\"First line.\\n\"\n\"Second.\\n\"",
+	}
+	for s, expected := range tests {
+		if quoted := poString(s); quoted != expected {
+			t.Errorf("%q quoted as %s, expected %s", s, quoted, expected)
+		}
+	}
+}
+
+func TestExportPoKeepsDescriptions(t *testing.T) {
+	xeniaDir := extractDescribedCatalog(t)
+	output := filepath.Join(t.TempDir(), "de.po")
+	RootCmd.SetArgs([]string{"i18n", "export-po", "--xenia-dir", xeniaDir, "--locale", "de", "--output", output})
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatalf("export-po failed: %s", err)
+	}
+	data, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	po := string(data)
+
+	for _, entry := range []string{
+		`#. Shown when the "user" can't be found
+#, fuzzy
+msgctxt "app.user.missing"
+msgid ""
+"User \"{{.Name}}\" not found.\n"
+"Try again."
+msgstr "Benutzer nicht gefunden."
+`,
+		`
+msgctxt "app.user.count#one"
+msgid "{{.Count}} user"
+msgstr ""
+`,
+		`
+msgctxt "app.user.count#other"
+msgid "{{.Count}} users"
+msgstr ""
+`,
+	} {
+		if !strings.Contains(po, entry) {
+			t.Errorf("missing entry:\n%s\nin:\n%s", entry, po)
+		}
+	}
+	if strings.Count(po, "#. ") != 1 {
+		t.Errorf("only app.user.missing has a description:\n%s", po)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// commandProfile measures the i18n commands, set with --cpuprofile,
+// --memprofile, --trace and --timings.
+type commandProfile struct {
+	cpuFile    *os.File
+	traceFile  *os.File
+	memProfile string
+	timings    bool
+
+	mutex  sync.Mutex
+	phases []string
+	times  map[string]time.Duration
+	// filesParsed and filesCached are the source files parsed and the ones
+	// read from the extraction cache, keys the references found.
+	filesParsed int
+	filesCached int
+	keys        int
+}
+
+var i18nProfile = &commandProfile{times: map[string]time.Duration{}}
+
+func init() {
+	I18nCmd.PersistentFlags().String("cpuprofile", "", "Write a CPU profile of the command to this file, read with go tool pprof")
+	I18nCmd.PersistentFlags().String("memprofile", "", "Write a heap profile of the command to this file, read with go tool pprof")
+	I18nCmd.PersistentFlags().String("trace", "", "Write an execution trace of the command to this file, read with go tool trace")
+	I18nCmd.PersistentFlags().Bool("timings", false, "Print the time spent in every phase, the files parsed and the keys found once done")
+}
+
+// configureProfiling starts the profiles of the commands having the flags,
+// stopped by stopProfiling.
+func configureProfiling(command *cobra.Command) error {
+	if command.Flags().Lookup("cpuprofile") == nil {
+		return nil
+	}
+	cpuProfile, err := command.Flags().GetString("cpuprofile")
+	if err != nil {
+		return errors.New("Invalid cpuprofile parameter")
+	}
+	memProfile, err := command.Flags().GetString("memprofile")
+	if err != nil {
+		return errors.New("Invalid memprofile parameter")
+	}
+	traceFile, err := command.Flags().GetString("trace")
+	if err != nil {
+		return errors.New("Invalid trace parameter")
+	}
+	timings, err := command.Flags().GetBool("timings")
+	if err != nil {
+		return errors.New("Invalid timings parameter")
+	}
+	i18nProfile.memProfile, i18nProfile.timings = memProfile, timings
+
+	if cpuProfile != "" {
+		f, err := createFile(cpuProfile)
+		if err != nil {
+			return err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return err
+		}
+		i18nProfile.cpuFile = f
+	}
+	if traceFile != "" {
+		f, err := createFile(traceFile)
+		if err != nil {
+			return err
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			return err
+		}
+		i18nProfile.traceFile = f
+	}
+	return nil
+}
+
+// stopProfiling writes the profiles and prints the timings of the command.
+func stopProfiling() {
+	p := i18nProfile
+	if p.cpuFile != nil {
+		pprof.StopCPUProfile()
+		p.cpuFile.Close()
+		p.cpuFile = nil
+	}
+	if p.traceFile != nil {
+		trace.Stop()
+		p.traceFile.Close()
+		p.traceFile = nil
+	}
+	if p.memProfile != "" {
+		if err := writeHeapProfile(p.memProfile); err != nil {
+			logger.Warn("Unable to write the heap profile", "path", p.memProfile, "error", err)
+		}
+	}
+	if p.timings {
+		p.print()
+	}
+}
+
+func writeHeapProfile(file string) error {
+	f, err := createFile(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// The profile shows the allocations of the last collection.
+	runtime.GC()
+	return pprof.WriteHeapProfile(f)
+}
+
+// phase measures a phase of the command until the returned function is
+// called, like in defer i18nProfile.phase("encode")(). The durations of a
+// phase run by several goroutines add up.
+func (p *commandProfile) phase(name string) func() {
+	start := time.Now()
+	return func() {
+		elapsed := time.Since(start)
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+		if _, ok := p.times[name]; !ok {
+			p.phases = append(p.phases, name)
+		}
+		p.times[name] += elapsed
+	}
+}
+
+// countFile counts a source file parsed or read from the extraction cache.
+func (p *commandProfile) countFile(cached bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if cached {
+		p.filesCached++
+	} else {
+		p.filesParsed++
+	}
+}
+
+func (p *commandProfile) countKeys(keys int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.keys += keys
+}
+
+// print writes the timings to stderr, with the diagnostics.
+func (p *commandProfile) print() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	w := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PHASE\tDURATION")
+	for _, name := range p.phases {
+		fmt.Fprintf(w, "%s\t%s\n", name, p.times[name].Round(time.Microsecond))
+	}
+	w.Flush()
+	fmt.Fprintf(os.Stderr, "%d files parsed, %d read from the cache, %d keys found.\n", p.filesParsed, p.filesCached, p.keys)
+}
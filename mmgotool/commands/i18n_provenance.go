@@ -0,0 +1,173 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	provenanceFile    = "provenance.json"
+	provenanceVersion = 1
+)
+
+// provenance remembers the files referencing every translation id, relative
+// to their module root. The ids that lost their references are kept until
+// they leave i18n/en.json, so check can tell where a removed id came from.
+type provenance struct {
+	Version int                 `json:"version"`
+	Keys    map[string][]string `json:"keys"`
+}
+
+// removalAttribution explains the removal of an id: the files that used it
+// and the commit removing the last reference.
+type removalAttribution struct {
+	Id      string   `json:"id"`
+	Files   []string `json:"files,omitempty"`
+	Commit  string   `json:"commit,omitempty"`
+	Subject string   `json:"subject,omitempty"`
+	// Uncommitted is set when the reference is only removed in the working
+	// tree.
+	Uncommitted bool `json:"uncommitted,omitempty"`
+}
+
+func (a removalAttribution) String() string {
+	if details := a.Details(); details != "" {
+		return fmt.Sprintf("%s (%s)", a.Id, details)
+	}
+	return a.Id
+}
+
+// Details describes where the id was used and where it was removed.
+func (a removalAttribution) Details() string {
+	details := []string{}
+	if len(a.Files) > 0 {
+		details = append(details, "was used in "+strings.Join(a.Files, ", "))
+	}
+	if a.Uncommitted {
+		details = append(details, "removed in uncommitted changes")
+	} else if a.Commit != "" {
+		details = append(details, fmt.Sprintf("removed in %s %q", a.Commit, a.Subject))
+	}
+	return strings.Join(details, ", ")
+}
+
+func provenancePath(xeniaDir string) string {
+	return filepath.Join(extractCacheDir(xeniaDir), provenanceFile)
+}
+
+func loadProvenance(xeniaDir string) *provenance {
+	p := &provenance{Version: provenanceVersion, Keys: map[string][]string{}}
+	data, err := readFile(provenancePath(xeniaDir))
+	if err != nil {
+		return p
+	}
+	var stored provenance
+	if err := json.Unmarshal(data, &stored); err != nil || stored.Version != provenanceVersion || stored.Keys == nil {
+		return p
+	}
+	return &stored
+}
+
+// update records the files of the current references, keeping the previous
+// files of the ids in translations that are no longer referenced.
+func (p *provenance) update(refs []keyRef, translations []Translation) {
+	keys := map[string][]string{}
+	for _, ref := range refs {
+		if ref.Path != "" && !contains(keys[ref.Id], ref.Path) {
+			keys[ref.Id] = append(keys[ref.Id], ref.Path)
+		}
+	}
+	for _, t := range translations {
+		if _, referenced := keys[t.Id]; !referenced {
+			if files, ok := p.Keys[t.Id]; ok {
+				keys[t.Id] = files
+			}
+		}
+	}
+	for id := range keys {
+		sort.Strings(keys[id])
+	}
+	p.Keys = keys
+}
+
+func (p *provenance) save(xeniaDir string) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err := mkdirAll(extractCacheDir(xeniaDir), 0755); err != nil {
+		return err
+	}
+	return writeFile(provenancePath(xeniaDir), data, 0644)
+}
+
+// attributeRemoval finds the commit that removed the last reference of id
+// in the git history of the source folders. When HEAD still references it,
+// the removal is not committed yet.
+func attributeRemoval(opts *extractOptions, id string, files []string) removalAttribution {
+	attribution := removalAttribution{Id: id, Files: files}
+	literal := `"` + id + `"`
+	for _, dir := range opts.SourceDirs() {
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		if execCommand("git", "-C", dir, "grep", "-q", "-F", literal, "HEAD", "--", "*.go").Run() == nil {
+			attribution.Uncommitted = true
+			return attribution
+		}
+		output, err := execCommand("git", "-C", dir, "log", "-n", "1", "-S", literal, "--format=%h%x00%s", "--name-only", "--", "*.go").Output()
+		if err != nil || len(output) == 0 {
+			continue
+		}
+		lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+		header := strings.SplitN(lines[0], "\x00", 2)
+		attribution.Commit = header[0]
+		if len(header) == 2 {
+			attribution.Subject = header[1]
+		}
+		if len(attribution.Files) == 0 {
+			for _, line := range lines[1:] {
+				if line = strings.TrimSpace(line); line != "" {
+					attribution.Files = append(attribution.Files, line)
+				}
+			}
+		}
+		return attribution
+	}
+	return attribution
+}
+
+// attributeRemovals explains the removed ids and records the provenance of
+// the current references.
+func attributeRemovals(opts *extractOptions, refs []keyRef, translations []Translation, removed []string) []removalAttribution {
+	known := loadProvenance(opts.XeniaDir)
+	attributions := []removalAttribution{}
+	for _, id := range removed {
+		attributions = append(attributions, attributeRemoval(opts, id, known.Keys[id]))
+	}
+	known.record(opts, refs, translations)
+	return attributions
+}
+
+// recordProvenance stores the files of the current references. It is a
+// no-op with --no-cache.
+func recordProvenance(opts *extractOptions, refs []keyRef, translations []Translation) {
+	loadProvenance(opts.XeniaDir).record(opts, refs, translations)
+}
+
+func (p *provenance) record(opts *extractOptions, refs []keyRef, translations []Translation) {
+	if opts.NoCache {
+		return
+	}
+	p.update(refs, translations)
+	if err := p.save(opts.XeniaDir); err != nil {
+		logger.Warn("Unable to save the provenance of the translation ids", "error", err)
+	}
+}
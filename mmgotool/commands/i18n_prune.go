@@ -0,0 +1,103 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var PruneCmd = &cobra.Command{
+	Use:     "prune",
+	Short:   "Remove orphaned translations",
+	Long:    "Remove from the non English translation files the keys that don't exist in the i18n/en.json file, or in the source code when --from-source is used",
+	Example: "  i18n prune --dry-run",
+	RunE:    pruneCmdF,
+}
+
+func init() {
+	addExtractFlags(PruneCmd)
+	PruneCmd.Flags().Bool("dry-run", false, "List the orphaned keys without modifying the translation files")
+	PruneCmd.Flags().Bool("from-source", false, "Compare against the keys extracted from the source code instead of i18n/en.json")
+	I18nCmd.AddCommand(PruneCmd)
+}
+
+func pruneCmdF(command *cobra.Command, args []string) error {
+	opts, err := getExtractOptions(command)
+	if err != nil {
+		return err
+	}
+	dryRun, err := command.Flags().GetBool("dry-run")
+	if err != nil {
+		return errors.New("Invalid dry-run parameter")
+	}
+	fromSource, err := command.Flags().GetBool("from-source")
+	if err != nil {
+		return errors.New("Invalid from-source parameter")
+	}
+
+	validKeys := map[string]bool{}
+	if fromSource {
+		var problems []extractProblem
+		validKeys, problems, err = getI18nStrings(opts)
+		if err != nil {
+			return err
+		}
+		if len(problems) > 0 {
+			return errors.New("Some source files were skipped, pruning from an incomplete extraction would remove used keys.")
+		}
+		if excluded := opts.ExcludedComponents(); len(excluded) > 0 {
+			return fmt.Errorf("The %s components are not extracted, pruning from an incomplete extraction would remove used keys.", strings.Join(excluded, ", "))
+		}
+	} else {
+		translations, err := getCurrentTranslations(opts.XeniaDir)
+		if err != nil {
+			return err
+		}
+		for _, t := range translations {
+			validKeys[t.Id] = true
+		}
+	}
+
+	files, err := localeFiles(opts.XeniaDir)
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	for _, file := range files {
+		translations, err := readTranslationsFile(file)
+		if err != nil {
+			return err
+		}
+
+		kept := []Translation{}
+		for _, t := range translations {
+			if validKeys[t.Id] {
+				kept = append(kept, t)
+				continue
+			}
+			fmt.Printf("%s: %s\n", localeName(file), t.Id)
+		}
+
+		pruned := len(translations) - len(kept)
+		total += pruned
+		if pruned == 0 || dryRun {
+			continue
+		}
+		if err := writeTranslationsFile(file, kept); err != nil {
+			return err
+		}
+	}
+
+	if dryRun {
+		fmt.Printf("%d orphaned translations found.\n", total)
+	} else {
+		fmt.Printf("%d orphaned translations removed.\n", total)
+	}
+	return nil
+}
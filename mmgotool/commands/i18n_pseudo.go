@@ -0,0 +1,152 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+)
+
+var PseudoCmd = &cobra.Command{
+	Use:   "pseudo",
+	Short: "Generate a pseudo-locale from the English strings",
+	Long: `Generate a pseudo-locale file from i18n/en.json to test the UI without real translations.
+
+Every letter of the English strings is replaced by an accented one, the strings are wrapped in brackets and padded to be about 30% longer. Hard-coded strings stand out as plain English, and truncated brackets reveal the layouts too narrow for longer translations. Template fields, printf verbs, HTML tags and entities are kept as is.`,
+	Example: `  i18n pseudo
+  i18n pseudo --locale en-x-long --expansion 0.5`,
+	RunE: pseudoCmdF,
+}
+
+func init() {
+	PseudoCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	PseudoCmd.Flags().String("locale", "en-x-pseudo", "Locale of the generated file")
+	PseudoCmd.Flags().String("output", "", "File to write, defaults to i18n/<locale>.json")
+	PseudoCmd.Flags().Float64("expansion", 0.3, "Length added to every string, as a fraction of its length")
+	PseudoCmd.Flags().Bool("no-brackets", false, "Don't wrap the strings in brackets")
+	I18nCmd.AddCommand(PseudoCmd)
+}
+
+// pseudoProtectedRegexp matches the parts of a string kept as is.
+var pseudoProtectedRegexp = regexp.MustCompile(templateActionRegexp.String() + `|<[^>]*>|&[A-Za-z]+;|&#[0-9]+;|` + printfVerbRegexp.String())
+
+var pseudoLetters = map[rune]rune{
+	'a': 'á', 'b': 'ƀ', 'c': 'ç', 'd': 'ð', 'e': 'é', 'f': 'ƒ', 'g': 'ĝ', 'h': 'ĥ', 'i': 'î', 'j': 'ĵ', 'k': 'ķ', 'l': 'ļ', 'm': 'ɱ',
+	'n': 'ñ', 'o': 'ö', 'p': 'þ', 'q': 'ǫ', 'r': 'ŕ', 's': 'š', 't': 'ţ', 'u': 'û', 'v': 'ṽ', 'w': 'ŵ', 'x': 'ẋ', 'y': 'ý', 'z': 'ž',
+	'A': 'Å', 'B': 'Ɓ', 'C': 'Ç', 'D': 'Đ', 'E': 'É', 'F': 'Ƒ', 'G': 'Ĝ', 'H': 'Ĥ', 'I': 'Î', 'J': 'Ĵ', 'K': 'Ķ', 'L': 'Ļ', 'M': 'Ṁ',
+	'N': 'Ñ', 'O': 'Ö', 'P': 'Þ', 'Q': 'Ǫ', 'R': 'Ŕ', 'S': 'Š', 'T': 'Ţ', 'U': 'Û', 'V': 'Ṽ', 'W': 'Ŵ', 'X': 'Ẋ', 'Y': 'Ý', 'Z': 'Ž',
+}
+
+type pseudoOptions struct {
+	Expansion float64
+	Brackets  bool
+}
+
+func pseudoAccent(text string) string {
+	return strings.Map(func(r rune) rune {
+		if accented, ok := pseudoLetters[r]; ok {
+			return accented
+		}
+		return r
+	}, text)
+}
+
+// pseudoString accents the text outside of the placeholders and markup, then
+// pads and brackets it.
+func pseudoString(text string, opts pseudoOptions) string {
+	if text == "" {
+		return text
+	}
+	var buf strings.Builder
+	visible := 0
+	last := 0
+	for _, match := range pseudoProtectedRegexp.FindAllStringIndex(text, -1) {
+		buf.WriteString(pseudoAccent(text[last:match[0]]))
+		buf.WriteString(text[match[0]:match[1]])
+		visible += utf8.RuneCountInString(text[last:match[0]])
+		last = match[1]
+	}
+	buf.WriteString(pseudoAccent(text[last:]))
+	visible += utf8.RuneCountInString(text[last:])
+
+	result := buf.String()
+	if padding := int(math.Ceil(float64(visible) * opts.Expansion)); padding > 0 {
+		result += " " + strings.Repeat("~", padding)
+	}
+	if opts.Brackets {
+		result = "[" + result + "]"
+	}
+	return result
+}
+
+// pseudoTranslation transforms a translation, every form of the plural ones.
+func pseudoTranslation(value interface{}, opts pseudoOptions) interface{} {
+	switch v := value.(type) {
+	case string:
+		return pseudoString(v, opts)
+	case map[string]interface{}:
+		forms := map[string]interface{}{}
+		for form, text := range v {
+			forms[form] = pseudoTranslation(text, opts)
+		}
+		return forms
+	}
+	return value
+}
+
+func pseudoCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	locale, err := command.Flags().GetString("locale")
+	if err != nil || locale == "" {
+		return errors.New("Invalid locale parameter")
+	}
+	output, err := command.Flags().GetString("output")
+	if err != nil {
+		return errors.New("Invalid output parameter")
+	}
+	expansion, err := command.Flags().GetFloat64("expansion")
+	if err != nil || expansion < 0 {
+		return errors.New("Invalid expansion parameter")
+	}
+	noBrackets, err := command.Flags().GetBool("no-brackets")
+	if err != nil {
+		return errors.New("Invalid no-brackets parameter")
+	}
+	if locale == "en" {
+		return errors.New("The pseudo-locale can't replace the English strings")
+	}
+	if output == "" {
+		output = filepath.Join(xeniaDir, "i18n", locale+".json")
+	}
+	command.SilenceUsage = true
+
+	source, err := readTranslationsFile(filepath.Join(xeniaDir, "i18n", "en.json"))
+	if err != nil {
+		return err
+	}
+	opts := pseudoOptions{Expansion: expansion, Brackets: !noBrackets}
+	translations := make([]Translation, 0, len(source))
+	for _, t := range source {
+		translations = append(translations, Translation{Id: t.Id, Translation: pseudoTranslation(t.Translation, opts)})
+	}
+	data, err := encodeCatalog(translations, catalogFileFormat(output))
+	if err != nil {
+		return err
+	}
+	if err := writeFile(output, data, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d pseudo-localized strings to %s.\n", len(translations), output)
+	return nil
+}
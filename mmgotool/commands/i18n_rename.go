@@ -0,0 +1,234 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var RenameCmd = &cobra.Command{
+	Use:   "rename <old-id> <new-id>",
+	Short: "Rename a translation id",
+	Long: `Rename a translation id in i18n/en.json, in the translation file of every locale and in the string literal of every Go call site found by extraction.
+
+The new id must not exist yet in i18n/en.json.`,
+	Example: `  i18n rename api.user.login.app_error api.user.login.invalid.app_error
+  i18n rename app.user.get.app_error app.user.get.missing.app_error --dry-run`,
+	Args: cobra.ExactArgs(2),
+	RunE: renameCmdF,
+}
+
+func init() {
+	addExtractFlags(RenameCmd)
+	RenameCmd.Flags().Bool("dry-run", false, "Print a unified diff of the changes instead of writing the files")
+	I18nCmd.AddCommand(RenameCmd)
+}
+
+// renamedFile is the new content of a file touched by a rename.
+type renamedFile struct {
+	Path    string
+	Current []byte
+	Updated []byte
+}
+
+func renameCmdF(command *cobra.Command, args []string) error {
+	opts, err := getExtractOptions(command)
+	if err != nil {
+		return err
+	}
+	dryRun, err := command.Flags().GetBool("dry-run")
+	if err != nil {
+		return errors.New("Invalid dry-run parameter")
+	}
+	oldId, newId := args[0], args[1]
+	if oldId == newId {
+		return errors.New("The old and new ids are the same")
+	}
+	command.SilenceUsage = true
+
+	enJSON := filepath.Join(opts.XeniaDir, "i18n", "en.json")
+	translations, err := readTranslationsFile(enJSON)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, t := range translations {
+		if t.Id == newId {
+			return fmt.Errorf("The id %s already exists in i18n/en.json.", newId)
+		}
+		found = found || t.Id == oldId
+	}
+	if !found {
+		return fmt.Errorf("The id %s doesn't exist in i18n/en.json.", oldId)
+	}
+
+	files, err := localeFiles(opts.XeniaDir)
+	if err != nil {
+		return err
+	}
+	renames := map[string]string{oldId: newId}
+	changes := []renamedFile{}
+	for _, file := range append([]string{enJSON}, files...) {
+		change, err := renameInTranslationsFile(file, renames)
+		if err != nil {
+			return err
+		}
+		if change != nil {
+			changes = append(changes, *change)
+		}
+	}
+	localeChanges := len(changes)
+
+	var walkErr error
+	walkSourceFiles(opts, func(p string) {
+		if walkErr != nil {
+			return
+		}
+		change, err := renameInSourceFile(p, renames)
+		if err != nil {
+			walkErr = err
+			return
+		}
+		if change != nil {
+			changes = append(changes, *change)
+		}
+	})
+	if walkErr != nil {
+		return walkErr
+	}
+
+	for _, change := range changes {
+		name := sourceRelativePath(opts, change.Path)
+		if dryRun {
+			fmt.Print(unifiedDiff("a/"+name, "b/"+name, string(change.Current), string(change.Updated), 3))
+			continue
+		}
+		if err := writeFile(change.Path, change.Updated, 0644); err != nil {
+			return err
+		}
+		logger.Debug("Renamed translation id", "path", name)
+	}
+
+	if !dryRun {
+		fmt.Printf("Renamed %s to %s in %d translation files and %d source files.\n", oldId, newId, localeChanges, len(changes)-localeChanges)
+	}
+	return nil
+}
+
+// renameInTranslationsFile renames the ids of the renames, old id to new id,
+// in a translation file, keeping the file sorted if it was. It returns nil if
+// the file doesn't have any of the ids.
+func renameInTranslationsFile(file string, renames map[string]string) (*renamedFile, error) {
+	current, err := readFile(file)
+	if err != nil {
+		return nil, err
+	}
+	translations, err := readTranslationsFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := sort.SliceIsSorted(translations, func(i, j int) bool { return translations[i].Id < translations[j].Id })
+	renamed := false
+	for i := range translations {
+		if newId, ok := renames[translations[i].Id]; ok {
+			translations[i].Id = newId
+			renamed = true
+		}
+	}
+	if !renamed {
+		return nil, nil
+	}
+	if sorted {
+		sort.SliceStable(translations, func(i, j int) bool { return translations[i].Id < translations[j].Id })
+	}
+
+	updated, err := encodeCatalog(translations, catalogFileFormat(file))
+	if err != nil {
+		return nil, err
+	}
+	return &renamedFile{Path: file, Current: current, Updated: updated}, nil
+}
+
+// renameInSourceFile replaces the literals of the renamed ids at every call
+// site the extraction recognizes. It returns nil if the file doesn't use any
+// of the ids.
+func renameInSourceFile(file string, renames map[string]string) (*renamedFile, error) {
+	src, err := readFile(file)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, src, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	literals := []*ast.BasicLit{}
+	newIds := []string{}
+	for _, literal := range idLiterals(f) {
+		if value, err := strconv.Unquote(literal.Value); err == nil {
+			if newId, ok := renames[value]; ok {
+				literals = append(literals, literal)
+				newIds = append(newIds, newId)
+			}
+		}
+	}
+	if len(literals) == 0 {
+		return nil, nil
+	}
+
+	updated := []byte{}
+	last := 0
+	for i, literal := range literals {
+		newId := newIds[i]
+		start := fset.Position(literal.Pos()).Offset
+		end := fset.Position(literal.End()).Offset
+		replacement := strconv.Quote(newId)
+		if literal.Value[0] == '`' && strconv.CanBackquote(newId) {
+			replacement = "`" + newId + "`"
+		}
+		updated = append(updated, src[last:start]...)
+		updated = append(updated, replacement...)
+		last = end
+	}
+	updated = append(updated, src[last:]...)
+	return &renamedFile{Path: file, Current: src, Updated: updated}, nil
+}
+
+// idLiterals returns, in source order, the string literals the extraction
+// reads translation ids from.
+func idLiterals(f *ast.File) []*ast.BasicLit {
+	literals := []*ast.BasicLit{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch expr := n.(type) {
+		case *ast.CallExpr:
+			if id, ok := sourceExtractor.IdArgument(expr); ok {
+				literals = append(literals, id)
+			}
+		case *ast.GenDecl:
+			if expr.Tok != token.CONST {
+				return true
+			}
+			for _, spec := range expr.Specs {
+				if valueSpec, ok := spec.(*ast.ValueSpec); ok {
+					if id, ok := sourceExtractor.ConstantId(valueSpec); ok {
+						literals = append(literals, id)
+					}
+				}
+			}
+		}
+		return true
+	})
+	return literals
+}
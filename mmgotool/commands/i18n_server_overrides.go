@@ -0,0 +1,164 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// The admin API endpoints below are the ones proposed for the server side
+// string overrides. The validate endpoint never persists anything.
+const (
+	overridesApplyPath    = "/api/v4/translations/overrides"
+	overridesValidatePath = "/api/v4/translations/overrides/validate"
+	adminTokenEnv         = "XENIA_ADMIN_TOKEN"
+)
+
+var ServerOverridesCmd = &cobra.Command{
+	Use:   "server-overrides",
+	Short: "Upload translation overrides to a running server",
+	Long: `Validate the override files locally and send them to a running server through the admin API, reporting which ids the server accepted.
+
+The admin token can be passed with --token or the ` + adminTokenEnv + ` environment variable.`,
+	Example: "  i18n server-overrides --server-url https://xenia.example.com --overrides-dir ./overrides --validate-only",
+	RunE:    serverOverridesCmdF,
+}
+
+func init() {
+	ServerOverridesCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	ServerOverridesCmd.Flags().String("overrides-dir", "", "Location of the override files: "+catalogStoreHelp)
+	ServerOverridesCmd.Flags().String("server-url", "", "URL of the Xenia server")
+	ServerOverridesCmd.Flags().String("token", "", "Admin access token")
+	ServerOverridesCmd.Flags().Bool("validate-only", false, "Ask the server to validate the overrides without applying them")
+	I18nCmd.AddCommand(ServerOverridesCmd)
+}
+
+type overridesRequest struct {
+	Locale       string                 `json:"locale"`
+	Translations map[string]interface{} `json:"translations"`
+}
+
+type overridesResponse struct {
+	Accepted []string `json:"accepted"`
+	Rejected []struct {
+		Id    string `json:"id"`
+		Error string `json:"error"`
+	} `json:"rejected"`
+}
+
+func sendOverrides(client *http.Client, endpoint, token string, request *overridesRequest) (*overridesResponse, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	httpRequest, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.Header.Set("Authorization", "Bearer "+token)
+	httpRequest.Header.Set("Content-Type", "application/json")
+
+	httpResponse, err := client.Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResponse.Body.Close()
+
+	data, err := ioutil.ReadAll(httpResponse.Body)
+	if err != nil {
+		return nil, err
+	}
+	if httpResponse.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Server returned %s: %s", httpResponse.Status, strings.TrimSpace(string(data)))
+	}
+
+	response := &overridesResponse{}
+	if err := json.Unmarshal(data, response); err != nil {
+		return nil, fmt.Errorf("Unable to parse the server response: %s", err.Error())
+	}
+	return response, nil
+}
+
+func serverOverridesCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	overridesDir, err := command.Flags().GetString("overrides-dir")
+	if err != nil || overridesDir == "" {
+		return errors.New("Invalid overrides-dir parameter")
+	}
+	serverURL, err := command.Flags().GetString("server-url")
+	if err != nil || serverURL == "" {
+		return errors.New("Invalid server-url parameter")
+	}
+	token, err := command.Flags().GetString("token")
+	if err != nil {
+		return errors.New("Invalid token parameter")
+	}
+	if token == "" {
+		token = os.Getenv(adminTokenEnv)
+	}
+	if token == "" {
+		return fmt.Errorf("An admin token is required, use --token or %s", adminTokenEnv)
+	}
+	validateOnly, err := command.Flags().GetBool("validate-only")
+	if err != nil {
+		return errors.New("Invalid validate-only parameter")
+	}
+
+	source, err := sourceTranslations(xeniaDir)
+	if err != nil {
+		return err
+	}
+	overrides, problems, err := loadOverrides(overridesDir, source)
+	if err != nil {
+		return err
+	}
+	command.SilenceUsage = true
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		return fmt.Errorf("%d invalid overrides.", len(problems))
+	}
+
+	endpoint := strings.TrimSuffix(serverURL, "/") + overridesApplyPath
+	if validateOnly {
+		endpoint = strings.TrimSuffix(serverURL, "/") + overridesValidatePath
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	rejected := 0
+	for _, o := range overrides {
+		response, err := sendOverrides(client, endpoint, token, &overridesRequest{Locale: o.Locale, Translations: o.Translations})
+		if err != nil {
+			return fmt.Errorf("%s: %s", o.Locale, err.Error())
+		}
+		sort.Strings(response.Accepted)
+		for _, id := range response.Accepted {
+			fmt.Printf("%s: %s: accepted\n", o.Locale, id)
+		}
+		for _, r := range response.Rejected {
+			fmt.Printf("%s: %s: rejected: %s\n", o.Locale, r.Id, r.Error)
+			rejected++
+		}
+	}
+
+	if rejected > 0 {
+		return fmt.Errorf("%d overrides rejected by the server.", rejected)
+	}
+	return nil
+}
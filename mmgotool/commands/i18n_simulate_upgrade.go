@@ -0,0 +1,153 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var SimulateUpgradeCmd = &cobra.Command{
+	Use:   "simulate-upgrade",
+	Short: "Compare the ids served by two releases",
+	Long: `Compare the translations of two releases to reason about rolling upgrades, where servers of both releases serve the same clients.
+
+The report lists the ids requested by the clients of the old release that the new servers no longer serve, the ids of the new release the old servers don't serve yet, and the ids whose placeholders changed, which render with missing values when the client and the server releases differ. The releases are git tags or any other revision of the Xenia repository, "v5.20" also matches the "v5.20.0" tag.`,
+	Example: `  i18n simulate-upgrade --from v5.20 --to v5.21
+  i18n simulate-upgrade --from v5.20 --to HEAD --format json`,
+	RunE: simulateUpgradeCmdF,
+}
+
+func init() {
+	SimulateUpgradeCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	SimulateUpgradeCmd.Flags().String("from", "", "Release or git revision running before the upgrade")
+	SimulateUpgradeCmd.Flags().String("to", "", "Release or git revision running after the upgrade")
+	SimulateUpgradeCmd.Flags().String("format", "text", "Output format: text or json")
+	I18nCmd.AddCommand(SimulateUpgradeCmd)
+}
+
+type placeholderChange struct {
+	Id      string   `json:"id"`
+	Removed []string `json:"removed,omitempty"`
+	Added   []string `json:"added,omitempty"`
+}
+
+func (c placeholderChange) String() string {
+	details := []string{}
+	if len(c.Removed) > 0 {
+		details = append(details, "removed "+strings.Join(c.Removed, ", "))
+	}
+	if len(c.Added) > 0 {
+		details = append(details, "added "+strings.Join(c.Added, ", "))
+	}
+	return fmt.Sprintf("%s (%s)", c.Id, strings.Join(details, ", "))
+}
+
+type upgradeReport struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// RemovedInNew are the ids of the old release the new servers don't
+	// serve.
+	RemovedInNew []string `json:"removed_in_new"`
+	// MissingInOld are the ids of the new release the old servers don't
+	// serve.
+	MissingInOld []string            `json:"missing_in_old"`
+	Placeholders []placeholderChange `json:"placeholders"`
+}
+
+func (r *upgradeReport) Problems() int {
+	return len(r.RemovedInNew) + len(r.MissingInOld) + len(r.Placeholders)
+}
+
+func simulateUpgrade(from, to []Translation) *upgradeReport {
+	report := &upgradeReport{RemovedInNew: []string{}, MissingInOld: []string{}, Placeholders: []placeholderChange{}}
+	old := map[string]interface{}{}
+	for _, t := range from {
+		old[t.Id] = t.Translation
+	}
+	current := map[string]bool{}
+	for _, t := range to {
+		current[t.Id] = true
+		previous, ok := old[t.Id]
+		if !ok {
+			report.MissingInOld = append(report.MissingInOld, t.Id)
+			continue
+		}
+		removed, added := comparePlaceholders(translationPlaceholders(previous), translationPlaceholders(t.Translation))
+		if len(removed) > 0 || len(added) > 0 {
+			report.Placeholders = append(report.Placeholders, placeholderChange{Id: t.Id, Removed: removed, Added: added})
+		}
+	}
+	for _, t := range from {
+		if !current[t.Id] {
+			report.RemovedInNew = append(report.RemovedInNew, t.Id)
+		}
+	}
+	sort.Strings(report.RemovedInNew)
+	sort.Strings(report.MissingInOld)
+	sort.Slice(report.Placeholders, func(i, j int) bool { return report.Placeholders[i].Id < report.Placeholders[j].Id })
+	return report
+}
+
+func simulateUpgradeCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	from, err := command.Flags().GetString("from")
+	if err != nil || from == "" {
+		return errors.New("Invalid from parameter")
+	}
+	to, err := command.Flags().GetString("to")
+	if err != nil || to == "" {
+		return errors.New("Invalid to parameter")
+	}
+	format, err := command.Flags().GetString("format")
+	if err != nil {
+		return errors.New("Invalid format parameter")
+	}
+	if format != "text" && format != "json" {
+		return fmt.Errorf("Unknown format %s", format)
+	}
+	command.SilenceUsage = true
+
+	fromTranslations, fromRevision, err := translationsAtRevision(xeniaDir, from)
+	if err != nil {
+		return err
+	}
+	toTranslations, toRevision, err := translationsAtRevision(xeniaDir, to)
+	if err != nil {
+		return err
+	}
+	report := simulateUpgrade(fromTranslations, toTranslations)
+	report.From, report.To = fromRevision, toRevision
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		for _, id := range report.RemovedInNew {
+			fmt.Printf("Not served by %s: %s\n", toRevision, id)
+		}
+		for _, id := range report.MissingInOld {
+			fmt.Printf("Not served by %s: %s\n", fromRevision, id)
+		}
+		for _, change := range report.Placeholders {
+			fmt.Println("Placeholders changed:", change.String())
+		}
+	}
+	if report.Problems() > 0 {
+		return fmt.Errorf("%d ids differ between %s and %s, clients and servers of both releases must not be mixed for them.", report.Problems(), fromRevision, toRevision)
+	}
+	return nil
+}
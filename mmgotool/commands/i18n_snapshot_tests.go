@@ -0,0 +1,239 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+
+	"github.com/xzl8028/xenia-utilities/mmgotool/internal/codegen"
+)
+
+//go:embed templates/i18n/*.tmpl
+var i18nTemplates embed.FS
+
+// snapshotPluralCounts are the counts used to render each English plural
+// category.
+var snapshotPluralCounts = map[string]int{"one": 1, "other": 2}
+
+var SnapshotTestsCmd = &cobra.Command{
+	Use:   "snapshot-tests",
+	Short: "Translation snapshot tests",
+}
+
+var SnapshotTestsGenCmd = &cobra.Command{
+	Use:   "gen",
+	Short: "Generate the translation snapshot tests",
+	Long: `Generate table-driven Go tests asserting the exact English text of the selected translations, rendered with sample arguments, so copy changes in high-visibility errors show up in the server's own test suite.
+The translations are selected with --key patterns like "api.user.login.*", or with the i18n.snapshot_keys entry of the configuration file. Every placeholder receives the sample value "<Name>" and plural translations get one case per English plural form.`,
+	Example: `  i18n snapshot-tests gen --key "api.user.login.*" --key api.context.session_expired.app_error`,
+	RunE:    snapshotTestsGenCmdF,
+}
+
+func init() {
+	SnapshotTestsGenCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	SnapshotTestsGenCmd.Flags().StringSlice("key", []string{}, "Pattern of the translation ids to snapshot")
+	SnapshotTestsGenCmd.Flags().String("output", "", "Generated test file, defaults to utils/i18n_snapshot_test.go in the Xenia folder")
+	SnapshotTestsGenCmd.Flags().String("package", "utils", "Package of the generated test")
+	SnapshotTestsGenCmd.Flags().Bool("check", false, "Only check that the generated test is up to date")
+	SnapshotTestsCmd.AddCommand(SnapshotTestsGenCmd)
+	I18nCmd.AddCommand(SnapshotTestsCmd)
+}
+
+type snapshotArg struct {
+	Name  string
+	Value string
+}
+
+type snapshotCase struct {
+	Name     string
+	Id       string
+	Plural   bool
+	Count    int
+	Args     []snapshotArg
+	Expected string
+}
+
+type snapshotTestData struct {
+	Package   string
+	Selection string
+	Cases     []snapshotCase
+}
+
+// renderSnapshot executes an English translation like the server does, with
+// a sample value for every template field.
+func renderSnapshot(text string, count int, plural bool) (string, []snapshotArg, error) {
+	args := []snapshotArg{}
+	data := map[string]interface{}{}
+	for _, placeholder := range extractPlaceholders(text) {
+		if !strings.HasPrefix(placeholder, "{{.") {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(placeholder, "{{."), "}}")
+		if plural && name == "Count" {
+			continue
+		}
+		value := "<" + name + ">"
+		data[name] = value
+		args = append(args, snapshotArg{Name: name, Value: strconv.Quote(value)})
+	}
+	if plural {
+		data["Count"] = count
+	}
+
+	tmpl, err := template.New("").Parse(text)
+	if err != nil {
+		return "", nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", nil, err
+	}
+	return buf.String(), args, nil
+}
+
+func matchesAnyPattern(id string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, id); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func snapshotCases(translations []Translation, patterns []string) ([]snapshotCase, error) {
+	cases := []snapshotCase{}
+	for _, t := range translations {
+		if !matchesAnyPattern(t.Id, patterns) {
+			continue
+		}
+		if text, ok := t.Translation.(string); ok {
+			expected, args, err := renderSnapshot(text, 0, false)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to render %s: %s", t.Id, err.Error())
+			}
+			cases = append(cases, snapshotCase{Name: t.Id, Id: t.Id, Args: args, Expected: expected})
+			continue
+		}
+
+		forms, ok := t.PluralForms()
+		if !ok {
+			continue
+		}
+		categories := []string{}
+		for category := range forms {
+			if _, ok := snapshotPluralCounts[category]; ok {
+				categories = append(categories, category)
+			}
+		}
+		sort.Strings(categories)
+		for _, category := range categories {
+			count := snapshotPluralCounts[category]
+			expected, args, err := renderSnapshot(forms[category], count, true)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to render %s: %s", t.Id, err.Error())
+			}
+			cases = append(cases, snapshotCase{
+				Name:     t.Id + "/" + category,
+				Id:       t.Id,
+				Plural:   true,
+				Count:    count,
+				Args:     args,
+				Expected: expected,
+			})
+		}
+	}
+	return cases, nil
+}
+
+func snapshotTestsGenCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	patterns, err := command.Flags().GetStringSlice("key")
+	if err != nil {
+		return errors.New("Invalid key parameter")
+	}
+	output, err := command.Flags().GetString("output")
+	if err != nil {
+		return errors.New("Invalid output parameter")
+	}
+	pkg, err := command.Flags().GetString("package")
+	if err != nil {
+		return errors.New("Invalid package parameter")
+	}
+	check, err := command.Flags().GetBool("check")
+	if err != nil {
+		return errors.New("Invalid check parameter")
+	}
+
+	if len(patterns) == 0 {
+		config, err := loadToolConfig(xeniaDir)
+		if err != nil {
+			return err
+		}
+		patterns = config.I18n.SnapshotKeys
+	}
+	if len(patterns) == 0 {
+		return errors.New("No translation selected, use --key or the i18n.snapshot_keys configuration.")
+	}
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("Invalid key pattern %q.", pattern)
+		}
+	}
+	if output == "" {
+		output = filepath.Join(xeniaDir, "utils", "i18n_snapshot_test.go")
+	}
+	command.SilenceUsage = true
+
+	translations, err := getCurrentTranslations(xeniaDir)
+	if err != nil {
+		return err
+	}
+	cases, err := snapshotCases(translations, patterns)
+	if err != nil {
+		return err
+	}
+	if len(cases) == 0 {
+		return errors.New("No translation matches the selected keys.")
+	}
+
+	engine, err := codegen.New("i18n snapshot-tests gen", i18nTemplates, "templates/i18n/*.tmpl")
+	if err != nil {
+		return err
+	}
+	data := snapshotTestData{Package: pkg, Selection: strings.Join(patterns, ", "), Cases: cases}
+	if err := sandbox.checkPath(output); err != nil {
+		return err
+	}
+
+	if check {
+		upToDate, err := engine.Check(output, "snapshot_test.go", data)
+		if err != nil {
+			return err
+		}
+		if !upToDate {
+			return fmt.Errorf("%s is out of date.", output)
+		}
+		return nil
+	}
+
+	if err := engine.WriteFile(output, "snapshot_test.go", data); err != nil {
+		return err
+	}
+	fmt.Printf("%d snapshot cases written to %s\n", len(cases), output)
+	return nil
+}
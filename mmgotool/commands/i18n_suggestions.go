@@ -0,0 +1,60 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// keySuggestion is an id of the catalog close to an id missing from it, the
+// missing one being likely a typo.
+type keySuggestion struct {
+	Id string `json:"id"`
+	// Location is the first call site of the missing id.
+	Location   string `json:"location,omitempty"`
+	Suggestion string `json:"suggestion"`
+	// SuggestionLocation is the catalog line of the suggested id.
+	SuggestionLocation string `json:"suggestion_location"`
+}
+
+func (s keySuggestion) String() string {
+	location := ""
+	if s.Location != "" {
+		location = " (" + s.Location + ")"
+	}
+	return fmt.Sprintf("%s%s, did you mean %s (%s)?", s.Id, location, s.Suggestion, s.SuggestionLocation)
+}
+
+// suggestMissingKeys returns the ids of the catalog at most two edits away
+// from the missing ones.
+func suggestMissingKeys(opts *extractOptions, refs []keyRef, translations []Translation, missing []string) []keySuggestion {
+	suggestions := []keySuggestion{}
+	if len(missing) == 0 {
+		return suggestions
+	}
+	known := make([]string, 0, len(translations))
+	for _, t := range translations {
+		known = append(known, t.Id)
+	}
+	first := firstRefs(refs)
+	locator := newCatalogLocator(opts, translations)
+	for _, id := range missing {
+		closest, ok := closestName(id, known)
+		if !ok {
+			continue
+		}
+		suggestion := keySuggestion{Id: id, Suggestion: closest}
+		if ref, ok := first[id]; ok {
+			suggestion.Location = refLocation(opts, ref) + ":" + strconv.Itoa(ref.Line)
+		}
+		file, line := locator.Locate(closest)
+		suggestion.SuggestionLocation = file
+		if line > 0 {
+			suggestion.SuggestionLocation += ":" + strconv.Itoa(line)
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions
+}
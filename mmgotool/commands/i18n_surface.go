@@ -0,0 +1,127 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var SurfaceCmd = &cobra.Command{
+	Use:   "surface",
+	Short: "Report the translation ids used by every package or file",
+	Long: `Report how many translation ids every package of the source code uses, largest first, to find the code owning most of the strings.
+
+With --by-file the report lists the files instead of the packages. An id used by several files counts for each of them.`,
+	Example: `  i18n surface
+  i18n surface --by-file --limit 30`,
+	RunE: surfaceCmdF,
+}
+
+func init() {
+	addExtractFlags(SurfaceCmd)
+	SurfaceCmd.Flags().Bool("by-file", false, "Report every Go file instead of every package")
+	SurfaceCmd.Flags().Int("limit", 20, "Number of packages or files reported, 0 for all")
+	SurfaceCmd.Flags().String("format", "text", "Output format: text or json")
+	I18nCmd.AddCommand(SurfaceCmd)
+}
+
+type surfaceEntry struct {
+	Path string `json:"path"`
+	Keys int    `json:"keys"`
+	// Calls are the translation calls, an id can be used several times.
+	Calls int `json:"calls"`
+}
+
+// translationSurface counts the ids and the calls of every file, or of
+// every folder.
+func translationSurface(refs []keyRef, byFile bool) []surfaceEntry {
+	keys := map[string]map[string]bool{}
+	calls := map[string]int{}
+	for _, ref := range refs {
+		owner := ref.Path
+		if !byFile {
+			owner = path.Dir(ref.Path)
+		}
+		if keys[owner] == nil {
+			keys[owner] = map[string]bool{}
+		}
+		keys[owner][ref.Id] = true
+		calls[owner]++
+	}
+
+	entries := []surfaceEntry{}
+	for owner, ids := range keys {
+		entries = append(entries, surfaceEntry{Path: owner, Keys: len(ids), Calls: calls[owner]})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Keys != entries[j].Keys {
+			return entries[i].Keys > entries[j].Keys
+		}
+		return entries[i].Path < entries[j].Path
+	})
+	return entries
+}
+
+func surfaceCmdF(command *cobra.Command, args []string) error {
+	opts, err := getExtractOptions(command)
+	if err != nil {
+		return err
+	}
+	byFile, err := command.Flags().GetBool("by-file")
+	if err != nil {
+		return errors.New("Invalid by-file parameter")
+	}
+	limit, err := command.Flags().GetInt("limit")
+	if err != nil || limit < 0 {
+		return errors.New("Invalid limit parameter")
+	}
+	format, err := command.Flags().GetString("format")
+	if err != nil {
+		return errors.New("Invalid format parameter")
+	}
+	if format != "text" && format != "json" {
+		return fmt.Errorf("Unknown format %s", format)
+	}
+	command.SilenceUsage = true
+
+	refs, problems := extractKeyRefs(opts)
+	if err := reportExtractProblems(opts, problems); err != nil {
+		return err
+	}
+	entries := translationSurface(refs, byFile)
+	total := len(entries)
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if byFile {
+		fmt.Fprintln(w, "KEYS\tCALLS\tFILE")
+	} else {
+		fmt.Fprintln(w, "KEYS\tCALLS\tPACKAGE")
+	}
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%d\t%d\t%s\n", entry.Keys, entry.Calls, entry.Path)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if len(entries) < total {
+		fmt.Printf("%d more, use --limit 0 to list them all.\n", total-len(entries))
+	}
+	return nil
+}
@@ -0,0 +1,708 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// triageContextLines is the number of source lines shown before and after
+// the call site of an added id.
+const triageContextLines = 2
+
+var TriageCmd = &cobra.Command{
+	Use:   "triage",
+	Short: "Review the added and removed translation ids one by one",
+	Long: `Walk through the ids extract would add to or remove from i18n/en.json, showing the source code using every added id and the English string of every removed one, and decide for each of them instead of accepting the whole extraction.
+
+An added id can be accepted, renamed to another new id, mapped to an existing id, the call sites being rewritten in both cases, or skipped. A removed id can be deleted or kept. Mapping an added id to a removed one keeps the removed string and its translations, the usual fix of a refactor renaming ids. Quitting keeps the decisions taken so far, the other ids are left as they are. The source files and i18n/en.json are written at the end, once confirmed.
+
+In a terminal the ids are listed on a full screen interface: the up and down arrows, page up and page down move between them, the first letter of an action decides the current id and u forgets its decision, so the earlier decisions can be changed until quitting with q. Ctrl-C aborts without writing anything. When the input is not a terminal, or with --plain, the ids are asked one after the other with line prompts answered by the first letter of the action.`,
+	Example: `  i18n triage --xenia-dir ../xenia-server --enterprise-dir ../enterprise
+  i18n triage --dry-run`,
+	Args: cobra.NoArgs,
+	RunE: triageCmdF,
+}
+
+func init() {
+	addExtractFlags(TriageCmd)
+	addPlaceholderFlag(TriageCmd)
+	TriageCmd.Flags().Bool("dry-run", false, "Print a unified diff of the changes instead of writing the files")
+	TriageCmd.Flags().Bool("plain", false, "Ask the decisions one by one with line prompts instead of the full screen interface")
+	I18nCmd.AddCommand(TriageCmd)
+}
+
+const (
+	triageAccept = "accept"
+	triageRename = "rename"
+	triageMap    = "map"
+	triageSkip   = "skip"
+	triageDelete = "delete"
+	triageKeep   = "keep"
+	triageQuit   = "quit"
+)
+
+// triageDecision is the choice made for an id, Target being the new id of
+// the renamed and mapped ones.
+type triageDecision struct {
+	Id     string
+	Action string
+	Target string
+}
+
+// triageSession asks the decisions of the added and removed ids.
+type triageSession struct {
+	prompt       *prompter
+	opts         *extractOptions
+	refs         map[string][]keyRef
+	translations map[string]Translation
+	locator      *catalogLocator
+	added        []string
+	removed      []string
+	// decisions are the decisions of the added then the removed ids, nil
+	// while undecided.
+	decisions []*triageDecision
+	// height is the number of lines of the terminal of the full screen
+	// interface, 0 when unknown.
+	height int
+}
+
+func (s *triageSession) total() int {
+	return len(s.added) + len(s.removed)
+}
+
+// item returns the id at a position, the added ids coming first, and
+// whether it is added.
+func (s *triageSession) item(position int) (string, bool) {
+	if position < len(s.added) {
+		return s.added[position], true
+	}
+	return s.removed[position-len(s.added)], false
+}
+
+// actions returns the actions of the id at a position. The ids built at
+// runtime have no call site to rewrite.
+func (s *triageSession) actions(position int) []string {
+	id, added := s.item(position)
+	switch {
+	case !added:
+		return []string{triageDelete, triageKeep}
+	case len(s.refs[id]) == 0:
+		return []string{triageAccept, triageSkip}
+	}
+	return []string{triageAccept, triageRename, triageMap, triageSkip}
+}
+
+// actionsHelp lists the actions with their shortcut, like [a]ccept, and the
+// argument of rename and map for the line prompts.
+func actionsHelp(actions []string, arguments bool) string {
+	help := []string{}
+	for _, action := range actions {
+		text := "[" + action[:1] + "]" + action[1:]
+		if arguments && action == triageRename {
+			text += " <new-id>"
+		} else if arguments && action == triageMap {
+			text += " <existing-id>"
+		}
+		help = append(help, text)
+	}
+	return strings.Join(help, ", ")
+}
+
+// suggestion returns the known id closest to an added id, the removed ones
+// first.
+func (s *triageSession) suggestion(id string) (string, bool) {
+	if suggestion, ok := closestName(id, s.removed); ok {
+		return suggestion, true
+	}
+	known := []string{}
+	for knownId := range s.translations {
+		known = append(known, knownId)
+	}
+	sort.Strings(known)
+	return closestName(id, known)
+}
+
+// mappedFrom returns the added id mapped to a removed id, "" when none.
+func (s *triageSession) mappedFrom(id string) string {
+	for _, decision := range s.decisions {
+		if decision != nil && decision.Action == triageMap && decision.Target == id {
+			return decision.Id
+		}
+	}
+	return ""
+}
+
+// isNewId tells whether an id can be the target of the rename at a
+// position: it must be unknown and not the target of another decision.
+func (s *triageSession) isNewId(target string, position int) bool {
+	if _, exists := s.translations[target]; exists || target == "" || len(s.refs[target]) > 0 {
+		return false
+	}
+	for i, decision := range s.decisions {
+		if i != position && decision != nil && decision.Action == triageRename && decision.Target == target {
+			return false
+		}
+	}
+	return true
+}
+
+// decide records the decision of the id at a position, replacing the
+// previous one, and returns why it can't be taken, "" when it is.
+func (s *triageSession) decide(position int, action, target string) string {
+	id, added := s.item(position)
+	if from := s.mappedFrom(id); !added && from != "" {
+		return fmt.Sprintf("%s is kept, %s is mapped to it.", id, from)
+	}
+	switch action {
+	case triageRename:
+		if !s.isNewId(target, position) {
+			return fmt.Sprintf("%q is not a new id.", target)
+		}
+	case triageMap:
+		if _, exists := s.translations[target]; !exists {
+			return fmt.Sprintf("%q is not in i18n/en.json.", target)
+		}
+	default:
+		target = ""
+	}
+	s.decisions[position] = &triageDecision{Id: id, Action: action, Target: target}
+	return ""
+}
+
+// result returns the decisions taken, the removed ids mapped from an added
+// one being kept.
+func (s *triageSession) result() []triageDecision {
+	decisions := []triageDecision{}
+	for position, decision := range s.decisions {
+		id, added := s.item(position)
+		if !added && s.mappedFrom(id) != "" {
+			decisions = append(decisions, triageDecision{Id: id, Action: triageKeep})
+		} else if decision != nil {
+			decisions = append(decisions, *decision)
+		}
+	}
+	return decisions
+}
+
+// sourceContext prints the lines around the first call site of an id.
+func (s *triageSession) sourceContext(out io.Writer, id string) {
+	refs := s.refs[id]
+	if len(refs) == 0 {
+		fmt.Fprintln(out, "  Built at runtime, no call site.")
+		return
+	}
+	ref := refs[0]
+	location := fmt.Sprintf("%s:%d", refLocation(s.opts, ref), ref.Line)
+	if len(refs) > 1 {
+		location += fmt.Sprintf(" and %d more call sites", len(refs)-1)
+	}
+	fmt.Fprintf(out, "  %s\n", location)
+
+	data, err := readFile(filepath.Join(s.opts.moduleDir(ref.Module), ref.Path))
+	if err != nil || ref.Line == 0 {
+		return
+	}
+	lines := strings.Split(string(data), "\n")
+	for line := ref.Line - triageContextLines; line <= ref.Line+triageContextLines; line++ {
+		if line < 1 || line > len(lines) {
+			continue
+		}
+		marker := " "
+		if line == ref.Line {
+			marker = ">"
+		}
+		fmt.Fprintf(out, "  %s %5d | %s\n", marker, line, lines[line-1])
+	}
+}
+
+// describe prints the id at a position with its context: the source code
+// using an added id, the English string of a removed one.
+func (s *triageSession) describe(out io.Writer, position int) {
+	id, added := s.item(position)
+	if !added {
+		text := "plural string"
+		if current, ok := s.translations[id].Translation.(string); ok {
+			text = fmt.Sprintf("%q", current)
+		}
+		file, line := s.locator.Locate(id)
+		fmt.Fprintf(out, "Removed %s: %s\n  %s:%d\n", id, text, file, line)
+		return
+	}
+	fmt.Fprintf(out, "Added %s\n", id)
+	s.sourceContext(out, id)
+	if suggestion, ok := s.suggestion(id); ok && len(s.refs[id]) > 0 {
+		fmt.Fprintf(out, "  Close to %s, \"map\" uses it.\n", suggestion)
+	}
+}
+
+// choose asks an action among the ones of the shortcuts, by first letter,
+// with its argument. The end of the input quits.
+func (s *triageSession) choose(question string, actions []string, def string) (string, string) {
+	for {
+		fmt.Fprintf(s.prompt.out, "%s [%s]: ", question, def[:1])
+		answer, err := s.prompt.in.ReadString('\n')
+		fields := strings.Fields(answer)
+		if len(fields) == 0 {
+			if err != nil {
+				fmt.Fprintln(s.prompt.out)
+				return triageQuit, ""
+			}
+			return def, ""
+		}
+		for _, action := range actions {
+			if fields[0] == action || fields[0] == action[:1] {
+				argument := ""
+				if len(fields) > 1 {
+					argument = fields[1]
+				}
+				return action, argument
+			}
+		}
+		fmt.Fprintf(s.prompt.out, "Unknown answer %s.\n", fields[0])
+	}
+}
+
+// ask asks the decision of the id at a position with a line prompt,
+// returning false when quitting.
+func (s *triageSession) ask(position int) bool {
+	id, _ := s.item(position)
+	actions := append(s.actions(position), triageQuit)
+	for {
+		action, target := s.choose("  "+actionsHelp(actions, true), actions, actions[0])
+		switch action {
+		case triageQuit:
+			return false
+		case triageRename:
+			if target == "" {
+				target = s.prompt.ask("  New id", "")
+			}
+		case triageMap:
+			if target == "" {
+				target, _ = s.suggestion(id)
+			}
+		}
+		if problem := s.decide(position, action, target); problem != "" {
+			fmt.Fprintf(s.prompt.out, "  %s\n", problem)
+			continue
+		}
+		return true
+	}
+}
+
+// runPrompts asks the decisions one after the other with line prompts, the
+// added ids first as mapping them can use the removed ones. It is used when
+// the input is not a terminal, like a script answering the prompts.
+func (s *triageSession) runPrompts() {
+	for position := 0; position < s.total(); position++ {
+		id, added := s.item(position)
+		fmt.Fprintf(s.prompt.out, "\n[%d/%d] ", position+1, s.total())
+		if !added && s.mappedFrom(id) != "" {
+			fmt.Fprintf(s.prompt.out, "Removed %s: kept, mapped from an added id.\n", id)
+			continue
+		}
+		s.describe(s.prompt.out, position)
+		if !s.ask(position) {
+			return
+		}
+	}
+}
+
+// triageKeys are the escape sequences of the keys moving between the ids
+// on the full screen interface.
+var triageKeys = map[string]string{
+	"\x1b[A":  "up",
+	"\x1bOA":  "up",
+	"\x1b[B":  "down",
+	"\x1bOB":  "down",
+	"\x1b[5~": "page-up",
+	"\x1b[6~": "page-down",
+	"\x1b[H":  "home",
+	"\x1b[1~": "home",
+	"\x1b[F":  "end",
+	"\x1b[4~": "end",
+}
+
+// readKey reads a key of a terminal in raw mode, the escape sequence of a
+// special key being read at once.
+func readKey(in *bufio.Reader) (string, error) {
+	b, err := in.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	if b != '\x1b' || in.Buffered() == 0 {
+		return string(b), nil
+	}
+	sequence := []byte{b}
+	for in.Buffered() > 0 {
+		c, _ := in.ReadByte()
+		sequence = append(sequence, c)
+		if len(sequence) > 2 && (c == '~' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')) {
+			break
+		}
+	}
+	if key, ok := triageKeys[string(sequence)]; ok {
+		return key, nil
+	}
+	return string(sequence), nil
+}
+
+// readLine reads a line on the last line of the screen, starting with text.
+// Escape and ctrl-c cancel it.
+func (s *triageSession) readLine(prompt, text string) (string, bool) {
+	for {
+		fmt.Fprintf(s.prompt.out, "\r\x1b[K%s%s", prompt, text)
+		key, err := readKey(s.prompt.in)
+		if err != nil {
+			return "", false
+		}
+		switch key {
+		case "\r", "\n":
+			return strings.TrimSpace(text), true
+		case "\x1b", "\x03":
+			return "", false
+		case "\x7f", "\b":
+			if text != "" {
+				_, size := utf8.DecodeLastRuneInString(text)
+				text = text[:len(text)-size]
+			}
+		default:
+			if len(key) == 1 && key[0] >= ' ' {
+				text += key
+			}
+		}
+	}
+}
+
+// listHeight is the number of ids listed on the screen, the rest of the
+// terminal showing the context of the current one.
+func (s *triageSession) listHeight() int {
+	if s.height-16 < 5 {
+		return 5
+	}
+	return s.height - 16
+}
+
+// label returns the decision of the id at a position as listed on the
+// screen.
+func (s *triageSession) label(position int) string {
+	id, added := s.item(position)
+	if from := s.mappedFrom(id); !added && from != "" {
+		return "[keep  ] removed " + id + " <- " + from
+	}
+	kind := "removed"
+	if added {
+		kind = "added  "
+	}
+	decision := s.decisions[position]
+	switch {
+	case decision == nil:
+		return "[      ] " + kind + " " + id
+	case decision.Target != "":
+		return fmt.Sprintf("[%-6s] %s %s -> %s", decision.Action, kind, id, decision.Target)
+	}
+	return fmt.Sprintf("[%-6s] %s %s", decision.Action, kind, id)
+}
+
+// draw renders the screen: the ids around the current one with their
+// decisions, the context of the current one and the keys.
+func (s *triageSession) draw(current int, message string) {
+	var screen bytes.Buffer
+	decided := 0
+	for _, decision := range s.decisions {
+		if decision != nil {
+			decided++
+		}
+	}
+	fmt.Fprintf(&screen, "Triage of %d added and %d removed ids, %d decided\n\n", len(s.added), len(s.removed), decided)
+
+	first := current - s.listHeight()/2
+	if first > s.total()-s.listHeight() {
+		first = s.total() - s.listHeight()
+	}
+	if first < 0 {
+		first = 0
+	}
+	for position := first; position < s.total() && position < first+s.listHeight(); position++ {
+		marker := " "
+		if position == current {
+			marker = ">"
+		}
+		fmt.Fprintf(&screen, "%s %s\n", marker, s.label(position))
+	}
+
+	screen.WriteString("\n")
+	s.describe(&screen, current)
+	fmt.Fprintf(&screen, "\n%s, [u]ndecide, up/down: move, [q]uit, ctrl-c: abort\n", actionsHelp(s.actions(current), false))
+	if message != "" {
+		fmt.Fprintln(&screen, message)
+	}
+	// The raw mode doesn't translate the new lines.
+	fmt.Fprint(s.prompt.out, "\x1b[H\x1b[2J"+strings.Replace(screen.String(), "\n", "\r\n", -1))
+}
+
+// runScreen asks the decisions on a full screen interface, where the ids
+// are decided in any order and the decisions changed until quitting. The
+// terminal must be in raw mode. It returns false when aborted.
+func (s *triageSession) runScreen() bool {
+	current, message := 0, ""
+	for {
+		s.draw(current, message)
+		message = ""
+		key, err := readKey(s.prompt.in)
+		if err != nil {
+			return true
+		}
+		switch key {
+		case "up", "p":
+			current--
+		case "down", "n":
+			current++
+		case "page-up":
+			current -= s.listHeight()
+		case "page-down":
+			current += s.listHeight()
+		case "home":
+			current = 0
+		case "end":
+			current = s.total() - 1
+		case "u":
+			s.decisions[current] = nil
+		case "q", "\x04":
+			return true
+		case "\x03":
+			return false
+		default:
+			action := ""
+			for _, candidate := range s.actions(current) {
+				if key == candidate[:1] {
+					action = candidate
+				}
+			}
+			if action == "" {
+				message = fmt.Sprintf("Unknown key %q.", key)
+				continue
+			}
+			id, _ := s.item(current)
+			target, ok := "", true
+			switch action {
+			case triageRename:
+				target, ok = s.readLine("New id: ", "")
+			case triageMap:
+				suggestion, _ := s.suggestion(id)
+				target, ok = s.readLine("Existing id: ", suggestion)
+			}
+			if !ok {
+				continue
+			}
+			if message = s.decide(current, action, target); message == "" {
+				current++
+			}
+		}
+		if current >= s.total() {
+			current = s.total() - 1
+		}
+		if current < 0 {
+			current = 0
+		}
+	}
+}
+
+// applyTriage returns the ids of the catalog and the references once the
+// decisions applied, and the renames of the call sites. The ids without
+// decision stay as they are: added ones are not added, removed ones kept.
+func applyTriage(i18nStrings map[string]bool, refs []keyRef, added, removed []string, decisions []triageDecision) (map[string]bool, []keyRef, map[string]string) {
+	used := map[string]bool{}
+	for id := range i18nStrings {
+		used[id] = true
+	}
+	for _, id := range added {
+		delete(used, id)
+	}
+	for _, id := range removed {
+		used[id] = true
+	}
+
+	renames := map[string]string{}
+	for _, decision := range decisions {
+		switch decision.Action {
+		case triageAccept:
+			used[decision.Id] = true
+		case triageRename, triageMap:
+			renames[decision.Id] = decision.Target
+			used[decision.Target] = true
+		case triageDelete:
+			delete(used, decision.Id)
+		}
+	}
+
+	renamedRefs := make([]keyRef, len(refs))
+	for i, ref := range refs {
+		if target, ok := renames[ref.Id]; ok {
+			ref.Id = target
+		}
+		renamedRefs[i] = ref
+	}
+	return used, renamedRefs, renames
+}
+
+func triageCmdF(command *cobra.Command, args []string) error {
+	opts, err := getExtractOptions(command)
+	if err != nil {
+		return err
+	}
+	placeholder, err := getPlaceholder(command, opts.XeniaDir)
+	if err != nil {
+		return err
+	}
+	dryRun, err := command.Flags().GetBool("dry-run")
+	if err != nil {
+		return errors.New("Invalid dry-run parameter")
+	}
+	plain, err := command.Flags().GetBool("plain")
+	if err != nil {
+		return errors.New("Invalid plain parameter")
+	}
+	command.SilenceUsage = true
+
+	refs, problems := extractKeyRefs(opts)
+	if err := reportExtractProblems(opts, problems); err != nil {
+		return err
+	}
+	i18nStrings := i18nStringsFromRefs(opts, refs)
+	translations, err := getCurrentTranslations(opts.XeniaDir)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		keepTranslations(i18nStrings, translations)
+	}
+	keepExcludedTranslations(opts, i18nStrings, translations)
+	added, removed := diffTranslations(i18nStrings, translations)
+	if len(added)+len(removed) == 0 {
+		fmt.Println("No translation id to triage, i18n/en.json is up to date.")
+		return nil
+	}
+
+	session := &triageSession{
+		prompt:       &prompter{in: bufio.NewReader(os.Stdin), out: os.Stdout},
+		opts:         opts,
+		refs:         map[string][]keyRef{},
+		translations: map[string]Translation{},
+		locator:      newCatalogLocator(opts, translations),
+		added:        added,
+		removed:      removed,
+		decisions:    make([]*triageDecision, len(added)+len(removed)),
+	}
+	for _, ref := range refs {
+		session.refs[ref.Id] = append(session.refs[ref.Id], ref)
+	}
+	for _, t := range translations {
+		session.translations[t.Id] = t
+	}
+	stdin, stdout := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !plain && term.IsTerminal(stdin) && term.IsTerminal(stdout) {
+		state, err := term.MakeRaw(stdin)
+		if err != nil {
+			return err
+		}
+		if _, height, err := term.GetSize(stdout); err == nil {
+			session.height = height
+		}
+		// The alternate screen gives the terminal back as it was.
+		fmt.Print("\x1b[?1049h")
+		completed := session.runScreen()
+		fmt.Print("\x1b[?1049l")
+		if err := term.Restore(stdin, state); err != nil {
+			return err
+		}
+		if !completed {
+			return errors.New("Triage aborted, nothing written.")
+		}
+	} else {
+		session.runPrompts()
+	}
+	decisions := session.result()
+
+	counts := map[string]int{}
+	for _, decision := range decisions {
+		counts[decision.Action]++
+	}
+	fmt.Printf("\n%d accepted, %d renamed, %d mapped, %d skipped, %d deleted, %d kept, %d left undecided.\n",
+		counts[triageAccept], counts[triageRename], counts[triageMap], counts[triageSkip], counts[triageDelete], counts[triageKeep], len(added)+len(removed)-len(decisions))
+	if len(decisions) == counts[triageSkip]+counts[triageKeep] {
+		return nil
+	}
+
+	used, renamedRefs, renames := applyTriage(i18nStrings, refs, added, removed, decisions)
+	changes := []renamedFile{}
+	files := map[string]bool{}
+	for _, ref := range refs {
+		file := filepath.Join(opts.moduleDir(ref.Module), ref.Path)
+		if _, ok := renames[ref.Id]; !ok || files[file] {
+			continue
+		}
+		files[file] = true
+		var change *renamedFile
+		if path.Ext(file) == ".go" {
+			if change, err = renameInSourceFile(file, renames); err != nil {
+				return err
+			}
+		}
+		if change == nil {
+			logger.Warn("Unable to rename the call sites, the id is not a Go string literal", "path", refLocation(opts, ref), "id", ref.Id)
+			continue
+		}
+		changes = append(changes, *change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+
+	result := mergeTranslations(translations, used, renamedRefs, placeholder)
+	for name, catalog := range englishCatalogs(result, enterpriseCatalogSplit(opts.XeniaDir)) {
+		file := path.Join(opts.XeniaDir, "i18n", name)
+		current, err := readFile(file)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		updated, err := encodeCatalog(catalog, catalogFileFormat(file))
+		if err != nil {
+			return err
+		}
+		if string(current) != string(updated) {
+			changes = append(changes, renamedFile{Path: file, Current: current, Updated: updated})
+		}
+	}
+
+	if dryRun {
+		for _, change := range changes {
+			name := findingPath(change.Path)
+			fmt.Print(unifiedDiff("a/"+name, "b/"+name, string(change.Current), string(change.Updated), 3))
+		}
+		return nil
+	}
+	if !session.prompt.confirm(fmt.Sprintf("Write the %d changed files?", len(changes)), true) {
+		return errors.New("Nothing written.")
+	}
+	for _, change := range changes {
+		if err := writeFile(change.Path, change.Updated, 0644); err != nil {
+			return err
+		}
+		logger.Debug("Triaged translation ids", "path", findingPath(change.Path))
+	}
+	fmt.Printf("Wrote %d files.\n", len(changes))
+	return nil
+}
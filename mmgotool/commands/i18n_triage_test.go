@@ -0,0 +1,81 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bufio"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTriageScreen(t *testing.T) {
+	const (
+		up        = "\x1b[A"
+		backspace = "\x7f"
+	)
+	clear := strings.Repeat(backspace, 40)
+	tests := []struct {
+		name      string
+		keys      string
+		completed bool
+		decisions []triageDecision
+	}{
+		{
+			name:      "decisions in order",
+			keys:      "asdq",
+			completed: true,
+			decisions: []triageDecision{{Id: "app.user.get", Action: triageAccept}, {Id: "app.user.list", Action: triageSkip}, {Id: "app.user.fetch", Action: triageDelete}},
+		},
+		{
+			name:      "going back over the decisions",
+			keys:      "asd" + up + up + "m" + clear + "app.user.fetch\r" + "u" + "q",
+			completed: true,
+			decisions: []triageDecision{{Id: "app.user.get", Action: triageMap, Target: "app.user.fetch"}, {Id: "app.user.fetch", Action: triageKeep}},
+		},
+		{
+			name:      "rename to an existing id",
+			keys:      "r" + "app.user.fetch\r" + "r" + "app.user.read\r",
+			completed: true,
+			decisions: []triageDecision{{Id: "app.user.get", Action: triageRename, Target: "app.user.read"}},
+		},
+		{
+			name:      "cancelled rename",
+			keys:      "rapp.user.read\x1b" + "n" + "k",
+			completed: true,
+			decisions: []triageDecision{},
+		},
+		{
+			name:      "abort",
+			keys:      "as\x03",
+			completed: false,
+			decisions: []triageDecision{{Id: "app.user.get", Action: triageAccept}, {Id: "app.user.list", Action: triageSkip}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := &extractOptions{XeniaDir: t.TempDir()}
+			session := &triageSession{
+				prompt: &prompter{in: bufio.NewReader(strings.NewReader(test.keys)), out: ioutil.Discard},
+				opts:   opts,
+				refs: map[string][]keyRef{
+					"app.user.get":  {{Id: "app.user.get", Path: "app/user.go", Line: 3}},
+					"app.user.list": {{Id: "app.user.list", Path: "app/user.go", Line: 4}},
+				},
+				translations: map[string]Translation{"app.user.fetch": {Id: "app.user.fetch", Translation: "Unable to fetch the user."}},
+				locator:      newCatalogLocator(opts, nil),
+				added:        []string{"app.user.get", "app.user.list"},
+				removed:      []string{"app.user.fetch"},
+				decisions:    make([]*triageDecision, 3),
+			}
+			if completed := session.runScreen(); completed != test.completed {
+				t.Errorf("completed %v, expected %v", completed, test.completed)
+			}
+			if decisions := session.result(); !reflect.DeepEqual(decisions, test.decisions) {
+				t.Errorf("decisions %+v, expected %+v", decisions, test.decisions)
+			}
+		})
+	}
+}
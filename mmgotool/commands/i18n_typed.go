@@ -0,0 +1,122 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"go/ast"
+	"go/types"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// defaultTranslationPackages are the packages declaring the translation
+// functions, or the types of the translation function variables, used by
+// --typed extraction.
+var defaultTranslationPackages = []string{
+	"github.com/xzl8028/xenia-server/model",
+	"github.com/xzl8028/xenia-server/utils",
+	"github.com/xzl8028/go-i18n/i18n",
+	"github.com/nicksnyder/go-i18n/i18n",
+}
+
+// calleePackage returns the package of the function called: its declaring
+// package, or for a function variable or field the package declaring its
+// named type.
+func calleePackage(info *types.Info, call *ast.CallExpr) string {
+	var ident *ast.Ident
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		ident = fun
+	case *ast.SelectorExpr:
+		ident = fun.Sel
+	default:
+		return ""
+	}
+	switch obj := info.Uses[ident].(type) {
+	case *types.Func:
+		if obj.Pkg() != nil {
+			return obj.Pkg().Path()
+		}
+	case *types.Var:
+		if named, ok := obj.Type().(*types.Named); ok && named.Obj().Pkg() != nil {
+			return named.Obj().Pkg().Path()
+		}
+		if obj.Pkg() != nil {
+			return obj.Pkg().Path()
+		}
+	}
+	return ""
+}
+
+// extractTypedKeyRefs loads the packages of every module root with their
+// type information and extracts the translation calls whose callee belongs
+// to a translation package. It returns the keys by absolute file path, the
+// files left out of the loaded packages, like the ones excluded by build
+// constraints, are not included.
+func extractTypedKeyRefs(opts *extractOptions) (map[string][]keyRef, []extractProblem) {
+	allowed := map[string]bool{}
+	for _, pkg := range opts.TranslationPackages {
+		allowed[pkg] = true
+	}
+
+	keysByPath := map[string][]keyRef{}
+	problems := []extractProblem{}
+	for _, dir := range opts.SourceDirs() {
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		config := &packages.Config{
+			Mode:  packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles | packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo,
+			Dir:   dir,
+			Tests: opts.IncludeTests || len(opts.TestPaths) > 0,
+		}
+		pkgs, err := packages.Load(config, "./...")
+		if err != nil {
+			problems = append(problems, extractProblem{Path: dir, Err: err})
+			continue
+		}
+		for _, pkg := range pkgs {
+			for _, pkgErr := range pkg.Errors {
+				logger.Debug("Package loaded with errors", "package", pkg.PkgPath, "error", pkgErr.Msg)
+			}
+			if pkg.TypesInfo == nil {
+				continue
+			}
+			accept := func(call *ast.CallExpr) bool {
+				callee := calleePackage(pkg.TypesInfo, call)
+				if callee == "" {
+					// Without type information the call is kept, like the
+					// untyped extraction does.
+					return true
+				}
+				if !allowed[callee] {
+					logger.Debug("Skipping translation call of another package", "position", pkg.Fset.Position(call.Pos()).String(), "package", callee)
+					return false
+				}
+				return true
+			}
+			for i, f := range pkg.Syntax {
+				if i >= len(pkg.CompiledGoFiles) {
+					break
+				}
+				path, err := filepath.Abs(pkg.CompiledGoFiles[i])
+				if err != nil {
+					continue
+				}
+				if _, done := keysByPath[path]; done {
+					continue
+				}
+				src, err := readFile(path)
+				if err != nil {
+					continue
+				}
+				keysByPath[path] = sourceExtractor.File(pkg.Fset, f, src, accept)
+				logger.Debug("Parsed typed file", "path", path, "keys", len(keysByPath[path]))
+			}
+		}
+	}
+	return keysByPath, problems
+}
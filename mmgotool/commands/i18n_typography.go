@@ -0,0 +1,326 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+var typographyPolicyFile = filepath.Join("i18n", "typography.yaml")
+
+// quoteStyles are the opening and closing double quotes of the quotes
+// policies.
+var quoteStyles = map[string][2]rune{
+	"straight":   {'"', '"'},
+	"curly":      {'“', '”'},
+	"low-high":   {'„', '“'},
+	"guillemets": {'«', '»'},
+}
+
+// doubleQuotes are the quotes rewritten by the quotes policies.
+const doubleQuotes = "\"“”„«»"
+
+const (
+	noBreakSpace       = '\u00a0'
+	narrowNoBreakSpace = '\u202f'
+)
+
+var NormalizeTypographyCmd = &cobra.Command{
+	Use:   "normalize-newlines-and-quotes [file...]",
+	Short: "Standardize the newlines, quotes, apostrophes and spaces of the translations",
+	Long: `Rewrite the translations with the typography of their locale, fixing the strings pasted from word processors: Windows newlines and escaped "\n", smart quotes, apostrophes and non-breaking spaces.
+
+The policy is read from i18n/typography.yaml, the default section applying to every locale and the locales sections overriding it, "pt" applying to "pt-BR" too. The quotes inside HTML tags and template actions are never changed, neither are the strings with unbalanced quotes.
+
+  default:
+    newlines: lf          # lf or keep
+    quotes: straight      # straight, curly, low-high, guillemets or keep
+    apostrophe: straight  # straight, curly or keep
+    nbsp: space           # space, before-punctuation or keep
+  locales:
+    de:
+      quotes: low-high
+    fr:
+      quotes: guillemets
+      apostrophe: curly
+      nbsp: before-punctuation
+
+Without policy file only the newlines are normalized. The files default to every translation file of the i18n folder.`,
+	Example: `  i18n normalize-newlines-and-quotes --dry-run
+  i18n normalize-newlines-and-quotes i18n/fr.json --policy ../typography.yaml`,
+	RunE: normalizeTypographyCmdF,
+}
+
+func init() {
+	NormalizeTypographyCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	NormalizeTypographyCmd.Flags().String("policy", "", "Path to the typography policy, defaults to "+typographyPolicyFile+" of the xenia-dir")
+	NormalizeTypographyCmd.Flags().Bool("dry-run", false, "Print a unified diff of the changes instead of writing the files")
+	I18nCmd.AddCommand(NormalizeTypographyCmd)
+}
+
+type typographyRules struct {
+	Newlines   string `yaml:"newlines"`
+	Quotes     string `yaml:"quotes"`
+	Apostrophe string `yaml:"apostrophe"`
+	Spaces     string `yaml:"nbsp"`
+}
+
+type typographyPolicy struct {
+	Default typographyRules            `yaml:"default"`
+	Locales map[string]typographyRules `yaml:"locales"`
+}
+
+// defaultTypography only normalizes the newlines.
+var defaultTypography = typographyRules{Newlines: "lf", Quotes: "keep", Apostrophe: "keep", Spaces: "keep"}
+
+func (r typographyRules) validate() error {
+	if r.Newlines != "" && r.Newlines != "lf" && r.Newlines != "keep" {
+		return fmt.Errorf("unknown newlines policy %s", r.Newlines)
+	}
+	if _, ok := quoteStyles[r.Quotes]; r.Quotes != "" && r.Quotes != "keep" && !ok {
+		return fmt.Errorf("unknown quotes policy %s", r.Quotes)
+	}
+	if r.Apostrophe != "" && r.Apostrophe != "straight" && r.Apostrophe != "curly" && r.Apostrophe != "keep" {
+		return fmt.Errorf("unknown apostrophe policy %s", r.Apostrophe)
+	}
+	if r.Spaces != "" && r.Spaces != "space" && r.Spaces != "before-punctuation" && r.Spaces != "keep" {
+		return fmt.Errorf("unknown nbsp policy %s", r.Spaces)
+	}
+	return nil
+}
+
+// override returns the rules with the ones set in other replacing them.
+func (r typographyRules) override(other typographyRules) typographyRules {
+	if other.Newlines != "" {
+		r.Newlines = other.Newlines
+	}
+	if other.Quotes != "" {
+		r.Quotes = other.Quotes
+	}
+	if other.Apostrophe != "" {
+		r.Apostrophe = other.Apostrophe
+	}
+	if other.Spaces != "" {
+		r.Spaces = other.Spaces
+	}
+	return r
+}
+
+func loadTypographyPolicy(policyFile string, required bool) (*typographyPolicy, error) {
+	policy := &typographyPolicy{}
+	data, err := readFile(policyFile)
+	if os.IsNotExist(err) && !required {
+		return policy, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return nil, fmt.Errorf("Unable to parse %s: %s", policyFile, err.Error())
+	}
+	if err := policy.Default.validate(); err != nil {
+		return nil, fmt.Errorf("Invalid default section of %s: %s", policyFile, err.Error())
+	}
+	for locale, rules := range policy.Locales {
+		if err := rules.validate(); err != nil {
+			return nil, fmt.Errorf("Invalid %s section of %s: %s", locale, policyFile, err.Error())
+		}
+	}
+	return policy, nil
+}
+
+// rules returns the rules of a locale, the ones of its language applying
+// before the ones of the locale.
+func (p *typographyPolicy) rules(locale string) typographyRules {
+	rules := defaultTypography.override(p.Default)
+	if language := strings.FieldsFunc(locale, func(r rune) bool { return r == '-' || r == '_' }); len(language) > 1 {
+		rules = rules.override(p.Locales[language[0]])
+	}
+	return rules.override(p.Locales[locale])
+}
+
+// markupEnd returns the index of the last rune of the HTML tag or of the
+// template action starting at i, -1 when there is none.
+func markupEnd(runes []rune, i int) int {
+	switch {
+	case runes[i] == '<':
+		for j := i + 1; j < len(runes); j++ {
+			if runes[j] == '>' {
+				return j
+			}
+		}
+	case runes[i] == '{' && i+1 < len(runes) && runes[i+1] == '{':
+		for j := i + 2; j+1 < len(runes); j++ {
+			if runes[j] == '}' && runes[j+1] == '}' {
+				return j + 1
+			}
+		}
+	}
+	return -1
+}
+
+// markupRunes flags the runes of the HTML tags and of the template actions,
+// whose quotes are syntax.
+func markupRunes(runes []rune) []bool {
+	markup := make([]bool, len(runes))
+	for i := 0; i < len(runes); {
+		end := markupEnd(runes, i)
+		if end == -1 {
+			i++
+			continue
+		}
+		for ; i <= end; i++ {
+			markup[i] = true
+		}
+	}
+	return markup
+}
+
+// normalizeTypography returns the text with the typography of the rules.
+func normalizeTypography(text string, rules typographyRules) string {
+	if rules.Newlines == "lf" {
+		text = strings.NewReplacer("\r\n", "\n", "\r", "\n", `\n`, "\n").Replace(text)
+	}
+	runes := []rune(text)
+	markup := markupRunes(runes)
+
+	if style, ok := quoteStyles[rules.Quotes]; ok {
+		quotes := []int{}
+		for i, r := range runes {
+			if !markup[i] && strings.ContainsRune(doubleQuotes, r) {
+				quotes = append(quotes, i)
+			}
+		}
+		// Unbalanced quotes can't be told apart.
+		if len(quotes)%2 == 0 {
+			for n, i := range quotes {
+				runes[i] = style[n%2]
+			}
+		}
+	}
+
+	for i, r := range runes {
+		if markup[i] {
+			continue
+		}
+		if (r == '\'' || r == '’') && i > 0 && (unicode.IsLetter(runes[i-1]) || unicode.IsDigit(runes[i-1])) {
+			switch rules.Apostrophe {
+			case "straight":
+				runes[i] = '\''
+			case "curly":
+				runes[i] = '’'
+			}
+		}
+		if r != ' ' && r != noBreakSpace && r != narrowNoBreakSpace {
+			continue
+		}
+		switch rules.Spaces {
+		case "space":
+			runes[i] = ' '
+		case "before-punctuation":
+			if (i+1 < len(runes) && strings.ContainsRune(";:!?»", runes[i+1])) || (i > 0 && runes[i-1] == '«') {
+				runes[i] = narrowNoBreakSpace
+			}
+		}
+	}
+	return string(runes)
+}
+
+// normalizeTranslationTypography applies the rules to a translation and to
+// its plural forms.
+func normalizeTranslationTypography(value interface{}, rules typographyRules) interface{} {
+	switch v := value.(type) {
+	case string:
+		return normalizeTypography(v, rules)
+	case map[string]interface{}:
+		forms := map[string]interface{}{}
+		for form, text := range v {
+			forms[form] = normalizeTranslationTypography(text, rules)
+		}
+		return forms
+	}
+	return value
+}
+
+func normalizeTypographyCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	policyFile, err := command.Flags().GetString("policy")
+	if err != nil {
+		return errors.New("Invalid policy parameter")
+	}
+	dryRun, err := command.Flags().GetBool("dry-run")
+	if err != nil {
+		return errors.New("Invalid dry-run parameter")
+	}
+	command.SilenceUsage = true
+
+	policy, err := loadTypographyPolicy(filepath.Join(xeniaDir, typographyPolicyFile), false)
+	if policyFile != "" {
+		policy, err = loadTypographyPolicy(policyFile, true)
+	}
+	if err != nil {
+		return err
+	}
+
+	files := args
+	if len(files) == 0 {
+		if files, err = filepath.Glob(filepath.Join(xeniaDir, "i18n", "*.json")); err != nil {
+			return err
+		}
+	}
+
+	changedFiles, changedStrings := 0, 0
+	for _, file := range files {
+		translations, err := readTranslationsFile(file)
+		if err != nil {
+			return err
+		}
+		rules := policy.rules(localeName(filepath.Base(file)))
+		changed := 0
+		for i, t := range translations {
+			normalized := normalizeTranslationTypography(t.Translation, rules)
+			if !reflect.DeepEqual(normalized, t.Translation) {
+				translations[i].Translation = normalized
+				changed++
+			}
+		}
+		if changed == 0 {
+			continue
+		}
+		changedFiles++
+		changedStrings += changed
+
+		if !dryRun {
+			if err := writeTranslationsFile(file, translations); err != nil {
+				return err
+			}
+			logger.Info("Normalized the typography", "path", file, "strings", changed)
+			continue
+		}
+		current, err := readFile(file)
+		if err != nil {
+			return err
+		}
+		updated, err := encodeCatalog(translations, catalogFileFormat(file))
+		if err != nil {
+			return err
+		}
+		fmt.Print(unifiedDiff("a/"+filepath.ToSlash(file), "b/"+filepath.ToSlash(file), string(current), string(updated), 3))
+	}
+	if !dryRun {
+		fmt.Printf("Normalized %d strings in %d translation files.\n", changedStrings, changedFiles)
+	}
+	return nil
+}
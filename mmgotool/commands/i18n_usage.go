@@ -0,0 +1,184 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// usagePackagesShown is the number of packages listed by the text report
+// for every id.
+const usagePackagesShown = 3
+
+var UsageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Count the call sites of every translation id",
+	Long: `Report, for every translation id of i18n/en.json and of the source code, how many call sites use it and in which packages.
+
+The ids used once are the ones to look at before deleting a feature, the ids without call sites are safe to delete unless the server builds them at runtime, which the report tells. The ids used by many packages are often too generic strings shared by unrelated features. --min and --max keep the ids whose number of call sites is in the range.`,
+	Example: `  i18n usage --max 1
+  i18n usage --min 10 --sort packages
+  i18n usage --max 0 --format json`,
+	RunE: usageCmdF,
+}
+
+func init() {
+	addExtractFlags(UsageCmd)
+	UsageCmd.Flags().Int("min", 0, "Only report the ids with at least this many call sites")
+	UsageCmd.Flags().Int("max", -1, "Only report the ids with at most this many call sites, -1 for no maximum")
+	UsageCmd.Flags().String("sort", "calls", "Sort order: calls or packages, the most used first, or id")
+	UsageCmd.Flags().Int("limit", 0, "Number of ids reported, 0 for all")
+	UsageCmd.Flags().String("format", "text", "Output format: text or json")
+	I18nCmd.AddCommand(UsageCmd)
+}
+
+type keyUsage struct {
+	Id       string   `json:"id"`
+	Calls    int      `json:"calls"`
+	Packages []string `json:"packages"`
+	// Dynamic is set for the ids without call sites the server builds at
+	// runtime.
+	Dynamic bool `json:"dynamic,omitempty"`
+}
+
+// refPackage returns the package of a reference, prefixed by its module
+// outside of the Xenia server.
+func refPackage(ref keyRef) string {
+	if ref.Module != "" {
+		return ref.Module + "/" + path.Dir(ref.Path)
+	}
+	return path.Dir(ref.Path)
+}
+
+// keyUsages counts the call sites of the ids of the catalog and of the
+// references. used are the ids the source code uses, dynamic ones included.
+func keyUsages(translations []Translation, refs []keyRef, used map[string]bool) []keyUsage {
+	calls := map[string]int{}
+	packages := map[string]map[string]bool{}
+	for _, t := range translations {
+		packages[t.Id] = map[string]bool{}
+	}
+	for _, ref := range refs {
+		if packages[ref.Id] == nil {
+			packages[ref.Id] = map[string]bool{}
+		}
+		packages[ref.Id][refPackage(ref)] = true
+		calls[ref.Id]++
+	}
+
+	usages := []keyUsage{}
+	for id, idPackages := range packages {
+		usage := keyUsage{Id: id, Calls: calls[id], Packages: []string{}, Dynamic: calls[id] == 0 && used[id]}
+		for p := range idPackages {
+			usage.Packages = append(usage.Packages, p)
+		}
+		sort.Strings(usage.Packages)
+		usages = append(usages, usage)
+	}
+	return usages
+}
+
+// sortKeyUsages sorts the usages by id or, the largest first, by number of
+// calls or of packages.
+func sortKeyUsages(usages []keyUsage, order string) {
+	sort.Slice(usages, func(i, j int) bool {
+		a, b := usages[i], usages[j]
+		switch {
+		case order == "calls" && a.Calls != b.Calls:
+			return a.Calls > b.Calls
+		case order == "packages" && len(a.Packages) != len(b.Packages):
+			return len(a.Packages) > len(b.Packages)
+		}
+		return a.Id < b.Id
+	})
+}
+
+func usageCmdF(command *cobra.Command, args []string) error {
+	opts, err := getExtractOptions(command)
+	if err != nil {
+		return err
+	}
+	min, err := command.Flags().GetInt("min")
+	if err != nil || min < 0 {
+		return errors.New("Invalid min parameter")
+	}
+	max, err := command.Flags().GetInt("max")
+	if err != nil || max < -1 {
+		return errors.New("Invalid max parameter")
+	}
+	order, err := command.Flags().GetString("sort")
+	if err != nil {
+		return errors.New("Invalid sort parameter")
+	}
+	if order != "calls" && order != "packages" && order != "id" {
+		return fmt.Errorf("Unknown sort order %s", order)
+	}
+	limit, err := command.Flags().GetInt("limit")
+	if err != nil || limit < 0 {
+		return errors.New("Invalid limit parameter")
+	}
+	format, err := command.Flags().GetString("format")
+	if err != nil {
+		return errors.New("Invalid format parameter")
+	}
+	if format != "text" && format != "json" {
+		return fmt.Errorf("Unknown format %s", format)
+	}
+	command.SilenceUsage = true
+
+	refs, problems := extractKeyRefs(opts)
+	if err := reportExtractProblems(opts, problems); err != nil {
+		return err
+	}
+	translations, err := getCurrentTranslations(opts.XeniaDir)
+	if err != nil {
+		return err
+	}
+
+	usages := []keyUsage{}
+	for _, usage := range keyUsages(translations, refs, i18nStringsFromRefs(opts, refs)) {
+		if usage.Calls >= min && (max == -1 || usage.Calls <= max) {
+			usages = append(usages, usage)
+		}
+	}
+	sortKeyUsages(usages, order)
+	total := len(usages)
+	if limit > 0 && len(usages) > limit {
+		usages = usages[:limit]
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(usages)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CALLS\tPACKAGES\tID\tUSED IN")
+	for _, usage := range usages {
+		usedIn := usage.Packages
+		if len(usedIn) > usagePackagesShown {
+			usedIn = append(usedIn[:usagePackagesShown:usagePackagesShown], fmt.Sprintf("and %d more", len(usage.Packages)-usagePackagesShown))
+		}
+		if usage.Dynamic {
+			usedIn = []string{"built at runtime"}
+		}
+		fmt.Fprintf(w, "%d\t%d\t%s\t%s\n", usage.Calls, len(usage.Packages), usage.Id, strings.Join(usedIn, ", "))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if len(usages) < total {
+		fmt.Printf("%d more, use --limit 0 to list them all.\n", total-len(usages))
+	}
+	return nil
+}
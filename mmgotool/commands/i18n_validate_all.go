@@ -0,0 +1,362 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+)
+
+var ValidateAllCmd = &cobra.Command{
+	Use:   "validate-all",
+	Short: "Validate every translation file",
+	Long: `Run every check of the translation files on en.json and on all the locale files, in parallel, and print a single report:
+
+  - the JSON syntax of the file
+  - the ids declared twice in the file
+  - the encoding: a byte order mark, invalid UTF-8, control characters in the translations
+  - the placeholders of the translations, compared with the English strings
+  - the plural forms of the translations, as validate-plurals does
+
+The text format ends with the number of strings and of findings of every locale. Only the classes of --fail-on fail the validation, the others are reported as warnings.
+
+Exit codes:
+` + validateAllClasses.exitCodesHelp(),
+	Example: `  i18n validate-all
+  i18n validate-all --locale es --locale fr --format sarif`,
+	Args: cobra.NoArgs,
+	RunE: runCheck(validateAllCmdF),
+}
+
+func init() {
+	ValidateAllCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	ValidateAllCmd.Flags().StringSlice("locale", []string{}, "Only validate these locales (defaults to all, en included)")
+	ValidateAllCmd.Flags().Int("jobs", 0, "Number of files to validate in parallel (defaults to GOMAXPROCS)")
+	addFindingsFormatFlag(ValidateAllCmd)
+	validateAllClasses.addFailOnFlag(ValidateAllCmd, validateAllClasses.names())
+	I18nCmd.AddCommand(ValidateAllCmd)
+}
+
+var (
+	jsonSyntaxRule  = registerFindingRule(findingRule{Id: "i18n/json-syntax", Description: "The translation file is not a valid catalog", Command: "i18n validate-all"})
+	duplicateIdRule = registerFindingRule(findingRule{Id: "i18n/duplicate-id", Description: "The id is declared more than once in the translation file", Command: "i18n validate-all"})
+	encodingRule    = registerFindingRule(findingRule{Id: "i18n/encoding", Description: "The translation file has a byte order mark, invalid UTF-8 or control characters", Command: "i18n validate-all"})
+)
+
+// validateAllClasses are the classes of i18n validate-all.
+var validateAllClasses = checkClassSet{
+	{Name: "syntax", Description: "files which are not valid catalogs", ExitCode: 3, Message: "Translation files are not valid catalogs."},
+	{Name: "duplicate-id", Description: "ids declared more than once in a file", ExitCode: 4, Message: "Translation files declare ids more than once."},
+	{Name: "encoding", Description: "byte order marks, invalid UTF-8 or control characters", ExitCode: 5, Message: "Translation files have encoding problems."},
+	{Name: "unexpected-placeholder", Description: "translations using placeholders the English string lacks", ExitCode: 6, Message: "Translations use placeholders the English strings lack."},
+	{Name: "missing-placeholder", Description: "translations dropping placeholders of the English string", ExitCode: 7, Message: "Translations drop placeholders of the English strings."},
+	{Name: "plural-forms", Description: "translations missing the plural categories of their locale", ExitCode: 8, Message: "Translations miss the plural categories of their locale."},
+}
+
+// validateAllRuleClasses are the classes of the findings of the rules of
+// validate-all, the placeholder and plural findings set their own.
+var validateAllRuleClasses = map[string]string{
+	jsonSyntaxRule.Id:  "syntax",
+	duplicateIdRule.Id: "duplicate-id",
+	encodingRule.Id:    "encoding",
+}
+
+// utf8BOM is the byte order mark some editors write at the start of the
+// UTF-8 files, refused by the JSON decoder.
+var utf8BOM = []byte("\xEF\xBB\xBF")
+
+// fileValidation is the result of the checks of a translation file.
+type fileValidation struct {
+	File     string
+	Locale   string
+	Strings  int
+	Findings []finding
+	Err      error
+}
+
+// dataPosition returns the line and the column of a byte offset of data.
+func dataPosition(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+// syntaxErrorPosition returns the position of a decoding error in data, 0
+// when the error has none.
+func syntaxErrorPosition(data []byte, err error) (int, int) {
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		return dataPosition(data, e.Offset)
+	case *json.UnmarshalTypeError:
+		return dataPosition(data, e.Offset)
+	}
+	return 0, 0
+}
+
+// duplicateCatalogIds returns the ids declared more than once in a catalog,
+// in the array and in the map formats, which decoding silently merges.
+func duplicateCatalogIds(data []byte) ([]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	start, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	for decoder.More() {
+		switch start {
+		case json.Delim('['):
+			var entry struct {
+				Id string `json:"id"`
+			}
+			if err := decoder.Decode(&entry); err != nil {
+				return nil, err
+			}
+			counts[entry.Id]++
+		case json.Delim('{'):
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			var value json.RawMessage
+			if err := decoder.Decode(&value); err != nil {
+				return nil, err
+			}
+			counts[key.(string)]++
+		default:
+			return nil, nil
+		}
+	}
+	duplicates := []string{}
+	for id, count := range counts {
+		if count > 1 && id != catalogMetaId && id != catalogStringsId {
+			duplicates = append(duplicates, id)
+		}
+	}
+	sort.Strings(duplicates)
+	return duplicates, nil
+}
+
+// translationStrings returns the strings of a translation, its plural
+// forms included.
+func translationStrings(value interface{}) []string {
+	if forms, ok := parsePluralForms(value); ok {
+		texts := []string{}
+		for _, category := range pluralCategoriesOrder {
+			if text, ok := forms[category]; ok {
+				texts = append(texts, text)
+			}
+		}
+		return texts
+	}
+	if text, ok := value.(string); ok {
+		return []string{text}
+	}
+	return nil
+}
+
+// suspiciousRunes returns the characters of a text left by broken encodings
+// or copy and paste: the control characters but the newline and the tab,
+// byte order marks and replacement characters.
+func suspiciousRunes(text string) []string {
+	found := []string{}
+	seen := map[rune]bool{}
+	for _, r := range text {
+		suspicious := r == '\uFEFF' || r == utf8.RuneError || (unicode.IsControl(r) && r != '\n' && r != '\t')
+		if suspicious && !seen[r] {
+			seen[r] = true
+			found = append(found, fmt.Sprintf("U+%04X", r))
+		}
+	}
+	return found
+}
+
+// validateCatalogFile runs the checks of validate-all on a translation file.
+// The placeholders are compared with the source strings for the locales
+// only.
+func validateCatalogFile(file string, source map[string]interface{}) *fileValidation {
+	locale := localeName(file)
+	result := &fileValidation{File: file, Locale: locale}
+	if err := sandbox.checkPath(file); err != nil {
+		result.Err = err
+		return result
+	}
+	data, err := readFile(file)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	report := func(rule findingRule, line, column int, message string) {
+		result.Findings = append(result.Findings, finding{Rule: rule, Message: message, File: findingPath(file), Line: line, Column: column, Class: validateAllRuleClasses[rule.Id]})
+	}
+
+	if bytes.HasPrefix(data, utf8BOM) {
+		report(encodingRule, 1, 1, "The file starts with a byte order mark.")
+		data = data[len(utf8BOM):]
+	}
+	if !utf8.Valid(data) {
+		offset := 0
+		for offset < len(data) {
+			r, size := utf8.DecodeRune(data[offset:])
+			if r == utf8.RuneError && size == 1 {
+				break
+			}
+			offset += size
+		}
+		line, column := dataPosition(data, int64(offset))
+		report(encodingRule, line, column, "The file is not valid UTF-8.")
+	}
+
+	translations, err := decodeTranslations(data)
+	if err != nil {
+		line, column := syntaxErrorPosition(data, err)
+		report(jsonSyntaxRule, line, column, fmt.Sprintf("Unable to parse the file: %s", err.Error()))
+		return result
+	}
+	result.Strings = len(translations)
+
+	lines := catalogIdLines(file)
+	duplicates, err := duplicateCatalogIds(data)
+	if err != nil {
+		line, column := syntaxErrorPosition(data, err)
+		report(jsonSyntaxRule, line, column, fmt.Sprintf("Unable to parse the file: %s", err.Error()))
+		return result
+	}
+	for _, id := range duplicates {
+		report(duplicateIdRule, lines[id], 0, fmt.Sprintf("%s: the id is declared more than once.", id))
+	}
+	for _, t := range translations {
+		found := []string{}
+		for _, text := range translationStrings(t.Translation) {
+			found = append(found, suspiciousRunes(text)...)
+		}
+		if len(found) > 0 {
+			report(encodingRule, lines[t.Id], 0, fmt.Sprintf("%s: the translation has the characters %s.", t.Id, strings.Join(found, ", ")))
+		}
+	}
+
+	if locale != "en" {
+		for _, mismatch := range findPlaceholderMismatches(source, locale, translations) {
+			result.Findings = append(result.Findings, mismatch.finding(file, lines))
+		}
+	}
+	for _, problem := range findPluralProblems(source, locale, translations) {
+		result.Findings = append(result.Findings, problem.finding(file, lines))
+	}
+	return result
+}
+
+// validateCatalogFiles validates the files with jobs goroutines, returning
+// the results in the order of the files.
+func validateCatalogFiles(files []string, source map[string]interface{}, jobs int) []*fileValidation {
+	if jobs < 1 {
+		jobs = runtime.GOMAXPROCS(0)
+	}
+	results := make([]*fileValidation, len(files))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				results[index] = validateCatalogFile(files[index], source)
+			}
+		}()
+	}
+	for index := range files {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+func validateAllCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	locales, err := command.Flags().GetStringSlice("locale")
+	if err != nil {
+		return errors.New("Invalid locale parameter")
+	}
+	jobs, err := command.Flags().GetInt("jobs")
+	if err != nil {
+		return errors.New("Invalid jobs parameter")
+	}
+	reporter, err := getFindingsReporter(command)
+	if err != nil {
+		return err
+	}
+	failOn, err := validateAllClasses.getCheckPolicy(command)
+	if err != nil {
+		return err
+	}
+	wanted := map[string]bool{}
+	for _, locale := range locales {
+		wanted[locale] = true
+	}
+
+	// A broken en.json is reported with the other files, the locales are
+	// then validated without comparing them with the English strings.
+	source, err := sourceTranslations(xeniaDir)
+	if err != nil {
+		logger.Warn("Unable to read the English strings, the placeholders are not validated", "error", err)
+		source = map[string]interface{}{}
+	}
+	files, err := localeFiles(xeniaDir)
+	if err != nil {
+		return err
+	}
+	files = append([]string{path.Join(xeniaDir, "i18n", "en.json")}, files...)
+	selected := []string{}
+	for _, file := range files {
+		if len(wanted) == 0 || wanted[localeName(file)] {
+			selected = append(selected, file)
+		}
+	}
+
+	results := validateCatalogFiles(selected, source, jobs)
+	command.SilenceUsage = true
+	for _, result := range results {
+		if result.Err != nil {
+			return result.Err
+		}
+	}
+	findings := []finding{}
+	for _, result := range results {
+		findings = append(findings, result.Findings...)
+	}
+	counts := failOn.Report(reporter, findings)
+	if err := reporter.Flush(); err != nil {
+		return err
+	}
+
+	if reporter.format == findingsFormatText {
+		reporter.Printf("\n")
+		w := tabwriter.NewWriter(reporter.out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "LOCALE\tSTRINGS\tFINDINGS")
+		for _, result := range results {
+			fmt.Fprintf(w, "%s\t%d\t%d\n", result.Locale, result.Strings, len(result.Findings))
+		}
+		w.Flush()
+	}
+	return failOn.Result(counts)
+}
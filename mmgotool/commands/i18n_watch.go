@@ -0,0 +1,301 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+)
+
+// watchDebounce groups the events of a change touching several files, like
+// a checkout, in a single refresh.
+const watchDebounce = 200 * time.Millisecond
+
+var WatchCmd = &cobra.Command{
+	Use:     "watch",
+	Short:   "Watch the source code for translation changes",
+	Long:    "Watch the Xenia and enterprise source code and check (or extract) the translations every time a Go file changes. The folders are watched with the file notifications of the system, the new folders included. When they are unavailable, like on some network file systems or past the inotify watch limit, or with --poll, the source code is scanned for changes every --interval instead, raise it on large trees.",
+	Example: "  i18n watch --extract",
+	RunE:    watchCmdF,
+}
+
+func init() {
+	addExtractFlags(WatchCmd)
+	WatchCmd.Flags().Duration("interval", 5*time.Second, "How often the source code is scanned for changes without file notifications")
+	WatchCmd.Flags().Bool("poll", false, "Scan the source code for changes every --interval instead of using the file notifications")
+	WatchCmd.Flags().Bool("extract", false, "Update the i18n/en.json file on every change instead of only checking it")
+	addPlaceholderFlag(WatchCmd)
+	I18nCmd.AddCommand(WatchCmd)
+}
+
+func watchCmdF(command *cobra.Command, args []string) error {
+	opts, err := getExtractOptions(command)
+	if err != nil {
+		return err
+	}
+	interval, err := command.Flags().GetDuration("interval")
+	if err != nil || interval <= 0 {
+		return errors.New("Invalid interval parameter")
+	}
+	poll, err := command.Flags().GetBool("poll")
+	if err != nil {
+		return errors.New("Invalid poll parameter")
+	}
+	extract, err := command.Flags().GetBool("extract")
+	if err != nil {
+		return errors.New("Invalid extract parameter")
+	}
+	placeholder, err := getPlaceholder(command, opts.XeniaDir)
+	if err != nil {
+		return err
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	watcher := newSourceWatcher(opts, interval, poll)
+	defer watcher.Close()
+
+	fmt.Println("Watching", strings.Join(opts.SourceDirs(), ", "), "for changes. Press Ctrl+C to stop.")
+
+	var previous map[string]bool
+	for {
+		refs, problems := extractKeyRefs(opts)
+		if err := reportExtractProblems(opts, problems); err != nil {
+			logger.Error(err.Error())
+		}
+		i18nStrings := i18nStringsFromRefs(opts, refs)
+		if len(opts.ExcludedComponents()) > 0 {
+			if translations, err := getCurrentTranslations(opts.XeniaDir); err == nil {
+				keepExcludedTranslations(opts, i18nStrings, translations)
+			}
+		}
+		if previous != nil {
+			printKeySetChanges(previous, i18nStrings)
+		}
+		previous = i18nStrings
+
+		if len(problems) > 0 {
+			logger.Warn("Waiting for the skipped files to be fixed before refreshing the translations file")
+		} else if err := watchRefresh(opts.XeniaDir, i18nStrings, refs, extract, placeholder); err != nil {
+			logger.Error(err.Error())
+		}
+
+		select {
+		case <-interrupt:
+			return nil
+		case <-watcher.Changes:
+		}
+	}
+}
+
+// sourceWatcher notifies the changes of the source files on Changes, with
+// the file notifications of the system or, when they are unavailable, by
+// scanning the source code.
+type sourceWatcher struct {
+	opts     *extractOptions
+	notifier *fsnotify.Watcher
+	// watched are the folders registered with the notifier.
+	watched map[string]bool
+	Changes chan struct{}
+	done    chan struct{}
+}
+
+// newSourceWatcher starts watching the source folders, scanning them every
+// interval when poll is set or the file notifications are unavailable.
+func newSourceWatcher(opts *extractOptions, interval time.Duration, poll bool) *sourceWatcher {
+	w := &sourceWatcher{opts: opts, watched: map[string]bool{}, Changes: make(chan struct{}, 1), done: make(chan struct{})}
+	if !poll {
+		err := w.startNotifier()
+		if err == nil {
+			go w.notifyLoop()
+			return w
+		}
+		logger.Warn("File notifications unavailable, scanning the source code for changes instead", "interval", interval.String(), "error", err)
+	}
+	go w.pollLoop(interval)
+	return w
+}
+
+func (w *sourceWatcher) Close() {
+	close(w.done)
+	if w.notifier != nil {
+		w.notifier.Close()
+	}
+}
+
+// changed notifies a change, merged with the one not received yet.
+func (w *sourceWatcher) changed() {
+	select {
+	case w.Changes <- struct{}{}:
+	default:
+	}
+}
+
+func (w *sourceWatcher) startNotifier() error {
+	notifier, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	w.notifier = notifier
+	if err := w.watchDirs(); err != nil {
+		notifier.Close()
+		w.notifier = nil
+		return err
+	}
+	logger.Debug("Watching the source folders", "folders", len(w.watched))
+	return nil
+}
+
+// watchDirs registers the source folders not watched yet. The notifications
+// of a folder don't include its subfolders.
+func (w *sourceWatcher) watchDirs() error {
+	return walkSourceDirs(w.opts, func(p string) error {
+		if w.watched[p] {
+			return nil
+		}
+		if err := w.notifier.Add(p); err != nil {
+			return fmt.Errorf("Unable to watch %s: %s", p, err.Error())
+		}
+		w.watched[p] = true
+		return nil
+	})
+}
+
+func (w *sourceWatcher) notifyLoop() {
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-w.done:
+			return
+		case event, ok := <-w.notifier.Events:
+			if !ok {
+				return
+			}
+			if w.handle(event) {
+				debounce = time.After(watchDebounce)
+			}
+		case err, ok := <-w.notifier.Errors:
+			if !ok {
+				return
+			}
+			// Events may be lost, like on an overflow of the queue.
+			logger.Warn("File notification error", "error", err)
+			debounce = time.After(watchDebounce)
+		case <-debounce:
+			debounce = nil
+			w.changed()
+		}
+	}
+}
+
+// handle watches the new folders and tells whether the event changes the
+// source files.
+func (w *sourceWatcher) handle(event fsnotify.Event) bool {
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		// The notifier drops the removed folders, they are watched again
+		// when created back.
+		delete(w.watched, event.Name)
+	}
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			// A folder moved or checked out comes with its files and
+			// subfolders.
+			if err := w.watchDirs(); err != nil {
+				logger.Warn("Unable to watch the new folder", "path", event.Name, "error", err)
+			}
+			return true
+		}
+	}
+	return event.Op != fsnotify.Chmod && strings.HasSuffix(event.Name, ".go")
+}
+
+// pollLoop scans the source files every interval.
+func (w *sourceWatcher) pollLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	snapshot := sourceFilesSnapshot(w.opts)
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+		if current := sourceFilesSnapshot(w.opts); !sameSnapshot(snapshot, current) {
+			snapshot = current
+			w.changed()
+		}
+	}
+}
+
+func watchRefresh(xeniaDir string, i18nStrings map[string]bool, refs []keyRef, extract bool, placeholder string) error {
+	if extract {
+		if err := updateTranslations(xeniaDir, i18nStrings, refs, placeholder); err != nil {
+			return err
+		}
+		fmt.Println("Translations file updated.")
+		return nil
+	}
+
+	translations, err := getCurrentTranslations(xeniaDir)
+	if err != nil {
+		return err
+	}
+	added, removed := diffTranslations(i18nStrings, translations)
+	if len(added) == 0 && len(removed) == 0 {
+		fmt.Println("Translations file up to date.")
+		return nil
+	}
+	fmt.Printf("Translations file out of date: %d added, %d removed.\n", len(added), len(removed))
+	return nil
+}
+
+// sourceFilesSnapshot returns the modification time of every source file
+// that extraction would parse.
+func sourceFilesSnapshot(opts *extractOptions) map[string]time.Time {
+	snapshot := map[string]time.Time{}
+	walkSourceFiles(opts, func(p string) {
+		if info, err := os.Stat(p); err == nil {
+			snapshot[p] = info.ModTime()
+		}
+	})
+	return snapshot
+}
+
+func sameSnapshot(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for p, modTime := range a {
+		if other, ok := b[p]; !ok || !other.Equal(modTime) {
+			return false
+		}
+	}
+	return true
+}
+
+func printKeySetChanges(previous, current map[string]bool) {
+	changes := []string{}
+	for key := range current {
+		if !previous[key] {
+			changes = append(changes, "+ "+key)
+		}
+	}
+	for key := range previous {
+		if !current[key] {
+			changes = append(changes, "- "+key)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i][2:] < changes[j][2:] })
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var WhoUsesLocaleCmd = &cobra.Command{
+	Use:   "who-uses-locale <telemetry.csv>",
+	Short: "Rank the locales by untranslated user exposure",
+	Long: `Join the locale usage telemetry export with the completeness of every translation file and rank the locales by the number of users multiplied by the share of untranslated strings.
+The CSV file must have a header row; the locale and users columns are selected by name.`,
+	Example: "  i18n who-uses-locale locale_usage.csv --users-column active_users",
+	Args:    cobra.ExactArgs(1),
+	RunE:    whoUsesLocaleCmdF,
+}
+
+func init() {
+	WhoUsesLocaleCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	WhoUsesLocaleCmd.Flags().String("locale-column", "locale", "Name of the CSV column with the locale")
+	WhoUsesLocaleCmd.Flags().String("users-column", "users", "Name of the CSV column with the number of users")
+	WhoUsesLocaleCmd.Flags().Int("top", 0, "Number of locales to list, 0 lists all of them")
+	I18nCmd.AddCommand(WhoUsesLocaleCmd)
+}
+
+type localeUsage struct {
+	Locale       string
+	Users        int64
+	Completeness float64
+	HasFile      bool
+}
+
+// Impact is the number of users multiplied by the share of untranslated
+// strings of their locale.
+func (u localeUsage) Impact() float64 {
+	return float64(u.Users) * (1 - u.Completeness)
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(locale), "_", "-", -1))
+}
+
+// localeCompleteness returns the share of the source strings having a
+// non-empty translation.
+func localeCompleteness(source, translations map[string]interface{}) float64 {
+	if len(source) == 0 {
+		return 1
+	}
+	translated := 0
+	for id := range source {
+		if value, ok := translations[id]; ok && !isEmptyTranslation(value) {
+			translated++
+		}
+	}
+	return float64(translated) / float64(len(source))
+}
+
+// readLocaleUsage sums the users of every locale of the telemetry export.
+func readLocaleUsage(filePath, localeColumn, usersColumn string) (map[string]int64, error) {
+	f, err := openFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read the header of %s: %s", filePath, err.Error())
+	}
+	localeIdx, usersIdx := -1, -1
+	for i, column := range header {
+		switch strings.TrimSpace(column) {
+		case localeColumn:
+			localeIdx = i
+		case usersColumn:
+			usersIdx = i
+		}
+	}
+	if localeIdx == -1 || usersIdx == -1 {
+		return nil, fmt.Errorf("%s must have the %s and %s columns.", filePath, localeColumn, usersColumn)
+	}
+
+	usage := map[string]int64{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		users, err := strconv.ParseInt(strings.TrimSpace(record[usersIdx]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid number of users %q", filePath, line, record[usersIdx])
+		}
+		usage[normalizeLocale(record[localeIdx])] += users
+	}
+	return usage, nil
+}
+
+func whoUsesLocaleCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	localeColumn, err := command.Flags().GetString("locale-column")
+	if err != nil {
+		return errors.New("Invalid locale-column parameter")
+	}
+	usersColumn, err := command.Flags().GetString("users-column")
+	if err != nil {
+		return errors.New("Invalid users-column parameter")
+	}
+	top, err := command.Flags().GetInt("top")
+	if err != nil {
+		return errors.New("Invalid top parameter")
+	}
+
+	usage, err := readLocaleUsage(args[0], localeColumn, usersColumn)
+	if err != nil {
+		return err
+	}
+	source, err := sourceTranslations(xeniaDir)
+	if err != nil {
+		return err
+	}
+	files, err := localeFiles(xeniaDir)
+	if err != nil {
+		return err
+	}
+	command.SilenceUsage = true
+
+	completeness := map[string]float64{}
+	names := map[string]string{}
+	for _, file := range files {
+		catalog, err := loadCatalog(file)
+		if err != nil {
+			return err
+		}
+		locale := normalizeLocale(localeName(file))
+		completeness[locale] = localeCompleteness(source, catalog.Translations)
+		names[locale] = localeName(file)
+	}
+
+	usages := []localeUsage{}
+	for locale, users := range usage {
+		if locale == "en" || strings.HasPrefix(locale, "en-") {
+			continue
+		}
+		u := localeUsage{Locale: locale, Users: users}
+		if name, ok := names[locale]; ok {
+			u.Locale = name
+			u.Completeness = completeness[locale]
+			u.HasFile = true
+		}
+		usages = append(usages, u)
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].Impact() != usages[j].Impact() {
+			return usages[i].Impact() > usages[j].Impact()
+		}
+		return usages[i].Locale < usages[j].Locale
+	})
+	if top > 0 && len(usages) > top {
+		usages = usages[:top]
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RANK\tLOCALE\tUSERS\tCOMPLETE\tUNTRANSLATED USERS")
+	for i, u := range usages {
+		complete := fmt.Sprintf("%.1f%%", u.Completeness*100)
+		if !u.HasFile {
+			complete = "no file"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%.0f\n", i+1, u.Locale, u.Users, complete, u.Impact())
+	}
+	return w.Flush()
+}
@@ -0,0 +1,290 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+const xliffNamespace = "urn:oasis:names:tc:xliff:document:2.0"
+
+var ExportXliffCmd = &cobra.Command{
+	Use:   "export-xliff",
+	Short: "Export a locale to XLIFF 2.0",
+	Long: `Export the translations of a locale to an XLIFF 2.0 file for translation management systems.
+
+Every id is a unit with the English source, the current translation as target and the state of the segment: initial when the translation is missing, translated otherwise. The description and the expiry release of the id are notes of the unit. Plural strings have one segment per plural category of the locale.`,
+	Example: "  i18n export-xliff --locale de --output de.xlf",
+	RunE:    exportXliffCmdF,
+}
+
+var ImportXliffCmd = &cobra.Command{
+	Use:   "import-xliff <file>",
+	Short: "Import an XLIFF 2.0 file",
+	Long: `Import the translations of an XLIFF 2.0 file into the translation file of its target language.
+
+Only the segments in the translated, reviewed or final state are imported, the units of unknown ids are skipped.`,
+	Example: "  i18n import-xliff de.xlf --dry-run",
+	Args:    cobra.ExactArgs(1),
+	RunE:    importXliffCmdF,
+}
+
+func init() {
+	ExportXliffCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	ExportXliffCmd.Flags().String("locale", "", "Locale to export, like de or pt-BR")
+	ExportXliffCmd.Flags().String("output", "", "Path to the XLIFF file (defaults to stdout)")
+	ImportXliffCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	ImportXliffCmd.Flags().Bool("dry-run", false, "Print the number of imported translations without writing the translation file")
+	I18nCmd.AddCommand(ExportXliffCmd, ImportXliffCmd)
+}
+
+type xliffDocument struct {
+	XMLName xml.Name    `xml:"xliff"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	Version string      `xml:"version,attr"`
+	SrcLang string      `xml:"srcLang,attr"`
+	TrgLang string      `xml:"trgLang,attr"`
+	Files   []xliffFile `xml:"file"`
+}
+
+type xliffFile struct {
+	Id    string      `xml:"id,attr"`
+	Units []xliffUnit `xml:"unit"`
+}
+
+type xliffUnit struct {
+	Id       string         `xml:"id,attr"`
+	Notes    *xliffNotes    `xml:"notes"`
+	Segments []xliffSegment `xml:"segment"`
+}
+
+type xliffNotes struct {
+	Notes []xliffNote `xml:"note"`
+}
+
+type xliffNote struct {
+	Category string `xml:"category,attr,omitempty"`
+	Text     string `xml:",chardata"`
+}
+
+type xliffSegment struct {
+	// Id is the plural category of the segment of a plural string.
+	Id     string  `xml:"id,attr,omitempty"`
+	State  string  `xml:"state,attr,omitempty"`
+	Source string  `xml:"source"`
+	Target *string `xml:"target"`
+}
+
+// importedStates are the segment states whose target is imported.
+var importedStates = map[string]bool{
+	"translated": true,
+	"reviewed":   true,
+	"final":      true,
+}
+
+func xliffSegmentFor(id, source, target string) xliffSegment {
+	state := "translated"
+	if target == "" {
+		state = "initial"
+	}
+	return xliffSegment{Id: id, State: state, Source: source, Target: &target}
+}
+
+// xliffUnitFor builds the unit of an English translation and its translation
+// in the locale, which may be nil.
+func xliffUnitFor(source Translation, translated interface{}, locale string) xliffUnit {
+	unit := xliffUnit{Id: source.Id}
+	notes := []xliffNote{}
+	if source.Description != "" {
+		notes = append(notes, xliffNote{Category: "description", Text: source.Description})
+	}
+	if source.Expires != "" {
+		notes = append(notes, xliffNote{Category: "expires", Text: source.Expires})
+	}
+	if len(notes) > 0 {
+		unit.Notes = &xliffNotes{Notes: notes}
+	}
+
+	if forms, ok := source.PluralForms(); ok {
+		targets, _ := parsePluralForms(translated)
+		for _, category := range requiredPluralCategories(locale) {
+			text, ok := forms[category]
+			if !ok {
+				text = forms["other"]
+			}
+			unit.Segments = append(unit.Segments, xliffSegmentFor(category, text, targets[category]))
+		}
+		return unit
+	}
+	text, _ := source.Translation.(string)
+	target, _ := translated.(string)
+	unit.Segments = []xliffSegment{xliffSegmentFor("", text, target)}
+	return unit
+}
+
+func exportXliffCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	locale, err := command.Flags().GetString("locale")
+	if err != nil || locale == "" {
+		return errors.New("Invalid locale parameter")
+	}
+	output, err := command.Flags().GetString("output")
+	if err != nil {
+		return errors.New("Invalid output parameter")
+	}
+	command.SilenceUsage = true
+
+	source, err := getCurrentTranslations(xeniaDir)
+	if err != nil {
+		return err
+	}
+	translated := map[string]interface{}{}
+	localeFile := filepath.Join(xeniaDir, "i18n", locale+".json")
+	translations, err := readTranslationsFile(localeFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, t := range translations {
+		translated[t.Id] = t.Translation
+	}
+
+	file := xliffFile{Id: "server"}
+	for _, t := range source {
+		file.Units = append(file.Units, xliffUnitFor(t, translated[t.Id], locale))
+	}
+	document := xliffDocument{Xmlns: xliffNamespace, Version: "2.0", SrcLang: "en", TrgLang: locale, Files: []xliffFile{file}}
+	data, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append([]byte(xml.Header), append(data, '\n')...)
+
+	if output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return writeFile(output, data, 0644)
+}
+
+// xliffTarget returns the translation of a unit, nil when no segment is
+// ready to be imported.
+func xliffTarget(unit xliffUnit) interface{} {
+	if len(unit.Segments) == 1 && unit.Segments[0].Id == "" {
+		segment := unit.Segments[0]
+		if !importedStates[segment.State] || segment.Target == nil || *segment.Target == "" {
+			return nil
+		}
+		return *segment.Target
+	}
+	forms := PluralForms{}
+	for _, segment := range unit.Segments {
+		if !isPluralCategory(segment.Id) || !importedStates[segment.State] || segment.Target == nil || *segment.Target == "" {
+			continue
+		}
+		forms[segment.Id] = *segment.Target
+	}
+	if len(forms) == 0 {
+		return nil
+	}
+	return forms
+}
+
+// sameTranslation compares a translation read from JSON with an imported
+// one, plural forms included.
+func sameTranslation(current, imported interface{}) bool {
+	if forms, ok := parsePluralForms(current); ok {
+		current = forms
+	}
+	return reflect.DeepEqual(current, imported)
+}
+
+func importXliffCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	dryRun, err := command.Flags().GetBool("dry-run")
+	if err != nil {
+		return errors.New("Invalid dry-run parameter")
+	}
+	command.SilenceUsage = true
+
+	data, err := readFile(args[0])
+	if err != nil {
+		return err
+	}
+	var document xliffDocument
+	if err := xml.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("Unable to parse %s: %s", args[0], err.Error())
+	}
+	if document.Version != "2.0" {
+		return fmt.Errorf("Unsupported XLIFF version %q, only 2.0 is supported.", document.Version)
+	}
+	if document.TrgLang == "" {
+		return errors.New("The XLIFF file has no target language.")
+	}
+
+	source, err := sourceTranslations(xeniaDir)
+	if err != nil {
+		return err
+	}
+	localeFile := filepath.Join(xeniaDir, "i18n", document.TrgLang+".json")
+	translations, err := readTranslationsFile(localeFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	sorted := sort.SliceIsSorted(translations, func(i, j int) bool { return translations[i].Id < translations[j].Id })
+	index := map[string]int{}
+	for i, t := range translations {
+		index[t.Id] = i
+	}
+
+	imported := 0
+	for _, file := range document.Files {
+		for _, unit := range file.Units {
+			if _, ok := source[unit.Id]; !ok {
+				logger.Warn("Skipping unknown id", "id", unit.Id)
+				continue
+			}
+			target := xliffTarget(unit)
+			if target == nil {
+				continue
+			}
+			if i, ok := index[unit.Id]; ok {
+				if sameTranslation(translations[i].Translation, target) {
+					continue
+				}
+				translations[i].Translation = target
+			} else {
+				index[unit.Id] = len(translations)
+				translations = append(translations, Translation{Id: unit.Id, Translation: target})
+			}
+			imported++
+		}
+	}
+	if sorted {
+		sort.SliceStable(translations, func(i, j int) bool { return translations[i].Id < translations[j].Id })
+	}
+
+	if dryRun {
+		fmt.Printf("%d translations would be imported into %s.\n", imported, localeFile)
+		return nil
+	}
+	if err := writeTranslationsFile(localeFile, translations); err != nil {
+		return err
+	}
+	fmt.Printf("%d translations imported into %s.\n", imported, localeFile)
+	return nil
+}
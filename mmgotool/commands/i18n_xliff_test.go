@@ -0,0 +1,48 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportXliffKeepsDescriptions(t *testing.T) {
+	xeniaDir := extractDescribedCatalog(t)
+	output := filepath.Join(t.TempDir(), "de.xlf")
+	RootCmd.SetArgs([]string{"i18n", "export-xliff", "--xenia-dir", xeniaDir, "--locale", "de", "--output", output})
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatalf("export-xliff failed: %s", err)
+	}
+	data, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var document xliffDocument
+	if err := xml.Unmarshal(data, &document); err != nil {
+		t.Fatal(err)
+	}
+
+	units := map[string]xliffUnit{}
+	for _, file := range document.Files {
+		for _, unit := range file.Units {
+			units[unit.Id] = unit
+		}
+	}
+	missing, ok := units["app.user.missing"]
+	if !ok {
+		t.Fatalf("no unit for app.user.missing:\n%s", data)
+	}
+	if missing.Notes == nil || len(missing.Notes.Notes) != 1 {
+		t.Fatalf("expected one note for app.user.missing, got %+v", missing.Notes)
+	}
+	if note := missing.Notes.Notes[0]; note.Category != "description" || note.Text != `Shown when the "user" can't be found` {
+		t.Errorf("unexpected note %+v", note)
+	}
+	if count := units["app.user.count"]; count.Notes != nil {
+		t.Errorf("app.user.count has no description, got the notes %+v", count.Notes)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bufio"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreRule is a compiled .gitignore style pattern.
+type ignoreRule struct {
+	pattern *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// ignoreMatcher decides whether a path, relative to the walked root and
+// using forward slashes, is excluded. Later rules take precedence like in
+// .gitignore files.
+type ignoreMatcher struct {
+	rules []ignoreRule
+}
+
+// addPattern adds a .gitignore style pattern declared in the base folder,
+// relative to the walked root ("" for the root itself).
+func (m *ignoreMatcher) addPattern(base, pattern string) {
+	pattern = strings.TrimRight(pattern, " ")
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return
+	}
+
+	rule := ignoreRule{}
+	if strings.HasPrefix(pattern, "!") {
+		rule.negate = true
+		pattern = pattern[1:]
+	} else if strings.HasPrefix(pattern, `\`) {
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		rule.dirOnly = true
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	if pattern == "" {
+		return
+	}
+
+	prefix := ""
+	if base != "" {
+		prefix = regexp.QuoteMeta(base + "/")
+	}
+	if strings.Contains(pattern, "/") {
+		pattern = strings.TrimPrefix(pattern, "/")
+	} else {
+		prefix += "(.*/)?"
+	}
+
+	compiled, err := regexp.Compile("^" + prefix + globToRegexp(pattern) + "(/.*)?$")
+	if err != nil {
+		return
+	}
+	rule.pattern = compiled
+	m.rules = append(m.rules, rule)
+}
+
+// addGitignore loads the .gitignore file of dir, if any. rel is the path of
+// dir relative to the walked root.
+func (m *ignoreMatcher) addGitignore(dir, rel string) {
+	f, err := openFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		m.addPattern(rel, scanner.Text())
+	}
+}
+
+func (m *ignoreMatcher) ignored(rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir && !rule.matchesParent(rel) {
+			continue
+		}
+		if rule.pattern.MatchString(rel) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// matchesParent reports whether a folder containing rel matches the rule, so
+// "build/" also excludes "build/main.go".
+func (r ignoreRule) matchesParent(rel string) bool {
+	idx := strings.LastIndex(rel, "/")
+	return idx != -1 && r.pattern.MatchString(rel[:idx])
+}
+
+// globToRegexp translates a glob supporting *, ?, [...] and ** into a
+// regular expression.
+func globToRegexp(glob string) string {
+	var buf strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			buf.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			buf.WriteString(".*")
+			i++
+		case c == '*':
+			buf.WriteString("[^/]*")
+		case c == '?':
+			buf.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end == -1 {
+				buf.WriteString(regexp.QuoteMeta(string(c)))
+				continue
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			buf.WriteString("[" + class + "]")
+			i += end
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,305 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// hookMarker identifies the git hooks written by init, the only ones it
+// replaces.
+const hookMarker = `# Installed by "mmgotool init".`
+
+// xeniaDirCandidates and enterpriseDirCandidates are the usual locations of
+// the server and enterprise source code, relative to the setup folder.
+var (
+	xeniaDirCandidates      = []string{".", "server", "../xenia-server", "../xenia"}
+	enterpriseDirCandidates = []string{"../enterprise", "../xenia-enterprise", "enterprise"}
+)
+
+var InitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Set up mmgotool for a repository",
+	Long: `Detect the layout of the repository, write the flags section of .mmgotool.yaml, optionally install the pre-commit hook and the shell completion, then run a first i18n check to validate the setup.
+Every question has a detected default, accepted with Enter or with --yes.`,
+	Example: "  init --dir ../xenia-server --yes",
+	Args:    cobra.NoArgs,
+	RunE:    initCmdF,
+}
+
+func init() {
+	InitCmd.Flags().String("dir", "./", "Root of the repository to set up")
+	InitCmd.Flags().Bool("yes", false, "Accept the detected defaults without asking")
+	InitCmd.Flags().Bool("no-hooks", false, "Do not offer to install the git hooks")
+	InitCmd.Flags().Bool("no-completion", false, "Do not offer to install the shell completion")
+	InitCmd.Flags().Bool("no-check", false, "Do not run the first check")
+	RootCmd.AddCommand(InitCmd)
+}
+
+type prompter struct {
+	in        *bufio.Reader
+	out       io.Writer
+	assumeYes bool
+}
+
+// ask returns the answer to question, or def when the answer is empty.
+func (p *prompter) ask(question, def string) string {
+	if p.assumeYes {
+		return def
+	}
+	if def != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", question)
+	}
+	answer, _ := p.in.ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return def
+	}
+	return answer
+}
+
+func (p *prompter) confirm(question string, def bool) bool {
+	if p.assumeYes {
+		return def
+	}
+	choices := "y/N"
+	if def {
+		choices = "Y/n"
+	}
+	for {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, choices)
+		answer, err := p.in.ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		switch {
+		case answer == "" || err != nil:
+			return def
+		case answer == "y" || answer == "yes":
+			return true
+		case answer == "n" || answer == "no":
+			return false
+		}
+	}
+}
+
+func isXeniaDir(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, "i18n", "en.json"))
+	return err == nil
+}
+
+func detectDir(root string, candidates []string, valid func(string) bool) string {
+	for _, candidate := range candidates {
+		if valid(filepath.Join(root, candidate)) {
+			return candidate
+		}
+	}
+	return ""
+}
+
+func isDir(dir string) bool {
+	info, err := os.Stat(dir)
+	return err == nil && info.IsDir()
+}
+
+// writeConfigFlags sets flags in the flags section of the configuration file
+// of dir, keeping the other sections.
+func writeConfigFlags(dir string, flags yaml.MapSlice) (string, error) {
+	configPath := filepath.Join(dir, configFileName)
+	config := yaml.MapSlice{}
+	data, err := readFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return "", fmt.Errorf("Unable to parse %s: %s", configPath, err.Error())
+	}
+
+	section := yaml.MapSlice{}
+	sectionIdx := -1
+	for i, item := range config {
+		if item.Key == "flags" {
+			sectionIdx = i
+			if existing, ok := item.Value.(yaml.MapSlice); ok {
+				section = existing
+			}
+		}
+	}
+	for _, flag := range flags {
+		replaced := false
+		for i, item := range section {
+			if item.Key == flag.Key {
+				section[i].Value = flag.Value
+				replaced = true
+			}
+		}
+		if !replaced {
+			section = append(section, flag)
+		}
+	}
+	if sectionIdx == -1 {
+		config = append(yaml.MapSlice{{Key: "flags", Value: section}}, config...)
+	} else {
+		config[sectionIdx].Value = section
+	}
+
+	out, err := yaml.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	return configPath, writeFile(configPath, out, 0644)
+}
+
+// installPreCommitHook writes the pre-commit hook running the verify
+// command. Hooks not written by mmgotool are left alone.
+func installPreCommitHook(dir string) (string, error) {
+	hook := "#!/bin/sh\n" + hookMarker + "\nexec mmgotool lint verify\n"
+	return writeGitHook(dir, "pre-commit", []byte(hook), false)
+}
+
+// writeGitHook writes the named git hook of the repository of dir. Without
+// force, the hooks not written by mmgotool are left alone.
+func writeGitHook(dir, name string, hook []byte, force bool) (string, error) {
+	output, err := execCommand("git", "-C", dir, "rev-parse", "--git-path", "hooks").Output()
+	if err != nil {
+		return "", errors.New("not a git repository")
+	}
+	hooksDir := strings.TrimSpace(string(output))
+	if !filepath.IsAbs(hooksDir) {
+		hooksDir = filepath.Join(dir, hooksDir)
+	}
+	hookPath := filepath.Join(hooksDir, name)
+	if current, err := readFile(hookPath); err == nil && !force && !bytes.Contains(current, []byte(hookMarker)) && !bytes.Contains(current, []byte(hooksMarker)) {
+		return "", fmt.Errorf("%s already exists", hookPath)
+	}
+
+	if err := mkdirAll(hooksDir, 0755); err != nil {
+		return "", err
+	}
+	return hookPath, writeFile(hookPath, hook, 0755)
+}
+
+// installCompletion writes the completion script of the user's shell where
+// the shell loads it from, registered for the mmgotool binary name.
+func installCompletion(shell string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	var completionPath string
+	switch filepath.Base(shell) {
+	case "bash":
+		completionPath = filepath.Join(home, ".local", "share", "bash-completion", "completions", binaryName)
+	case "zsh":
+		completionPath = filepath.Join(home, ".zsh", "completions", "_"+binaryName)
+	case "fish":
+		completionPath = filepath.Join(home, ".config", "fish", "completions", binaryName+".fish")
+	default:
+		return "", fmt.Errorf("no completion available for %s", shell)
+	}
+
+	var buf bytes.Buffer
+	if err := writeCompletion(&buf, filepath.Base(shell)); err != nil {
+		return "", err
+	}
+	if err := mkdirAll(filepath.Dir(completionPath), 0755); err != nil {
+		return "", err
+	}
+	return completionPath, writeFile(completionPath, buf.Bytes(), 0644)
+}
+
+func initCmdF(command *cobra.Command, args []string) error {
+	dir, err := command.Flags().GetString("dir")
+	if err != nil {
+		return errors.New("Invalid dir parameter")
+	}
+	assumeYes, err := command.Flags().GetBool("yes")
+	if err != nil {
+		return errors.New("Invalid yes parameter")
+	}
+	noHooks, err := command.Flags().GetBool("no-hooks")
+	if err != nil {
+		return errors.New("Invalid no-hooks parameter")
+	}
+	noCompletion, err := command.Flags().GetBool("no-completion")
+	if err != nil {
+		return errors.New("Invalid no-completion parameter")
+	}
+	noCheck, err := command.Flags().GetBool("no-check")
+	if err != nil {
+		return errors.New("Invalid no-check parameter")
+	}
+	command.SilenceUsage = true
+
+	p := &prompter{in: bufio.NewReader(os.Stdin), out: os.Stdout, assumeYes: assumeYes}
+
+	xeniaDir := p.ask("Folder of the Xenia server source code", detectDir(dir, xeniaDirCandidates, isXeniaDir))
+	if xeniaDir == "" || !isXeniaDir(filepath.Join(dir, xeniaDir)) {
+		return fmt.Errorf("No i18n/en.json found in %q, is it the Xenia server source code?", xeniaDir)
+	}
+	enterpriseDir := p.ask("Folder of the enterprise source code, empty for none", detectDir(dir, enterpriseDirCandidates, isDir))
+	if enterpriseDir != "" && !isDir(filepath.Join(dir, enterpriseDir)) {
+		return fmt.Errorf("The enterprise folder %q does not exist.", enterpriseDir)
+	}
+
+	flags := yaml.MapSlice{{Key: "xenia-dir", Value: xeniaDir}}
+	if enterpriseDir != "" {
+		flags = append(flags, yaml.MapItem{Key: "enterprise-dir", Value: enterpriseDir})
+	}
+	configPath, err := writeConfigFlags(dir, flags)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Wrote", configPath)
+
+	if !noHooks && p.confirm("Install the pre-commit hook running mmgotool lint verify?", true) {
+		if hookPath, err := installPreCommitHook(dir); err != nil {
+			logger.Warn("Pre-commit hook not installed", "error", err)
+		} else {
+			fmt.Println("Installed", hookPath)
+		}
+	}
+
+	if shell := os.Getenv("SHELL"); !noCompletion && shell != "" && p.confirm("Install the shell completion for "+filepath.Base(shell)+"?", true) {
+		if completionPath, err := installCompletion(shell); err != nil {
+			logger.Warn("Shell completion not installed", "error", err)
+		} else {
+			fmt.Println("Installed", completionPath)
+		}
+	}
+
+	if noCheck {
+		return nil
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	fmt.Println("Running mmgotool i18n check...")
+	check := execCommand(executable, "i18n", "check")
+	check.Dir = dir
+	check.Stdout = os.Stdout
+	check.Stderr = os.Stderr
+	err = check.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok && i18nCheckClasses.isFailureCode(exitErr.ExitCode()) {
+		fmt.Println("The setup works, the translations file is out of date: run mmgotool i18n extract.")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("The first check failed, review %s: %s", configPath, err.Error())
+	}
+	fmt.Println("The setup works.")
+	return nil
+}
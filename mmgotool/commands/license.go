@@ -0,0 +1,229 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const licenseHeader = `// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+`
+
+var generatedCodePattern = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+var LicenseCmd = &cobra.Command{
+	Use:   "license",
+	Short: "Copyright header management",
+}
+
+var LicenseCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the copyright header of the Go files",
+	Long: `Report the Go files with a missing or incorrect copyright header. Generated files and the vendor directory are skipped.
+
+Only the classes of --fail-on fail the check, the others are reported as warnings.
+
+Exit codes:
+` + licenseCheckClasses.exitCodesHelp(),
+	Example: "  lint license check --dir ../xenia-server",
+	RunE:    runCheck(licenseCheckCmdF),
+}
+
+var LicenseFixCmd = &cobra.Command{
+	Use:     "fix",
+	Short:   "Insert or update the copyright header of the Go files",
+	Long:    "Insert the copyright header in the Go files missing it and replace the incorrect ones. Build constraints and the rest of the file are kept after the header.",
+	Example: "  lint license fix --dir ../xenia-server",
+	RunE:    licenseFixCmdF,
+}
+
+func init() {
+	for _, command := range []*cobra.Command{LicenseCheckCmd, LicenseFixCmd} {
+		command.Flags().String("dir", "./", "Path to the folder with the Go files")
+		command.Flags().StringArray("exclude", []string{}, "Glob pattern of paths to skip, can be repeated")
+		command.Flags().Bool("no-gitignore", false, "Do not skip the paths ignored by .gitignore files")
+	}
+	addFindingsFormatFlag(LicenseCheckCmd)
+	licenseCheckClasses.addFailOnFlag(LicenseCheckCmd, licenseCheckClasses.names())
+	LicenseCmd.AddCommand(LicenseCheckCmd)
+	LicenseCmd.AddCommand(LicenseFixCmd)
+	LintCmd.AddCommand(LicenseCmd)
+}
+
+var (
+	licenseMissingRule   = registerFindingRule(findingRule{Id: "license/missing-header", Description: "The Go file has no copyright header", Command: "lint license check", Fix: "lint license fix"})
+	licenseIncorrectRule = registerFindingRule(findingRule{Id: "license/incorrect-header", Description: "The copyright header of the Go file is not the Xenia one", Command: "lint license check", Fix: "lint license fix"})
+)
+
+// licenseCheckClasses are the classes of lint license check.
+var licenseCheckClasses = checkClassSet{
+	{Name: "missing", Description: "Go files without a copyright header", ExitCode: 3, Message: "Go files miss the copyright header, run mmgotool lint license fix."},
+	{Name: "incorrect", Description: "Go files with another copyright header", ExitCode: 4, Message: "Go files have an incorrect copyright header, run mmgotool lint license fix."},
+}
+
+const (
+	licenseOk = iota
+	licenseMissing
+	licenseIncorrect
+	licenseGenerated
+)
+
+// leadingComments returns the length of the line comments at the top of
+// src, before the first blank line or code.
+func leadingComments(src string) int {
+	end := 0
+	for end < len(src) {
+		lineEnd := strings.IndexByte(src[end:], '\n')
+		line := src[end:]
+		if lineEnd != -1 {
+			line = src[end : end+lineEnd+1]
+		}
+		if !strings.HasPrefix(line, "//") {
+			break
+		}
+		end += len(line)
+	}
+	return end
+}
+
+// licenseStatus reports the state of the copyright header of src and the
+// length of the existing header to replace when it is incorrect.
+func licenseStatus(src string) (int, int) {
+	for _, line := range strings.Split(src, "\n") {
+		if strings.HasPrefix(line, "package ") {
+			break
+		}
+		if generatedCodePattern.MatchString(line) {
+			return licenseGenerated, 0
+		}
+	}
+
+	if strings.HasPrefix(src, licenseHeader) {
+		return licenseOk, 0
+	}
+	header := src[:leadingComments(src)]
+	if strings.Contains(strings.ToLower(header), "copyright") && !strings.HasPrefix(header, "//go:build") && !strings.HasPrefix(header, "// +build") {
+		return licenseIncorrect, len(header)
+	}
+	return licenseMissing, 0
+}
+
+func fixLicense(src string) string {
+	status, headerLen := licenseStatus(src)
+	switch status {
+	case licenseMissing:
+		return licenseHeader + "\n" + src
+	case licenseIncorrect:
+		return licenseHeader + src[headerLen:]
+	}
+	return src
+}
+
+func walkGoFiles(command *cobra.Command, fn func(p string) error) error {
+	dir, err := command.Flags().GetString("dir")
+	if err != nil {
+		return errors.New("Invalid dir parameter")
+	}
+	exclude, err := command.Flags().GetStringArray("exclude")
+	if err != nil {
+		return errors.New("Invalid exclude parameter")
+	}
+	noGitignore, err := command.Flags().GetBool("no-gitignore")
+	if err != nil {
+		return errors.New("Invalid no-gitignore parameter")
+	}
+
+	matcher := &ignoreMatcher{}
+	for _, pattern := range exclude {
+		matcher.addPattern("", pattern)
+	}
+	return walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			rel = ""
+		}
+
+		if info.IsDir() {
+			if info.Name() == ".git" || info.Name() == "vendor" || (rel != "" && matcher.ignored(rel, true)) {
+				return filepath.SkipDir
+			}
+			if !noGitignore {
+				matcher.addGitignore(p, rel)
+			}
+			return nil
+		}
+		if !strings.HasSuffix(p, ".go") || matcher.ignored(rel, false) {
+			return nil
+		}
+		return fn(p)
+	})
+}
+
+func licenseCheckCmdF(command *cobra.Command, args []string) error {
+	reporter, err := getFindingsReporter(command)
+	if err != nil {
+		return err
+	}
+	failOn, err := licenseCheckClasses.getCheckPolicy(command)
+	if err != nil {
+		return err
+	}
+	findings := []finding{}
+	err = walkGoFiles(command, func(p string) error {
+		src, err := readFile(p)
+		if err != nil {
+			return err
+		}
+		switch status, _ := licenseStatus(string(src)); status {
+		case licenseMissing:
+			findings = append(findings, finding{Rule: licenseMissingRule, Message: "Missing copyright header", File: findingPath(p), Line: 1, Text: "Missing header: " + p, Class: "missing"})
+		case licenseIncorrect:
+			findings = append(findings, finding{Rule: licenseIncorrectRule, Message: "Incorrect copyright header", File: findingPath(p), Line: 1, Text: "Incorrect header: " + p, Class: "incorrect"})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	command.SilenceUsage = true
+	counts := failOn.Report(reporter, findings)
+	if err := reporter.Flush(); err != nil {
+		return err
+	}
+	return failOn.Result(counts)
+}
+
+func licenseFixCmdF(command *cobra.Command, args []string) error {
+	return walkGoFiles(command, func(p string) error {
+		src, err := readFile(p)
+		if err != nil {
+			return err
+		}
+		fixed := fixLicense(string(src))
+		if fixed == string(src) {
+			return nil
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Fixed:", p)
+		return writeFile(p, []byte(fixed), info.Mode())
+	})
+}
@@ -0,0 +1,237 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type logLevel int
+
+const (
+	logLevelDebug logLevel = iota
+	logLevelInfo
+	logLevelWarn
+	logLevelError
+)
+
+var logLevelNames = map[logLevel]string{
+	logLevelDebug: "debug",
+	logLevelInfo:  "info",
+	logLevelWarn:  "warn",
+	logLevelError: "error",
+}
+
+// toolLogger writes the diagnostics of the commands to stderr, leaving
+// stdout to their actual output. Messages take alternating key and value
+// arguments, printed as key=value pairs or as JSON fields.
+type toolLogger struct {
+	mu    sync.Mutex
+	out   io.Writer
+	level logLevel
+	json  bool
+	// github writes the warnings and errors as GitHub Actions workflow
+	// commands, shown as annotations of the run.
+	github bool
+}
+
+var logger = &toolLogger{out: os.Stderr, level: logLevelInfo}
+
+func init() {
+	RootCmd.PersistentFlags().Bool("verbose", false, "Print debug information, like the files walked and skipped")
+	RootCmd.PersistentFlags().Bool("quiet", false, "Only print errors")
+	RootCmd.PersistentFlags().String("log-format", "text", "Format of the diagnostics: text, json or github")
+	RootCmd.PersistentFlags().Bool("action", false, "Run as a GitHub Action step, reporting the diagnostics as workflow annotations")
+}
+
+func configureLogger(command *cobra.Command, args []string) error {
+	verbose, err := command.Flags().GetBool("verbose")
+	if err != nil {
+		return errors.New("Invalid verbose parameter")
+	}
+	quiet, err := command.Flags().GetBool("quiet")
+	if err != nil {
+		return errors.New("Invalid quiet parameter")
+	}
+	format, err := command.Flags().GetString("log-format")
+	if err != nil {
+		return errors.New("Invalid log-format parameter")
+	}
+	action, err := command.Flags().GetBool("action")
+	if err != nil {
+		return errors.New("Invalid action parameter")
+	}
+	if action {
+		format = "github"
+	}
+	if verbose && quiet {
+		return errors.New("The verbose and quiet flags can't be used together")
+	}
+	if format != "text" && format != "json" && format != "github" {
+		return fmt.Errorf("Unknown log format %s", format)
+	}
+	defer warnLegacyCommand()
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	logger.json = format == "json"
+	logger.github = format == "github"
+	switch {
+	case verbose:
+		logger.level = logLevelDebug
+	case quiet:
+		logger.level = logLevelError
+	default:
+		logger.level = logLevelInfo
+	}
+	return nil
+}
+
+func warnLegacyCommand() {
+	if usedLegacyCommand != "" {
+		logger.Warn("The command moved, the old name will be removed in a future version", "command", usedLegacyCommand, "new", legacyCommands[usedLegacyCommand]+" "+usedLegacyCommand)
+	}
+}
+
+// flags returns the command line flags configuring another command's
+// logger like this one, in the --name=value form.
+func (l *toolLogger) flags() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	flags := []string{}
+	switch {
+	case l.json:
+		flags = append(flags, "--log-format=json")
+	case l.github:
+		flags = append(flags, "--log-format=github")
+	}
+	switch l.level {
+	case logLevelDebug:
+		flags = append(flags, "--verbose=true")
+	case logLevelError:
+		flags = append(flags, "--quiet=true")
+	}
+	return flags
+}
+
+// Verbose reports whether debug messages are printed.
+func (l *toolLogger) Verbose() bool {
+	return l.enabled(logLevelDebug)
+}
+
+func (l *toolLogger) enabled(level logLevel) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return level >= l.level
+}
+
+func (l *toolLogger) Debug(msg string, keyvals ...interface{}) {
+	l.log(logLevelDebug, msg, keyvals)
+}
+
+func (l *toolLogger) Info(msg string, keyvals ...interface{}) {
+	l.log(logLevelInfo, msg, keyvals)
+}
+
+func (l *toolLogger) Warn(msg string, keyvals ...interface{}) {
+	l.log(logLevelWarn, msg, keyvals)
+}
+
+func (l *toolLogger) Error(msg string, keyvals ...interface{}) {
+	l.log(logLevelError, msg, keyvals)
+}
+
+func (l *toolLogger) log(level logLevel, msg string, keyvals []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if level < l.level {
+		return
+	}
+
+	var buf bytes.Buffer
+	if l.json {
+		buf.WriteString("{")
+		writeJSONField(&buf, "time", time.Now().UTC().Format(time.RFC3339))
+		buf.WriteString(",")
+		writeJSONField(&buf, "level", logLevelNames[level])
+		buf.WriteString(",")
+		writeJSONField(&buf, "msg", msg)
+		for i := 0; i < len(keyvals); i += 2 {
+			buf.WriteString(",")
+			writeJSONField(&buf, fmt.Sprint(keyvals[i]), logValue(keyvals, i+1))
+		}
+		buf.WriteString("}\n")
+	} else if l.github && level != logLevelInfo {
+		var text bytes.Buffer
+		text.WriteString(msg)
+		for i := 0; i < len(keyvals); i += 2 {
+			fmt.Fprintf(&text, " %v=%v", keyvals[i], logValue(keyvals, i+1))
+		}
+		fmt.Fprintf(&buf, "::%s::%s\n", githubCommands[level], escapeWorkflowCommand(text.String()))
+	} else {
+		if level != logLevelInfo {
+			fmt.Fprintf(&buf, "%s: ", logLevelNames[level])
+		}
+		buf.WriteString(msg)
+		for i := 0; i < len(keyvals); i += 2 {
+			fmt.Fprintf(&buf, " %v=%v", keyvals[i], logValue(keyvals, i+1))
+		}
+		buf.WriteString("\n")
+	}
+	l.out.Write(buf.Bytes())
+}
+
+var githubCommands = map[logLevel]string{
+	logLevelDebug: "debug",
+	logLevelWarn:  "warning",
+	logLevelError: "error",
+}
+
+// escapeWorkflowCommand escapes the message of a workflow command so it
+// stays on one line.
+func escapeWorkflowCommand(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// ReportError annotates the GitHub Actions run with the error returned by a
+// command run with --action.
+func (l *toolLogger) ReportError(err error) {
+	l.mu.Lock()
+	github := l.github
+	l.mu.Unlock()
+	if github {
+		l.Error(err.Error())
+	}
+}
+
+func logValue(keyvals []interface{}, i int) interface{} {
+	if i >= len(keyvals) {
+		return "MISSING"
+	}
+	if err, ok := keyvals[i].(error); ok {
+		return err.Error()
+	}
+	return keyvals[i]
+}
+
+func writeJSONField(buf *bytes.Buffer, key string, value interface{}) {
+	encodedKey, _ := json.Marshal(key)
+	encodedValue, err := json.Marshal(value)
+	if err != nil {
+		encodedValue, _ = json.Marshal(fmt.Sprint(value))
+	}
+	buf.Write(encodedKey)
+	buf.WriteString(":")
+	buf.Write(encodedValue)
+}
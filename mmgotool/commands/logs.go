@@ -0,0 +1,397 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+const defaultLogCatalogFile = "mlog_catalog.json"
+
+// logLevels are the mlog functions and logger methods writing a message.
+var logLevels = map[string]bool{
+	"Debug":    true,
+	"Info":     true,
+	"Warn":     true,
+	"Error":    true,
+	"Critical": true,
+	"Fatal":    true,
+}
+
+var LogsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Structured log messages tooling",
+}
+
+var LogsCatalogCmd = &cobra.Command{
+	Use:   "catalog",
+	Short: "Catalog of the log messages",
+}
+
+var LogsCatalogExtractCmd = &cobra.Command{
+	Use:     "extract",
+	Short:   "Extract the log messages and fields into the catalog",
+	Long:    "Extract the messages and field names of the mlog calls of the source code into the log catalog file",
+	Example: "  lint logs catalog extract --bump-version",
+	RunE:    logsCatalogExtractCmdF,
+}
+
+var LogsCatalogCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the log catalog",
+	Long: `Check that the log catalog is up to date and that the messages of the allowlist, used by the documented alerting queries, still exist with all their fields.
+Changing an allowlisted message requires bumping the catalog version above the version the allowlist was reviewed for.`,
+	Example: "  lint logs catalog check --allowlist docs/alerting_messages.json",
+	RunE:    logsCatalogCheckCmdF,
+}
+
+func init() {
+	for _, command := range []*cobra.Command{LogsCatalogExtractCmd, LogsCatalogCheckCmd} {
+		addExtractFlags(command)
+		command.Flags().String("catalog", defaultLogCatalogFile, "Path to the catalog file, relative to the xenia-dir")
+	}
+	LogsCatalogExtractCmd.Flags().Bool("bump-version", false, "Increment the catalog version")
+	LogsCatalogCheckCmd.Flags().String("allowlist", "", "Path to the allowlist of the messages used by alerting queries")
+	LogsCatalogCmd.AddCommand(LogsCatalogExtractCmd)
+	LogsCatalogCmd.AddCommand(LogsCatalogCheckCmd)
+	LogsCmd.AddCommand(LogsCatalogCmd)
+	LintCmd.AddCommand(LogsCmd)
+}
+
+type logMessage struct {
+	Message string   `json:"message"`
+	Levels  []string `json:"levels"`
+	Fields  []string `json:"fields"`
+	Files   []string `json:"files"`
+}
+
+type logCatalog struct {
+	Version  int          `json:"version"`
+	Messages []logMessage `json:"messages"`
+}
+
+type logAllowlist struct {
+	// CatalogVersion is the catalog version the allowlist was reviewed for.
+	CatalogVersion int      `json:"catalog_version"`
+	Messages       []string `json:"messages"`
+}
+
+// logCall is a log message found in the source code.
+type logCall struct {
+	Message string
+	Level   string
+	Fields  []string
+}
+
+// extractLogCalls returns the calls of the file passing a literal message
+// to mlog or to a logger method along mlog fields.
+func extractLogCalls(src []byte) ([]logCall, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	calls := []logCall{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || !logLevels[sel.Sel.Name] {
+			return true
+		}
+		lit, ok := call.Args[0].(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return true
+		}
+		message, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			return true
+		}
+
+		fields := []string{}
+		for _, arg := range call.Args[1:] {
+			if field, ok := mlogFieldName(arg); ok {
+				fields = append(fields, field)
+			}
+		}
+		if !isMlogPackage(sel.X) && len(fields) == 0 {
+			return true
+		}
+		calls = append(calls, logCall{Message: message, Level: sel.Sel.Name, Fields: fields})
+		return true
+	})
+	return calls, nil
+}
+
+func isMlogPackage(expr ast.Expr) bool {
+	ident, ok := expr.(*ast.Ident)
+	return ok && ident.Name == "mlog"
+}
+
+// mlogFieldName returns the name of a field built with an mlog helper such
+// as mlog.String("user_id", id). mlog.Err always names its field "error".
+func mlogFieldName(expr ast.Expr) (string, bool) {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return "", false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || !isMlogPackage(sel.X) {
+		return "", false
+	}
+	if sel.Sel.Name == "Err" {
+		return "error", true
+	}
+	if len(call.Args) == 0 {
+		return "", false
+	}
+	lit, ok := call.Args[0].(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	name, err := strconv.Unquote(lit.Value)
+	return name, err == nil
+}
+
+func extractLogMessages(opts *extractOptions) ([]logMessage, error) {
+	byMessage := map[string]*logMessage{}
+	var walkErr error
+	walkSourceFiles(opts, func(p string) {
+		if walkErr != nil {
+			return
+		}
+		src, err := readFile(p)
+		if err != nil {
+			walkErr = err
+			return
+		}
+		calls, err := extractLogCalls(src)
+		if err != nil {
+			walkErr = err
+			return
+		}
+		rel, err := filepath.Rel(opts.XeniaDir, p)
+		if err != nil {
+			rel = p
+		}
+		for _, call := range calls {
+			message, ok := byMessage[call.Message]
+			if !ok {
+				message = &logMessage{Message: call.Message}
+				byMessage[call.Message] = message
+			}
+			message.Levels = appendUnique(message.Levels, call.Level)
+			for _, field := range call.Fields {
+				message.Fields = appendUnique(message.Fields, field)
+			}
+			message.Files = appendUnique(message.Files, filepath.ToSlash(rel))
+		}
+	})
+	if walkErr != nil {
+		return nil, walkErr
+	}
+
+	messages := []logMessage{}
+	for _, message := range byMessage {
+		sort.Strings(message.Levels)
+		sort.Strings(message.Fields)
+		sort.Strings(message.Files)
+		if message.Fields == nil {
+			message.Fields = []string{}
+		}
+		messages = append(messages, *message)
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].Message < messages[j].Message })
+	return messages, nil
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+func logCatalogPath(command *cobra.Command, xeniaDir string) (string, error) {
+	catalogFile, err := command.Flags().GetString("catalog")
+	if err != nil {
+		return "", errors.New("Invalid catalog parameter")
+	}
+	if !filepath.IsAbs(catalogFile) {
+		catalogFile = filepath.Join(xeniaDir, catalogFile)
+	}
+	return catalogFile, nil
+}
+
+func readLogCatalog(path string) (*logCatalog, error) {
+	data, err := readFile(path)
+	if os.IsNotExist(err) {
+		return &logCatalog{Version: 1, Messages: []logMessage{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	catalog := &logCatalog{}
+	if err := json.Unmarshal(data, catalog); err != nil {
+		return nil, fmt.Errorf("Unable to parse %s: %s", path, err.Error())
+	}
+	return catalog, nil
+}
+
+func encodeLogCatalog(catalog *logCatalog) ([]byte, error) {
+	data, err := json.MarshalIndent(catalog, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func logsCatalogExtractCmdF(command *cobra.Command, args []string) error {
+	opts, err := getExtractOptions(command)
+	if err != nil {
+		return err
+	}
+	catalogFile, err := logCatalogPath(command, opts.XeniaDir)
+	if err != nil {
+		return err
+	}
+	bumpVersion, err := command.Flags().GetBool("bump-version")
+	if err != nil {
+		return errors.New("Invalid bump-version parameter")
+	}
+
+	catalog, err := readLogCatalog(catalogFile)
+	if err != nil {
+		return err
+	}
+	catalog.Messages, err = extractLogMessages(opts)
+	if err != nil {
+		return err
+	}
+	if bumpVersion {
+		catalog.Version++
+	}
+
+	data, err := encodeLogCatalog(catalog)
+	if err != nil {
+		return err
+	}
+	if err := writeFile(catalogFile, data, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d log messages to %s (version %d).\n", len(catalog.Messages), catalogFile, catalog.Version)
+	return nil
+}
+
+// checkAllowlist returns the problems of the allowlisted messages missing
+// from messages or missing some of the fields they had in the catalog.
+func checkAllowlist(allowlist *logAllowlist, catalog *logCatalog, messages []logMessage) []string {
+	current := map[string]logMessage{}
+	for _, message := range messages {
+		current[message.Message] = message
+	}
+	previous := map[string]logMessage{}
+	for _, message := range catalog.Messages {
+		previous[message.Message] = message
+	}
+
+	problems := []string{}
+	for _, text := range allowlist.Messages {
+		message, ok := current[text]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("Message %q no longer exists", text))
+			continue
+		}
+		for _, field := range previous[text].Fields {
+			found := false
+			for _, f := range message.Fields {
+				found = found || f == field
+			}
+			if !found {
+				problems = append(problems, fmt.Sprintf("Message %q lost the field %q", text, field))
+			}
+		}
+	}
+	return problems
+}
+
+func logsCatalogCheckCmdF(command *cobra.Command, args []string) error {
+	opts, err := getExtractOptions(command)
+	if err != nil {
+		return err
+	}
+	catalogFile, err := logCatalogPath(command, opts.XeniaDir)
+	if err != nil {
+		return err
+	}
+	allowlistFile, err := command.Flags().GetString("allowlist")
+	if err != nil {
+		return errors.New("Invalid allowlist parameter")
+	}
+
+	catalog, err := readLogCatalog(catalogFile)
+	if err != nil {
+		return err
+	}
+	messages, err := extractLogMessages(opts)
+	if err != nil {
+		return err
+	}
+	command.SilenceUsage = true
+
+	failed := false
+	if allowlistFile != "" {
+		data, err := readFile(allowlistFile)
+		if err != nil {
+			return err
+		}
+		allowlist := &logAllowlist{}
+		if err := json.Unmarshal(data, allowlist); err != nil {
+			return fmt.Errorf("Unable to parse %s: %s", allowlistFile, err.Error())
+		}
+		problems := checkAllowlist(allowlist, catalog, messages)
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		if len(problems) > 0 && catalog.Version <= allowlist.CatalogVersion {
+			fmt.Println("Allowlisted log messages changed, bump the catalog version and update the alerting queries.")
+			failed = true
+		}
+	}
+
+	expected, err := encodeLogCatalog(&logCatalog{Version: catalog.Version, Messages: messages})
+	if err != nil {
+		return err
+	}
+	current, err := readFile(catalogFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if string(current) != string(expected) {
+		fmt.Printf("%s is out of date, run mmgotool lint logs catalog extract.\n", catalogFile)
+		failed = true
+	}
+
+	if failed {
+		return errors.New("The log catalog check failed.")
+	}
+	fmt.Println("The log catalog is up to date.")
+	return nil
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/xzl8028/xenia-utilities/mmgotool/internal/codegen"
+)
+
+//go:embed templates/make/*.tmpl
+var makeTemplates embed.FS
+
+type makeManifest struct {
+	ManifestPath string              `json:"-"`
+	I18n         *makeI18nSection    `json:"i18n"`
+	Codegen      []makeCodegenTarget `json:"codegen"`
+	Lint         *makeLintSection    `json:"lint"`
+	Tool         *makeToolSection    `json:"tool"`
+}
+
+type makeI18nSection struct {
+	XeniaDir      string `json:"xenia_dir"`
+	EnterpriseDir string `json:"enterprise_dir"`
+}
+
+type makeCodegenTarget struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Command     string `json:"command"`
+}
+
+// makeToolSection builds mmgotool from a checkout of its repository, with
+// the version, the commit and the build date of the version command.
+type makeToolSection struct {
+	Dir    string `json:"dir"`
+	Output string `json:"output"`
+	// VersionPackage is the package of the build variables.
+	VersionPackage string `json:"-"`
+}
+
+type makeLintSection struct {
+	Packages     []string `json:"packages"`
+	Vet          bool     `json:"vet"`
+	Gofmt        bool     `json:"gofmt"`
+	GolangciLint bool     `json:"golangci_lint"`
+}
+
+var MakeCmd = &cobra.Command{
+	Use:   "make",
+	Short: "Management of the shared Makefile fragments",
+}
+
+var MakeTargetsCmd = &cobra.Command{
+	Use:   "targets",
+	Short: "Makefile targets generation",
+}
+
+var MakeTargetsGenCmd = &cobra.Command{
+	Use:     "gen",
+	Short:   "Generate the Makefile fragment",
+	Long:    "Generate the i18n, codegen, lint and mmgotool build Makefile targets described in the manifest file",
+	Example: "  codegen make targets gen --manifest mmgotool-make.json --output build/mmgotool.mk",
+	RunE:    makeTargetsGenCmdF,
+}
+
+func init() {
+	MakeTargetsGenCmd.Flags().String("manifest", "mmgotool-make.json", "Path to the manifest describing the targets")
+	MakeTargetsGenCmd.Flags().String("output", "", "Path to the generated Makefile fragment (defaults to stdout)")
+	MakeTargetsGenCmd.Flags().Bool("check", false, "Fail if the output file is not up to date instead of writing it")
+	MakeTargetsCmd.AddCommand(MakeTargetsGenCmd)
+	MakeCmd.AddCommand(MakeTargetsCmd)
+	CodegenCmd.AddCommand(MakeCmd)
+}
+
+func loadMakeManifest(manifestPath string) (*makeManifest, error) {
+	data, err := readFile(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &makeManifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("Unable to parse %s: %s", manifestPath, err.Error())
+	}
+	manifest.ManifestPath = manifestPath
+
+	if manifest.I18n != nil {
+		if manifest.I18n.XeniaDir == "" {
+			manifest.I18n.XeniaDir = "./"
+		}
+		if manifest.I18n.EnterpriseDir == "" {
+			manifest.I18n.EnterpriseDir = "../enterprise"
+		}
+	}
+	for _, target := range manifest.Codegen {
+		if target.Name == "" || target.Command == "" {
+			return nil, fmt.Errorf("Every codegen target in %s needs a name and a command", manifestPath)
+		}
+	}
+	if manifest.Lint != nil && len(manifest.Lint.Packages) == 0 {
+		manifest.Lint.Packages = []string{"./..."}
+	}
+	if manifest.Tool != nil {
+		if manifest.Tool.Dir == "" {
+			manifest.Tool.Dir = "../xenia-utilities/mmgotool"
+		}
+		if manifest.Tool.Output == "" {
+			manifest.Tool.Output = "bin/" + binaryName
+		}
+		manifest.Tool.VersionPackage = versionPackage
+	}
+	return manifest, nil
+}
+
+func makeTargetsGenCmdF(command *cobra.Command, args []string) error {
+	manifestPath, err := command.Flags().GetString("manifest")
+	if err != nil {
+		return errors.New("Invalid manifest parameter")
+	}
+	output, err := command.Flags().GetString("output")
+	if err != nil {
+		return errors.New("Invalid output parameter")
+	}
+	check, err := command.Flags().GetBool("check")
+	if err != nil {
+		return errors.New("Invalid check parameter")
+	}
+
+	manifest, err := loadMakeManifest(manifestPath)
+	if err != nil {
+		return err
+	}
+
+	engine, err := codegen.New("codegen make targets gen", makeTemplates, "templates/make/*.tmpl")
+	if err != nil {
+		return err
+	}
+	fragment, err := engine.RenderRaw("targets.mk", manifest)
+	if err != nil {
+		return err
+	}
+
+	if check {
+		if output == "" {
+			return errors.New("The check mode requires an output file")
+		}
+		upToDate, err := matchesFile(output, fragment)
+		if err != nil {
+			return err
+		}
+		if !upToDate {
+			command.SilenceUsage = true
+			return fmt.Errorf("%s is out of date.", output)
+		}
+		return nil
+	}
+
+	if output == "" {
+		_, err = os.Stdout.Write(fragment)
+		return err
+	}
+	return writeFile(output, fragment, 0644)
+}
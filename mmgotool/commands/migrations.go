@@ -0,0 +1,321 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// migrationDrivers are the database drivers with their own migrations
+// folder.
+var migrationDrivers = []string{"mysql", "postgres"}
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+var migrationNamePattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+var MigrationsCmd = &cobra.Command{
+	Use:   "migrations",
+	Short: "Database migrations tooling",
+	Long:  "Scaffold and check the SQL migrations of db/migrations, numbered like 000042_add_user_props.up.sql with a folder per database driver.",
+}
+
+var MigrationsNewCmd = &cobra.Command{
+	Use:   "new <name>",
+	Short: "Scaffold a new migration",
+	Long:  "Create the up and down SQL files of a migration for MySQL and Postgres with the next number, and register them in the migrations list.",
+	Example: `  dev migrations new add_user_props
+  dev migrations new "Add user props" --xenia-dir ../xenia-server`,
+	Args: cobra.ExactArgs(1),
+	RunE: migrationsNewCmdF,
+}
+
+var MigrationsCheckCmd = &cobra.Command{
+	Use:     "check",
+	Short:   "Check the migrations",
+	Long:    "Check that the migrations of every driver are numbered without gaps or duplicates, that every up migration has a down migration, that the drivers have the same migrations and that every migration file is registered in the migrations list.",
+	Example: "  dev migrations check --xenia-dir ../xenia-server",
+	RunE:    migrationsCheckCmdF,
+}
+
+func init() {
+	for _, command := range []*cobra.Command{MigrationsNewCmd, MigrationsCheckCmd} {
+		command.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+		command.Flags().String("migrations-dir", filepath.Join("db", "migrations"), "Path to the migrations folder, relative to the xenia-dir")
+		command.Flags().String("list-file", "migrations.list", "Path to the list of the registered migration files, relative to the migrations folder")
+	}
+	MigrationsCmd.AddCommand(MigrationsNewCmd)
+	MigrationsCmd.AddCommand(MigrationsCheckCmd)
+	DevCmd.AddCommand(MigrationsCmd)
+}
+
+type migrationFile struct {
+	Driver    string
+	Number    int
+	Name      string
+	Direction string
+}
+
+// Path is the path of the file relative to the migrations folder, as
+// written in the migrations list.
+func (f migrationFile) Path() string {
+	return fmt.Sprintf("%s/%06d_%s.%s.sql", f.Driver, f.Number, f.Name, f.Direction)
+}
+
+type migrationsLayout struct {
+	Dir      string
+	ListFile string
+}
+
+func getMigrationsLayout(command *cobra.Command) (*migrationsLayout, error) {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return nil, errors.New("Invalid xenia-dir parameter")
+	}
+	migrationsDir, err := command.Flags().GetString("migrations-dir")
+	if err != nil {
+		return nil, errors.New("Invalid migrations-dir parameter")
+	}
+	listFile, err := command.Flags().GetString("list-file")
+	if err != nil {
+		return nil, errors.New("Invalid list-file parameter")
+	}
+	dir := filepath.Join(xeniaDir, migrationsDir)
+	return &migrationsLayout{Dir: dir, ListFile: filepath.Join(dir, listFile)}, nil
+}
+
+// files returns the migration files of every driver. The files not named
+// like a migration are returned apart.
+func (l *migrationsLayout) files() ([]migrationFile, []string, error) {
+	files := []migrationFile{}
+	unknown := []string{}
+	for _, driver := range migrationDrivers {
+		entries, err := readDir(filepath.Join(l.Dir, driver))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			match := migrationFilePattern.FindStringSubmatch(entry.Name())
+			if match == nil {
+				unknown = append(unknown, driver+"/"+entry.Name())
+				continue
+			}
+			number, _ := strconv.Atoi(match[1])
+			files = append(files, migrationFile{Driver: driver, Number: number, Name: match[2], Direction: match[3]})
+		}
+	}
+	return files, unknown, nil
+}
+
+// registered returns the files of the migrations list, nil when there is no
+// list.
+func (l *migrationsLayout) registered() ([]string, error) {
+	f, err := openFile(l.ListFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	paths := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			paths = append(paths, line)
+		}
+	}
+	return paths, scanner.Err()
+}
+
+// migrationName turns a description like "Add user props" into
+// add_user_props.
+func migrationName(description string) string {
+	return strings.Trim(migrationNamePattern.ReplaceAllString(strings.ToLower(description), "_"), "_")
+}
+
+func migrationsNewCmdF(command *cobra.Command, args []string) error {
+	layout, err := getMigrationsLayout(command)
+	if err != nil {
+		return err
+	}
+	name := migrationName(args[0])
+	if name == "" {
+		return errors.New("Invalid migration name")
+	}
+	command.SilenceUsage = true
+
+	files, _, err := layout.files()
+	if err != nil {
+		return err
+	}
+	number := 1
+	for _, file := range files {
+		if file.Number >= number {
+			number = file.Number + 1
+		}
+		if file.Name == name {
+			return fmt.Errorf("A migration named %s already exists.", name)
+		}
+	}
+
+	created := []migrationFile{}
+	for _, driver := range migrationDrivers {
+		for _, direction := range []string{"up", "down"} {
+			file := migrationFile{Driver: driver, Number: number, Name: name, Direction: direction}
+			path := filepath.Join(layout.Dir, filepath.FromSlash(file.Path()))
+			if err := mkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			content := fmt.Sprintf("-- %s migration %s of %s.\n", strings.Title(direction), name, driver)
+			if err := writeFile(path, []byte(content), 0644); err != nil {
+				return err
+			}
+			created = append(created, file)
+			fmt.Println("Created", path)
+		}
+	}
+
+	registered, err := layout.registered()
+	if err != nil {
+		return err
+	}
+	if registered == nil {
+		logger.Warn("No migrations list, the new migration is not registered", "path", layout.ListFile)
+		return nil
+	}
+	for _, file := range created {
+		registered = append(registered, file.Path())
+	}
+	sort.Strings(registered)
+	return writeFile(layout.ListFile, []byte(strings.Join(registered, "\n")+"\n"), 0644)
+}
+
+// checkMigrations returns the problems of the migration files and of their
+// registration.
+func checkMigrations(files []migrationFile, unknown, registered []string) []string {
+	problems := []string{}
+	for _, path := range unknown {
+		problems = append(problems, fmt.Sprintf("%s is not named like NNNNNN_name.up.sql or NNNNNN_name.down.sql", path))
+	}
+
+	byDriver := map[string]map[int]map[string]bool{}
+	names := map[string]map[int]string{}
+	for _, file := range files {
+		if byDriver[file.Driver] == nil {
+			byDriver[file.Driver] = map[int]map[string]bool{}
+			names[file.Driver] = map[int]string{}
+		}
+		if byDriver[file.Driver][file.Number] == nil {
+			byDriver[file.Driver][file.Number] = map[string]bool{}
+		}
+		if previous, ok := names[file.Driver][file.Number]; ok && previous != file.Name {
+			problems = append(problems, fmt.Sprintf("%s: number %06d is used by %s and %s", file.Driver, file.Number, previous, file.Name))
+		}
+		names[file.Driver][file.Number] = file.Name
+		byDriver[file.Driver][file.Number][file.Direction] = true
+	}
+
+	for _, driver := range migrationDrivers {
+		numbers := []int{}
+		for number := range byDriver[driver] {
+			numbers = append(numbers, number)
+		}
+		sort.Ints(numbers)
+		for i, number := range numbers {
+			if i == 0 && number != 1 {
+				problems = append(problems, fmt.Sprintf("%s: the first migration is %06d instead of 000001", driver, number))
+			} else if i > 0 && number == numbers[i-1]+2 {
+				problems = append(problems, fmt.Sprintf("%s: migration %06d is missing", driver, number-1))
+			} else if i > 0 && number != numbers[i-1]+1 {
+				problems = append(problems, fmt.Sprintf("%s: migrations %06d to %06d are missing", driver, numbers[i-1]+1, number-1))
+			}
+			directions := byDriver[driver][number]
+			name := names[driver][number]
+			if !directions["up"] {
+				problems = append(problems, fmt.Sprintf("%s: %06d_%s has no up migration", driver, number, name))
+			}
+			if !directions["down"] {
+				problems = append(problems, fmt.Sprintf("%s: %06d_%s has no down migration", driver, number, name))
+			}
+			for _, other := range migrationDrivers {
+				if other == driver {
+					continue
+				}
+				if otherName, ok := names[other][number]; !ok {
+					problems = append(problems, fmt.Sprintf("%s: %06d_%s has no %s migration", driver, number, name, other))
+				} else if otherName != name && driver < other {
+					problems = append(problems, fmt.Sprintf("%06d is %s for %s and %s for %s", number, name, driver, otherName, other))
+				}
+			}
+		}
+	}
+
+	if registered != nil {
+		listed := map[string]bool{}
+		for _, path := range registered {
+			listed[path] = true
+		}
+		existing := map[string]bool{}
+		for _, file := range files {
+			existing[file.Path()] = true
+			if !listed[file.Path()] {
+				problems = append(problems, fmt.Sprintf("%s is not registered in the migrations list", file.Path()))
+			}
+		}
+		for _, path := range registered {
+			if !existing[path] {
+				problems = append(problems, fmt.Sprintf("%s is registered but does not exist", path))
+			}
+		}
+	}
+	return problems
+}
+
+func migrationsCheckCmdF(command *cobra.Command, args []string) error {
+	layout, err := getMigrationsLayout(command)
+	if err != nil {
+		return err
+	}
+	command.SilenceUsage = true
+
+	files, unknown, err := layout.files()
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 && len(unknown) == 0 {
+		return fmt.Errorf("No migration found in %s.", layout.Dir)
+	}
+	registered, err := layout.registered()
+	if err != nil {
+		return err
+	}
+	if registered == nil {
+		logger.Warn("No migrations list, the registration is not checked", "path", layout.ListFile)
+	}
+
+	problems := checkMigrations(files, unknown, registered)
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problems found in the migrations.", len(problems))
+	}
+	return nil
+}
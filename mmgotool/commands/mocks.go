@@ -0,0 +1,595 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/xzl8028/xenia-utilities/mmgotool/internal/codegen"
+)
+
+// mocksGenerators are the generator names found in the header of the mocks,
+// the current one first. Mocks generated before the command moved under
+// codegen are still recognized as stale.
+var mocksGenerators = []string{"mmgotool codegen mocks generate", "mmgotool mocks generate"}
+
+//go:embed templates/mocks/*.tmpl
+var mocksTemplates embed.FS
+
+var MocksCmd = &cobra.Command{
+	Use:   "mocks",
+	Short: "Interface mocks generation",
+}
+
+var MocksGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate the mocks of a package",
+	Long: `Generate a testify compatible mock for every interface declared in the package, one file per interface.
+Generated mocks whose interface no longer exists are removed.`,
+	Example: "  codegen mocks generate --dir store --output store/storetest/mocks",
+	RunE:    mocksGenerateCmdF,
+}
+
+var MocksCheckCmd = &cobra.Command{
+	Use:     "check",
+	Short:   "Check that the mocks of a package are up to date",
+	Example: "  codegen mocks check --dir store --output store/storetest/mocks",
+	RunE:    mocksCheckCmdF,
+}
+
+func init() {
+	for _, command := range []*cobra.Command{MocksGenerateCmd, MocksCheckCmd} {
+		command.Flags().String("dir", "./", "Path to the package declaring the interfaces")
+		command.Flags().String("output", "", "Path to the mocks directory, defaults to the mocks directory inside the package")
+		command.Flags().String("package", "mocks", "Package name of the generated mocks")
+		command.Flags().StringArray("interface", []string{}, "Interface to mock, can be repeated, defaults to every exported interface")
+		command.Flags().String("import-path", "", "Import path of the package, detected from go.mod or GOPATH by default")
+	}
+	MocksCmd.AddCommand(MocksGenerateCmd)
+	MocksCmd.AddCommand(MocksCheckCmd)
+	CodegenCmd.AddCommand(MocksCmd)
+}
+
+type mockResult struct {
+	Type     string
+	IsError  bool
+	Nillable bool
+}
+
+type mockMethod struct {
+	Name         string
+	ParamsDecl   string
+	ParamTypes   string
+	ParamNames   string
+	CallArgs     string
+	FixedArgs    string
+	Variadic     bool
+	VariadicName string
+	Results      []mockResult
+	ResultsDecl  string
+	ResultNames  string
+}
+
+type mockInterface struct {
+	Package    string
+	Name       string
+	StdImports []string
+	Imports    []string
+	Methods    []mockMethod
+}
+
+type mocksOptions struct {
+	Dir        string
+	Output     string
+	Package    string
+	Interfaces []string
+	ImportPath string
+}
+
+func getMocksOptions(command *cobra.Command) (*mocksOptions, error) {
+	dir, err := command.Flags().GetString("dir")
+	if err != nil {
+		return nil, errors.New("Invalid dir parameter")
+	}
+	output, err := command.Flags().GetString("output")
+	if err != nil {
+		return nil, errors.New("Invalid output parameter")
+	}
+	pkg, err := command.Flags().GetString("package")
+	if err != nil {
+		return nil, errors.New("Invalid package parameter")
+	}
+	interfaces, err := command.Flags().GetStringArray("interface")
+	if err != nil {
+		return nil, errors.New("Invalid interface parameter")
+	}
+	importPath, err := command.Flags().GetString("import-path")
+	if err != nil {
+		return nil, errors.New("Invalid import-path parameter")
+	}
+	if output == "" {
+		output = filepath.Join(dir, "mocks")
+	}
+	return &mocksOptions{Dir: dir, Output: output, Package: pkg, Interfaces: interfaces, ImportPath: importPath}, nil
+}
+
+// mockSource is the parsed package the mocks are generated from.
+type mockSource struct {
+	fset       *token.FileSet
+	name       string
+	importPath string
+	types      map[string]bool
+	interfaces map[string]*ast.InterfaceType
+	files      map[string]*ast.File
+	inPackage  bool
+}
+
+func loadMockSource(opts *mocksOptions) (*mockSource, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parseDir(fset, opts.Dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	var pkg *ast.Package
+	for _, p := range pkgs {
+		pkg = p
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("Expected one package in %s, found %d.", opts.Dir, len(pkgs))
+	}
+
+	source := &mockSource{
+		fset:       fset,
+		name:       pkg.Name,
+		types:      map[string]bool{},
+		interfaces: map[string]*ast.InterfaceType{},
+		files:      map[string]*ast.File{},
+	}
+	for _, f := range pkg.Files {
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				source.types[typeSpec.Name.Name] = true
+				if iface, ok := typeSpec.Type.(*ast.InterfaceType); ok {
+					source.interfaces[typeSpec.Name.Name] = iface
+					source.files[typeSpec.Name.Name] = f
+				}
+			}
+		}
+	}
+
+	sourceDir, err := filepath.Abs(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	outputDir, err := filepath.Abs(opts.Output)
+	if err != nil {
+		return nil, err
+	}
+	source.inPackage = sourceDir == outputDir
+	if !source.inPackage {
+		source.importPath = opts.ImportPath
+		if source.importPath == "" {
+			source.importPath, err = packageImportPath(sourceDir)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return source, nil
+}
+
+// packageImportPath guesses the import path of the package in dir from the
+// enclosing go.mod or from the GOPATH.
+func packageImportPath(dir string) (string, error) {
+	for root := dir; ; root = filepath.Dir(root) {
+		if module := readModulePath(filepath.Join(root, "go.mod")); module != "" {
+			rel, err := filepath.Rel(root, dir)
+			if err != nil {
+				return "", err
+			}
+			if rel == "." {
+				return module, nil
+			}
+			return module + "/" + filepath.ToSlash(rel), nil
+		}
+		if filepath.Dir(root) == root {
+			break
+		}
+	}
+
+	for _, gopath := range filepath.SplitList(os.Getenv("GOPATH")) {
+		src := filepath.Join(gopath, "src") + string(os.PathSeparator)
+		if strings.HasPrefix(dir, src) {
+			return filepath.ToSlash(strings.TrimPrefix(dir, src)), nil
+		}
+	}
+	return "", fmt.Errorf("Unable to find the import path of %s, use the import-path flag.", dir)
+}
+
+func readModulePath(goMod string) string {
+	f, err := openFile(goMod)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "module ") {
+			return strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module ")), "\"")
+		}
+	}
+	return ""
+}
+
+func (s *mockSource) interfaceNames(selected []string) ([]string, error) {
+	if len(selected) > 0 {
+		for _, name := range selected {
+			if _, ok := s.interfaces[name]; !ok {
+				return nil, fmt.Errorf("Interface %s not found in package %s.", name, s.name)
+			}
+		}
+		return selected, nil
+	}
+	names := []string{}
+	for name := range s.interfaces {
+		if ast.IsExported(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// qualify prefixes the types declared in the source package with its name
+// so they can be used from the mocks package.
+func (s *mockSource) qualify(expr ast.Expr) {
+	if s.inPackage {
+		return
+	}
+	ast.Inspect(expr, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.SelectorExpr:
+			return false
+		case *ast.Field:
+			s.qualify(node.Type)
+			return false
+		case *ast.Ident:
+			if s.types[node.Name] {
+				node.Name = s.name + "." + node.Name
+			}
+		}
+		return true
+	})
+}
+
+func (s *mockSource) typeString(expr ast.Expr) string {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, s.fset, expr)
+	return buf.String()
+}
+
+// methods returns the methods of the interface including the ones of the
+// embedded interfaces, sorted by name, and the files declaring them.
+func (s *mockSource) methods(name string, seen map[string]bool) (map[string]*ast.FuncType, []*ast.File, error) {
+	if seen[name] {
+		return map[string]*ast.FuncType{}, nil, nil
+	}
+	seen[name] = true
+
+	methods := map[string]*ast.FuncType{}
+	files := []*ast.File{s.files[name]}
+	for _, field := range s.interfaces[name].Methods.List {
+		if funcType, ok := field.Type.(*ast.FuncType); ok {
+			for _, method := range field.Names {
+				methods[method.Name] = funcType
+			}
+			continue
+		}
+
+		embedded, ok := field.Type.(*ast.Ident)
+		if !ok || s.interfaces[embedded.Name] == nil {
+			return nil, nil, fmt.Errorf("Interface %s embeds %s which is not an interface of package %s.", name, s.typeString(field.Type), s.name)
+		}
+		embeddedMethods, embeddedFiles, err := s.methods(embedded.Name, seen)
+		if err != nil {
+			return nil, nil, err
+		}
+		for method, funcType := range embeddedMethods {
+			methods[method] = funcType
+		}
+		files = append(files, embeddedFiles...)
+	}
+	return methods, files, nil
+}
+
+func (s *mockSource) mockInterface(name, pkg string) (*mockInterface, error) {
+	methods, files, err := s.methods(name, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+
+	iface := &mockInterface{Package: pkg, Name: name}
+	seenImports := map[string]bool{}
+	for _, f := range files {
+		for _, imp := range f.Imports {
+			spec := imp.Path.Value
+			if imp.Name != nil {
+				spec = imp.Name.Name + " " + spec
+			}
+			if seenImports[spec] {
+				continue
+			}
+			seenImports[spec] = true
+			if strings.Contains(strings.SplitN(imp.Path.Value, "/", 2)[0], ".") {
+				iface.Imports = append(iface.Imports, spec)
+			} else {
+				iface.StdImports = append(iface.StdImports, spec)
+			}
+		}
+	}
+	if !s.inPackage {
+		iface.Imports = append(iface.Imports, fmt.Sprintf("%s %q", s.name, s.importPath))
+	}
+	iface.Imports = append(iface.Imports, `mock "github.com/stretchr/testify/mock"`)
+	sort.Strings(iface.StdImports)
+	sort.Strings(iface.Imports)
+
+	methodNames := []string{}
+	for method := range methods {
+		methodNames = append(methodNames, method)
+	}
+	sort.Strings(methodNames)
+	for _, method := range methodNames {
+		iface.Methods = append(iface.Methods, s.mockMethod(method, methods[method]))
+	}
+	return iface, nil
+}
+
+func (s *mockSource) mockMethod(name string, funcType *ast.FuncType) mockMethod {
+	s.qualify(funcType)
+	method := mockMethod{Name: name}
+
+	decls, types, names, callArgs, fixedArgs := []string{}, []string{}, []string{}, []string{}, []string{}
+	for _, field := range funcType.Params.List {
+		typ := s.typeString(field.Type)
+		_, variadic := field.Type.(*ast.Ellipsis)
+		fieldNames := []string{}
+		for _, ident := range field.Names {
+			fieldNames = append(fieldNames, ident.Name)
+		}
+		if len(fieldNames) == 0 {
+			fieldNames = append(fieldNames, "_")
+		}
+		for _, paramName := range fieldNames {
+			if paramName == "_" {
+				paramName = fmt.Sprintf("_a%d", len(names))
+			}
+			decls = append(decls, paramName+" "+typ)
+			types = append(types, typ)
+			names = append(names, paramName)
+			if variadic {
+				method.Variadic = true
+				method.VariadicName = paramName
+				callArgs = append(callArgs, paramName+"...")
+			} else {
+				callArgs = append(callArgs, paramName)
+				fixedArgs = append(fixedArgs, paramName)
+			}
+		}
+	}
+	method.ParamsDecl = strings.Join(decls, ", ")
+	method.ParamTypes = strings.Join(types, ", ")
+	method.ParamNames = strings.Join(names, ", ")
+	method.CallArgs = strings.Join(callArgs, ", ")
+	method.FixedArgs = strings.Join(fixedArgs, ", ")
+
+	resultTypes, resultNames := []string{}, []string{}
+	if funcType.Results != nil {
+		for _, field := range funcType.Results.List {
+			count := len(field.Names)
+			if count == 0 {
+				count = 1
+			}
+			for i := 0; i < count; i++ {
+				typ := s.typeString(field.Type)
+				method.Results = append(method.Results, mockResult{
+					Type:     typ,
+					IsError:  typ == "error",
+					Nillable: isNillableType(field.Type),
+				})
+				resultTypes = append(resultTypes, typ)
+				resultNames = append(resultNames, fmt.Sprintf("r%d", len(resultNames)))
+			}
+		}
+	}
+	switch len(resultTypes) {
+	case 0:
+	case 1:
+		method.ResultsDecl = resultTypes[0]
+	default:
+		method.ResultsDecl = "(" + strings.Join(resultTypes, ", ") + ")"
+	}
+	method.ResultNames = strings.Join(resultNames, ", ")
+	return method
+}
+
+// isNillableType reports whether a value of the type can be nil. Named
+// types are assumed nillable unless they are predeclared basic types.
+func isNillableType(expr ast.Expr) bool {
+	switch typ := expr.(type) {
+	case *ast.Ident:
+		switch typ.Name {
+		case "bool", "string", "byte", "rune", "uintptr",
+			"int", "int8", "int16", "int32", "int64",
+			"uint", "uint8", "uint16", "uint32", "uint64",
+			"float32", "float64", "complex64", "complex128":
+			return false
+		}
+		return true
+	case *ast.ArrayType:
+		return typ.Len == nil
+	case *ast.StructType:
+		return false
+	}
+	return true
+}
+
+// renderMocks returns the generated source of every mock keyed by file path.
+func renderMocks(opts *mocksOptions) (map[string][]byte, error) {
+	source, err := loadMockSource(opts)
+	if err != nil {
+		return nil, err
+	}
+	names, err := source.interfaceNames(opts.Interfaces)
+	if err != nil {
+		return nil, err
+	}
+	engine, err := codegen.New("codegen mocks generate", mocksTemplates, "templates/mocks/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	mocks := map[string][]byte{}
+	for _, name := range names {
+		iface, err := source.mockInterface(name, opts.Package)
+		if err != nil {
+			return nil, err
+		}
+		src, err := engine.Render("mock.go", iface)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to generate the mock of %s: %s", name, err.Error())
+		}
+		mocks[filepath.Join(opts.Output, name+".go")] = src
+	}
+	return mocks, nil
+}
+
+// staleMocks returns the generated mocks of the output directory that are
+// not part of mocks.
+func staleMocks(output string, mocks map[string][]byte) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(output, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	stale := []string{}
+	for _, file := range files {
+		if _, ok := mocks[file]; ok {
+			continue
+		}
+		content, err := readFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, generator := range mocksGenerators {
+			if bytes.Contains(content, []byte(`// Code generated by "`+generator+`". DO NOT EDIT.`)) {
+				stale = append(stale, file)
+				break
+			}
+		}
+	}
+	return stale, nil
+}
+
+func sortedMockPaths(mocks map[string][]byte) []string {
+	paths := []string{}
+	for p := range mocks {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func mocksGenerateCmdF(command *cobra.Command, args []string) error {
+	opts, err := getMocksOptions(command)
+	if err != nil {
+		return err
+	}
+	mocks, err := renderMocks(opts)
+	if err != nil {
+		return err
+	}
+
+	if err := mkdirAll(opts.Output, 0755); err != nil {
+		return err
+	}
+	for _, p := range sortedMockPaths(mocks) {
+		if err := writeFile(p, mocks[p], 0644); err != nil {
+			return err
+		}
+	}
+
+	if len(opts.Interfaces) == 0 {
+		stale, err := staleMocks(opts.Output, mocks)
+		if err != nil {
+			return err
+		}
+		for _, p := range stale {
+			if err := removeFile(p); err != nil {
+				return err
+			}
+			fmt.Println("Removed", p)
+		}
+	}
+	fmt.Printf("Generated %d mocks in %s.\n", len(mocks), opts.Output)
+	return nil
+}
+
+func mocksCheckCmdF(command *cobra.Command, args []string) error {
+	opts, err := getMocksOptions(command)
+	if err != nil {
+		return err
+	}
+	mocks, err := renderMocks(opts)
+	if err != nil {
+		return err
+	}
+
+	outdated := []string{}
+	for _, p := range sortedMockPaths(mocks) {
+		upToDate, err := matchesFile(p, mocks[p])
+		if err != nil {
+			return err
+		}
+		if !upToDate {
+			outdated = append(outdated, p)
+		}
+	}
+	if len(opts.Interfaces) == 0 {
+		stale, err := staleMocks(opts.Output, mocks)
+		if err != nil {
+			return err
+		}
+		outdated = append(outdated, stale...)
+	}
+
+	if len(outdated) > 0 {
+		for _, p := range outdated {
+			fmt.Println("Out of date:", p)
+		}
+		command.SilenceUsage = true
+		return errors.New("The mocks are out of date, run mmgotool codegen mocks generate.")
+	}
+	fmt.Println("The mocks are up to date.")
+	return nil
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// namingPresets are the built-in naming policies of the translation ids.
+var namingPresets = map[string]string{
+	"dotted-lowercase":        `^[a-z0-9_]+(\.[a-z0-9_]+)+$`,
+	"package.file.func.error": `^[a-z0-9_]+(\.[a-z0-9_]+){3,}$`,
+}
+
+// defaultNamingDirs restricts the ids of a first segment to the source
+// folders using it, relative to the module root.
+var defaultNamingDirs = map[string][]string{
+	"api":   {"api", "api4"},
+	"app":   {"app"},
+	"cli":   {"cmd"},
+	"model": {"model"},
+	"store": {"store"},
+	"web":   {"web"},
+}
+
+type namingPolicy struct {
+	Name    string
+	pattern *regexp.Regexp
+	dirs    map[string][]string
+}
+
+type namingViolation struct {
+	Id     string `json:"id"`
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+func (v namingViolation) String() string {
+	return fmt.Sprintf("%s (%s): %s", v.Id, v.Path, v.Reason)
+}
+
+// parseNamingPolicy reads a preset name or a regular expression. The folder
+// rules of dirs replace the default ones for their prefixes.
+func parseNamingPolicy(spec string, dirs map[string][]string) (*namingPolicy, error) {
+	expr, ok := namingPresets[spec]
+	if !ok {
+		expr = spec
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		presets := []string{}
+		for name := range namingPresets {
+			presets = append(presets, name)
+		}
+		sort.Strings(presets)
+		return nil, fmt.Errorf("Invalid naming policy %q, use a regular expression or one of %s", spec, strings.Join(presets, ", "))
+	}
+
+	policy := &namingPolicy{Name: spec, pattern: pattern, dirs: map[string][]string{}}
+	for prefix, folders := range defaultNamingDirs {
+		policy.dirs[prefix] = folders
+	}
+	for prefix, folders := range dirs {
+		policy.dirs[prefix] = folders
+	}
+	return policy, nil
+}
+
+func (p *namingPolicy) check(ref keyRef) []namingViolation {
+	violations := []namingViolation{}
+	if !p.pattern.MatchString(ref.Id) {
+		violations = append(violations, namingViolation{Id: ref.Id, Path: ref.Path, Reason: fmt.Sprintf("does not match the %s naming policy", p.Name)})
+	}
+
+	prefix := strings.SplitN(ref.Id, ".", 2)[0]
+	folders, ok := p.dirs[prefix]
+	if !ok || ref.Path == "" {
+		return violations
+	}
+	for _, folder := range folders {
+		if strings.HasPrefix(ref.Path, strings.TrimSuffix(folder, "/")+"/") {
+			return violations
+		}
+	}
+	return append(violations, namingViolation{Id: ref.Id, Path: ref.Path, Reason: fmt.Sprintf("%s.* ids belong to %s", prefix, strings.Join(folders, "/, ")+"/")})
+}
+
+// namingViolations checks the references of the given ids, every id once.
+func (p *namingPolicy) namingViolations(refs []keyRef, ids []string) []namingViolation {
+	wanted := map[string]bool{}
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	violations := []namingViolation{}
+	seen := map[string]bool{}
+	for _, ref := range refs {
+		key := ref.Id + "\x00" + ref.Path
+		if !wanted[ref.Id] || seen[key] {
+			continue
+		}
+		seen[key] = true
+		violations = append(violations, p.check(ref)...)
+	}
+	return violations
+}
+
+// sourceRelativePath returns the path of a source file relative to the
+// module root containing it, with forward slashes.
+func sourceRelativePath(opts *extractOptions, filePath string) string {
+	best := ""
+	dirs := opts.SourceDirs()
+	if opts.WebappDir != "" {
+		dirs = append(dirs, opts.WebappDir)
+	}
+	for _, dir := range dirs {
+		rel, err := filepath.Rel(dir, filePath)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if best == "" || len(rel) < len(best) {
+			best = rel
+		}
+	}
+	return filepath.ToSlash(best)
+}
@@ -0,0 +1,251 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var PerfCmd = &cobra.Command{
+	Use:   "perf",
+	Short: "Performance tooling",
+}
+
+var PerfRegressCmd = &cobra.Command{
+	Use:   "regress",
+	Short: "Compare benchmarks between two git refs",
+	Long: `Run the benchmarks of the selected packages on the base and head git refs, print a Markdown comparison table and fail when a benchmark regresses beyond the thresholds.
+
+When benchstat is installed its detailed statistics are printed too.`,
+	Example: "  dev perf regress --base origin/master --package ./store/sqlstore/... --bench BenchmarkGetPosts",
+	RunE:    perfRegressCmdF,
+}
+
+func init() {
+	PerfRegressCmd.Flags().String("repo-dir", "./", "Path to the git repository")
+	PerfRegressCmd.Flags().String("base", "origin/master", "Git ref of the baseline")
+	PerfRegressCmd.Flags().String("head", "HEAD", "Git ref to compare against the baseline")
+	PerfRegressCmd.Flags().StringArray("package", []string{}, "Package to benchmark, can be repeated")
+	PerfRegressCmd.Flags().String("bench", ".", "Regular expression selecting the benchmarks to run")
+	PerfRegressCmd.Flags().Int("count", 5, "Number of times every benchmark runs")
+	PerfRegressCmd.Flags().Float64("threshold", 10, "Maximum allowed increase of ns/op, in percent")
+	PerfRegressCmd.Flags().Float64("mem-threshold", 0, "Maximum allowed increase of B/op and allocs/op, in percent (0 disables the check)")
+	PerfRegressCmd.Flags().String("output", "", "Write the Markdown table to this file too")
+	PerfCmd.AddCommand(PerfRegressCmd)
+	DevCmd.AddCommand(PerfCmd)
+}
+
+var benchmarkLineRegexp = regexp.MustCompile(`^(Benchmark\S+?)(-\d+)?\s+\d+\s+(.*)$`)
+
+// benchmarkResults maps every benchmark to the values of each of its metrics
+// (ns/op, B/op, allocs/op...) over all the runs.
+type benchmarkResults map[string]map[string][]float64
+
+func parseBenchmarkOutput(output []byte) benchmarkResults {
+	results := benchmarkResults{}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		match := benchmarkLineRegexp.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if match == nil {
+			continue
+		}
+		fields := strings.Fields(match[3])
+		for i := 0; i+1 < len(fields); i += 2 {
+			value, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				continue
+			}
+			if results[match[1]] == nil {
+				results[match[1]] = map[string][]float64{}
+			}
+			results[match[1]][fields[i+1]] = append(results[match[1]][fields[i+1]], value)
+		}
+	}
+	return results
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, value := range values {
+		total += value
+	}
+	return total / float64(len(values))
+}
+
+// runBenchmarks checks out ref in a temporary worktree and runs the
+// benchmarks there.
+func runBenchmarks(repoDir, ref string, packages []string, bench string, count int) ([]byte, error) {
+	worktree, err := ioutil.TempDir("", "mmgotool-perf-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(worktree)
+
+	if output, err := execCommand("git", "-C", repoDir, "worktree", "add", "--detach", worktree, ref).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("Unable to check out %s: %s", ref, strings.TrimSpace(string(output)))
+	}
+	defer execCommand("git", "-C", repoDir, "worktree", "remove", "--force", worktree).Run()
+
+	args := append([]string{"test", "-run", "^$", "-bench", bench, "-benchmem", "-count", strconv.Itoa(count)}, packages...)
+	cmd := execCommand("go", args...)
+	cmd.Dir = worktree
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("Benchmarks failed on %s: %s", ref, err.Error())
+	}
+	return output, nil
+}
+
+type benchmarkComparison struct {
+	Name   string
+	Metric string
+	Base   float64
+	Head   float64
+}
+
+func (c benchmarkComparison) Delta() float64 {
+	if c.Base == 0 {
+		return 0
+	}
+	return (c.Head - c.Base) / c.Base * 100
+}
+
+func compareBenchmarks(base, head benchmarkResults) []benchmarkComparison {
+	comparisons := []benchmarkComparison{}
+	for name, metrics := range head {
+		for metric, values := range metrics {
+			baseValues := base[name][metric]
+			if len(baseValues) == 0 {
+				continue
+			}
+			comparisons = append(comparisons, benchmarkComparison{Name: name, Metric: metric, Base: mean(baseValues), Head: mean(values)})
+		}
+	}
+	sort.Slice(comparisons, func(i, j int) bool {
+		if comparisons[i].Name != comparisons[j].Name {
+			return comparisons[i].Name < comparisons[j].Name
+		}
+		return comparisons[i].Metric < comparisons[j].Metric
+	})
+	return comparisons
+}
+
+func perfRegressCmdF(command *cobra.Command, args []string) error {
+	repoDir, err := command.Flags().GetString("repo-dir")
+	if err != nil {
+		return errors.New("Invalid repo-dir parameter")
+	}
+	base, err := command.Flags().GetString("base")
+	if err != nil {
+		return errors.New("Invalid base parameter")
+	}
+	head, err := command.Flags().GetString("head")
+	if err != nil {
+		return errors.New("Invalid head parameter")
+	}
+	packages, err := command.Flags().GetStringArray("package")
+	if err != nil || len(packages) == 0 {
+		return errors.New("Invalid package parameter")
+	}
+	bench, err := command.Flags().GetString("bench")
+	if err != nil {
+		return errors.New("Invalid bench parameter")
+	}
+	count, err := command.Flags().GetInt("count")
+	if err != nil {
+		return errors.New("Invalid count parameter")
+	}
+	threshold, err := command.Flags().GetFloat64("threshold")
+	if err != nil {
+		return errors.New("Invalid threshold parameter")
+	}
+	memThreshold, err := command.Flags().GetFloat64("mem-threshold")
+	if err != nil {
+		return errors.New("Invalid mem-threshold parameter")
+	}
+	output, err := command.Flags().GetString("output")
+	if err != nil {
+		return errors.New("Invalid output parameter")
+	}
+
+	command.SilenceUsage = true
+	baseOutput, err := runBenchmarks(repoDir, base, packages, bench, count)
+	if err != nil {
+		return err
+	}
+	headOutput, err := runBenchmarks(repoDir, head, packages, bench, count)
+	if err != nil {
+		return err
+	}
+
+	if benchstat, err := exec.LookPath("benchstat"); err == nil {
+		if statsOutput, err := runBenchstat(benchstat, baseOutput, headOutput); err == nil {
+			fmt.Println(string(statsOutput))
+		}
+	}
+
+	var table bytes.Buffer
+	fmt.Fprintf(&table, "| Benchmark | Metric | %s | %s | Delta |\n", base, head)
+	fmt.Fprintln(&table, "|---|---|---:|---:|---:|")
+	regressions := 0
+	for _, c := range compareBenchmarks(parseBenchmarkOutput(baseOutput), parseBenchmarkOutput(headOutput)) {
+		limit := threshold
+		if c.Metric != "ns/op" {
+			limit = memThreshold
+		}
+		marker := ""
+		if limit > 0 && c.Delta() > limit {
+			marker = " :x:"
+			regressions++
+		}
+		fmt.Fprintf(&table, "| %s | %s | %.2f | %.2f | %+.2f%%%s |\n", c.Name, c.Metric, c.Base, c.Head, c.Delta(), marker)
+	}
+
+	fmt.Print(table.String())
+	if output != "" {
+		if err := writeFile(output, table.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+
+	if regressions > 0 {
+		return fmt.Errorf("%d benchmark regressions beyond the thresholds.", regressions)
+	}
+	return nil
+}
+
+func runBenchstat(benchstat string, baseOutput, headOutput []byte) ([]byte, error) {
+	dir, err := ioutil.TempDir("", "mmgotool-benchstat-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	baseFile := filepath.Join(dir, "base.txt")
+	headFile := filepath.Join(dir, "head.txt")
+	if err := ioutil.WriteFile(baseFile, baseOutput, 0644); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(headFile, headOutput, 0644); err != nil {
+		return nil, err
+	}
+	return execCommand(benchstat, baseFile, headFile).Output()
+}
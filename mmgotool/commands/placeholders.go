@@ -0,0 +1,90 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"regexp"
+	"sort"
+)
+
+var (
+	templateActionRegexp = regexp.MustCompile(`{{[^}]*}}`)
+	templateFieldRegexp  = regexp.MustCompile(`\.([A-Za-z_][A-Za-z0-9_]*)`)
+	printfVerbRegexp     = regexp.MustCompile(`%(\[\d+\])?[-+# 0]*(\d+|\*)?(\.(\d+|\*))?[a-zA-Z%]`)
+)
+
+// extractPlaceholders returns the sorted placeholders used in text. Template
+// fields like {{.Name}} are reported once as "{{.Name}}", printf verbs like %s
+// are reported as many times as they appear because their position matters.
+func extractPlaceholders(text string) []string {
+	placeholders := []string{}
+	seen := map[string]bool{}
+	for _, action := range templateActionRegexp.FindAllString(text, -1) {
+		for _, match := range templateFieldRegexp.FindAllStringSubmatch(action, -1) {
+			placeholder := "{{." + match[1] + "}}"
+			if !seen[placeholder] {
+				seen[placeholder] = true
+				placeholders = append(placeholders, placeholder)
+			}
+		}
+	}
+
+	withoutActions := templateActionRegexp.ReplaceAllString(text, "")
+	for _, verb := range printfVerbRegexp.FindAllString(withoutActions, -1) {
+		if verb != "%%" {
+			placeholders = append(placeholders, verb)
+		}
+	}
+	sort.Strings(placeholders)
+	return placeholders
+}
+
+// translationPlaceholders returns the placeholders of a translation value.
+// Plural translations report the placeholders of all their forms.
+func translationPlaceholders(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return extractPlaceholders(v)
+	case map[string]interface{}:
+		seen := map[string]bool{}
+		placeholders := []string{}
+		for _, form := range v {
+			for _, placeholder := range translationPlaceholders(form) {
+				if !seen[placeholder] {
+					seen[placeholder] = true
+					placeholders = append(placeholders, placeholder)
+				}
+			}
+		}
+		sort.Strings(placeholders)
+		return placeholders
+	}
+	return []string{}
+}
+
+// comparePlaceholders returns the placeholders of expected missing in actual
+// and the ones of actual not present in expected.
+func comparePlaceholders(expected, actual []string) ([]string, []string) {
+	counts := map[string]int{}
+	for _, placeholder := range expected {
+		counts[placeholder]++
+	}
+	for _, placeholder := range actual {
+		counts[placeholder]--
+	}
+
+	missing := []string{}
+	extra := []string{}
+	for placeholder, count := range counts {
+		for ; count > 0; count-- {
+			missing = append(missing, placeholder)
+		}
+		for ; count < 0; count++ {
+			extra = append(extra, placeholder)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+	return missing, extra
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractPlaceholders(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         string
+		placeholders []string
+	}{
+		{name: "no placeholder", text: "Unable to save.", placeholders: []string{}},
+		{name: "template fields", text: "{{.Name}} joined {{.Team}}", placeholders: []string{"{{.Name}}", "{{.Team}}"}},
+		{name: "template field used twice", text: "{{.Name}} is {{.Name}}", placeholders: []string{"{{.Name}}"}},
+		{name: "template actions", text: "{{if .Count}}{{.Count}} new{{end}}", placeholders: []string{"{{.Count}}"}},
+		{name: "printf verbs", text: "%s has %d users", placeholders: []string{"%d", "%s"}},
+		{name: "printf verb used twice", text: "%s and %s", placeholders: []string{"%s", "%s"}},
+		{name: "printf flags and indexes", text: "%[2]s %-5d %.2f", placeholders: []string{"%-5d", "%.2f", "%[2]s"}},
+		{name: "percent sign", text: "100%% done", placeholders: []string{}},
+		{name: "template and printf", text: "{{.Name}} uploaded %d files", placeholders: []string{"%d", "{{.Name}}"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if placeholders := extractPlaceholders(test.text); !reflect.DeepEqual(placeholders, test.placeholders) {
+				t.Errorf("got placeholders %q, want %q", placeholders, test.placeholders)
+			}
+		})
+	}
+}
+
+func TestComparePlaceholders(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		target   string
+		missing  []string
+		extra    []string
+		mismatch string
+	}{
+		{name: "same placeholders", source: "{{.Name}} has %d files", target: "%d Dateien von {{.Name}}", missing: []string{}, extra: []string{}},
+		{name: "missing field", source: "{{.Name}} joined {{.Team}}", target: "{{.Name}} ist beigetreten", missing: []string{"{{.Team}}"}, extra: []string{}, mismatch: "missing-placeholder"},
+		{name: "renamed field", source: "Hello {{.Name}}", target: "Hallo {{.Nom}}", missing: []string{"{{.Name}}"}, extra: []string{"{{.Nom}}"}, mismatch: "unexpected-placeholder"},
+		{name: "printf verb dropped once", source: "%s and %s", target: "%s und", missing: []string{"%s"}, extra: []string{}, mismatch: "missing-placeholder"},
+		{name: "printf verb changed", source: "%d files", target: "%s Dateien", missing: []string{"%d"}, extra: []string{"%s"}, mismatch: "unexpected-placeholder"},
+		{name: "field used once more", source: "{{.Name}}", target: "{{.Name}} ({{.Name}})", missing: []string{}, extra: []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			missing, extra := comparePlaceholders(extractPlaceholders(test.source), extractPlaceholders(test.target))
+			if !reflect.DeepEqual(missing, test.missing) || !reflect.DeepEqual(extra, test.extra) {
+				t.Errorf("got missing %q and extra %q, want %q and %q", missing, extra, test.missing, test.extra)
+			}
+
+			source := map[string]interface{}{"app.x": test.source}
+			mismatches := findPlaceholderMismatches(source, "de", []Translation{{Id: "app.x", Translation: test.target}})
+			switch {
+			case test.mismatch == "" && len(mismatches) > 0:
+				t.Errorf("unexpected mismatch %s", mismatches[0])
+			case test.mismatch != "" && len(mismatches) != 1:
+				t.Errorf("got %d mismatches, want one", len(mismatches))
+			case test.mismatch != "" && mismatches[0].class() != test.mismatch:
+				t.Errorf("got class %s, want %s", mismatches[0].class(), test.mismatch)
+			}
+		})
+	}
+}
+
+func TestFindPlaceholderMismatches(t *testing.T) {
+	source := map[string]interface{}{
+		"app.count":   map[string]interface{}{"one": "{{.Count}} file", "other": "{{.Count}} files"},
+		"app.greet":   "Hello {{.Name}}",
+		"app.missing": "Hello {{.Name}}",
+	}
+	translations := []Translation{
+		{Id: "app.count", Translation: map[string]interface{}{"one": "eine Datei", "other": "{{.Count}} Dateien"}},
+		{Id: "app.greet", Translation: ""},
+		{Id: "app.missing", Translation: "Hallo"},
+		{Id: "app.unknown", Translation: "{{.Name}}"},
+	}
+	mismatches := findPlaceholderMismatches(source, "de", translations)
+	expected := []*placeholderMismatch{{Id: "app.missing", Locale: "de", Missing: []string{"{{.Name}}"}, Extra: []string{}}}
+	if !reflect.DeepEqual(mismatches, expected) {
+		t.Errorf("got mismatches %+v, want %+v", mismatches, expected)
+	}
+}
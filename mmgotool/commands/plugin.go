@@ -0,0 +1,395 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/xzl8028/xenia-utilities/mmgotool/internal/codegen"
+)
+
+//go:embed templates/plugin/*.tmpl
+var pluginTemplates embed.FS
+
+const (
+	pluginIdMinLength = 3
+	pluginIdMaxLength = 190
+)
+
+var (
+	pluginIdPattern = regexp.MustCompile(`^[a-zA-Z0-9-_.]+$`)
+	semverPattern   = regexp.MustCompile(`^v?(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)(?:-[0-9A-Za-z.-]+)?(?:\+[0-9A-Za-z.-]+)?$`)
+)
+
+// pluginSettingTypes are the setting types understood by the System Console.
+var pluginSettingTypes = map[string]bool{
+	"bool":      true,
+	"dropdown":  true,
+	"generated": true,
+	"radio":     true,
+	"text":      true,
+	"longtext":  true,
+	"number":    true,
+	"username":  true,
+	"custom":    true,
+}
+
+// pluginScaffoldFiles are the files written by plugin init, with the
+// template rendering them and the part of the plugin they belong to.
+var pluginScaffoldFiles = []struct {
+	Path     string
+	Template string
+	Part     string
+}{
+	{"plugin.json", "plugin.json", ""},
+	{"Makefile", "Makefile", ""},
+	{"server/main.go", "main.go", "server"},
+	{"server/plugin.go", "plugin.go", "server"},
+	{"webapp/package.json", "package.json", "webapp"},
+	{"webapp/webpack.config.js", "webpack.config.js", "webapp"},
+	{"webapp/src/index.js", "index.js", "webapp"},
+}
+
+var PluginCmd = &cobra.Command{
+	Use:   "plugin",
+	Short: "Plugin scaffolding and validation",
+}
+
+var PluginInitCmd = &cobra.Command{
+	Use:     "init <plugin id>",
+	Short:   "Scaffold a new plugin",
+	Long:    "Create a new plugin with its manifest, a server Go stub, a webapp stub and a Makefile building and packaging it",
+	Example: "  dev plugin init com.example.demo --name Demo --output ./demo",
+	Args:    cobra.ExactArgs(1),
+	RunE:    pluginInitCmdF,
+}
+
+var PluginValidateCmd = &cobra.Command{
+	Use:     "validate [plugin.json]",
+	Short:   "Validate a plugin manifest",
+	Long:    "Check a plugin manifest against the manifest schema: the id, the versions, the server executables, the webapp bundle and the types of the settings schema",
+	Example: "  dev plugin validate ./demo/plugin.json",
+	Args:    cobra.MaximumNArgs(1),
+	RunE:    pluginValidateCmdF,
+}
+
+func init() {
+	PluginInitCmd.Flags().String("name", "", "Display name of the plugin, defaults to the id")
+	PluginInitCmd.Flags().String("description", "", "Description of the plugin")
+	PluginInitCmd.Flags().String("output", "", "Folder of the new plugin, defaults to the id")
+	PluginInitCmd.Flags().String("min-server-version", "5.12.0", "Minimum server version supported by the plugin")
+	PluginInitCmd.Flags().Bool("no-server", false, "Do not scaffold the server part")
+	PluginInitCmd.Flags().Bool("no-webapp", false, "Do not scaffold the webapp part")
+	PluginInitCmd.Flags().Bool("force", false, "Overwrite the existing files")
+	PluginCmd.AddCommand(PluginInitCmd)
+	PluginCmd.AddCommand(PluginValidateCmd)
+	DevCmd.AddCommand(PluginCmd)
+}
+
+type pluginScaffold struct {
+	ID               string
+	Name             string
+	Description      string
+	MinServerVersion string
+	Server           bool
+	Webapp           bool
+}
+
+type pluginManifest struct {
+	Id               string                 `json:"id"`
+	Name             string                 `json:"name"`
+	Description      string                 `json:"description"`
+	HomepageURL      string                 `json:"homepage_url"`
+	SupportURL       string                 `json:"support_url"`
+	ReleaseNotesURL  string                 `json:"release_notes_url"`
+	IconPath         string                 `json:"icon_path"`
+	Version          string                 `json:"version"`
+	MinServerVersion string                 `json:"min_server_version"`
+	Server           *pluginManifestServer  `json:"server"`
+	Webapp           *pluginManifestWebapp  `json:"webapp"`
+	SettingsSchema   *pluginSettingsSchema  `json:"settings_schema"`
+	Props            map[string]interface{} `json:"props"`
+}
+
+type pluginManifestServer struct {
+	Executables map[string]string `json:"executables"`
+	Executable  string            `json:"executable"`
+}
+
+type pluginManifestWebapp struct {
+	BundlePath string `json:"bundle_path"`
+}
+
+type pluginSettingsSchema struct {
+	Header   string          `json:"header"`
+	Footer   string          `json:"footer"`
+	Settings []pluginSetting `json:"settings"`
+}
+
+type pluginSetting struct {
+	Key                string                `json:"key"`
+	DisplayName        string                `json:"display_name"`
+	Type               string                `json:"type"`
+	HelpText           string                `json:"help_text"`
+	RegenerateHelpText string                `json:"regenerate_help_text"`
+	Placeholder        string                `json:"placeholder"`
+	Default            interface{}           `json:"default"`
+	Options            []pluginSettingOption `json:"options"`
+}
+
+type pluginSettingOption struct {
+	DisplayName string `json:"display_name"`
+	Value       string `json:"value"`
+}
+
+func validatePluginId(id string) error {
+	if len(id) < pluginIdMinLength || len(id) > pluginIdMaxLength {
+		return fmt.Errorf("the id must be between %d and %d characters long", pluginIdMinLength, pluginIdMaxLength)
+	}
+	if !pluginIdPattern.MatchString(id) {
+		return errors.New("the id can only contain ASCII letters, digits, dashes, underscores and periods")
+	}
+	return nil
+}
+
+// validatePluginManifest returns the problems of the manifest, in the order
+// of the fields. Unknown fields are rejected while decoding.
+func validatePluginManifest(manifest *pluginManifest) []string {
+	problems := []string{}
+	if err := validatePluginId(manifest.Id); err != nil {
+		problems = append(problems, "id: "+err.Error())
+	}
+	if manifest.Name == "" {
+		problems = append(problems, "name: the name is required")
+	}
+	if manifest.Version != "" && !semverPattern.MatchString(manifest.Version) {
+		problems = append(problems, fmt.Sprintf("version: %q is not a semantic version", manifest.Version))
+	}
+	if manifest.MinServerVersion != "" && !semverPattern.MatchString(manifest.MinServerVersion) {
+		problems = append(problems, fmt.Sprintf("min_server_version: %q is not a semantic version", manifest.MinServerVersion))
+	}
+	if manifest.Server == nil && manifest.Webapp == nil {
+		problems = append(problems, "the plugin has neither a server nor a webapp part")
+	}
+
+	if manifest.Server != nil {
+		if len(manifest.Server.Executables) == 0 && manifest.Server.Executable == "" {
+			problems = append(problems, "server: no executable is declared")
+		}
+		for _, platform := range codegen.SortedKeys(manifest.Server.Executables) {
+			if !strings.Contains(platform, "-") {
+				problems = append(problems, fmt.Sprintf("server.executables: %q is not an <os>-<arch> platform", platform))
+			}
+			if filepath.IsAbs(manifest.Server.Executables[platform]) {
+				problems = append(problems, fmt.Sprintf("server.executables.%s: the path must be relative to the plugin", platform))
+			}
+		}
+	}
+	if manifest.Webapp != nil {
+		if manifest.Webapp.BundlePath == "" {
+			problems = append(problems, "webapp.bundle_path: the bundle path is required")
+		} else if filepath.IsAbs(manifest.Webapp.BundlePath) {
+			problems = append(problems, "webapp.bundle_path: the path must be relative to the plugin")
+		} else if !strings.HasSuffix(manifest.Webapp.BundlePath, ".js") {
+			problems = append(problems, "webapp.bundle_path: the bundle must be a JavaScript file")
+		}
+	}
+
+	if manifest.SettingsSchema != nil {
+		keys := map[string]bool{}
+		for i, setting := range manifest.SettingsSchema.Settings {
+			field := fmt.Sprintf("settings_schema.settings[%d]", i)
+			if setting.Key != "" {
+				field = "settings_schema." + setting.Key
+				if keys[strings.ToLower(setting.Key)] {
+					problems = append(problems, field+": the key is declared more than once")
+				}
+				keys[strings.ToLower(setting.Key)] = true
+			} else if setting.Type != "custom" {
+				problems = append(problems, field+": the key is required")
+			}
+			problems = append(problems, validatePluginSetting(field, setting)...)
+		}
+	}
+	return problems
+}
+
+func validatePluginSetting(field string, setting pluginSetting) []string {
+	problems := []string{}
+	if !pluginSettingTypes[setting.Type] {
+		return append(problems, fmt.Sprintf("%s: unknown type %q", field, setting.Type))
+	}
+
+	switch setting.Type {
+	case "dropdown", "radio":
+		if len(setting.Options) == 0 {
+			problems = append(problems, fmt.Sprintf("%s: a %s setting needs options", field, setting.Type))
+		}
+		if setting.Default != nil {
+			found := false
+			for _, option := range setting.Options {
+				if option.Value == fmt.Sprint(setting.Default) {
+					found = true
+				}
+			}
+			if !found {
+				problems = append(problems, fmt.Sprintf("%s: the default %v is not one of the options", field, setting.Default))
+			}
+		}
+	case "bool":
+		if _, ok := setting.Default.(bool); setting.Default != nil && !ok {
+			problems = append(problems, field+": the default of a bool setting must be true or false")
+		}
+	case "number":
+		if _, ok := setting.Default.(float64); setting.Default != nil && !ok {
+			problems = append(problems, field+": the default of a number setting must be a number")
+		}
+	default:
+		if len(setting.Options) > 0 {
+			problems = append(problems, fmt.Sprintf("%s: a %s setting does not take options", field, setting.Type))
+		}
+	}
+	return problems
+}
+
+func pluginInitCmdF(command *cobra.Command, args []string) error {
+	name, err := command.Flags().GetString("name")
+	if err != nil {
+		return errors.New("Invalid name parameter")
+	}
+	description, err := command.Flags().GetString("description")
+	if err != nil {
+		return errors.New("Invalid description parameter")
+	}
+	output, err := command.Flags().GetString("output")
+	if err != nil {
+		return errors.New("Invalid output parameter")
+	}
+	minServerVersion, err := command.Flags().GetString("min-server-version")
+	if err != nil {
+		return errors.New("Invalid min-server-version parameter")
+	}
+	noServer, err := command.Flags().GetBool("no-server")
+	if err != nil {
+		return errors.New("Invalid no-server parameter")
+	}
+	noWebapp, err := command.Flags().GetBool("no-webapp")
+	if err != nil {
+		return errors.New("Invalid no-webapp parameter")
+	}
+	force, err := command.Flags().GetBool("force")
+	if err != nil {
+		return errors.New("Invalid force parameter")
+	}
+
+	id := args[0]
+	if err := validatePluginId(id); err != nil {
+		return fmt.Errorf("Invalid plugin id: %s.", err.Error())
+	}
+	if !semverPattern.MatchString(minServerVersion) {
+		return errors.New("Invalid min-server-version parameter, a semantic version is expected")
+	}
+	if noServer && noWebapp {
+		return errors.New("A plugin needs a server or a webapp part")
+	}
+	if name == "" {
+		name = id
+	}
+	if output == "" {
+		output = id
+	}
+	command.SilenceUsage = true
+
+	scaffold := pluginScaffold{
+		ID:               id,
+		Name:             name,
+		Description:      description,
+		MinServerVersion: minServerVersion,
+		Server:           !noServer,
+		Webapp:           !noWebapp,
+	}
+	engine, err := codegen.New("dev plugin init", pluginTemplates, "templates/plugin/*.tmpl")
+	if err != nil {
+		return err
+	}
+
+	files := map[string][]byte{}
+	for _, file := range pluginScaffoldFiles {
+		if (file.Part == "server" && noServer) || (file.Part == "webapp" && noWebapp) {
+			continue
+		}
+		content, err := engine.RenderRaw(file.Template, scaffold)
+		if err != nil {
+			return err
+		}
+		if strings.HasSuffix(file.Path, ".go") {
+			if content, err = codegen.FormatSource(content); err != nil {
+				return err
+			}
+		}
+		path := filepath.Join(output, filepath.FromSlash(file.Path))
+		if _, err := os.Stat(path); err == nil && !force {
+			return fmt.Errorf("%s already exists, use --force to overwrite it.", path)
+		}
+		files[path] = content
+	}
+
+	for _, file := range pluginScaffoldFiles {
+		path := filepath.Join(output, filepath.FromSlash(file.Path))
+		content, ok := files[path]
+		if !ok {
+			continue
+		}
+		if err := mkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := writeFile(path, content, 0644); err != nil {
+			return err
+		}
+		fmt.Println("Created", path)
+	}
+	return nil
+}
+
+func pluginValidateCmdF(command *cobra.Command, args []string) error {
+	manifestPath := "plugin.json"
+	if len(args) == 1 {
+		manifestPath = args[0]
+	}
+	if info, err := os.Stat(manifestPath); err == nil && info.IsDir() {
+		manifestPath = filepath.Join(manifestPath, "plugin.json")
+	}
+	command.SilenceUsage = true
+
+	data, err := readFile(manifestPath)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	manifest := &pluginManifest{}
+	if err := decoder.Decode(manifest); err != nil {
+		return fmt.Errorf("Unable to parse %s: %s", manifestPath, err.Error())
+	}
+
+	problems := validatePluginManifest(manifest)
+	for _, problem := range problems {
+		fmt.Printf("%s: %s\n", manifestPath, problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problems found in the plugin manifest.", len(problems))
+	}
+	fmt.Println("Plugin manifest is valid.")
+	return nil
+}
@@ -0,0 +1,211 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/xzl8028/xenia-utilities/mmgotool/pkg/i18nextract"
+)
+
+var PluginI18nCmd = &cobra.Command{
+	Use:   "i18n",
+	Short: "Plugin translations tooling",
+}
+
+var PluginI18nCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the translations of a plugin",
+	Long: `Check the translation files of a plugin the way i18n check and the i18n validators check the server ones: the ids used by the server code, in server/, and by the webapp, in webapp/src/, must be in the English file and the English file must not hold unused ids. The ids used with a count must have plural forms, and the translations of every locale must keep the placeholders of the English string and have the plural categories of the locale.
+
+The plugin translation functions are added to the server ones with --function name:position, the position of the id among the arguments starting at 0.`,
+	Example: `  dev plugin i18n check --plugin-dir ./myplugin
+  dev plugin i18n check --plugin-dir ./myplugin --function Localize:1`,
+	Args: cobra.NoArgs,
+	RunE: pluginI18nCheckCmdF,
+}
+
+func init() {
+	PluginI18nCheckCmd.Flags().String("plugin-dir", ".", "Path to the plugin")
+	PluginI18nCheckCmd.Flags().String("i18n-dir", filepath.Join("assets", "i18n"), "Folder of the translation files, relative to the plugin-dir")
+	PluginI18nCheckCmd.Flags().StringArray("function", []string{}, "Translation function of the plugin as name:position of the id argument, can be repeated")
+	PluginI18nCheckCmd.Flags().Bool("include-tests", false, "Extract translations from the test files too")
+	PluginI18nCmd.AddCommand(PluginI18nCheckCmd)
+	PluginCmd.AddCommand(PluginI18nCmd)
+}
+
+// pluginTranslationFunctions returns the server translation functions with
+// the ones given as name:position.
+func pluginTranslationFunctions(specs []string) (map[string]int, error) {
+	functions := map[string]int{}
+	for name, position := range i18nextract.DefaultFunctions {
+		functions[name] = position
+	}
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Invalid function %s, expected name:position", spec)
+		}
+		position, err := strconv.Atoi(parts[1])
+		if err != nil || position < 0 {
+			return nil, fmt.Errorf("Invalid position of the function %s", spec)
+		}
+		functions[parts[0]] = position
+	}
+	return functions, nil
+}
+
+// pluginSourceDirs returns the folders of the plugin holding source code.
+func pluginSourceDirs(pluginDir string) []string {
+	dirs := []string{}
+	for _, dir := range []string{filepath.Join(pluginDir, "server"), filepath.Join(pluginDir, "webapp", "src")} {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// readPluginCatalog reads a translation file of a plugin, in the array or
+// the map format, as sorted translations.
+func readPluginCatalog(file string) ([]Translation, error) {
+	catalog, err := loadCatalog(file)
+	if err != nil {
+		return nil, err
+	}
+	translations := []Translation{}
+	for _, id := range catalog.Ids() {
+		translations = append(translations, Translation{Id: id, Translation: catalog.Translations[id]})
+	}
+	return translations, nil
+}
+
+func pluginI18nCheckCmdF(command *cobra.Command, args []string) error {
+	pluginDir, err := command.Flags().GetString("plugin-dir")
+	if err != nil {
+		return errors.New("Invalid plugin-dir parameter")
+	}
+	i18nDir, err := command.Flags().GetString("i18n-dir")
+	if err != nil {
+		return errors.New("Invalid i18n-dir parameter")
+	}
+	if !filepath.IsAbs(i18nDir) {
+		i18nDir = filepath.Join(pluginDir, i18nDir)
+	}
+	functionSpecs, err := command.Flags().GetStringArray("function")
+	if err != nil {
+		return errors.New("Invalid function parameter")
+	}
+	functions, err := pluginTranslationFunctions(functionSpecs)
+	if err != nil {
+		return err
+	}
+	includeTests, err := command.Flags().GetBool("include-tests")
+	if err != nil {
+		return errors.New("Invalid include-tests parameter")
+	}
+	command.SilenceUsage = true
+
+	extractor := i18nextract.New(i18nextract.Options{
+		Functions:    functions,
+		IncludeTests: includeTests,
+		Webapp:       true,
+		OnNonLiteral: func(pos token.Position, function string) {
+			logger.Debug("Skipping translation call with a non literal id", "position", pos.String(), "func", function)
+		},
+	})
+	keys, err := extractor.Extract(context.Background(), pluginSourceDirs(pluginDir)...)
+	if fileErrors, ok := err.(i18nextract.FileErrors); ok {
+		for _, fileError := range fileErrors {
+			logger.Warn("Unable to extract translations", "path", fileError.Path, "error", fileError.Err.Error())
+		}
+		return errors.New("Some source files of the plugin could not be extracted.")
+	} else if err != nil {
+		return err
+	}
+
+	english, err := readPluginCatalog(filepath.Join(i18nDir, "en.json"))
+	if err != nil {
+		return err
+	}
+	source := map[string]interface{}{}
+	for _, t := range english {
+		source[t.Id] = t.Translation
+	}
+
+	problems := 0
+	for _, id := range keys.Ids() {
+		if _, ok := source[id]; !ok {
+			fmt.Println("Missing:", id)
+			problems++
+		}
+	}
+	for _, t := range english {
+		if _, ok := keys[t.Id]; !ok {
+			fmt.Println("Unused:", t.Id)
+			problems++
+		}
+	}
+	refs := []keyRef{}
+	for _, id := range keys.Ids() {
+		refs = append(refs, keys[id]...)
+	}
+	plural := pluralKeyIds(refs)
+	pluralIds := []string{}
+	for id := range plural {
+		pluralIds = append(pluralIds, id)
+	}
+	sort.Strings(pluralIds)
+	for _, id := range pluralIds {
+		if value, ok := source[id]; ok {
+			if _, isPlural := parsePluralForms(value); !isPlural {
+				fmt.Printf("Plural: %s is used with a count but has no plural forms\n", id)
+				problems++
+			}
+		}
+	}
+	for _, problem := range findPluralProblems(source, "en", english) {
+		fmt.Println("Plural:", problem.String())
+		problems++
+	}
+
+	files, err := filepath.Glob(filepath.Join(i18nDir, "*.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		locale := localeName(file)
+		if locale == "en" {
+			continue
+		}
+		translations, err := readPluginCatalog(file)
+		if err != nil {
+			return err
+		}
+		for _, mismatch := range findPlaceholderMismatches(source, locale, translations) {
+			fmt.Println("Placeholders:", mismatch.String())
+			problems++
+		}
+		for _, problem := range findPluralProblems(source, locale, translations) {
+			fmt.Println("Plural:", problem.String())
+			problems++
+		}
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%d problems found in the plugin translations.", problems)
+	}
+	fmt.Printf("The %d translation ids of the plugin are valid.\n", len(english))
+	return nil
+}
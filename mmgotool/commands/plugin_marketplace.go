@@ -0,0 +1,297 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const marketplaceIconPrefix = "data:image/svg+xml;base64,"
+
+var labelColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+var PluginLintMarketplaceCmd = &cobra.Command{
+	Use:   "lint-marketplace <listing.json>",
+	Short: "Validate the marketplace listing of a plugin",
+	Long: `Validate the marketplace listing of a plugin against its built bundle: the manifest of the listing must be the one of the bundle, the labels need a name and a #rrggbb color, the icon must be an SVG data URI, the homepage and release notes URLs must answer and the bundle downloaded from the download URL must have the checksum of the built bundle.
+
+The listing is a marketplace entry or a list of entries, the one of the bundle plugin is checked. With --offline the URLs are not requested.`,
+	Example: `  dev plugin lint-marketplace listing.json --bundle dist/com.example.demo-1.0.0.tar.gz
+  dev plugin lint-marketplace plugins.json --bundle dist/demo.tar.gz --label integration --label productivity`,
+	Args: cobra.ExactArgs(1),
+	RunE: pluginLintMarketplaceCmdF,
+}
+
+func init() {
+	PluginLintMarketplaceCmd.Flags().String("bundle", "", "Path to the built .tar.gz bundle of the plugin")
+	PluginLintMarketplaceCmd.Flags().StringArray("label", []string{}, "Label allowed in the listing, can be repeated, any label when not set")
+	PluginLintMarketplaceCmd.Flags().Bool("offline", false, "Do not request the URLs of the listing")
+	PluginCmd.AddCommand(PluginLintMarketplaceCmd)
+}
+
+// marketplacePlugin is an entry of a marketplace listing.
+type marketplacePlugin struct {
+	HomepageURL     string             `json:"homepage_url"`
+	IconData        string             `json:"icon_data"`
+	DownloadURL     string             `json:"download_url"`
+	ReleaseNotesURL string             `json:"release_notes_url"`
+	Labels          []marketplaceLabel `json:"labels"`
+	Signature       string             `json:"signature"`
+	Manifest        pluginManifest     `json:"manifest"`
+}
+
+type marketplaceLabel struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	URL         string `json:"url"`
+	Color       string `json:"color"`
+}
+
+// readMarketplaceListing reads a marketplace entry or a list of entries.
+func readMarketplaceListing(file string) ([]marketplacePlugin, error) {
+	data, err := readFile(file)
+	if err != nil {
+		return nil, err
+	}
+	entries := []marketplacePlugin{}
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "{") {
+		entry := marketplacePlugin{}
+		err = json.Unmarshal(data, &entry)
+		entries = append(entries, entry)
+	} else {
+		err = json.Unmarshal(data, &entries)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse %s: %s", file, err.Error())
+	}
+	return entries, nil
+}
+
+// readBundleManifest returns the manifest of a .tar.gz plugin bundle.
+func readBundleManifest(bundle []byte) (*pluginManifest, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(bundle))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil, errors.New("no plugin.json in the bundle")
+		}
+		if err != nil {
+			return nil, err
+		}
+		file := strings.TrimPrefix(path.Clean(header.Name), "./")
+		if header.Typeflag != tar.TypeReg || path.Base(file) != "plugin.json" || strings.Count(file, "/") > 1 {
+			continue
+		}
+		manifest := &pluginManifest{}
+		if err := json.NewDecoder(archive).Decode(manifest); err != nil {
+			return nil, fmt.Errorf("Unable to parse the plugin.json of the bundle: %s", err.Error())
+		}
+		return manifest, nil
+	}
+}
+
+func isHTTPURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// checkMarketplaceLabels returns the problems of the labels, the allowed
+// ones only restricting the names when set.
+func checkMarketplaceLabels(labels []marketplaceLabel, allowed []string) []string {
+	problems := []string{}
+	for i, label := range labels {
+		field := fmt.Sprintf("labels[%d]", i)
+		if label.Name == "" {
+			problems = append(problems, field+": the name is required")
+		} else {
+			field = "labels." + label.Name
+			if len(allowed) > 0 && !containsString(allowed, label.Name) {
+				problems = append(problems, fmt.Sprintf("%s: the label is not one of %s", field, strings.Join(allowed, ", ")))
+			}
+		}
+		if label.Color != "" && !labelColorPattern.MatchString(label.Color) {
+			problems = append(problems, fmt.Sprintf("%s: %q is not a #rrggbb color", field, label.Color))
+		}
+		if label.URL != "" && !isHTTPURL(label.URL) {
+			problems = append(problems, fmt.Sprintf("%s: %q is not an http URL", field, label.URL))
+		}
+	}
+	return problems
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMarketplaceIcon returns the problem of the icon, empty when it is an
+// SVG data URI.
+func checkMarketplaceIcon(icon string) string {
+	if icon == "" {
+		return "icon_data: the icon is required"
+	}
+	if !strings.HasPrefix(icon, marketplaceIconPrefix) {
+		return "icon_data: the icon must be a " + marketplaceIconPrefix + " data URI"
+	}
+	svg, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(icon, marketplaceIconPrefix))
+	if err != nil {
+		return "icon_data: the icon is not valid base64"
+	}
+	if !bytes.Contains(svg, []byte("<svg")) {
+		return "icon_data: the icon is not an SVG image"
+	}
+	return ""
+}
+
+// checkURLAnswers returns the problem of a URL not answering with a
+// success or a redirection.
+func checkURLAnswers(client *http.Client, field, value string) string {
+	response, err := client.Get(value)
+	if err != nil {
+		return fmt.Sprintf("%s: %s", field, err.Error())
+	}
+	response.Body.Close()
+	if response.StatusCode >= 400 {
+		return fmt.Sprintf("%s: %s answers %s", field, value, response.Status)
+	}
+	return ""
+}
+
+func pluginLintMarketplaceCmdF(command *cobra.Command, args []string) error {
+	bundlePath, err := command.Flags().GetString("bundle")
+	if err != nil || bundlePath == "" {
+		return errors.New("Invalid bundle parameter")
+	}
+	allowedLabels, err := command.Flags().GetStringArray("label")
+	if err != nil {
+		return errors.New("Invalid label parameter")
+	}
+	offline, err := command.Flags().GetBool("offline")
+	if err != nil {
+		return errors.New("Invalid offline parameter")
+	}
+	command.SilenceUsage = true
+
+	bundle, err := readFile(bundlePath)
+	if err != nil {
+		return err
+	}
+	manifest, err := readBundleManifest(bundle)
+	if err != nil {
+		return fmt.Errorf("Unable to read the manifest of %s: %s", bundlePath, err.Error())
+	}
+	entries, err := readMarketplaceListing(args[0])
+	if err != nil {
+		return err
+	}
+	var entry *marketplacePlugin
+	for i := range entries {
+		if entries[i].Manifest.Id == manifest.Id {
+			entry = &entries[i]
+		}
+	}
+	if entry == nil {
+		return fmt.Errorf("%s has no entry for the %s plugin.", args[0], manifest.Id)
+	}
+
+	problems := []string{}
+	for _, problem := range validatePluginManifest(manifest) {
+		problems = append(problems, "bundle manifest: "+problem)
+	}
+	if entry.Manifest.Version != manifest.Version {
+		problems = append(problems, fmt.Sprintf("manifest.version: the listing has %q, the bundle %q", entry.Manifest.Version, manifest.Version))
+	}
+	if entry.Manifest.MinServerVersion != manifest.MinServerVersion {
+		problems = append(problems, fmt.Sprintf("manifest.min_server_version: the listing has %q, the bundle %q", entry.Manifest.MinServerVersion, manifest.MinServerVersion))
+	}
+	problems = append(problems, checkMarketplaceLabels(entry.Labels, allowedLabels)...)
+	if problem := checkMarketplaceIcon(entry.IconData); problem != "" {
+		problems = append(problems, problem)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	for _, u := range []struct {
+		Field    string
+		Value    string
+		Required bool
+	}{
+		{"homepage_url", entry.HomepageURL, true},
+		{"release_notes_url", entry.ReleaseNotesURL, false},
+		{"download_url", entry.DownloadURL, true},
+	} {
+		if u.Value == "" {
+			if u.Required {
+				problems = append(problems, u.Field+": the URL is required")
+			}
+			continue
+		}
+		if !isHTTPURL(u.Value) {
+			problems = append(problems, fmt.Sprintf("%s: %q is not an http URL", u.Field, u.Value))
+			continue
+		}
+		if offline || u.Field == "download_url" {
+			continue
+		}
+		if problem := checkURLAnswers(client, u.Field, u.Value); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+
+	if !offline && isHTTPURL(entry.DownloadURL) {
+		logger.Info("Downloading the released bundle", "url", entry.DownloadURL)
+		client.Timeout = 5 * time.Minute
+		released, err := downloadFile(client, entry.DownloadURL)
+		if err != nil {
+			problems = append(problems, "download_url: "+err.Error())
+		} else if expected, actual := sha256Hex(bundle), sha256Hex(released); expected != actual {
+			problems = append(problems, fmt.Sprintf("download_url: the released bundle has the checksum %s, the built bundle %s", actual, expected))
+		}
+	}
+
+	for _, problem := range problems {
+		fmt.Printf("%s: %s\n", args[0], problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problems found in the marketplace listing.", len(problems))
+	}
+	fmt.Println("The marketplace listing is valid.")
+	return nil
+}
+
+func downloadFile(client *http.Client, location string) ([]byte, error) {
+	response, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s answers %s", location, response.Status)
+	}
+	return ioutil.ReadAll(response.Body)
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"strings"
+)
+
+// PluralForms is a plural translation, indexed by CLDR plural category.
+type PluralForms map[string]string
+
+var pluralCategoriesOrder = []string{"zero", "one", "two", "few", "many", "other"}
+
+// cldrPluralCategories lists the cardinal plural categories CLDR defines for
+// integer counts in every language. Languages not listed only need "other".
+var cldrPluralCategories = map[string][]string{
+	"ar": {"zero", "one", "two", "few", "many", "other"},
+	"bg": {"one", "other"},
+	"ca": {"one", "other"},
+	"cs": {"one", "few", "other"},
+	"da": {"one", "other"},
+	"de": {"one", "other"},
+	"el": {"one", "other"},
+	"en": {"one", "other"},
+	"es": {"one", "other"},
+	"et": {"one", "other"},
+	"fa": {"one", "other"},
+	"fi": {"one", "other"},
+	"fr": {"one", "other"},
+	"he": {"one", "two", "other"},
+	"hr": {"one", "few", "other"},
+	"hu": {"one", "other"},
+	"id": {"other"},
+	"it": {"one", "other"},
+	"ja": {"other"},
+	"ko": {"other"},
+	"lt": {"one", "few", "other"},
+	"lv": {"zero", "one", "other"},
+	"nb": {"one", "other"},
+	"nl": {"one", "other"},
+	"pl": {"one", "few", "many", "other"},
+	"pt": {"one", "other"},
+	"ro": {"one", "few", "other"},
+	"ru": {"one", "few", "many", "other"},
+	"sk": {"one", "few", "other"},
+	"sl": {"one", "two", "few", "other"},
+	"sr": {"one", "few", "other"},
+	"sv": {"one", "other"},
+	"th": {"other"},
+	"tr": {"one", "other"},
+	"uk": {"one", "few", "many", "other"},
+	"vi": {"other"},
+	"zh": {"other"},
+}
+
+// requiredPluralCategories returns the plural categories a locale like
+// "pt-BR" or "zh_CN" must provide.
+func requiredPluralCategories(locale string) []string {
+	language := strings.ToLower(strings.FieldsFunc(locale, func(r rune) bool { return r == '-' || r == '_' })[0])
+	if categories, ok := cldrPluralCategories[language]; ok {
+		return categories
+	}
+	return []string{"other"}
+}
+
+func isPluralCategory(category string) bool {
+	for _, known := range pluralCategoriesOrder {
+		if known == category {
+			return true
+		}
+	}
+	return false
+}
+
+// PluralForms returns the typed plural translation when the translation is a
+// map of plural categories.
+func (t Translation) PluralForms() (PluralForms, bool) {
+	return parsePluralForms(t.Translation)
+}
+
+func parsePluralForms(value interface{}) (PluralForms, bool) {
+	var forms PluralForms
+	switch v := value.(type) {
+	case map[string]interface{}:
+		forms = PluralForms{}
+		for category, text := range v {
+			str, ok := text.(string)
+			if !ok {
+				return nil, false
+			}
+			forms[category] = str
+		}
+	case PluralForms:
+		forms = v
+	default:
+		return nil, false
+	}
+	for category := range forms {
+		if !isPluralCategory(category) {
+			return nil, false
+		}
+	}
+	return forms, true
+}
+
+// MissingCategories returns the categories required by the locale that are
+// absent or empty.
+func (p PluralForms) MissingCategories(locale string) []string {
+	missing := []string{}
+	for _, category := range requiredPluralCategories(locale) {
+		if p[category] == "" {
+			missing = append(missing, category)
+		}
+	}
+	return missing
+}
+
+func emptyPluralForms(locale string) PluralForms {
+	forms := PluralForms{}
+	for _, category := range requiredPluralCategories(locale) {
+		forms[category] = ""
+	}
+	return forms
+}
+
+func pluralKeyIds(refs []keyRef) map[string]bool {
+	plural := map[string]bool{}
+	for _, ref := range refs {
+		if ref.Plural {
+			plural[ref.Id] = true
+		}
+	}
+	return plural
+}
@@ -0,0 +1,529 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	remoteTokenEnv = "MMGOTOOL_REMOTE_TOKEN"
+	// remoteAnnotation marks the commands that can run on a remote server.
+	remoteAnnotation = "remote"
+)
+
+var RemoteCmd = &cobra.Command{
+	Use:   "remote",
+	Short: "Remote execution server",
+}
+
+var RemoteServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run the commands sent with --remote",
+	Long: `Serve the heavy commands, like the full tree extraction, sent by the clients using --remote http://host:port.
+Every request names the commits to check out: they are checked out in temporary git worktrees of the configured repositories and the command output is streamed back. Clients must run the same mmgotool binary and send the token of the server in $` + remoteTokenEnv + `.
+
+Only the check commands run remotely, with the flags which neither write files nor access the network. They run jailed in the checkouts, without network access and without running other programs, so --typed, which runs the go command, and --freeze-since, which runs git, are refused.
+
+The protocol is a JSON request answered with the output streamed as JSON lines over HTTP or HTTPS, served by the standard library. It is not gRPC: a grpc:// remote is refused, put the server behind an HTTPS proxy or load balancer instead of a gRPC one.`,
+	Example: "  MMGOTOOL_REMOTE_TOKEN=secret dev remote serve --xenia-repo /srv/xenia-server --enterprise-repo /srv/enterprise\n  dev remote serve --listen 0.0.0.0:7070 --token secret --xenia-repo /srv/xenia-server",
+	Args:    cobra.NoArgs,
+	RunE:    remoteServeCmdF,
+}
+
+func init() {
+	RemoteServeCmd.Flags().String("listen", "127.0.0.1:7070", "Address the server listens on")
+	RemoteServeCmd.Flags().String("token", "", "Token the clients must send, defaults to $"+remoteTokenEnv)
+	RemoteServeCmd.Flags().String("xenia-repo", "", "Git repository of the Xenia server source code")
+	RemoteServeCmd.Flags().String("enterprise-repo", "", "Git repository of the enterprise source code")
+	RemoteServeCmd.Flags().Int("jobs", 2, "Number of commands run at the same time")
+	RemoteCmd.AddCommand(RemoteServeCmd)
+	DevCmd.AddCommand(RemoteCmd)
+
+	RootCmd.PersistentFlags().String("remote", "", "http:// or https:// URL of a remote server running the heavy commands, see dev remote serve")
+	for command := range remoteFlags {
+		if command.Annotations == nil {
+			command.Annotations = map[string]string{}
+		}
+		command.Annotations[remoteAnnotation] = "true"
+	}
+}
+
+// remoteFlags are the commands which can run remotely, with their flags the
+// server accepts. The folder flags are replaced with the checkouts and the
+// flags writing files, accessing the network or running other programs, like
+// --typed and --freeze-since, are refused.
+var remoteFlags = map[*cobra.Command][]string{
+	CheckCmd: {
+		"exclude", "no-gitignore", "follow-symlinks", "include-tests", "include-tests-path", "jobs", "no-cache", "translation-package", "strict",
+		"include-server", "include-enterprise", "include-templates", "include-cmd",
+		"allow-empty", "fail-on", "class-exit-codes", "naming-policy", "format", "summary-threshold", "expand", "release", "expiry-window",
+	},
+	ErrcheckI18nCmd: {
+		"exclude", "no-gitignore", "follow-symlinks", "include-tests", "include-tests-path", "jobs", "no-cache", "translation-package", "strict",
+		"include-server", "include-enterprise", "include-templates", "include-cmd",
+		"fail-on", "class-exit-codes", "format",
+	},
+	VerifyAllCmd: {"check", "jobs"},
+}
+
+// remoteGlobalFlags are the flags of the root command the server accepts.
+var remoteGlobalFlags = []string{"verbose", "quiet", "log-format"}
+
+// commitPattern is the full commit hash sent by the clients, nothing which
+// git could take for an option or a revision expression.
+var commitPattern = regexp.MustCompile("^[0-9a-f]{40}$")
+
+// isRemoteFlag tells whether the server accepts the flag for the command.
+func isRemoteFlag(command *cobra.Command, name string) bool {
+	for _, allowed := range append(remoteFlags[command], remoteGlobalFlags...) {
+		if allowed == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isLocalFlag tells whether the flag only matters to the client: the
+// folders sent as commits and the sandbox of the client.
+func isLocalFlag(name string) bool {
+	switch name {
+	case "remote", "no-exec", "no-network", "jail":
+		return true
+	}
+	return strings.HasSuffix(name, "-dir")
+}
+
+// checkRemoteArgs checks the command line of a request: a command which can
+// run remotely, the flags it accepts in the --name=value form sent by the
+// clients, then its arguments.
+func checkRemoteArgs(args []string) error {
+	command, rest, err := RootCmd.Find(args)
+	if err != nil || command == RootCmd {
+		return errors.New("unknown command")
+	}
+	if _, ok := remoteFlags[command]; !ok {
+		return fmt.Errorf("the %s command can't run remotely", command.CommandPath())
+	}
+	for _, arg := range rest {
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name := strings.SplitN(strings.TrimPrefix(arg, "--"), "=", 2)[0]
+		if !strings.HasPrefix(arg, "--") || !strings.Contains(arg, "=") || !isRemoteFlag(command, name) {
+			return fmt.Errorf("the %s flag can't be used remotely", strings.SplitN(arg, "=", 2)[0])
+		}
+	}
+	return nil
+}
+
+type remoteRequest struct {
+	Version          string   `json:"version"`
+	Args             []string `json:"args"`
+	XeniaCommit      string   `json:"xenia_commit"`
+	EnterpriseCommit string   `json:"enterprise_commit,omitempty"`
+	// WithoutEnterprise is set when the command takes the enterprise source
+	// code but the client has none.
+	WithoutEnterprise bool `json:"without_enterprise,omitempty"`
+}
+
+// remoteMessage is a line of the streamed response: either output of the
+// command or, last, its exit code.
+type remoteMessage struct {
+	Stream   string `json:"stream,omitempty"`
+	Data     string `json:"data,omitempty"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// toolVersion identifies the running binary, the server only accepts
+// clients running exactly the same one.
+func toolVersion() (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(executable)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16], nil
+}
+
+func gitHead(dir string) (string, error) {
+	output, err := execCommand("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("Unable to read the commit of %s, the remote mode needs a git checkout", dir)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func warnIfDirty(dir string) {
+	output, err := execCommand("git", "-C", dir, "status", "--porcelain", "--untracked-files=no").Output()
+	if err == nil && len(strings.TrimSpace(string(output))) > 0 {
+		logger.Warn("Uncommitted changes are not sent to the remote server", "dir", dir)
+	}
+}
+
+// remoteArgs rebuilds the command line to run remotely. The local flags are
+// left out, the server passes its own checkouts.
+func remoteArgs(command *cobra.Command, args []string) []string {
+	path := strings.Fields(command.CommandPath())[1:]
+	remote := append([]string{}, path...)
+	command.Flags().Visit(func(flag *pflag.Flag) {
+		if isLocalFlag(flag.Name) {
+			return
+		}
+		switch flag.Value.Type() {
+		case "stringArray":
+			values, _ := command.Flags().GetStringArray(flag.Name)
+			for _, value := range values {
+				remote = append(remote, "--"+flag.Name+"="+value)
+			}
+		case "stringSlice":
+			values, _ := command.Flags().GetStringSlice(flag.Name)
+			for _, value := range values {
+				remote = append(remote, "--"+flag.Name+"="+value)
+			}
+		default:
+			remote = append(remote, "--"+flag.Name+"="+flag.Value.String())
+		}
+	})
+	return append(remote, args...)
+}
+
+// dispatchRemote replaces the run of the command with its execution on the
+// remote server.
+func dispatchRemote(command *cobra.Command, remoteURL string) error {
+	command.SilenceUsage = true
+	parsed, err := url.Parse(remoteURL)
+	if err == nil && parsed.Scheme == "grpc" {
+		return fmt.Errorf("Invalid remote %s, dev remote serve streams over HTTP, not gRPC: use http://%s or https://%s", remoteURL, parsed.Host, parsed.Host)
+	}
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return fmt.Errorf("Invalid remote %s, an http:// or https:// URL of dev remote serve is expected", remoteURL)
+	}
+	if command.Annotations[remoteAnnotation] != "true" {
+		return fmt.Errorf("The %s command can't run remotely", command.CommandPath())
+	}
+	var refused []string
+	command.Flags().Visit(func(flag *pflag.Flag) {
+		if !isLocalFlag(flag.Name) && !isRemoteFlag(command, flag.Name) {
+			refused = append(refused, "--"+flag.Name)
+		}
+	})
+	if len(refused) > 0 {
+		return fmt.Errorf("These flags can't be used with --remote: %s", strings.Join(refused, ", "))
+	}
+	command.RunE = func(command *cobra.Command, args []string) error {
+		return runRemote(command, args, strings.TrimSuffix(remoteURL, "/"))
+	}
+	return nil
+}
+
+func runRemote(command *cobra.Command, args []string, remoteURL string) error {
+	version, err := toolVersion()
+	if err != nil {
+		return err
+	}
+	request := remoteRequest{Version: version, Args: remoteArgs(command, args)}
+
+	xeniaDir := "./"
+	if flag := command.Flags().Lookup("xenia-dir"); flag != nil {
+		xeniaDir = flag.Value.String()
+	}
+	if request.XeniaCommit, err = gitHead(xeniaDir); err != nil {
+		return err
+	}
+	warnIfDirty(xeniaDir)
+	if flag := command.Flags().Lookup("enterprise-dir"); flag != nil && flag.Value.String() != "" {
+		enterpriseDir := flag.Value.String()
+		if request.EnterpriseCommit, err = gitHead(enterpriseDir); err != nil {
+			if flag.Changed {
+				return err
+			}
+			logger.Debug("Running without the enterprise source code", "dir", enterpriseDir)
+			request.WithoutEnterprise = true
+		} else {
+			warnIfDirty(enterpriseDir)
+		}
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	httpRequest, err := http.NewRequest(http.MethodPost, remoteURL+"/v1/run", strings.NewReader(string(body)))
+	if err != nil {
+		return err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	if token := os.Getenv(remoteTokenEnv); token != "" {
+		httpRequest.Header.Set("Authorization", "Bearer "+token)
+	}
+	logger.Debug("Running remotely", "remote", remoteURL, "args", strings.Join(request.Args, " "), "commit", request.XeniaCommit)
+
+	response, err := http.DefaultClient.Do(httpRequest)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 4096))
+		return fmt.Errorf("Remote server error %s: %s", response.Status, strings.TrimSpace(string(message)))
+	}
+
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var message remoteMessage
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			return fmt.Errorf("Invalid response of the remote server: %s", err.Error())
+		}
+		switch {
+		case message.ExitCode != nil:
+			if message.Error != "" {
+				return &ExitError{Code: *message.ExitCode, Err: errors.New(message.Error)}
+			}
+			if *message.ExitCode != 0 {
+				return &ExitError{Code: *message.ExitCode, Err: fmt.Errorf("Remote command exited with code %d.", *message.ExitCode)}
+			}
+			return nil
+		case message.Stream == "stderr":
+			fmt.Fprint(os.Stderr, message.Data)
+		default:
+			fmt.Fprint(os.Stdout, message.Data)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return errors.New("The remote server closed the connection before the command finished.")
+}
+
+type remoteServer struct {
+	executable     string
+	version        string
+	token          string
+	xeniaRepo      string
+	enterpriseRepo string
+	slots          chan struct{}
+	// gitMutex serializes the worktree operations of each repository.
+	gitMutex sync.Mutex
+}
+
+// checkout creates a temporary worktree of repo at commit, fetching the
+// commit when the repository doesn't have it yet.
+func (s *remoteServer) checkout(repo, commit string) (string, func(), error) {
+	if !commitPattern.MatchString(commit) {
+		return "", nil, fmt.Errorf("invalid commit %q, a full commit hash is expected", commit)
+	}
+	s.gitMutex.Lock()
+	defer s.gitMutex.Unlock()
+
+	if execCommand("git", "-C", repo, "cat-file", "-e", commit+"^{commit}").Run() != nil {
+		if output, err := execCommand("git", "-C", repo, "fetch", "--quiet", "origin", "--", commit).CombinedOutput(); err != nil {
+			return "", nil, fmt.Errorf("unable to fetch %s: %s", commit, strings.TrimSpace(string(output)))
+		}
+	}
+	dir, err := ioutil.TempDir("", "mmgotool-remote-")
+	if err != nil {
+		return "", nil, err
+	}
+	if output, err := execCommand("git", "-C", repo, "worktree", "add", "--detach", "--force", "--", dir, commit).CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, fmt.Errorf("unable to check out %s: %s", commit, strings.TrimSpace(string(output)))
+	}
+	cleanup := func() {
+		s.gitMutex.Lock()
+		defer s.gitMutex.Unlock()
+		execCommand("git", "-C", repo, "worktree", "remove", "--force", dir).Run()
+		os.RemoveAll(dir)
+	}
+	return dir, cleanup, nil
+}
+
+type streamWriter struct {
+	mu      *sync.Mutex
+	stream  string
+	encoder *json.Encoder
+	flusher http.Flusher
+}
+
+func (w *streamWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.encoder.Encode(remoteMessage{Stream: w.stream, Data: string(data)}); err != nil {
+		return 0, err
+	}
+	if w.flusher != nil {
+		w.flusher.Flush()
+	}
+	return len(data), nil
+}
+
+func (s *remoteServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/v1/run" {
+		http.NotFound(w, r)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+s.token)) != 1 {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+	var request remoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkRemoteArgs(request.Args); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusForbidden)
+		return
+	}
+	if request.Version != s.version {
+		http.Error(w, fmt.Sprintf("the server runs mmgotool %s, the client %s", s.version, request.Version), http.StatusConflict)
+		return
+	}
+	if request.EnterpriseCommit != "" && s.enterpriseRepo == "" {
+		http.Error(w, "the server has no enterprise repository", http.StatusBadRequest)
+		return
+	}
+
+	s.slots <- struct{}{}
+	defer func() { <-s.slots }()
+
+	args := append([]string{}, request.Args...)
+	xeniaDir, cleanup, err := s.checkout(s.xeniaRepo, request.XeniaCommit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer cleanup()
+	args = append(args, "--xenia-dir="+xeniaDir, "--jail="+xeniaDir, "--no-network", "--no-exec")
+	if request.EnterpriseCommit != "" {
+		enterpriseDir, cleanup, err := s.checkout(s.enterpriseRepo, request.EnterpriseCommit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer cleanup()
+		args = append(args, "--enterprise-dir="+enterpriseDir, "--jail="+enterpriseDir)
+	} else if request.WithoutEnterprise {
+		emptyDir, err := ioutil.TempDir("", "mmgotool-remote-")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer os.RemoveAll(emptyDir)
+		args = append(args, "--enterprise-dir="+emptyDir, "--jail="+emptyDir)
+	}
+	logger.Info("Running", "args", strings.Join(request.Args, " "), "commit", request.XeniaCommit)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	var mu sync.Mutex
+
+	cmd := execCommand(s.executable, args...)
+	cmd.Dir = xeniaDir
+	cmd.Stdout = &streamWriter{mu: &mu, stream: "stdout", encoder: encoder, flusher: flusher}
+	cmd.Stderr = &streamWriter{mu: &mu, stream: "stderr", encoder: encoder, flusher: flusher}
+	runErr := cmd.Run()
+
+	exitCode := 0
+	message := remoteMessage{ExitCode: &exitCode}
+	if exitErr, ok := runErr.(*exec.ExitError); ok {
+		exitCode = exitErr.ExitCode()
+	} else if runErr != nil {
+		exitCode = 1
+		message.Error = runErr.Error()
+	}
+	mu.Lock()
+	encoder.Encode(message)
+	mu.Unlock()
+}
+
+func remoteServeCmdF(command *cobra.Command, args []string) error {
+	listen, err := command.Flags().GetString("listen")
+	if err != nil {
+		return errors.New("Invalid listen parameter")
+	}
+	xeniaRepo, err := command.Flags().GetString("xenia-repo")
+	if err != nil {
+		return errors.New("Invalid xenia-repo parameter")
+	}
+	enterpriseRepo, err := command.Flags().GetString("enterprise-repo")
+	if err != nil {
+		return errors.New("Invalid enterprise-repo parameter")
+	}
+	jobs, err := command.Flags().GetInt("jobs")
+	if err != nil {
+		return errors.New("Invalid jobs parameter")
+	}
+	token, err := command.Flags().GetString("token")
+	if err != nil {
+		return errors.New("Invalid token parameter")
+	}
+	if token == "" {
+		token = os.Getenv(remoteTokenEnv)
+	}
+	if token == "" {
+		return errors.New("A token is required, set the token parameter or $" + remoteTokenEnv)
+	}
+	if xeniaRepo == "" {
+		return errors.New("The xenia-repo parameter is required")
+	}
+	if jobs < 1 {
+		return errors.New("Invalid jobs parameter, at least one job is required")
+	}
+	command.SilenceUsage = true
+
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	version, err := toolVersion()
+	if err != nil {
+		return err
+	}
+	server := &remoteServer{
+		executable:     executable,
+		version:        version,
+		token:          token,
+		xeniaRepo:      xeniaRepo,
+		enterpriseRepo: enterpriseRepo,
+		slots:          make(chan struct{}, jobs),
+	}
+	if err := sandbox.checkNetwork(); err != nil {
+		return err
+	}
+	logger.Info("Listening", "address", listen, "version", version)
+	return http.ListenAndServe(listen, server)
+}
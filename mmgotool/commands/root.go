@@ -4,18 +4,89 @@
 package commands
 
 import (
+	"errors"
+	"time"
+
 	"github.com/spf13/cobra"
 )
 
 type Command = cobra.Command
 
+// ExitError is returned by the commands that document specific exit codes.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+// ExitCode returns the process exit code matching the error returned by Run.
+func ExitCode(err error) int {
+	if exitErr, ok := err.(*ExitError); ok {
+		return exitErr.Code
+	}
+	return 1
+}
+
 func Run(args []string) error {
+	args, usedLegacyCommand = rewriteLegacyArgs(args)
 	RootCmd.SetArgs(args)
-	return RootCmd.Execute()
+	start := time.Now()
+	command, err := RootCmd.ExecuteC()
+	stopProfiling()
+	recordUsage(command, time.Since(start), err)
+	writeRunSummary(command, time.Since(start), err)
+	if err != nil {
+		logger.ReportError(err)
+	}
+	return err
 }
 
 var RootCmd = &cobra.Command{
 	Use:   "mmdev",
 	Short: "Xenia dev utils cli",
-	Long:  `Xenia cli to help in the development process`,
+	Long: `Xenia cli to help in the development process.
+The commands are grouped by purpose: i18n, codegen, lint, release and dev. Run with --cheatsheet to list the common workflows.
+Every flag can also be set with an MMGOTOOL_<FLAG> environment variable, like MMGOTOOL_XENIA_DIR. The flags describing the layout of the source code, like --xenia-dir or --exclude, can also be set in the flags section of the closest .mmgotool.yaml file, the ones accessing the network or writing files can't. The command line takes precedence over the environment, which takes precedence over the file.`,
+	RunE:              rootCmdF,
+	PersistentPreRunE: rootPreRunE,
+}
+
+// rootPreRunE applies the sandbox flags, fills the flags from the
+// environment and the configuration file, configures the logger and sends
+// the command to the remote server when --remote is used.
+func rootPreRunE(command *cobra.Command, args []string) error {
+	if err := configureSandbox(command); err != nil {
+		return err
+	}
+	if err := applyFlagDefaults(command); err != nil {
+		command.SilenceUsage = true
+		return err
+	}
+	if err := configureSandbox(command); err != nil {
+		return err
+	}
+	if err := configureLogger(command, args); err != nil {
+		return err
+	}
+	if err := configureCatalogFormat(command); err != nil {
+		return err
+	}
+	if err := configureProfiling(command); err != nil {
+		return err
+	}
+	remote, err := command.Flags().GetString("remote")
+	if err != nil {
+		return errors.New("Invalid remote parameter")
+	}
+	if remote != "" {
+		return dispatchRemote(command, remote)
+	}
+	return nil
+}
+
+func init() {
+	RootCmd.Flags().Bool("cheatsheet", false, "List the commands of the common workflows")
 }
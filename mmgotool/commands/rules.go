@@ -0,0 +1,113 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+)
+
+// findingRules are the rules of the check commands, registered with
+// registerFindingRule.
+var findingRules = []findingRule{}
+
+// registerFindingRule adds a rule to the ones listed by rules list, an error
+// unless its severity is set.
+func registerFindingRule(rule findingRule) findingRule {
+	if rule.Severity == "" {
+		rule.Severity = severityError
+	}
+	findingRules = append(findingRules, rule)
+	return rule
+}
+
+var RulesCmd = &cobra.Command{
+	Use:   "rules",
+	Short: "Documentation of the check rules",
+}
+
+var RulesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the rules of the check commands",
+	Long: `List every rule the check commands report findings for, with the ids of their SARIF results, the command checking them, their default severity and the command fixing them when there is one.
+
+The json format is meant for the tools rendering the rules, like a developer portal linking the findings to their documentation.`,
+	Example: `  rules list
+  rules list --format json`,
+	Args: cobra.NoArgs,
+	RunE: rulesListCmdF,
+}
+
+func init() {
+	RulesListCmd.Flags().String("format", "text", "Output format: text or json")
+	RulesCmd.AddCommand(RulesListCmd)
+	RootCmd.AddCommand(RulesCmd)
+}
+
+// ruleDoc is a rule in the json format of rules list.
+type ruleDoc struct {
+	Id          string `json:"id"`
+	Description string `json:"description"`
+	Command     string `json:"command"`
+	Severity    string `json:"severity"`
+	Autofix     bool   `json:"autofix"`
+	FixCommand  string `json:"fix_command,omitempty"`
+}
+
+// sortedFindingRules returns the registered rules sorted by id.
+func sortedFindingRules() []findingRule {
+	rules := append([]findingRule{}, findingRules...)
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Id < rules[j].Id })
+	return rules
+}
+
+func rulesListCmdF(command *cobra.Command, args []string) error {
+	format, err := command.Flags().GetString("format")
+	if err != nil {
+		return errors.New("Invalid format parameter")
+	}
+	if format != "text" && format != "json" {
+		return fmt.Errorf("Unknown format %s", format)
+	}
+
+	rules := sortedFindingRules()
+	if format == "json" {
+		docs := []ruleDoc{}
+		for _, rule := range rules {
+			docs = append(docs, ruleDoc{
+				Id:          rule.Id,
+				Description: rule.Description,
+				Command:     binaryName + " " + rule.Command,
+				Severity:    rule.Severity,
+				Autofix:     rule.Fix != "",
+				FixCommand:  rule.fixCommand(),
+			})
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(docs)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "RULE\tSEVERITY\tCOMMAND\tAUTOFIX\tDESCRIPTION")
+	for _, rule := range rules {
+		fix := "-"
+		if rule.Fix != "" {
+			fix = rule.fixCommand()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", rule.Id, rule.Severity, binaryName+" "+rule.Command, fix, rule.Description)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,262 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	stepPassed  = "PASS"
+	stepFailed  = "FAIL"
+	stepSkipped = "SKIP"
+)
+
+var RunScriptCmd = &cobra.Command{
+	Use:   "run-script <plan.yaml>",
+	Short: "Run the steps of a plan file",
+	Long: `Run, one after the other, the mmgotool commands listed in a plan file and report their results together, so the release automation keeps one reviewed plan instead of shell scripts.
+
+The flags section is shared by every step, like the MMGOTOOL_* environment variables: the steps use the same xenia-dir, so the same extraction cache, and the same cache-url. A flag is only used by the steps whose command defines it, the args of a step take precedence. The steps run from the folder of the plan file and the plan stops at the first failing step, unless it has continue_on_error.
+
+  flags:
+    xenia-dir: ../xenia-server
+    enterprise-dir: ../enterprise
+    cache-url: https://ci-cache.example.com/mmgotool
+  steps:
+    - name: extract
+      args: [i18n, extract]
+    - name: license
+      args: [lint, license, check]
+      continue_on_error: true
+    - name: pack
+      args: [i18n, pack, --output-dir, dist/i18n]
+    - name: report
+      args: [i18n, usage, --max, "0", --format, json]`,
+	Example: `  run-script release-plan.yaml
+  run-script release-plan.yaml --format json > report.json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runScriptCmdF,
+}
+
+func init() {
+	RunScriptCmd.Flags().String("format", "text", "Output format of the report: text or json")
+	RunScriptCmd.Flags().Bool("dry-run", false, "Print the commands of the steps without running them")
+	RootCmd.AddCommand(RunScriptCmd)
+}
+
+type scriptPlan struct {
+	// Flags are the flags of every step, by flag name.
+	Flags map[string]interface{} `yaml:"flags"`
+	Steps []scriptStep           `yaml:"steps"`
+}
+
+type scriptStep struct {
+	Name string `yaml:"name"`
+	// Args are the mmgotool arguments of the step.
+	Args []string `yaml:"args"`
+	// ContinueOnError runs the next steps when the step fails.
+	ContinueOnError bool `yaml:"continue_on_error"`
+}
+
+type stepResult struct {
+	Name     string   `json:"name"`
+	Args     []string `json:"args"`
+	Status   string   `json:"status"`
+	Duration float64  `json:"duration_seconds"`
+	ExitCode int      `json:"exit_code"`
+	Output   string   `json:"output,omitempty"`
+}
+
+func readScriptPlan(planFile string) (*scriptPlan, error) {
+	data, err := readFile(planFile)
+	if err != nil {
+		return nil, err
+	}
+	plan := &scriptPlan{}
+	if err := yaml.UnmarshalStrict(data, plan); err != nil {
+		return nil, fmt.Errorf("Unable to parse %s: %s", planFile, err.Error())
+	}
+	if len(plan.Steps) == 0 {
+		return nil, fmt.Errorf("%s has no steps.", planFile)
+	}
+	names := map[string]bool{}
+	for i, step := range plan.Steps {
+		if step.Name == "" {
+			return nil, fmt.Errorf("Step %d of %s has no name.", i+1, planFile)
+		}
+		if names[step.Name] {
+			return nil, fmt.Errorf("Step %s of %s is declared twice.", step.Name, planFile)
+		}
+		names[step.Name] = true
+		if len(step.Args) == 0 {
+			return nil, fmt.Errorf("Step %s of %s has no args.", step.Name, planFile)
+		}
+		if step.Args[0] == "run-script" {
+			return nil, fmt.Errorf("Step %s of %s runs another plan.", step.Name, planFile)
+		}
+	}
+	if err := checkConfigFlags(RootCmd, plan.Flags); err != nil {
+		return nil, fmt.Errorf("Invalid flags in %s: %s", planFile, err.Error())
+	}
+	return plan, nil
+}
+
+// stepEnvironment returns the environment of the steps, the flags of the
+// plan set with their MMGOTOOL_* variables.
+func stepEnvironment(flags map[string]interface{}) []string {
+	names := []string{}
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	env := os.Environ()
+	for _, name := range names {
+		value := fmt.Sprint(flags[name])
+		if list, ok := flags[name].([]interface{}); ok {
+			values := []string{}
+			for _, item := range list {
+				values = append(values, fmt.Sprint(item))
+			}
+			value = strings.Join(values, ",")
+		}
+		env = append(env, flagEnvName(name)+"="+value)
+	}
+	return env
+}
+
+// runScriptStep runs a step and returns its result, the output of the
+// command being captured for the report.
+func runScriptStep(executable, dir string, env []string, step scriptStep) stepResult {
+	result := stepResult{Name: step.Name, Args: step.Args, Status: stepPassed}
+	start := time.Now()
+	cmd := execSelf(executable, step.Args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	output, err := cmd.CombinedOutput()
+	result.Duration = time.Since(start).Seconds()
+	result.Output = string(output)
+	if err != nil {
+		result.Status = stepFailed
+		result.ExitCode = 1
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			result.ExitCode = exitErr.ExitCode()
+		} else {
+			result.Output += err.Error() + "\n"
+		}
+	}
+	return result
+}
+
+// runScriptPlan runs the steps in order, the ones after a failing step
+// without continue_on_error being skipped.
+func runScriptPlan(plan *scriptPlan, dir string) ([]stepResult, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	env := stepEnvironment(plan.Flags)
+
+	results := []stepResult{}
+	stopped := false
+	for _, step := range plan.Steps {
+		if stopped {
+			results = append(results, stepResult{Name: step.Name, Args: step.Args, Status: stepSkipped})
+			continue
+		}
+		logger.Info("Running step", "step", step.Name, "args", strings.Join(step.Args, " "))
+		result := runScriptStep(executable, dir, env, step)
+		if result.Status == stepFailed && !step.ContinueOnError {
+			stopped = true
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func runScriptCmdF(command *cobra.Command, args []string) error {
+	format, err := command.Flags().GetString("format")
+	if err != nil {
+		return errors.New("Invalid format parameter")
+	}
+	if format != "text" && format != "json" {
+		return fmt.Errorf("Unknown format %s", format)
+	}
+	dryRun, err := command.Flags().GetBool("dry-run")
+	if err != nil {
+		return errors.New("Invalid dry-run parameter")
+	}
+	command.SilenceUsage = true
+
+	plan, err := readScriptPlan(args[0])
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(args[0])
+
+	if dryRun {
+		for _, step := range plan.Steps {
+			fmt.Printf("%s: mmgotool %s\n", step.Name, strings.Join(step.Args, " "))
+		}
+		return nil
+	}
+
+	results, err := runScriptPlan(plan, dir)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	rows := [][]string{}
+	for _, result := range results {
+		if result.Status == stepFailed {
+			failed++
+		}
+		duration := time.Duration(result.Duration * float64(time.Second)).Round(time.Millisecond)
+		rows = append(rows, []string{result.Status, result.Name, duration.String(), strings.Join(result.Args, " ")})
+	}
+	stepSummary.AddTable("Steps", []string{"Status", "Step", "Duration", "Command"}, rows)
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			return err
+		}
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "STATUS\tSTEP\tDURATION\tCOMMAND")
+		for _, row := range rows {
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		for _, result := range results {
+			if result.Status != stepFailed {
+				continue
+			}
+			fmt.Printf("\n--- %s: mmgotool %s\n", result.Name, strings.Join(result.Args, " "))
+			fmt.Print(result.Output)
+		}
+	}
+
+	if failed > 0 {
+		return &ExitError{Code: 1, Err: fmt.Errorf("%d of %d steps failed.", failed, len(results))}
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/xzl8028/xenia-utilities/mmgotool/internal/codegen"
+)
+
+var (
+	errExecDisabled    = errors.New("Running external programs is disabled by --no-exec.")
+	errNetworkDisabled = errors.New("Network access is disabled by --no-network.")
+)
+
+// sandboxPolicy restricts the tool on untrusted source trees, like the
+// branches of forked pull requests, set with --no-exec, --no-network and
+// --jail.
+type sandboxPolicy struct {
+	// noExec refuses to run external programs: git runs the fsmonitor and
+	// the filters the repository configures, go the toolexec of its flags.
+	noExec    bool
+	noNetwork bool
+	// roots are the folders, absolute with their symlinks resolved, the
+	// files read and written must be in. Empty without --jail.
+	roots []string
+}
+
+var sandbox = &sandboxPolicy{}
+
+func init() {
+	RootCmd.PersistentFlags().Bool("no-exec", false, "Never run external programs like git or go, which can run the hooks and tools configured by the source tree")
+	RootCmd.PersistentFlags().Bool("no-network", false, "Never access the network")
+	RootCmd.PersistentFlags().StringArray("jail", []string{}, "Only read and write files in this folder, can be repeated")
+}
+
+// deniedTransport replaces the HTTP transport with --no-network.
+type deniedTransport struct{}
+
+func (deniedTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	return nil, fmt.Errorf("%s: %s", request.URL.Host, errNetworkDisabled.Error())
+}
+
+// configureSandbox applies the sandbox flags. It runs before and after the
+// flags are read from the environment and the configuration file, which can
+// add restrictions but not lift the ones of the command line.
+func configureSandbox(command *cobra.Command) error {
+	noExec, err := command.Flags().GetBool("no-exec")
+	if err != nil {
+		return errors.New("Invalid no-exec parameter")
+	}
+	noNetwork, err := command.Flags().GetBool("no-network")
+	if err != nil {
+		return errors.New("Invalid no-network parameter")
+	}
+	jail, err := command.Flags().GetStringArray("jail")
+	if err != nil {
+		return errors.New("Invalid jail parameter")
+	}
+
+	sandbox.noExec = sandbox.noExec || noExec
+	if noNetwork && !sandbox.noNetwork {
+		sandbox.noNetwork = true
+		http.DefaultTransport = deniedTransport{}
+	}
+	if len(sandbox.roots) == 0 {
+		for _, dir := range jail {
+			root, err := filepath.EvalSymlinks(dir)
+			if err != nil {
+				return fmt.Errorf("Invalid jail folder %s: %s", dir, err.Error())
+			}
+			if root, err = filepath.Abs(root); err != nil {
+				return err
+			}
+			sandbox.roots = append(sandbox.roots, root)
+		}
+	}
+	return nil
+}
+
+// execCommand returns the command running an external program, failing to
+// start with --no-exec.
+func execCommand(name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	if sandbox.noExec {
+		cmd.Err = errExecDisabled
+	}
+	return cmd
+}
+
+// execSelf returns the command running another mmgotool command line with
+// the sandbox and the logging of the running one. It starts with --no-exec,
+// the flag being passed on.
+func execSelf(executable string, args ...string) *exec.Cmd {
+	return exec.Command(executable, append(sandbox.flags(), append(logger.flags(), args...)...)...)
+}
+
+// flags returns the command line flags restricting another command like
+// this sandbox, in the --name=value form.
+func (s *sandboxPolicy) flags() []string {
+	flags := []string{}
+	if s.noExec {
+		flags = append(flags, "--no-exec=true")
+	}
+	if s.noNetwork {
+		flags = append(flags, "--no-network=true")
+	}
+	for _, root := range s.roots {
+		flags = append(flags, "--jail="+root)
+	}
+	return flags
+}
+
+// checkNetwork fails with --no-network, for the network accesses not made
+// with the HTTP client.
+func (s *sandboxPolicy) checkNetwork() error {
+	if s.noNetwork {
+		return errNetworkDisabled
+	}
+	return nil
+}
+
+// resolve returns the absolute path of p with its symlinks resolved, the
+// ones of its folder for a file not created yet.
+func resolve(p string) (string, error) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved, nil
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Dir(abs))
+	if err != nil {
+		return abs, nil
+	}
+	return filepath.Join(dir, filepath.Base(abs)), nil
+}
+
+// allows tells whether the path is in one of the roots of --jail.
+func (s *sandboxPolicy) allows(p string) bool {
+	if len(s.roots) == 0 {
+		return true
+	}
+	resolved, err := resolve(p)
+	if err != nil {
+		return false
+	}
+	for _, root := range s.roots {
+		if rel, err := filepath.Rel(root, resolved); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPath fails for the paths out of the roots of --jail, symlinks
+// pointing out of them included.
+func (s *sandboxPolicy) checkPath(p string) error {
+	if !s.allows(p) {
+		return fmt.Errorf("%s is out of the --jail folders.", p)
+	}
+	return nil
+}
+
+// The file helpers below check the path against the --jail folders before
+// reading or writing it. The commands access every file through them, the
+// temporary folders they create themselves aside.
+
+func readFile(p string) ([]byte, error) {
+	if err := sandbox.checkPath(p); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(p)
+}
+
+func writeFile(p string, data []byte, perm os.FileMode) error {
+	if err := sandbox.checkPath(p); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(p, data, perm)
+}
+
+func openFile(p string) (*os.File, error) {
+	if err := sandbox.checkPath(p); err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func createFile(p string) (*os.File, error) {
+	if err := sandbox.checkPath(p); err != nil {
+		return nil, err
+	}
+	return os.Create(p)
+}
+
+// appendFile opens the file for appending, creating it when missing.
+func appendFile(p string, perm os.FileMode) (*os.File, error) {
+	if err := sandbox.checkPath(p); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(p, os.O_APPEND|os.O_CREATE|os.O_WRONLY, perm)
+}
+
+func removeFile(p string) error {
+	if err := sandbox.checkPath(p); err != nil {
+		return err
+	}
+	return os.Remove(p)
+}
+
+func readDir(p string) ([]os.FileInfo, error) {
+	if err := sandbox.checkPath(p); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadDir(p)
+}
+
+func mkdirAll(p string, perm os.FileMode) error {
+	if err := sandbox.checkPath(p); err != nil {
+		return err
+	}
+	return os.MkdirAll(p, perm)
+}
+
+// matchesFile reports whether the file has the expected content, like
+// codegen.MatchesFile.
+func matchesFile(p string, expected []byte) (bool, error) {
+	if err := sandbox.checkPath(p); err != nil {
+		return false, err
+	}
+	return codegen.MatchesFile(p, expected)
+}
+
+// walk walks the folder like filepath.Walk, which doesn't follow the
+// symlinks under it. The files walked are read with readFile.
+func walk(root string, fn filepath.WalkFunc) error {
+	if err := sandbox.checkPath(root); err != nil {
+		return err
+	}
+	return filepath.Walk(root, fn)
+}
+
+// parseDir parses the Go files of the folder passing the filter, like
+// parser.ParseDir.
+func parseDir(fset *token.FileSet, dir string, filter func(os.FileInfo) bool, mode parser.Mode) (map[string]*ast.Package, error) {
+	entries, err := readDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	pkgs := map[string]*ast.Package{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") || (filter != nil && !filter(entry)) {
+			continue
+		}
+		filename := filepath.Join(dir, entry.Name())
+		src, err := readFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		f, err := parser.ParseFile(fset, filename, src, mode)
+		if err != nil {
+			return nil, err
+		}
+		pkg, ok := pkgs[f.Name.Name]
+		if !ok {
+			pkg = &ast.Package{Name: f.Name.Name, Files: map[string]*ast.File{}}
+			pkgs[f.Name.Name] = pkg
+		}
+		pkg.Files[filename] = f
+	}
+	return pkgs, nil
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSandboxJail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mmgotool-sandbox-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	jail := filepath.Join(dir, "jail")
+	outside := filepath.Join(dir, "outside")
+	writeTestFile(t, filepath.Join(jail, "app", "app.go"), "package app\n")
+	writeTestFile(t, filepath.Join(outside, "secret.txt"), "secret\n")
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(jail, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(jail, "linkdir")); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(previous *sandboxPolicy) { sandbox = previous }(sandbox)
+	sandbox = &sandboxPolicy{}
+	root, err := filepath.EvalSymlinks(jail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sandbox.roots = []string{root}
+
+	tests := []struct {
+		name    string
+		access  func() error
+		allowed bool
+	}{
+		{"read in the jail", func() error { _, err := readFile(filepath.Join(jail, "app", "app.go")); return err }, true},
+		{"write in the jail", func() error { return writeFile(filepath.Join(jail, "new.txt"), []byte("new\n"), 0644) }, true},
+		{"create a folder in the jail", func() error { return mkdirAll(filepath.Join(jail, "a", "b"), 0755) }, true},
+		{"read out of the jail", func() error { _, err := readFile(filepath.Join(outside, "secret.txt")); return err }, false},
+		{"read through ..", func() error { _, err := readFile(filepath.Join(jail, "..", "outside", "secret.txt")); return err }, false},
+		{"read a symlink out of the jail", func() error { _, err := readFile(filepath.Join(jail, "link.txt")); return err }, false},
+		{"read in a symlinked folder", func() error { _, err := readFile(filepath.Join(jail, "linkdir", "secret.txt")); return err }, false},
+		{"write out of the jail", func() error { return writeFile(filepath.Join(outside, "new.txt"), []byte("new\n"), 0644) }, false},
+		{"append out of the jail", func() error { _, err := appendFile(filepath.Join(outside, "summary.md"), 0644); return err }, false},
+		{"open out of the jail", func() error { _, err := openFile(filepath.Join(outside, "secret.txt")); return err }, false},
+		{"list out of the jail", func() error { _, err := readDir(outside); return err }, false},
+		{"remove out of the jail", func() error { return removeFile(filepath.Join(outside, "secret.txt")) }, false},
+		{"walk out of the jail", func() error { return walk(outside, func(string, os.FileInfo, error) error { return nil }) }, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.access()
+			if test.allowed && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !test.allowed && (err == nil || !strings.Contains(err.Error(), "out of the --jail folders")) {
+				t.Fatalf("got error %v, want a jail error", err)
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(outside, "secret.txt")); err != nil {
+		t.Errorf("the file out of the jail was removed: %s", err)
+	}
+}
@@ -0,0 +1,405 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// secretsAllowlistFile is the default allowlist of secrets scan, relative to
+// the Xenia folder.
+const secretsAllowlistFile = ".secrets-allowlist"
+
+var (
+	secretFormatRule    = registerFindingRule(findingRule{Id: "secrets/known-format", Description: "The string literal has the format of a credential, like an AWS access key or a private key", Command: "secrets scan"})
+	secretNameRule      = registerFindingRule(findingRule{Id: "secrets/sensitive-name", Description: "The string literal is assigned to a password, secret, token or key", Command: "secrets scan"})
+	secretEntropyRule   = registerFindingRule(findingRule{Id: "secrets/high-entropy", Description: "The string literal is random enough to be a generated key or token", Command: "secrets scan", Severity: severityWarning})
+	sensitiveNameRegexp = regexp.MustCompile(`(?i)(passw(or)?d|passwd|pwd|secret|token|api_?key|access_?key|private_?key|credential|auth_?key|signing_?key)`)
+	// translationIdRegexp matches the translation ids and the dotted
+	// names, like api.user.password.invalid, which aren't secrets.
+	translationIdRegexp = regexp.MustCompile(`^[a-z0-9_]+(\.[a-z0-9_]+)+$`)
+	base64Regexp        = regexp.MustCompile(`^[A-Za-z0-9+/_=-]+$`)
+)
+
+// secretFormats are the formats of the credentials issued by well known
+// services.
+var secretFormats = []struct {
+	Name    string
+	Pattern *regexp.Regexp
+}{
+	{"AWS access key", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"GitHub token", regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"Stripe secret key", regexp.MustCompile(`\b[sr]k_live_[0-9A-Za-z]{24,}\b`)},
+	{"private key", regexp.MustCompile(`-----BEGIN ([A-Z]+ )?PRIVATE KEY-----`)},
+	{"JSON web token", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	{"URL with credentials", regexp.MustCompile(`\b[a-z][a-z0-9+.-]*://[^/\s:@]+:[^/\s:@$%{]+@`)},
+}
+
+var SecretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Hard-coded credentials detection",
+}
+
+var SecretsScanCmd = &cobra.Command{
+	Use:   "scan",
+	Short: "Find the credentials hard-coded in the Go sources",
+	Long: `Scan the string literals of the Go sources for hard-coded credentials, walking the source folders like i18n extract:
+
+  - secrets/known-format: the literals with the format of a credential issued by a well known service, like AWS access keys, GitHub and Slack tokens or private keys.
+  - secrets/sensitive-name: the literals assigned to a variable, a constant, a field or a map key named like a password, a secret, a token or a key.
+  - secrets/high-entropy: the long literals of base64 characters random enough to be generated keys. These are warnings, which don't fail the command.
+
+The secrets are never printed in full, only their first characters and their fingerprint. The allowlist file, .secrets-allowlist of the xenia-dir by default, lists the false positives, one per line: a fingerprint like "fingerprint:3f2a9c0d1e4b", or a glob of the files to skip, relative to the source folder, like "app/testdata/*". Lines starting with # are comments.`,
+	Example: `  secrets scan --xenia-dir ../xenia-server --include-tests
+  secrets scan --allowlist ./ci/secrets-allowlist --format sarif > secrets.sarif`,
+	Args: cobra.NoArgs,
+	RunE: secretsScanCmdF,
+}
+
+func init() {
+	addExtractFlags(SecretsScanCmd)
+	SecretsScanCmd.Flags().String("allowlist", "", "File of the fingerprints and files to skip, defaults to "+secretsAllowlistFile+" of the xenia-dir when it exists")
+	SecretsScanCmd.Flags().Float64("min-entropy", 4.5, "Minimum Shannon entropy, in bits per character, of the high entropy literals")
+	SecretsScanCmd.Flags().Int("min-length", 20, "Minimum length of the high entropy literals")
+	SecretsScanCmd.Flags().String("format", findingsFormatText, "Output format: text, json or sarif")
+	SecretsCmd.AddCommand(SecretsScanCmd)
+	RootCmd.AddCommand(SecretsCmd)
+}
+
+// secretsAllowlist are the fingerprints and the file globs of the false
+// positives.
+type secretsAllowlist struct {
+	fingerprints map[string]bool
+	files        []string
+}
+
+func readSecretsAllowlist(file string) (*secretsAllowlist, error) {
+	allowlist := &secretsAllowlist{fingerprints: map[string]bool{}}
+	if file == "" {
+		return allowlist, nil
+	}
+	f, err := openFile(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "fingerprint:") {
+			allowlist.fingerprints[strings.TrimPrefix(line, "fingerprint:")] = true
+			continue
+		}
+		if _, err := path.Match(line, ""); err != nil {
+			return nil, fmt.Errorf("Invalid pattern %s in %s.", line, file)
+		}
+		allowlist.files = append(allowlist.files, line)
+	}
+	return allowlist, scanner.Err()
+}
+
+// skipsFile tells whether the file, relative to its source folder, is
+// allowlisted.
+func (a *secretsAllowlist) skipsFile(rel string) bool {
+	for _, pattern := range a.files {
+		if matched, _ := path.Match(pattern, rel); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// secretCandidate is a string literal looking like a credential.
+type secretCandidate struct {
+	Rule     findingRule `json:"-"`
+	RuleId   string      `json:"rule"`
+	File     string      `json:"file"`
+	Line     int         `json:"line"`
+	Column   int         `json:"column"`
+	Kind     string      `json:"kind"`
+	Name     string      `json:"name,omitempty"`
+	Redacted string      `json:"redacted"`
+	// Fingerprint identifies the secret in the allowlist without
+	// disclosing it.
+	Fingerprint string `json:"fingerprint"`
+}
+
+func (s secretCandidate) finding() finding {
+	message := fmt.Sprintf("Possible %s %s", s.Kind, s.Redacted)
+	if s.Name != "" {
+		message = fmt.Sprintf("Possible %s %s in %s", s.Kind, s.Redacted, s.Name)
+	}
+	message += fmt.Sprintf(" (fingerprint:%s)", s.Fingerprint)
+	return finding{Rule: s.Rule, Message: message, File: s.File, Line: s.Line, Column: s.Column}
+}
+
+// secretFingerprint returns the first characters of the SHA-256 of the
+// secret.
+func secretFingerprint(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// redactSecret returns the first characters of the secret, masking the
+// others.
+func redactSecret(secret string) string {
+	runes := []rune(secret)
+	shown := 4
+	if len(runes) <= 8 {
+		shown = 1
+	}
+	return string(runes[:shown]) + strings.Repeat("*", minInt(len(runes)-shown, 8))
+}
+
+// shannonEntropy returns the entropy of the text in bits per character.
+func shannonEntropy(text string) float64 {
+	counts := map[rune]int{}
+	total := 0
+	for _, r := range text {
+		counts[r]++
+		total++
+	}
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// literalNames returns the names the string literals of the file are
+// assigned to: variables, constants, struct fields and map keys.
+func literalNames(f *ast.File) map[*ast.BasicLit]string {
+	names := map[*ast.BasicLit]string{}
+	exprName := func(expr ast.Expr) string {
+		switch e := expr.(type) {
+		case *ast.Ident:
+			return e.Name
+		case *ast.SelectorExpr:
+			return e.Sel.Name
+		case *ast.IndexExpr:
+			if key, ok := stringLiteral(e.Index); ok {
+				return key
+			}
+		}
+		if key, ok := stringLiteral(expr); ok {
+			return key
+		}
+		return ""
+	}
+	assign := func(name string, value ast.Expr) {
+		if lit, ok := value.(*ast.BasicLit); ok && lit.Kind == token.STRING && name != "" {
+			names[lit] = name
+		}
+	}
+	ast.Inspect(f, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.AssignStmt:
+			if len(n.Lhs) == len(n.Rhs) {
+				for i := range n.Lhs {
+					assign(exprName(n.Lhs[i]), n.Rhs[i])
+				}
+			}
+		case *ast.ValueSpec:
+			if len(n.Names) == len(n.Values) {
+				for i := range n.Names {
+					assign(n.Names[i].Name, n.Values[i])
+				}
+			}
+		case *ast.KeyValueExpr:
+			assign(exprName(n.Key), n.Value)
+		case *ast.BinaryExpr:
+			// Comparisons like password == "hunter2".
+			if n.Op == token.EQL || n.Op == token.NEQ {
+				assign(exprName(n.X), n.Y)
+			}
+		}
+		return true
+	})
+	return names
+}
+
+// placeholderSecret tells whether the value assigned to a sensitive name
+// is not a credential: a message, a template, an identifier or the name
+// itself, like TokenTypePasswordRecovery = "password_recovery".
+func placeholderSecret(name, value string) bool {
+	if len(value) < 6 || strings.ContainsAny(value, " \t\n") || translationIdRegexp.MatchString(value) {
+		return true
+	}
+	if strings.Contains(value, "{{") || strings.Contains(value, "${") || strings.Contains(value, "%") {
+		return true
+	}
+	normalize := func(s string) string {
+		return strings.ToLower(strings.NewReplacer("_", "", "-", "", ".", "", " ", "").Replace(s))
+	}
+	if strings.Contains(normalize(name), normalize(value)) {
+		return true
+	}
+	// Values made of a single repeated character, like "********".
+	return strings.Count(value, value[:1]) == len(value)
+}
+
+// scanSecrets returns the string literals of the Go file looking like
+// credentials.
+func scanSecrets(filePath string, src []byte, minEntropy float64, minLength int) ([]secretCandidate, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filePath, src, 0)
+	if err != nil {
+		return nil, err
+	}
+	names := literalNames(f)
+	candidates := []secretCandidate{}
+	ast.Inspect(f, func(node ast.Node) bool {
+		lit, ok := node.(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return true
+		}
+		value, ok := stringLiteral(lit)
+		if !ok || value == "" {
+			return true
+		}
+		position := fset.Position(lit.Pos())
+		candidate := secretCandidate{File: findingPath(position.Filename), Line: position.Line, Column: position.Column, Name: names[lit]}
+
+		secret := ""
+		for _, format := range secretFormats {
+			if match := format.Pattern.FindString(value); match != "" {
+				candidate.Rule, candidate.Kind, secret = secretFormatRule, format.Name, match
+				break
+			}
+		}
+		if secret == "" && candidate.Name != "" && sensitiveNameRegexp.MatchString(candidate.Name) && !placeholderSecret(candidate.Name, value) {
+			candidate.Rule, candidate.Kind, secret = secretNameRule, "hard-coded credential", value
+		}
+		if secret == "" && len(value) >= minLength && base64Regexp.MatchString(value) && strings.ContainsAny(value, "0123456789") && shannonEntropy(value) >= minEntropy {
+			candidate.Rule, candidate.Kind, secret = secretEntropyRule, "generated key", value
+		}
+		if secret == "" {
+			return true
+		}
+		candidate.RuleId = candidate.Rule.Id
+		candidate.Redacted = redactSecret(secret)
+		candidate.Fingerprint = secretFingerprint(secret)
+		candidates = append(candidates, candidate)
+		return true
+	})
+	return candidates, nil
+}
+
+func secretsScanCmdF(command *cobra.Command, args []string) error {
+	opts, err := getExtractOptions(command)
+	if err != nil {
+		return err
+	}
+	allowlistFile, err := command.Flags().GetString("allowlist")
+	if err != nil {
+		return errors.New("Invalid allowlist parameter")
+	}
+	minEntropy, err := command.Flags().GetFloat64("min-entropy")
+	if err != nil {
+		return errors.New("Invalid min-entropy parameter")
+	}
+	minLength, err := command.Flags().GetInt("min-length")
+	if err != nil {
+		return errors.New("Invalid min-length parameter")
+	}
+	format, err := command.Flags().GetString("format")
+	if err != nil {
+		return errors.New("Invalid format parameter")
+	}
+	if format != findingsFormatText && format != findingsFormatSARIF && format != "json" {
+		return fmt.Errorf("Unknown format %s", format)
+	}
+	if allowlistFile == "" {
+		if _, err := os.Stat(filepath.Join(opts.XeniaDir, secretsAllowlistFile)); err == nil {
+			allowlistFile = filepath.Join(opts.XeniaDir, secretsAllowlistFile)
+		}
+	}
+	command.SilenceUsage = true
+
+	allowlist, err := readSecretsAllowlist(allowlistFile)
+	if err != nil {
+		return err
+	}
+
+	secrets := []secretCandidate{}
+	var walkErr error
+	walkSourceFiles(opts, func(p string) {
+		if walkErr != nil || !strings.HasSuffix(p, ".go") {
+			return
+		}
+		for _, dir := range opts.SourceDirs() {
+			if rel, err := filepath.Rel(dir, p); err == nil && !strings.HasPrefix(rel, "..") && allowlist.skipsFile(filepath.ToSlash(rel)) {
+				logger.Debug("Skipping allowlisted file", "path", p)
+				return
+			}
+		}
+		src, err := readFile(p)
+		if err != nil {
+			walkErr = err
+			return
+		}
+		candidates, err := scanSecrets(p, src, minEntropy, minLength)
+		if err != nil {
+			logger.Warn("Unable to parse the file", "path", p, "error", err)
+			return
+		}
+		for _, candidate := range candidates {
+			if !allowlist.fingerprints[candidate.Fingerprint] {
+				secrets = append(secrets, candidate)
+			}
+		}
+	})
+	if walkErr != nil {
+		return walkErr
+	}
+
+	failures := 0
+	for _, secret := range secrets {
+		if secret.Rule.Severity != severityWarning {
+			failures++
+		}
+	}
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(secrets); err != nil {
+			return err
+		}
+	} else {
+		reporter, _ := newFindingsReporter(format)
+		for _, secret := range secrets {
+			reporter.Report(secret.finding())
+		}
+		if err := reporter.Flush(); err != nil {
+			return err
+		}
+	}
+	if failures > 0 {
+		return fmt.Errorf("%d possible secrets found, remove them or add their fingerprint to the allowlist.", failures)
+	}
+	return nil
+}
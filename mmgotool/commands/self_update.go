@@ -0,0 +1,315 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	defaultReleaseRepo = "xzl8028/xenia-utilities"
+	// releaseChecksumsAsset lists the SHA-256 of the release assets, in the
+	// sha256sum format. Its minisign signature is the asset with the
+	// signatureExtension suffix.
+	releaseChecksumsAsset = "checksums.txt"
+)
+
+var SelfUpdateCmd = &cobra.Command{
+	Use:   "self-update",
+	Short: "Replace mmgotool with its latest release",
+	Long: `Download the latest GitHub release of mmgotool, or the one of --version, and replace the running binary with it.
+
+The release asset is the binary named mmgotool-<os>-<arch>, with the .exe extension on Windows, checked against the checksums.txt asset of the release. With --public-key, checksums.txt must also be signed by the minisign key, in the checksums.txt.minisig asset. A release without them is only installed with --insecure. The development builds, made without the version linker flags, are only replaced with --force. The GitHub token is read from the token flag or $` + githubTokenEnv + `, to raise the rate limit of the API.`,
+	Example: `  self-update --check
+  self-update --version v5.3.0
+  self-update --public-key mmgotool-release.pub`,
+	Args: cobra.NoArgs,
+	RunE: selfUpdateCmdF,
+}
+
+func init() {
+	SelfUpdateCmd.Flags().Bool("check", false, "Only tell whether a newer release is available")
+	SelfUpdateCmd.Flags().String("version", "", "Release tag to install instead of the latest one")
+	SelfUpdateCmd.Flags().String("github-repo", defaultReleaseRepo, "GitHub repository publishing the releases")
+	SelfUpdateCmd.Flags().String("token", "", "GitHub API token")
+	SelfUpdateCmd.Flags().Bool("force", false, "Replace the binary even when it is a development build or already up to date")
+	SelfUpdateCmd.Flags().String("public-key", "", "Path to the minisign public key signing the checksums of the releases")
+	SelfUpdateCmd.Flags().Bool("insecure", false, "Install the release even when its checksums, or their signature with --public-key, are missing")
+	RootCmd.AddCommand(SelfUpdateCmd)
+}
+
+type githubRelease struct {
+	TagName string               `json:"tag_name"`
+	Assets  []githubReleaseAsset `json:"assets"`
+}
+
+type githubReleaseAsset struct {
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+// asset returns the asset of the release with the name, nil when missing.
+func (r *githubRelease) asset(name string) *githubReleaseAsset {
+	for i := range r.Assets {
+		if r.Assets[i].Name == name {
+			return &r.Assets[i]
+		}
+	}
+	return nil
+}
+
+// releaseAssetName returns the name of the binary asset of the platform.
+func releaseAssetName(goos, goarch string) string {
+	name := fmt.Sprintf("%s-%s-%s", binaryName, goos, goarch)
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// sameVersion tells whether the release tag is the version, with or without
+// the v prefix.
+func sameVersion(tag, version string) bool {
+	return strings.TrimPrefix(tag, "v") == strings.TrimPrefix(version, "v")
+}
+
+func githubGet(client *http.Client, url, token string) (*http.Response, error) {
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/vnd.github+json")
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, fmt.Errorf("unexpected status %s reading %s", response.Status, url)
+	}
+	return response, nil
+}
+
+// fetchRelease reads the release of the tag, the latest one when empty.
+func fetchRelease(client *http.Client, repo, tag, token string) (*githubRelease, error) {
+	url := "https://api.github.com/repos/" + repo + "/releases/latest"
+	if tag != "" {
+		url = "https://api.github.com/repos/" + repo + "/releases/tags/" + tag
+	}
+	response, err := githubGet(client, url, token)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	release := &githubRelease{}
+	if err := json.NewDecoder(response.Body).Decode(release); err != nil {
+		return nil, err
+	}
+	return release, nil
+}
+
+// download reads the whole asset.
+func download(client *http.Client, asset *githubReleaseAsset, token string) ([]byte, error) {
+	response, err := githubGet(client, asset.BrowserDownloadURL, token)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	return ioutil.ReadAll(response.Body)
+}
+
+// releaseChecksum returns the SHA-256 of the asset listed in the checksums
+// asset of the release, verifying the signature of the checksums when the
+// public key is set. A missing checksum or signature is an error unless
+// insecure, the checksum being empty then.
+func releaseChecksum(client *http.Client, release *githubRelease, name, token string, publicKey ed25519.PublicKey, keyId []byte, insecure bool) (string, error) {
+	checksumsAsset := release.asset(releaseChecksumsAsset)
+	if checksumsAsset == nil {
+		if insecure {
+			logger.Warn("The release has no checksums, installing it unverified", "version", release.TagName)
+			return "", nil
+		}
+		return "", fmt.Errorf("The release %s has no %s to verify the download, pass --insecure to install it anyway.", release.TagName, releaseChecksumsAsset)
+	}
+	checksums, err := download(client, checksumsAsset, token)
+	if err != nil {
+		return "", err
+	}
+
+	if publicKey != nil {
+		signatureAsset := release.asset(releaseChecksumsAsset + signatureExtension)
+		switch {
+		case signatureAsset != nil:
+			signature, err := download(client, signatureAsset, token)
+			if err != nil {
+				return "", err
+			}
+			if _, err := verifySignature(releaseChecksumsAsset, checksums, signature, publicKey, keyId); err != nil {
+				return "", err
+			}
+		case insecure:
+			logger.Warn("The checksums of the release are not signed, using them unverified", "version", release.TagName)
+		default:
+			return "", fmt.Errorf("The %s of the release %s is not signed, pass --insecure to install it anyway.", releaseChecksumsAsset, release.TagName)
+		}
+	}
+
+	for _, line := range strings.Split(string(checksums), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return fields[0], nil
+		}
+	}
+	return "", fmt.Errorf("%s is not listed in the %s of %s.", name, releaseChecksumsAsset, release.TagName)
+}
+
+// replaceExecutable writes the new binary next to the running one and
+// renames it over it. Windows can't replace a running binary, which is moved
+// aside first.
+func replaceExecutable(executable string, binary io.Reader, checksum string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(executable), "."+binaryName+"-update-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), binary); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); checksum != "" && !strings.EqualFold(sum, checksum) {
+		return fmt.Errorf("The checksum of the download is %s instead of %s, the binary was left unchanged.", sum, checksum)
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		old := executable + ".old"
+		os.Remove(old)
+		if err := os.Rename(executable, old); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), executable)
+}
+
+func selfUpdateCmdF(command *cobra.Command, args []string) error {
+	check, err := command.Flags().GetBool("check")
+	if err != nil {
+		return errors.New("Invalid check parameter")
+	}
+	tag, err := command.Flags().GetString("version")
+	if err != nil {
+		return errors.New("Invalid version parameter")
+	}
+	repo, err := command.Flags().GetString("github-repo")
+	if err != nil {
+		return errors.New("Invalid github-repo parameter")
+	}
+	token, err := command.Flags().GetString("token")
+	if err != nil {
+		return errors.New("Invalid token parameter")
+	}
+	force, err := command.Flags().GetBool("force")
+	if err != nil {
+		return errors.New("Invalid force parameter")
+	}
+	publicKeyPath, err := command.Flags().GetString("public-key")
+	if err != nil {
+		return errors.New("Invalid public-key parameter")
+	}
+	insecure, err := command.Flags().GetBool("insecure")
+	if err != nil {
+		return errors.New("Invalid insecure parameter")
+	}
+	if token == "" {
+		token = os.Getenv(githubTokenEnv)
+	}
+	command.SilenceUsage = true
+	var publicKey ed25519.PublicKey
+	var keyId []byte
+	if publicKeyPath != "" {
+		if publicKey, keyId, err = readPublicKey(publicKeyPath); err != nil {
+			return err
+		}
+	}
+
+	client := &http.Client{Timeout: 5 * time.Minute}
+	release, err := fetchRelease(client, repo, tag, token)
+	if err != nil {
+		return fmt.Errorf("Unable to read the release of %s: %s", repo, err.Error())
+	}
+	upToDate := sameVersion(release.TagName, Version)
+	if check {
+		switch {
+		case upToDate:
+			fmt.Printf("%s %s is up to date.\n", binaryName, Version)
+		case Version == "dev":
+			fmt.Printf("%s is a development build, the latest release is %s.\n", binaryName, release.TagName)
+		default:
+			fmt.Printf("%s %s is available, this is %s. Run %s self-update to install it.\n", binaryName, release.TagName, Version, binaryName)
+		}
+		return nil
+	}
+	if upToDate && !force {
+		fmt.Printf("%s %s is up to date.\n", binaryName, Version)
+		return nil
+	}
+	if Version == "dev" && !force {
+		return fmt.Errorf("%s is a development build, pass --force to replace it with %s.", binaryName, release.TagName)
+	}
+
+	name := releaseAssetName(runtime.GOOS, runtime.GOARCH)
+	asset := release.asset(name)
+	if asset == nil {
+		return fmt.Errorf("The release %s has no %s binary.", release.TagName, name)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		return err
+	}
+	if err := sandbox.checkPath(executable); err != nil {
+		return err
+	}
+	checksum, err := releaseChecksum(client, release, name, token, publicKey, keyId, insecure)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Downloading the release", "version", release.TagName, "asset", name)
+	response, err := githubGet(client, asset.BrowserDownloadURL, token)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if err := replaceExecutable(executable, response.Body, checksum); err != nil {
+		return err
+	}
+	fmt.Printf("Updated %s from %s to %s.\n", executable, Version, release.TagName)
+	return nil
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReleaseChecksum(t *testing.T) {
+	const (
+		binary    = "mmgotool-linux-amd64"
+		checksum  = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+		checksums = checksum + "  " + binary + "\n"
+	)
+
+	dir, err := ioutil.TempDir("", "mmgotool-self-update-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "release.key")
+	writeTestFile(t, keyFile, string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})))
+	checksumsFile := filepath.Join(dir, releaseChecksumsAsset)
+	writeTestFile(t, checksumsFile, checksums)
+	if err := signArtifact(checksumsFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	signature, err := ioutil.ReadFile(checksumsFile + signatureExtension)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assets := map[string]string{
+		releaseChecksumsAsset:                      checksums,
+		releaseChecksumsAsset + signatureExtension: string(signature),
+		"tampered.txt":                             strings.Replace(checksums, "e3b0", "0000", 1),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(assets[strings.TrimPrefix(r.URL.Path, "/")]))
+	}))
+	defer server.Close()
+	release := func(names ...string) *githubRelease {
+		release := &githubRelease{TagName: "v5.3.0"}
+		for _, name := range names {
+			asset := githubReleaseAsset{Name: name, BrowserDownloadURL: server.URL + "/" + name}
+			if name == "tampered.txt" {
+				asset.Name = releaseChecksumsAsset
+			}
+			release.Assets = append(release.Assets, asset)
+		}
+		return release
+	}
+
+	tests := []struct {
+		name     string
+		release  *githubRelease
+		signed   bool
+		insecure bool
+		checksum string
+		err      string
+	}{
+		{
+			name:     "checksums",
+			release:  release(releaseChecksumsAsset),
+			checksum: checksum,
+		},
+		{
+			name:    "no checksums",
+			release: release(),
+			err:     "pass --insecure",
+		},
+		{
+			name:     "no checksums insecure",
+			release:  release(),
+			insecure: true,
+		},
+		{
+			name:     "signed checksums",
+			release:  release(releaseChecksumsAsset, releaseChecksumsAsset+signatureExtension),
+			signed:   true,
+			checksum: checksum,
+		},
+		{
+			name:    "unsigned checksums",
+			release: release(releaseChecksumsAsset),
+			signed:  true,
+			err:     "is not signed, pass --insecure",
+		},
+		{
+			name:     "unsigned checksums insecure",
+			release:  release(releaseChecksumsAsset),
+			signed:   true,
+			insecure: true,
+			checksum: checksum,
+		},
+		{
+			name:     "tampered checksums",
+			release:  release("tampered.txt", releaseChecksumsAsset+signatureExtension),
+			signed:   true,
+			insecure: true,
+			err:      "Invalid signature for " + releaseChecksumsAsset,
+		},
+		{
+			name:    "binary not listed",
+			release: &githubRelease{TagName: "v5.3.0", Assets: []githubReleaseAsset{{Name: releaseChecksumsAsset, BrowserDownloadURL: server.URL + "/missing"}}},
+			err:     "is not listed",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var key ed25519.PublicKey
+			if test.signed {
+				key = publicKey
+			}
+			got, err := releaseChecksum(server.Client(), test.release, binary, "", key, nil, test.insecure)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != test.checksum {
+				t.Errorf("got checksum %q, want %q", got, test.checksum)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/blake2b"
+)
+
+// Artifacts are signed with Ed25519 keys. The private key is a PEM file, the
+// public key and the signatures use the minisign formats, so the artifacts
+// can also be verified with minisign -Vm <artifact> -p <name>.pub. The
+// signature is written next to the artifact with the signatureExtension
+// suffix.
+const signatureExtension = ".minisig"
+
+const (
+	minisignAlgorithm       = "Ed"
+	minisignHashedAlgorithm = "ED"
+	minisignUntrusted       = "untrusted comment: "
+	minisignTrusted         = "trusted comment: "
+)
+
+var KeygenCmd = &cobra.Command{
+	Use:     "keygen <name>",
+	Short:   "Generate a signing key pair",
+	Long:    "Generate the <name>.key private key used with --sign-key and the <name>.pub minisign public key used to verify the signed artifacts, with i18n verify or minisign",
+	Example: "  i18n keygen release",
+	Args:    cobra.ExactArgs(1),
+	RunE:    keygenCmdF,
+}
+
+var VerifyCmd = &cobra.Command{
+	Use:   "verify <artifact>",
+	Short: "Verify a signed artifact",
+	Long:  "Verify the minisign signature of an artifact generated with --sign-key, like the manifest.json of i18n pack",
+	Example: `  i18n verify report.txt --public-key release.pub
+  minisign -Vm report.txt -p release.pub`,
+	Args: cobra.ExactArgs(1),
+	RunE: verifyCmdF,
+}
+
+func init() {
+	VerifyCmd.Flags().String("public-key", "", "Path to the public key, in the minisign or the PEM format")
+	VerifyCmd.Flags().String("signature", "", "Path to the signature (defaults to the artifact path with the "+signatureExtension+" suffix)")
+	I18nCmd.AddCommand(KeygenCmd, VerifyCmd)
+}
+
+func keygenCmdF(command *cobra.Command, args []string) error {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFile(args[0]+".key", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0600); err != nil {
+		return err
+	}
+	if err := writeFile(args[0]+".pub", encodeMinisignPublicKey(publicKey), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Keys written to %s.key and %s.pub\n", args[0], args[0])
+	return nil
+}
+
+func verifyCmdF(command *cobra.Command, args []string) error {
+	publicKeyPath, err := command.Flags().GetString("public-key")
+	if err != nil || publicKeyPath == "" {
+		return errors.New("Invalid public-key parameter")
+	}
+	signaturePath, err := command.Flags().GetString("signature")
+	if err != nil {
+		return errors.New("Invalid signature parameter")
+	}
+	if signaturePath == "" {
+		signaturePath = args[0] + signatureExtension
+	}
+
+	command.SilenceUsage = true
+	trustedComment, err := verifyArtifact(args[0], signaturePath, publicKeyPath)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Signature verified:", args[0])
+	fmt.Println("Trusted comment:", trustedComment)
+	return nil
+}
+
+func readPEM(keyPath, blockType string) ([]byte, error) {
+	data, err := readFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("%s does not contain a %s", keyPath, strings.ToLower(blockType))
+	}
+	return block.Bytes, nil
+}
+
+// minisignKeyId returns the key id of the minisign formats, derived from the
+// public key since the PEM private key has none.
+func minisignKeyId(publicKey ed25519.PublicKey) []byte {
+	sum := sha256.Sum256(publicKey)
+	return sum[:8]
+}
+
+func encodeMinisignPublicKey(publicKey ed25519.PublicKey) []byte {
+	keyId := minisignKeyId(publicKey)
+	key := append(append([]byte(minisignAlgorithm), keyId...), publicKey...)
+	return []byte(fmt.Sprintf("%sminisign public key %X\n%s\n", minisignUntrusted, binary.LittleEndian.Uint64(keyId), base64.StdEncoding.EncodeToString(key)))
+}
+
+// readPublicKey reads a minisign public key, or a PEM one, returning its key
+// id, nil for a PEM key.
+func readPublicKey(publicKeyPath string) (ed25519.PublicKey, []byte, error) {
+	data, err := readFile(publicKeyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if bytes.HasPrefix(data, []byte("-----BEGIN")) {
+		der, err := readPEM(publicKeyPath, "PUBLIC KEY")
+		if err != nil {
+			return nil, nil, err
+		}
+		key, err := x509.ParsePKIXPublicKey(der)
+		if err != nil {
+			return nil, nil, err
+		}
+		publicKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, nil, fmt.Errorf("%s is not an Ed25519 public key", publicKeyPath)
+		}
+		return publicKey, nil, nil
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	encoded := strings.TrimSpace(lines[len(lines)-1])
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 2+8+ed25519.PublicKeySize || string(key[:2]) != minisignAlgorithm {
+		return nil, nil, fmt.Errorf("%s is not a minisign public key", publicKeyPath)
+	}
+	return ed25519.PublicKey(key[10:]), key[2:10], nil
+}
+
+// signArtifact writes the minisign signature of the artifact, prehashed with
+// BLAKE2b like minisign does, using the private key generated by the keygen
+// command.
+func signArtifact(artifactPath, privateKeyPath string) error {
+	der, err := readPEM(privateKeyPath, "PRIVATE KEY")
+	if err != nil {
+		return err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return err
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return fmt.Errorf("%s is not an Ed25519 private key", privateKeyPath)
+	}
+
+	artifact, err := readFile(artifactPath)
+	if err != nil {
+		return err
+	}
+	digest := blake2b.Sum512(artifact)
+	signature := ed25519.Sign(privateKey, digest[:])
+	trustedComment := fmt.Sprintf("timestamp:%d\tfile:%s\thashed", time.Now().Unix(), filepath.Base(artifactPath))
+	globalSignature := ed25519.Sign(privateKey, append(append([]byte{}, signature...), trustedComment...))
+
+	keyId := minisignKeyId(privateKey.Public().(ed25519.PublicKey))
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "%ssignature from mmgotool secret key\n", minisignUntrusted)
+	fmt.Fprintln(&out, base64.StdEncoding.EncodeToString(append(append([]byte(minisignHashedAlgorithm), keyId...), signature...)))
+	fmt.Fprintf(&out, "%s%s\n", minisignTrusted, trustedComment)
+	fmt.Fprintln(&out, base64.StdEncoding.EncodeToString(globalSignature))
+	return writeFile(artifactPath+signatureExtension, out.Bytes(), 0644)
+}
+
+// verifyArtifact verifies the minisign signature of the artifact, prehashed
+// or not, and returns its trusted comment.
+func verifyArtifact(artifactPath, signaturePath, publicKeyPath string) (string, error) {
+	publicKey, keyId, err := readPublicKey(publicKeyPath)
+	if err != nil {
+		return "", err
+	}
+	signature, err := readFile(signaturePath)
+	if err != nil {
+		return "", err
+	}
+	artifact, err := readFile(artifactPath)
+	if err != nil {
+		return "", err
+	}
+	return verifySignature(artifactPath, artifact, signature, publicKey, keyId)
+}
+
+// verifySignature verifies the minisign signature of the artifact named name
+// with the public key and its key id, derived from the key when nil.
+func verifySignature(name string, artifact, signatureData []byte, publicKey ed25519.PublicKey, keyId []byte) (string, error) {
+	lines := strings.Split(strings.TrimRight(string(signatureData), "\n"), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], minisignUntrusted) || !strings.HasPrefix(lines[2], minisignTrusted) {
+		return "", fmt.Errorf("Invalid signature file of %s", name)
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(signature) != 2+8+ed25519.SignatureSize {
+		return "", fmt.Errorf("Invalid signature file of %s", name)
+	}
+	globalSignature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(globalSignature) != ed25519.SignatureSize {
+		return "", fmt.Errorf("Invalid signature file of %s", name)
+	}
+	if keyId == nil {
+		keyId = minisignKeyId(publicKey)
+	}
+	if !bytes.Equal(signature[2:10], keyId) {
+		return "", fmt.Errorf("%s was signed with another key", name)
+	}
+
+	switch string(signature[:2]) {
+	case minisignHashedAlgorithm:
+		digest := blake2b.Sum512(artifact)
+		artifact = digest[:]
+	case minisignAlgorithm:
+	default:
+		return "", fmt.Errorf("Invalid signature file of %s", name)
+	}
+	if !ed25519.Verify(publicKey, artifact, signature[10:]) {
+		return "", fmt.Errorf("Invalid signature for %s", name)
+	}
+	trustedComment := strings.TrimPrefix(lines[2], minisignTrusted)
+	if !ed25519.Verify(publicKey, append(append([]byte{}, signature[10:]...), trustedComment...), globalSignature) {
+		return "", fmt.Errorf("Invalid trusted comment signature for %s", name)
+	}
+	return trustedComment, nil
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The minisign key and signature of the manifest were generated by another
+// minisign implementation, the signature not being prehashed.
+const (
+	minisignTestPublicKey = `untrusted comment: minisign public key: E14A89C6C39F218E
+RWSOIZ/DxolK4b0xPf0BtUu1uMW40Yg7w4u8PZQVrHjgyKjgivO9WPYn
+`
+	minisignTestSignature = `untrusted comment: signature from minisign secret key
+RWSOIZ/DxolK4Yjt3V+WauXrEoT2xeqbuD721G8/EeC+o2AkhiOmPKw9o6fN1Tgqbde2XUOse031kvrhb0H+XHe2ZzqXknLKcAg=
+trusted comment: timestamp:1700000000	file:manifest.json	hashed
+/K10S78VFHWaHZwOnImJ4rJ6Mu2ReWERzRRCjfotGJ7o8ezO6ZKdUFEl7ny1kATsW25CniW3bZNa1dY4FXtbCA==
+`
+	minisignTestManifest = "{\"locales\":{}}\n"
+)
+
+func TestVerifyMinisignSignature(t *testing.T) {
+	tests := []struct {
+		name      string
+		manifest  string
+		signature string
+		err       string
+	}{
+		{
+			name:      "valid",
+			manifest:  minisignTestManifest,
+			signature: minisignTestSignature,
+		},
+		{
+			name:      "modified artifact",
+			manifest:  "{\"locales\":{\"fr\":{}}}\n",
+			signature: minisignTestSignature,
+			err:       "Invalid signature for",
+		},
+		{
+			name:      "modified trusted comment",
+			manifest:  minisignTestManifest,
+			signature: strings.Replace(minisignTestSignature, "timestamp:1700000000", "timestamp:1800000000", 1),
+			err:       "Invalid trusted comment signature",
+		},
+		{
+			name:      "truncated",
+			manifest:  minisignTestManifest,
+			signature: strings.Join(strings.Split(minisignTestSignature, "\n")[:2], "\n"),
+			err:       "Invalid signature file of",
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "mmgotool-signing-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	publicKey := filepath.Join(dir, "minisign.pub")
+	writeTestFile(t, publicKey, minisignTestPublicKey)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manifest := filepath.Join(dir, "manifest.json")
+			writeTestFile(t, manifest, test.manifest)
+			writeTestFile(t, manifest+signatureExtension, test.signature)
+			trustedComment, err := verifyArtifact(manifest, manifest+signatureExtension, publicKey)
+			if test.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if trustedComment != "timestamp:1700000000\tfile:manifest.json\thashed" {
+					t.Errorf("got trusted comment %q", trustedComment)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("got error %v, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestSignArtifact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mmgotool-signing-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "release.key")
+	writeTestFile(t, keyFile, string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})))
+	minisignKey := filepath.Join(dir, "release.pub")
+	writeTestFile(t, minisignKey, string(encodeMinisignPublicKey(publicKey)))
+	pemKey := filepath.Join(dir, "release.pem")
+	writeTestFile(t, pemKey, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})))
+	otherPublicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey := filepath.Join(dir, "other.pub")
+	writeTestFile(t, otherKey, string(encodeMinisignPublicKey(otherPublicKey)))
+
+	manifest := filepath.Join(dir, "manifest.json")
+	writeTestFile(t, manifest, minisignTestManifest)
+	if err := signArtifact(manifest, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	signature, err := ioutil.ReadFile(manifest + signatureExtension)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The base64 of the ED algorithm of the prehashed signatures.
+	if lines := strings.Split(string(signature), "\n"); !strings.HasPrefix(lines[1], "RU") {
+		t.Errorf("got signature %q, want a prehashed one", lines[1])
+	}
+
+	for _, key := range []string{minisignKey, pemKey} {
+		if _, err := verifyArtifact(manifest, manifest+signatureExtension, key); err != nil {
+			t.Errorf("unexpected error verifying with %s: %s", filepath.Base(key), err)
+		}
+	}
+	if _, err := verifyArtifact(manifest, manifest+signatureExtension, otherKey); err == nil || !strings.Contains(err.Error(), "signed with another key") {
+		t.Errorf("got error %v verifying with another key", err)
+	}
+}
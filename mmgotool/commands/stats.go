@@ -0,0 +1,171 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Usage statistics are only recorded when this environment variable is set to
+// true, and they never leave the local usage file.
+const usageStatsEnv = "MMGOTOOL_USAGE_STATS"
+
+type usageRecord struct {
+	Command    string    `json:"command"`
+	Timestamp  time.Time `json:"timestamp"`
+	DurationMs int64     `json:"duration_ms"`
+	Success    bool      `json:"success"`
+}
+
+var StatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show local usage statistics",
+	Long: fmt.Sprintf(`Show how often and how long every command ran on this machine.
+
+Statistics are only collected when the %s environment variable is set to true.
+They are stored in a local file and never sent anywhere.`, usageStatsEnv),
+	Example: "  dev stats",
+	RunE:    statsCmdF,
+}
+
+func init() {
+	StatsCmd.Flags().Bool("clear", false, "Delete the recorded usage statistics")
+	DevCmd.AddCommand(StatsCmd)
+}
+
+func usageStatsEnabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv(usageStatsEnv))
+	return enabled
+}
+
+func usageFilePath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "mmgotool", "usage.jsonl"), nil
+}
+
+// recordUsage appends the executed command to the local usage file. Failures
+// are ignored, statistics must never break a command.
+func recordUsage(command *cobra.Command, duration time.Duration, err error) {
+	if command == nil || command == StatsCmd || !usageStatsEnabled() {
+		return
+	}
+	usageFile, pathErr := usageFilePath()
+	if pathErr != nil {
+		return
+	}
+	if mkdirAll(filepath.Dir(usageFile), 0700) != nil {
+		return
+	}
+	f, openErr := appendFile(usageFile, 0600)
+	if openErr != nil {
+		return
+	}
+	defer f.Close()
+
+	json.NewEncoder(f).Encode(usageRecord{
+		Command:    command.CommandPath(),
+		Timestamp:  time.Now().UTC(),
+		DurationMs: int64(duration / time.Millisecond),
+		Success:    err == nil,
+	})
+}
+
+type usageSummary struct {
+	command  string
+	runs     int
+	failures int
+	total    time.Duration
+	lastRun  time.Time
+}
+
+func statsCmdF(command *cobra.Command, args []string) error {
+	clear, err := command.Flags().GetBool("clear")
+	if err != nil {
+		return errors.New("Invalid clear parameter")
+	}
+
+	usageFile, err := usageFilePath()
+	if err != nil {
+		return err
+	}
+
+	if clear {
+		if err := removeFile(usageFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	if !usageStatsEnabled() {
+		fmt.Printf("Usage statistics are disabled, set %s=true to enable them.\n", usageStatsEnv)
+	}
+
+	f, err := openFile(usageFile)
+	if os.IsNotExist(err) {
+		fmt.Println("No usage statistics recorded.")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	summaries := map[string]*usageSummary{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record usageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		summary, ok := summaries[record.Command]
+		if !ok {
+			summary = &usageSummary{command: record.Command}
+			summaries[record.Command] = summary
+		}
+		summary.runs++
+		if !record.Success {
+			summary.failures++
+		}
+		summary.total += time.Duration(record.DurationMs) * time.Millisecond
+		if record.Timestamp.After(summary.lastRun) {
+			summary.lastRun = record.Timestamp
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	sorted := []*usageSummary{}
+	for _, summary := range summaries {
+		sorted = append(sorted, summary)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].runs != sorted[j].runs {
+			return sorted[i].runs > sorted[j].runs
+		}
+		return sorted[i].command < sorted[j].command
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COMMAND\tRUNS\tFAILURES\tAVG DURATION\tLAST RUN")
+	for _, summary := range sorted {
+		average := summary.total / time.Duration(summary.runs)
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\n", summary.command, summary.runs, summary.failures, average.Round(time.Millisecond), summary.lastRun.Format("2006-01-02"))
+	}
+	return w.Flush()
+}
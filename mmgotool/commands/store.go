@@ -0,0 +1,260 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"go/ast"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/xzl8028/xenia-utilities/mmgotool/internal/codegen"
+)
+
+//go:embed templates/store/*.tmpl
+var storeTemplates embed.FS
+
+// storeLayer is a generated wrapper of the store.
+type storeLayer struct {
+	Package  string
+	Prefix   string
+	Template string
+	Imports  []string
+}
+
+var storeLayers = []storeLayer{
+	{Package: "opentracinglayer", Prefix: "OpenTracingLayer", Template: "opentracing_layer.go", Imports: []string{
+		`"context"`,
+		`opentracing "github.com/opentracing/opentracing-go"`,
+		`"github.com/opentracing/opentracing-go/ext"`,
+		`spanlog "github.com/opentracing/opentracing-go/log"`,
+	}},
+	{Package: "retrylayer", Prefix: "RetryLayer", Template: "retry_layer.go", Imports: []string{`"fmt"`, `"strings"`}},
+	{Package: "timerlayer", Prefix: "TimerLayer", Template: "timer_layer.go", Imports: []string{`timemodule "time"`}},
+}
+
+var StoreCmd = &cobra.Command{
+	Use:   "store",
+	Short: "Store code generation",
+}
+
+var StoreGenerateLayersCmd = &cobra.Command{
+	Use:   "generate-layers",
+	Short: "Generate the OpenTracing, timer and retry layers of the store",
+	Long: `Parse the Store interface and generate the OpenTracing, timer metrics and retry wrappers of every sub store it returns.
+Each layer is written to <output-dir>/<layer>/<layer>.go. With --check nothing is written and the differences with the checked-in layers are printed instead.`,
+	Example: "  codegen store generate-layers --dir store --check",
+	RunE:    storeGenerateLayersCmdF,
+}
+
+func init() {
+	StoreGenerateLayersCmd.Flags().String("dir", "store", "Path to the package declaring the Store interface")
+	StoreGenerateLayersCmd.Flags().String("output-dir", "", "Path to the folder receiving the layer packages, defaults to the dir")
+	StoreGenerateLayersCmd.Flags().String("interface", "Store", "Name of the store interface")
+	StoreGenerateLayersCmd.Flags().String("import-path", "", "Import path of the store package, detected from go.mod or GOPATH by default")
+	StoreGenerateLayersCmd.Flags().Bool("check", false, "Fail if the checked-in layers are out of date instead of writing them")
+	StoreCmd.AddCommand(StoreGenerateLayersCmd)
+	CodegenCmd.AddCommand(StoreCmd)
+}
+
+type storeMethod struct {
+	mockMethod
+	// ErrorVar is the result variable holding the error, if any.
+	ErrorVar string
+	// ContextParam is the name of the context.Context parameter, if any.
+	ContextParam string
+}
+
+type subStore struct {
+	Accessor  string
+	Interface string
+	Methods   []storeMethod
+}
+
+type storeLayerData struct {
+	Package        string
+	Name           string
+	StorePackage   string
+	StoreInterface string
+	StoreType      string
+	StdImports     []string
+	Imports        []string
+	SubStores      []subStore
+
+	// storeImports are the imports needed by the sub store methods.
+	storeImports []string
+}
+
+// loadStoreLayerData reads the sub stores of the store interface: the
+// methods without parameters returning another interface of the package.
+func loadStoreLayerData(dir, outputDir, name, importPath string) (*storeLayerData, error) {
+	opts := &mocksOptions{Dir: dir, Output: filepath.Join(outputDir, storeLayers[0].Package), ImportPath: importPath}
+	source, err := loadMockSource(opts)
+	if err != nil {
+		return nil, err
+	}
+	if source.interfaces[name] == nil {
+		return nil, fmt.Errorf("Interface %s not found in package %s.", name, source.name)
+	}
+	methods, _, err := source.methods(name, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+
+	data := &storeLayerData{
+		StorePackage:   source.name,
+		StoreInterface: name,
+		StoreType:      source.name + "." + name,
+	}
+	imports := map[string]bool{fmt.Sprintf("%s %q", source.name, source.importPath): true}
+	accessors := []string{}
+	for accessor := range methods {
+		accessors = append(accessors, accessor)
+	}
+	sort.Strings(accessors)
+	for _, accessor := range accessors {
+		funcType := methods[accessor]
+		if len(funcType.Params.List) != 0 || funcType.Results == nil || len(funcType.Results.List) != 1 {
+			continue
+		}
+		result, ok := funcType.Results.List[0].Type.(*ast.Ident)
+		if !ok || source.interfaces[result.Name] == nil || result.Name == name {
+			continue
+		}
+
+		iface, err := source.mockInterface(result.Name, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, spec := range append(iface.StdImports, iface.Imports...) {
+			imports[spec] = true
+		}
+		sub := subStore{Accessor: accessor, Interface: result.Name}
+		for _, method := range iface.Methods {
+			sub.Methods = append(sub.Methods, newStoreMethod(method))
+		}
+		data.SubStores = append(data.SubStores, sub)
+	}
+	if len(data.SubStores) == 0 {
+		return nil, fmt.Errorf("Interface %s has no sub store.", name)
+	}
+
+	delete(imports, `mock "github.com/stretchr/testify/mock"`)
+	for spec := range imports {
+		data.storeImports = append(data.storeImports, spec)
+	}
+	sort.Strings(data.storeImports)
+	return data, nil
+}
+
+func newStoreMethod(method mockMethod) storeMethod {
+	storeMethod := storeMethod{mockMethod: method}
+	if n := len(method.Results); n > 0 && method.Results[n-1].IsError {
+		storeMethod.ErrorVar = fmt.Sprintf("r%d", n-1)
+	}
+	if strings.HasPrefix(method.ParamTypes, "context.Context") {
+		storeMethod.ContextParam = strings.SplitN(method.ParamNames, ",", 2)[0]
+	}
+	return storeMethod
+}
+
+// layerData returns the template data of the layer, with the imports of the
+// layer added to the ones of the store and split in standard library and
+// third party groups.
+func (d storeLayerData) layerData(layer storeLayer) storeLayerData {
+	d.Package = layer.Package
+	d.Name = layer.Prefix
+	d.StdImports, d.Imports = []string{}, []string{}
+	seen := map[string]bool{}
+	for _, spec := range append(append([]string{}, layer.Imports...), d.storeImports...) {
+		if seen[spec] {
+			continue
+		}
+		seen[spec] = true
+		importPath := spec[strings.Index(spec, `"`)+1:]
+		if strings.Contains(strings.SplitN(importPath, "/", 2)[0], ".") {
+			d.Imports = append(d.Imports, spec)
+		} else {
+			d.StdImports = append(d.StdImports, spec)
+		}
+	}
+	sort.Strings(d.StdImports)
+	sort.Strings(d.Imports)
+	return d
+}
+
+func storeGenerateLayersCmdF(command *cobra.Command, args []string) error {
+	dir, err := command.Flags().GetString("dir")
+	if err != nil {
+		return errors.New("Invalid dir parameter")
+	}
+	outputDir, err := command.Flags().GetString("output-dir")
+	if err != nil {
+		return errors.New("Invalid output-dir parameter")
+	}
+	name, err := command.Flags().GetString("interface")
+	if err != nil {
+		return errors.New("Invalid interface parameter")
+	}
+	importPath, err := command.Flags().GetString("import-path")
+	if err != nil {
+		return errors.New("Invalid import-path parameter")
+	}
+	check, err := command.Flags().GetBool("check")
+	if err != nil {
+		return errors.New("Invalid check parameter")
+	}
+	if outputDir == "" {
+		outputDir = dir
+	}
+
+	data, err := loadStoreLayerData(dir, outputDir, name, importPath)
+	if err != nil {
+		return err
+	}
+	engine, err := codegen.New("codegen store generate-layers", storeTemplates, "templates/store/*.tmpl")
+	if err != nil {
+		return err
+	}
+
+	outdated := 0
+	for _, layer := range storeLayers {
+		src, err := engine.Render(layer.Template, data.layerData(layer))
+		if err != nil {
+			return fmt.Errorf("Unable to generate the %s: %s", layer.Package, err.Error())
+		}
+		layerFile := filepath.Join(outputDir, layer.Package, layer.Package+".go")
+
+		if !check {
+			if err := mkdirAll(filepath.Dir(layerFile), 0755); err != nil {
+				return err
+			}
+			if err := writeFile(layerFile, src, 0644); err != nil {
+				return err
+			}
+			fmt.Println("Generated", layerFile)
+			continue
+		}
+
+		current, err := readFile(layerFile)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if diff := unifiedDiff("a/"+filepath.ToSlash(layerFile), "b/"+filepath.ToSlash(layerFile), string(current), string(src), 3); diff != "" {
+			fmt.Print(diff)
+			outdated++
+		}
+	}
+
+	if outdated > 0 {
+		command.SilenceUsage = true
+		return fmt.Errorf("%d store layers are out of date, run mmgotool codegen store generate-layers.", outdated)
+	}
+	return nil
+}
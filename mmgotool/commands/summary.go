@@ -0,0 +1,180 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// summaryCollapsedRows is the number of rows above which a table of the
+// summary is folded in a <details> block.
+const summaryCollapsedRows = 20
+
+// runSummary collects the Markdown sections written with --summary-file.
+// Every command gets its result and duration, the commands add their own
+// tables and timings.
+type runSummary struct {
+	sections []string
+	timings  []summaryTiming
+}
+
+type summaryTiming struct {
+	Phase    string
+	Duration time.Duration
+}
+
+var stepSummary = &runSummary{}
+
+func init() {
+	RootCmd.PersistentFlags().String("summary-file", "", "Append a Markdown summary of the run to this file, like $GITHUB_STEP_SUMMARY")
+}
+
+// summaryRequested tells whether the run writes a summary, for the commands
+// computing details only shown there.
+func summaryRequested(command *cobra.Command) bool {
+	summaryFile, err := command.Flags().GetString("summary-file")
+	return err == nil && summaryFile != ""
+}
+
+// AddTable adds a table, folded when it has many rows. Nothing is added
+// without rows.
+func (s *runSummary) AddTable(title string, header []string, rows [][]string) {
+	if len(rows) == 0 {
+		return
+	}
+	var buf bytes.Buffer
+	collapsed := len(rows) > summaryCollapsedRows
+	if collapsed {
+		fmt.Fprintf(&buf, "<details><summary>%s (%d)</summary>\n\n", title, len(rows))
+	} else {
+		fmt.Fprintf(&buf, "### %s (%d)\n\n", title, len(rows))
+	}
+	fmt.Fprintf(&buf, "| %s |\n", strings.Join(header, " | "))
+	fmt.Fprintf(&buf, "|%s\n", strings.Repeat(" --- |", len(header)))
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = markdownCell(cell)
+		}
+		fmt.Fprintf(&buf, "| %s |\n", strings.Join(cells, " | "))
+	}
+	if collapsed {
+		buf.WriteString("\n</details>\n")
+	}
+	s.sections = append(s.sections, buf.String())
+}
+
+// AddTiming records the duration of a phase of the command.
+func (s *runSummary) AddTiming(phase string, duration time.Duration) {
+	s.timings = append(s.timings, summaryTiming{Phase: phase, Duration: duration})
+}
+
+// markdownCell escapes a value for a table cell.
+func markdownCell(value string) string {
+	value = strings.Replace(value, "|", `\|`, -1)
+	value = strings.Replace(value, "\n", " ", -1)
+	return value
+}
+
+func (s *runSummary) markdown(command *cobra.Command, duration time.Duration, err error) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "## `mmgotool %s`\n\n", strings.TrimPrefix(command.CommandPath(), command.Root().Name()+" "))
+	if err != nil {
+		fmt.Fprintf(&buf, "**Failed** in %s: %s\n\n", duration.Round(time.Millisecond), markdownCell(err.Error()))
+	} else {
+		fmt.Fprintf(&buf, "**Passed** in %s.\n\n", duration.Round(time.Millisecond))
+	}
+	for _, section := range s.sections {
+		buf.WriteString(section)
+		buf.WriteString("\n")
+	}
+	if len(s.timings) > 0 {
+		buf.WriteString("### Timing\n\n| Phase | Duration |\n| --- | --- |\n")
+		for _, timing := range s.timings {
+			fmt.Fprintf(&buf, "| %s | %s |\n", markdownCell(timing.Phase), timing.Duration.Round(time.Millisecond))
+		}
+		fmt.Fprintf(&buf, "| Total | %s |\n\n", duration.Round(time.Millisecond))
+	}
+	return buf.Bytes()
+}
+
+// writeRunSummary appends the summary of the run to the --summary-file, the
+// CI job summaries are shared by every step.
+func writeRunSummary(command *cobra.Command, duration time.Duration, err error) {
+	if command == nil {
+		return
+	}
+	summaryFile, flagErr := command.Flags().GetString("summary-file")
+	if flagErr != nil || summaryFile == "" {
+		return
+	}
+	f, openErr := appendFile(summaryFile, 0644)
+	if openErr != nil {
+		logger.Warn("Unable to write the summary file", "path", summaryFile, "error", openErr)
+		return
+	}
+	defer f.Close()
+	if _, writeErr := f.Write(stepSummary.markdown(command, duration, err)); writeErr != nil {
+		logger.Warn("Unable to write the summary file", "path", summaryFile, "error", writeErr)
+	}
+}
+
+// localeCompletionDelta is the share of the English ids translated by a
+// locale before and after a change of the English ids.
+type localeCompletionDelta struct {
+	Locale string
+	Before float64
+	After  float64
+}
+
+// localeCompletionDeltas compares the completion of every locale with the
+// ids of i18n/en.json and with the ids expected from the source code.
+func localeCompletionDeltas(xeniaDir string, translations []Translation, i18nStrings map[string]bool) ([]localeCompletionDelta, error) {
+	files, err := localeFiles(xeniaDir)
+	if err != nil {
+		return nil, err
+	}
+	before := map[string]bool{}
+	for _, t := range translations {
+		before[t.Id] = true
+	}
+
+	deltas := []localeCompletionDelta{}
+	for _, file := range files {
+		localeTranslations, err := readTranslationsFile(file)
+		if err != nil {
+			return nil, err
+		}
+		translated := map[string]bool{}
+		for _, t := range localeTranslations {
+			if !isEmptyTranslation(t.Translation) {
+				translated[t.Id] = true
+			}
+		}
+		deltas = append(deltas, localeCompletionDelta{
+			Locale: localeName(file),
+			Before: completionRatio(translated, before),
+			After:  completionRatio(translated, i18nStrings),
+		})
+	}
+	return deltas, nil
+}
+
+func completionRatio(translated, ids map[string]bool) float64 {
+	if len(ids) == 0 {
+		return 1
+	}
+	count := 0
+	for id := range ids {
+		if translated[id] {
+			count++
+		}
+	}
+	return float64(count) / float64(len(ids))
+}
@@ -0,0 +1,374 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// sanitizedValue is the placeholder written by the server in place of the
+// secrets of the configuration.
+const sanitizedValue = "********"
+
+var translationIdPattern = regexp.MustCompile(`\b[a-z0-9_]+(?:\.[a-z0-9_]+)+\.app_error\b`)
+
+var SupportPacketCmd = &cobra.Command{
+	Use:   "support-packet",
+	Short: "Support packet tooling",
+}
+
+var SupportPacketAnalyzeCmd = &cobra.Command{
+	Use:   "analyze <support-packet.zip>",
+	Short: "Summarize a support packet",
+	Long: `Unpack a support packet and print the server and plugins versions, the configuration settings that differ from the defaults and the most frequent errors of the logs grouped by translation id.
+Error messages are mapped back to their translation id using the i18n/en.json file of the xenia-dir when available.`,
+	Example: "  dev support-packet analyze xenia_support_packet.zip --defaults config/default.json",
+	Args:    cobra.ExactArgs(1),
+	RunE:    supportPacketAnalyzeCmdF,
+}
+
+func init() {
+	SupportPacketAnalyzeCmd.Flags().String("defaults", "", "Path to a configuration file with the default settings")
+	SupportPacketAnalyzeCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	SupportPacketAnalyzeCmd.Flags().Int("top", 20, "Number of errors to list")
+	SupportPacketCmd.AddCommand(SupportPacketAnalyzeCmd)
+	DevCmd.AddCommand(SupportPacketCmd)
+}
+
+type supportPacket struct {
+	files map[string][]byte
+}
+
+func openSupportPacket(filePath string) (*supportPacket, error) {
+	if err := sandbox.checkPath(filePath); err != nil {
+		return nil, err
+	}
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	packet := &supportPacket{files: map[string][]byte{}}
+	for _, f := range reader.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		packet.files[f.Name] = data
+	}
+	return packet, nil
+}
+
+// find returns the content of the first file with one of the names, looking
+// in every directory of the packet.
+func (p *supportPacket) find(names ...string) ([]byte, string) {
+	for _, name := range p.sortedNames() {
+		for _, candidate := range names {
+			if path.Base(name) == candidate {
+				return p.files[name], name
+			}
+		}
+	}
+	return nil, ""
+}
+
+func (p *supportPacket) sortedNames() []string {
+	names := []string{}
+	for name := range p.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// flattenSettings returns the leaves of a decoded JSON document keyed by
+// their dotted path.
+func flattenSettings(prefix string, value interface{}, settings map[string]interface{}) {
+	if object, ok := value.(map[string]interface{}); ok {
+		for key, child := range object {
+			name := key
+			if prefix != "" {
+				name = prefix + "." + key
+			}
+			flattenSettings(name, child, settings)
+		}
+		return
+	}
+	settings[prefix] = value
+}
+
+type settingChange struct {
+	Name    string
+	Default interface{}
+	Value   interface{}
+}
+
+// configChanges returns the settings of config that differ from defaults,
+// ignoring the sanitized secrets.
+func configChanges(config, defaults map[string]interface{}) []settingChange {
+	changes := []settingChange{}
+	for name, value := range config {
+		if value == sanitizedValue {
+			continue
+		}
+		defaultValue, ok := defaults[name]
+		if ok && reflect.DeepEqual(value, defaultValue) {
+			continue
+		}
+		changes = append(changes, settingChange{Name: name, Default: defaultValue, Value: value})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+func formatSettingValue(value interface{}) string {
+	if value == nil {
+		return "(unset)"
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// errorMessageIds maps the English messages without placeholders to their
+// translation id.
+func errorMessageIds(xeniaDir string) map[string]string {
+	translations, err := getCurrentTranslations(xeniaDir)
+	if err != nil {
+		return map[string]string{}
+	}
+	ids := map[string]string{}
+	for _, t := range translations {
+		text, ok := t.Translation.(string)
+		if !ok || text == "" || strings.Contains(text, "{{") {
+			continue
+		}
+		ids[text] = t.Id
+	}
+	return ids
+}
+
+// errorTranslationId finds the translation id of a logged error. Ids
+// written as is are used first, then the message of an AppError, formatted
+// as "where: message, details", is looked up in the English translations.
+func errorTranslationId(message string, messageIds map[string]string) string {
+	if id := translationIdPattern.FindString(message); id != "" {
+		return id
+	}
+	if idx := strings.Index(message, ": "); idx != -1 {
+		message = message[idx+2:]
+	}
+	for {
+		if id, ok := messageIds[message]; ok {
+			return id
+		}
+		idx := strings.LastIndex(message, ", ")
+		if idx == -1 {
+			return ""
+		}
+		message = message[:idx]
+	}
+}
+
+type logEntry struct {
+	Level string `json:"level"`
+	Msg   string `json:"msg"`
+	Error string `json:"error"`
+}
+
+// countLogErrors counts the error log entries by translation id, or by
+// message when the id is unknown.
+func countLogErrors(data []byte, messageIds map[string]string) map[string]int {
+	counts := map[string]int{}
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry logEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if entry.Level != "error" && entry.Level != "critical" && entry.Level != "fatal" {
+			continue
+		}
+		key := errorTranslationId(entry.Error, messageIds)
+		if key == "" {
+			key = errorTranslationId(entry.Msg, messageIds)
+		}
+		if key == "" {
+			key = entry.Msg
+		}
+		counts[key]++
+	}
+	return counts
+}
+
+func printPacketVersions(packet *supportPacket) error {
+	data, name := packet.find("support_packet.yaml")
+	if data == nil {
+		fmt.Println("  No support_packet.yaml found.")
+		return nil
+	}
+	info := yaml.MapSlice{}
+	if err := yaml.Unmarshal(data, &info); err != nil {
+		return fmt.Errorf("Unable to parse %s: %s", name, err.Error())
+	}
+	for _, item := range info {
+		switch item.Value.(type) {
+		case yaml.MapSlice, []interface{}:
+			continue
+		}
+		fmt.Printf("  %v: %v\n", item.Key, item.Value)
+	}
+	return nil
+}
+
+func printPacketPlugins(packet *supportPacket) error {
+	data, name := packet.find("plugins.json")
+	if data == nil {
+		fmt.Println("  No plugins.json found.")
+		return nil
+	}
+	var plugins map[string][]struct {
+		Id      string `json:"id"`
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(data, &plugins); err != nil {
+		return fmt.Errorf("Unable to parse %s: %s", name, err.Error())
+	}
+	for _, state := range []string{"active", "inactive"} {
+		for _, plugin := range plugins[state] {
+			fmt.Printf("  %s %s (%s)\n", plugin.Id, plugin.Version, state)
+		}
+	}
+	return nil
+}
+
+func printPacketConfig(packet *supportPacket, defaultsFile string) error {
+	if defaultsFile == "" {
+		fmt.Println("  Skipped, use the defaults flag to compare the configuration.")
+		return nil
+	}
+	data, name := packet.find("sanitized_config.json", "config.json")
+	if data == nil {
+		fmt.Println("  No configuration found.")
+		return nil
+	}
+	var config, defaults interface{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("Unable to parse %s: %s", name, err.Error())
+	}
+	defaultsData, err := readFile(defaultsFile)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(defaultsData, &defaults); err != nil {
+		return fmt.Errorf("Unable to parse %s: %s", defaultsFile, err.Error())
+	}
+
+	configSettings, defaultSettings := map[string]interface{}{}, map[string]interface{}{}
+	flattenSettings("", config, configSettings)
+	flattenSettings("", defaults, defaultSettings)
+	changes := configChanges(configSettings, defaultSettings)
+	for _, change := range changes {
+		fmt.Printf("  %s: %s (default %s)\n", change.Name, formatSettingValue(change.Value), formatSettingValue(change.Default))
+	}
+	if len(changes) == 0 {
+		fmt.Println("  No setting differs from the defaults.")
+	}
+	return nil
+}
+
+func printPacketErrors(packet *supportPacket, xeniaDir string, top int) {
+	messageIds := errorMessageIds(xeniaDir)
+	counts := map[string]int{}
+	for _, name := range packet.sortedNames() {
+		if !strings.HasSuffix(name, ".log") {
+			continue
+		}
+		for key, count := range countLogErrors(packet.files[name], messageIds) {
+			counts[key] += count
+		}
+	}
+
+	keys := []string{}
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) > top {
+		keys = keys[:top]
+	}
+	for _, key := range keys {
+		fmt.Printf("  %6d %s\n", counts[key], key)
+	}
+	if len(keys) == 0 {
+		fmt.Println("  No errors found in the logs.")
+	}
+}
+
+func supportPacketAnalyzeCmdF(command *cobra.Command, args []string) error {
+	defaultsFile, err := command.Flags().GetString("defaults")
+	if err != nil {
+		return errors.New("Invalid defaults parameter")
+	}
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	top, err := command.Flags().GetInt("top")
+	if err != nil {
+		return errors.New("Invalid top parameter")
+	}
+
+	packet, err := openSupportPacket(args[0])
+	if err != nil {
+		return err
+	}
+	command.SilenceUsage = true
+
+	fmt.Println("Server:")
+	if err := printPacketVersions(packet); err != nil {
+		return err
+	}
+	fmt.Println("\nPlugins:")
+	if err := printPacketPlugins(packet); err != nil {
+		return err
+	}
+	fmt.Println("\nConfiguration changes:")
+	if err := printPacketConfig(packet, defaultsFile); err != nil {
+		return err
+	}
+	fmt.Println("\nErrors:")
+	printPacketErrors(packet, xeniaDir, top)
+	return nil
+}
@@ -0,0 +1,414 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// testFailureOutputLines is the number of output lines kept for every
+// failed test.
+const testFailureOutputLines = 20
+
+var TestsCmd = &cobra.Command{
+	Use:   "tests",
+	Short: "Test results tooling",
+}
+
+var TestsReportCmd = &cobra.Command{
+	Use:   "report [file...]",
+	Short: "Summarize go test -json output",
+	Long: `Read the output of one or more "go test -json" runs, from the files or from the standard input, and summarize the failures and durations by package and test.
+
+A test that both passed and failed, in different runs or within one run with -count, is reported as flaky. With a CODEOWNERS file the owners of the package of every test are listed, so failures can be routed to their team.`,
+	Example: `  go test -json ./... | mmgotool dev tests report
+  dev tests report run1.json run2.json run3.json --format json --output report.json
+  dev tests report ci.json --codeowners .github/CODEOWNERS --repo-dir ../xenia-server`,
+	RunE: testsReportCmdF,
+}
+
+func init() {
+	TestsReportCmd.Flags().String("format", "markdown", "Output format: markdown or json")
+	TestsReportCmd.Flags().String("output", "", "Write the report to this file instead of the standard output")
+	TestsReportCmd.Flags().Int("slowest", 10, "Number of slowest tests to list")
+	TestsReportCmd.Flags().String("codeowners", "", "Path to a CODEOWNERS file to find the owners of the packages")
+	TestsReportCmd.Flags().String("repo-dir", "./", "Path to the repository of the tested module, to map the packages to their folder")
+	TestsCmd.AddCommand(TestsReportCmd)
+	DevCmd.AddCommand(TestsCmd)
+}
+
+// testEvent is a line of the go test -json output.
+type testEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+type testResult struct {
+	Package  string   `json:"package"`
+	Test     string   `json:"test"`
+	Passes   int      `json:"passes"`
+	Failures int      `json:"failures"`
+	Skips    int      `json:"skips"`
+	Total    float64  `json:"total_seconds"`
+	Max      float64  `json:"max_seconds"`
+	Owners   []string `json:"owners,omitempty"`
+	// Output is the end of the output of the last failure.
+	Output []string `json:"output,omitempty"`
+
+	output []string
+}
+
+func (r *testResult) Runs() int {
+	return r.Passes + r.Failures
+}
+
+func (r *testResult) Flaky() bool {
+	return r.Passes > 0 && r.Failures > 0
+}
+
+func (r *testResult) Average() float64 {
+	if r.Runs() == 0 {
+		return 0
+	}
+	return r.Total / float64(r.Runs())
+}
+
+type packageResult struct {
+	Package  string   `json:"package"`
+	Tests    int      `json:"tests"`
+	Failures int      `json:"failures"`
+	Flaky    int      `json:"flaky"`
+	Total    float64  `json:"total_seconds"`
+	Owners   []string `json:"owners,omitempty"`
+}
+
+type testsReport struct {
+	Runs     int              `json:"runs"`
+	Tests    int              `json:"tests"`
+	Failed   []*testResult    `json:"failed"`
+	Flaky    []*testResult    `json:"flaky"`
+	Slowest  []*testResult    `json:"slowest"`
+	Packages []*packageResult `json:"packages"`
+}
+
+// testResults aggregates the events of every run by test.
+type testResults struct {
+	tests    map[string]*testResult
+	packages map[string]*packageResult
+}
+
+func newTestResults() *testResults {
+	return &testResults{tests: map[string]*testResult{}, packages: map[string]*packageResult{}}
+}
+
+func (r *testResults) read(input io.Reader) error {
+	reader := bufio.NewReader(input)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var event testEvent
+			// Build errors and other text are mixed with the events.
+			if json.Unmarshal(line, &event) == nil && event.Package != "" {
+				r.add(event)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func (r *testResults) add(event testEvent) {
+	pkg := r.packages[event.Package]
+	if pkg == nil {
+		pkg = &packageResult{Package: event.Package}
+		r.packages[event.Package] = pkg
+	}
+	if event.Test == "" {
+		if event.Action == "pass" || event.Action == "fail" {
+			pkg.Total += event.Elapsed
+		}
+		return
+	}
+
+	key := event.Package + " " + event.Test
+	test := r.tests[key]
+	if test == nil {
+		test = &testResult{Package: event.Package, Test: event.Test}
+		r.tests[key] = test
+	}
+	switch event.Action {
+	case "run":
+		test.output = nil
+	case "output":
+		test.output = append(test.output, strings.TrimRight(event.Output, "\n"))
+		if len(test.output) > testFailureOutputLines {
+			test.output = test.output[len(test.output)-testFailureOutputLines:]
+		}
+	case "pass", "fail":
+		if event.Action == "pass" {
+			test.Passes++
+		} else {
+			test.Failures++
+			test.Output = test.output
+		}
+		test.Total += event.Elapsed
+		if event.Elapsed > test.Max {
+			test.Max = event.Elapsed
+		}
+	case "skip":
+		test.Skips++
+	}
+}
+
+// report sorts the results, the packages and tests failing the most first.
+func (r *testResults) report(runs, slowest int, owners *codeOwners) *testsReport {
+	report := &testsReport{Runs: runs, Failed: []*testResult{}, Flaky: []*testResult{}, Slowest: []*testResult{}, Packages: []*packageResult{}}
+	timed := []*testResult{}
+	for _, test := range r.tests {
+		pkg := r.packages[test.Package]
+		test.Owners = owners.packageOwners(test.Package)
+		pkg.Tests++
+		report.Tests++
+		switch {
+		case test.Flaky():
+			report.Flaky = append(report.Flaky, test)
+			pkg.Flaky++
+		case test.Failures > 0:
+			report.Failed = append(report.Failed, test)
+			pkg.Failures++
+		}
+		if test.Runs() > 0 {
+			timed = append(timed, test)
+		}
+	}
+	for _, pkg := range r.packages {
+		pkg.Owners = owners.packageOwners(pkg.Package)
+		report.Packages = append(report.Packages, pkg)
+	}
+
+	byFailures := func(tests []*testResult) {
+		sort.Slice(tests, func(i, j int) bool {
+			if tests[i].Failures != tests[j].Failures {
+				return tests[i].Failures > tests[j].Failures
+			}
+			if tests[i].Package != tests[j].Package {
+				return tests[i].Package < tests[j].Package
+			}
+			return tests[i].Test < tests[j].Test
+		})
+	}
+	byFailures(report.Failed)
+	byFailures(report.Flaky)
+	sort.Slice(timed, func(i, j int) bool { return timed[i].Average() > timed[j].Average() })
+	if len(timed) > slowest {
+		timed = timed[:slowest]
+	}
+	report.Slowest = timed
+	sort.Slice(report.Packages, func(i, j int) bool {
+		a, b := report.Packages[i], report.Packages[j]
+		if a.Failures+a.Flaky != b.Failures+b.Flaky {
+			return a.Failures+a.Flaky > b.Failures+b.Flaky
+		}
+		return a.Package < b.Package
+	})
+	return report
+}
+
+func (r *testsReport) markdown() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("## Test report\n\n")
+	fmt.Fprintf(&buf, "%d runs, %d tests, %d failed, %d flaky.\n\n", r.Runs, r.Tests, len(r.Failed), len(r.Flaky))
+
+	if len(r.Failed) > 0 {
+		buf.WriteString("### Failed tests\n\n| Package | Test | Failures | Owners |\n| --- | --- | --- | --- |\n")
+		for _, test := range r.Failed {
+			fmt.Fprintf(&buf, "| %s | %s | %d | %s |\n", test.Package, markdownCell(test.Test), test.Failures, strings.Join(test.Owners, " "))
+		}
+		buf.WriteString("\n")
+		for _, test := range r.Failed {
+			if len(test.Output) == 0 {
+				continue
+			}
+			fmt.Fprintf(&buf, "<details><summary>%s %s</summary>\n\n```\n%s\n```\n\n</details>\n\n", test.Package, test.Test, strings.Join(test.Output, "\n"))
+		}
+	}
+	if len(r.Flaky) > 0 {
+		buf.WriteString("### Flaky tests\n\n| Package | Test | Passes | Failures | Owners |\n| --- | --- | --- | --- | --- |\n")
+		for _, test := range r.Flaky {
+			fmt.Fprintf(&buf, "| %s | %s | %d | %d | %s |\n", test.Package, markdownCell(test.Test), test.Passes, test.Failures, strings.Join(test.Owners, " "))
+		}
+		buf.WriteString("\n")
+	}
+	if len(r.Packages) > 0 {
+		buf.WriteString("### Packages\n\n| Package | Tests | Failed | Flaky | Duration | Owners |\n| --- | --- | --- | --- | --- | --- |\n")
+		for _, pkg := range r.Packages {
+			fmt.Fprintf(&buf, "| %s | %d | %d | %d | %s | %s |\n", pkg.Package, pkg.Tests, pkg.Failures, pkg.Flaky, formatSeconds(pkg.Total), strings.Join(pkg.Owners, " "))
+		}
+		buf.WriteString("\n")
+	}
+	if len(r.Slowest) > 0 {
+		buf.WriteString("### Slowest tests\n\n| Package | Test | Average | Max |\n| --- | --- | --- | --- |\n")
+		for _, test := range r.Slowest {
+			fmt.Fprintf(&buf, "| %s | %s | %s | %s |\n", test.Package, markdownCell(test.Test), formatSeconds(test.Average()), formatSeconds(test.Max))
+		}
+		buf.WriteString("\n")
+	}
+	return buf.Bytes()
+}
+
+func formatSeconds(seconds float64) string {
+	return time.Duration(seconds * float64(time.Second)).Round(time.Millisecond).String()
+}
+
+// codeOwners resolves the owners of the packages from a CODEOWNERS file,
+// the last matching pattern wins.
+type codeOwners struct {
+	module string
+	rules  []codeOwnersRule
+}
+
+type codeOwnersRule struct {
+	matcher *ignoreMatcher
+	owners  []string
+}
+
+func loadCodeOwners(path, repoDir string) (*codeOwners, error) {
+	data, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	owners := &codeOwners{module: goModulePath(repoDir)}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		matcher := &ignoreMatcher{}
+		matcher.addPattern("", fields[0])
+		owners.rules = append(owners.rules, codeOwnersRule{matcher: matcher, owners: fields[1:]})
+	}
+	return owners, nil
+}
+
+// goModulePath returns the module path declared by the go.mod file of the
+// folder, empty without one.
+func goModulePath(dir string) string {
+	data, err := readFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`)
+		}
+	}
+	return ""
+}
+
+func (o *codeOwners) packageOwners(pkg string) []string {
+	if o == nil {
+		return nil
+	}
+	rel := pkg
+	if o.module != "" {
+		rel = strings.TrimPrefix(strings.TrimPrefix(pkg, o.module), "/")
+	}
+	for i := len(o.rules) - 1; i >= 0; i-- {
+		if o.rules[i].matcher.ignored(rel, true) {
+			return o.rules[i].owners
+		}
+	}
+	return nil
+}
+
+func testsReportCmdF(command *cobra.Command, args []string) error {
+	format, err := command.Flags().GetString("format")
+	if err != nil {
+		return errors.New("Invalid format parameter")
+	}
+	if format != "markdown" && format != "json" {
+		return fmt.Errorf("Unknown format %s", format)
+	}
+	output, err := command.Flags().GetString("output")
+	if err != nil {
+		return errors.New("Invalid output parameter")
+	}
+	slowest, err := command.Flags().GetInt("slowest")
+	if err != nil || slowest < 0 {
+		return errors.New("Invalid slowest parameter")
+	}
+	codeOwnersFile, err := command.Flags().GetString("codeowners")
+	if err != nil {
+		return errors.New("Invalid codeowners parameter")
+	}
+	repoDir, err := command.Flags().GetString("repo-dir")
+	if err != nil {
+		return errors.New("Invalid repo-dir parameter")
+	}
+	command.SilenceUsage = true
+
+	var owners *codeOwners
+	if codeOwnersFile != "" {
+		if owners, err = loadCodeOwners(codeOwnersFile, repoDir); err != nil {
+			return err
+		}
+	}
+
+	results := newTestResults()
+	if len(args) == 0 {
+		args = []string{"-"}
+	}
+	for _, arg := range args {
+		if arg == "-" {
+			err = results.read(os.Stdin)
+		} else {
+			var f *os.File
+			if f, err = openFile(arg); err != nil {
+				return err
+			}
+			err = results.read(f)
+			f.Close()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(results.packages) == 0 {
+		return errors.New("No go test -json event found in the input.")
+	}
+
+	report := results.report(len(args), slowest, owners)
+	var data []byte
+	if format == "json" {
+		if data, err = json.MarshalIndent(report, "", "  "); err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	} else {
+		data = report.markdown()
+	}
+	if output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return writeFile(output, data, 0644)
+}
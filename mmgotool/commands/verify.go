@@ -0,0 +1,178 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultVerifyChecks run when the configuration file has no verify
+// section.
+var defaultVerifyChecks = []verifyCheck{
+	{Name: "i18n", Args: []string{"i18n", "check"}},
+	{Name: "license", Args: []string{"lint", "license", "check"}},
+}
+
+var VerifyAllCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Run every configured check",
+	Long: `Run the checks listed in the verify section of the .mmgotool.yaml file, in parallel, and report their results together.
+Each check is an mmgotool command line run from the xenia-dir. Without configuration the i18n and license checks are run.
+
+  verify:
+    jobs: 4
+    checks:
+      - name: i18n
+        args: [i18n, check, --enterprise-dir, ../enterprise]
+      - name: license
+        args: [lint, license, check]`,
+	Example: "  lint verify --xenia-dir ../xenia-server --check i18n",
+	RunE:    verifyAllCmdF,
+}
+
+func init() {
+	VerifyAllCmd.Flags().String("xenia-dir", "./", "Path to folder with the Xenia source code")
+	VerifyAllCmd.Flags().StringArray("check", []string{}, "Name of a check to run, can be repeated, defaults to all the checks")
+	VerifyAllCmd.Flags().Int("jobs", 0, "Number of checks run at the same time, defaults to the configuration or the number of CPUs")
+	LintCmd.AddCommand(VerifyAllCmd)
+}
+
+type verifyResult struct {
+	Check    verifyCheck
+	Duration time.Duration
+	Output   string
+	Err      error
+}
+
+func runVerifyCheck(executable, xeniaDir string, check verifyCheck) verifyResult {
+	start := time.Now()
+	cmd := execSelf(executable, check.Args...)
+	cmd.Dir = xeniaDir
+	output, err := cmd.CombinedOutput()
+	return verifyResult{Check: check, Duration: time.Since(start), Output: string(output), Err: err}
+}
+
+func selectVerifyChecks(checks []verifyCheck, names []string) ([]verifyCheck, error) {
+	if len(names) == 0 {
+		return checks, nil
+	}
+	selected := []verifyCheck{}
+	for _, name := range names {
+		found := false
+		for _, check := range checks {
+			if check.Name == name {
+				selected = append(selected, check)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("Unknown check %s", name)
+		}
+	}
+	return selected, nil
+}
+
+// configuredVerifyChecks returns the checks of the configuration, or the
+// default ones, selected by name.
+func configuredVerifyChecks(config *toolConfig, names []string) ([]verifyCheck, error) {
+	checks := config.Verify.Checks
+	if len(checks) == 0 {
+		checks = defaultVerifyChecks
+	}
+	return selectVerifyChecks(checks, names)
+}
+
+// runVerifyChecks runs the checks in parallel, with the jobs of the command
+// line, of the configuration or the number of CPUs.
+func runVerifyChecks(xeniaDir string, checks []verifyCheck, jobs, configJobs int) ([]verifyResult, error) {
+	if jobs < 1 {
+		jobs = configJobs
+	}
+	if jobs < 1 {
+		jobs = runtime.GOMAXPROCS(0)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]verifyResult, len(checks))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range indexes {
+				results[idx] = runVerifyCheck(executable, xeniaDir, checks[idx])
+			}
+		}()
+	}
+	for idx := range checks {
+		indexes <- idx
+	}
+	close(indexes)
+	wg.Wait()
+	return results, nil
+}
+
+func verifyAllCmdF(command *cobra.Command, args []string) error {
+	xeniaDir, err := command.Flags().GetString("xenia-dir")
+	if err != nil {
+		return errors.New("Invalid xenia-dir parameter")
+	}
+	names, err := command.Flags().GetStringArray("check")
+	if err != nil {
+		return errors.New("Invalid check parameter")
+	}
+	jobs, err := command.Flags().GetInt("jobs")
+	if err != nil {
+		return errors.New("Invalid jobs parameter")
+	}
+
+	config, err := loadToolConfig(xeniaDir)
+	if err != nil {
+		return err
+	}
+	checks, err := configuredVerifyChecks(config, names)
+	if err != nil {
+		return err
+	}
+	command.SilenceUsage = true
+
+	results, err := runVerifyChecks(xeniaDir, checks, jobs, config.Verify.Jobs)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, result := range results {
+		status := "PASS"
+		if result.Err != nil {
+			status = "FAIL"
+			failed++
+		}
+		fmt.Printf("%s  %-20s %s\n", status, result.Check.Name, result.Duration.Round(time.Millisecond))
+	}
+	for _, result := range results {
+		if result.Err == nil {
+			continue
+		}
+		fmt.Printf("\n--- %s: mmgotool %s\n", result.Check.Name, strings.Join(result.Check.Args, " "))
+		fmt.Print(result.Output)
+	}
+
+	if failed > 0 {
+		return &ExitError{Code: 1, Err: fmt.Errorf("%d of %d checks failed.", failed, len(results))}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/spf13/cobra"
+)
+
+// versionPackage is the package of the build variables, for the -X flags of
+// the linker.
+const versionPackage = "github.com/xzl8028/xenia-utilities/mmgotool/commands"
+
+// Version, Commit and BuildDate describe the build, set with the linker flags
+// of the mmgotool target of the generated Makefile fragment:
+//
+//	go build -ldflags "-X github.com/xzl8028/xenia-utilities/mmgotool/commands.Version=5.3.0"
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+var VersionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version of mmgotool",
+	Long:  "Print the version, the commit and the build date of mmgotool. The builds made without the linker flags fall back on the commit recorded by the Go toolchain, when there is one.",
+	Example: `  version
+  version --format json`,
+	Args: cobra.NoArgs,
+	RunE: versionCmdF,
+}
+
+func init() {
+	VersionCmd.Flags().String("format", "text", "Output format: text or json")
+	RootCmd.AddCommand(VersionCmd)
+}
+
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// currentBuildInfo returns the description of the running binary.
+func currentBuildInfo() buildInfo {
+	info := buildInfo{Version: Version, Commit: Commit, BuildDate: BuildDate, GoVersion: runtime.Version(), Platform: runtime.GOOS + "/" + runtime.GOARCH}
+	if info.Commit != "" {
+		return info
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Commit = setting.Value
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			}
+		}
+	}
+	return info
+}
+
+func versionCmdF(command *cobra.Command, args []string) error {
+	format, err := command.Flags().GetString("format")
+	if err != nil {
+		return errors.New("Invalid format parameter")
+	}
+	if format != "text" && format != "json" {
+		return fmt.Errorf("Unknown format %s", format)
+	}
+
+	info := currentBuildInfo()
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(info)
+	}
+	fmt.Printf("%s %s\n", binaryName, info.Version)
+	if info.Commit != "" {
+		fmt.Println("Commit:", info.Commit)
+	}
+	if info.BuildDate != "" {
+		fmt.Println("Build date:", info.BuildDate)
+	}
+	fmt.Println("Go version:", info.GoVersion)
+	fmt.Println("Platform:", info.Platform)
+	return nil
+}
@@ -0,0 +1,497 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/xzl8028/xenia-utilities/mmgotool/pkg/i18nextract"
+)
+
+const defaultTelemetrySchemaFile = "telemetry_schema.json"
+
+// telemetryCall is the position of the event name and of the properties in
+// the arguments of a telemetry function.
+type telemetryCall struct {
+	Event      int
+	Properties int
+}
+
+// telemetryServerFunctions are the server functions sending an event, like
+// SendTelemetry(TrackConfigService, map[string]interface{}{...}).
+var telemetryServerFunctions = map[string]telemetryCall{
+	"SendTelemetry": {Event: 0, Properties: 1},
+}
+
+// telemetryWebappFunctions are the webapp functions sending an event, like
+// trackEvent('admin', 'click_save', {section}).
+var telemetryWebappFunctions = map[string]telemetryCall{
+	"trackEvent": {Event: 1, Properties: 2},
+}
+
+var VetTelemetryCmd = &cobra.Command{
+	Use:   "vet-telemetry",
+	Short: "Check the telemetry events against the approved schema",
+	Long: `Extract the event and property names of the SendTelemetry calls of the server and of the trackEvent calls of the webapp, and check them against the approved telemetry schema. Events and properties missing from the schema are reported, with the closest approved name when they look like a typo.
+
+The schema is a json file listing the approved events and their properties:
+
+  {
+    "events": {
+      "config_service": {"properties": ["enable_security_fix_alert", "enable_insecure_outgoing_connections"]},
+      "click_save": {"properties": ["section"]}
+    }
+  }
+
+Event names passed through variables and properties passed through variables are not checked. Constants of the server are resolved.
+
+Only the classes of --fail-on fail the check, the others are reported as warnings.
+
+Exit codes:
+` + vetTelemetryClasses.exitCodesHelp(),
+	Example: `  lint vet-telemetry --xenia-dir ../xenia-server --webapp-dir ../xenia-webapp
+  lint vet-telemetry --schema ../telemetry/schema.json --unused`,
+	RunE: runCheck(vetTelemetryCmdF),
+}
+
+func init() {
+	addExtractFlags(VetTelemetryCmd)
+	VetTelemetryCmd.Flags().String("schema", defaultTelemetrySchemaFile, "Path to the approved telemetry schema, relative to the xenia-dir")
+	VetTelemetryCmd.Flags().Bool("unused", false, "Also report the approved events no longer sent")
+	addFindingsFormatFlag(VetTelemetryCmd)
+	vetTelemetryClasses.addFailOnFlag(VetTelemetryCmd, vetTelemetryClasses.names())
+	LintCmd.AddCommand(VetTelemetryCmd)
+}
+
+type telemetrySchemaEvent struct {
+	Description string   `json:"description,omitempty"`
+	Properties  []string `json:"properties"`
+}
+
+type telemetrySchema struct {
+	Events map[string]telemetrySchemaEvent `json:"events"`
+}
+
+// telemetryEvent is an event sent by the source code.
+type telemetryEvent struct {
+	Position   token.Position
+	Name       string
+	Properties []string
+}
+
+// telemetryConstants returns the string constants of the Go files, by name.
+// The packages aren't told apart, the names of the telemetry constants are
+// unique enough.
+func telemetryConstants(files []*ast.File) map[string]string {
+	constants := map[string]string{}
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.CONST {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				value, ok := spec.(*ast.ValueSpec)
+				if !ok || len(value.Names) != len(value.Values) {
+					continue
+				}
+				for i, name := range value.Names {
+					if text, ok := stringLiteral(value.Values[i]); ok {
+						constants[name.Name] = text
+					}
+				}
+			}
+		}
+	}
+	return constants
+}
+
+// telemetryName returns the value of a literal or of a string constant.
+func telemetryName(expr ast.Expr, constants map[string]string) (string, bool) {
+	if text, ok := stringLiteral(expr); ok {
+		return text, true
+	}
+	var name string
+	switch e := expr.(type) {
+	case *ast.Ident:
+		name = e.Name
+	case *ast.SelectorExpr:
+		name = e.Sel.Name
+	default:
+		return "", false
+	}
+	text, ok := constants[name]
+	return text, ok
+}
+
+// serverTelemetryEvents returns the events of the telemetry calls of the Go
+// file.
+func serverTelemetryEvents(fset *token.FileSet, f *ast.File, constants map[string]string) []telemetryEvent {
+	events := []telemetryEvent{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		name := ""
+		switch fun := call.Fun.(type) {
+		case *ast.SelectorExpr:
+			name = fun.Sel.Name
+		case *ast.Ident:
+			name = fun.Name
+		}
+		function, ok := telemetryServerFunctions[name]
+		if !ok || len(call.Args) <= function.Event {
+			return true
+		}
+		event, ok := telemetryName(call.Args[function.Event], constants)
+		if !ok {
+			logger.Debug("Skipping telemetry event passed through a variable", "position", fset.Position(call.Pos()).String())
+			return true
+		}
+		properties := []string{}
+		if len(call.Args) > function.Properties {
+			if lit, ok := call.Args[function.Properties].(*ast.CompositeLit); ok {
+				for _, elt := range lit.Elts {
+					kv, ok := elt.(*ast.KeyValueExpr)
+					if !ok {
+						continue
+					}
+					if property, ok := telemetryName(kv.Key, constants); ok {
+						properties = append(properties, property)
+					}
+				}
+			}
+		}
+		events = append(events, telemetryEvent{Position: fset.Position(call.Pos()), Name: event, Properties: properties})
+		return true
+	})
+	return events
+}
+
+// webappTelemetryEvents returns the events of the telemetry calls of the
+// webapp source.
+func webappTelemetryEvents(filePath string, src []byte) []telemetryEvent {
+	tokens := i18nextract.LexJS(src)
+	events := []telemetryEvent{}
+	for i := 0; i+1 < len(tokens); i++ {
+		function, ok := telemetryWebappFunctions[tokens[i].Text]
+		if !ok || !tokens[i].Ident || tokens[i+1].Text != "(" || (i > 0 && tokens[i-1].Text == "function") {
+			continue
+		}
+		args := jsCallArguments(tokens, i+1)
+		position := token.Position{Filename: filePath, Line: tokens[i].Line}
+		if len(args) <= function.Event {
+			continue
+		}
+		event := args[function.Event]
+		if len(event) != 1 || !event[0].String {
+			logger.Debug("Skipping telemetry event passed through a variable", "position", position.String())
+			continue
+		}
+		properties := []string{}
+		if len(args) > function.Properties {
+			properties = jsObjectKeys(args[function.Properties])
+		}
+		events = append(events, telemetryEvent{Position: position, Name: event[0].Text, Properties: properties})
+	}
+	return events
+}
+
+// jsCallArguments splits the tokens of the arguments of the call whose
+// parenthesis is at the index.
+func jsCallArguments(tokens []i18nextract.JSToken, paren int) [][]i18nextract.JSToken {
+	args := [][]i18nextract.JSToken{}
+	current := []i18nextract.JSToken{}
+	depth := 0
+	for i := paren; i < len(tokens); i++ {
+		t := tokens[i]
+		if !t.Ident && !t.String {
+			switch t.Text {
+			case "(", "{", "[":
+				depth++
+				if depth == 1 {
+					continue
+				}
+			case ")", "}", "]":
+				depth--
+				if depth == 0 {
+					if len(current) > 0 {
+						args = append(args, current)
+					}
+					return args
+				}
+			case ",":
+				if depth == 1 {
+					args = append(args, current)
+					current = []i18nextract.JSToken{}
+					continue
+				}
+			}
+		}
+		current = append(current, t)
+	}
+	return args
+}
+
+// jsObjectKeys returns the keys of an object literal, shorthand properties
+// included. Spread and computed keys are skipped.
+func jsObjectKeys(tokens []i18nextract.JSToken) []string {
+	keys := []string{}
+	if len(tokens) == 0 || tokens[0].Text != "{" || tokens[0].String {
+		return keys
+	}
+	depth := 0
+	expectKey := true
+	for i, t := range tokens {
+		if !t.Ident && !t.String {
+			if depth == 1 {
+				expectKey = t.Text == ","
+			}
+			switch t.Text {
+			case "{", "(", "[":
+				depth++
+			case "}", ")", "]":
+				depth--
+			}
+			continue
+		}
+		if depth != 1 || !expectKey {
+			continue
+		}
+		expectKey = false
+		if i+1 < len(tokens) && (tokens[i+1].Text == ":" || (t.Ident && (tokens[i+1].Text == "," || tokens[i+1].Text == "}"))) {
+			keys = append(keys, t.Text)
+		}
+	}
+	return keys
+}
+
+// extractTelemetryEvents returns the events sent by the server and webapp
+// sources.
+func extractTelemetryEvents(opts *extractOptions) ([]telemetryEvent, error) {
+	fset := token.NewFileSet()
+	files := []*ast.File{}
+	var walkErr error
+	walkSourceFiles(opts, func(p string) {
+		if walkErr != nil {
+			return
+		}
+		src, err := readFile(p)
+		if err != nil {
+			walkErr = err
+			return
+		}
+		f, err := parser.ParseFile(fset, p, src, 0)
+		if err != nil {
+			walkErr = err
+			return
+		}
+		files = append(files, f)
+	})
+	if walkErr != nil {
+		return nil, walkErr
+	}
+
+	constants := telemetryConstants(files)
+	events := []telemetryEvent{}
+	for _, f := range files {
+		events = append(events, serverTelemetryEvents(fset, f, constants)...)
+	}
+	walkWebappFiles(opts, func(p string) {
+		if walkErr != nil {
+			return
+		}
+		src, err := readFile(p)
+		if err != nil {
+			walkErr = err
+			return
+		}
+		events = append(events, webappTelemetryEvents(p, src)...)
+	})
+	if walkErr != nil {
+		return nil, walkErr
+	}
+	return events, nil
+}
+
+// editDistance returns the Levenshtein distance between the names.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(minInt(previous[j]+1, current[j-1]+1), previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// closestName returns the candidate a typo of the name would give: at most
+// two edits away, and fewer than a third of the name.
+func closestName(name string, candidates []string) (string, bool) {
+	best, bestDistance := "", 3
+	for _, candidate := range candidates {
+		distance := editDistance(name, candidate)
+		if distance < bestDistance && distance*3 < len(name) {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best, best != ""
+}
+
+// unregisteredMessage suggests the closest candidate to the name in the
+// message.
+func unregisteredMessage(message, name string, candidates []string) string {
+	if closest, ok := closestName(name, candidates); ok {
+		message += fmt.Sprintf(", did you mean %q?", closest)
+	}
+	return message
+}
+
+var (
+	telemetryEventRule    = registerFindingRule(findingRule{Id: "telemetry/unregistered-event", Description: "The telemetry event is not in the schema", Command: "lint vet-telemetry"})
+	telemetryPropertyRule = registerFindingRule(findingRule{Id: "telemetry/unregistered-property", Description: "The property of the telemetry event is not in the schema", Command: "lint vet-telemetry"})
+	telemetryUnusedRule   = registerFindingRule(findingRule{Id: "telemetry/unused-event", Description: "The approved telemetry event is no longer sent", Command: "lint vet-telemetry"})
+)
+
+// vetTelemetryClasses are the classes of lint vet-telemetry.
+var vetTelemetryClasses = checkClassSet{
+	{Name: "event", Description: "events missing from the schema", ExitCode: 3, Message: "Telemetry events are missing from the schema."},
+	{Name: "property", Description: "properties of approved events missing from the schema", ExitCode: 4, Message: "Properties of telemetry events are missing from the schema."},
+	{Name: "unused", Description: "approved events no longer sent, with --unused", ExitCode: 5, Message: "Approved telemetry events are no longer sent."},
+}
+
+func telemetryFinding(rule findingRule, class string, position token.Position, message string) finding {
+	return finding{Rule: rule, Message: message, File: findingPath(position.Filename), Line: position.Line, Column: position.Column, Text: fmt.Sprintf("%s: %s", position, message), Class: class}
+}
+
+// checkTelemetryEvents returns the problems of the events missing from the
+// schema or having properties missing from it.
+func checkTelemetryEvents(events []telemetryEvent, schema *telemetrySchema) []finding {
+	approved := []string{}
+	for name := range schema.Events {
+		approved = append(approved, name)
+	}
+	sort.Strings(approved)
+
+	problems := []finding{}
+	for _, event := range events {
+		schemaEvent, ok := schema.Events[event.Name]
+		if !ok {
+			problems = append(problems, telemetryFinding(telemetryEventRule, "event", event.Position, unregisteredMessage(fmt.Sprintf("unregistered event %q", event.Name), event.Name, approved)))
+			continue
+		}
+		properties := map[string]bool{}
+		for _, property := range schemaEvent.Properties {
+			properties[property] = true
+		}
+		for _, property := range event.Properties {
+			if !properties[property] {
+				problems = append(problems, telemetryFinding(telemetryPropertyRule, "property", event.Position, unregisteredMessage(fmt.Sprintf("unregistered property %q of event %q", property, event.Name), property, schemaEvent.Properties)))
+			}
+		}
+	}
+	return problems
+}
+
+func readTelemetrySchema(path string) (*telemetrySchema, error) {
+	data, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	schema := &telemetrySchema{}
+	if err := json.Unmarshal(data, schema); err != nil {
+		return nil, fmt.Errorf("Unable to parse %s: %s", path, err.Error())
+	}
+	return schema, nil
+}
+
+func vetTelemetryCmdF(command *cobra.Command, args []string) error {
+	opts, err := getExtractOptions(command)
+	if err != nil {
+		return err
+	}
+	schemaFile, err := command.Flags().GetString("schema")
+	if err != nil {
+		return errors.New("Invalid schema parameter")
+	}
+	if !filepath.IsAbs(schemaFile) {
+		schemaFile = filepath.Join(opts.XeniaDir, schemaFile)
+	}
+	unused, err := command.Flags().GetBool("unused")
+	if err != nil {
+		return errors.New("Invalid unused parameter")
+	}
+	reporter, err := getFindingsReporter(command)
+	if err != nil {
+		return err
+	}
+	failOn, err := vetTelemetryClasses.getCheckPolicy(command)
+	if err != nil {
+		return err
+	}
+	command.SilenceUsage = true
+
+	schema, err := readTelemetrySchema(schemaFile)
+	if err != nil {
+		return err
+	}
+	events, err := extractTelemetryEvents(opts)
+	if err != nil {
+		return err
+	}
+
+	problems := checkTelemetryEvents(events, schema)
+	if unused {
+		sent := map[string]bool{}
+		for _, event := range events {
+			sent[event.Name] = true
+		}
+		names := []string{}
+		for name := range schema.Events {
+			if !sent[name] {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		lines := catalogIdLines(schemaFile)
+		for _, name := range names {
+			message := fmt.Sprintf("approved event %q is no longer sent", name)
+			problems = append(problems, finding{Rule: telemetryUnusedRule, Message: message, File: findingPath(schemaFile), Line: lines[name], Text: fmt.Sprintf("%s: %s", schemaFile, message), Class: "unused"})
+		}
+	}
+	counts := failOn.Report(reporter, problems)
+	if err := reporter.Flush(); err != nil {
+		return err
+	}
+	if len(problems) == 0 {
+		reporter.Printf("The %d telemetry events match the schema.\n", len(events))
+	}
+	return failOn.Result(counts)
+}
@@ -0,0 +1,232 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+// Package codegen is the shared core of the mmgotool code generators. It
+// renders embedded templates, injects the license and "generated" header,
+// formats the result like goimports and can compare the output with the
+// checked-in files.
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/imports"
+)
+
+const headerTemplate = `// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+// Code generated by "mmgotool %s". DO NOT EDIT.
+
+`
+
+// Engine renders the templates of one generator.
+type Engine struct {
+	Generator string
+	templates *template.Template
+}
+
+// New parses the templates matching patterns from fsys. Generator is the
+// command name written in the header of every generated file.
+func New(generator string, fsys fs.FS, patterns ...string) (*Engine, error) {
+	templates, err := template.New(generator).Funcs(Funcs).ParseFS(fsys, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	return &Engine{Generator: generator, templates: templates}, nil
+}
+
+// Funcs are the helpers available to every template. They never depend on
+// map iteration order so the output is deterministic.
+var Funcs = template.FuncMap{
+	"join":       strings.Join,
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"quote":      strconv.Quote,
+	"export":     Export,
+	"unexport":   Unexport,
+	"sortedKeys": SortedKeys,
+}
+
+// Render executes the named template and returns the formatted Go source
+// with the generated code header.
+func (e *Engine) Render(name string, data interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, headerTemplate, e.Generator)
+	if err := e.templates.ExecuteTemplate(&buf, name, data); err != nil {
+		return nil, err
+	}
+	return FormatSource(buf.Bytes())
+}
+
+// RenderRaw executes the named template without the Go header and without
+// formatting, for generators emitting non Go files.
+func (e *Engine) RenderRaw(name string, data interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := e.templates.ExecuteTemplate(&buf, name, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteFile renders the named template into path.
+func (e *Engine) WriteFile(path, name string, data interface{}) error {
+	src, err := e.Render(name, data)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, src, 0644)
+}
+
+// Check renders the named template and reports whether path already contains
+// exactly the same output.
+func (e *Engine) Check(path, name string, data interface{}) (bool, error) {
+	src, err := e.Render(name, data)
+	if err != nil {
+		return false, err
+	}
+	return MatchesFile(path, src)
+}
+
+// MatchesFile reports whether the file at path has the expected content. A
+// missing file never matches.
+func MatchesFile(path string, expected []byte) (bool, error) {
+	current, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(current, expected), nil
+}
+
+// formatOptions format the imports without adding the missing ones, which
+// would look them up in GOPATH and the module cache and run go.
+var formatOptions = &imports.Options{FormatOnly: true, Comments: true, TabIndent: true, TabWidth: 8}
+
+// FormatSource formats src like goimports: the unused imports are removed
+// and the imports grouped and sorted. The missing imports are never added,
+// the templates list them, so the output only depends on src and not on the
+// packages of the machine.
+func FormatSource(src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("generated code does not parse: %s", err.Error())
+	}
+	if removeUnusedImports(fset, f) {
+		var buf bytes.Buffer
+		if err := format.Node(&buf, fset, f); err != nil {
+			return nil, err
+		}
+		src = buf.Bytes()
+	}
+	formatted, err := imports.Process("", src, formatOptions)
+	if err != nil {
+		return nil, fmt.Errorf("generated code does not parse: %s", err.Error())
+	}
+	return formatted, nil
+}
+
+// removeUnusedImports deletes the imports whose name is never used by the
+// file, like the imports of the sources of a generator which the generated
+// code doesn't need.
+func removeUnusedImports(fset *token.FileSet, f *ast.File) bool {
+	used := map[string]bool{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			// The package names are the identifiers the parser can't
+			// resolve.
+			if x, ok := sel.X.(*ast.Ident); ok && x.Obj == nil {
+				used[x.Name] = true
+			}
+		}
+		return true
+	})
+	removed := false
+	for _, spec := range append([]*ast.ImportSpec{}, f.Imports...) {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := ""
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if name == "_" || name == "." || (name != "" && used[name]) || (name == "" && used[assumedPackageName(importPath)]) {
+			continue
+		}
+		removed = astutil.DeleteNamedImport(fset, f, name, importPath) || removed
+	}
+	return removed
+}
+
+// assumedPackageName guesses the name of the package of an import path
+// without reading it, like goimports: the last element of the path without
+// its major version, its go- prefix and what follows the identifier, like
+// yaml for gopkg.in/yaml.v2.
+func assumedPackageName(importPath string) string {
+	base := path.Base(importPath)
+	if strings.HasPrefix(base, "v") {
+		if _, err := strconv.Atoi(base[1:]); err == nil && path.Dir(importPath) != "." {
+			base = path.Base(path.Dir(importPath))
+		}
+	}
+	base = strings.TrimPrefix(base, "go-")
+	if i := strings.IndexFunc(base, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}); i >= 0 {
+		base = base[:i]
+	}
+	return base
+}
+
+// Export upper cases the first letter of name.
+func Export(name string) string {
+	if name == "" {
+		return name
+	}
+	runes := []rune(name)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+// Unexport lower cases the first letter of name.
+func Unexport(name string) string {
+	if name == "" {
+		return name
+	}
+	runes := []rune(name)
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
+
+// SortedKeys returns the keys of a string keyed map in order.
+func SortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package codegen
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden files with the current output")
+
+func TestRenderGolden(t *testing.T) {
+	tests := []struct {
+		name string
+		data interface{}
+	}{
+		{
+			name: "imports.go",
+			data: map[string]interface{}{"Package": "settings"},
+		},
+		{
+			name: "funcs.go",
+			data: map[string]interface{}{
+				"Package":  "settings",
+				"Settings": map[string]string{"siteName": "site_name", "enableSignup": "enable_signup"},
+			},
+		},
+	}
+
+	engine, err := New("codegen test", os.DirFS("testdata"), "*.tmpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			src, err := engine.Render(test.name, test.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", test.name+".golden")
+			if *update {
+				if err := ioutil.WriteFile(golden, src, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			expected, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(src, expected) {
+				t.Errorf("%s differs from %s, run go test -update to accept it:\n%s", test.name, golden, src)
+			}
+		})
+	}
+}
+
+func TestFormatSourceInvalid(t *testing.T) {
+	if _, err := FormatSource([]byte("package p\n\nfunc {\n")); err == nil {
+		t.Error("expected an error for a source not parsing")
+	}
+}
+
+func TestFormatSourceMissingImport(t *testing.T) {
+	src := []byte("package p\n\nfunc Upper(s string) string {\n\treturn strings.ToUpper(s)\n}\n")
+	formatted, err := FormatSource(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(formatted, src) {
+		t.Errorf("the missing strings import should not be added:\n%s", formatted)
+	}
+}
+
+func TestAssumedPackageName(t *testing.T) {
+	tests := map[string]string{
+		"fmt":                              "fmt",
+		"net/http":                         "http",
+		"gopkg.in/yaml.v2":                 "yaml",
+		"github.com/go-sql-driver/mysql":   "mysql",
+		"github.com/xzl8028/go-i18n/i18n":  "i18n",
+		"github.com/xzl8028/ldap-client":   "ldap",
+		"github.com/go-redis/redis/v8":     "redis",
+		"github.com/stretchr/testify/mock": "mock",
+	}
+	for importPath, expected := range tests {
+		if name := assumedPackageName(importPath); name != expected {
+			t.Errorf("%s: name %s, expected %s", importPath, name, expected)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "codegen-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	engine, err := New("codegen test", os.DirFS("testdata"), "*.tmpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]interface{}{"Package": "settings"}
+	output := filepath.Join(dir, "settings", "imports.go")
+
+	if upToDate, err := engine.Check(output, "imports.go", data); err != nil || upToDate {
+		t.Fatalf("got %t, %v for a missing file, want false, nil", upToDate, err)
+	}
+	if err := engine.WriteFile(output, "imports.go", data); err != nil {
+		t.Fatal(err)
+	}
+	if upToDate, err := engine.Check(output, "imports.go", data); err != nil || !upToDate {
+		t.Fatalf("got %t, %v for the written file, want true, nil", upToDate, err)
+	}
+	if err := ioutil.WriteFile(output, []byte("package settings\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if upToDate, err := engine.Check(output, "imports.go", data); err != nil || upToDate {
+		t.Fatalf("got %t, %v for a modified file, want false, nil", upToDate, err)
+	}
+}
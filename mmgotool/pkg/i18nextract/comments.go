@@ -0,0 +1,46 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package i18nextract
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+const translatorCommentPrefix = "i18n:"
+
+// translatorComments returns the text of the "// i18n:" comments in the
+// file keyed by the line they apply to: the line following a comment on its
+// own lines, or the line of a trailing comment. Comment lines following the
+// prefixed one in the same group are appended to the text.
+func translatorComments(fset *token.FileSet, f *ast.File, src []byte) map[int]string {
+	comments := map[int]string{}
+	for _, group := range f.Comments {
+		lines := []string{}
+		for _, c := range group.List {
+			text := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+			if len(lines) == 0 {
+				if !strings.HasPrefix(text, translatorCommentPrefix) {
+					continue
+				}
+				text = strings.TrimSpace(strings.TrimPrefix(text, translatorCommentPrefix))
+			}
+			if text != "" {
+				lines = append(lines, text)
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		start := fset.Position(group.Pos())
+		line := fset.Position(group.End()).Line
+		lineStart := start.Offset - start.Column + 1
+		if strings.TrimSpace(string(src[lineStart:start.Offset])) == "" {
+			line++
+		}
+		comments[line] = strings.Join(lines, " ")
+	}
+	return comments
+}
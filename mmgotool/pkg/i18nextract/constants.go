@@ -0,0 +1,167 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package i18nextract
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+)
+
+// fileStrings returns the values of the package-level string constants of a
+// file, and of its package-level string variables it never assigns, by
+// name, so the translation calls passing them as id are extracted:
+//
+//	var errKey = "api.user.banned.app_error"
+//
+//	return model.NewAppError("Login", errKey, nil, "", http.StatusForbidden)
+//
+// The values may concatenate string literals and other such names. The names
+// declared again in a function are left out, the calls using them may refer
+// to the local declaration.
+func fileStrings(f *ast.File) map[string]string {
+	values := map[string]ast.Expr{}
+	variables := map[string]bool{}
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || (gen.Tok != token.CONST && gen.Tok != token.VAR) {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			valueSpec, ok := spec.(*ast.ValueSpec)
+			if !ok || len(valueSpec.Names) != len(valueSpec.Values) {
+				continue
+			}
+			for i, name := range valueSpec.Names {
+				values[name.Name] = valueSpec.Values[i]
+				variables[name.Name] = gen.Tok == token.VAR
+			}
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	// redeclared are the names declared in functions, assigned the
+	// variables assigned or whose address is taken.
+	redeclared := map[string]bool{}
+	assigned := map[string]bool{}
+	visitFunction := func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range node.Lhs {
+				if ident, ok := lhs.(*ast.Ident); ok {
+					if node.Tok == token.DEFINE {
+						redeclared[ident.Name] = true
+					} else {
+						assigned[ident.Name] = true
+					}
+				}
+			}
+		case *ast.ValueSpec:
+			for _, name := range node.Names {
+				redeclared[name.Name] = true
+			}
+		case *ast.Field:
+			for _, name := range node.Names {
+				redeclared[name.Name] = true
+			}
+		case *ast.RangeStmt:
+			for _, expr := range []ast.Expr{node.Key, node.Value} {
+				if ident, ok := expr.(*ast.Ident); ok && node.Tok == token.DEFINE {
+					redeclared[ident.Name] = true
+				}
+			}
+		case *ast.UnaryExpr:
+			if ident, ok := node.X.(*ast.Ident); ok && node.Op == token.AND {
+				assigned[ident.Name] = true
+			}
+		}
+		return true
+	}
+	for _, decl := range f.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok {
+			ast.Inspect(fn, visitFunction)
+			continue
+		}
+		// The function literals of the package-level declarations.
+		ast.Inspect(decl, func(n ast.Node) bool {
+			if lit, ok := n.(*ast.FuncLit); ok {
+				ast.Inspect(lit, visitFunction)
+				return false
+			}
+			return true
+		})
+	}
+
+	resolved := map[string]string{}
+	var resolve func(name string, visiting map[string]bool) (string, bool)
+	resolve = func(name string, visiting map[string]bool) (string, bool) {
+		if value, ok := resolved[name]; ok {
+			return value, true
+		}
+		expr, ok := values[name]
+		if !ok || visiting[name] || redeclared[name] || (variables[name] && assigned[name]) {
+			return "", false
+		}
+		visiting[name] = true
+		value := ""
+		for _, part := range concatenationParts(expr) {
+			switch p := part.(type) {
+			case *ast.BasicLit:
+				if p.Kind != token.STRING {
+					return "", false
+				}
+				text, err := strconv.Unquote(p.Value)
+				if err != nil {
+					return "", false
+				}
+				value += text
+			case *ast.Ident:
+				text, ok := resolve(p.Name, visiting)
+				if !ok {
+					return "", false
+				}
+				value += text
+			default:
+				return "", false
+			}
+		}
+		resolved[name] = value
+		return value, true
+	}
+	for name := range values {
+		resolve(name, map[string]bool{})
+	}
+	return resolved
+}
+
+// resolvedId returns the id passed to a translation call through a name of
+// fileStrings, or a concatenation of such names and string literals.
+func (e *Extractor) resolvedId(call *ast.CallExpr, names map[string]string) (string, bool) {
+	idx, ok := e.opts.Functions[calleeName(call)]
+	if !ok || len(call.Args) <= idx || len(names) == 0 {
+		return "", false
+	}
+	id := ""
+	for _, part := range concatenationParts(call.Args[idx]) {
+		switch p := part.(type) {
+		case *ast.BasicLit:
+			text, err := strconv.Unquote(p.Value)
+			if p.Kind != token.STRING || err != nil {
+				return "", false
+			}
+			id += text
+		case *ast.Ident:
+			text, ok := names[p.Name]
+			if !ok {
+				return "", false
+			}
+			id += text
+		default:
+			return "", false
+		}
+	}
+	return id, true
+}
@@ -0,0 +1,657 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+// Package i18nextract finds the translation ids used by the Xenia source
+// code: the ids passed to the translation functions, as literals or through
+// the string constants and variables of their file, expanded when they
+// concatenate the string cases of an enclosing switch, the ones of a few
+// error constants and, in the webapp, the ids of formatMessage calls and
+// FormattedMessage elements.
+//
+// An Extractor holds no mutable state, it can be shared by goroutines:
+//
+//	extractor := i18nextract.New(i18nextract.Options{})
+//	keys, err := extractor.Extract(ctx, "./server", "./enterprise")
+package i18nextract
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultFunctions maps the name of the Xenia functions receiving
+// translation ids to the position of the id argument.
+var DefaultFunctions = map[string]int{
+	"T":               0,
+	"NewAppError":     1,
+	"newAppError":     0,
+	"translateFunc":   0,
+	"TranslateAsHtml": 1,
+	"userLocale":      0,
+	"localT":          0,
+	"TDefault":        0,
+}
+
+// DefaultTextArguments maps the name of the Xenia translation functions
+// receiving the English text of the id to the position of that argument.
+var DefaultTextArguments = map[string]int{
+	"TDefault": 1,
+}
+
+// DefaultStatusArguments maps the name of the Xenia error constructors to the
+// position of their HTTP status argument.
+var DefaultStatusArguments = map[string]int{
+	"NewAppError": 4,
+}
+
+// DefaultConstants are the names of the Xenia constants holding translation
+// ids.
+var DefaultConstants = map[string]bool{
+	"MISSING_CHANNEL_ERROR":        true,
+	"MISSING_CHANNEL_MEMBER_ERROR": true,
+	"CHANNEL_EXISTS_ERROR":         true,
+	"MISSING_STATUS_ERROR":         true,
+	"TEAM_MEMBER_EXISTS_ERROR":     true,
+	"MISSING_AUTH_ACCOUNT_ERROR":   true,
+	"MISSING_ACCOUNT_ERROR":        true,
+	"EXPIRED_LICENSE_ERROR":        true,
+	"INVALID_LICENSE_ERROR":        true,
+}
+
+// DefaultSkippedFiles are the path suffixes of the Xenia Go files never
+// extracted.
+var DefaultSkippedFiles = []string{"model/client4.go"}
+
+// Ref is a reference to a translation id found in the source code.
+type Ref struct {
+	Id string `json:"id"`
+	// Plural is set when the translation is called with a count argument.
+	Plural bool `json:"plural,omitempty"`
+	// Description is the text of an adjacent "// i18n:" comment.
+	Description string `json:"description,omitempty"`
+	// Path is the file using the key and Module the folder Extract found
+	// it in. They are not encoded, the same content may live in several
+	// files.
+	Path   string `json:"-"`
+	Module string `json:"-"`
+	// Line, Function and CallKind locate the reference in its file: the
+	// line of the call, the function holding it and the translation
+	// function, component or constant used.
+	Line     int    `json:"line,omitempty"`
+	Function string `json:"function,omitempty"`
+	CallKind string `json:"callKind,omitempty"`
+	// Status is the HTTP status of an error constructor call, when it is a
+	// constant.
+	Status int `json:"status,omitempty"`
+	// Default is the English text given by the call, like the second
+	// argument of TDefault, when it is a string literal.
+	Default string `json:"default,omitempty"`
+}
+
+// KeySet holds the references of every translation id found.
+type KeySet map[string][]Ref
+
+// Ids returns the sorted ids of the set.
+func (s KeySet) Ids() []string {
+	ids := make([]string, 0, len(s))
+	for id := range s {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// FileError is a file Extract couldn't read or parse.
+type FileError struct {
+	Path string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+// FileErrors are the files skipped by Extract, sorted by path.
+type FileErrors []*FileError
+
+func (e FileErrors) Error() string {
+	messages := []string{}
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Options configure an Extractor. The zero value extracts the Xenia
+// translation calls of the Go files, tests excluded.
+type Options struct {
+	// Functions maps the name of the translation functions to the position
+	// of their id argument, DefaultFunctions when nil.
+	Functions map[string]int
+	// Constants are the names of the constants holding translation ids,
+	// DefaultConstants when nil.
+	Constants map[string]bool
+	// StatusArguments maps the name of the error constructors to the
+	// position of their HTTP status argument, DefaultStatusArguments when
+	// nil.
+	StatusArguments map[string]int
+	// TextArguments maps the name of the translation functions receiving
+	// the English text of the id to the position of that argument,
+	// DefaultTextArguments when nil.
+	TextArguments map[string]int
+	// SkippedFiles are the path suffixes of the Go files never extracted,
+	// DefaultSkippedFiles when nil.
+	SkippedFiles []string
+	// IncludeTests extracts the _test.go files, and the test files of the
+	// webapp, too.
+	IncludeTests bool
+	// Webapp extracts the JavaScript and TypeScript files too.
+	Webapp bool
+	// FollowSymlinks walks the symlinked folders, see Walk.
+	FollowSymlinks bool
+	// Skip, when set, is called with the roots and with the folders and
+	// files the walk would visit otherwise, a folder before its content,
+	// and returns true to skip them. It is called by one goroutine, one
+	// root after the other.
+	Skip func(root, path string, info os.FileInfo) bool
+	// Parse, when set, returns the references of a file in place of
+	// reading it and calling Source, to cache them or to find them another
+	// way. It may be called from several goroutines at once.
+	Parse func(path string) ([]Ref, error)
+	// Jobs is the number of files parsed at the same time, GOMAXPROCS when
+	// not positive.
+	Jobs int
+	// OnNonLiteral is called with the translation calls whose id is neither
+	// a string literal nor a string constant or variable of their file, the
+	// extraction can't know which id they use. It may be called from
+	// several goroutines at once.
+	OnNonLiteral func(pos token.Position, function string)
+}
+
+// Extractor finds translation references. It is safe for concurrent use.
+type Extractor struct {
+	opts Options
+}
+
+// New returns an Extractor, the options must not be modified afterwards.
+func New(opts Options) *Extractor {
+	if opts.Functions == nil {
+		opts.Functions = DefaultFunctions
+	}
+	if opts.Constants == nil {
+		opts.Constants = DefaultConstants
+	}
+	if opts.StatusArguments == nil {
+		opts.StatusArguments = DefaultStatusArguments
+	}
+	if opts.TextArguments == nil {
+		opts.TextArguments = DefaultTextArguments
+	}
+	if opts.SkippedFiles == nil {
+		opts.SkippedFiles = DefaultSkippedFiles
+	}
+	if opts.Jobs < 1 {
+		opts.Jobs = runtime.GOMAXPROCS(0)
+	}
+	return &Extractor{opts: opts}
+}
+
+// Extract walks the folders, module roots, in parallel and returns the
+// references of their source files. The vendor folders of the roots, the
+// nested modules and the folders of SkippedDirs are skipped. The files
+// that can't be read or parsed are returned as FileErrors along with the
+// references of the other files.
+func (e *Extractor) Extract(ctx context.Context, dirs ...string) (KeySet, error) {
+	files := make(chan sourceFile)
+	results := make(chan fileResult)
+	var wg sync.WaitGroup
+	for i := 0; i < e.opts.Jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range files {
+				refs, err := e.parse(file.path)
+				results <- fileResult{sourceFile: file, refs: refs, err: err}
+			}
+		}()
+	}
+
+	walkErr := make(chan error, 1)
+	go func() {
+		walkErr <- e.WalkSourceFiles(ctx, dirs, func(path, root string) error {
+			select {
+			case files <- sourceFile{path: path, module: root}:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		close(files)
+		wg.Wait()
+		close(results)
+	}()
+
+	keys := KeySet{}
+	problems := FileErrors{}
+	for result := range results {
+		if result.err != nil {
+			problems = append(problems, &FileError{Path: result.path, Err: result.err})
+			continue
+		}
+		for _, ref := range result.refs {
+			ref.Path, ref.Module = result.path, result.module
+			keys[ref.Id] = append(keys[ref.Id], ref)
+		}
+	}
+	if err := <-walkErr; err != nil {
+		return nil, err
+	}
+	for _, refs := range keys {
+		sort.SliceStable(refs, func(i, j int) bool {
+			if refs[i].Path != refs[j].Path {
+				return refs[i].Path < refs[j].Path
+			}
+			return refs[i].Line < refs[j].Line
+		})
+	}
+	if len(problems) > 0 {
+		sort.Slice(problems, func(i, j int) bool { return problems[i].Path < problems[j].Path })
+		return keys, problems
+	}
+	return keys, nil
+}
+
+// sourceFile is a file to extract and the folder it was found in.
+type sourceFile struct {
+	path   string
+	module string
+}
+
+type fileResult struct {
+	sourceFile
+	refs []Ref
+	err  error
+}
+
+// parse returns the references of a file found by the walk.
+func (e *Extractor) parse(path string) ([]Ref, error) {
+	if e.opts.Parse != nil {
+		return e.opts.Parse(path)
+	}
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return e.Source(path, src)
+}
+
+// WalkSourceFiles calls fn with the source files Extract parses in the
+// folders, module roots, and the root holding them, until fn fails or the
+// context is done.
+func (e *Extractor) WalkSourceFiles(ctx context.Context, dirs []string, fn func(path, root string) error) error {
+	return e.walkSources(ctx, dirs, nil, fn)
+}
+
+// WalkSourceDirs calls fn with the folders walked by WalkSourceFiles, the
+// folders to watch for changes of the source files.
+func (e *Extractor) WalkSourceDirs(ctx context.Context, dirs []string, fn func(path, root string) error) error {
+	return e.walkSources(ctx, dirs, fn, nil)
+}
+
+// walkSources walks the folders and the source files of the roots, calling
+// dirFn and fileFn when set.
+func (e *Extractor) walkSources(ctx context.Context, dirs []string, dirFn, fileFn func(path, root string) error) error {
+	roots := map[string]bool{}
+	for _, dir := range dirs {
+		if abs, err := filepath.Abs(dir); err == nil {
+			roots[abs] = true
+		}
+	}
+	for _, dir := range dirs {
+		vendorDir := filepath.Join(dir, "vendor")
+		err := Walk(dir, e.opts.FollowSymlinks, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if info.IsDir() {
+				if p != dir {
+					if p == vendorDir || SkippedDirs[info.Name()] || IsNestedModule(p) {
+						return filepath.SkipDir
+					}
+					// The other roots are walked on their own.
+					if abs, err := filepath.Abs(p); err == nil && roots[abs] {
+						return filepath.SkipDir
+					}
+				}
+				if e.opts.Skip != nil && e.opts.Skip(dir, p, info) {
+					return filepath.SkipDir
+				}
+				if dirFn != nil {
+					return dirFn(p, dir)
+				}
+				return nil
+			}
+			if fileFn == nil || !e.IsSourceFile(p) || (e.opts.Skip != nil && e.opts.Skip(dir, p, info)) {
+				return nil
+			}
+			return fileFn(p, dir)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsSourceFile tells if the file is extracted.
+func (e *Extractor) IsSourceFile(path string) bool {
+	if IsWebappFile(path) {
+		return e.opts.Webapp && (e.opts.IncludeTests || !IsWebappTestFile(path))
+	}
+	if !strings.HasSuffix(path, ".go") {
+		return false
+	}
+	for _, suffix := range e.opts.SkippedFiles {
+		if strings.HasSuffix(path, suffix) {
+			return false
+		}
+	}
+	return e.opts.IncludeTests || !strings.HasSuffix(path, "_test.go")
+}
+
+// Source returns the references of a Go or webapp source.
+func (e *Extractor) Source(path string, src []byte) ([]Ref, error) {
+	if IsWebappFile(path) {
+		return e.webappSource(path, src), nil
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	return e.File(fset, f, src, nil), nil
+}
+
+// File returns the references of a parsed Go file. When accept is set, only
+// the translation calls it accepts are extracted.
+func (e *Extractor) File(fset *token.FileSet, f *ast.File, src []byte, accept func(call *ast.CallExpr) bool) []Ref {
+	comments := translatorComments(fset, f, src)
+	names := fileStrings(f)
+
+	refs := []Ref{}
+	// parents are the nodes holding the current one, the innermost last.
+	parents := []ast.Node{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil {
+			parents = parents[:len(parents)-1]
+			return true
+		}
+		defer func() { parents = append(parents, n) }()
+		switch expr := n.(type) {
+		case *ast.CallExpr:
+			name := calleeName(expr)
+			if _, ok := e.opts.Functions[name]; !ok {
+				return true
+			}
+			ids := []string{}
+			if id, ok := literalValue(e.IdArgument(expr)); ok {
+				ids = append(ids, id)
+			} else if id, ok := e.resolvedId(expr, names); ok {
+				ids = append(ids, id)
+			} else if caseIds, ok := e.switchCaseIds(expr, parents, names); ok {
+				ids = caseIds
+			} else {
+				if e.opts.OnNonLiteral != nil && len(expr.Args) > e.opts.Functions[name] {
+					e.opts.OnNonLiteral(fset.Position(expr.Pos()), name)
+				}
+				return true
+			}
+			if accept != nil && !accept(expr) {
+				return true
+			}
+			line := fset.Position(n.Pos()).Line
+			for _, id := range ids {
+				refs = append(refs, Ref{
+					Id:          id,
+					Plural:      e.hasCountArgument(name, expr.Args),
+					Description: comments[line],
+					Line:        line,
+					Function:    enclosingFunction(f, n.Pos()),
+					CallKind:    name,
+					Status:      e.statusArgument(name, expr.Args),
+					Default:     e.textArgument(name, expr.Args),
+				})
+			}
+		case *ast.GenDecl:
+			if expr.Tok != token.CONST {
+				return true
+			}
+			for _, spec := range expr.Specs {
+				valueSpec, ok := spec.(*ast.ValueSpec)
+				if !ok {
+					continue
+				}
+				id, ok := literalValue(e.ConstantId(valueSpec))
+				if !ok {
+					continue
+				}
+				refs = append(refs, Ref{
+					Id:       id,
+					Line:     fset.Position(valueSpec.Pos()).Line,
+					Function: enclosingFunction(f, valueSpec.Pos()),
+					CallKind: "const",
+				})
+			}
+		}
+		return true
+	})
+	return refs
+}
+
+// calleeName returns the name of the function called, without its package
+// or receiver.
+func calleeName(call *ast.CallExpr) string {
+	switch fun := call.Fun.(type) {
+	case *ast.SelectorExpr:
+		return fun.Sel.Name
+	case *ast.Ident:
+		return fun.Name
+	}
+	return ""
+}
+
+// IdArgument returns the string literal passed as id to a translation call.
+func (e *Extractor) IdArgument(call *ast.CallExpr) (*ast.BasicLit, bool) {
+	idx, ok := e.opts.Functions[calleeName(call)]
+	if !ok || len(call.Args) <= idx {
+		return nil, false
+	}
+	id, ok := call.Args[idx].(*ast.BasicLit)
+	return id, ok
+}
+
+// literalValue returns the value of a string literal, interpreted or raw.
+func literalValue(lit *ast.BasicLit, ok bool) (string, bool) {
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	value, err := strconv.Unquote(lit.Value)
+	return value, err == nil
+}
+
+// switchCaseIds expands the id of a translation call concatenating string
+// literals and the tags of enclosing switches, once for every string literal
+// of the case clauses holding the call:
+//
+//	switch provider {
+//	case "gitlab", "google":
+//		return model.NewAppError("Login", "api.user."+provider+".app_error", nil, "", http.StatusBadRequest)
+//	}
+//
+// The calls of a default clause or of a case with other values are not
+// expanded. The other names of the id are looked up in names, see
+// fileStrings.
+func (e *Extractor) switchCaseIds(call *ast.CallExpr, parents []ast.Node, names map[string]string) ([]string, bool) {
+	idx := e.opts.Functions[calleeName(call)]
+	if len(call.Args) <= idx {
+		return nil, false
+	}
+	arg := call.Args[idx]
+	if _, ok := arg.(*ast.BinaryExpr); !ok {
+		return nil, false
+	}
+
+	// values are the case values of the innermost switch on every tag.
+	values := map[string][]string{}
+	for i := len(parents) - 1; i >= 2; i-- {
+		clause, ok := parents[i].(*ast.CaseClause)
+		if !ok {
+			continue
+		}
+		switchStmt, ok := parents[i-2].(*ast.SwitchStmt)
+		if !ok {
+			continue
+		}
+		tag, ok := switchStmt.Tag.(*ast.Ident)
+		if !ok || values[tag.Name] != nil || !usesIdent(arg, tag.Name) {
+			continue
+		}
+		if len(clause.List) == 0 {
+			return nil, false
+		}
+		for _, value := range clause.List {
+			lit, ok := value.(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				return nil, false
+			}
+			caseValue, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				return nil, false
+			}
+			values[tag.Name] = append(values[tag.Name], caseValue)
+		}
+	}
+	if len(values) == 0 {
+		return nil, false
+	}
+
+	ids := []string{""}
+	for _, part := range concatenationParts(arg) {
+		lit, isLit := part.(*ast.BasicLit)
+		ident, isIdent := part.(*ast.Ident)
+		switch {
+		case isLit && lit.Kind == token.STRING:
+			text, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				return nil, false
+			}
+			for i := range ids {
+				ids[i] += text
+			}
+		case isIdent && values[ident.Name] != nil:
+			expanded := []string{}
+			for _, id := range ids {
+				for _, value := range values[ident.Name] {
+					expanded = append(expanded, id+value)
+				}
+			}
+			ids = expanded
+		case isIdent:
+			text, ok := names[ident.Name]
+			if !ok {
+				return nil, false
+			}
+			for i := range ids {
+				ids[i] += text
+			}
+		default:
+			return nil, false
+		}
+	}
+	return ids, true
+}
+
+// usesIdent tells if the expression refers to the identifier.
+func usesIdent(expr ast.Expr, name string) bool {
+	found := false
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && ident.Name == name {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// concatenationParts returns the operands of a chain of + operations, in
+// order.
+func concatenationParts(expr ast.Expr) []ast.Expr {
+	switch x := expr.(type) {
+	case *ast.ParenExpr:
+		return concatenationParts(x.X)
+	case *ast.BinaryExpr:
+		if x.Op == token.ADD {
+			return append(concatenationParts(x.X), concatenationParts(x.Y)...)
+		}
+	}
+	return []ast.Expr{expr}
+}
+
+// ConstantId returns the string literal of a constant holding a translation
+// id.
+func (e *Extractor) ConstantId(spec *ast.ValueSpec) (*ast.BasicLit, bool) {
+	if len(spec.Names) == 0 || len(spec.Values) == 0 || !e.opts.Constants[spec.Names[0].Name] {
+		return nil, false
+	}
+	id, ok := spec.Values[0].(*ast.BasicLit)
+	return id, ok
+}
+
+// enclosingFunction returns the name of the function declaration holding
+// pos, like (*T).Name for a method, empty outside of functions.
+func enclosingFunction(f *ast.File, pos token.Pos) string {
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || pos < fn.Pos() || pos >= fn.End() {
+			continue
+		}
+		if fn.Recv == nil || len(fn.Recv.List) == 0 {
+			return fn.Name.Name
+		}
+		receiver := receiverTypeName(fn.Recv.List[0].Type)
+		if strings.HasPrefix(receiver, "*") {
+			receiver = "(" + receiver + ")"
+		}
+		return receiver + "." + fn.Name.Name
+	}
+	return ""
+}
+
+// receiverTypeName returns the type of a method receiver, like T or *T,
+// without its type parameters.
+func receiverTypeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return "*" + receiverTypeName(t.X)
+	case *ast.Ident:
+		return t.Name
+	case *ast.IndexExpr:
+		return receiverTypeName(t.X)
+	case *ast.IndexListExpr:
+		return receiverTypeName(t.X)
+	}
+	return ""
+}
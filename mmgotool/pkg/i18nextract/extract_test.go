@@ -0,0 +1,375 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package i18nextract
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSourceLiteralIds(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		ids  []string
+	}{
+		{
+			name: "interpreted string",
+			src:  `func f() { T("api.user.get.app_error") }`,
+			ids:  []string{"api.user.get.app_error"},
+		},
+		{
+			name: "raw string",
+			src:  "func f() { T(`api.user.raw.app_error`) }",
+			ids:  []string{"api.user.raw.app_error"},
+		},
+		{
+			name: "escapes",
+			src:  `func f() { T("api.user.\x65scaped.app_error") }`,
+			ids:  []string{"api.user.escaped.app_error"},
+		},
+		{
+			name: "raw string constant",
+			src:  "const MISSING_ACCOUNT_ERROR = `store.sql_user.missing_account.const`",
+			ids:  []string{"store.sql_user.missing_account.const"},
+		},
+		{
+			name: "not a string",
+			src:  `func f() { T(42) }`,
+			ids:  []string{},
+		},
+	}
+
+	extractor := New(Options{})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			refs, err := extractor.Source("file.go", []byte("package p\n\n"+test.src+"\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := []string{}
+			for _, ref := range refs {
+				ids = append(ids, ref.Id)
+			}
+			if !reflect.DeepEqual(ids, test.ids) {
+				t.Errorf("got ids %q, want %q", ids, test.ids)
+			}
+		})
+	}
+}
+
+func TestSourceStatus(t *testing.T) {
+	src := `package p
+
+func f() {
+	NewAppError("Login", "api.user.login.app_error", nil, "", http.StatusProxyAuthRequired)
+	NewAppError("Login", "api.user.login.locked.app_error", nil, "", http.StatusTeapot)
+	NewAppError("Login", "api.user.login.literal.app_error", nil, "", 400)
+}
+`
+	refs, err := New(Options{}).Source("file.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := []int{}
+	for _, ref := range refs {
+		statuses = append(statuses, ref.Status)
+	}
+	if expected := []int{407, 418, 400}; !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("got statuses %v, want %v", statuses, expected)
+	}
+}
+
+func TestExtractJobsDeterministic(t *testing.T) {
+	dirs := []string{t.TempDir(), t.TempDir()}
+	for i, dir := range dirs {
+		for j := 0; j < 40; j++ {
+			src := fmt.Sprintf(`package p
+
+func f() {
+	T("app.shared"); T("app.shared")
+	T("app.file_%d_%d")
+	T("app.shared")
+}
+`, i, j)
+			if j%10 == 0 {
+				src = "package p\n\nfunc {\n"
+			}
+			file := filepath.Join(dir, fmt.Sprintf("pkg%d", j%4), fmt.Sprintf("file%02d.go", j))
+			if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(file, []byte(src), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	expectedKeys, expectedErr := New(Options{Jobs: 1}).Extract(context.Background(), dirs...)
+	if _, ok := expectedErr.(FileErrors); !ok || len(expectedErr.(FileErrors)) != 8 {
+		t.Fatalf("expected the 8 broken files as errors, got %v", expectedErr)
+	}
+	if refs := expectedKeys["app.shared"]; len(refs) != 216 {
+		t.Fatalf("got %d references of app.shared, want 216", len(refs))
+	}
+	for run := 0; run < 5; run++ {
+		keys, err := New(Options{Jobs: 8}).Extract(context.Background(), dirs...)
+		if !reflect.DeepEqual(keys, expectedKeys) {
+			t.Fatalf("run %d: the references with 8 jobs differ from the ones with 1 job", run)
+		}
+		if !reflect.DeepEqual(err, expectedErr) {
+			t.Fatalf("run %d: got errors %v with 8 jobs, want %v", run, err, expectedErr)
+		}
+	}
+}
+
+// sourceIds returns the ids extracted from the Go source of a file of
+// package p.
+func sourceIds(t *testing.T, src string) []string {
+	t.Helper()
+	refs, err := New(Options{}).Source("file.go", []byte("package p\n\n"+src+"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, ref := range refs {
+		ids = append(ids, ref.Id)
+	}
+	return ids
+}
+
+func TestSourceSwitchCaseIds(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		ids  []string
+	}{
+		{
+			name: "string cases",
+			src: `func f(provider string) {
+	switch provider {
+	case "gitlab", "google":
+		T("api.user." + provider + ".app_error")
+	case "office365":
+		T("api.user." + provider + ".app_error")
+	}
+}`,
+			ids: []string{"api.user.gitlab.app_error", "api.user.google.app_error", "api.user.office365.app_error"},
+		},
+		{
+			name: "nested switches",
+			src: `func f(provider, kind string) {
+	switch provider {
+	case "gitlab", "google":
+		switch kind {
+		case "login", "signup":
+			T("api." + kind + "." + provider + ".app_error")
+		}
+	}
+}`,
+			ids: []string{"api.login.gitlab.app_error", "api.login.google.app_error", "api.signup.gitlab.app_error", "api.signup.google.app_error"},
+		},
+		{
+			name: "innermost switch on the tag",
+			src: `func f(provider string) {
+	switch provider {
+	case "gitlab", "google":
+		switch provider {
+		case "gitlab":
+			T("api.user." + provider + ".app_error")
+		}
+	}
+}`,
+			ids: []string{"api.user.gitlab.app_error"},
+		},
+		{
+			name: "switch with an init statement",
+			src: `func f(user User) {
+	switch provider := user.AuthService; provider {
+	case "saml":
+		T("api.user." + provider + ".app_error")
+	}
+}`,
+			ids: []string{"api.user.saml.app_error"},
+		},
+		{
+			name: "package constant in the id",
+			src: `const errorSuffix = ".app_error"
+
+func f(provider string) {
+	switch provider {
+	case "gitlab":
+		T("api.user." + provider + errorSuffix)
+	}
+}`,
+			ids: []string{"api.user.gitlab.app_error"},
+		},
+		{
+			name: "default clause",
+			src: `func f(provider string) {
+	switch provider {
+	case "gitlab":
+	default:
+		T("api.user." + provider + ".app_error")
+	}
+}`,
+			ids: []string{},
+		},
+		{
+			name: "case with a value that is not a string literal",
+			src: `func f(provider string) {
+	switch provider {
+	case "gitlab", otherProvider:
+		T("api.user." + provider + ".app_error")
+	}
+}`,
+			ids: []string{},
+		},
+		{
+			name: "name unknown to the switches",
+			src: `func f(provider, kind string) {
+	switch provider {
+	case "gitlab":
+		T("api." + kind + "." + provider + ".app_error")
+	}
+}`,
+			ids: []string{},
+		},
+		{
+			name: "switch without the call",
+			src: `func f(provider string) {
+	switch provider {
+	case "gitlab":
+	}
+	T("api.user." + provider + ".app_error")
+}`,
+			ids: []string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if ids := sourceIds(t, test.src); !reflect.DeepEqual(ids, test.ids) {
+				t.Errorf("got ids %q, want %q", ids, test.ids)
+			}
+		})
+	}
+}
+
+func TestSourceResolvedIds(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		ids  []string
+	}{
+		{
+			name: "package constant",
+			src: `const errKey = "api.user.banned.app_error"
+
+func f() { T(errKey) }`,
+			ids: []string{"api.user.banned.app_error"},
+		},
+		{
+			name: "package variable never assigned",
+			src: `var errKey = "api.user.banned.app_error"
+
+func f() { T(errKey) }`,
+			ids: []string{"api.user.banned.app_error"},
+		},
+		{
+			name: "grouped declarations and concatenations",
+			src: `const (
+	prefix = "api.user."
+	suffix = ".app_error"
+)
+
+var errKey = prefix + "banned" + suffix
+
+func f() { T(errKey) }`,
+			ids: []string{"api.user.banned.app_error"},
+		},
+		{
+			name: "name concatenated in the call",
+			src: `const prefix = "api.user."
+
+func f() { T(prefix + "banned.app_error") }`,
+			ids: []string{"api.user.banned.app_error"},
+		},
+		{
+			name: "declared after the call",
+			src: `func f() { T(errKey) }
+
+var errKey = "api.user.banned.app_error"`,
+			ids: []string{"api.user.banned.app_error"},
+		},
+		{
+			name: "package variable assigned",
+			src: `var errKey = "api.user.banned.app_error"
+
+func init() { errKey = "api.user.other.app_error" }
+
+func f() { T(errKey) }`,
+			ids: []string{},
+		},
+		{
+			name: "package variable whose address is taken",
+			src: `var errKey = "api.user.banned.app_error"
+
+func init() { flag.StringVar(&errKey, "key", errKey, "") }
+
+func f() { T(errKey) }`,
+			ids: []string{},
+		},
+		{
+			name: "name declared again in a function",
+			src: `const errKey = "api.user.banned.app_error"
+
+func f() {
+	errKey := "api.user.local.app_error"
+	T(errKey)
+}`,
+			ids: []string{},
+		},
+		{
+			name: "parameter with the name",
+			src: `const errKey = "api.user.banned.app_error"
+
+func f(errKey string) { T(errKey) }`,
+			ids: []string{},
+		},
+		{
+			name: "value that is not a string",
+			src: `const errKey = 42
+
+func f() { T(errKey) }`,
+			ids: []string{},
+		},
+		{
+			name: "function call value",
+			src: `var errKey = strings.ToLower("API.USER.BANNED.APP_ERROR")
+
+func f() { T(errKey) }`,
+			ids: []string{},
+		},
+		{
+			name: "cyclic declarations",
+			src: `const a = b
+const b = a
+
+func f() { T(a) }`,
+			ids: []string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if ids := sourceIds(t, test.src); !reflect.DeepEqual(ids, test.ids) {
+				t.Errorf("got ids %q, want %q", ids, test.ids)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package i18nextract
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// hasCountArgument reports whether a translation function call passes a
+// count after the translation id: a number, a len() call, a variable whose
+// name contains "count" or a template data map with a "Count" entry.
+func (e *Extractor) hasCountArgument(name string, args []ast.Expr) bool {
+	idx, ok := e.opts.Functions[name]
+	if !ok || len(args) <= idx+1 {
+		return false
+	}
+	return isCountExpr(args[idx+1])
+}
+
+func isCountExpr(expr ast.Expr) bool {
+	switch arg := expr.(type) {
+	case *ast.BasicLit:
+		return arg.Kind == token.INT || arg.Kind == token.FLOAT
+	case *ast.Ident:
+		return strings.Contains(strings.ToLower(arg.Name), "count")
+	case *ast.SelectorExpr:
+		return strings.Contains(strings.ToLower(arg.Sel.Name), "count")
+	case *ast.CallExpr:
+		fun, ok := arg.Fun.(*ast.Ident)
+		return ok && fun.Name == "len"
+	case *ast.CompositeLit:
+		for _, elt := range arg.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				continue
+			}
+			if key, ok := kv.Key.(*ast.BasicLit); ok && key.Value == `"Count"` {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package i18nextract
+
+import (
+	"go/ast"
+	"go/token"
+	"net/http"
+	"strconv"
+)
+
+// HTTPStatusConstants maps the names of the net/http status constants to
+// their value.
+var HTTPStatusConstants = map[string]int{
+	"StatusContinue":                      http.StatusContinue,
+	"StatusSwitchingProtocols":            http.StatusSwitchingProtocols,
+	"StatusProcessing":                    http.StatusProcessing,
+	"StatusEarlyHints":                    http.StatusEarlyHints,
+	"StatusOK":                            http.StatusOK,
+	"StatusCreated":                       http.StatusCreated,
+	"StatusAccepted":                      http.StatusAccepted,
+	"StatusNonAuthoritativeInfo":          http.StatusNonAuthoritativeInfo,
+	"StatusNoContent":                     http.StatusNoContent,
+	"StatusResetContent":                  http.StatusResetContent,
+	"StatusPartialContent":                http.StatusPartialContent,
+	"StatusMultiStatus":                   http.StatusMultiStatus,
+	"StatusAlreadyReported":               http.StatusAlreadyReported,
+	"StatusIMUsed":                        http.StatusIMUsed,
+	"StatusMultipleChoices":               http.StatusMultipleChoices,
+	"StatusMovedPermanently":              http.StatusMovedPermanently,
+	"StatusFound":                         http.StatusFound,
+	"StatusSeeOther":                      http.StatusSeeOther,
+	"StatusNotModified":                   http.StatusNotModified,
+	"StatusUseProxy":                      http.StatusUseProxy,
+	"StatusTemporaryRedirect":             http.StatusTemporaryRedirect,
+	"StatusPermanentRedirect":             http.StatusPermanentRedirect,
+	"StatusBadRequest":                    http.StatusBadRequest,
+	"StatusUnauthorized":                  http.StatusUnauthorized,
+	"StatusPaymentRequired":               http.StatusPaymentRequired,
+	"StatusForbidden":                     http.StatusForbidden,
+	"StatusNotFound":                      http.StatusNotFound,
+	"StatusMethodNotAllowed":              http.StatusMethodNotAllowed,
+	"StatusNotAcceptable":                 http.StatusNotAcceptable,
+	"StatusProxyAuthRequired":             http.StatusProxyAuthRequired,
+	"StatusRequestTimeout":                http.StatusRequestTimeout,
+	"StatusConflict":                      http.StatusConflict,
+	"StatusGone":                          http.StatusGone,
+	"StatusLengthRequired":                http.StatusLengthRequired,
+	"StatusPreconditionFailed":            http.StatusPreconditionFailed,
+	"StatusRequestEntityTooLarge":         http.StatusRequestEntityTooLarge,
+	"StatusRequestURITooLong":             http.StatusRequestURITooLong,
+	"StatusUnsupportedMediaType":          http.StatusUnsupportedMediaType,
+	"StatusRequestedRangeNotSatisfiable":  http.StatusRequestedRangeNotSatisfiable,
+	"StatusExpectationFailed":             http.StatusExpectationFailed,
+	"StatusTeapot":                        http.StatusTeapot,
+	"StatusMisdirectedRequest":            http.StatusMisdirectedRequest,
+	"StatusUnprocessableEntity":           http.StatusUnprocessableEntity,
+	"StatusLocked":                        http.StatusLocked,
+	"StatusFailedDependency":              http.StatusFailedDependency,
+	"StatusTooEarly":                      http.StatusTooEarly,
+	"StatusUpgradeRequired":               http.StatusUpgradeRequired,
+	"StatusPreconditionRequired":          http.StatusPreconditionRequired,
+	"StatusTooManyRequests":               http.StatusTooManyRequests,
+	"StatusRequestHeaderFieldsTooLarge":   http.StatusRequestHeaderFieldsTooLarge,
+	"StatusUnavailableForLegalReasons":    http.StatusUnavailableForLegalReasons,
+	"StatusInternalServerError":           http.StatusInternalServerError,
+	"StatusNotImplemented":                http.StatusNotImplemented,
+	"StatusBadGateway":                    http.StatusBadGateway,
+	"StatusServiceUnavailable":            http.StatusServiceUnavailable,
+	"StatusGatewayTimeout":                http.StatusGatewayTimeout,
+	"StatusHTTPVersionNotSupported":       http.StatusHTTPVersionNotSupported,
+	"StatusVariantAlsoNegotiates":         http.StatusVariantAlsoNegotiates,
+	"StatusInsufficientStorage":           http.StatusInsufficientStorage,
+	"StatusLoopDetected":                  http.StatusLoopDetected,
+	"StatusNotExtended":                   http.StatusNotExtended,
+	"StatusNetworkAuthenticationRequired": http.StatusNetworkAuthenticationRequired,
+}
+
+// statusArgument returns the HTTP status of an error constructor call, 0
+// when it is not a constant.
+func (e *Extractor) statusArgument(name string, args []ast.Expr) int {
+	idx, ok := e.opts.StatusArguments[name]
+	if !ok || len(args) <= idx {
+		return 0
+	}
+	switch arg := args[idx].(type) {
+	case *ast.BasicLit:
+		if arg.Kind == token.INT {
+			if status, err := strconv.Atoi(arg.Value); err == nil {
+				return status
+			}
+		}
+	case *ast.SelectorExpr:
+		return HTTPStatusConstants[arg.Sel.Name]
+	}
+	return 0
+}
@@ -0,0 +1,28 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package i18nextract
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+)
+
+// textArgument returns the English text given to a translation call, empty
+// when it is not a string literal.
+func (e *Extractor) textArgument(name string, args []ast.Expr) string {
+	idx, ok := e.opts.TextArguments[name]
+	if !ok || len(args) <= idx {
+		return ""
+	}
+	lit, ok := args[idx].(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return ""
+	}
+	text, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return ""
+	}
+	return text
+}
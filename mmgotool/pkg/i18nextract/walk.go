@@ -0,0 +1,130 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package i18nextract
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SkippedDirs are the folders never holding source code to extract, some of
+// them huge.
+var SkippedDirs = map[string]bool{
+	".git":             true,
+	".hg":              true,
+	".svn":             true,
+	"node_modules":     true,
+	"bower_components": true,
+	"testdata":         true,
+}
+
+// IsNestedModule tells if the folder is the root of a Go module.
+func IsNestedModule(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, "go.mod"))
+	return err == nil
+}
+
+// isWithin tells if p is dir or one of its descendants.
+func isWithin(p, dir string) bool {
+	return p == dir || strings.HasPrefix(p, dir+string(filepath.Separator))
+}
+
+// linkInfo is the information of the target of a symlink, under the name of
+// the link.
+type linkInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (i linkInfo) Name() string {
+	return i.name
+}
+
+type treeWalker struct {
+	followSymlinks bool
+	fn             filepath.WalkFunc
+	// rootReal is the root with its symlinks resolved, visited the folders
+	// walked through symlinks.
+	rootReal string
+	visited  map[string]bool
+}
+
+// Walk walks the files of root like filepath.Walk, except that a
+// symlinked root is walked, and that the symlinked folders are walked when
+// followSymlinks is set. A symlinked folder already walked, inside the root
+// or holding it is skipped so that symlink cycles end and no file is seen
+// twice.
+func Walk(root string, followSymlinks bool, fn filepath.WalkFunc) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+	rootReal, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return fn(root, info, err)
+	}
+	if rootReal, err = filepath.Abs(rootReal); err != nil {
+		return fn(root, info, err)
+	}
+	w := &treeWalker{followSymlinks: followSymlinks, fn: fn, rootReal: rootReal, visited: map[string]bool{rootReal: true}}
+	if err := w.walk(root, info); err != nil && err != filepath.SkipDir {
+		return err
+	}
+	return nil
+}
+
+func (w *treeWalker) walk(p string, info os.FileInfo) error {
+	if err := w.fn(p, info, nil); err != nil || !info.IsDir() {
+		return err
+	}
+	entries, err := ioutil.ReadDir(p)
+	if err != nil {
+		return w.fn(p, info, err)
+	}
+	for _, entry := range entries {
+		child := filepath.Join(p, entry.Name())
+		if entry.Mode()&os.ModeSymlink != 0 && w.followSymlinks {
+			var skip bool
+			if entry, skip = w.resolveLink(child, entry); skip {
+				continue
+			}
+		}
+		if err := w.walk(child, entry); err != nil {
+			if err != filepath.SkipDir {
+				return err
+			}
+			// Like filepath.Walk, SkipDir on a file skips the rest of its
+			// folder.
+			if !entry.IsDir() {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// resolveLink returns the information of the target of a symlink, and
+// whether it must be skipped.
+func (w *treeWalker) resolveLink(p string, link os.FileInfo) (os.FileInfo, bool) {
+	target, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return nil, true
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return nil, true
+	}
+	if info.IsDir() {
+		if target, err = filepath.Abs(target); err != nil {
+			return nil, true
+		}
+		if w.visited[target] || isWithin(target, w.rootReal) || isWithin(w.rootReal, target) {
+			return nil, true
+		}
+		w.visited[target] = true
+	}
+	return linkInfo{FileInfo: info, name: link.Name()}, false
+}
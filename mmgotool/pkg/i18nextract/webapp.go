@@ -0,0 +1,295 @@
+// Copyright (c) 2015-present Xenia, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package i18nextract
+
+import (
+	"go/token"
+	"path/filepath"
+	"strings"
+)
+
+// formattedMessageComponents are the React components receiving the
+// translation id in their id attribute.
+var formattedMessageComponents = map[string]bool{
+	"FormattedMessage":         true,
+	"FormattedHTMLMessage":     true,
+	"FormattedMarkdownMessage": true,
+}
+
+var webappExtensions = map[string]bool{
+	".js":  true,
+	".jsx": true,
+	".ts":  true,
+	".tsx": true,
+}
+
+// IsWebappFile tells if the file is a JavaScript or TypeScript source, type
+// declarations excluded.
+func IsWebappFile(path string) bool {
+	return webappExtensions[filepath.Ext(path)] && !strings.HasSuffix(path, ".d.ts")
+}
+
+// IsWebappTestFile tells if the webapp file holds tests.
+func IsWebappTestFile(path string) bool {
+	base := filepath.Base(path)
+	return strings.Contains(base, ".test.") || strings.Contains(base, ".spec.")
+}
+
+type jsTokenKind int
+
+const (
+	jsIdent jsTokenKind = iota
+	jsString
+	jsTemplate
+	jsPunct
+)
+
+type jsToken struct {
+	kind jsTokenKind
+	text string
+	line int
+}
+
+// lexJS splits JavaScript, TypeScript and JSX sources into tokens, dropping
+// comments and regular expressions. It doesn't fully understand JSX: quotes
+// in JSX text may start a string, so strings stop at the end of the line to
+// limit the damage.
+func lexJS(src []byte) []jsToken {
+	tokens := []jsToken{}
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := strings.Index(string(src[i+2:]), "*/")
+			if end < 0 {
+				end = len(src) - i - 2
+			}
+			line += strings.Count(string(src[i:i+2+end]), "\n")
+			i += end + 4
+		case c == '/' && startsRegexp(tokens):
+			i++
+			for inClass := false; i < len(src) && src[i] != '\n'; i++ {
+				if src[i] == '\\' {
+					i++
+				} else if src[i] == '[' {
+					inClass = true
+				} else if src[i] == ']' {
+					inClass = false
+				} else if src[i] == '/' && !inClass {
+					i++
+					break
+				}
+			}
+		case c == '"' || c == '\'' || c == '`':
+			start, startLine := i, line
+			for i++; i < len(src) && src[i] != c; i++ {
+				if src[i] == '\\' {
+					i++
+				} else if src[i] == '\n' {
+					if c != '`' {
+						break
+					}
+					line++
+				}
+			}
+			kind := jsString
+			if c == '`' {
+				kind = jsTemplate
+			}
+			if i > len(src) {
+				i = len(src)
+			}
+			tokens = append(tokens, jsToken{kind: kind, text: unquoteJS(string(src[start+1 : i])), line: startLine})
+			if i < len(src) && src[i] == c {
+				i++
+			}
+		case isJSIdentByte(c):
+			start := i
+			for i < len(src) && isJSIdentByte(src[i]) {
+				i++
+			}
+			tokens = append(tokens, jsToken{kind: jsIdent, text: string(src[start:i]), line: line})
+		default:
+			tokens = append(tokens, jsToken{kind: jsPunct, text: string(c), line: line})
+			i++
+		}
+	}
+	return tokens
+}
+
+// JSToken is a token of a webapp source, for the tools reading other calls
+// than the translations ones.
+type JSToken struct {
+	Text string
+	Line int
+	// Ident is set for the identifiers and keywords, String for the string
+	// literals and the template literals without substitutions.
+	Ident  bool
+	String bool
+}
+
+// LexJS splits a webapp source into tokens, dropping comments and regular
+// expressions.
+func LexJS(src []byte) []JSToken {
+	tokens := []JSToken{}
+	for _, t := range lexJS(src) {
+		tokens = append(tokens, JSToken{
+			Text:   t.text,
+			Line:   t.line,
+			Ident:  t.kind == jsIdent,
+			String: t.kind == jsString || (t.kind == jsTemplate && !strings.Contains(t.text, "${")),
+		})
+	}
+	return tokens
+}
+
+func isJSIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// startsRegexp tells if a slash after the tokens starts a regular expression
+// rather than a division.
+func startsRegexp(tokens []jsToken) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+	last := tokens[len(tokens)-1]
+	switch last.kind {
+	case jsIdent:
+		switch last.text {
+		case "return", "typeof", "case", "do", "else", "in", "of", "new", "delete", "void", "throw", "yield", "await":
+			return true
+		}
+		return false
+	case jsPunct:
+		// A slash after < is the end of a JSX tag.
+		return last.text != ")" && last.text != "]" && last.text != "}" && last.text != "<"
+	}
+	return false
+}
+
+func unquoteJS(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// webappSource returns the ids of the formatMessage({id: '...'}) calls and
+// the <FormattedMessage id="..."/> elements of the source.
+func (e *Extractor) webappSource(filePath string, src []byte) []Ref {
+	tokens := lexJS(src)
+	keys := []Ref{}
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if token.kind != jsIdent {
+			continue
+		}
+		switch {
+		case token.text == "formatMessage" && i+1 < len(tokens) && tokens[i+1].text == "(":
+			if i+2 < len(tokens) && tokens[i+2].text == "{" {
+				if ref, ok := messageDescriptor(tokens, i+2); ok {
+					ref.Line, ref.CallKind = token.line, token.text
+					keys = append(keys, ref)
+					continue
+				}
+			}
+			e.nonLiteral(filePath, token)
+		case formattedMessageComponents[token.text] && i > 0 && tokens[i-1].text == "<":
+			if ref, ok := formattedMessageId(tokens, i+1); ok {
+				ref.Line, ref.CallKind = token.line, token.text
+				keys = append(keys, ref)
+				continue
+			}
+			e.nonLiteral(filePath, token)
+		}
+	}
+	return keys
+}
+
+func (e *Extractor) nonLiteral(filePath string, call jsToken) {
+	if e.opts.OnNonLiteral != nil {
+		e.opts.OnNonLiteral(token.Position{Filename: filePath, Line: call.line}, call.text)
+	}
+}
+
+// messageDescriptor reads the id and description of the object literal
+// starting at the brace token.
+func messageDescriptor(tokens []jsToken, brace int) (Ref, bool) {
+	ref := Ref{}
+	depth := 0
+	for i := brace; i < len(tokens); i++ {
+		if tokens[i].kind == jsPunct {
+			switch tokens[i].text {
+			case "{", "(", "[":
+				depth++
+				continue
+			case "}", ")", "]":
+				depth--
+				if depth == 0 {
+					return ref, ref.Id != ""
+				}
+				continue
+			}
+		}
+		if depth != 1 || i+2 >= len(tokens) || tokens[i+1].text != ":" || tokens[i+2].kind != jsString {
+			continue
+		}
+		if tokens[i].kind != jsIdent && tokens[i].kind != jsString {
+			continue
+		}
+		switch tokens[i].text {
+		case "id":
+			ref.Id = tokens[i+2].text
+		case "description":
+			ref.Description = tokens[i+2].text
+		}
+	}
+	return ref, false
+}
+
+// formattedMessageId reads the id and description attributes of the JSX
+// element whose attributes start at the token.
+func formattedMessageId(tokens []jsToken, start int) (Ref, bool) {
+	ref := Ref{}
+	for i := start; i+2 < len(tokens); i++ {
+		if tokens[i].text == ">" || (tokens[i].text == "/" && tokens[i+1].text == ">") {
+			break
+		}
+		if tokens[i].kind != jsIdent || tokens[i+1].text != "=" {
+			continue
+		}
+		value := tokens[i+2]
+		if value.text == "{" && i+4 < len(tokens) && tokens[i+4].text == "}" {
+			value = tokens[i+3]
+		}
+		if value.kind != jsString {
+			continue
+		}
+		switch tokens[i].text {
+		case "id":
+			ref.Id = value.text
+		case "description":
+			ref.Description = value.text
+		}
+	}
+	return ref, ref.Id != ""
+}